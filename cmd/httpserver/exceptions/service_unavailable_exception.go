@@ -0,0 +1,20 @@
+package exceptions
+
+import "net/http"
+
+type ServiceUnavailableException struct {
+	HTTPCode   int    `json:"code" default:"503"`
+	ErrMessage string `json:"error" default:"error message"`
+}
+
+func (exception ServiceUnavailableException) Error() string {
+	return exception.ErrMessage
+}
+
+func (exception ServiceUnavailableException) Code() int {
+	return exception.HTTPCode
+}
+
+func NewServiceUnavailableException(message string) ServiceUnavailableException {
+	return ServiceUnavailableException{ErrMessage: message, HTTPCode: http.StatusServiceUnavailable}
+}
@@ -0,0 +1,20 @@
+package exceptions
+
+import "net/http"
+
+type TooManyRequestsException struct {
+	HTTPCode   int    `json:"code" default:"429"`
+	ErrMessage string `json:"error" default:"error message"`
+}
+
+func (exception TooManyRequestsException) Error() string {
+	return exception.ErrMessage
+}
+
+func (exception TooManyRequestsException) Code() int {
+	return exception.HTTPCode
+}
+
+func NewTooManyRequestsException(message string) TooManyRequestsException {
+	return TooManyRequestsException{ErrMessage: message, HTTPCode: http.StatusTooManyRequests}
+}
@@ -11,14 +11,61 @@ func (s *Server) Routes() {
 	root := s.Server.Group(s.dependencies.Config.Prefix)
 	root.GET("/swagger/*", echoSwagger.WrapHandler)
 	root.POST("/migrate", s.dependencies.MigrationHandler.UploadMigrationCSV)
+	root.POST("/migrate/validate", s.dependencies.MigrationHandler.ValidateMigrationCSV)
+	root.GET("/migrations", s.dependencies.MigrationHandler.ListMigrations)
+	root.GET("/migrations/:job_id", s.dependencies.MigrationHandler.GetMigrationJob)
+	root.GET("/migrations/:job_id/rejects", s.dependencies.MigrationHandler.GetMigrationRejects)
+	root.GET("/migrations/:job_id/transactions", s.dependencies.MigrationHandler.GetMigrationTransactions)
+	root.POST("/migrations/:job_id/rollback", s.dependencies.MigrationHandler.RollbackMigration)
+	root.GET("/changes", s.dependencies.ChangeHandler.ListChanges)
+
+	uploadsGroup := root.Group("/uploads")
+	uploadsGroup.POST("", s.dependencies.MigrationUploadHandler.CreateMigrationUpload)
+	uploadsGroup.GET("/:upload_id", s.dependencies.MigrationUploadHandler.GetMigrationUpload)
+	uploadsGroup.PUT("/:upload_id", s.dependencies.MigrationUploadHandler.WriteMigrationUploadChunk)
+	uploadsGroup.POST("/:upload_id/finalize", s.dependencies.MigrationUploadHandler.FinalizeMigrationUpload)
+	uploadsGroup.DELETE("/:upload_id", s.dependencies.MigrationUploadHandler.DeleteMigrationUpload)
+
+	profilesGroup := root.Group("/migration-profiles")
+	profilesGroup.POST("", s.dependencies.MigrationProfileHandler.CreateMigrationProfile)
+	profilesGroup.GET("", s.dependencies.MigrationProfileHandler.ListMigrationProfiles)
+	profilesGroup.GET("/:name", s.dependencies.MigrationProfileHandler.GetMigrationProfile)
+	profilesGroup.DELETE("/:name", s.dependencies.MigrationProfileHandler.DeleteMigrationProfile)
+
+	balancesGroup := root.Group("/balances")
+	balancesGroup.POST("/query", s.dependencies.BalanceHandler.QueryBalances)
 
 	usersGroup := root.Group("/users")
+	usersGroup.GET("", s.dependencies.UserHandler.ListUsers)
 	usersGroup.GET("/:user_id/balance", s.dependencies.BalanceHandler.GetUserBalanceWithOptions)
+	usersGroup.GET("/:user_id/balance/stream", s.dependencies.BalanceStreamHandler.StreamUserBalance)
+	usersGroup.GET("/:user_id/transactions", s.dependencies.TransactionHandler.ListUserTransactions)
+	usersGroup.POST("/:user_id/alert-rules", s.dependencies.AlertHandler.CreateAlertRule)
+	usersGroup.GET("/:user_id/alert-rules", s.dependencies.AlertHandler.ListAlertRules)
+	usersGroup.GET("/:user_id/alert-rules/:rule_id", s.dependencies.AlertHandler.GetAlertRule)
+	usersGroup.PUT("/:user_id/alert-rules/:rule_id", s.dependencies.AlertHandler.UpdateAlertRule)
+	usersGroup.DELETE("/:user_id/alert-rules/:rule_id", s.dependencies.AlertHandler.DeleteAlertRule)
+	usersGroup.GET("/:user_id/alerts", s.dependencies.AlertHandler.ListAlerts)
 	usersGroup.POST("/create", s.dependencies.UserHandler.CreateUser)
 	usersGroup.PUT("/:id", s.dependencies.UserHandler.UpdateUser)
 	usersGroup.DELETE("/:id", s.dependencies.UserHandler.DeleteUser)
 	usersGroup.GET("/:id", s.dependencies.UserHandler.GetUser)
 
+	adminGroup := root.Group("/admin")
+	adminGroup.GET("/outbox", s.dependencies.OutboxHandler.ListOutboxMessages)
+	adminGroup.GET("/outbox/:message_id", s.dependencies.OutboxHandler.GetOutboxMessage)
+	adminGroup.POST("/outbox/:message_id/redrive", s.dependencies.OutboxHandler.RedriveOutboxMessage)
+
+	webhooksGroup := root.Group("/webhooks")
+	webhooksGroup.POST("", s.dependencies.WebhookHandler.CreateWebhook)
+	webhooksGroup.GET("", s.dependencies.WebhookHandler.ListWebhooks)
+	webhooksGroup.GET("/:webhook_id", s.dependencies.WebhookHandler.GetWebhook)
+	webhooksGroup.PUT("/:webhook_id", s.dependencies.WebhookHandler.UpdateWebhook)
+	webhooksGroup.DELETE("/:webhook_id", s.dependencies.WebhookHandler.DeleteWebhook)
+	webhooksGroup.GET("/:webhook_id/deliveries", s.dependencies.WebhookHandler.ListWebhookDeliveries)
+	webhooksGroup.POST("/:webhook_id/deliveries/:delivery_id/replay",
+		s.dependencies.WebhookHandler.ReplayWebhookDelivery)
+
 	transactionsGroup := root.Group("/transactions")
 	transactionsGroup.POST("/create", s.dependencies.TransactionHandler.CreateTransaction)
 	transactionsGroup.PUT("/:id", s.dependencies.TransactionHandler.UpdateTransaction)
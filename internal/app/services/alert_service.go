@@ -0,0 +1,286 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/sebastianreh/user-balance-api/internal/domain/alert"
+	"github.com/sebastianreh/user-balance-api/internal/domain/balance"
+	"github.com/sebastianreh/user-balance-api/internal/domain/outbox"
+	"github.com/sebastianreh/user-balance-api/internal/domain/transaction"
+	"github.com/sebastianreh/user-balance-api/internal/domain/user"
+	"github.com/sebastianreh/user-balance-api/pkg/email"
+	"github.com/sebastianreh/user-balance-api/pkg/logger"
+)
+
+const (
+	alertServiceName = "AlertService"
+)
+
+type AlertService interface {
+	// CreateRule saves a rule of an existing user, it's sent to the email of the user when it has no recipients
+	CreateRule(ctx context.Context, rule alert.Rule) (alert.Rule, error)
+	UpdateRule(ctx context.Context, rule alert.Rule) (alert.Rule, error)
+	GetRule(ctx context.Context, userID, ruleID string) (alert.Rule, error)
+	ListRules(ctx context.Context, userID string) ([]alert.Rule, error)
+	DeleteRule(ctx context.Context, userID, ruleID string) error
+	ListAlerts(ctx context.Context, options alert.ListOptions) (alert.ListPage, error)
+	// Check fires the active rules of the users and of the users of the transactions, the balance rules against the
+	// current balance and the debit rules against the transactions. The alerts are emailed through the outbox
+	Check(ctx context.Context, userIDs []string, transactions []transaction.Transaction) error
+}
+
+type alertService struct {
+	log               logger.Logger
+	repository        alert.Repository
+	userRepository    user.Repository
+	balanceRepository balance.Repository
+}
+
+func NewAlertService(log logger.Logger, repository alert.Repository, userRepository user.Repository,
+	balanceRepository balance.Repository) AlertService {
+	return &alertService{
+		log:               log,
+		repository:        repository,
+		userRepository:    userRepository,
+		balanceRepository: balanceRepository,
+	}
+}
+
+func (s *alertService) CreateRule(ctx context.Context, rule alert.Rule) (alert.Rule, error) {
+	rule, err := s.withRecipients(ctx, rule)
+	if err != nil {
+		return rule, err
+	}
+
+	saved, err := s.repository.SaveRule(ctx, rule)
+	if err != nil {
+		return saved, err
+	}
+
+	s.checkRule(ctx, saved)
+	return saved, nil
+}
+
+func (s *alertService) UpdateRule(ctx context.Context, rule alert.Rule) (alert.Rule, error) {
+	rule, err := s.withRecipients(ctx, rule)
+	if err != nil {
+		return rule, err
+	}
+
+	updated, err := s.repository.UpdateRule(ctx, rule)
+	if err != nil {
+		return updated, err
+	}
+
+	s.checkRule(ctx, updated)
+	return updated, nil
+}
+
+func (s *alertService) GetRule(ctx context.Context, userID, ruleID string) (alert.Rule, error) {
+	return s.repository.FindRule(ctx, userID, ruleID)
+}
+
+func (s *alertService) ListRules(ctx context.Context, userID string) ([]alert.Rule, error) {
+	if _, err := s.userRepository.FindByID(ctx, userID); err != nil {
+		return nil, err
+	}
+
+	return s.repository.ListRules(ctx, userID)
+}
+
+func (s *alertService) DeleteRule(ctx context.Context, userID, ruleID string) error {
+	return s.repository.DeleteRule(ctx, userID, ruleID)
+}
+
+func (s *alertService) ListAlerts(ctx context.Context, options alert.ListOptions) (alert.ListPage, error) {
+	page := alert.ListPage{Alerts: make([]alert.Alert, 0)}
+	if _, err := s.userRepository.FindByID(ctx, options.UserID); err != nil {
+		return page, err
+	}
+
+	limit := options.Limit
+	// One extra alert tells if there is a next page
+	options.Limit = limit + 1
+	alerts, err := s.repository.ListAlerts(ctx, options)
+	if err != nil {
+		return page, err
+	}
+
+	if len(alerts) > limit {
+		alerts = alerts[:limit]
+		page.NextCursor = alert.Cursor{ID: alerts[limit-1].ID}.Encode()
+	}
+
+	page.Alerts = alerts
+	return page, nil
+}
+
+func (s *alertService) Check(ctx context.Context, userIDs []string, transactions []transaction.Transaction) error {
+	rules, err := s.repository.FindActiveRules(ctx, usersOf(userIDs, transactions))
+	if err != nil || len(rules) == 0 {
+		return err
+	}
+
+	balances, err := s.ruleBalances(ctx, rules)
+	if err != nil {
+		return err
+	}
+
+	var errs []error
+	var cleared []string
+	for _, rule := range rules {
+		switch rule.Type {
+		case alert.TypeBalanceBelow:
+			userBalance, found := balances[rule.UserID]
+			if !found {
+				continue
+			}
+
+			if !rule.Triggers(userBalance.Balance) {
+				if rule.TriggeredAt != nil {
+					cleared = append(cleared, rule.ID)
+				}
+				continue
+			}
+
+			if rule.TriggeredAt == nil {
+				errs = append(errs, s.fire(ctx, rule, userBalance.Balance, ""))
+			}
+		case alert.TypeDebitAbove:
+			for _, userTransaction := range transactions {
+				if userTransaction.UserID == rule.UserID && rule.Debits(userTransaction.Amount) {
+					errs = append(errs, s.fire(ctx, rule, userTransaction.Amount, userTransaction.ID))
+				}
+			}
+		}
+	}
+
+	if len(cleared) > 0 {
+		errs = append(errs, s.repository.Clear(ctx, cleared))
+	}
+
+	if err = errors.Join(errs...); err != nil {
+		s.log.ErrorAt(err, alertServiceName, "Check")
+		return err
+	}
+
+	return nil
+}
+
+// withRecipients checks the user of the rule, a rule without recipients is sent to the email of the user
+func (s *alertService) withRecipients(ctx context.Context, rule alert.Rule) (alert.Rule, error) {
+	ruleUser, err := s.userRepository.FindByID(ctx, rule.UserID)
+	if err != nil {
+		return rule, err
+	}
+
+	if len(rule.Recipients) == 0 {
+		if ruleUser.Email == "" {
+			return rule, errors.New(alert.MissingRecipientsError)
+		}
+		rule.Recipients = []string{ruleUser.Email}
+	}
+
+	return rule, nil
+}
+
+// checkRule checks a balance rule right away, so a balance that is already below it is alerted without waiting for
+// the next change. The rule is saved when this runs, so errors are only logged
+func (s *alertService) checkRule(ctx context.Context, rule alert.Rule) {
+	if rule.Type != alert.TypeBalanceBelow || !rule.Active {
+		return
+	}
+
+	if err := s.Check(ctx, []string{rule.UserID}, nil); err != nil {
+		s.log.ErrorAt(fmt.Errorf("could not check alert rule %s: %w", rule.ID, err), alertServiceName, "checkRule")
+	}
+}
+
+// ruleBalances returns the balances of the users with balance rules
+func (s *alertService) ruleBalances(ctx context.Context, rules []alert.Rule) (map[string]balance.UserBalance, error) {
+	var userIDs []string
+	for _, rule := range rules {
+		if rule.Type == alert.TypeBalanceBelow {
+			userIDs = append(userIDs, rule.UserID)
+		}
+	}
+
+	if len(userIDs) == 0 {
+		return nil, nil
+	}
+
+	balances, err := s.balanceRepository.FindByUserIDs(ctx, usersOf(userIDs, nil), "", "")
+	if err != nil {
+		s.log.ErrorAt(err, alertServiceName, "ruleBalances")
+		return nil, err
+	}
+
+	for userID, userBalance := range balances {
+		userBalance.RoundBalanceToTwoDecimalPlaces()
+		balances[userID] = userBalance
+	}
+
+	return balances, nil
+}
+
+// fire saves the alert of the rule with its email, nothing is sent when the rule already fired for the condition
+func (s *alertService) fire(ctx context.Context, rule alert.Rule, value float64, transactionID string) error {
+	firedAlert := alert.Alert{RuleID: rule.ID, UserID: rule.UserID, Type: rule.Type, Threshold: rule.Threshold,
+		Value: value, TransactionID: transactionID, Recipients: rule.Recipients}
+	alertEmail := alertMessage(firedAlert)
+	message, err := outbox.NewMessage(outbox.KindEmail, alertEmail.Subject, alertEmail)
+	if err != nil {
+		return err
+	}
+
+	fired, err := s.repository.Fire(ctx, firedAlert, message)
+	if err != nil {
+		return fmt.Errorf("could not fire alert rule %s: %w", rule.ID, err)
+	}
+
+	if fired {
+		s.log.Info("Alert fired", "rule_id", rule.ID, "user_id", rule.UserID, "type", rule.Type)
+	}
+
+	return nil
+}
+
+// alertMessage is the email of an alert, it tells what crossed the threshold of which rule
+func alertMessage(firedAlert alert.Alert) email.Message {
+	message := email.Message{To: firedAlert.Recipients}
+	if firedAlert.Type == alert.TypeDebitAbove {
+		message.Subject = fmt.Sprintf("Debit of %.2f for user %s", -firedAlert.Value, firedAlert.UserID)
+		message.Text = fmt.Sprintf("Transaction %s debited %.2f from user %s, above the %.2f of alert rule %s.",
+			firedAlert.TransactionID, -firedAlert.Value, firedAlert.UserID, firedAlert.Threshold, firedAlert.RuleID)
+		return message
+	}
+
+	message.Subject = fmt.Sprintf("Balance of user %s below %.2f", firedAlert.UserID, firedAlert.Threshold)
+	message.Text = fmt.Sprintf("The balance of user %s is %.2f, below the %.2f of alert rule %s. It will be alerted "+
+		"again once the balance goes back to %.2f or above and drops again.", firedAlert.UserID, firedAlert.Value,
+		firedAlert.Threshold, firedAlert.RuleID, firedAlert.Threshold)
+	return message
+}
+
+// usersOf returns the users and the users of the transactions without repeating them
+func usersOf(userIDs []string, transactions []transaction.Transaction) []string {
+	seen := make(map[string]bool, len(userIDs)+len(transactions))
+	users := make([]string, 0, len(userIDs)+len(transactions))
+	for _, userID := range userIDs {
+		if userID != "" && !seen[userID] {
+			seen[userID] = true
+			users = append(users, userID)
+		}
+	}
+
+	for _, userTransaction := range transactions {
+		if userTransaction.UserID != "" && !seen[userTransaction.UserID] {
+			seen[userTransaction.UserID] = true
+			users = append(users, userTransaction.UserID)
+		}
+	}
+
+	return users
+}
@@ -0,0 +1,242 @@
+package services_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/sebastianreh/user-balance-api/internal/app/services"
+	"github.com/sebastianreh/user-balance-api/internal/domain/alert"
+	"github.com/sebastianreh/user-balance-api/internal/domain/balance"
+	"github.com/sebastianreh/user-balance-api/internal/domain/outbox"
+	"github.com/sebastianreh/user-balance-api/internal/domain/transaction"
+	"github.com/sebastianreh/user-balance-api/internal/domain/user"
+	"github.com/sebastianreh/user-balance-api/pkg/email"
+	"github.com/sebastianreh/user-balance-api/pkg/logger"
+	"github.com/sebastianreh/user-balance-api/test/mocks"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func Test_AlertService_Rules(t *testing.T) {
+	ctx := context.TODO()
+	log := logger.NewLogger()
+	debitRule := alert.Rule{UserID: "1", Type: alert.TypeDebitAbove, Threshold: 1000, Active: true}
+
+	t.Run("When a rule without recipients is sent to the email of the user", func(t *testing.T) {
+		repository := mocks.NewAlertRepositoryMock()
+		userRepository := mocks.NewUserRepositoryMock()
+		userRepository.On("FindByID", ctx, "1").Return(user.User{ID: "1", Email: "user@example.com"}, nil)
+		expected := debitRule
+		expected.Recipients = []string{"user@example.com"}
+		repository.On("SaveRule", ctx, expected).Return(alert.Rule{ID: "3"}, nil)
+
+		service := services.NewAlertService(log, repository, userRepository, mocks.NewBalanceRepositoryMock())
+		created, err := service.CreateRule(ctx, debitRule)
+
+		assert.Nil(t, err)
+		assert.Equal(t, "3", created.ID)
+		repository.AssertExpectations(t)
+	})
+
+	t.Run("When the rule has no recipients and the user has no email", func(t *testing.T) {
+		repository := mocks.NewAlertRepositoryMock()
+		userRepository := mocks.NewUserRepositoryMock()
+		userRepository.On("FindByID", ctx, "1").Return(user.User{ID: "1"}, nil)
+
+		service := services.NewAlertService(log, repository, userRepository, mocks.NewBalanceRepositoryMock())
+		_, err := service.CreateRule(ctx, debitRule)
+
+		assert.EqualError(t, err, alert.MissingRecipientsError)
+		repository.AssertNotCalled(t, "SaveRule", mock.Anything, mock.Anything)
+	})
+
+	t.Run("When the user of the rule doesn't exist", func(t *testing.T) {
+		userRepository := mocks.NewUserRepositoryMock()
+		userRepository.On("FindByID", ctx, "1").Return(user.User{}, errors.New(user.NotFoundError))
+
+		service := services.NewAlertService(log, mocks.NewAlertRepositoryMock(), userRepository,
+			mocks.NewBalanceRepositoryMock())
+		_, err := service.UpdateRule(ctx, debitRule)
+
+		assert.EqualError(t, err, user.NotFoundError)
+	})
+
+	t.Run("When a new balance rule is checked against the current balance", func(t *testing.T) {
+		balanceRule := alert.Rule{ID: "4", UserID: "1", Type: alert.TypeBalanceBelow, Threshold: 100,
+			Recipients: []string{"ops@example.com"}, Active: true}
+		repository := mocks.NewAlertRepositoryMock()
+		userRepository := mocks.NewUserRepositoryMock()
+		balanceRepository := mocks.NewBalanceRepositoryMock()
+		userRepository.On("FindByID", ctx, "1").Return(user.User{ID: "1"}, nil)
+		repository.On("SaveRule", ctx, balanceRule).Return(balanceRule, nil)
+		repository.On("FindActiveRules", ctx, []string{"1"}).Return([]alert.Rule{balanceRule}, nil)
+		balanceRepository.On("FindByUserIDs", ctx, []string{"1"}, "", "").
+			Return(map[string]balance.UserBalance{"1": {Balance: 20}}, nil)
+		repository.On("Fire", ctx, mock.Anything, mock.Anything).Return(true, nil)
+
+		service := services.NewAlertService(log, repository, userRepository, balanceRepository)
+		_, err := service.CreateRule(ctx, balanceRule)
+
+		assert.Nil(t, err)
+		repository.AssertNumberOfCalls(t, "Fire", 1)
+	})
+}
+
+func Test_AlertService_Check(t *testing.T) {
+	ctx := context.TODO()
+	log := logger.NewLogger()
+	triggeredAt := time.Now()
+	balanceRule := alert.Rule{ID: "1", UserID: "1", Type: alert.TypeBalanceBelow, Threshold: 100,
+		Recipients: []string{"ops@example.com"}, Active: true}
+	debitRule := alert.Rule{ID: "2", UserID: "2", Type: alert.TypeDebitAbove, Threshold: 1000,
+		Recipients: []string{"ops@example.com"}, Active: true}
+
+	t.Run("When a balance drops below the rule the alert is emailed", func(t *testing.T) {
+		repository := mocks.NewAlertRepositoryMock()
+		balanceRepository := mocks.NewBalanceRepositoryMock()
+		repository.On("FindActiveRules", ctx, []string{"1"}).Return([]alert.Rule{balanceRule}, nil)
+		balanceRepository.On("FindByUserIDs", ctx, []string{"1"}, "", "").
+			Return(map[string]balance.UserBalance{"1": {Balance: 80.004}}, nil)
+		repository.On("Fire", ctx, alert.Alert{RuleID: "1", UserID: "1", Type: alert.TypeBalanceBelow, Threshold: 100,
+			Value: 80, Recipients: []string{"ops@example.com"}}, mock.MatchedBy(func(message outbox.Message) bool {
+			var payload email.Message
+			_ = json.Unmarshal(message.Payload, &payload)
+			return message.Kind == outbox.KindEmail && payload.Subject == "Balance of user 1 below 100.00" &&
+				payload.To[0] == "ops@example.com"
+		})).Return(true, nil)
+
+		service := services.NewAlertService(log, repository, mocks.NewUserRepositoryMock(), balanceRepository)
+		err := service.Check(ctx, []string{"1"}, nil)
+
+		assert.Nil(t, err)
+		repository.AssertExpectations(t)
+	})
+
+	t.Run("When a triggered balance rule is not fired again", func(t *testing.T) {
+		triggered := balanceRule
+		triggered.TriggeredAt = &triggeredAt
+		repository := mocks.NewAlertRepositoryMock()
+		balanceRepository := mocks.NewBalanceRepositoryMock()
+		repository.On("FindActiveRules", ctx, []string{"1"}).Return([]alert.Rule{triggered}, nil)
+		balanceRepository.On("FindByUserIDs", ctx, []string{"1"}, "", "").
+			Return(map[string]balance.UserBalance{"1": {Balance: 50}}, nil)
+
+		service := services.NewAlertService(log, repository, mocks.NewUserRepositoryMock(), balanceRepository)
+		err := service.Check(ctx, []string{"1"}, nil)
+
+		assert.Nil(t, err)
+		repository.AssertNotCalled(t, "Fire", mock.Anything, mock.Anything, mock.Anything)
+		repository.AssertNotCalled(t, "Clear", mock.Anything, mock.Anything)
+	})
+
+	t.Run("When the balance recovers the rule is cleared to fire again", func(t *testing.T) {
+		triggered := balanceRule
+		triggered.TriggeredAt = &triggeredAt
+		repository := mocks.NewAlertRepositoryMock()
+		balanceRepository := mocks.NewBalanceRepositoryMock()
+		repository.On("FindActiveRules", ctx, []string{"1"}).Return([]alert.Rule{triggered}, nil)
+		balanceRepository.On("FindByUserIDs", ctx, []string{"1"}, "", "").
+			Return(map[string]balance.UserBalance{"1": {Balance: 100}}, nil)
+		repository.On("Clear", ctx, []string{"1"}).Return(nil)
+
+		service := services.NewAlertService(log, repository, mocks.NewUserRepositoryMock(), balanceRepository)
+		err := service.Check(ctx, []string{"1"}, nil)
+
+		assert.Nil(t, err)
+		repository.AssertExpectations(t)
+	})
+
+	t.Run("When every large debit fires its own alert", func(t *testing.T) {
+		transactions := []transaction.Transaction{
+			{ID: "10", UserID: "2", Amount: -1500},
+			{ID: "11", UserID: "2", Amount: -200},
+			{ID: "12", UserID: "2", Amount: 5000},
+			{ID: "13", UserID: "2", Amount: -1000.5},
+		}
+		repository := mocks.NewAlertRepositoryMock()
+		balanceRepository := mocks.NewBalanceRepositoryMock()
+		repository.On("FindActiveRules", ctx, []string{"2"}).Return([]alert.Rule{debitRule}, nil)
+		repository.On("Fire", ctx, mock.MatchedBy(func(fired alert.Alert) bool {
+			return fired.TransactionID == "10" && fired.Value == -1500
+		}), mock.Anything).Return(true, nil)
+		repository.On("Fire", ctx, mock.MatchedBy(func(fired alert.Alert) bool {
+			return fired.TransactionID == "13"
+		}), mock.Anything).Return(false, nil)
+
+		service := services.NewAlertService(log, repository, mocks.NewUserRepositoryMock(), balanceRepository)
+		err := service.Check(ctx, nil, transactions)
+
+		assert.Nil(t, err)
+		repository.AssertNumberOfCalls(t, "Fire", 2)
+		balanceRepository.AssertNotCalled(t, "FindByUserIDs", mock.Anything, mock.Anything, mock.Anything,
+			mock.Anything)
+	})
+
+	t.Run("When an alert can't be saved the other rules are still checked", func(t *testing.T) {
+		repository := mocks.NewAlertRepositoryMock()
+		balanceRepository := mocks.NewBalanceRepositoryMock()
+		repository.On("FindActiveRules", ctx, []string{"1", "2"}).Return([]alert.Rule{balanceRule, debitRule}, nil)
+		balanceRepository.On("FindByUserIDs", ctx, []string{"1"}, "", "").
+			Return(map[string]balance.UserBalance{"1": {Balance: 0}}, nil)
+		repository.On("Fire", ctx, mock.MatchedBy(func(fired alert.Alert) bool {
+			return fired.RuleID == "1"
+		}), mock.Anything).Return(false, errors.New("connection reset"))
+		repository.On("Fire", ctx, mock.MatchedBy(func(fired alert.Alert) bool {
+			return fired.RuleID == "2"
+		}), mock.Anything).Return(true, nil)
+
+		service := services.NewAlertService(log, repository, mocks.NewUserRepositoryMock(), balanceRepository)
+		err := service.Check(ctx, []string{"1"}, []transaction.Transaction{{ID: "10", UserID: "2", Amount: -1500}})
+
+		assert.ErrorContains(t, err, "could not fire alert rule 1: connection reset")
+		repository.AssertNumberOfCalls(t, "Fire", 2)
+	})
+
+	t.Run("When the users have no rules", func(t *testing.T) {
+		repository := mocks.NewAlertRepositoryMock()
+		balanceRepository := mocks.NewBalanceRepositoryMock()
+		repository.On("FindActiveRules", ctx, []string{"1"}).Return([]alert.Rule{}, nil)
+
+		service := services.NewAlertService(log, repository, mocks.NewUserRepositoryMock(), balanceRepository)
+		err := service.Check(ctx, []string{"1", "1"}, nil)
+
+		assert.Nil(t, err)
+		balanceRepository.AssertNotCalled(t, "FindByUserIDs", mock.Anything, mock.Anything, mock.Anything,
+			mock.Anything)
+	})
+}
+
+func Test_AlertService_ListAlerts(t *testing.T) {
+	ctx := context.TODO()
+	log := logger.NewLogger()
+
+	t.Run("When there is a next page its cursor is the last alert", func(t *testing.T) {
+		repository := mocks.NewAlertRepositoryMock()
+		userRepository := mocks.NewUserRepositoryMock()
+		userRepository.On("FindByID", ctx, "1").Return(user.User{ID: "1"}, nil)
+		repository.On("ListAlerts", ctx, alert.ListOptions{UserID: "1", Limit: 3}).
+			Return([]alert.Alert{{ID: "9"}, {ID: "7"}, {ID: "4"}}, nil)
+
+		service := services.NewAlertService(log, repository, userRepository, mocks.NewBalanceRepositoryMock())
+		page, err := service.ListAlerts(ctx, alert.ListOptions{UserID: "1", Limit: 2})
+
+		assert.Nil(t, err)
+		assert.Len(t, page.Alerts, 2)
+		assert.Equal(t, alert.Cursor{ID: "7"}.Encode(), page.NextCursor)
+	})
+
+	t.Run("When the user doesn't exist", func(t *testing.T) {
+		userRepository := mocks.NewUserRepositoryMock()
+		userRepository.On("FindByID", ctx, "1").Return(user.User{}, errors.New(user.NotFoundError))
+
+		service := services.NewAlertService(log, mocks.NewAlertRepositoryMock(), userRepository,
+			mocks.NewBalanceRepositoryMock())
+		page, err := service.ListAlerts(ctx, alert.ListOptions{UserID: "1", Limit: 2})
+
+		assert.EqualError(t, err, user.NotFoundError)
+		assert.Empty(t, page.Alerts)
+	})
+}
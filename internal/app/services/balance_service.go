@@ -17,21 +17,26 @@ const (
 type BalanceService interface {
 	GetBalanceByUserIDWithOptions(ctx context.Context, userID, fromDate, toDate string) (balance.UserBalance, error)
 	GetBalanceByUserID(ctx context.Context, userID string) (balance.UserBalance, error)
+	GetBalancesByUserIDs(ctx context.Context, query balance.BatchQuery) (balance.BatchResult, error)
 }
 
 type balanceService struct {
 	log                   logger.Logger
 	userRepository        user.Repository
 	transactionRepository transaction.Repository
+	balanceRepository     balance.Repository
 	balanceCalculator     balance.Calculator
 }
 
-func NewBalanceService(log logger.Logger, userRepository user.Repository,
-	transactionRepository transaction.Repository, balanceCalculator balance.Calculator) BalanceService {
+// NewBalanceService aggregates balances in the balance repository, when it is nil the transactions of a single user
+// are loaded and summed by the calculator instead. The batch balances are only aggregated by the repository
+func NewBalanceService(log logger.Logger, userRepository user.Repository, transactionRepository transaction.Repository,
+	balanceRepository balance.Repository, balanceCalculator balance.Calculator) BalanceService {
 	return &balanceService{
 		log:                   log,
 		userRepository:        userRepository,
 		transactionRepository: transactionRepository,
+		balanceRepository:     balanceRepository,
 		balanceCalculator:     balanceCalculator,
 	}
 }
@@ -44,6 +49,10 @@ func (s balanceService) GetBalanceByUserIDWithOptions(ctx context.Context, userI
 		return userBalance, err
 	}
 
+	if s.balanceRepository != nil {
+		return s.balanceRepository.FindByUserID(ctx, userID, fromDate, toDate)
+	}
+
 	transactions, err := s.transactionRepository.FindByUserIDWithOptions(ctx, userID, fromDate, toDate)
 	if err != nil {
 		return userBalance, err
@@ -57,3 +66,32 @@ func (s balanceService) GetBalanceByUserIDWithOptions(ctx context.Context, userI
 func (s balanceService) GetBalanceByUserID(ctx context.Context, userID string) (balance.UserBalance, error) {
 	return s.GetBalanceByUserIDWithOptions(ctx, userID, customStr.Empty, customStr.Empty)
 }
+
+func (s balanceService) GetBalancesByUserIDs(ctx context.Context, query balance.BatchQuery) (balance.BatchResult, error) {
+	result := balance.BatchResult{
+		Balances: make(map[string]balance.UserBalance),
+		Errors:   make(map[string]string),
+	}
+
+	toDate := query.To
+	if !customStr.IsEmpty(query.AsOf) {
+		toDate = query.AsOf
+	}
+
+	balances, err := s.balanceRepository.FindByUserIDs(ctx, query.UserIDs, query.From, toDate)
+	if err != nil {
+		return result, err
+	}
+
+	for _, userID := range query.UserIDs {
+		userBalance, found := balances[userID]
+		if !found {
+			result.Errors[userID] = UserNotFound
+			continue
+		}
+
+		result.Balances[userID] = userBalance
+	}
+
+	return result, nil
+}
@@ -13,6 +13,7 @@ import (
 	"github.com/sebastianreh/user-balance-api/pkg/logger"
 	"github.com/sebastianreh/user-balance-api/test/mocks"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
 )
 
 func Test_BalanceService_GetBalanceByUserIDWithOptions(t *testing.T) {
@@ -53,7 +54,7 @@ func Test_BalanceService_GetBalanceByUserIDWithOptions(t *testing.T) {
 		calculator := mocks.NewCalculatorMock()
 		calculator.On("CalculateBalanceByUser", transactions).Return(expectedBalance)
 
-		service := services.NewBalanceService(logger.NewLogger(), userRepo, transactionRepo, calculator)
+		service := services.NewBalanceService(logger.NewLogger(), userRepo, transactionRepo, nil, calculator)
 		userBalance, err := service.GetBalanceByUserIDWithOptions(ctx, userID, fromDate, toDate)
 
 		assert.Nil(t, err)
@@ -70,7 +71,7 @@ func Test_BalanceService_GetBalanceByUserIDWithOptions(t *testing.T) {
 
 		calculator := mocks.NewCalculatorMock()
 
-		service := services.NewBalanceService(logger.NewLogger(), userRepo, transactionRepo, calculator)
+		service := services.NewBalanceService(logger.NewLogger(), userRepo, transactionRepo, nil, calculator)
 		userBalance, err := service.GetBalanceByUserIDWithOptions(ctx, userID, fromDate, toDate)
 
 		assert.Error(t, err)
@@ -91,13 +92,51 @@ func Test_BalanceService_GetBalanceByUserIDWithOptions(t *testing.T) {
 
 		calculator := mocks.NewCalculatorMock()
 
-		service := services.NewBalanceService(logger.NewLogger(), userRepo, transactionRepo, calculator)
+		service := services.NewBalanceService(logger.NewLogger(), userRepo, transactionRepo, nil, calculator)
 		userBalance, err := service.GetBalanceByUserIDWithOptions(ctx, userID, fromDate, toDate)
 
 		assert.Error(t, err)
 		assert.Equal(t, expectedError, err)
 		assert.Equal(t, balance.UserBalance{}, userBalance)
 	})
+
+	t.Run("When GetBalanceByUserIDWithOptions aggregates in the balance repository", func(t *testing.T) {
+		expectedBalance := balance.UserBalance{Balance: -100, TotalDebits: 1, TotalCredits: 1}
+
+		userRepo := mocks.NewUserRepositoryMock()
+		userRepo.On("FindByID", ctx, userID).Return(user.User{ID: userID}, nil)
+
+		balanceRepo := mocks.NewBalanceRepositoryMock()
+		balanceRepo.On("FindByUserID", ctx, userID, fromDate, toDate).Return(expectedBalance, nil)
+
+		transactionRepo := mocks.NewTransactionRepositoryMock()
+		calculator := mocks.NewCalculatorMock()
+
+		service := services.NewBalanceService(logger.NewLogger(), userRepo, transactionRepo, balanceRepo, calculator)
+		userBalance, err := service.GetBalanceByUserIDWithOptions(ctx, userID, fromDate, toDate)
+
+		assert.Nil(t, err)
+		assert.Equal(t, expectedBalance, userBalance)
+		transactionRepo.AssertNotCalled(t, "FindByUserIDWithOptions", ctx, userID, fromDate, toDate)
+		calculator.AssertNotCalled(t, "CalculateBalanceByUser", mock.Anything)
+	})
+
+	t.Run("When GetBalanceByUserIDWithOptions balance repository returns error", func(t *testing.T) {
+		expectedError := errors.New("balance repository error")
+
+		userRepo := mocks.NewUserRepositoryMock()
+		userRepo.On("FindByID", ctx, userID).Return(user.User{ID: userID}, nil)
+
+		balanceRepo := mocks.NewBalanceRepositoryMock()
+		balanceRepo.On("FindByUserID", ctx, userID, fromDate, toDate).Return(balance.UserBalance{}, expectedError)
+
+		service := services.NewBalanceService(logger.NewLogger(), userRepo, mocks.NewTransactionRepositoryMock(),
+			balanceRepo, mocks.NewCalculatorMock())
+		userBalance, err := service.GetBalanceByUserIDWithOptions(ctx, userID, fromDate, toDate)
+
+		assert.Equal(t, expectedError, err)
+		assert.Equal(t, balance.UserBalance{}, userBalance)
+	})
 }
 
 func Test_BalanceService_GetBalanceByUserID(t *testing.T) {
@@ -136,7 +175,7 @@ func Test_BalanceService_GetBalanceByUserID(t *testing.T) {
 		calculator := mocks.NewCalculatorMock()
 		calculator.On("CalculateBalanceByUser", transactions).Return(expectedBalance)
 
-		service := services.NewBalanceService(logger.NewLogger(), userRepo, transactionRepo, calculator)
+		service := services.NewBalanceService(logger.NewLogger(), userRepo, transactionRepo, nil, calculator)
 		userBalance, err := service.GetBalanceByUserID(ctx, userID)
 
 		assert.Nil(t, err)
@@ -153,7 +192,7 @@ func Test_BalanceService_GetBalanceByUserID(t *testing.T) {
 
 		calculator := mocks.NewCalculatorMock()
 
-		service := services.NewBalanceService(logger.NewLogger(), userRepo, transactionRepo, calculator)
+		service := services.NewBalanceService(logger.NewLogger(), userRepo, transactionRepo, nil, calculator)
 		userBalance, err := service.GetBalanceByUserID(ctx, userID)
 
 		assert.Error(t, err)
@@ -174,7 +213,7 @@ func Test_BalanceService_GetBalanceByUserID(t *testing.T) {
 
 		calculator := mocks.NewCalculatorMock()
 
-		service := services.NewBalanceService(logger.NewLogger(), userRepo, transactionRepo, calculator)
+		service := services.NewBalanceService(logger.NewLogger(), userRepo, transactionRepo, nil, calculator)
 		userBalance, err := service.GetBalanceByUserID(ctx, userID)
 
 		assert.Error(t, err)
@@ -182,3 +221,73 @@ func Test_BalanceService_GetBalanceByUserID(t *testing.T) {
 		assert.Equal(t, balance.UserBalance{}, userBalance)
 	})
 }
+
+func Test_BalanceService_GetBalancesByUserIDs(t *testing.T) {
+	ctx := context.TODO()
+
+	t.Run("When GetBalancesByUserIDs marks missing users as not found", func(t *testing.T) {
+		query := balance.BatchQuery{UserIDs: []string{"1", "2", "abc"}, From: "2024-01-01T00:00:00Z"}
+		balances := map[string]balance.UserBalance{
+			"1": {Balance: 100, TotalCredits: 1},
+		}
+
+		balanceRepo := mocks.NewBalanceRepositoryMock()
+		balanceRepo.On("FindByUserIDs", ctx, query.UserIDs, query.From, "").Return(balances, nil)
+
+		service := services.NewBalanceService(logger.NewLogger(), mocks.NewUserRepositoryMock(),
+			mocks.NewTransactionRepositoryMock(), balanceRepo, mocks.NewCalculatorMock())
+		result, err := service.GetBalancesByUserIDs(ctx, query)
+
+		assert.Nil(t, err)
+		assert.Equal(t, balances, result.Balances)
+		assert.Equal(t, map[string]string{"2": services.UserNotFound, "abc": services.UserNotFound}, result.Errors)
+	})
+
+	t.Run("When GetBalancesByUserIDs uses as_of as the end date", func(t *testing.T) {
+		query := balance.BatchQuery{UserIDs: []string{"1"}, AsOf: "2024-06-30T00:00:00Z"}
+
+		balanceRepo := mocks.NewBalanceRepositoryMock()
+		balanceRepo.On("FindByUserIDs", ctx, query.UserIDs, "", query.AsOf).
+			Return(map[string]balance.UserBalance{"1": {}}, nil)
+
+		service := services.NewBalanceService(logger.NewLogger(), mocks.NewUserRepositoryMock(),
+			mocks.NewTransactionRepositoryMock(), balanceRepo, mocks.NewCalculatorMock())
+		result, err := service.GetBalancesByUserIDs(ctx, query)
+
+		assert.Nil(t, err)
+		assert.Len(t, result.Balances, 1)
+		assert.Empty(t, result.Errors)
+	})
+
+	t.Run("When GetBalancesByUserIDs applies the from date and as_of together", func(t *testing.T) {
+		query := balance.BatchQuery{UserIDs: []string{"1"}, From: "2024-01-01T00:00:00Z", To: "2024-12-31T00:00:00Z",
+			AsOf: "2024-06-30T00:00:00Z"}
+
+		balanceRepo := mocks.NewBalanceRepositoryMock()
+		balanceRepo.On("FindByUserIDs", ctx, query.UserIDs, query.From, query.AsOf).
+			Return(map[string]balance.UserBalance{"1": {Balance: 100}}, nil)
+
+		service := services.NewBalanceService(logger.NewLogger(), mocks.NewUserRepositoryMock(),
+			mocks.NewTransactionRepositoryMock(), balanceRepo, mocks.NewCalculatorMock())
+		result, err := service.GetBalancesByUserIDs(ctx, query)
+
+		assert.Nil(t, err)
+		assert.Equal(t, map[string]balance.UserBalance{"1": {Balance: 100}}, result.Balances)
+		assert.Empty(t, result.Errors)
+	})
+
+	t.Run("When balance repository returns error", func(t *testing.T) {
+		expectedError := errors.New("balance repository error")
+		query := balance.BatchQuery{UserIDs: []string{"1"}}
+
+		balanceRepo := mocks.NewBalanceRepositoryMock()
+		balanceRepo.On("FindByUserIDs", ctx, query.UserIDs, "", "").
+			Return(map[string]balance.UserBalance{}, expectedError)
+
+		service := services.NewBalanceService(logger.NewLogger(), mocks.NewUserRepositoryMock(),
+			mocks.NewTransactionRepositoryMock(), balanceRepo, mocks.NewCalculatorMock())
+		_, err := service.GetBalancesByUserIDs(ctx, query)
+
+		assert.Equal(t, expectedError, err)
+	})
+}
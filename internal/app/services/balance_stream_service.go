@@ -0,0 +1,47 @@
+package services
+
+import (
+	"context"
+
+	"github.com/sebastianreh/user-balance-api/internal/domain/balance"
+	"github.com/sebastianreh/user-balance-api/internal/domain/user"
+	"github.com/sebastianreh/user-balance-api/pkg/logger"
+	"github.com/sebastianreh/user-balance-api/pkg/pubsub"
+)
+
+type BalanceStreamService interface {
+	// Subscribe checks the user and returns a subscription signaled after every change of its balance, it fails when
+	// the stream limits are reached
+	Subscribe(ctx context.Context, userID string) (*pubsub.Subscription, error)
+	// Current returns the balance sent to the streams of the user
+	Current(ctx context.Context, userID string) (balance.UserBalance, error)
+}
+
+type balanceStreamService struct {
+	log            logger.Logger
+	userRepository user.Repository
+	balanceService BalanceService
+	broker         *pubsub.Broker
+}
+
+func NewBalanceStreamService(log logger.Logger, userRepository user.Repository, balanceService BalanceService,
+	broker *pubsub.Broker) BalanceStreamService {
+	return &balanceStreamService{
+		log:            log,
+		userRepository: userRepository,
+		balanceService: balanceService,
+		broker:         broker,
+	}
+}
+
+func (s *balanceStreamService) Subscribe(ctx context.Context, userID string) (*pubsub.Subscription, error) {
+	if _, err := s.userRepository.FindByID(ctx, userID); err != nil {
+		return nil, err
+	}
+
+	return s.broker.Subscribe(userID)
+}
+
+func (s *balanceStreamService) Current(ctx context.Context, userID string) (balance.UserBalance, error) {
+	return s.balanceService.GetBalanceByUserID(ctx, userID)
+}
@@ -0,0 +1,61 @@
+package services_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/sebastianreh/user-balance-api/internal/app/services"
+	"github.com/sebastianreh/user-balance-api/internal/domain/balance"
+	"github.com/sebastianreh/user-balance-api/internal/domain/user"
+	"github.com/sebastianreh/user-balance-api/pkg/logger"
+	"github.com/sebastianreh/user-balance-api/pkg/pubsub"
+	"github.com/sebastianreh/user-balance-api/test/mocks"
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_BalanceStreamService(t *testing.T) {
+	ctx := context.TODO()
+	log := logger.NewLogger()
+
+	t.Run("When the stream of a user is signaled by the changes of its balance", func(t *testing.T) {
+		broker := pubsub.NewBroker(0, 0)
+		userRepository := mocks.NewUserRepositoryMock()
+		userRepository.On("FindByID", ctx, "1").Return(user.User{ID: "1"}, nil)
+
+		service := services.NewBalanceStreamService(log, userRepository, mocks.NewBalanceServiceMock(), broker)
+		subscription, err := service.Subscribe(ctx, "1")
+		assert.Nil(t, err)
+
+		broker.Publish("2")
+		assert.Empty(t, subscription.Signals())
+		broker.Publish("1")
+		assert.Len(t, subscription.Signals(), 1)
+	})
+
+	t.Run("When the user doesn't exist nothing is subscribed", func(t *testing.T) {
+		broker := pubsub.NewBroker(1, 0)
+		userRepository := mocks.NewUserRepositoryMock()
+		userRepository.On("FindByID", ctx, "1").Return(user.User{}, errors.New(user.NotFoundError))
+
+		service := services.NewBalanceStreamService(log, userRepository, mocks.NewBalanceServiceMock(), broker)
+		_, err := service.Subscribe(ctx, "1")
+		assert.EqualError(t, err, user.NotFoundError)
+
+		_, err = broker.Subscribe("2")
+		assert.Nil(t, err)
+	})
+
+	t.Run("When the current balance is the one of the balance endpoint", func(t *testing.T) {
+		balanceService := mocks.NewBalanceServiceMock()
+		balanceService.On("GetBalanceByUserID", ctx, "1").Return(balance.UserBalance{Balance: 10}, nil)
+
+		service := services.NewBalanceStreamService(log, mocks.NewUserRepositoryMock(), balanceService,
+			pubsub.NewBroker(0, 0))
+		current, err := service.Current(ctx, "1")
+
+		assert.Nil(t, err)
+		assert.Equal(t, balance.UserBalance{Balance: 10}, current)
+		assert.NotEqual(t, current.Version(), balance.UserBalance{Balance: 10, TotalCredits: 1}.Version())
+	})
+}
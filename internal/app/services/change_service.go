@@ -0,0 +1,43 @@
+package services
+
+import (
+	"context"
+
+	"github.com/sebastianreh/user-balance-api/internal/domain/change"
+	"github.com/sebastianreh/user-balance-api/pkg/logger"
+)
+
+const (
+	changeServiceName = "ChangeService"
+)
+
+type ChangeService interface {
+	// ListChanges reads the changes feed after the since cursor of the options, in the order they were committed
+	ListChanges(ctx context.Context, options change.ListOptions) (change.ListPage, error)
+}
+
+type changeService struct {
+	log        logger.Logger
+	repository change.Repository
+}
+
+func NewChangeService(log logger.Logger, repository change.Repository) ChangeService {
+	return &changeService{
+		log:        log,
+		repository: repository,
+	}
+}
+
+func (s *changeService) ListChanges(ctx context.Context, options change.ListOptions) (change.ListPage, error) {
+	limit := options.Limit
+	// One extra change tells if there are more to read right away
+	options.Limit = limit + 1
+	changes, err := s.repository.List(ctx, options)
+	if err != nil {
+		s.log.ErrorAt(err, changeServiceName, "ListChanges")
+		return change.ListPage{}, err
+	}
+
+	options.Limit = limit
+	return change.NewListPage(changes, options), nil
+}
@@ -0,0 +1,71 @@
+package services_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/sebastianreh/user-balance-api/internal/app/services"
+	"github.com/sebastianreh/user-balance-api/internal/domain/change"
+	"github.com/sebastianreh/user-balance-api/pkg/logger"
+	"github.com/sebastianreh/user-balance-api/test/mocks"
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_ChangeService_ListChanges(t *testing.T) {
+	ctx := context.TODO()
+	log := logger.NewLogger()
+	changes := []change.Change{
+		{Cursor: "11", Entity: change.EntityUser, EntityID: "1", Operation: change.OperationInsert},
+		{Cursor: "12", Entity: change.EntityTransaction, EntityID: "tx-1", Operation: change.OperationInsert},
+		{Cursor: "13", Entity: change.EntityTransaction, EntityID: "tx-1", Operation: change.OperationSoftDelete},
+	}
+
+	t.Run("When there are more changes than the limit the page tells to keep reading", func(t *testing.T) {
+		repositoryMock := mocks.NewChangeRepositoryMock()
+		repositoryMock.On("List", ctx, change.ListOptions{Since: 10, Limit: 3}).Return(changes, nil)
+
+		service := services.NewChangeService(log, repositoryMock)
+		page, err := service.ListChanges(ctx, change.ListOptions{Since: 10, Limit: 2})
+
+		assert.Nil(t, err)
+		assert.Len(t, page.Changes, 2)
+		assert.True(t, page.HasMore)
+		assert.Equal(t, "12", page.NextCursor)
+	})
+
+	t.Run("When the feed is read up to its end", func(t *testing.T) {
+		repositoryMock := mocks.NewChangeRepositoryMock()
+		repositoryMock.On("List", ctx, change.ListOptions{Since: 10, Limit: 101}).Return(changes, nil)
+
+		service := services.NewChangeService(log, repositoryMock)
+		page, err := service.ListChanges(ctx, change.ListOptions{Since: 10, Limit: 100})
+
+		assert.Nil(t, err)
+		assert.Len(t, page.Changes, 3)
+		assert.False(t, page.HasMore)
+		assert.Equal(t, "13", page.NextCursor)
+	})
+
+	t.Run("When there are no new changes the since cursor is returned", func(t *testing.T) {
+		repositoryMock := mocks.NewChangeRepositoryMock()
+		repositoryMock.On("List", ctx, change.ListOptions{Since: 13, Limit: 101}).Return([]change.Change{}, nil)
+
+		service := services.NewChangeService(log, repositoryMock)
+		page, err := service.ListChanges(ctx, change.ListOptions{Since: 13, Limit: 100})
+
+		assert.Nil(t, err)
+		assert.Empty(t, page.Changes)
+		assert.Equal(t, "13", page.NextCursor)
+	})
+
+	t.Run("When the repository fails", func(t *testing.T) {
+		repositoryMock := mocks.NewChangeRepositoryMock()
+		repositoryMock.On("List", ctx, change.ListOptions{Limit: 101}).Return([]change.Change{}, errors.New("db error"))
+
+		service := services.NewChangeService(log, repositoryMock)
+		_, err := service.ListChanges(ctx, change.ListOptions{Limit: 100})
+
+		assert.ErrorContains(t, err, "db error")
+	})
+}
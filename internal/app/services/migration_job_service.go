@@ -0,0 +1,587 @@
+package services
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/sebastianreh/user-balance-api/internal/domain/migration"
+	"github.com/sebastianreh/user-balance-api/internal/domain/outbox"
+	"github.com/sebastianreh/user-balance-api/internal/domain/report"
+	"github.com/sebastianreh/user-balance-api/internal/domain/transaction"
+	"github.com/sebastianreh/user-balance-api/internal/domain/webhook"
+	"github.com/sebastianreh/user-balance-api/internal/infrastructure/config"
+	"github.com/sebastianreh/user-balance-api/pkg/decompress"
+	"github.com/sebastianreh/user-balance-api/pkg/fingerprint"
+	"github.com/sebastianreh/user-balance-api/pkg/logger"
+	"github.com/sebastianreh/user-balance-api/pkg/notifier"
+)
+
+const (
+	migrationJobServiceName = "MigrationJobService"
+)
+
+// MigrationFile is a file already stored on the local disk, the job created for it takes it over and removes it once
+// it ends. When no job is created the file is left untouched
+type MigrationFile struct {
+	Name string
+	Path string
+}
+
+type MigrationJobService interface {
+	StartMigration(ctx context.Context, file *multipart.FileHeader, options migration.Options) (migration.Job, error)
+	RunMigration(ctx context.Context, file *multipart.FileHeader, options migration.Options) (migration.Job, error)
+	StartFileMigration(ctx context.Context, file MigrationFile, options migration.Options) (migration.Job, error)
+	RunFileMigration(ctx context.Context, file MigrationFile, options migration.Options) (migration.Job, error)
+	GetJob(ctx context.Context, jobID string) (migration.Job, error)
+	ListJobs(ctx context.Context, options migration.ListOptions) (migration.ListPage, error)
+	ListTransactions(ctx context.Context, jobID string, options migration.ListOptions) (transaction.ListPage, error)
+	Rollback(ctx context.Context, jobID string) (migration.Job, error)
+	ForEachReject(ctx context.Context, jobID string, handle func(reject migration.Reject) error) error
+	FailInterruptedJobs(ctx context.Context) error
+}
+
+type migrationJobService struct {
+	config                config.Config
+	log                   logger.Logger
+	jobRepository         migration.Repository
+	transactionRepository transaction.Repository
+	migrationService      MigrationService
+	reportService         MigrationReportService
+	notificationService   NotificationService
+	webhookService        WebhookService
+	alertService          AlertService
+}
+
+func NewMigrationJobService(cfg config.Config, log logger.Logger, jobRepository migration.Repository,
+	transactionRepository transaction.Repository, migrationService MigrationService,
+	reportService MigrationReportService, notificationService NotificationService, webhookService WebhookService,
+	alertService AlertService) MigrationJobService {
+	return &migrationJobService{
+		config:                cfg,
+		log:                   log,
+		jobRepository:         jobRepository,
+		transactionRepository: transactionRepository,
+		migrationService:      migrationService,
+		reportService:         reportService,
+		notificationService:   notificationService,
+		webhookService:        webhookService,
+		alertService:          alertService,
+	}
+}
+
+// StartMigration stores the uploaded file and processes it in the background, the request temporary files
+// are removed once the response is sent so the upload is copied first
+func (s *migrationJobService) StartMigration(ctx context.Context, file *multipart.FileHeader,
+	options migration.Options) (migration.Job, error) {
+	copied, err := copyToTempFile(file)
+	if err != nil {
+		s.log.ErrorAt(err, migrationJobServiceName, "StartMigration")
+		return migration.Job{}, err
+	}
+
+	job, err := s.startJob(ctx, copied, options)
+	if err != nil {
+		_ = os.Remove(copied.path)
+		s.log.ErrorAt(err, migrationJobServiceName, "StartMigration")
+		return job, err
+	}
+
+	return job, nil
+}
+
+// StartFileMigration processes a file of the local disk in the background like StartMigration
+func (s *migrationJobService) StartFileMigration(ctx context.Context, file MigrationFile,
+	options migration.Options) (migration.Job, error) {
+	stored, err := checksumFile(file)
+	if err != nil {
+		s.log.ErrorAt(err, migrationJobServiceName, "StartFileMigration")
+		return migration.Job{}, err
+	}
+
+	job, err := s.startJob(ctx, stored, options)
+	if err != nil {
+		s.log.ErrorAt(err, migrationJobServiceName, "StartFileMigration")
+		return job, err
+	}
+
+	return job, nil
+}
+
+// RunMigration processes the uploaded file under a job before returning, so the records rejected in partial mode
+// can be downloaded and the migration rolled back afterwards. The returned job has the final status, the error is the
+// one that failed it
+func (s *migrationJobService) RunMigration(ctx context.Context, file *multipart.FileHeader,
+	options migration.Options) (migration.Job, error) {
+	copied, err := copyToTempFile(file)
+	if err != nil {
+		s.log.ErrorAt(err, migrationJobServiceName, "RunMigration")
+		return migration.Job{}, err
+	}
+
+	job, err := s.createJob(ctx, copied, options)
+	if err != nil {
+		_ = os.Remove(copied.path)
+		s.log.ErrorAt(err, migrationJobServiceName, "RunMigration")
+		return job, err
+	}
+
+	options.MigrationID = job.ID
+	return s.runJob(ctx, job, copied.path, options)
+}
+
+// RunFileMigration processes a file of the local disk before returning like RunMigration. The file was taken over
+// when the returned job has an ID, even if the migration failed
+func (s *migrationJobService) RunFileMigration(ctx context.Context, file MigrationFile,
+	options migration.Options) (migration.Job, error) {
+	stored, err := checksumFile(file)
+	if err != nil {
+		s.log.ErrorAt(err, migrationJobServiceName, "RunFileMigration")
+		return migration.Job{}, err
+	}
+
+	job, err := s.createJob(ctx, stored, options)
+	if err != nil {
+		s.log.ErrorAt(err, migrationJobServiceName, "RunFileMigration")
+		return job, err
+	}
+
+	options.MigrationID = job.ID
+	return s.runJob(ctx, job, stored.path, options)
+}
+
+// GetJob returns the job state, an active job without recent heartbeats lost its instance so it's marked as failed
+func (s *migrationJobService) GetJob(ctx context.Context, jobID string) (migration.Job, error) {
+	job, err := s.jobRepository.FindByID(ctx, jobID)
+	if err != nil {
+		return job, err
+	}
+
+	if job.IsStale(time.Now(), s.config.Workers.MigrationJobStaleAfter) {
+		job.Status = migration.StatusFailed
+		job.Error = migration.InterruptedError
+		err = s.jobRepository.Update(ctx, job)
+		switch {
+		case err == nil:
+		case err.Error() == migration.NotFoundError:
+			// Another request finished the job since it was read, its state is the saved one
+			return s.jobRepository.FindByID(ctx, jobID)
+		default:
+			return job, err
+		}
+	}
+
+	return job, nil
+}
+
+// ListJobs returns a page of the migration history from the newest job to the oldest
+func (s *migrationJobService) ListJobs(ctx context.Context, options migration.ListOptions) (migration.ListPage, error) {
+	limit := options.Limit
+	// One extra job tells if there is a next page
+	options.Limit = limit + 1
+	jobs, err := s.jobRepository.List(ctx, options)
+	if err != nil {
+		s.log.ErrorAt(err, migrationJobServiceName, "ListJobs")
+		return migration.ListPage{}, err
+	}
+
+	page := migration.ListPage{Migrations: jobs}
+	if len(jobs) > limit {
+		page.Migrations = jobs[:limit]
+		page.NextCursor = migration.Cursor{ID: page.Migrations[limit-1].ID}.Encode()
+	}
+
+	return page, nil
+}
+
+// ListTransactions returns a page of the transactions saved by the job that were not deleted, ordered by id
+func (s *migrationJobService) ListTransactions(ctx context.Context, jobID string,
+	options migration.ListOptions) (transaction.ListPage, error) {
+	if _, err := s.jobRepository.FindByID(ctx, jobID); err != nil {
+		return transaction.ListPage{}, err
+	}
+
+	var afterID string
+	if options.After != nil {
+		afterID = options.After.ID
+	}
+
+	items, err := s.transactionRepository.ListByMigration(ctx, jobID, afterID, options.Limit+1)
+	if err != nil {
+		s.log.ErrorAt(err, migrationJobServiceName, "ListTransactions")
+		return transaction.ListPage{}, err
+	}
+
+	page := transaction.ListPage{Transactions: items}
+	if len(items) > options.Limit {
+		page.Transactions = items[:options.Limit]
+		page.NextCursor = migration.Cursor{ID: page.Transactions[options.Limit-1].ID}.Encode()
+	}
+
+	return page, nil
+}
+
+// Rollback deletes the transactions saved by a completed or failed job, it's refused when any of them was edited
+// since. A single migration.rolled_back delivery is saved with the rollback, like the migration sends a single
+// migration.completed. The alert rules of the users of the job are checked once it's saved
+func (s *migrationJobService) Rollback(ctx context.Context, jobID string) (migration.Job, error) {
+	job, err := s.jobRepository.FindByID(ctx, jobID)
+	if err != nil {
+		return job, err
+	}
+
+	// The repository checks it again with the job locked, this only spares building the deliveries of a job that
+	// is still running
+	if !job.CanRollBack() {
+		return migration.Job{}, errors.New(migration.RollbackStatusError)
+	}
+
+	rolledBackAt := time.Now()
+	job.Status = migration.StatusRolledBack
+	job.RolledBackAt = &rolledBackAt
+	messages, err := s.webhookService.Messages(ctx, webhook.EventMigrationRolledBack, job)
+	if err != nil {
+		s.log.ErrorAt(err, migrationJobServiceName, "Rollback")
+		return migration.Job{}, err
+	}
+
+	deleted, err := s.jobRepository.Rollback(ctx, jobID, messages...)
+	if err != nil {
+		s.log.ErrorAt(err, migrationJobServiceName, "Rollback")
+		return migration.Job{}, err
+	}
+
+	s.log.Info("Migration rolled back", "job_id", jobID, "transactions", deleted)
+	// The rollback is saved, the alerts never fail it and the alert service logs its errors
+	userIDs, err := s.transactionRepository.FindMigrationUserIDs(ctx, jobID)
+	if err != nil {
+		s.log.ErrorAt(err, migrationJobServiceName, "Rollback")
+	} else {
+		_ = s.alertService.Check(ctx, userIDs, nil)
+	}
+
+	return s.jobRepository.FindByID(ctx, jobID)
+}
+
+// ForEachReject hands the rejected records of the job to handle ordered by line
+func (s *migrationJobService) ForEachReject(ctx context.Context, jobID string,
+	handle func(reject migration.Reject) error) error {
+	if _, err := s.jobRepository.FindByID(ctx, jobID); err != nil {
+		return err
+	}
+
+	return s.jobRepository.ForEachReject(ctx, jobID, handle)
+}
+
+// FailInterruptedJobs marks as failed the jobs left behind by instances that stopped, it runs on startup. A job that
+// stopped after every record of its file was saved or rejected is finished instead, so its report and messages are
+// still sent
+func (s *migrationJobService) FailInterruptedJobs(ctx context.Context) error {
+	staleBefore := time.Now().Add(-s.config.Workers.MigrationJobStaleAfter)
+	staleJobs, err := s.jobRepository.FindStale(ctx, staleBefore)
+	if err != nil {
+		return err
+	}
+
+	for _, job := range staleJobs {
+		s.finishSavedJob(ctx, job)
+	}
+
+	failedJobs, err := s.jobRepository.FailStale(ctx, staleBefore, migration.InterruptedError)
+	if err != nil {
+		return err
+	}
+
+	if failedJobs > 0 {
+		s.log.Warn("Interrupted migration jobs marked as failed", "jobs", failedJobs)
+	}
+
+	return nil
+}
+
+// finishSavedJob completes an interrupted job whose records are all in the database. The users it created and its
+// processing time were only known by the instance that ran it, so its summary goes without them
+func (s *migrationJobService) finishSavedJob(ctx context.Context, job migration.Job) {
+	summary, err := s.jobRepository.Saved(ctx, job.ID)
+	if err != nil {
+		s.log.ErrorAt(fmt.Errorf("could not read migration job %s: %w", job.ID, err), migrationJobServiceName,
+			"finishSavedJob")
+		return
+	}
+
+	if job.Progress.RowsValidated == 0 || summary.TotalRecords+summary.RejectedRecords != job.Progress.RowsValidated {
+		return
+	}
+
+	job.Progress.RowsInserted = summary.TotalRecords
+	job.Progress.BatchesDone = job.Progress.BatchesTotal
+	if _, err = s.completeJob(ctx, job, summary, job.ReportDestinations); err != nil {
+		s.log.ErrorAt(fmt.Errorf("could not finish migration job %s: %w", job.ID, err), migrationJobServiceName,
+			"finishSavedJob")
+		return
+	}
+
+	s.log.Warn("Interrupted migration job finished", "job", job.ID)
+}
+
+// startJob creates the job and processes its file in the background
+func (s *migrationJobService) startJob(ctx context.Context, file jobFile,
+	options migration.Options) (migration.Job, error) {
+	job, err := s.createJob(ctx, file, options)
+	if err != nil {
+		return job, err
+	}
+
+	options.MigrationID = job.ID
+	go func() {
+		_, _ = s.runJob(context.Background(), job, file.path, options)
+	}()
+
+	return job, nil
+}
+
+// createJob fingerprints the file and refuses one that another migration already imported, unless forced.
+// A forced job records the migration that imported the file before. The file is left to the caller on errors
+func (s *migrationJobService) createJob(ctx context.Context, file jobFile,
+	options migration.Options) (migration.Job, error) {
+	job := migration.Job{Status: migration.StatusPending, FileName: file.name, Checksum: file.checksum,
+		Mode: options.Mode, UploadedBy: options.UploadedBy, ReportDestinations: options.ReportDestinations}
+	contentHash, err := s.contentHash(file.path)
+	if err != nil {
+		return job, fmt.Errorf("%s: %w", ReadFileError, err)
+	}
+
+	job.ContentHash = contentHash
+	previous, err := s.jobRepository.FindImported(ctx, job.Checksum, job.ContentHash)
+	switch {
+	case err == nil && !options.Force:
+		return job, fmt.Errorf("%s by migration %s", migration.DuplicateFileError, previous.ID)
+	case err == nil:
+		job.Forced = true
+		job.DuplicateOf = previous.ID
+	case err.Error() != migration.NotFoundError:
+		return job, err
+	}
+
+	job.ID, err = s.jobRepository.Save(ctx, job)
+	if err != nil {
+		return job, err
+	}
+
+	job.CreatedAt = time.Now()
+	job.UpdatedAt = job.CreatedAt
+
+	return job, nil
+}
+
+// contentHash hashes the decompressed content, so a compressed copy of an imported file is a duplicate too
+func (s *migrationJobService) contentHash(path string) (string, error) {
+	open := decompress.Opener(func() (io.ReadCloser, error) {
+		return os.Open(path)
+	}, decompress.Limits{
+		MaxSize:    s.config.Workers.MigrationMaxDecompressedSize,
+		MaxEntries: s.config.Workers.MigrationMaxArchiveEntries,
+	})
+
+	content, err := open()
+	if err != nil {
+		return "", err
+	}
+	defer content.Close()
+
+	return fingerprint.ContentHash(content)
+}
+
+func (s *migrationJobService) runJob(ctx context.Context, job migration.Job, path string,
+	options migration.Options) (migration.Job, error) {
+	defer os.Remove(path)
+
+	stopHeartbeat := s.startHeartbeat(ctx, job.ID)
+	defer stopHeartbeat()
+
+	job.Status = migration.StatusRunning
+	s.updateJob(ctx, job)
+
+	open := func() (io.ReadCloser, error) {
+		return os.Open(path)
+	}
+
+	hooks := migration.Hooks{
+		// Progress updates are serialized by the migration service and finish before it returns
+		OnProgress: func(progress migration.Progress) {
+			job.Progress = progress
+			s.updateJob(ctx, job)
+		},
+		OnRejects: func(rejects []migration.Reject) error {
+			return s.jobRepository.SaveRejects(ctx, job.ID, rejects)
+		},
+	}
+
+	started := time.Now()
+	summary, err := s.migrationService.ProcessBalanceWithOptions(ctx, open, options, hooks)
+	if err != nil {
+		return s.failJob(ctx, job, err), err
+	}
+
+	summary.JobID = job.ID
+	summary.ProcessingTime = time.Since(started)
+	if job, err = s.completeJob(ctx, job, summary, options.ReportDestinations); err != nil {
+		return s.failJob(ctx, job, err), err
+	}
+
+	return job, nil
+}
+
+// completeJob saves the completed job with its report, notifications and webhook deliveries. They are sent by the
+// outbox dispatcher and saved with the job, so a completed job always gets them
+func (s *migrationJobService) completeJob(ctx context.Context, job migration.Job, summary report.MigrationSummary,
+	to []string) (migration.Job, error) {
+	job.Summary = &summary
+	reportMessage, err := s.reportService.GenerateReport(ctx, summary, to)
+	if err != nil {
+		return job, err
+	}
+
+	job.Status = migration.StatusCompleted
+	notificationMessages, err := s.notificationService.Messages(jobNotification(job))
+	if err != nil {
+		return job, err
+	}
+
+	webhookMessages, err := s.webhookService.Messages(ctx, webhook.EventMigrationCompleted, job)
+	if err != nil {
+		return job, err
+	}
+
+	messages := append([]outbox.Message{reportMessage}, notificationMessages...)
+	return job, s.jobRepository.Finish(ctx, job, append(messages, webhookMessages...)...)
+}
+
+func (s *migrationJobService) startHeartbeat(ctx context.Context, jobID string) func() {
+	ticker := time.NewTicker(s.config.Workers.MigrationJobHeartbeat)
+	done := make(chan struct{})
+
+	go func() {
+		for {
+			select {
+			case <-done:
+				return
+			case <-ticker.C:
+				if err := s.jobRepository.Heartbeat(ctx, jobID); err != nil {
+					s.log.ErrorAt(err, migrationJobServiceName, "startHeartbeat")
+				}
+			}
+		}
+	}()
+
+	return func() {
+		ticker.Stop()
+		close(done)
+	}
+}
+
+// failJob saves the failed job with its notifications, the job is saved without them when they can't be built
+func (s *migrationJobService) failJob(ctx context.Context, job migration.Job, err error) migration.Job {
+	s.log.ErrorAt(fmt.Errorf("migration job %s failed: %w", job.ID, err), migrationJobServiceName, "runJob")
+	job.Status = migration.StatusFailed
+	job.Error = err.Error()
+	messages, notificationErr := s.notificationService.Messages(jobNotification(job))
+	if notificationErr != nil {
+		s.updateJob(ctx, job)
+		return job
+	}
+
+	if finishErr := s.jobRepository.Finish(ctx, job, messages...); finishErr != nil {
+		s.log.ErrorAt(fmt.Errorf("could not update migration job %s: %w", job.ID, finishErr),
+			migrationJobServiceName, "failJob")
+	}
+
+	return job
+}
+
+// jobNotification tells that the job finished, with its summary, or failed, with its error
+func jobNotification(job migration.Job) notifier.Notification {
+	notification := notifier.Notification{
+		Event:   notifier.EventMigrationFinished,
+		Subject: fmt.Sprintf("Migration %s finished", job.ID),
+		Data:    map[string]any{"job_id": job.ID, "file_name": job.FileName, "status": job.Status},
+	}
+
+	lines := []string{fmt.Sprintf("File: %s", job.FileName)}
+	if job.Status == migration.StatusFailed {
+		notification.Event = notifier.EventMigrationFailed
+		notification.Subject = fmt.Sprintf("Migration %s failed", job.ID)
+		notification.Data["error"] = job.Error
+		lines = append(lines, fmt.Sprintf("Error: %s", job.Error))
+	}
+
+	if job.Summary != nil {
+		notification.Data["summary"] = job.Summary
+		lines = append(lines, fmt.Sprintf("Total Records Processed: %d", job.Summary.TotalRecords),
+			fmt.Sprintf("Total Users Updated: %d", job.Summary.UsersUpdated),
+			fmt.Sprintf("Total Records Rejected: %d", job.Summary.RejectedRecords))
+	}
+
+	notification.Text = strings.Join(lines, "\n")
+	return notification
+}
+
+// updateJob saves the progress of an active job, a job failed as stale or rolled back meanwhile keeps its saved state
+func (s *migrationJobService) updateJob(ctx context.Context, job migration.Job) {
+	if err := s.jobRepository.Update(ctx, job); err != nil {
+		s.log.ErrorAt(fmt.Errorf("could not update migration job %s: %w", job.ID, err),
+			migrationJobServiceName, "updateJob")
+	}
+}
+
+// jobFile is the local copy of a migration file with its hex SHA-256
+type jobFile struct {
+	name     string
+	path     string
+	checksum string
+}
+
+// copyToTempFile copies the upload, its checksum is computed while it's copied
+func copyToTempFile(file *multipart.FileHeader) (jobFile, error) {
+	src, err := file.Open()
+	if err != nil {
+		return jobFile{}, err
+	}
+	defer src.Close()
+
+	dst, err := os.CreateTemp("", "migration-*")
+	if err != nil {
+		return jobFile{}, err
+	}
+	defer dst.Close()
+
+	hash := sha256.New()
+	if _, err = io.Copy(io.MultiWriter(dst, hash), src); err != nil {
+		_ = os.Remove(dst.Name())
+		return jobFile{}, err
+	}
+
+	return jobFile{name: file.Filename, path: dst.Name(), checksum: hex.EncodeToString(hash.Sum(nil))}, nil
+}
+
+func checksumFile(file MigrationFile) (jobFile, error) {
+	src, err := os.Open(file.Path)
+	if err != nil {
+		return jobFile{}, fmt.Errorf("%s: %w", ReadFileError, err)
+	}
+	defer src.Close()
+
+	hash := sha256.New()
+	if _, err = io.Copy(hash, src); err != nil {
+		return jobFile{}, fmt.Errorf("%s: %w", ReadFileError, err)
+	}
+
+	return jobFile{name: file.Name, path: file.Path, checksum: hex.EncodeToString(hash.Sum(nil))}, nil
+}
@@ -0,0 +1,1017 @@
+package services_test
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"mime/multipart"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/sebastianreh/user-balance-api/internal/app/services"
+	"github.com/sebastianreh/user-balance-api/internal/domain/migration"
+	"github.com/sebastianreh/user-balance-api/internal/domain/outbox"
+	"github.com/sebastianreh/user-balance-api/internal/domain/report"
+	"github.com/sebastianreh/user-balance-api/internal/domain/transaction"
+	"github.com/sebastianreh/user-balance-api/internal/domain/webhook"
+	"github.com/sebastianreh/user-balance-api/internal/infrastructure/config"
+	"github.com/sebastianreh/user-balance-api/pkg/fingerprint"
+	"github.com/sebastianreh/user-balance-api/pkg/logger"
+	"github.com/sebastianreh/user-balance-api/pkg/notifier"
+	"github.com/sebastianreh/user-balance-api/test/mocks"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func Test_MigrationJobService_StartMigration(t *testing.T) {
+	ctx := context.TODO()
+	cfg := config.NewConfig()
+	log := logger.NewLogger()
+	destinations := []string{"test@example.com"}
+	options := migration.Options{Mode: migration.ModeDefault, ReportDestinations: destinations}
+	reportMessage := newReportMessage()
+
+	t.Run("When the job is processed in the background until completion", func(t *testing.T) {
+		content := "id,user_id,amount,datetime\n1,1,100,2024-09-13T10:00:00Z"
+		file := newFileHeader(t, "test.csv", content)
+		summary := report.MigrationSummary{TotalRecords: 1, UsersUpdated: 1}
+		expectedSummary := summary
+		expectedSummary.JobID = "1"
+		expectedOptions := options
+		expectedOptions.MigrationID = "1"
+		finished := make(chan migration.Job, 1)
+
+		jobRepo := mocks.NewMigrationJobRepositoryMock()
+		jobRepo.On("FindImported", ctx, mock.Anything, mock.Anything).
+			Return(migration.Job{}, errors.New(migration.NotFoundError))
+		jobRepo.On("Save", ctx, migration.Job{Status: migration.StatusPending, FileName: "test.csv",
+			Checksum: checksum(content), ContentHash: contentHash(t, content), Mode: migration.ModeDefault,
+			ReportDestinations: destinations}).Return("1", nil)
+		jobRepo.On("Update", mock.Anything, mock.MatchedBy(func(job migration.Job) bool {
+			return job.IsActive()
+		})).Return(nil)
+		jobRepo.On("Finish", mock.Anything, mock.Anything, []outbox.Message{reportMessage}).
+			Run(func(args mock.Arguments) {
+				finished <- args.Get(1).(migration.Job)
+			}).Return(nil)
+
+		migrationService := mocks.NewMigrationServiceMock()
+		migrationService.On("ProcessBalanceWithOptions", mock.Anything, mock.Anything, expectedOptions,
+			mock.Anything).Run(func(args mock.Arguments) {
+			hooks := args.Get(3).(migration.Hooks)
+			hooks.OnProgress(migration.Progress{RowsValidated: 1, RowsInserted: 1, BatchesDone: 1, BatchesTotal: 1})
+		}).Return(summary, nil)
+
+		reportService := mocks.NewReportServiceMock()
+		reportService.On("GenerateReport", mock.Anything, matchSummary(expectedSummary), destinations).
+			Return(reportMessage, nil)
+
+		service := services.NewMigrationJobService(cfg, log, jobRepo, mocks.NewTransactionRepositoryMock(),
+			migrationService, reportService, newNotificationService(), newWebhookService(), newAlertService())
+		job, err := service.StartMigration(ctx, file, options)
+
+		assert.Nil(t, err)
+		assert.Equal(t, "1", job.ID)
+		assert.Equal(t, migration.StatusPending, job.Status)
+
+		finishedJob := waitForJob(t, finished)
+		assert.Equal(t, migration.StatusCompleted, finishedJob.Status)
+		assert.Equal(t, &expectedSummary, withoutProcessingTime(finishedJob.Summary))
+		assert.Equal(t, 1, finishedJob.Progress.BatchesDone)
+	})
+
+	t.Run("When the migration fails the job is marked as failed", func(t *testing.T) {
+		file := newFileHeader(t, "test.csv", "id,user_id,amount,datetime")
+		finished := make(chan migration.Job, 1)
+
+		jobRepo := mocks.NewMigrationJobRepositoryMock()
+		jobRepo.On("FindImported", ctx, mock.Anything, mock.Anything).
+			Return(migration.Job{}, errors.New(migration.NotFoundError))
+		jobRepo.On("Save", ctx, mock.Anything).Return("2", nil)
+		jobRepo.On("Update", mock.Anything, mock.MatchedBy(func(job migration.Job) bool {
+			return job.IsActive()
+		})).Return(nil)
+		jobRepo.On("Finish", mock.Anything, mock.Anything, []outbox.Message{}).Run(func(args mock.Arguments) {
+			finished <- args.Get(1).(migration.Job)
+		}).Return(nil)
+
+		migrationService := mocks.NewMigrationServiceMock()
+		migrationService.On("ProcessBalanceWithOptions", mock.Anything, mock.Anything, mock.Anything, mock.Anything).
+			Return(report.MigrationSummary{}, errors.New(services.ReadFileError))
+
+		reportService := mocks.NewReportServiceMock()
+
+		service := services.NewMigrationJobService(cfg, log, jobRepo, mocks.NewTransactionRepositoryMock(),
+			migrationService, reportService, newNotificationService(), newWebhookService(), newAlertService())
+		_, err := service.StartMigration(ctx, file, options)
+		assert.Nil(t, err)
+
+		finishedJob := waitForJob(t, finished)
+		assert.Equal(t, migration.StatusFailed, finishedJob.Status)
+		assert.Equal(t, services.ReadFileError, finishedJob.Error)
+		reportService.AssertNotCalled(t, "GenerateReport", mock.Anything, mock.Anything, mock.Anything)
+	})
+
+	t.Run("When the job can't be saved", func(t *testing.T) {
+		file := newFileHeader(t, "test.csv", "id,user_id,amount,datetime")
+		expectedError := errors.New("repository error")
+
+		jobRepo := mocks.NewMigrationJobRepositoryMock()
+		jobRepo.On("FindImported", ctx, mock.Anything, mock.Anything).
+			Return(migration.Job{}, errors.New(migration.NotFoundError))
+		jobRepo.On("Save", ctx, mock.Anything).Return("", expectedError)
+
+		migrationService := mocks.NewMigrationServiceMock()
+
+		service := services.NewMigrationJobService(cfg, log, jobRepo, mocks.NewTransactionRepositoryMock(),
+			migrationService, mocks.NewReportServiceMock(), newNotificationService(), newWebhookService(), newAlertService())
+		_, err := service.StartMigration(ctx, file, options)
+
+		assert.Equal(t, expectedError, err)
+		migrationService.AssertNotCalled(t, "ProcessBalanceWithOptions", mock.Anything, mock.Anything, mock.Anything,
+			mock.Anything)
+	})
+}
+
+func Test_MigrationJobService_RunMigration(t *testing.T) {
+	ctx := context.TODO()
+	cfg := config.NewConfig()
+	log := logger.NewLogger()
+	destinations := []string{"test@example.com"}
+	options := migration.Options{Mode: migration.ModePartial, ReportDestinations: destinations,
+		UploadedBy: "ops@example.com"}
+	expectedOptions := options
+	expectedOptions.MigrationID = "1"
+	reportMessage := newReportMessage()
+
+	t.Run("When a partial migration stores its rejected records", func(t *testing.T) {
+		content := "id,user_id,amount,datetime\n1,1,0,2024-09-13T10:00:00Z"
+		file := newFileHeader(t, "test.csv", content)
+		rejects := []migration.Reject{{Line: 2, Raw: "1,1,0,2024-09-13T10:00:00Z",
+			Reason: migration.RejectReasonZeroAmount, Detail: transaction.ZeroAmountError}}
+		summary := report.MigrationSummary{RejectedRecords: 1,
+			RejectsByReason: map[string]int{migration.RejectReasonZeroAmount: 1}}
+		expectedSummary := summary
+		expectedSummary.JobID = "1"
+
+		jobRepo := mocks.NewMigrationJobRepositoryMock()
+		jobRepo.On("FindImported", ctx, mock.Anything, mock.Anything).
+			Return(migration.Job{}, errors.New(migration.NotFoundError))
+		jobRepo.On("Save", ctx, migration.Job{Status: migration.StatusPending, FileName: "test.csv",
+			Checksum: checksum(content), ContentHash: contentHash(t, content), UploadedBy: "ops@example.com",
+			Mode: migration.ModePartial, ReportDestinations: destinations}).Return("1", nil)
+		jobRepo.On("Update", ctx, mock.Anything).Return(nil)
+		jobRepo.On("Finish", ctx, mock.Anything, []outbox.Message{reportMessage}).Return(nil)
+		jobRepo.On("SaveRejects", ctx, "1", rejects).Return(nil)
+
+		migrationService := mocks.NewMigrationServiceMock()
+		migrationService.On("ProcessBalanceWithOptions", ctx, mock.Anything, expectedOptions, mock.Anything).
+			Run(func(args mock.Arguments) {
+				hooks := args.Get(3).(migration.Hooks)
+				assert.NoError(t, hooks.OnRejects(rejects))
+			}).Return(summary, nil)
+
+		reportService := mocks.NewReportServiceMock()
+		reportService.On("GenerateReport", mock.Anything, matchSummary(expectedSummary), destinations).
+			Return(reportMessage, nil)
+
+		service := services.NewMigrationJobService(cfg, log, jobRepo, mocks.NewTransactionRepositoryMock(),
+			migrationService, reportService, newNotificationService(), newWebhookService(), newAlertService())
+		job, err := service.RunMigration(ctx, file, options)
+
+		assert.Nil(t, err)
+		assert.Equal(t, migration.StatusCompleted, job.Status)
+		assert.Equal(t, &expectedSummary, withoutProcessingTime(job.Summary))
+		jobRepo.AssertCalled(t, "SaveRejects", ctx, "1", rejects)
+	})
+
+	t.Run("When the job finishes its notifications are saved with the report", func(t *testing.T) {
+		content := "id,user_id,amount,datetime\n1,1,100,2024-09-13T10:00:00Z"
+		file := newFileHeader(t, "test.csv", content)
+		notificationMessage := outbox.Message{Kind: outbox.KindNotification, Description: "Migration 1 finished",
+			Payload: []byte(`{"channel":"slack"}`), Status: outbox.StatusPending}
+
+		jobRepo := mocks.NewMigrationJobRepositoryMock()
+		jobRepo.On("FindImported", ctx, mock.Anything, mock.Anything).
+			Return(migration.Job{}, errors.New(migration.NotFoundError))
+		jobRepo.On("Save", ctx, mock.Anything).Return("1", nil)
+		jobRepo.On("Update", ctx, mock.Anything).Return(nil)
+		jobRepo.On("Finish", ctx, mock.Anything, []outbox.Message{reportMessage, notificationMessage}).Return(nil)
+
+		migrationService := mocks.NewMigrationServiceMock()
+		migrationService.On("ProcessBalanceWithOptions", ctx, mock.Anything, expectedOptions, mock.Anything).
+			Return(report.MigrationSummary{TotalRecords: 1, UsersUpdated: 1}, nil)
+
+		reportService := mocks.NewReportServiceMock()
+		reportService.On("GenerateReport", mock.Anything, mock.Anything, destinations).Return(reportMessage, nil)
+
+		notificationService := mocks.NewNotificationServiceMock()
+		notificationService.On("Messages", mock.MatchedBy(func(notification notifier.Notification) bool {
+			return notification.Event == notifier.EventMigrationFinished && notification.Data["job_id"] == "1" &&
+				strings.Contains(notification.Text, "Total Records Processed: 1")
+		})).Return([]outbox.Message{notificationMessage}, nil)
+
+		service := services.NewMigrationJobService(cfg, log, jobRepo, mocks.NewTransactionRepositoryMock(),
+			migrationService, reportService, notificationService, newWebhookService(), newAlertService())
+		job, err := service.RunMigration(ctx, file, options)
+
+		assert.Nil(t, err)
+		assert.Equal(t, migration.StatusCompleted, job.Status)
+		jobRepo.AssertExpectations(t)
+	})
+
+	t.Run("When the job completes its webhook deliveries are saved with the report", func(t *testing.T) {
+		content := "id,user_id,amount,datetime\n1,1,100,2024-09-13T10:00:00Z"
+		file := newFileHeader(t, "test.csv", content)
+		webhookMessage := outbox.Message{Kind: outbox.KindWebhook, Description: "migration.completed to https://a.io",
+			Reference: "webhook:1", Payload: []byte(`{"subscription_id":"1"}`), Status: outbox.StatusPending}
+
+		jobRepo := mocks.NewMigrationJobRepositoryMock()
+		jobRepo.On("FindImported", ctx, mock.Anything, mock.Anything).
+			Return(migration.Job{}, errors.New(migration.NotFoundError))
+		jobRepo.On("Save", ctx, mock.Anything).Return("1", nil)
+		jobRepo.On("Update", ctx, mock.Anything).Return(nil)
+		jobRepo.On("Finish", ctx, mock.Anything, []outbox.Message{reportMessage, webhookMessage}).Return(nil)
+
+		migrationService := mocks.NewMigrationServiceMock()
+		migrationService.On("ProcessBalanceWithOptions", ctx, mock.Anything, expectedOptions, mock.Anything).
+			Return(report.MigrationSummary{TotalRecords: 1, UsersUpdated: 1}, nil)
+
+		reportService := mocks.NewReportServiceMock()
+		reportService.On("GenerateReport", mock.Anything, mock.Anything, destinations).Return(reportMessage, nil)
+
+		webhookService := mocks.NewWebhookServiceMock()
+		webhookService.On("Messages", ctx, webhook.EventMigrationCompleted, mock.MatchedBy(func(job migration.Job) bool {
+			return job.ID == "1" && job.Status == migration.StatusCompleted && job.Summary.TotalRecords == 1
+		})).Return([]outbox.Message{webhookMessage}, nil)
+
+		service := services.NewMigrationJobService(cfg, log, jobRepo, mocks.NewTransactionRepositoryMock(),
+			migrationService, reportService, newNotificationService(), webhookService, newAlertService())
+		job, err := service.RunMigration(ctx, file, options)
+
+		assert.Nil(t, err)
+		assert.Equal(t, migration.StatusCompleted, job.Status)
+		jobRepo.AssertExpectations(t)
+	})
+
+	t.Run("When the job fails its notifications are saved with it", func(t *testing.T) {
+		file := newFileHeader(t, "test.csv", "id,user_id,amount,datetime")
+		notificationMessage := outbox.Message{Kind: outbox.KindNotification, Description: "Migration 1 failed",
+			Payload: []byte(`{"channel":"slack"}`), Status: outbox.StatusPending}
+
+		jobRepo := mocks.NewMigrationJobRepositoryMock()
+		jobRepo.On("FindImported", ctx, mock.Anything, mock.Anything).
+			Return(migration.Job{}, errors.New(migration.NotFoundError))
+		jobRepo.On("Save", ctx, mock.Anything).Return("1", nil)
+		jobRepo.On("Update", ctx, mock.Anything).Return(nil)
+		jobRepo.On("Finish", ctx, mock.MatchedBy(func(job migration.Job) bool {
+			return job.Status == migration.StatusFailed
+		}), []outbox.Message{notificationMessage}).Return(nil)
+
+		migrationService := mocks.NewMigrationServiceMock()
+		migrationService.On("ProcessBalanceWithOptions", ctx, mock.Anything, expectedOptions, mock.Anything).
+			Return(report.MigrationSummary{}, errors.New(services.ReadFileError))
+
+		notificationService := mocks.NewNotificationServiceMock()
+		notificationService.On("Messages", mock.MatchedBy(func(notification notifier.Notification) bool {
+			return notification.Event == notifier.EventMigrationFailed &&
+				notification.Data["error"] == services.ReadFileError
+		})).Return([]outbox.Message{notificationMessage}, nil)
+
+		service := services.NewMigrationJobService(cfg, log, jobRepo, mocks.NewTransactionRepositoryMock(),
+			migrationService, mocks.NewReportServiceMock(), notificationService, newWebhookService(), newAlertService())
+		job, err := service.RunMigration(ctx, file, options)
+
+		assert.EqualError(t, err, services.ReadFileError)
+		assert.Equal(t, migration.StatusFailed, job.Status)
+		jobRepo.AssertExpectations(t)
+	})
+
+	t.Run("When the migration fails the error is returned with the failed job", func(t *testing.T) {
+		file := newFileHeader(t, "test.csv", "id,user_id,amount,datetime")
+		expectedError := errors.New(services.ReadFileError)
+
+		jobRepo := mocks.NewMigrationJobRepositoryMock()
+		jobRepo.On("FindImported", ctx, mock.Anything, mock.Anything).
+			Return(migration.Job{}, errors.New(migration.NotFoundError))
+		jobRepo.On("Save", ctx, mock.Anything).Return("1", nil)
+		jobRepo.On("Update", ctx, mock.Anything).Return(nil)
+		jobRepo.On("Finish", ctx, mock.Anything, []outbox.Message{}).Return(nil)
+
+		migrationService := mocks.NewMigrationServiceMock()
+		migrationService.On("ProcessBalanceWithOptions", ctx, mock.Anything, expectedOptions, mock.Anything).
+			Return(report.MigrationSummary{}, expectedError)
+
+		reportService := mocks.NewReportServiceMock()
+
+		service := services.NewMigrationJobService(cfg, log, jobRepo, mocks.NewTransactionRepositoryMock(),
+			migrationService, reportService, newNotificationService(), newWebhookService(), newAlertService())
+		job, err := service.RunMigration(ctx, file, options)
+
+		assert.Equal(t, expectedError, err)
+		assert.Equal(t, migration.StatusFailed, job.Status)
+		reportService.AssertNotCalled(t, "GenerateReport", mock.Anything, mock.Anything, mock.Anything)
+	})
+
+	t.Run("When the job and its report can't be saved the job is failed", func(t *testing.T) {
+		content := "id,user_id,amount,datetime\n1,1,100,2024-09-13T10:00:00Z"
+		file := newFileHeader(t, "test.csv", content)
+		expectedError := errors.New("repository error")
+
+		jobRepo := mocks.NewMigrationJobRepositoryMock()
+		jobRepo.On("FindImported", ctx, mock.Anything, mock.Anything).
+			Return(migration.Job{}, errors.New(migration.NotFoundError))
+		jobRepo.On("Save", ctx, mock.Anything).Return("1", nil)
+		jobRepo.On("Update", ctx, mock.Anything).Return(nil)
+		jobRepo.On("Finish", ctx, mock.MatchedBy(func(job migration.Job) bool {
+			return job.Status == migration.StatusCompleted
+		}), []outbox.Message{reportMessage}).Return(expectedError)
+		jobRepo.On("Finish", ctx, mock.MatchedBy(func(job migration.Job) bool {
+			return job.Status == migration.StatusFailed
+		}), []outbox.Message{}).Return(nil)
+
+		migrationService := mocks.NewMigrationServiceMock()
+		migrationService.On("ProcessBalanceWithOptions", ctx, mock.Anything, expectedOptions, mock.Anything).
+			Return(report.MigrationSummary{TotalRecords: 1, UsersUpdated: 1}, nil)
+
+		reportService := mocks.NewReportServiceMock()
+		reportService.On("GenerateReport", mock.Anything, mock.Anything, destinations).Return(reportMessage, nil)
+
+		service := services.NewMigrationJobService(cfg, log, jobRepo, mocks.NewTransactionRepositoryMock(),
+			migrationService, reportService, newNotificationService(), newWebhookService(), newAlertService())
+		job, err := service.RunMigration(ctx, file, options)
+
+		assert.Equal(t, expectedError, err)
+		assert.Equal(t, migration.StatusFailed, job.Status)
+		jobRepo.AssertCalled(t, "Finish", ctx, mock.MatchedBy(func(job migration.Job) bool {
+			return job.Status == migration.StatusFailed
+		}), []outbox.Message{})
+	})
+
+	t.Run("When a re-saved copy of an imported file is refused with the earlier migration", func(t *testing.T) {
+		content := "id,user_id,amount,datetime\r\n1,1,100,2024-09-13T10:00:00Z\r\n\r\n"
+		file := newFileHeader(t, "copy.csv", content)
+		normalizedHash := contentHash(t, "id,user_id,amount,datetime\n1,1,100,2024-09-13T10:00:00Z")
+
+		jobRepo := mocks.NewMigrationJobRepositoryMock()
+		jobRepo.On("FindImported", ctx, checksum(content), normalizedHash).
+			Return(migration.Job{ID: "7", Status: migration.StatusCompleted}, nil)
+
+		migrationService := mocks.NewMigrationServiceMock()
+
+		service := services.NewMigrationJobService(cfg, log, jobRepo, mocks.NewTransactionRepositoryMock(),
+			migrationService, mocks.NewReportServiceMock(), newNotificationService(), newWebhookService(), newAlertService())
+		_, err := service.RunMigration(ctx, file, options)
+
+		assert.EqualError(t, err, migration.DuplicateFileError+" by migration 7")
+		jobRepo.AssertNotCalled(t, "Save", mock.Anything, mock.Anything)
+		migrationService.AssertNotCalled(t, "ProcessBalanceWithOptions", mock.Anything, mock.Anything, mock.Anything,
+			mock.Anything)
+	})
+
+	t.Run("When a forced import records the migration it repeats", func(t *testing.T) {
+		content := "id,user_id,amount,datetime\n1,1,100,2024-09-13T10:00:00Z"
+		file := newFileHeader(t, "test.csv", content)
+		forcedOptions := options
+		forcedOptions.Force = true
+		expectedForcedOptions := forcedOptions
+		expectedForcedOptions.MigrationID = "8"
+
+		jobRepo := mocks.NewMigrationJobRepositoryMock()
+		jobRepo.On("FindImported", ctx, checksum(content), contentHash(t, content)).
+			Return(migration.Job{ID: "7", Status: migration.StatusCompleted}, nil)
+		jobRepo.On("Save", ctx, migration.Job{Status: migration.StatusPending, FileName: "test.csv",
+			Checksum: checksum(content), ContentHash: contentHash(t, content), Forced: true, DuplicateOf: "7",
+			UploadedBy: "ops@example.com", Mode: migration.ModePartial, ReportDestinations: destinations}).Return("8", nil)
+		jobRepo.On("Update", ctx, mock.Anything).Return(nil)
+		jobRepo.On("Finish", ctx, mock.Anything, []outbox.Message{reportMessage}).Return(nil)
+
+		migrationService := mocks.NewMigrationServiceMock()
+		migrationService.On("ProcessBalanceWithOptions", ctx, mock.Anything, expectedForcedOptions, mock.Anything).
+			Return(report.MigrationSummary{TotalRecords: 1, UsersUpdated: 1}, nil)
+
+		reportService := mocks.NewReportServiceMock()
+		reportService.On("GenerateReport", mock.Anything, mock.Anything, destinations).Return(reportMessage, nil)
+
+		service := services.NewMigrationJobService(cfg, log, jobRepo, mocks.NewTransactionRepositoryMock(),
+			migrationService, reportService, newNotificationService(), newWebhookService(), newAlertService())
+		job, err := service.RunMigration(ctx, file, forcedOptions)
+
+		assert.Nil(t, err)
+		assert.Equal(t, migration.StatusCompleted, job.Status)
+		assert.True(t, job.Forced)
+		assert.Equal(t, "7", job.DuplicateOf)
+	})
+
+	t.Run("When the imported files can't be looked up", func(t *testing.T) {
+		file := newFileHeader(t, "test.csv", "id,user_id,amount,datetime")
+		expectedError := errors.New("repository error")
+
+		jobRepo := mocks.NewMigrationJobRepositoryMock()
+		jobRepo.On("FindImported", ctx, mock.Anything, mock.Anything).Return(migration.Job{}, expectedError)
+
+		service := services.NewMigrationJobService(cfg, log, jobRepo, mocks.NewTransactionRepositoryMock(),
+			mocks.NewMigrationServiceMock(), mocks.NewReportServiceMock(), newNotificationService(),
+			newWebhookService(), newAlertService())
+		_, err := service.RunMigration(ctx, file, options)
+
+		assert.Equal(t, expectedError, err)
+		jobRepo.AssertNotCalled(t, "Save", mock.Anything, mock.Anything)
+	})
+
+	t.Run("When another upload of the file saved its job first", func(t *testing.T) {
+		file := newFileHeader(t, "test.csv", "id,user_id,amount,datetime")
+		migrationService := mocks.NewMigrationServiceMock()
+
+		jobRepo := mocks.NewMigrationJobRepositoryMock()
+		jobRepo.On("FindImported", ctx, mock.Anything, mock.Anything).
+			Return(migration.Job{}, errors.New(migration.NotFoundError))
+		jobRepo.On("Save", ctx, mock.Anything).Return("", errors.New(migration.DuplicateFileError))
+
+		service := services.NewMigrationJobService(cfg, log, jobRepo, mocks.NewTransactionRepositoryMock(),
+			migrationService, mocks.NewReportServiceMock(), newNotificationService(), newWebhookService(),
+			newAlertService())
+		_, err := service.RunMigration(ctx, file, options)
+
+		assert.EqualError(t, err, migration.DuplicateFileError)
+		migrationService.AssertNotCalled(t, "ProcessBalanceWithOptions", mock.Anything, mock.Anything, mock.Anything,
+			mock.Anything)
+	})
+}
+
+func Test_MigrationJobService_RunFileMigration(t *testing.T) {
+	ctx := context.TODO()
+	cfg := config.NewConfig()
+	log := logger.NewLogger()
+	destinations := []string{"test@example.com"}
+	options := migration.Options{Mode: migration.ModeDefault, ReportDestinations: destinations}
+	content := "id,user_id,amount,datetime\n1,1,100,2024-09-13T10:00:00Z"
+	reportMessage := newReportMessage()
+
+	t.Run("When the job takes over the file and removes it once it ends", func(t *testing.T) {
+		file := newMigrationFile(t, "upload.csv", content)
+		expectedOptions := options
+		expectedOptions.MigrationID = "3"
+
+		jobRepo := mocks.NewMigrationJobRepositoryMock()
+		jobRepo.On("FindImported", ctx, checksum(content), contentHash(t, content)).
+			Return(migration.Job{}, errors.New(migration.NotFoundError))
+		jobRepo.On("Save", ctx, migration.Job{Status: migration.StatusPending, FileName: "upload.csv",
+			Checksum: checksum(content), ContentHash: contentHash(t, content), Mode: migration.ModeDefault,
+			ReportDestinations: destinations}).Return("3", nil)
+		jobRepo.On("Update", ctx, mock.Anything).Return(nil)
+		jobRepo.On("Finish", ctx, mock.Anything, []outbox.Message{reportMessage}).Return(nil)
+
+		migrationService := mocks.NewMigrationServiceMock()
+		migrationService.On("ProcessBalanceWithOptions", ctx, mock.Anything, expectedOptions, mock.Anything).
+			Return(report.MigrationSummary{TotalRecords: 1, UsersUpdated: 1}, nil)
+
+		reportService := mocks.NewReportServiceMock()
+		reportService.On("GenerateReport", mock.Anything, mock.Anything, destinations).Return(reportMessage, nil)
+
+		service := services.NewMigrationJobService(cfg, log, jobRepo, mocks.NewTransactionRepositoryMock(),
+			migrationService, reportService, newNotificationService(), newWebhookService(), newAlertService())
+		job, err := service.RunFileMigration(ctx, file, options)
+
+		assert.Nil(t, err)
+		assert.Equal(t, migration.StatusCompleted, job.Status)
+		_, err = os.Stat(file.Path)
+		assert.True(t, errors.Is(err, os.ErrNotExist))
+	})
+
+	t.Run("When the file was already imported it's left untouched", func(t *testing.T) {
+		file := newMigrationFile(t, "upload.csv", content)
+
+		jobRepo := mocks.NewMigrationJobRepositoryMock()
+		jobRepo.On("FindImported", ctx, checksum(content), contentHash(t, content)).
+			Return(migration.Job{ID: "7", Status: migration.StatusCompleted}, nil)
+
+		service := services.NewMigrationJobService(cfg, log, jobRepo, mocks.NewTransactionRepositoryMock(),
+			mocks.NewMigrationServiceMock(), mocks.NewReportServiceMock(), newNotificationService(),
+			newWebhookService(), newAlertService())
+		job, err := service.RunFileMigration(ctx, file, options)
+
+		assert.EqualError(t, err, migration.DuplicateFileError+" by migration 7")
+		assert.Empty(t, job.ID)
+		_, err = os.Stat(file.Path)
+		assert.Nil(t, err)
+	})
+
+	t.Run("When the file can't be read", func(t *testing.T) {
+		file := services.MigrationFile{Name: "upload.csv", Path: filepath.Join(t.TempDir(), "missing.csv")}
+
+		service := services.NewMigrationJobService(cfg, log, mocks.NewMigrationJobRepositoryMock(),
+			mocks.NewTransactionRepositoryMock(), mocks.NewMigrationServiceMock(), mocks.NewReportServiceMock(),
+			newNotificationService(), newWebhookService(), newAlertService())
+		_, err := service.RunFileMigration(ctx, file, options)
+
+		assert.True(t, strings.HasPrefix(err.Error(), services.ReadFileError))
+	})
+}
+
+func Test_MigrationJobService_ForEachReject(t *testing.T) {
+	ctx := context.TODO()
+	cfg := config.NewConfig()
+	log := logger.NewLogger()
+
+	t.Run("When the rejects of the job are handed in order", func(t *testing.T) {
+		rejects := []migration.Reject{
+			{Line: 2, Reason: migration.RejectReasonUnknownUser},
+			{Line: 5, Reason: migration.RejectReasonDuplicateID},
+		}
+
+		jobRepo := mocks.NewMigrationJobRepositoryMock()
+		jobRepo.On("FindByID", ctx, "1").Return(migration.Job{ID: "1"}, nil)
+		jobRepo.On("ForEachReject", ctx, "1", mock.Anything).Return(rejects, nil)
+
+		service := services.NewMigrationJobService(cfg, log, jobRepo, mocks.NewTransactionRepositoryMock(),
+			mocks.NewMigrationServiceMock(), mocks.NewReportServiceMock(), newNotificationService(),
+			newWebhookService(), newAlertService())
+
+		var result []migration.Reject
+		err := service.ForEachReject(ctx, "1", func(reject migration.Reject) error {
+			result = append(result, reject)
+			return nil
+		})
+
+		assert.Nil(t, err)
+		assert.Equal(t, rejects, result)
+	})
+
+	t.Run("When the job does not exist", func(t *testing.T) {
+		expectedError := errors.New(migration.NotFoundError)
+
+		jobRepo := mocks.NewMigrationJobRepositoryMock()
+		jobRepo.On("FindByID", ctx, "1").Return(migration.Job{}, expectedError)
+
+		service := services.NewMigrationJobService(cfg, log, jobRepo, mocks.NewTransactionRepositoryMock(),
+			mocks.NewMigrationServiceMock(), mocks.NewReportServiceMock(), newNotificationService(),
+			newWebhookService(), newAlertService())
+		err := service.ForEachReject(ctx, "1", func(reject migration.Reject) error {
+			return nil
+		})
+
+		assert.Equal(t, expectedError, err)
+		jobRepo.AssertNotCalled(t, "ForEachReject", mock.Anything, mock.Anything, mock.Anything)
+	})
+}
+
+func Test_MigrationJobService_GetJob(t *testing.T) {
+	ctx := context.TODO()
+	cfg := config.NewConfig()
+	log := logger.NewLogger()
+
+	t.Run("When GetJob returns an active job", func(t *testing.T) {
+		job := migration.Job{ID: "1", Status: migration.StatusRunning, UpdatedAt: time.Now()}
+
+		jobRepo := mocks.NewMigrationJobRepositoryMock()
+		jobRepo.On("FindByID", ctx, "1").Return(job, nil)
+
+		service := services.NewMigrationJobService(cfg, log, jobRepo, mocks.NewTransactionRepositoryMock(),
+			mocks.NewMigrationServiceMock(), mocks.NewReportServiceMock(), newNotificationService(),
+			newWebhookService(), newAlertService())
+		result, err := service.GetJob(ctx, "1")
+
+		assert.Nil(t, err)
+		assert.Equal(t, job, result)
+		jobRepo.AssertNotCalled(t, "Update", mock.Anything, mock.Anything)
+	})
+
+	t.Run("When GetJob finds a job abandoned by its instance", func(t *testing.T) {
+		job := migration.Job{ID: "1", Status: migration.StatusRunning,
+			UpdatedAt: time.Now().Add(-cfg.Workers.MigrationJobStaleAfter - time.Minute)}
+		expectedJob := job
+		expectedJob.Status = migration.StatusFailed
+		expectedJob.Error = migration.InterruptedError
+
+		jobRepo := mocks.NewMigrationJobRepositoryMock()
+		jobRepo.On("FindByID", ctx, "1").Return(job, nil)
+		jobRepo.On("Update", ctx, expectedJob).Return(nil)
+
+		service := services.NewMigrationJobService(cfg, log, jobRepo, mocks.NewTransactionRepositoryMock(),
+			mocks.NewMigrationServiceMock(), mocks.NewReportServiceMock(), newNotificationService(),
+			newWebhookService(), newAlertService())
+		result, err := service.GetJob(ctx, "1")
+
+		assert.Nil(t, err)
+		assert.Equal(t, expectedJob, result)
+	})
+
+	t.Run("When the abandoned job was finished by another request it's read again", func(t *testing.T) {
+		job := migration.Job{ID: "1", Status: migration.StatusRunning,
+			UpdatedAt: time.Now().Add(-cfg.Workers.MigrationJobStaleAfter - time.Minute)}
+		finishedJob := migration.Job{ID: "1", Status: migration.StatusCompleted, UpdatedAt: time.Now()}
+
+		jobRepo := mocks.NewMigrationJobRepositoryMock()
+		jobRepo.On("FindByID", ctx, "1").Return(job, nil).Once()
+		jobRepo.On("Update", ctx, mock.Anything).Return(errors.New(migration.NotFoundError))
+		jobRepo.On("FindByID", ctx, "1").Return(finishedJob, nil).Once()
+
+		service := services.NewMigrationJobService(cfg, log, jobRepo, mocks.NewTransactionRepositoryMock(),
+			mocks.NewMigrationServiceMock(), mocks.NewReportServiceMock(), newNotificationService(),
+			newWebhookService(), newAlertService())
+		result, err := service.GetJob(ctx, "1")
+
+		assert.Nil(t, err)
+		assert.Equal(t, finishedJob, result)
+	})
+
+	t.Run("When GetJob does not find the job", func(t *testing.T) {
+		expectedError := errors.New(migration.NotFoundError)
+
+		jobRepo := mocks.NewMigrationJobRepositoryMock()
+		jobRepo.On("FindByID", ctx, "1").Return(migration.Job{}, expectedError)
+
+		service := services.NewMigrationJobService(cfg, log, jobRepo, mocks.NewTransactionRepositoryMock(),
+			mocks.NewMigrationServiceMock(), mocks.NewReportServiceMock(), newNotificationService(),
+			newWebhookService(), newAlertService())
+		_, err := service.GetJob(ctx, "1")
+
+		assert.Equal(t, expectedError, err)
+	})
+}
+
+func Test_MigrationJobService_ListJobs(t *testing.T) {
+	ctx := context.TODO()
+	cfg := config.NewConfig()
+	log := logger.NewLogger()
+
+	t.Run("When there are more jobs than the limit the next cursor points to the last one", func(t *testing.T) {
+		jobs := []migration.Job{{ID: "3"}, {ID: "2"}, {ID: "1"}}
+
+		jobRepo := mocks.NewMigrationJobRepositoryMock()
+		jobRepo.On("List", ctx, migration.ListOptions{Limit: 3}).Return(jobs, nil)
+
+		service := services.NewMigrationJobService(cfg, log, jobRepo, mocks.NewTransactionRepositoryMock(),
+			mocks.NewMigrationServiceMock(), mocks.NewReportServiceMock(), newNotificationService(),
+			newWebhookService(), newAlertService())
+		page, err := service.ListJobs(ctx, migration.ListOptions{Limit: 2})
+
+		assert.Nil(t, err)
+		assert.Equal(t, jobs[:2], page.Migrations)
+		cursor, err := migration.DecodeJobCursor(page.NextCursor)
+		assert.Nil(t, err)
+		assert.Equal(t, "2", cursor.ID)
+	})
+
+	t.Run("When the last page has no next cursor", func(t *testing.T) {
+		jobs := []migration.Job{{ID: "1"}}
+
+		jobRepo := mocks.NewMigrationJobRepositoryMock()
+		jobRepo.On("List", ctx, migration.ListOptions{Limit: 3}).Return(jobs, nil)
+
+		service := services.NewMigrationJobService(cfg, log, jobRepo, mocks.NewTransactionRepositoryMock(),
+			mocks.NewMigrationServiceMock(), mocks.NewReportServiceMock(), newNotificationService(),
+			newWebhookService(), newAlertService())
+		page, err := service.ListJobs(ctx, migration.ListOptions{Limit: 2})
+
+		assert.Nil(t, err)
+		assert.Equal(t, jobs, page.Migrations)
+		assert.Empty(t, page.NextCursor)
+	})
+
+	t.Run("When List returns an error", func(t *testing.T) {
+		expectedError := errors.New("repository error")
+
+		jobRepo := mocks.NewMigrationJobRepositoryMock()
+		jobRepo.On("List", ctx, mock.Anything).Return([]migration.Job(nil), expectedError)
+
+		service := services.NewMigrationJobService(cfg, log, jobRepo, mocks.NewTransactionRepositoryMock(),
+			mocks.NewMigrationServiceMock(), mocks.NewReportServiceMock(), newNotificationService(),
+			newWebhookService(), newAlertService())
+		_, err := service.ListJobs(ctx, migration.ListOptions{Limit: 2})
+
+		assert.Equal(t, expectedError, err)
+	})
+}
+
+func Test_MigrationJobService_ListTransactions(t *testing.T) {
+	ctx := context.TODO()
+	cfg := config.NewConfig()
+	log := logger.NewLogger()
+
+	t.Run("When the transactions of the job are paged after the cursor", func(t *testing.T) {
+		items := []transaction.ListItem{
+			{Transaction: transaction.Transaction{ID: "11", MigrationID: "1"}},
+			{Transaction: transaction.Transaction{ID: "12", MigrationID: "1"}},
+		}
+
+		jobRepo := mocks.NewMigrationJobRepositoryMock()
+		jobRepo.On("FindByID", ctx, "1").Return(migration.Job{ID: "1"}, nil)
+		transactionRepo := mocks.NewTransactionRepositoryMock()
+		transactionRepo.On("ListByMigration", ctx, "1", "10", 2).Return(items, nil)
+
+		service := services.NewMigrationJobService(cfg, log, jobRepo, transactionRepo,
+			mocks.NewMigrationServiceMock(), mocks.NewReportServiceMock(), newNotificationService(),
+			newWebhookService(), newAlertService())
+		page, err := service.ListTransactions(ctx, "1", migration.ListOptions{Limit: 1,
+			After: &migration.Cursor{ID: "10"}})
+
+		assert.Nil(t, err)
+		assert.Equal(t, items[:1], page.Transactions)
+		cursor, err := migration.DecodeTransactionCursor(page.NextCursor)
+		assert.Nil(t, err)
+		assert.Equal(t, "11", cursor.ID)
+	})
+
+	t.Run("When the job does not exist", func(t *testing.T) {
+		expectedError := errors.New(migration.NotFoundError)
+
+		jobRepo := mocks.NewMigrationJobRepositoryMock()
+		jobRepo.On("FindByID", ctx, "1").Return(migration.Job{}, expectedError)
+		transactionRepo := mocks.NewTransactionRepositoryMock()
+
+		service := services.NewMigrationJobService(cfg, log, jobRepo, transactionRepo,
+			mocks.NewMigrationServiceMock(), mocks.NewReportServiceMock(), newNotificationService(),
+			newWebhookService(), newAlertService())
+		_, err := service.ListTransactions(ctx, "1", migration.ListOptions{Limit: 1})
+
+		assert.Equal(t, expectedError, err)
+		transactionRepo.AssertNotCalled(t, "ListByMigration", mock.Anything, mock.Anything, mock.Anything,
+			mock.Anything)
+	})
+}
+
+func Test_MigrationJobService_Rollback(t *testing.T) {
+	ctx := context.TODO()
+	cfg := config.NewConfig()
+	log := logger.NewLogger()
+
+	rolledBackAt := time.Now()
+	rolledBack := migration.Job{ID: "1", Status: migration.StatusRolledBack, RolledBackAt: &rolledBackAt}
+
+	t.Run("When Rollback saves a single rolled back event with it and checks the alerts of the users", func(t *testing.T) {
+		messages := []outbox.Message{{Kind: outbox.KindWebhook, Reference: "webhook:1"}}
+
+		jobRepo := mocks.NewMigrationJobRepositoryMock()
+		jobRepo.On("FindByID", ctx, "1").Return(migration.Job{ID: "1", Status: migration.StatusCompleted}, nil).Once()
+		jobRepo.On("Rollback", ctx, "1", messages).Return(int64(3), nil)
+		jobRepo.On("FindByID", ctx, "1").Return(rolledBack, nil)
+		transactionRepo := mocks.NewTransactionRepositoryMock()
+		transactionRepo.On("FindMigrationUserIDs", ctx, "1").Return([]string{"1", "2"}, nil)
+		webhookService := mocks.NewWebhookServiceMock()
+		webhookService.On("Messages", ctx, webhook.EventMigrationRolledBack, mock.MatchedBy(func(job migration.Job) bool {
+			return job.ID == "1" && job.Status == migration.StatusRolledBack && job.RolledBackAt != nil
+		})).Return(messages, nil)
+		alertService := mocks.NewAlertServiceMock()
+		alertService.On("Check", ctx, []string{"1", "2"}, []transaction.Transaction(nil)).Return(nil)
+
+		service := services.NewMigrationJobService(cfg, log, jobRepo, transactionRepo,
+			mocks.NewMigrationServiceMock(), mocks.NewReportServiceMock(), newNotificationService(), webhookService,
+			alertService)
+		result, err := service.Rollback(ctx, "1")
+
+		assert.Nil(t, err)
+		assert.Equal(t, rolledBack, result)
+		jobRepo.AssertExpectations(t)
+		alertService.AssertExpectations(t)
+		transactionRepo.AssertNotCalled(t, "ListByMigration", mock.Anything, mock.Anything, mock.Anything,
+			mock.Anything)
+	})
+
+	t.Run("When a failed migration is rolled back", func(t *testing.T) {
+		jobRepo := mocks.NewMigrationJobRepositoryMock()
+		jobRepo.On("FindByID", ctx, "1").Return(migration.Job{ID: "1", Status: migration.StatusFailed}, nil).Once()
+		jobRepo.On("Rollback", ctx, "1", []outbox.Message{}).Return(int64(1), nil)
+		jobRepo.On("FindByID", ctx, "1").Return(rolledBack, nil)
+		transactionRepo := mocks.NewTransactionRepositoryMock()
+		transactionRepo.On("FindMigrationUserIDs", ctx, "1").Return([]string{"1"}, nil)
+
+		service := services.NewMigrationJobService(cfg, log, jobRepo, transactionRepo,
+			mocks.NewMigrationServiceMock(), mocks.NewReportServiceMock(), newNotificationService(),
+			newWebhookService(), newAlertService())
+		result, err := service.Rollback(ctx, "1")
+
+		assert.Nil(t, err)
+		assert.Equal(t, rolledBack, result)
+	})
+
+	t.Run("When the users of a rolled back migration can't be read the alerts are skipped", func(t *testing.T) {
+		jobRepo := mocks.NewMigrationJobRepositoryMock()
+		jobRepo.On("FindByID", ctx, "1").Return(migration.Job{ID: "1", Status: migration.StatusCompleted}, nil).Once()
+		jobRepo.On("Rollback", ctx, "1", []outbox.Message{}).Return(int64(1), nil)
+		jobRepo.On("FindByID", ctx, "1").Return(rolledBack, nil)
+		transactionRepo := mocks.NewTransactionRepositoryMock()
+		transactionRepo.On("FindMigrationUserIDs", ctx, "1").Return([]string(nil), errors.New("repository error"))
+		alertService := newAlertService()
+
+		service := services.NewMigrationJobService(cfg, log, jobRepo, transactionRepo,
+			mocks.NewMigrationServiceMock(), mocks.NewReportServiceMock(), newNotificationService(),
+			newWebhookService(), alertService)
+		result, err := service.Rollback(ctx, "1")
+
+		assert.Nil(t, err)
+		assert.Equal(t, rolledBack, result)
+		alertService.AssertNotCalled(t, "Check", mock.Anything, mock.Anything, mock.Anything)
+	})
+
+	t.Run("When the migration is still running", func(t *testing.T) {
+		jobRepo := mocks.NewMigrationJobRepositoryMock()
+		jobRepo.On("FindByID", ctx, "1").Return(migration.Job{ID: "1", Status: migration.StatusRunning}, nil)
+		webhookService := newWebhookService()
+
+		service := services.NewMigrationJobService(cfg, log, jobRepo, mocks.NewTransactionRepositoryMock(),
+			mocks.NewMigrationServiceMock(), mocks.NewReportServiceMock(), newNotificationService(),
+			webhookService, newAlertService())
+		_, err := service.Rollback(ctx, "1")
+
+		assert.EqualError(t, err, migration.RollbackStatusError)
+		webhookService.AssertNotCalled(t, "Messages", mock.Anything, mock.Anything, mock.Anything)
+		jobRepo.AssertNotCalled(t, "Rollback", mock.Anything, mock.Anything, mock.Anything)
+	})
+
+	t.Run("When the transactions were edited after the migration", func(t *testing.T) {
+		expectedError := errors.New(migration.RollbackEditedError)
+
+		jobRepo := mocks.NewMigrationJobRepositoryMock()
+		jobRepo.On("FindByID", ctx, "1").Return(migration.Job{ID: "1", Status: migration.StatusCompleted}, nil)
+		jobRepo.On("Rollback", ctx, "1", []outbox.Message{}).Return(int64(0), expectedError)
+		alertService := newAlertService()
+
+		service := services.NewMigrationJobService(cfg, log, jobRepo, mocks.NewTransactionRepositoryMock(),
+			mocks.NewMigrationServiceMock(), mocks.NewReportServiceMock(), newNotificationService(),
+			newWebhookService(), alertService)
+		_, err := service.Rollback(ctx, "1")
+
+		assert.Equal(t, expectedError, err)
+		alertService.AssertNotCalled(t, "Check", mock.Anything, mock.Anything, mock.Anything)
+	})
+}
+
+func Test_MigrationJobService_FailInterruptedJobs(t *testing.T) {
+	ctx := context.TODO()
+	cfg := config.NewConfig()
+	log := logger.NewLogger()
+
+	t.Run("When FailInterruptedJobs marks the stale jobs", func(t *testing.T) {
+		jobRepo := mocks.NewMigrationJobRepositoryMock()
+		jobRepo.On("FindStale", ctx, mock.Anything).Return([]migration.Job{}, nil)
+		jobRepo.On("FailStale", ctx, mock.Anything, migration.InterruptedError).Return(int64(2), nil)
+
+		service := services.NewMigrationJobService(cfg, log, jobRepo, mocks.NewTransactionRepositoryMock(),
+			mocks.NewMigrationServiceMock(), mocks.NewReportServiceMock(), newNotificationService(),
+			newWebhookService(), newAlertService())
+
+		assert.Nil(t, service.FailInterruptedJobs(ctx))
+	})
+
+	t.Run("When a job stopped after all its records were saved it's finished with its report", func(t *testing.T) {
+		destinations := []string{"test@example.com"}
+		reportMessage := newReportMessage()
+		interrupted := migration.Job{ID: "3", Status: migration.StatusRunning, ReportDestinations: destinations,
+			Progress: migration.Progress{RowsValidated: 3, RowsInserted: 1, BatchesDone: 1, BatchesTotal: 2}}
+		saved := report.MigrationSummary{JobID: "3", TotalRecords: 2, UsersUpdated: 1, RejectedRecords: 1,
+			RejectsByReason: map[string]int{migration.RejectReasonUnknownUser: 1}}
+
+		jobRepo := mocks.NewMigrationJobRepositoryMock()
+		jobRepo.On("FindStale", ctx, mock.Anything).Return([]migration.Job{interrupted}, nil)
+		jobRepo.On("Saved", ctx, "3").Return(saved, nil)
+		var finished migration.Job
+		jobRepo.On("Finish", ctx, mock.Anything, []outbox.Message{reportMessage}).Run(func(args mock.Arguments) {
+			finished = args.Get(1).(migration.Job)
+		}).Return(nil)
+		jobRepo.On("FailStale", ctx, mock.Anything, migration.InterruptedError).Return(int64(0), nil)
+
+		reportService := mocks.NewReportServiceMock()
+		reportService.On("GenerateReport", ctx, saved, destinations).Return(reportMessage, nil)
+
+		service := services.NewMigrationJobService(cfg, log, jobRepo, mocks.NewTransactionRepositoryMock(),
+			mocks.NewMigrationServiceMock(), reportService, newNotificationService(), newWebhookService(),
+			newAlertService())
+
+		assert.Nil(t, service.FailInterruptedJobs(ctx))
+		assert.Equal(t, migration.StatusCompleted, finished.Status)
+		assert.Equal(t, &saved, finished.Summary)
+		assert.Equal(t, migration.Progress{RowsValidated: 3, RowsInserted: 2, BatchesDone: 2, BatchesTotal: 2},
+			finished.Progress)
+	})
+
+	t.Run("When a job stopped before all its records were saved it's failed", func(t *testing.T) {
+		interrupted := migration.Job{ID: "3", Status: migration.StatusRunning,
+			Progress: migration.Progress{RowsValidated: 3, RowsInserted: 1, BatchesDone: 1, BatchesTotal: 2}}
+
+		jobRepo := mocks.NewMigrationJobRepositoryMock()
+		jobRepo.On("FindStale", ctx, mock.Anything).Return([]migration.Job{interrupted}, nil)
+		jobRepo.On("Saved", ctx, "3").Return(report.MigrationSummary{JobID: "3", TotalRecords: 1, UsersUpdated: 1},
+			nil)
+		jobRepo.On("FailStale", ctx, mock.Anything, migration.InterruptedError).Return(int64(1), nil)
+
+		reportService := mocks.NewReportServiceMock()
+		service := services.NewMigrationJobService(cfg, log, jobRepo, mocks.NewTransactionRepositoryMock(),
+			mocks.NewMigrationServiceMock(), reportService, newNotificationService(), newWebhookService(),
+			newAlertService())
+
+		assert.Nil(t, service.FailInterruptedJobs(ctx))
+		jobRepo.AssertNotCalled(t, "Finish", mock.Anything, mock.Anything, mock.Anything)
+		reportService.AssertNotCalled(t, "GenerateReport", mock.Anything, mock.Anything, mock.Anything)
+	})
+
+	t.Run("When FailStale returns an error", func(t *testing.T) {
+		expectedError := errors.New("repository error")
+
+		jobRepo := mocks.NewMigrationJobRepositoryMock()
+		jobRepo.On("FindStale", ctx, mock.Anything).Return([]migration.Job{}, nil)
+		jobRepo.On("FailStale", ctx, mock.Anything, migration.InterruptedError).Return(int64(0), expectedError)
+
+		service := services.NewMigrationJobService(cfg, log, jobRepo, mocks.NewTransactionRepositoryMock(),
+			mocks.NewMigrationServiceMock(), mocks.NewReportServiceMock(), newNotificationService(),
+			newWebhookService(), newAlertService())
+
+		assert.Equal(t, expectedError, service.FailInterruptedJobs(ctx))
+	})
+}
+
+func checksum(content string) string {
+	sum := sha256.Sum256([]byte(content))
+	return hex.EncodeToString(sum[:])
+}
+
+func contentHash(t *testing.T, content string) string {
+	hash, err := fingerprint.ContentHash(strings.NewReader(content))
+	assert.NoError(t, err)
+
+	return hash
+}
+
+func newFileHeader(t *testing.T, filename, content string) *multipart.FileHeader {
+	body := new(bytes.Buffer)
+	writer := multipart.NewWriter(body)
+	part, err := writer.CreateFormFile("file", filename)
+	assert.NoError(t, err)
+
+	_, err = part.Write([]byte(content))
+	assert.NoError(t, err)
+	assert.NoError(t, writer.Close())
+
+	req := httptest.NewRequest("POST", "/", body)
+	req.Header.Set("Content-Type", writer.FormDataContentType())
+	_, file, err := req.FormFile("file")
+	assert.NoError(t, err)
+
+	return file
+}
+
+func newMigrationFile(t *testing.T, filename, content string) services.MigrationFile {
+	path := filepath.Join(t.TempDir(), filename)
+	assert.Nil(t, os.WriteFile(path, []byte(content), 0o600))
+
+	return services.MigrationFile{Name: filename, Path: path}
+}
+
+// matchSummary compares the summary sent to the report without its processing time, which changes on every run
+func matchSummary(expected report.MigrationSummary) interface{} {
+	return mock.MatchedBy(func(summary report.MigrationSummary) bool {
+		summary.ProcessingTime = 0
+		return assert.ObjectsAreEqual(expected, summary)
+	})
+}
+
+func withoutProcessingTime(summary *report.MigrationSummary) *report.MigrationSummary {
+	if summary == nil {
+		return nil
+	}
+
+	withoutTime := *summary
+	withoutTime.ProcessingTime = 0
+	return &withoutTime
+}
+
+func newReportMessage() outbox.Message {
+	return outbox.Message{Kind: outbox.KindEmail, Description: "Migration Report of migration 1",
+		Payload: []byte(`{"Subject":"Migration Report"}`), Status: outbox.StatusPending}
+}
+
+func waitForJob(t *testing.T, finished <-chan migration.Job) migration.Job {
+	select {
+	case job := <-finished:
+		return job
+	case <-time.After(5 * time.Second):
+		t.Fatal("migration job did not finish")
+		return migration.Job{}
+	}
+}
+
+// newNotificationService routes no notification, so the jobs are finished only with their report
+func newNotificationService() *mocks.NotificationServiceMock {
+	notificationService := mocks.NewNotificationServiceMock()
+	notificationService.On("Messages", mock.Anything).Return([]outbox.Message{}, nil)
+	return notificationService
+}
+
+func newWebhookService() *mocks.WebhookServiceMock {
+	webhookService := mocks.NewWebhookServiceMock()
+	webhookService.On("Messages", mock.Anything, mock.Anything, mock.Anything).Return([]outbox.Message{}, nil)
+	return webhookService
+}
+
+func newAlertService() *mocks.AlertServiceMock {
+	alertService := mocks.NewAlertServiceMock()
+	alertService.On("Check", mock.Anything, mock.Anything, mock.Anything).Return(nil)
+	return alertService
+}
@@ -0,0 +1,46 @@
+package services
+
+import (
+	"context"
+
+	"github.com/sebastianreh/user-balance-api/internal/domain/migration"
+	"github.com/sebastianreh/user-balance-api/pkg/logger"
+)
+
+type MigrationProfileService interface {
+	CreateProfile(ctx context.Context, profile migration.MappingProfile) (migration.MappingProfile, error)
+	GetProfile(ctx context.Context, name string) (migration.MappingProfile, error)
+	ListProfiles(ctx context.Context) ([]migration.MappingProfile, error)
+	DeleteProfile(ctx context.Context, name string) error
+}
+
+type migrationProfileService struct {
+	log        logger.Logger
+	repository migration.ProfileRepository
+}
+
+func NewMigrationProfileService(log logger.Logger, repository migration.ProfileRepository) MigrationProfileService {
+	return &migrationProfileService{
+		log:        log,
+		repository: repository,
+	}
+}
+
+// CreateProfile stores a profile already checked with Validate, profiles are never updated so a running migration
+// keeps the mapping it started with
+func (s *migrationProfileService) CreateProfile(ctx context.Context,
+	profile migration.MappingProfile) (migration.MappingProfile, error) {
+	return s.repository.Save(ctx, profile)
+}
+
+func (s *migrationProfileService) GetProfile(ctx context.Context, name string) (migration.MappingProfile, error) {
+	return s.repository.FindByName(ctx, name)
+}
+
+func (s *migrationProfileService) ListProfiles(ctx context.Context) ([]migration.MappingProfile, error) {
+	return s.repository.List(ctx)
+}
+
+func (s *migrationProfileService) DeleteProfile(ctx context.Context, name string) error {
+	return s.repository.Delete(ctx, name)
+}
@@ -0,0 +1,78 @@
+package services_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/sebastianreh/user-balance-api/internal/app/services"
+	"github.com/sebastianreh/user-balance-api/internal/domain/migration"
+	"github.com/sebastianreh/user-balance-api/pkg/logger"
+	"github.com/sebastianreh/user-balance-api/test/mocks"
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_MigrationProfileService(t *testing.T) {
+	ctx := context.TODO()
+	log := logger.NewLogger()
+	profile := migration.MappingProfile{
+		Name:    "partner-a",
+		Columns: map[string][]string{migration.FieldUserID: {"customer"}},
+	}
+
+	t.Run("When a profile is created it's returned with its creation time", func(t *testing.T) {
+		repository := mocks.NewMappingProfileRepositoryMock()
+		saved := profile
+		saved.CreatedAt = time.Now()
+		repository.On("Save", ctx, profile).Return(saved, nil)
+
+		service := services.NewMigrationProfileService(log, repository)
+		created, err := service.CreateProfile(ctx, profile)
+
+		assert.Nil(t, err)
+		assert.Equal(t, saved, created)
+	})
+
+	t.Run("When the profile name is already taken", func(t *testing.T) {
+		repository := mocks.NewMappingProfileRepositoryMock()
+		repository.On("Save", ctx, profile).Return(profile, errors.New(migration.DuplicateProfileError))
+
+		service := services.NewMigrationProfileService(log, repository)
+		_, err := service.CreateProfile(ctx, profile)
+
+		assert.EqualError(t, err, migration.DuplicateProfileError)
+	})
+
+	t.Run("When a profile is found by its name", func(t *testing.T) {
+		repository := mocks.NewMappingProfileRepositoryMock()
+		repository.On("FindByName", ctx, profile.Name).Return(profile, nil)
+
+		service := services.NewMigrationProfileService(log, repository)
+		found, err := service.GetProfile(ctx, profile.Name)
+
+		assert.Nil(t, err)
+		assert.Equal(t, profile, found)
+	})
+
+	t.Run("When the profiles are listed", func(t *testing.T) {
+		repository := mocks.NewMappingProfileRepositoryMock()
+		repository.On("List", ctx).Return([]migration.MappingProfile{profile}, nil)
+
+		service := services.NewMigrationProfileService(log, repository)
+		profiles, err := service.ListProfiles(ctx)
+
+		assert.Nil(t, err)
+		assert.Equal(t, []migration.MappingProfile{profile}, profiles)
+	})
+
+	t.Run("When the profile to delete does not exist", func(t *testing.T) {
+		repository := mocks.NewMappingProfileRepositoryMock()
+		repository.On("Delete", ctx, "missing").Return(errors.New(migration.ProfileNotFoundError))
+
+		service := services.NewMigrationProfileService(log, repository)
+		err := service.DeleteProfile(ctx, "missing")
+
+		assert.EqualError(t, err, migration.ProfileNotFoundError)
+	})
+}
@@ -1,52 +1,241 @@
 package services
 
 import (
+	"bytes"
+	"context"
+	"encoding/csv"
 	"fmt"
+	"math"
+	"slices"
+	"sort"
+	"strconv"
 	"strings"
+	"time"
 
+	"github.com/sebastianreh/user-balance-api/internal/domain/outbox"
 	"github.com/sebastianreh/user-balance-api/internal/domain/report"
+	"github.com/sebastianreh/user-balance-api/internal/domain/transaction"
 	"github.com/sebastianreh/user-balance-api/pkg/email"
 	"github.com/sebastianreh/user-balance-api/pkg/logger"
 )
 
 const (
 	MigrationReportServiceName = "MigrationReportService"
+	MigrationReportTemplate    = "migration_report"
+	reportLargestTransactions  = 5
+	reportLargestUserDeltas    = 10
 )
 
 type MigrationReportService interface {
-	GenerateAndSendReport(migrationSummary report.MigrationSummary, to []string) error
+	// GenerateReport returns the report email as an outbox message, it's sent once it's saved with the job
+	GenerateReport(ctx context.Context, migrationSummary report.MigrationSummary, to []string) (outbox.Message, error)
 }
 
 type migrationReportService struct {
-	log          logger.Logger
-	emailService email.EmailService
+	log                   logger.Logger
+	renderer              email.Renderer
+	transactionRepository transaction.Repository
 }
 
-func NewMigrationReportService(log logger.Logger, emailService email.EmailService) MigrationReportService {
+func NewMigrationReportService(log logger.Logger, renderer email.Renderer,
+	transactionRepository transaction.Repository) MigrationReportService {
 	return &migrationReportService{
-		log:          log,
-		emailService: emailService,
+		log:                   log,
+		renderer:              renderer,
+		transactionRepository: transactionRepository,
 	}
 }
 
-func (s *migrationReportService) GenerateAndSendReport(summary report.MigrationSummary, to []string) error {
+// GenerateReport writes the summary of a migration. The report of a job adds up the transactions it saved, lists
+// the largest ones and the users that changed the most, and attaches the balance of every user of the file. When
+// those can't be read the summary is sent without them. The HTML body is rendered from the report template and sent
+// along with the plain text one, which is sent alone when the template fails
+func (s *migrationReportService) GenerateReport(ctx context.Context, summary report.MigrationSummary,
+	to []string) (outbox.Message, error) {
 	subject := "Migration Report"
-	body := s.generateReportBody(summary)
-	err := s.emailService.SendEmail(to, subject, body)
+	description := subject
+	var breakdown *migrationBreakdown
+	if summary.JobID != "" {
+		description = fmt.Sprintf("%s of migration %s", subject, summary.JobID)
+		var err error
+		if breakdown, err = s.buildBreakdown(ctx, summary.JobID); err != nil {
+			s.log.ErrorAt(fmt.Errorf("could not build the report breakdown of migration %s: %w", summary.JobID, err),
+				MigrationReportServiceName, "GenerateReport")
+			breakdown = nil
+		}
+	}
+
+	message := email.Message{To: to, Subject: subject, Text: s.generateReportBody(summary, breakdown)}
+	if breakdown != nil {
+		message.Attachments = []email.Attachment{breakdown.Attachment}
+	}
+
+	html, err := s.renderer.Render(MigrationReportTemplate, newReportView(summary, breakdown))
+	if err != nil {
+		s.log.ErrorAt(fmt.Errorf("could not render the report template: %w", err), MigrationReportServiceName,
+			"GenerateReport")
+	} else {
+		message.HTML = html
+	}
+
+	reportMessage, err := outbox.NewMessage(outbox.KindEmail, description, message)
 	if err != nil {
-		err = fmt.Errorf("could not send report email, error: %w", err)
-		s.log.ErrorAt(err, MigrationReportServiceName, "GenerateAndSendReport")
-		return err
+		err = fmt.Errorf("could not build report message, error: %w", err)
+		s.log.ErrorAt(err, MigrationReportServiceName, "GenerateReport")
+		return outbox.Message{}, err
 	}
 
-	return nil
+	return reportMessage, nil
 }
 
-func (s *migrationReportService) generateReportBody(summary report.MigrationSummary) string {
+func (s *migrationReportService) generateReportBody(summary report.MigrationSummary,
+	breakdown *migrationBreakdown) string {
 	reportEmailBody := []string{
 		fmt.Sprintf("Total Records Processed: %d", summary.TotalRecords),
 		fmt.Sprintf("Total Users Updated: %d", summary.UsersUpdated),
 	}
 
+	if summary.UsersCreated > 0 {
+		reportEmailBody = append(reportEmailBody, fmt.Sprintf("Total Users Created: %d", summary.UsersCreated))
+	}
+
+	if summary.ProcessingTime > 0 {
+		reportEmailBody = append(reportEmailBody, fmt.Sprintf("Processing Time: %s", processingTime(summary)))
+	}
+
+	if summary.RejectedRecords > 0 {
+		reportEmailBody = append(reportEmailBody, fmt.Sprintf("Total Records Rejected: %d", summary.RejectedRecords))
+
+		reasons := make([]string, 0, len(summary.RejectsByReason))
+		for reason := range summary.RejectsByReason {
+			reasons = append(reasons, reason)
+		}
+		sort.Strings(reasons)
+
+		for _, reason := range reasons {
+			reportEmailBody = append(reportEmailBody, fmt.Sprintf("  %s: %d", reason, summary.RejectsByReason[reason]))
+		}
+
+		if summary.JobID != "" {
+			reportEmailBody = append(reportEmailBody,
+				fmt.Sprintf("Rejected records can be downloaded from /migrations/%s/rejects", summary.JobID))
+		}
+	}
+
+	if breakdown != nil {
+		reportEmailBody = append(reportEmailBody, breakdown.lines()...)
+	}
+
 	return strings.Join(reportEmailBody, "\n")
 }
+
+// buildBreakdown reads the totals of the migration and writes the balance of every user to the attachment, keeping
+// only the largest deltas for the body
+func (s *migrationReportService) buildBreakdown(ctx context.Context, migrationID string) (*migrationBreakdown, error) {
+	totals, err := s.transactionRepository.SummarizeMigration(ctx, migrationID, reportLargestTransactions)
+	if err != nil {
+		return nil, err
+	}
+
+	breakdown := &migrationBreakdown{Totals: totals}
+	var balances bytes.Buffer
+	writer := csv.NewWriter(&balances)
+	if err = writer.Write(report.BalancesCSVHeader()); err != nil {
+		return nil, err
+	}
+
+	err = s.transactionRepository.ForEachMigrationUser(ctx, migrationID, func(delta transaction.UserDelta) error {
+		breakdown.addDelta(delta)
+		return writer.Write([]string{delta.UserID, report.FormatCSVAmount(delta.Balance),
+			strconv.Itoa(delta.TotalDebits), strconv.Itoa(delta.TotalCredits)})
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	writer.Flush()
+	if err = writer.Error(); err != nil {
+		return nil, err
+	}
+
+	breakdown.Attachment = email.Attachment{FileName: fmt.Sprintf("migration-%s-balances.csv", migrationID),
+		ContentType: "text/csv", Content: balances.Bytes()}
+
+	return breakdown, nil
+}
+
+// migrationBreakdown holds what the report adds for a migration job, LargestDeltas is sorted by absolute amount.
+// Its fields are exported for the report template
+type migrationBreakdown struct {
+	Totals        transaction.MigrationTotals
+	LargestDeltas []transaction.UserDelta
+	Attachment    email.Attachment
+}
+
+// reportView is the data of the report template
+type reportView struct {
+	Summary        report.MigrationSummary
+	ProcessingTime string
+	Breakdown      *migrationBreakdown
+}
+
+func newReportView(summary report.MigrationSummary, breakdown *migrationBreakdown) reportView {
+	view := reportView{Summary: summary, Breakdown: breakdown}
+	if summary.ProcessingTime > 0 {
+		view.ProcessingTime = processingTime(summary)
+	}
+
+	return view
+}
+
+func processingTime(summary report.MigrationSummary) string {
+	return summary.ProcessingTime.Round(time.Millisecond).String()
+}
+
+// addDelta keeps the delta when it's among the largest ones, the earlier user wins a tie
+func (b *migrationBreakdown) addDelta(delta transaction.UserDelta) {
+	position := sort.Search(len(b.LargestDeltas), func(i int) bool {
+		return math.Abs(b.LargestDeltas[i].NetAmount) < math.Abs(delta.NetAmount)
+	})
+	if position == reportLargestUserDeltas {
+		return
+	}
+
+	b.LargestDeltas = slices.Insert(b.LargestDeltas, position, delta)
+	if len(b.LargestDeltas) > reportLargestUserDeltas {
+		b.LargestDeltas = b.LargestDeltas[:reportLargestUserDeltas]
+	}
+}
+
+func (b *migrationBreakdown) NetAmount() float64 {
+	return b.Totals.Credited - b.Totals.Debited
+}
+
+func (b *migrationBreakdown) lines() []string {
+	lines := []string{
+		fmt.Sprintf("Total Credited: %.2f", b.Totals.Credited),
+		fmt.Sprintf("Total Debited: %.2f", b.Totals.Debited),
+		fmt.Sprintf("Net Amount: %.2f", b.NetAmount()),
+	}
+
+	if len(b.Totals.Largest) > 0 {
+		lines = append(lines, "Largest Transactions:")
+		for _, largest := range b.Totals.Largest {
+			line := fmt.Sprintf("  %s: %.2f to user %s", largest.ID, largest.Amount, largest.UserID)
+			if largest.DateTime != nil {
+				line += " on " + largest.DateTime.UTC().Format(time.RFC3339)
+			}
+			lines = append(lines, line)
+		}
+	}
+
+	if len(b.LargestDeltas) > 0 {
+		lines = append(lines, "Largest User Deltas:")
+		for _, delta := range b.LargestDeltas {
+			lines = append(lines, fmt.Sprintf("  user %s: %+.2f (%d credits, %d debits), balance %.2f",
+				delta.UserID, delta.NetAmount, delta.Credits, delta.Debits, delta.Balance))
+		}
+	}
+
+	return append(lines, fmt.Sprintf("The balance of every user is in the attached %s", b.Attachment.FileName))
+}
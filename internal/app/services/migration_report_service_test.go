@@ -1,57 +1,202 @@
 package services_test
 
 import (
+	"context"
+	"encoding/json"
 	"errors"
+	"strconv"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/sebastianreh/user-balance-api/internal/app/services"
+	"github.com/sebastianreh/user-balance-api/internal/domain/outbox"
 	"github.com/sebastianreh/user-balance-api/internal/domain/report"
+	"github.com/sebastianreh/user-balance-api/internal/domain/transaction"
+	"github.com/sebastianreh/user-balance-api/pkg/email"
 	"github.com/sebastianreh/user-balance-api/pkg/logger"
 	"github.com/sebastianreh/user-balance-api/test/mocks"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
 )
 
-func TestMigrationReportService_GenerateAndSendReport(t *testing.T) {
+func TestMigrationReportService_GenerateReport(t *testing.T) {
+	ctx := context.TODO()
 	log := logger.NewLogger()
 	reportBody := "Total Records Processed: 5000\nTotal Users Updated: 200"
+	reportHTML := "<p>Migration Report</p>"
+	to := []string{"recipient@example.com"}
 
-	t.Run("it sends the report successfully", func(t *testing.T) {
-		emailServiceMock := mocks.NewEmailServiceMock()
-		reportService := services.NewMigrationReportService(log, emailServiceMock)
+	t.Run("it returns the report as a pending email", func(t *testing.T) {
+		reportService := services.NewMigrationReportService(log, newReportRenderer(),
+			mocks.NewTransactionRepositoryMock())
 
 		summary := report.MigrationSummary{
 			TotalRecords: 5000,
 			UsersUpdated: 200,
 		}
-		to := []string{"recipient@example.com"}
 
-		emailServiceMock.On("SendEmail", to, "Migration Report", reportBody).
-			Return(nil)
-
-		err := reportService.GenerateAndSendReport(summary, to)
+		message, err := reportService.GenerateReport(ctx, summary, to)
 
 		assert.Nil(t, err)
-		emailServiceMock.AssertCalled(t, "SendEmail", to, "Migration Report", reportBody)
+		assert.Equal(t, outbox.KindEmail, message.Kind)
+		assert.Equal(t, outbox.StatusPending, message.Status)
+		assert.Equal(t, "Migration Report", message.Description)
+		assert.Equal(t, email.Message{To: to, Subject: "Migration Report", Text: reportBody, HTML: reportHTML},
+			decodeReport(t, message))
 	})
 
-	t.Run("it returns error when email sending fails", func(t *testing.T) {
-		emailServiceMock := mocks.NewEmailServiceMock()
-		reportService := services.NewMigrationReportService(log, emailServiceMock)
+	t.Run("it adds the rejected records by reason to the report", func(t *testing.T) {
+		transactionRepository := mocks.NewTransactionRepositoryMock()
+		transactionRepository.On("SummarizeMigration", ctx, "7", 5).
+			Return(transaction.MigrationTotals{}, errors.New("connection refused"))
+		reportService := services.NewMigrationReportService(log, newReportRenderer(), transactionRepository)
 
 		summary := report.MigrationSummary{
-			TotalRecords: 5000,
-			UsersUpdated: 200,
+			JobID:           "7",
+			TotalRecords:    5000,
+			UsersUpdated:    200,
+			RejectedRecords: 3,
+			RejectsByReason: map[string]int{"validation": 1, "unknown_user": 2},
+		}
+		expectedBody := reportBody + "\nTotal Records Rejected: 3\n  unknown_user: 2\n  validation: 1\n" +
+			"Rejected records can be downloaded from /migrations/7/rejects"
+
+		message, err := reportService.GenerateReport(ctx, summary, to)
+
+		assert.Nil(t, err)
+		assert.Equal(t, "Migration Report of migration 7", message.Description)
+		assert.Equal(t, email.Message{To: to, Subject: "Migration Report", Text: expectedBody, HTML: reportHTML},
+			decodeReport(t, message))
+	})
+
+	t.Run("it adds the created users to the report", func(t *testing.T) {
+		reportService := services.NewMigrationReportService(log, newReportRenderer(),
+			mocks.NewTransactionRepositoryMock())
+
+		summary := report.MigrationSummary{TotalRecords: 5000, UsersUpdated: 200, UsersCreated: 12}
+
+		message, err := reportService.GenerateReport(ctx, summary, to)
+
+		assert.Nil(t, err)
+		assert.Equal(t, reportBody+"\nTotal Users Created: 12", decodeReport(t, message).Text)
+	})
+
+	t.Run("it adds the processing time to the report", func(t *testing.T) {
+		reportService := services.NewMigrationReportService(log, newReportRenderer(),
+			mocks.NewTransactionRepositoryMock())
+
+		summary := report.MigrationSummary{TotalRecords: 5000, UsersUpdated: 200,
+			ProcessingTime: 83*time.Second + 250400*time.Microsecond}
+
+		message, err := reportService.GenerateReport(ctx, summary, to)
+
+		assert.Nil(t, err)
+		assert.Equal(t, reportBody+"\nProcessing Time: 1m23.25s", decodeReport(t, message).Text)
+	})
+
+	t.Run("it adds the totals of the job and attaches the balance of every user", func(t *testing.T) {
+		transactionRepository := mocks.NewTransactionRepositoryMock()
+		dateTime := time.Date(2024, 9, 13, 10, 0, 0, 0, time.UTC)
+		transactionRepository.On("SummarizeMigration", ctx, "7", 5).Return(transaction.MigrationTotals{
+			Credited: 1500, Debited: 300.5,
+			Largest: []transaction.Transaction{{ID: "3", UserID: "2", Amount: 1000, DateTime: &dateTime},
+				{ID: "4", UserID: "1", Amount: -300.5, DateTime: &dateTime}},
+		}, nil)
+		transactionRepository.On("ForEachMigrationUser", ctx, "7", mock.Anything).Return([]transaction.UserDelta{
+			{UserID: "1", NetAmount: 199.5, Credits: 1, Debits: 1, Balance: 249.5, TotalDebits: 1, TotalCredits: 2},
+			{UserID: "2", NetAmount: 1000, Credits: 1, Balance: 1000, TotalCredits: 1},
+		}, nil)
+		reportService := services.NewMigrationReportService(log, newReportRenderer(), transactionRepository)
+
+		summary := report.MigrationSummary{JobID: "7", TotalRecords: 5000, UsersUpdated: 200}
+		expectedBody := reportBody + "\nTotal Credited: 1500.00\nTotal Debited: 300.50\nNet Amount: 1199.50\n" +
+			"Largest Transactions:\n  3: 1000.00 to user 2 on 2024-09-13T10:00:00Z\n" +
+			"  4: -300.50 to user 1 on 2024-09-13T10:00:00Z\n" +
+			"Largest User Deltas:\n  user 2: +1000.00 (1 credits, 0 debits), balance 1000.00\n" +
+			"  user 1: +199.50 (1 credits, 1 debits), balance 249.50\n" +
+			"The balance of every user is in the attached migration-7-balances.csv"
+		expectedAttachments := []email.Attachment{{FileName: "migration-7-balances.csv", ContentType: "text/csv",
+			Content: []byte("user_id,balance,total_debts,total_credits\n1,249.5,1,2\n2,1000.0,0,1\n")}}
+
+		message, err := reportService.GenerateReport(ctx, summary, to)
+
+		assert.Nil(t, err)
+		assert.Equal(t, email.Message{To: to, Subject: "Migration Report", Text: expectedBody, HTML: reportHTML,
+			Attachments: expectedAttachments}, decodeReport(t, message))
+	})
+
+	t.Run("it keeps only the largest user deltas in the report", func(t *testing.T) {
+		transactionRepository := mocks.NewTransactionRepositoryMock()
+		transactionRepository.On("SummarizeMigration", ctx, "7", 5).Return(transaction.MigrationTotals{}, nil)
+		deltas := make([]transaction.UserDelta, 0, 12)
+		for userID := 1; userID <= 12; userID++ {
+			deltas = append(deltas, transaction.UserDelta{UserID: strconv.Itoa(userID), NetAmount: float64(userID % 6)})
 		}
-		to := []string{"recipient@example.com"}
-		expectedError := errors.New("failed to send email")
+		transactionRepository.On("ForEachMigrationUser", ctx, "7", mock.Anything).Return(deltas, nil)
+		reportService := services.NewMigrationReportService(log, newReportRenderer(), transactionRepository)
 
-		emailServiceMock.On("SendEmail", to, "Migration Report", reportBody).
-			Return(expectedError)
+		message, err := reportService.GenerateReport(ctx, report.MigrationSummary{JobID: "7"}, nil)
 
-		err := reportService.GenerateAndSendReport(summary, to)
+		assert.Nil(t, err)
+		body := decodeReport(t, message).Text
+		assert.Contains(t, body, "Largest User Deltas:\n  user 5: +5.00 (0 credits, 0 debits), balance 0.00\n"+
+			"  user 11: +5.00 (0 credits, 0 debits), balance 0.00\n  user 4: +4.00")
+		assert.Equal(t, 10, strings.Count(body, "  user "))
+		assert.NotContains(t, body, "user 6:")
+		assert.NotContains(t, body, "user 12:")
+	})
+
+	t.Run("it sends the plain text report when the template fails", func(t *testing.T) {
+		renderer := mocks.NewEmailRendererMock()
+		renderer.On("Render", services.MigrationReportTemplate, mock.Anything).
+			Return("", errors.New("template: migration_report.html: no such template"))
+		reportService := services.NewMigrationReportService(log, renderer, mocks.NewTransactionRepositoryMock())
 
-		assert.Error(t, err)
-		assert.Contains(t, err.Error(), "could not send report email")
-		emailServiceMock.AssertCalled(t, "SendEmail", to, "Migration Report", reportBody)
+		message, err := reportService.GenerateReport(ctx, report.MigrationSummary{TotalRecords: 5000,
+			UsersUpdated: 200}, to)
+
+		assert.Nil(t, err)
+		assert.Equal(t, email.Message{To: to, Subject: "Migration Report", Text: reportBody}, decodeReport(t, message))
 	})
+
+	t.Run("it renders the HTML report from the templates", func(t *testing.T) {
+		renderer, err := email.NewTemplateRenderer("../../../templates/email")
+		assert.Nil(t, err)
+		transactionRepository := mocks.NewTransactionRepositoryMock()
+		transactionRepository.On("SummarizeMigration", ctx, "7", 5).Return(transaction.MigrationTotals{
+			Credited: 1500, Debited: 300.5,
+			Largest: []transaction.Transaction{{ID: "<3>", UserID: "2", Amount: 1000}},
+		}, nil)
+		transactionRepository.On("ForEachMigrationUser", ctx, "7", mock.Anything).Return([]transaction.UserDelta{
+			{UserID: "2", NetAmount: 1000, Credits: 1, Balance: 1000, TotalCredits: 1},
+		}, nil)
+		reportService := services.NewMigrationReportService(log, renderer, transactionRepository)
+
+		summary := report.MigrationSummary{JobID: "7", TotalRecords: 5000, UsersUpdated: 200, RejectedRecords: 1,
+			RejectsByReason: map[string]int{"validation": 1}, ProcessingTime: 2 * time.Second}
+		message, err := reportService.GenerateReport(ctx, summary, nil)
+
+		assert.Nil(t, err)
+		html := decodeReport(t, message).HTML
+		assert.Contains(t, html, "<title>Migration Report</title>")
+		assert.Contains(t, html, "<td>Processing Time</td><td><strong>2s</strong></td>")
+		assert.Contains(t, html, "<tr><td>validation</td><td>1</td></tr>")
+		assert.Contains(t, html, "<strong>1199.50</strong>")
+		assert.Contains(t, html, "<td>&lt;3&gt;</td>")
+		assert.Contains(t, html, "<td align=\"right\">&#43;1000.00</td>")
+		assert.Contains(t, html, "<code>migration-7-balances.csv</code>")
+	})
+}
+
+func newReportRenderer() *mocks.EmailRendererMock {
+	renderer := mocks.NewEmailRendererMock()
+	renderer.On("Render", services.MigrationReportTemplate, mock.Anything).Return("<p>Migration Report</p>", nil)
+	return renderer
+}
+
+func decodeReport(t *testing.T, message outbox.Message) email.Message {
+	var reportMessage email.Message
+	assert.Nil(t, json.Unmarshal(message.Payload, &reportMessage))
+	return reportMessage
 }
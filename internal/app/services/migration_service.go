@@ -4,16 +4,20 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"io"
 	"mime/multipart"
+	"sort"
 	"strings"
 	"sync"
 
+	"github.com/sebastianreh/user-balance-api/internal/domain/migration"
 	"github.com/sebastianreh/user-balance-api/internal/domain/report"
 	"github.com/sebastianreh/user-balance-api/internal/domain/transaction"
 	"github.com/sebastianreh/user-balance-api/internal/domain/user"
 	"github.com/sebastianreh/user-balance-api/internal/infrastructure/config"
-	"github.com/sebastianreh/user-balance-api/pkg/csv"
+	"github.com/sebastianreh/user-balance-api/pkg/decompress"
 	"github.com/sebastianreh/user-balance-api/pkg/logger"
+	"github.com/sebastianreh/user-balance-api/pkg/records"
 )
 
 const (
@@ -23,6 +27,9 @@ const (
 
 type MigrationService interface {
 	ProcessBalance(ctx context.Context, file *multipart.FileHeader) (report.MigrationSummary, error)
+	ProcessBalanceWithOptions(ctx context.Context, open records.Opener, options migration.Options,
+		hooks migration.Hooks) (report.MigrationSummary, error)
+	ValidateBalance(ctx context.Context, open records.Opener, options migration.Options) (migration.ValidationReport, error)
 }
 
 type migrationService struct {
@@ -30,127 +37,734 @@ type migrationService struct {
 	log                   logger.Logger
 	userRepository        user.Repository
 	transactionRepository transaction.Repository
-	csvProcessor          csv.CsvProcessor
+	sources               records.Sources
+	alertService          AlertService
 }
 
 func NewMigrationService(cfg config.Config, log logger.Logger, userRepository user.Repository,
-	transactionRepository transaction.Repository, csvProcessor csv.CsvProcessor) MigrationService {
+	transactionRepository transaction.Repository, sources records.Sources, alertService AlertService) MigrationService {
 	return &migrationService{
 		config:                cfg,
 		log:                   log,
 		userRepository:        userRepository,
 		transactionRepository: transactionRepository,
-		csvProcessor:          csvProcessor,
+		sources:               sources,
+		alertService:          alertService,
 	}
 }
 
 func (s *migrationService) ProcessBalance(ctx context.Context, file *multipart.FileHeader) (report.MigrationSummary, error) {
+	return s.process(ctx, func() (io.ReadCloser, error) {
+		return file.Open()
+	}, migration.Options{Mode: migration.ModeDefault, Format: records.FormatCSV}, migration.Hooks{})
+}
+
+// ProcessBalanceWithOptions works like ProcessBalance with the mode, format and profile of the options, reporting the
+// progress after the file is read and after every saved batch. In partial mode the rejected records are handed to
+// hooks.OnRejects
+func (s *migrationService) ProcessBalanceWithOptions(ctx context.Context, open records.Opener, options migration.Options,
+	hooks migration.Hooks) (report.MigrationSummary, error) {
+	return s.process(ctx, open, options, hooks)
+}
+
+// process reads the file twice without holding it in memory, the first pass validates every record so an invalid
+// file writes nothing and the second one streams the batches to the workers through a bounded channel. In partial
+// mode the first pass only counts the records, the invalid ones are rejected while streaming. In strict mode the
+// batches are staged and committed together once all of them succeed, so users can't be created along the way
+func (s *migrationService) process(ctx context.Context, open records.Opener, options migration.Options,
+	hooks migration.Hooks) (report.MigrationSummary, error) {
 	var migrationSummary report.MigrationSummary
-	records, err := s.csvProcessor.ReadFile(file, recordValidator)
+	batchSize := s.config.Workers.MigrationWorkerBatchSize
+	progress := newMigrationProgress(hooks.OnProgress)
+	mode := options.Mode
+	mapping := columnMapping(options.Profile)
+
+	if mode == migration.ModeStrict && options.CreateMissingUsers {
+		return migrationSummary, errors.New(migration.StrictMissingUsersError)
+	}
+
+	source, err := s.sources.Get(fileFormat(options))
+	if err != nil {
+		return migrationSummary, fmt.Errorf("%s: %w", ReadFileError, err)
+	}
+	open = s.decompressed(open)
+
+	totalRecords, err := readFile(source, open, mode, mapping, recordValidatorFor(options))
 	if err != nil {
 		s.log.ErrorAt(fmt.Errorf("error reading file: %s", err.Error()), migrationServiceName, "ProcessBalance")
-		return migrationSummary, errors.New(ReadFileError)
+		return migrationSummary, fmt.Errorf("%s: %w", ReadFileError, err)
 	}
 
-	batches := createBatches(records, s.config.Workers.MigrationWorkerBatchSize)
+	progress.validated(totalRecords, countBatches(totalRecords, batchSize))
+
+	var stageID string
+	if mode == migration.ModeStrict {
+		stageID, err = s.transactionRepository.CreateStage(ctx)
+		if err != nil {
+			s.log.ErrorAt(fmt.Errorf("error creating stage: %s", err.Error()), migrationServiceName, "ProcessBalance")
+			return migrationSummary, err
+		}
+	}
 
-	errChan := make(chan error, len(batches))
-	processedChan := make(chan map[string][]transaction.Transaction, len(batches))
+	src, err := open()
+	if err != nil {
+		s.log.ErrorAt(fmt.Errorf("error reading file: %s", err.Error()), migrationServiceName, "ProcessBalance")
+		s.discardStage(ctx, stageID)
+		return migrationSummary, fmt.Errorf("%s: %w", ReadFileError, err)
+	}
+	defer src.Close()
+
+	workers := max(s.config.Workers.MigrationWorkersSize, 1)
+	batches := make(chan records.Batch, workers)
+	results := make(chan batchResult, workers)
+	streamErr := make(chan error, 1)
 	var wg sync.WaitGroup
 
-	for _, batch := range batches {
+	for i := 0; i < workers; i++ {
 		wg.Add(1)
-		go func(batch [][]string) {
+		go func() {
 			defer wg.Done()
-			s.processBatch(ctx, batch, processedChan, errChan)
-		}(batch)
+			for batch := range batches {
+				if mode == migration.ModePartial {
+					results <- s.processPartialBatch(ctx, batch, options, hooks, progress)
+					continue
+				}
+
+				results <- s.processBatch(ctx, batch, options, stageID, progress)
+			}
+		}()
 	}
 
+	go func() {
+		streamErr <- source.StreamBatches(ctx, src, mapping, batchSize, batches)
+	}()
+
 	go func() {
 		wg.Wait()
-		close(processedChan)
-		close(errChan)
+		close(results)
 	}()
 
-	if err = collectErrors(errChan); err != nil {
-		s.log.ErrorAt(fmt.Errorf("error during batch processing: %s", err.Error()), migrationServiceName, "ProcessBalance")
-		return migrationSummary, err
+	migrationSummary, err = s.collectResults(ctx, results)
+	if streamError := <-streamErr; streamError != nil && err == nil {
+		err = fmt.Errorf("%s: %w", ReadFileError, streamError)
 	}
 
-	migrationSummary = generateReport(processedChan)
+	if err == nil && stageID != "" {
+		if err = s.commitStage(ctx, stageID, progress); err == nil {
+			s.checkStageAlerts(ctx, options.MigrationID)
+		}
+	}
+
+	if err != nil {
+		s.log.ErrorAt(fmt.Errorf("error during batch processing: %s", err.Error()), migrationServiceName, "ProcessBalance")
+		s.discardStage(ctx, stageID)
+		return report.MigrationSummary{}, err
+	}
 
 	return migrationSummary, nil
 }
 
-func (s *migrationService) processBatch(ctx context.Context, batch [][]string,
-	processedChan chan<- map[string][]transaction.Transaction, errChan chan<- error) {
-	var transactions []transaction.Transaction
-	processedTransactions := make(map[string][]transaction.Transaction)
+func (s *migrationService) commitStage(ctx context.Context, stageID string, progress *migrationProgress) error {
+	committed, err := s.transactionRepository.CommitStage(ctx, stageID)
+	if err != nil {
+		return fmt.Errorf("error committing transaction batches: %w", err)
+	}
+
+	progress.committed(int(committed))
+
+	return nil
+}
+
+// checkAlerts fires the alert rules of the transactions of a committed batch. Alerts never fail a migration, the
+// alert service logs its errors
+func (s *migrationService) checkAlerts(ctx context.Context, saved []transaction.Transaction) {
+	if len(saved) == 0 {
+		return
+	}
 
-	for _, record := range batch {
-		userTransaction, err := transaction.CreateTransactionByRecord(record)
+	_ = s.alertService.Check(ctx, nil, saved)
+}
+
+// checkStageAlerts fires the alert rules of a committed stage, its transactions are read back page by page from the
+// ledger so the staged batches don't have to be kept in memory
+func (s *migrationService) checkStageAlerts(ctx context.Context, migrationID string) {
+	if migrationID == "" {
+		return
+	}
+
+	var afterID string
+	for {
+		items, err := s.transactionRepository.ListByMigration(ctx, migrationID, afterID, migration.MaxListLimit)
 		if err != nil {
-			errChan <- fmt.Errorf("error creating transaction by record: %w", err)
+			s.log.ErrorAt(fmt.Errorf("error reading committed transactions: %w", err), migrationServiceName,
+				"checkStageAlerts")
 			return
 		}
+
+		saved := make([]transaction.Transaction, 0, len(items))
+		for _, item := range items {
+			saved = append(saved, item.Transaction)
+		}
+		s.checkAlerts(ctx, saved)
+
+		if len(items) < migration.MaxListLimit {
+			return
+		}
+		afterID = items[len(items)-1].ID
+	}
+}
+
+// discardStage drops the staged batches of a failed strict migration, the ledger is untouched either way
+func (s *migrationService) discardStage(ctx context.Context, stageID string) {
+	if stageID == "" {
+		return
+	}
+
+	if err := s.transactionRepository.DiscardStage(ctx, stageID); err != nil {
+		s.log.ErrorAt(fmt.Errorf("error discarding stage %s: %w", stageID, err), migrationServiceName, "discardStage")
+	}
+}
+
+// ValidateBalance runs every check of the import without writing anything. The batches are checked one at a time,
+// only the ids already seen are kept to find the ones repeated in the file
+func (s *migrationService) ValidateBalance(ctx context.Context, open records.Opener,
+	options migration.Options) (migration.ValidationReport, error) {
+	source, err := s.sources.Get(fileFormat(options))
+	if err != nil {
+		return migration.ValidationReport{}, fmt.Errorf("%s: %w", ReadFileError, err)
+	}
+	open = s.decompressed(open)
+
+	src, err := open()
+	if err != nil {
+		s.log.ErrorAt(fmt.Errorf("error reading file: %s", err.Error()), migrationServiceName, "ValidateBalance")
+		return migration.ValidationReport{}, fmt.Errorf("%s: %w", ReadFileError, err)
+	}
+	defer src.Close()
+
+	batches := make(chan records.Batch, 1)
+	streamErr := make(chan error, 1)
+	go func() {
+		streamErr <- source.StreamBatches(ctx, src, columnMapping(options.Profile),
+			s.config.Workers.MigrationWorkerBatchSize, batches)
+	}()
+
+	validation := newFileValidation()
+	for batch := range batches {
+		// The channel is drained after a failure so the stream can finish
+		if err == nil {
+			err = s.validateBatch(ctx, batch, options, validation)
+		}
+	}
+
+	if streamError := <-streamErr; streamError != nil && err == nil {
+		err = fmt.Errorf("%s: %w", ReadFileError, streamError)
+	}
+
+	if err != nil {
+		s.log.ErrorAt(fmt.Errorf("error validating file: %s", err.Error()), migrationServiceName, "ValidateBalance")
+		return migration.ValidationReport{}, err
+	}
+
+	return validation.report(), nil
+}
+
+// validateBatch rejects the records the same way a partial migration would, checking the users and the saved
+// transactions with read only queries. With create_missing_users the unknown users are counted as created instead
+func (s *migrationService) validateBatch(ctx context.Context, batch records.Batch, options migration.Options,
+	validation *fileValidation) error {
+	validator := recordValidatorFor(options)
+	transactions := make([]transaction.Transaction, 0, len(batch))
+	parsed := make([]records.Record, 0, len(batch))
+	for _, record := range batch {
+		userTransaction, reason, err := parseRecord(record, validator)
+		if line, seen := validation.lines[userTransaction.ID]; err == nil && seen {
+			reason = migration.RejectReasonDuplicateID
+			err = fmt.Errorf("%s with line %d", transaction.DuplicateTransactionError, line)
+		}
+
+		if err != nil {
+			validation.reject(newReject(record, reason, err.Error()))
+			continue
+		}
+
+		validation.lines[userTransaction.ID] = record.Line
 		transactions = append(transactions, userTransaction)
-		processedTransactions[userTransaction.UserID] = append(processedTransactions[userTransaction.UserID], userTransaction)
+		parsed = append(parsed, record)
 	}
 
-	err := s.transactionRepository.SaveBatch(ctx, transactions)
+	if len(transactions) == 0 {
+		return nil
+	}
+
+	ids := make([]string, 0, len(transactions))
+	userIDs := make([]string, 0, len(transactions))
+	for _, userTransaction := range transactions {
+		ids = append(ids, userTransaction.ID)
+		userIDs = append(userIDs, userTransaction.UserID)
+	}
+
+	activeUsers, err := s.userRepository.FindActiveIDs(ctx, userIDs)
+	if err != nil {
+		return fmt.Errorf("error finding users: %w", err)
+	}
+
+	existingIDs, err := s.transactionRepository.FindExistingIDs(ctx, ids)
+	if err != nil {
+		return fmt.Errorf("error finding transactions: %w", err)
+	}
+
+	for i, userTransaction := range transactions {
+		switch {
+		case !activeUsers[userTransaction.UserID] && options.CreateMissingUsers:
+			validation.create(userTransaction.UserID)
+			validation.accept(userTransaction.UserID)
+		case !activeUsers[userTransaction.UserID]:
+			validation.reject(newReject(parsed[i], migration.RejectReasonUnknownUser, user.NotFoundError))
+		case existingIDs[userTransaction.ID]:
+			validation.reject(newReject(parsed[i], migration.RejectReasonDuplicateID,
+				transaction.DuplicateTransactionError))
+		default:
+			validation.accept(userTransaction.UserID)
+		}
+	}
+
+	return nil
+}
+
+func readFile(source records.Source, open records.Opener, mode string, mapping *records.Mapping,
+	validator func(record []string) error) (int, error) {
+	src, err := open()
 	if err != nil {
-		errChan <- fmt.Errorf("error saving transaction batch: %w", err)
+		return 0, err
+	}
+	defer src.Close()
+
+	if mode == migration.ModePartial {
+		return source.Count(src)
 	}
-	processedChan <- processedTransactions
 
-	errChan <- nil
+	return source.Validate(src, mapping, validator)
+}
+
+// decompressed reads gzip, zip and zstd files decompressed, within the limits of the config
+func (s *migrationService) decompressed(open records.Opener) records.Opener {
+	return decompress.Opener(open, decompress.Limits{
+		MaxSize:    s.config.Workers.MigrationMaxDecompressedSize,
+		MaxEntries: s.config.Workers.MigrationMaxArchiveEntries,
+	})
 }
 
-func createBatches(records [][]string, batchSize int) [][][]string {
-	var batches [][][]string
-	for batchSize < len(records) {
-		records, batches = records[batchSize:], append(batches, records[0:batchSize:batchSize])
+func fileFormat(options migration.Options) string {
+	if options.Format == "" {
+		return records.FormatCSV
 	}
 
-	batches = append(batches, records)
-	return batches
+	return options.Format
 }
 
-func collectErrors(errChan <-chan error) error {
-	var errorMessages []string
-	for err := range errChan {
+// columnMapping sorts the columns named in the profile in the order of the record validator, the user fields go
+// after them and are empty when the file doesn't have them
+func columnMapping(profile *migration.MappingProfile) *records.Mapping {
+	if profile == nil {
+		return nil
+	}
+
+	defaults := make(map[string]string, len(profile.Defaults)+len(migration.UserFields()))
+	for _, field := range migration.UserFields() {
+		defaults[field] = ""
+	}
+	for field, value := range profile.Defaults {
+		defaults[field] = value
+	}
+
+	return &records.Mapping{
+		Fields:   append(migration.RecordFields(), migration.UserFields()...),
+		Columns:  profile.Columns,
+		Ignored:  profile.IgnoredColumns,
+		Defaults: defaults,
+		Strict:   profile.Strict,
+	}
+}
+
+type batchResult struct {
+	userRecords  map[string]int
+	usersCreated int
+	rejected     map[string]int
+	// saved are the transactions the batch wrote to the ledger, staged batches are written when the stage commits
+	saved []transaction.Transaction
+	err   error
+}
+
+// processBatch saves the batch in its own database transaction, or adds it to the stage when there is one
+func (s *migrationService) processBatch(ctx context.Context, batch records.Batch, options migration.Options,
+	stageID string, progress *migrationProgress) batchResult {
+	transactions := make([]transaction.Transaction, 0, len(batch))
+	userRecords := make(map[string]int)
+
+	for _, record := range batch {
+		userTransaction, err := transaction.CreateTransactionByRecord(record.Fields)
 		if err != nil {
-			errorMessages = append(errorMessages, err.Error())
+			return batchResult{err: fmt.Errorf("error creating transaction by record: %w", err)}
 		}
+		userTransaction.MigrationID = options.MigrationID
+		transactions = append(transactions, userTransaction)
+		userRecords[userTransaction.UserID]++
 	}
 
-	errorSet := make(map[string]bool)
-	var uniqueErrors []string
+	if stageID != "" {
+		if err := s.transactionRepository.StageBatch(ctx, stageID, transactions); err != nil {
+			return batchResult{err: fmt.Errorf("error staging transaction batch: %w", err)}
+		}
+
+		// Staged rows are counted as inserted once the stage is committed
+		progress.batchDone(0)
+		return batchResult{userRecords: userRecords}
+	}
 
-	for _, errMsg := range errorMessages {
-		if _, exists := errorSet[errMsg]; !exists {
-			errorSet[errMsg] = true
-			uniqueErrors = append(uniqueErrors, errMsg)
+	var usersCreated int
+	if options.CreateMissingUsers {
+		var emailTaken map[string]bool
+		var err error
+		if usersCreated, emailTaken, err = s.createMissingUsers(ctx, batch); err != nil {
+			return batchResult{err: err}
+		}
+
+		for _, userTransaction := range transactions {
+			if emailTaken[userTransaction.UserID] {
+				return batchResult{err: fmt.Errorf("error creating missing users: user %s: %s", userTransaction.UserID,
+					user.DuplicateEmailError)}
+			}
 		}
 	}
 
-	if len(uniqueErrors) > 0 {
-		return errors.New(strings.Join(uniqueErrors, ", "))
+	err := s.transactionRepository.SaveBatch(ctx, transactions)
+	if err != nil {
+		return batchResult{err: fmt.Errorf("error saving transaction batch: %w", err)}
 	}
 
-	return nil
+	progress.batchDone(len(transactions))
+
+	return batchResult{userRecords: userRecords, usersCreated: usersCreated, saved: transactions}
+}
+
+// processPartialBatch saves the valid records of the batch, the rejected ones are stored with their line and reason
+func (s *migrationService) processPartialBatch(ctx context.Context, batch records.Batch, options migration.Options,
+	hooks migration.Hooks, progress *migrationProgress) batchResult {
+	var rejects []migration.Reject
+	validator := recordValidatorFor(options)
+	transactions := make([]transaction.Transaction, 0, len(batch))
+	parsed := make([]records.Record, 0, len(batch))
+	batchIDs := make(map[string]bool, len(batch))
+
+	for _, record := range batch {
+		userTransaction, reason, err := parseRecord(record, validator)
+		if err == nil && batchIDs[userTransaction.ID] {
+			reason, err = migration.RejectReasonDuplicateID, errors.New(transaction.DuplicateTransactionError)
+		}
+
+		if err != nil {
+			rejects = append(rejects, newReject(record, reason, err.Error()))
+			continue
+		}
+
+		batchIDs[userTransaction.ID] = true
+		userTransaction.MigrationID = options.MigrationID
+		transactions = append(transactions, userTransaction)
+		parsed = append(parsed, record)
+	}
+
+	var rejections []transaction.Rejection
+	var usersCreated int
+	if len(transactions) > 0 && options.CreateMissingUsers {
+		var emailTaken map[string]bool
+		var err error
+		if usersCreated, emailTaken, err = s.createMissingUsers(ctx, parsed); err != nil {
+			return batchResult{err: err}
+		}
+
+		transactions, parsed, rejects = rejectTakenEmails(transactions, parsed, rejects, emailTaken)
+	}
+
+	if len(transactions) > 0 {
+		var err error
+		rejections, err = s.transactionRepository.SaveBatchSkippingRejected(ctx, transactions)
+		if err != nil {
+			return batchResult{err: fmt.Errorf("error saving transaction batch: %w", err)}
+		}
+	}
+
+	rejectedIndexes := make(map[int]bool, len(rejections))
+	for _, rejection := range rejections {
+		rejectedIndexes[rejection.Index] = true
+		rejects = append(rejects, newReject(parsed[rejection.Index], rejectReason(rejection.Reason), rejection.Reason))
+	}
+
+	userRecords := make(map[string]int)
+	saved := make([]transaction.Transaction, 0, len(transactions))
+	for i, userTransaction := range transactions {
+		if !rejectedIndexes[i] {
+			userRecords[userTransaction.UserID]++
+			saved = append(saved, userTransaction)
+		}
+	}
+
+	result := batchResult{userRecords: userRecords, usersCreated: usersCreated, saved: saved}
+	if len(rejects) > 0 {
+		if err := hooks.OnRejects(rejects); err != nil {
+			return batchResult{err: fmt.Errorf("error saving rejected records: %w", err)}
+		}
+
+		result.rejected = make(map[string]int)
+		for _, reject := range rejects {
+			result.rejected[reject.Reason]++
+		}
+	}
+
+	progress.batchDone(len(transactions) - len(rejections))
+
+	return result
+}
+
+// createMissingUsers creates the users of the records that don't exist yet, a user takes the attributes of its first
+// record in the batch. The users whose email belongs to another user are returned in emailTaken
+func (s *migrationService) createMissingUsers(ctx context.Context, batch []records.Record) (int, map[string]bool,
+	error) {
+	users := make([]user.User, 0, len(batch))
+	seen := make(map[string]bool, len(batch))
+	for _, record := range batch {
+		userEntity := user.CreateUserByRecord(record.Fields)
+		if !seen[userEntity.ID] {
+			seen[userEntity.ID] = true
+			users = append(users, userEntity)
+		}
+	}
+
+	created, emailTaken, err := s.userRepository.CreateMissing(ctx, users)
+	if err != nil {
+		return 0, nil, fmt.Errorf("error creating missing users: %w", err)
+	}
+
+	return created, emailTaken, nil
 }
 
-func generateReport(processedChan <-chan map[string][]transaction.Transaction) report.MigrationSummary {
+// rejectTakenEmails leaves out the records of the users that were not created because their email belongs to another
+// user, they are rejected instead of failing as unknown users
+func rejectTakenEmails(transactions []transaction.Transaction, parsed []records.Record, rejects []migration.Reject,
+	emailTaken map[string]bool) ([]transaction.Transaction, []records.Record, []migration.Reject) {
+	if len(emailTaken) == 0 {
+		return transactions, parsed, rejects
+	}
+
+	kept := make([]transaction.Transaction, 0, len(transactions))
+	keptRecords := make([]records.Record, 0, len(parsed))
+	for i, userTransaction := range transactions {
+		if emailTaken[userTransaction.UserID] {
+			rejects = append(rejects, newReject(parsed[i], migration.RejectReasonDuplicateEmail, user.DuplicateEmailError))
+			continue
+		}
+
+		kept = append(kept, userTransaction)
+		keptRecords = append(keptRecords, parsed[i])
+	}
+
+	return kept, keptRecords, rejects
+}
+
+func parseRecord(record records.Record, validator func(record []string) error) (transaction.Transaction, string,
+	error) {
+	if record.Err != nil {
+		return transaction.Transaction{}, migration.RejectReasonValidation, record.Err
+	}
+
+	if err := validator(record.Fields); err != nil {
+		return transaction.Transaction{}, migration.RejectReasonValidation, err
+	}
+
+	userTransaction, err := transaction.CreateTransactionByRecord(record.Fields)
+	if err != nil {
+		return userTransaction, migration.RejectReasonValidation, err
+	}
+
+	if userTransaction.Amount == 0 {
+		return userTransaction, migration.RejectReasonZeroAmount, errors.New(transaction.ZeroAmountError)
+	}
+
+	return userTransaction, "", nil
+}
+
+func newReject(record records.Record, reason, detail string) migration.Reject {
+	return migration.Reject{Line: record.Line, Raw: record.Raw, Reason: reason, Detail: detail}
+}
+
+func rejectReason(repositoryReason string) string {
+	switch repositoryReason {
+	case user.NotFoundError:
+		return migration.RejectReasonUnknownUser
+	case transaction.DuplicateTransactionError:
+		return migration.RejectReasonDuplicateID
+	case transaction.ZeroAmountError:
+		return migration.RejectReasonZeroAmount
+	default:
+		return migration.RejectReasonValidation
+	}
+}
+
+// fileValidation accumulates the outcome of a dry run, lines keeps where every valid id was first seen
+type fileValidation struct {
+	lines    map[string]int
+	users    map[string]bool
+	created  map[string]bool
+	problems []migration.Reject
+	summary  report.MigrationSummary
+}
+
+func newFileValidation() *fileValidation {
+	return &fileValidation{
+		lines:   make(map[string]int),
+		users:   make(map[string]bool),
+		created: make(map[string]bool),
+	}
+}
+
+func (v *fileValidation) create(userID string) {
+	if !v.created[userID] {
+		v.created[userID] = true
+		v.summary.UsersCreated++
+	}
+}
+
+func (v *fileValidation) accept(userID string) {
+	v.summary.TotalRecords++
+	if !v.users[userID] {
+		v.users[userID] = true
+		v.summary.UsersUpdated++
+	}
+}
+
+func (v *fileValidation) reject(problem migration.Reject) {
+	if v.summary.RejectsByReason == nil {
+		v.summary.RejectsByReason = make(map[string]int)
+	}
+	v.summary.RejectsByReason[problem.Reason]++
+	v.summary.RejectedRecords++
+	v.problems = append(v.problems, problem)
+}
+
+func (v *fileValidation) report() migration.ValidationReport {
+	validationReport := migration.ValidationReport{
+		Summary:  v.summary,
+		Problems: v.problems,
+	}
+
+	if total := v.summary.TotalRecords + v.summary.RejectedRecords; total < records.MinRecords {
+		validationReport.FileErrors = append(validationReport.FileErrors,
+			fmt.Sprintf("the file must have at least %d records", records.MinRecords))
+	}
+
+	// The users and transactions are checked after the rest of the batch, so the problems are sorted back by line
+	sort.SliceStable(validationReport.Problems, func(i, j int) bool {
+		return validationReport.Problems[i].Line < validationReport.Problems[j].Line
+	})
+
+	if validationReport.Problems == nil {
+		validationReport.Problems = []migration.Reject{}
+	}
+	validationReport.Valid = len(validationReport.Problems) == 0 && len(validationReport.FileErrors) == 0
+
+	return validationReport
+}
+
+// migrationProgress accumulates the progress of the concurrent batches, a nil value tracks nothing
+type migrationProgress struct {
+	mu       sync.Mutex
+	progress migration.Progress
+	onUpdate func(progress migration.Progress)
+}
+
+func newMigrationProgress(onUpdate func(progress migration.Progress)) *migrationProgress {
+	if onUpdate == nil {
+		return nil
+	}
+
+	return &migrationProgress{onUpdate: onUpdate}
+}
+
+func (p *migrationProgress) validated(rows, batches int) {
+	if p == nil {
+		return
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.progress.RowsValidated = rows
+	p.progress.BatchesTotal = batches
+	p.onUpdate(p.progress)
+}
+
+func (p *migrationProgress) batchDone(rowsInserted int) {
+	if p == nil {
+		return
+	}
+
+	// The update runs under the lock so a slower caller never overwrites a newer snapshot
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.progress.RowsInserted += rowsInserted
+	p.progress.BatchesDone++
+	p.onUpdate(p.progress)
+}
+
+func (p *migrationProgress) committed(rowsInserted int) {
+	if p == nil {
+		return
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.progress.RowsInserted = rowsInserted
+	p.onUpdate(p.progress)
+}
+
+func countBatches(records, batchSize int) int {
+	if batchSize < 1 {
+		return 0
+	}
+
+	return (records + batchSize - 1) / batchSize
+}
+
+// collectResults builds the summary from the saved batches and joins the unique errors of the failed ones. The alert
+// rules of each saved batch are checked as it arrives, saved batches stay saved when another one fails
+func (s *migrationService) collectResults(ctx context.Context,
+	results <-chan batchResult) (report.MigrationSummary, error) {
 	var summary report.MigrationSummary
+	var uniqueErrors []string
+	errorSet := make(map[string]bool)
 
 	// Created a map to track unique users since it's using a batch processor
 	uniqueUsers := make(map[string]bool)
-	for reportMap := range processedChan {
-		for userID, userTransactions := range reportMap {
-			summary.TotalRecords += len(userTransactions)
+	for result := range results {
+		if result.err != nil {
+			if errMsg := result.err.Error(); !errorSet[errMsg] {
+				errorSet[errMsg] = true
+				uniqueErrors = append(uniqueErrors, errMsg)
+			}
+			continue
+		}
+
+		summary.UsersCreated += result.usersCreated
+		s.checkAlerts(ctx, result.saved)
+		for reason, rejected := range result.rejected {
+			if summary.RejectsByReason == nil {
+				summary.RejectsByReason = make(map[string]int)
+			}
+			summary.RejectsByReason[reason] += rejected
+			summary.RejectedRecords += rejected
+		}
 
-			// Track unique users
+		for userID, records := range result.userRecords {
+			summary.TotalRecords += records
 			if !uniqueUsers[userID] {
 				uniqueUsers[userID] = true
 				summary.UsersUpdated++
@@ -158,5 +772,9 @@ func generateReport(processedChan <-chan map[string][]transaction.Transaction) r
 		}
 	}
 
-	return summary
+	if len(uniqueErrors) > 0 {
+		return summary, errors.New(strings.Join(uniqueErrors, ", "))
+	}
+
+	return summary, nil
 }
@@ -3,11 +3,17 @@ package services_test
 import (
 	"context"
 	"errors"
-	"mime/multipart"
+	"io"
+	"strings"
+	"sync"
 	"testing"
 
+	"github.com/sebastianreh/user-balance-api/internal/domain/migration"
 	"github.com/sebastianreh/user-balance-api/internal/domain/report"
+	"github.com/sebastianreh/user-balance-api/internal/domain/transaction"
+	"github.com/sebastianreh/user-balance-api/internal/domain/user"
 	"github.com/sebastianreh/user-balance-api/internal/infrastructure/config"
+	"github.com/sebastianreh/user-balance-api/pkg/records"
 
 	"github.com/sebastianreh/user-balance-api/internal/app/services"
 	"github.com/sebastianreh/user-balance-api/pkg/logger"
@@ -20,40 +26,45 @@ func Test_MigrationService_ProcessBalance(t *testing.T) {
 	ctx := context.TODO()
 	cfg := config.NewConfig()
 	loggerMock := logger.NewLogger()
-	fileHeader := &multipart.FileHeader{}
+	fileHeader := newFileHeader(t, "test.csv", "id,user_id,amount,datetime")
 
-	t.Run("When ReadFile returns an error", func(t *testing.T) {
-		expectedError := errors.New(services.ReadFileError)
+	t.Run("When Validate returns an error", func(t *testing.T) {
+		expectedError := errors.New(services.ReadFileError + ": line 2: amount field is not a valid float")
 
-		csvProcessor := mocks.NewCsvProcessorMock()
-		csvProcessor.On("ReadFile", fileHeader, mock.Anything).Return([][]string{}, expectedError)
+		csvProcessor := mocks.NewRecordSourceMock()
+		csvProcessor.On("Validate", mock.Anything, mock.Anything, mock.Anything).
+			Return(0, errors.New("line 2: amount field is not a valid float"))
 
 		userRepo := mocks.NewUserRepositoryMock()
 		transactionRepo := mocks.NewTransactionRepositoryMock()
 
-		service := services.NewMigrationService(cfg, loggerMock, userRepo, transactionRepo, csvProcessor)
+		service := services.NewMigrationService(cfg, loggerMock, userRepo, transactionRepo, csvSources(csvProcessor), newAlertService())
 
 		summary, err := service.ProcessBalance(ctx, fileHeader)
 
 		assert.NotNil(t, err)
 		assert.Empty(t, summary)
-		assert.Equal(t, expectedError, err)
+		assert.Equal(t, expectedError.Error(), err.Error())
+		csvProcessor.AssertNotCalled(t, "StreamBatches", mock.Anything, mock.Anything, mock.Anything, mock.Anything,
+			mock.Anything)
 	})
 
 	t.Run("When transaction.CreateTransactionByRecord returns an error", func(t *testing.T) {
-		records := [][]string{{"1", "test_user", "100.00", "2024-09-13"}}
+		batches := []records.Batch{{{Line: 2, Fields: []string{"1", "test_user", "100.00", "2024-09-13"}}}}
 		expectedError := errors.New("error creating transaction by record: parsing time \"2024-09-13\"" +
 			" as \"2006-01-02T15:04:05Z07:00\": cannot parse \"\" as \"T\"")
 
-		csvProcessor := mocks.NewCsvProcessorMock()
-		csvProcessor.On("ReadFile", fileHeader, mock.Anything).Return(records, nil)
+		csvProcessor := mocks.NewRecordSourceMock()
+		csvProcessor.On("Validate", mock.Anything, mock.Anything, mock.Anything).Return(1, nil)
+		csvProcessor.On("StreamBatches", ctx, mock.Anything, mock.Anything, cfg.Workers.MigrationWorkerBatchSize, mock.Anything).
+			Return(batches, nil)
 
 		userRepo := mocks.NewUserRepositoryMock()
 		userRepo.On("Save", mock.Anything, mock.Anything).Return(nil)
 
 		transactionRepo := mocks.NewTransactionRepositoryMock()
 
-		service := services.NewMigrationService(cfg, loggerMock, userRepo, transactionRepo, csvProcessor)
+		service := services.NewMigrationService(cfg, loggerMock, userRepo, transactionRepo, csvSources(csvProcessor), newAlertService())
 
 		summary, err := service.ProcessBalance(ctx, fileHeader)
 
@@ -63,11 +74,12 @@ func Test_MigrationService_ProcessBalance(t *testing.T) {
 	})
 
 	t.Run("When transactionRepository.SaveBatch returns an error", func(t *testing.T) {
-		records := [][]string{{"1", "test_user", "100.00", "2024-09-13T10:00:00Z"}}
+		batches := []records.Batch{{{Line: 2, Fields: []string{"1", "test_user", "100.00", "2024-09-13T10:00:00Z"}}}}
 		expectedError := errors.New("error saving transaction batch: repository error")
 
-		csvProcessor := mocks.NewCsvProcessorMock()
-		csvProcessor.On("ReadFile", fileHeader, mock.Anything).Return(records, nil)
+		csvProcessor := mocks.NewRecordSourceMock()
+		csvProcessor.On("Validate", mock.Anything, mock.Anything, mock.Anything).Return(1, nil)
+		csvProcessor.On("StreamBatches", ctx, mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return(batches, nil)
 
 		userRepo := mocks.NewUserRepositoryMock()
 		userRepo.On("Save", mock.Anything, mock.Anything).Return(nil)
@@ -75,7 +87,7 @@ func Test_MigrationService_ProcessBalance(t *testing.T) {
 		transactionRepo := mocks.NewTransactionRepositoryMock()
 		transactionRepo.On("SaveBatch", mock.Anything, mock.Anything).Return(errors.New("repository error"))
 
-		service := services.NewMigrationService(cfg, loggerMock, userRepo, transactionRepo, csvProcessor)
+		service := services.NewMigrationService(cfg, loggerMock, userRepo, transactionRepo, csvSources(csvProcessor), newAlertService())
 
 		summary, err := service.ProcessBalance(ctx, fileHeader)
 
@@ -84,15 +96,36 @@ func Test_MigrationService_ProcessBalance(t *testing.T) {
 		assert.Equal(t, err, expectedError)
 	})
 
+	t.Run("When StreamBatches fails reading the file", func(t *testing.T) {
+		csvProcessor := mocks.NewRecordSourceMock()
+		csvProcessor.On("Validate", mock.Anything, mock.Anything, mock.Anything).Return(2, nil)
+		csvProcessor.On("StreamBatches", ctx, mock.Anything, mock.Anything, mock.Anything, mock.Anything).
+			Return([]records.Batch{}, errors.New("unexpected EOF"))
+
+		service := services.NewMigrationService(cfg, loggerMock, mocks.NewUserRepositoryMock(),
+			mocks.NewTransactionRepositoryMock(), csvSources(csvProcessor), newAlertService())
+
+		_, err := service.ProcessBalance(ctx, fileHeader)
+
+		assert.EqualError(t, err, services.ReadFileError+": unexpected EOF")
+	})
+
 	t.Run("When ProcessBalance completes successfully", func(t *testing.T) {
-		records := [][]string{{"1", "test_user", "100.00", "2024-09-13T10:00:00Z"}}
+		batches := []records.Batch{
+			{
+				{Line: 2, Fields: []string{"1", "1", "100.00", "2024-09-13T10:00:00Z"}},
+				{Line: 3, Fields: []string{"2", "1", "-10.00", "2024-09-13T10:00:00Z"}},
+			},
+			{{Line: 4, Fields: []string{"3", "2", "50.00", "2024-09-13T10:00:00Z"}}},
+		}
 		expectedSummary := report.MigrationSummary{
-			TotalRecords: 1,
-			UsersUpdated: 1,
+			TotalRecords: 3,
+			UsersUpdated: 2,
 		}
 
-		csvProcessor := mocks.NewCsvProcessorMock()
-		csvProcessor.On("ReadFile", fileHeader, mock.Anything).Return(records, nil)
+		csvProcessor := mocks.NewRecordSourceMock()
+		csvProcessor.On("Validate", mock.Anything, mock.Anything, mock.Anything).Return(3, nil)
+		csvProcessor.On("StreamBatches", ctx, mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return(batches, nil)
 
 		userRepo := mocks.NewUserRepositoryMock()
 		userRepo.On("Save", mock.Anything, mock.Anything).Return(nil)
@@ -100,11 +133,735 @@ func Test_MigrationService_ProcessBalance(t *testing.T) {
 		transactionRepo := mocks.NewTransactionRepositoryMock()
 		transactionRepo.On("SaveBatch", mock.Anything, mock.Anything).Return(nil)
 
-		service := services.NewMigrationService(cfg, loggerMock, userRepo, transactionRepo, csvProcessor)
+		service := services.NewMigrationService(cfg, loggerMock, userRepo, transactionRepo, csvSources(csvProcessor), newAlertService())
 
 		summary, err := service.ProcessBalance(ctx, fileHeader)
 
 		assert.Equal(t, expectedSummary, summary)
 		assert.Nil(t, err)
+		transactionRepo.AssertNumberOfCalls(t, "SaveBatch", 2)
+	})
+}
+
+func Test_MigrationService_ProcessBalanceWithOptions(t *testing.T) {
+	ctx := context.TODO()
+	cfg := config.NewConfig()
+	cfg.Workers.MigrationWorkerBatchSize = 1
+	loggerMock := logger.NewLogger()
+	options := migration.Options{Mode: migration.ModeDefault}
+	open := func() (io.ReadCloser, error) {
+		return io.NopCloser(strings.NewReader("id,user_id,amount,datetime")), nil
+	}
+
+	t.Run("When ProcessBalanceWithOptions reports every saved batch", func(t *testing.T) {
+		batches := []records.Batch{
+			{{Line: 2, Fields: []string{"1", "1", "100.00", "2024-09-13T10:00:00Z"}}},
+			{{Line: 3, Fields: []string{"2", "2", "-50.00", "2024-09-13T10:00:00Z"}}},
+		}
+
+		csvProcessor := mocks.NewRecordSourceMock()
+		csvProcessor.On("Validate", mock.Anything, mock.Anything, mock.Anything).Return(2, nil)
+		csvProcessor.On("StreamBatches", ctx, mock.Anything, mock.Anything, 1, mock.Anything).Return(batches, nil)
+
+		transactionRepo := mocks.NewTransactionRepositoryMock()
+		transactionRepo.On("SaveBatch", mock.Anything, mock.Anything).Return(nil)
+
+		service := services.NewMigrationService(cfg, loggerMock, mocks.NewUserRepositoryMock(), transactionRepo,
+			csvSources(csvProcessor), newAlertService())
+
+		var mu sync.Mutex
+		var updates []migration.Progress
+		summary, err := service.ProcessBalanceWithOptions(ctx, open, options, migration.Hooks{
+			OnProgress: func(progress migration.Progress) {
+				mu.Lock()
+				defer mu.Unlock()
+				updates = append(updates, progress)
+			},
+		})
+
+		assert.Nil(t, err)
+		assert.Equal(t, report.MigrationSummary{TotalRecords: 2, UsersUpdated: 2}, summary)
+		assert.Len(t, updates, 3)
+		assert.Equal(t, migration.Progress{RowsValidated: 2, BatchesTotal: 2}, updates[0])
+		assert.Equal(t, migration.Progress{RowsValidated: 2, RowsInserted: 2, BatchesDone: 2, BatchesTotal: 2}, updates[2])
+	})
+
+	t.Run("When the alert rules of every batch are checked once it is saved", func(t *testing.T) {
+		batches := []records.Batch{
+			{{Line: 2, Fields: []string{"1", "1", "100.00", "2024-09-13T10:00:00Z"}}},
+			{{Line: 3, Fields: []string{"2", "2", "-50.00", "2024-09-13T10:00:00Z"}}},
+		}
+
+		csvProcessor := mocks.NewRecordSourceMock()
+		csvProcessor.On("Validate", mock.Anything, mock.Anything, mock.Anything).Return(2, nil)
+		csvProcessor.On("StreamBatches", ctx, mock.Anything, mock.Anything, 1, mock.Anything).Return(batches, nil)
+
+		transactionRepo := mocks.NewTransactionRepositoryMock()
+		transactionRepo.On("SaveBatch", mock.Anything, mock.MatchedBy(func(transactions []transaction.Transaction) bool {
+			return transactions[0].ID == "1"
+		})).Return(nil)
+		transactionRepo.On("SaveBatch", mock.Anything, mock.MatchedBy(func(transactions []transaction.Transaction) bool {
+			return transactions[0].ID == "2"
+		})).Return(errors.New("repository error"))
+
+		alertService := mocks.NewAlertServiceMock()
+		alertService.On("Check", ctx, []string(nil), mock.MatchedBy(func(transactions []transaction.Transaction) bool {
+			return len(transactions) == 1 && transactions[0].ID == "1"
+		})).Return(nil)
+
+		service := services.NewMigrationService(cfg, loggerMock, mocks.NewUserRepositoryMock(), transactionRepo,
+			csvSources(csvProcessor), alertService)
+		_, err := service.ProcessBalanceWithOptions(ctx, open, options, migration.Hooks{})
+
+		assert.EqualError(t, err, "error saving transaction batch: repository error")
+		alertService.AssertNumberOfCalls(t, "Check", 1)
+	})
+
+	t.Run("When a profile is given the file columns are mapped by its header names", func(t *testing.T) {
+		profile := &migration.MappingProfile{
+			Name:           "partner",
+			Columns:        map[string][]string{migration.FieldUserID: {"customer"}},
+			IgnoredColumns: []string{"notes"},
+			Strict:         true,
+		}
+		expectedMapping := &records.Mapping{
+			Fields:  append(migration.RecordFields(), migration.UserFields()...),
+			Columns: profile.Columns,
+			Ignored: profile.IgnoredColumns,
+			Defaults: map[string]string{migration.FieldFirstName: "", migration.FieldLastName: "",
+				migration.FieldEmail: ""},
+			Strict: true,
+		}
+		batches := []records.Batch{{{Line: 2, Fields: []string{"1", "1", "100.00", "2024-09-13T10:00:00Z"}}}}
+
+		csvProcessor := mocks.NewRecordSourceMock()
+		csvProcessor.On("Validate", mock.Anything, expectedMapping, mock.Anything).Return(1, nil)
+		csvProcessor.On("StreamBatches", ctx, mock.Anything, expectedMapping, 1, mock.Anything).Return(batches, nil)
+
+		transactionRepo := mocks.NewTransactionRepositoryMock()
+		transactionRepo.On("SaveBatch", mock.Anything, mock.Anything).Return(nil)
+
+		service := services.NewMigrationService(cfg, loggerMock, mocks.NewUserRepositoryMock(), transactionRepo,
+			csvSources(csvProcessor), newAlertService())
+
+		summary, err := service.ProcessBalanceWithOptions(ctx, open,
+			migration.Options{Mode: migration.ModeDefault, Profile: profile}, migration.Hooks{})
+
+		assert.Nil(t, err)
+		assert.Equal(t, report.MigrationSummary{TotalRecords: 1, UsersUpdated: 1}, summary)
+		csvProcessor.AssertExpectations(t)
+	})
+
+	t.Run("When a migration ID is given every saved transaction is stamped with it", func(t *testing.T) {
+		batches := []records.Batch{{
+			{Line: 2, Fields: []string{"1", "1", "100.00", "2024-09-13T10:00:00Z"}},
+			{Line: 3, Fields: []string{"2", "2", "-50.00", "2024-09-13T10:00:00Z"}},
+		}}
+
+		csvProcessor := mocks.NewRecordSourceMock()
+		csvProcessor.On("Validate", mock.Anything, mock.Anything, mock.Anything).Return(2, nil)
+		csvProcessor.On("StreamBatches", ctx, mock.Anything, mock.Anything, 1, mock.Anything).Return(batches, nil)
+
+		transactionRepo := mocks.NewTransactionRepositoryMock()
+		transactionRepo.On("SaveBatch", mock.Anything, mock.MatchedBy(func(transactions []transaction.Transaction) bool {
+			for _, userTransaction := range transactions {
+				if userTransaction.MigrationID != "7" {
+					return false
+				}
+			}
+
+			return len(transactions) == 2
+		})).Return(nil)
+
+		service := services.NewMigrationService(cfg, loggerMock, mocks.NewUserRepositoryMock(), transactionRepo,
+			csvSources(csvProcessor), newAlertService())
+
+		_, err := service.ProcessBalanceWithOptions(ctx, open,
+			migration.Options{Mode: migration.ModeDefault, MigrationID: "7"}, migration.Hooks{})
+
+		assert.Nil(t, err)
+		transactionRepo.AssertExpectations(t)
+	})
+
+	t.Run("When Validate returns an error", func(t *testing.T) {
+		csvProcessor := mocks.NewRecordSourceMock()
+		csvProcessor.On("Validate", mock.Anything, mock.Anything, mock.Anything).Return(0, errors.New("line 2: invalid record"))
+
+		service := services.NewMigrationService(cfg, loggerMock, mocks.NewUserRepositoryMock(),
+			mocks.NewTransactionRepositoryMock(), csvSources(csvProcessor), newAlertService())
+
+		_, err := service.ProcessBalanceWithOptions(ctx, open, options, migration.Hooks{
+			OnProgress: func(progress migration.Progress) {
+				t.Fatal("progress should not be reported")
+			},
+		})
+
+		assert.EqualError(t, err, services.ReadFileError+": line 2: invalid record")
+	})
+
+	t.Run("When the file can't be opened", func(t *testing.T) {
+		service := services.NewMigrationService(cfg, loggerMock, mocks.NewUserRepositoryMock(),
+			mocks.NewTransactionRepositoryMock(), csvSources(mocks.NewRecordSourceMock()), newAlertService())
+
+		_, err := service.ProcessBalanceWithOptions(ctx, func() (io.ReadCloser, error) {
+			return nil, errors.New("file not found")
+		}, options, migration.Hooks{})
+
+		assert.EqualError(t, err, services.ReadFileError+": file not found")
+	})
+
+	t.Run("When a batch fails it's not counted as done", func(t *testing.T) {
+		batches := []records.Batch{{{Line: 2, Fields: []string{"1", "1", "100.00", "2024-09-13T10:00:00Z"}}}}
+
+		csvProcessor := mocks.NewRecordSourceMock()
+		csvProcessor.On("Validate", mock.Anything, mock.Anything, mock.Anything).Return(1, nil)
+		csvProcessor.On("StreamBatches", ctx, mock.Anything, mock.Anything, 1, mock.Anything).Return(batches, nil)
+
+		transactionRepo := mocks.NewTransactionRepositoryMock()
+		transactionRepo.On("SaveBatch", mock.Anything, mock.Anything).Return(errors.New("repository error"))
+
+		service := services.NewMigrationService(cfg, loggerMock, mocks.NewUserRepositoryMock(), transactionRepo,
+			csvSources(csvProcessor), newAlertService())
+
+		var last migration.Progress
+		_, err := service.ProcessBalanceWithOptions(ctx, open, options, migration.Hooks{
+			OnProgress: func(progress migration.Progress) {
+				last = progress
+			},
+		})
+
+		assert.Error(t, err)
+		assert.Equal(t, migration.Progress{RowsValidated: 1, BatchesTotal: 1}, last)
+	})
+}
+
+func Test_MigrationService_ProcessBalanceWithOptions_Partial(t *testing.T) {
+	ctx := context.TODO()
+	cfg := config.NewConfig()
+	loggerMock := logger.NewLogger()
+	options := migration.Options{Mode: migration.ModePartial}
+	open := func() (io.ReadCloser, error) {
+		return io.NopCloser(strings.NewReader("id,user_id,amount,datetime")), nil
+	}
+
+	t.Run("When the valid records are saved and the rest are rejected", func(t *testing.T) {
+		batches := []records.Batch{{
+			{Line: 2, Raw: "1,1,100.00,2024-09-13T10:00:00Z", Fields: []string{"1", "1", "100.00", "2024-09-13T10:00:00Z"}},
+			{Line: 3, Raw: "2,1,abc,2024-09-13T10:00:00Z", Fields: []string{"2", "1", "abc", "2024-09-13T10:00:00Z"}},
+			{Line: 4, Raw: "3,1,0,2024-09-13T10:00:00Z", Fields: []string{"3", "1", "0", "2024-09-13T10:00:00Z"}},
+			{Line: 5, Raw: "1,2,10.00,2024-09-13T10:00:00Z", Fields: []string{"1", "2", "10.00", "2024-09-13T10:00:00Z"}},
+			{Line: 6, Raw: "4,9,10.00,2024-09-13T10:00:00Z", Fields: []string{"4", "9", "10.00", "2024-09-13T10:00:00Z"}},
+			{Line: 7, Raw: "5,\"2", Err: errors.New("extraneous or missing \" in quoted-field")},
+		}}
+
+		csvProcessor := mocks.NewRecordSourceMock()
+		csvProcessor.On("Count", mock.Anything).Return(6, nil)
+		csvProcessor.On("StreamBatches", ctx, mock.Anything, mock.Anything, cfg.Workers.MigrationWorkerBatchSize, mock.Anything).
+			Return(batches, nil)
+
+		transactionRepo := mocks.NewTransactionRepositoryMock()
+		transactionRepo.On("SaveBatchSkippingRejected", ctx, mock.MatchedBy(func(transactions []transaction.Transaction) bool {
+			return len(transactions) == 2 && transactions[0].ID == "1" && transactions[1].ID == "4"
+		})).Return([]transaction.Rejection{{Index: 1, Reason: user.NotFoundError}}, nil)
+
+		var rejects []migration.Reject
+		service := services.NewMigrationService(cfg, loggerMock, mocks.NewUserRepositoryMock(), transactionRepo,
+			csvSources(csvProcessor), newAlertService())
+		summary, err := service.ProcessBalanceWithOptions(ctx, open, options, migration.Hooks{
+			OnRejects: func(batchRejects []migration.Reject) error {
+				rejects = append(rejects, batchRejects...)
+				return nil
+			},
+		})
+
+		assert.Nil(t, err)
+		assert.Equal(t, report.MigrationSummary{TotalRecords: 1, UsersUpdated: 1, RejectedRecords: 5,
+			RejectsByReason: map[string]int{
+				migration.RejectReasonValidation:  2,
+				migration.RejectReasonZeroAmount:  1,
+				migration.RejectReasonDuplicateID: 1,
+				migration.RejectReasonUnknownUser: 1,
+			}}, summary)
+		assert.Len(t, rejects, 5)
+		assert.Equal(t, migration.Reject{Line: 6, Raw: "4,9,10.00,2024-09-13T10:00:00Z",
+			Reason: migration.RejectReasonUnknownUser, Detail: user.NotFoundError}, rejects[4])
+		csvProcessor.AssertNotCalled(t, "Validate", mock.Anything, mock.Anything, mock.Anything)
+	})
+
+	t.Run("When the rejects can't be stored the migration fails", func(t *testing.T) {
+		batches := []records.Batch{{{Line: 2, Raw: "1,1,0,2024-09-13T10:00:00Z",
+			Fields: []string{"1", "1", "0", "2024-09-13T10:00:00Z"}}}}
+
+		csvProcessor := mocks.NewRecordSourceMock()
+		csvProcessor.On("Count", mock.Anything).Return(1, nil)
+		csvProcessor.On("StreamBatches", ctx, mock.Anything, mock.Anything, cfg.Workers.MigrationWorkerBatchSize, mock.Anything).
+			Return(batches, nil)
+
+		transactionRepo := mocks.NewTransactionRepositoryMock()
+
+		service := services.NewMigrationService(cfg, loggerMock, mocks.NewUserRepositoryMock(), transactionRepo,
+			csvSources(csvProcessor), newAlertService())
+		_, err := service.ProcessBalanceWithOptions(ctx, open, options, migration.Hooks{
+			OnRejects: func(rejects []migration.Reject) error {
+				return errors.New("repository error")
+			},
+		})
+
+		assert.EqualError(t, err, "error saving rejected records: repository error")
+		transactionRepo.AssertNotCalled(t, "SaveBatchSkippingRejected", mock.Anything, mock.Anything)
+	})
+}
+
+func Test_MigrationService_ProcessBalanceWithOptions_Strict(t *testing.T) {
+	ctx := context.TODO()
+	cfg := config.NewConfig()
+	cfg.Workers.MigrationWorkerBatchSize = 1
+	loggerMock := logger.NewLogger()
+	options := migration.Options{Mode: migration.ModeStrict}
+	open := func() (io.ReadCloser, error) {
+		return io.NopCloser(strings.NewReader("id,user_id,amount,datetime")), nil
+	}
+	batches := []records.Batch{
+		{{Line: 2, Fields: []string{"1", "1", "100.00", "2024-09-13T10:00:00Z"}}},
+		{{Line: 3, Fields: []string{"2", "2", "-50.00", "2024-09-13T10:00:00Z"}}},
+	}
+
+	t.Run("When every batch is staged the stage is committed", func(t *testing.T) {
+		csvProcessor := mocks.NewRecordSourceMock()
+		csvProcessor.On("Validate", mock.Anything, mock.Anything, mock.Anything).Return(2, nil)
+		csvProcessor.On("StreamBatches", ctx, mock.Anything, mock.Anything, 1, mock.Anything).Return(batches, nil)
+
+		transactionRepo := mocks.NewTransactionRepositoryMock()
+		transactionRepo.On("CreateStage", ctx).Return("5", nil)
+		transactionRepo.On("StageBatch", ctx, "5", mock.Anything).Return(nil)
+		transactionRepo.On("CommitStage", ctx, "5").Return(int64(2), nil)
+
+		service := services.NewMigrationService(cfg, loggerMock, mocks.NewUserRepositoryMock(), transactionRepo,
+			csvSources(csvProcessor), newAlertService())
+
+		var mu sync.Mutex
+		var last migration.Progress
+		summary, err := service.ProcessBalanceWithOptions(ctx, open, options, migration.Hooks{
+			OnProgress: func(progress migration.Progress) {
+				mu.Lock()
+				defer mu.Unlock()
+				last = progress
+			},
+		})
+
+		assert.Nil(t, err)
+		assert.Equal(t, report.MigrationSummary{TotalRecords: 2, UsersUpdated: 2}, summary)
+		assert.Equal(t, migration.Progress{RowsValidated: 2, RowsInserted: 2, BatchesDone: 2, BatchesTotal: 2}, last)
+		transactionRepo.AssertNumberOfCalls(t, "StageBatch", 2)
+		transactionRepo.AssertNotCalled(t, "SaveBatch", mock.Anything, mock.Anything)
+		transactionRepo.AssertNotCalled(t, "DiscardStage", mock.Anything, mock.Anything)
 	})
+
+	t.Run("When a late batch fails the stage is discarded without committing", func(t *testing.T) {
+		csvProcessor := mocks.NewRecordSourceMock()
+		csvProcessor.On("Validate", mock.Anything, mock.Anything, mock.Anything).Return(2, nil)
+		csvProcessor.On("StreamBatches", ctx, mock.Anything, mock.Anything, 1, mock.Anything).Return(batches, nil)
+
+		transactionRepo := mocks.NewTransactionRepositoryMock()
+		transactionRepo.On("CreateStage", ctx).Return("5", nil)
+		transactionRepo.On("StageBatch", ctx, "5", mock.MatchedBy(func(transactions []transaction.Transaction) bool {
+			return transactions[0].ID == "1"
+		})).Return(nil)
+		transactionRepo.On("StageBatch", ctx, "5", mock.MatchedBy(func(transactions []transaction.Transaction) bool {
+			return transactions[0].ID == "2"
+		})).Return(errors.New("repository error"))
+		transactionRepo.On("DiscardStage", ctx, "5").Return(nil)
+
+		alertService := newAlertService()
+		service := services.NewMigrationService(cfg, loggerMock, mocks.NewUserRepositoryMock(), transactionRepo,
+			csvSources(csvProcessor), alertService)
+		summary, err := service.ProcessBalanceWithOptions(ctx, open, options, migration.Hooks{})
+
+		assert.EqualError(t, err, "error staging transaction batch: repository error")
+		assert.Empty(t, summary)
+		transactionRepo.AssertNotCalled(t, "CommitStage", mock.Anything, mock.Anything)
+		transactionRepo.AssertCalled(t, "DiscardStage", ctx, "5")
+		alertService.AssertNotCalled(t, "Check", mock.Anything, mock.Anything, mock.Anything)
+	})
+
+	t.Run("When the committed stage is read back to check its alert rules", func(t *testing.T) {
+		csvProcessor := mocks.NewRecordSourceMock()
+		csvProcessor.On("Validate", mock.Anything, mock.Anything, mock.Anything).Return(2, nil)
+		csvProcessor.On("StreamBatches", ctx, mock.Anything, mock.Anything, 1, mock.Anything).Return(batches, nil)
+
+		committed := []transaction.ListItem{{Transaction: transaction.Transaction{ID: "1", UserID: "1", Amount: 100}},
+			{Transaction: transaction.Transaction{ID: "2", UserID: "2", Amount: -50}}}
+		transactionRepo := mocks.NewTransactionRepositoryMock()
+		transactionRepo.On("CreateStage", ctx).Return("5", nil)
+		transactionRepo.On("StageBatch", ctx, "5", mock.Anything).Return(nil)
+		transactionRepo.On("CommitStage", ctx, "5").Return(int64(2), nil)
+		transactionRepo.On("ListByMigration", ctx, "7", "", migration.MaxListLimit).Return(committed, nil)
+
+		alertService := mocks.NewAlertServiceMock()
+		alertService.On("Check", ctx, []string(nil), []transaction.Transaction{committed[0].Transaction,
+			committed[1].Transaction}).Return(nil)
+
+		service := services.NewMigrationService(cfg, loggerMock, mocks.NewUserRepositoryMock(), transactionRepo,
+			csvSources(csvProcessor), alertService)
+		_, err := service.ProcessBalanceWithOptions(ctx, open, migration.Options{Mode: migration.ModeStrict,
+			MigrationID: "7"}, migration.Hooks{})
+
+		assert.Nil(t, err)
+		alertService.AssertNumberOfCalls(t, "Check", 1)
+	})
+
+	t.Run("When the commit fails the stage is discarded", func(t *testing.T) {
+		csvProcessor := mocks.NewRecordSourceMock()
+		csvProcessor.On("Validate", mock.Anything, mock.Anything, mock.Anything).Return(2, nil)
+		csvProcessor.On("StreamBatches", ctx, mock.Anything, mock.Anything, 1, mock.Anything).Return(batches, nil)
+
+		transactionRepo := mocks.NewTransactionRepositoryMock()
+		transactionRepo.On("CreateStage", ctx).Return("5", nil)
+		transactionRepo.On("StageBatch", ctx, "5", mock.Anything).Return(nil)
+		transactionRepo.On("CommitStage", ctx, "5").Return(int64(0), errors.New(transaction.DuplicateTransactionError))
+		transactionRepo.On("DiscardStage", ctx, "5").Return(nil)
+
+		service := services.NewMigrationService(cfg, loggerMock, mocks.NewUserRepositoryMock(), transactionRepo,
+			csvSources(csvProcessor), newAlertService())
+		_, err := service.ProcessBalanceWithOptions(ctx, open, options, migration.Hooks{})
+
+		assert.EqualError(t, err, "error committing transaction batches: "+transaction.DuplicateTransactionError)
+		transactionRepo.AssertCalled(t, "DiscardStage", ctx, "5")
+	})
+
+	t.Run("When the file is invalid no stage is created", func(t *testing.T) {
+		csvProcessor := mocks.NewRecordSourceMock()
+		csvProcessor.On("Validate", mock.Anything, mock.Anything, mock.Anything).Return(0, errors.New("line 2: invalid record"))
+
+		transactionRepo := mocks.NewTransactionRepositoryMock()
+
+		service := services.NewMigrationService(cfg, loggerMock, mocks.NewUserRepositoryMock(), transactionRepo,
+			csvSources(csvProcessor), newAlertService())
+		_, err := service.ProcessBalanceWithOptions(ctx, open, options, migration.Hooks{})
+
+		assert.EqualError(t, err, services.ReadFileError+": line 2: invalid record")
+		transactionRepo.AssertNotCalled(t, "CreateStage", mock.Anything)
+	})
+}
+
+func Test_MigrationService_ProcessBalanceWithOptions_CreateMissingUsers(t *testing.T) {
+	ctx := context.TODO()
+	cfg := config.NewConfig()
+	loggerMock := logger.NewLogger()
+	open := func() (io.ReadCloser, error) {
+		return io.NopCloser(strings.NewReader("id,user_id,amount,datetime,first_name,last_name,email")), nil
+	}
+
+	t.Run("When the users of the batch are created before saving it", func(t *testing.T) {
+		batches := []records.Batch{{
+			{Line: 2, Fields: []string{"1", "7", "100.00", "2024-09-13T10:00:00Z", "Ada", "Lovelace", "Ada@Example.com"}},
+			{Line: 3, Fields: []string{"2", "7", "-10.00", "2024-09-13T10:00:00Z", "Other", "", ""}},
+			{Line: 4, Fields: []string{"3", "8", "20.00", "2024-09-13T10:00:00Z", "", "", ""}},
+		}}
+
+		csvProcessor := mocks.NewRecordSourceMock()
+		csvProcessor.On("Validate", mock.Anything, mock.Anything, mock.Anything).Run(func(args mock.Arguments) {
+			validator := args.Get(2).(func(record []string) error)
+			assert.EqualError(t, validator([]string{"1", "7", "100.00", "2024-09-13T10:00:00Z", "", "", "ada"}),
+				user.InvalidEmailError)
+		}).Return(3, nil)
+		csvProcessor.On("StreamBatches", ctx, mock.Anything, mock.Anything, mock.Anything, mock.Anything).
+			Return(batches, nil)
+
+		userRepo := mocks.NewUserRepositoryMock()
+		userRepo.On("CreateMissing", ctx, []user.User{
+			{ID: "7", FirstName: "Ada", LastName: "Lovelace", Email: "ada@example.com"},
+			{ID: "8"},
+		}).Return(1, nil, nil)
+
+		transactionRepo := mocks.NewTransactionRepositoryMock()
+		transactionRepo.On("SaveBatch", ctx, mock.Anything).Return(nil)
+
+		service := services.NewMigrationService(cfg, loggerMock, userRepo, transactionRepo, csvSources(csvProcessor), newAlertService())
+		summary, err := service.ProcessBalanceWithOptions(ctx, open,
+			migration.Options{Mode: migration.ModeDefault, CreateMissingUsers: true}, migration.Hooks{})
+
+		assert.Nil(t, err)
+		assert.Equal(t, report.MigrationSummary{TotalRecords: 3, UsersUpdated: 2, UsersCreated: 1}, summary)
+		userRepo.AssertExpectations(t)
+	})
+
+	t.Run("When a partial migration creates the users of the valid records only", func(t *testing.T) {
+		batches := []records.Batch{{
+			{Line: 2, Fields: []string{"1", "7", "100.00", "2024-09-13T10:00:00Z", "", "", "ada@example.com"}},
+			{Line: 3, Fields: []string{"2", "8", "10.00", "2024-09-13T10:00:00Z", "", "", "not an email"}},
+		}}
+
+		csvProcessor := mocks.NewRecordSourceMock()
+		csvProcessor.On("Count", mock.Anything).Return(2, nil)
+		csvProcessor.On("StreamBatches", ctx, mock.Anything, mock.Anything, mock.Anything, mock.Anything).
+			Return(batches, nil)
+
+		userRepo := mocks.NewUserRepositoryMock()
+		userRepo.On("CreateMissing", ctx, []user.User{{ID: "7", Email: "ada@example.com"}}).Return(1, nil, nil)
+
+		transactionRepo := mocks.NewTransactionRepositoryMock()
+		transactionRepo.On("SaveBatchSkippingRejected", ctx, mock.Anything).Return([]transaction.Rejection(nil), nil)
+
+		service := services.NewMigrationService(cfg, loggerMock, userRepo, transactionRepo, csvSources(csvProcessor), newAlertService())
+		summary, err := service.ProcessBalanceWithOptions(ctx, open,
+			migration.Options{Mode: migration.ModePartial, CreateMissingUsers: true}, migration.Hooks{
+				OnRejects: func(rejects []migration.Reject) error {
+					assert.Equal(t, user.InvalidEmailError, rejects[0].Detail)
+					return nil
+				},
+			})
+
+		assert.Nil(t, err)
+		assert.Equal(t, report.MigrationSummary{TotalRecords: 1, UsersUpdated: 1, UsersCreated: 1, RejectedRecords: 1,
+			RejectsByReason: map[string]int{migration.RejectReasonValidation: 1}}, summary)
+	})
+
+	t.Run("When the email of a new user is taken its records are rejected", func(t *testing.T) {
+		batches := []records.Batch{{
+			{Line: 2, Fields: []string{"1", "7", "100.00", "2024-09-13T10:00:00Z", "", "", "ada@example.com"}},
+			{Line: 3, Fields: []string{"2", "8", "10.00", "2024-09-13T10:00:00Z", "", "", "ada@example.com"}},
+		}}
+
+		csvProcessor := mocks.NewRecordSourceMock()
+		csvProcessor.On("Count", mock.Anything).Return(2, nil)
+		csvProcessor.On("StreamBatches", ctx, mock.Anything, mock.Anything, mock.Anything, mock.Anything).
+			Return(batches, nil)
+
+		userRepo := mocks.NewUserRepositoryMock()
+		userRepo.On("CreateMissing", ctx, []user.User{{ID: "7", Email: "ada@example.com"},
+			{ID: "8", Email: "ada@example.com"}}).Return(1, map[string]bool{"8": true}, nil)
+
+		transactionRepo := mocks.NewTransactionRepositoryMock()
+		transactionRepo.On("SaveBatchSkippingRejected", ctx, mock.MatchedBy(func(transactions []transaction.Transaction) bool {
+			return len(transactions) == 1 && transactions[0].UserID == "7"
+		})).Return([]transaction.Rejection(nil), nil)
+
+		var rejected []migration.Reject
+		service := services.NewMigrationService(cfg, loggerMock, userRepo, transactionRepo, csvSources(csvProcessor),
+			newAlertService())
+		summary, err := service.ProcessBalanceWithOptions(ctx, open,
+			migration.Options{Mode: migration.ModePartial, CreateMissingUsers: true}, migration.Hooks{
+				OnRejects: func(rejects []migration.Reject) error {
+					rejected = rejects
+					return nil
+				},
+			})
+
+		assert.Nil(t, err)
+		assert.Equal(t, []migration.Reject{{Line: 3, Reason: migration.RejectReasonDuplicateEmail,
+			Detail: user.DuplicateEmailError}}, rejected)
+		assert.Equal(t, report.MigrationSummary{TotalRecords: 1, UsersUpdated: 1, UsersCreated: 1, RejectedRecords: 1,
+			RejectsByReason: map[string]int{migration.RejectReasonDuplicateEmail: 1}}, summary)
+	})
+
+	t.Run("When the email of a new user is taken the batch fails", func(t *testing.T) {
+		batches := []records.Batch{{
+			{Line: 2, Fields: []string{"1", "7", "100.00", "2024-09-13T10:00:00Z", "", "", "ada@example.com"}},
+		}}
+
+		csvProcessor := mocks.NewRecordSourceMock()
+		csvProcessor.On("Validate", mock.Anything, mock.Anything, mock.Anything).Return(1, nil)
+		csvProcessor.On("StreamBatches", ctx, mock.Anything, mock.Anything, mock.Anything, mock.Anything).
+			Return(batches, nil)
+
+		userRepo := mocks.NewUserRepositoryMock()
+		userRepo.On("CreateMissing", ctx, []user.User{{ID: "7", Email: "ada@example.com"}}).
+			Return(0, map[string]bool{"7": true}, nil)
+
+		transactionRepo := mocks.NewTransactionRepositoryMock()
+
+		service := services.NewMigrationService(cfg, loggerMock, userRepo, transactionRepo, csvSources(csvProcessor),
+			newAlertService())
+		_, err := service.ProcessBalanceWithOptions(ctx, open,
+			migration.Options{Mode: migration.ModeDefault, CreateMissingUsers: true}, migration.Hooks{})
+
+		assert.EqualError(t, err, "error creating missing users: user 7: "+user.DuplicateEmailError)
+		transactionRepo.AssertNotCalled(t, "SaveBatch", mock.Anything, mock.Anything)
+	})
+
+	t.Run("When the users can't be created the migration fails", func(t *testing.T) {
+		batches := []records.Batch{{{Line: 2, Fields: []string{"1", "7", "100.00", "2024-09-13T10:00:00Z"}}}}
+
+		csvProcessor := mocks.NewRecordSourceMock()
+		csvProcessor.On("Validate", mock.Anything, mock.Anything, mock.Anything).Return(1, nil)
+		csvProcessor.On("StreamBatches", ctx, mock.Anything, mock.Anything, mock.Anything, mock.Anything).
+			Return(batches, nil)
+
+		userRepo := mocks.NewUserRepositoryMock()
+		userRepo.On("CreateMissing", ctx, []user.User{{ID: "7"}}).Return(0, nil, errors.New("repository error"))
+
+		transactionRepo := mocks.NewTransactionRepositoryMock()
+
+		service := services.NewMigrationService(cfg, loggerMock, userRepo, transactionRepo, csvSources(csvProcessor), newAlertService())
+		_, err := service.ProcessBalanceWithOptions(ctx, open,
+			migration.Options{Mode: migration.ModeDefault, CreateMissingUsers: true}, migration.Hooks{})
+
+		assert.EqualError(t, err, "error creating missing users: repository error")
+		transactionRepo.AssertNotCalled(t, "SaveBatch", mock.Anything, mock.Anything)
+	})
+
+	t.Run("When the migration is strict nothing is read", func(t *testing.T) {
+		csvProcessor := mocks.NewRecordSourceMock()
+
+		service := services.NewMigrationService(cfg, loggerMock, mocks.NewUserRepositoryMock(),
+			mocks.NewTransactionRepositoryMock(), csvSources(csvProcessor), newAlertService())
+		_, err := service.ProcessBalanceWithOptions(ctx, open,
+			migration.Options{Mode: migration.ModeStrict, CreateMissingUsers: true}, migration.Hooks{})
+
+		assert.EqualError(t, err, migration.StrictMissingUsersError)
+		csvProcessor.AssertNotCalled(t, "Validate", mock.Anything, mock.Anything, mock.Anything)
+	})
+}
+
+func Test_MigrationService_ValidateBalance(t *testing.T) {
+	ctx := context.TODO()
+	cfg := config.NewConfig()
+	cfg.Workers.MigrationWorkerBatchSize = 3
+	loggerMock := logger.NewLogger()
+	open := func() (io.ReadCloser, error) {
+		return io.NopCloser(strings.NewReader("id,user_id,amount,datetime")), nil
+	}
+
+	t.Run("When every problem of the file is reported without writing", func(t *testing.T) {
+		batches := []records.Batch{
+			{
+				{Line: 2, Raw: "1,1,100.00,2024-09-13T10:00:00Z", Fields: []string{"1", "1", "100.00", "2024-09-13T10:00:00Z"}},
+				{Line: 3, Raw: "2,9,10.00,2024-09-13T10:00:00Z", Fields: []string{"2", "9", "10.00", "2024-09-13T10:00:00Z"}},
+				{Line: 4, Raw: "3,1,abc,2024-09-13T10:00:00Z", Fields: []string{"3", "1", "abc", "2024-09-13T10:00:00Z"}},
+			},
+			{
+				{Line: 5, Raw: "4,1,0,2024-09-13T10:00:00Z", Fields: []string{"4", "1", "0", "2024-09-13T10:00:00Z"}},
+				{Line: 6, Raw: "1,2,10.00,2024-09-13T10:00:00Z", Fields: []string{"1", "2", "10.00", "2024-09-13T10:00:00Z"}},
+				{Line: 7, Raw: "5,2,10.00,2024-09-13T10:00:00Z", Fields: []string{"5", "2", "10.00", "2024-09-13T10:00:00Z"}},
+			},
+		}
+
+		csvProcessor := mocks.NewRecordSourceMock()
+		csvProcessor.On("StreamBatches", ctx, mock.Anything, mock.Anything, 3, mock.Anything).Return(batches, nil)
+
+		userRepo := mocks.NewUserRepositoryMock()
+		userRepo.On("FindActiveIDs", ctx, []string{"1", "9"}).Return(map[string]bool{"1": true}, nil)
+		userRepo.On("FindActiveIDs", ctx, []string{"2"}).Return(map[string]bool{"2": true}, nil)
+
+		transactionRepo := mocks.NewTransactionRepositoryMock()
+		transactionRepo.On("FindExistingIDs", ctx, []string{"1", "2"}).Return(map[string]bool{}, nil)
+		transactionRepo.On("FindExistingIDs", ctx, []string{"5"}).Return(map[string]bool{"5": true}, nil)
+
+		service := services.NewMigrationService(cfg, loggerMock, userRepo, transactionRepo, csvSources(csvProcessor), newAlertService())
+		validationReport, err := service.ValidateBalance(ctx, open, migration.Options{})
+
+		assert.Nil(t, err)
+		assert.False(t, validationReport.Valid)
+		assert.Empty(t, validationReport.FileErrors)
+		assert.Equal(t, report.MigrationSummary{TotalRecords: 1, UsersUpdated: 1, RejectedRecords: 5,
+			RejectsByReason: map[string]int{
+				migration.RejectReasonUnknownUser: 1,
+				migration.RejectReasonValidation:  1,
+				migration.RejectReasonZeroAmount:  1,
+				migration.RejectReasonDuplicateID: 2,
+			}}, validationReport.Summary)
+
+		lines := make([]int, 0, len(validationReport.Problems))
+		for _, problem := range validationReport.Problems {
+			lines = append(lines, problem.Line)
+		}
+		assert.Equal(t, []int{3, 4, 5, 6, 7}, lines)
+		assert.Equal(t, migration.Reject{Line: 6, Raw: "1,2,10.00,2024-09-13T10:00:00Z",
+			Reason: migration.RejectReasonDuplicateID, Detail: transaction.DuplicateTransactionError + " with line 2"},
+			validationReport.Problems[3])
+		transactionRepo.AssertNotCalled(t, "SaveBatch", mock.Anything, mock.Anything)
+	})
+
+	t.Run("When the file is valid", func(t *testing.T) {
+		batches := []records.Batch{{
+			{Line: 2, Fields: []string{"1", "1", "100.00", "2024-09-13T10:00:00Z"}},
+			{Line: 3, Fields: []string{"2", "1", "-10.00", "2024-09-13T10:00:00Z"}},
+		}}
+
+		csvProcessor := mocks.NewRecordSourceMock()
+		csvProcessor.On("StreamBatches", ctx, mock.Anything, mock.Anything, 3, mock.Anything).Return(batches, nil)
+
+		userRepo := mocks.NewUserRepositoryMock()
+		userRepo.On("FindActiveIDs", ctx, []string{"1", "1"}).Return(map[string]bool{"1": true}, nil)
+
+		transactionRepo := mocks.NewTransactionRepositoryMock()
+		transactionRepo.On("FindExistingIDs", ctx, []string{"1", "2"}).Return(map[string]bool{}, nil)
+
+		service := services.NewMigrationService(cfg, loggerMock, userRepo, transactionRepo, csvSources(csvProcessor), newAlertService())
+		validationReport, err := service.ValidateBalance(ctx, open, migration.Options{})
+
+		assert.Nil(t, err)
+		assert.Equal(t, migration.ValidationReport{Valid: true, Problems: []migration.Reject{},
+			Summary: report.MigrationSummary{TotalRecords: 2, UsersUpdated: 1}}, validationReport)
+	})
+
+	t.Run("When the unknown users would be created", func(t *testing.T) {
+		batches := []records.Batch{{
+			{Line: 2, Fields: []string{"1", "9", "100.00", "2024-09-13T10:00:00Z"}},
+			{Line: 3, Fields: []string{"2", "9", "-10.00", "2024-09-13T10:00:00Z"}},
+		}}
+
+		csvProcessor := mocks.NewRecordSourceMock()
+		csvProcessor.On("StreamBatches", ctx, mock.Anything, mock.Anything, 3, mock.Anything).Return(batches, nil)
+
+		userRepo := mocks.NewUserRepositoryMock()
+		userRepo.On("FindActiveIDs", ctx, []string{"9", "9"}).Return(map[string]bool{}, nil)
+
+		transactionRepo := mocks.NewTransactionRepositoryMock()
+		transactionRepo.On("FindExistingIDs", ctx, []string{"1", "2"}).Return(map[string]bool{}, nil)
+
+		service := services.NewMigrationService(cfg, loggerMock, userRepo, transactionRepo, csvSources(csvProcessor), newAlertService())
+		validationReport, err := service.ValidateBalance(ctx, open, migration.Options{CreateMissingUsers: true})
+
+		assert.Nil(t, err)
+		assert.True(t, validationReport.Valid)
+		assert.Equal(t, report.MigrationSummary{TotalRecords: 2, UsersUpdated: 1, UsersCreated: 1},
+			validationReport.Summary)
+		userRepo.AssertNotCalled(t, "CreateMissing", mock.Anything, mock.Anything)
+	})
+
+	t.Run("When the file has too few records", func(t *testing.T) {
+		batches := []records.Batch{{{Line: 2, Fields: []string{"1", "1", "100.00", "2024-09-13T10:00:00Z"}}}}
+
+		csvProcessor := mocks.NewRecordSourceMock()
+		csvProcessor.On("StreamBatches", ctx, mock.Anything, mock.Anything, 3, mock.Anything).Return(batches, nil)
+
+		userRepo := mocks.NewUserRepositoryMock()
+		userRepo.On("FindActiveIDs", ctx, mock.Anything).Return(map[string]bool{"1": true}, nil)
+
+		transactionRepo := mocks.NewTransactionRepositoryMock()
+		transactionRepo.On("FindExistingIDs", ctx, mock.Anything).Return(map[string]bool{}, nil)
+
+		service := services.NewMigrationService(cfg, loggerMock, userRepo, transactionRepo, csvSources(csvProcessor), newAlertService())
+		validationReport, err := service.ValidateBalance(ctx, open, migration.Options{})
+
+		assert.Nil(t, err)
+		assert.False(t, validationReport.Valid)
+		assert.Equal(t, []string{"the file must have at least 2 records"}, validationReport.FileErrors)
+	})
+
+	t.Run("When the users can't be checked", func(t *testing.T) {
+		batches := []records.Batch{
+			{{Line: 2, Fields: []string{"1", "1", "100.00", "2024-09-13T10:00:00Z"}}},
+			{{Line: 3, Fields: []string{"2", "1", "100.00", "2024-09-13T10:00:00Z"}}},
+		}
+
+		csvProcessor := mocks.NewRecordSourceMock()
+		csvProcessor.On("StreamBatches", ctx, mock.Anything, mock.Anything, 3, mock.Anything).Return(batches, nil)
+
+		userRepo := mocks.NewUserRepositoryMock()
+		userRepo.On("FindActiveIDs", ctx, mock.Anything).Return(map[string]bool(nil), errors.New("repository error"))
+
+		service := services.NewMigrationService(cfg, loggerMock, userRepo, mocks.NewTransactionRepositoryMock(),
+			csvSources(csvProcessor), newAlertService())
+		_, err := service.ValidateBalance(ctx, open, migration.Options{})
+
+		assert.EqualError(t, err, "error finding users: repository error")
+		userRepo.AssertNumberOfCalls(t, "FindActiveIDs", 1)
+	})
+}
+
+func csvSources(source records.Source) records.Sources {
+	return records.Sources{records.FormatCSV: source}
 }
@@ -0,0 +1,145 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"time"
+
+	"github.com/sebastianreh/user-balance-api/internal/domain/migration"
+	"github.com/sebastianreh/user-balance-api/internal/domain/upload"
+	"github.com/sebastianreh/user-balance-api/internal/infrastructure/config"
+	"github.com/sebastianreh/user-balance-api/pkg/logger"
+)
+
+const (
+	migrationUploadServiceName = "MigrationUploadService"
+)
+
+type MigrationUploadService interface {
+	CreateUpload(ctx context.Context, fileName string, size int64) (upload.Upload, error)
+	GetUpload(ctx context.Context, uploadID string) (upload.Upload, error)
+	WriteChunk(ctx context.Context, uploadID string, chunk upload.Chunk, content io.Reader) (upload.Upload, error)
+	OpenUpload(ctx context.Context, uploadID string) (io.ReadCloser, error)
+	FinalizeUpload(ctx context.Context, uploadID string, options migration.Options, async bool) (migration.Job, error)
+	DeleteUpload(ctx context.Context, uploadID string) error
+	DeleteExpiredUploads(ctx context.Context) error
+}
+
+type migrationUploadService struct {
+	config     config.Config
+	log        logger.Logger
+	repository upload.Repository
+	jobService MigrationJobService
+}
+
+func NewMigrationUploadService(cfg config.Config, log logger.Logger, repository upload.Repository,
+	jobService MigrationJobService) MigrationUploadService {
+	return &migrationUploadService{
+		config:     cfg,
+		log:        log,
+		repository: repository,
+		jobService: jobService,
+	}
+}
+
+// CreateUpload starts an empty upload of a file of the given size, its chunks are sent with WriteChunk
+func (s *migrationUploadService) CreateUpload(ctx context.Context, fileName string,
+	size int64) (upload.Upload, error) {
+	if size > s.config.Uploads.MaxSize {
+		return upload.Upload{}, fmt.Errorf("%s of %d bytes", upload.TooLargeError, s.config.Uploads.MaxSize)
+	}
+
+	return s.repository.Create(ctx, upload.Upload{FileName: fileName, Size: size})
+}
+
+func (s *migrationUploadService) GetUpload(ctx context.Context, uploadID string) (upload.Upload, error) {
+	return s.repository.FindByID(ctx, uploadID)
+}
+
+// WriteChunk appends the chunk to the upload, it must start at the received bytes. A chunk cut short keeps the
+// bytes that arrived, the returned upload tells where to resume
+func (s *migrationUploadService) WriteChunk(ctx context.Context, uploadID string, chunk upload.Chunk,
+	content io.Reader) (upload.Upload, error) {
+	current, err := s.repository.FindByID(ctx, uploadID)
+	if err != nil {
+		return current, err
+	}
+
+	if chunk.Total != current.Size {
+		return current, fmt.Errorf("%s of %d bytes", upload.SizeMismatchError, current.Size)
+	}
+
+	return s.repository.Append(ctx, uploadID, chunk.Start, io.LimitReader(content, chunk.Length()))
+}
+
+// OpenUpload reads the file of a complete upload that was not finalized yet
+func (s *migrationUploadService) OpenUpload(ctx context.Context, uploadID string) (io.ReadCloser, error) {
+	current, err := s.repository.FindByID(ctx, uploadID)
+	if err != nil {
+		return nil, err
+	}
+
+	switch {
+	case current.JobID != "":
+		return nil, errors.New(upload.FinalizedError)
+	case !current.IsComplete():
+		return nil, fmt.Errorf("%s, %d of %d bytes were received", upload.IncompleteError, current.ReceivedBytes,
+			current.Size)
+	}
+
+	return os.Open(current.Path)
+}
+
+// FinalizeUpload hands the file of a complete upload to a migration job. When the job can't be created, for
+// example because the file was already imported, the upload is kept so it can be finalized again
+func (s *migrationUploadService) FinalizeUpload(ctx context.Context, uploadID string, options migration.Options,
+	async bool) (migration.Job, error) {
+	claimed, err := s.repository.Claim(ctx, uploadID)
+	if err != nil {
+		return migration.Job{}, err
+	}
+
+	file := MigrationFile{Name: claimed.FileName, Path: claimed.Path}
+	var job migration.Job
+	if async {
+		job, err = s.jobService.StartFileMigration(ctx, file, options)
+	} else {
+		job, err = s.jobService.RunFileMigration(ctx, file, options)
+	}
+
+	if job.ID == "" {
+		if releaseErr := s.repository.Release(ctx, uploadID); releaseErr != nil {
+			s.log.ErrorAt(releaseErr, migrationUploadServiceName, "FinalizeUpload")
+		}
+
+		return job, err
+	}
+
+	if finishErr := s.repository.Finish(ctx, uploadID, job.ID); finishErr != nil {
+		s.log.ErrorAt(fmt.Errorf("could not record migration job %s of upload %s: %w", job.ID, uploadID,
+			finishErr), migrationUploadServiceName, "FinalizeUpload")
+	}
+
+	return job, err
+}
+
+func (s *migrationUploadService) DeleteUpload(ctx context.Context, uploadID string) error {
+	return s.repository.Delete(ctx, uploadID)
+}
+
+// DeleteExpiredUploads removes the uploads abandoned by their clients, it runs on startup
+func (s *migrationUploadService) DeleteExpiredUploads(ctx context.Context) error {
+	deleted, err := s.repository.DeleteExpired(ctx, time.Now().Add(-s.config.Uploads.ExpireAfter))
+	if err != nil {
+		return err
+	}
+
+	if deleted > 0 {
+		s.log.Warn("Expired migration uploads deleted", "uploads", deleted)
+	}
+
+	return nil
+}
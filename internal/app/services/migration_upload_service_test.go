@@ -0,0 +1,185 @@
+package services_test
+
+import (
+	"context"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/sebastianreh/user-balance-api/internal/app/services"
+	"github.com/sebastianreh/user-balance-api/internal/domain/migration"
+	"github.com/sebastianreh/user-balance-api/internal/domain/upload"
+	"github.com/sebastianreh/user-balance-api/internal/infrastructure/config"
+	"github.com/sebastianreh/user-balance-api/pkg/logger"
+	"github.com/sebastianreh/user-balance-api/test/mocks"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+const uploadID = "0123456789abcdef0123456789abcdef"
+
+func Test_MigrationUploadService_CreateUpload(t *testing.T) {
+	ctx := context.TODO()
+	cfg := config.NewConfig()
+	cfg.Uploads.MaxSize = 100
+	log := logger.NewLogger()
+
+	t.Run("When the upload is created empty", func(t *testing.T) {
+		created := upload.Upload{ID: uploadID, FileName: "migration.csv", Size: 100, Status: upload.StatusReceiving}
+		repository := mocks.NewUploadRepositoryMock()
+		repository.On("Create", ctx, upload.Upload{FileName: "migration.csv", Size: 100}).Return(created, nil)
+
+		service := services.NewMigrationUploadService(cfg, log, repository, mocks.NewMigrationJobServiceMock())
+		result, err := service.CreateUpload(ctx, "migration.csv", 100)
+
+		assert.Nil(t, err)
+		assert.Equal(t, created, result)
+	})
+
+	t.Run("When the file is larger than the limit", func(t *testing.T) {
+		repository := mocks.NewUploadRepositoryMock()
+
+		service := services.NewMigrationUploadService(cfg, log, repository, mocks.NewMigrationJobServiceMock())
+		_, err := service.CreateUpload(ctx, "migration.csv", 101)
+
+		assert.EqualError(t, err, upload.TooLargeError+" of 100 bytes")
+		repository.AssertNotCalled(t, "Create", mock.Anything, mock.Anything)
+	})
+}
+
+func Test_MigrationUploadService_WriteChunk(t *testing.T) {
+	ctx := context.TODO()
+	cfg := config.NewConfig()
+	log := logger.NewLogger()
+	current := upload.Upload{ID: uploadID, Size: 10, ReceivedBytes: 4}
+
+	t.Run("When only the bytes of the range are appended", func(t *testing.T) {
+		repository := mocks.NewUploadRepositoryMock()
+		repository.On("FindByID", ctx, uploadID).Return(current, nil)
+		repository.On("Append", ctx, uploadID, int64(4), mock.Anything).Run(func(args mock.Arguments) {
+			content, err := io.ReadAll(args.Get(3).(io.Reader))
+			assert.Nil(t, err)
+			assert.Equal(t, "456", string(content))
+		}).Return(upload.Upload{ID: uploadID, Size: 10, ReceivedBytes: 7}, nil)
+
+		service := services.NewMigrationUploadService(cfg, log, repository, mocks.NewMigrationJobServiceMock())
+		result, err := service.WriteChunk(ctx, uploadID, upload.Chunk{Start: 4, End: 6, Total: 10},
+			strings.NewReader("4567"))
+
+		assert.Nil(t, err)
+		assert.Equal(t, int64(7), result.ReceivedBytes)
+	})
+
+	t.Run("When the range total is not the upload size", func(t *testing.T) {
+		repository := mocks.NewUploadRepositoryMock()
+		repository.On("FindByID", ctx, uploadID).Return(current, nil)
+
+		service := services.NewMigrationUploadService(cfg, log, repository, mocks.NewMigrationJobServiceMock())
+		_, err := service.WriteChunk(ctx, uploadID, upload.Chunk{Start: 4, End: 6, Total: 20},
+			strings.NewReader("456"))
+
+		assert.EqualError(t, err, upload.SizeMismatchError+" of 10 bytes")
+		repository.AssertNotCalled(t, "Append", mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+	})
+}
+
+func Test_MigrationUploadService_FinalizeUpload(t *testing.T) {
+	ctx := context.TODO()
+	cfg := config.NewConfig()
+	log := logger.NewLogger()
+	options := migration.Options{Mode: migration.ModeDefault}
+	claimed := upload.Upload{ID: uploadID, FileName: "migration.csv", Size: 10, ReceivedBytes: 10,
+		Path: "/uploads/" + uploadID + ".part"}
+	file := services.MigrationFile{Name: "migration.csv", Path: claimed.Path}
+
+	t.Run("When the file is handed to a background job", func(t *testing.T) {
+		job := migration.Job{ID: "5", Status: migration.StatusPending}
+		repository := mocks.NewUploadRepositoryMock()
+		repository.On("Claim", ctx, uploadID).Return(claimed, nil)
+		repository.On("Finish", ctx, uploadID, "5").Return(nil)
+
+		jobService := mocks.NewMigrationJobServiceMock()
+		jobService.On("StartFileMigration", ctx, file, options).Return(job, nil)
+
+		service := services.NewMigrationUploadService(cfg, log, repository, jobService)
+		result, err := service.FinalizeUpload(ctx, uploadID, options, true)
+
+		assert.Nil(t, err)
+		assert.Equal(t, job, result)
+		repository.AssertNotCalled(t, "Release", mock.Anything, mock.Anything)
+	})
+
+	t.Run("When a failed migration still took the file", func(t *testing.T) {
+		expectedError := errors.New(services.ReadFileError)
+		job := migration.Job{ID: "6", Status: migration.StatusFailed}
+		repository := mocks.NewUploadRepositoryMock()
+		repository.On("Claim", ctx, uploadID).Return(claimed, nil)
+		repository.On("Finish", ctx, uploadID, "6").Return(nil)
+
+		jobService := mocks.NewMigrationJobServiceMock()
+		jobService.On("RunFileMigration", ctx, file, options).Return(job, expectedError)
+
+		service := services.NewMigrationUploadService(cfg, log, repository, jobService)
+		result, err := service.FinalizeUpload(ctx, uploadID, options, false)
+
+		assert.Equal(t, expectedError, err)
+		assert.Equal(t, job, result)
+	})
+
+	t.Run("When the job is not created the upload is released", func(t *testing.T) {
+		expectedError := errors.New(migration.DuplicateFileError + " by migration 7")
+		repository := mocks.NewUploadRepositoryMock()
+		repository.On("Claim", ctx, uploadID).Return(claimed, nil)
+		repository.On("Release", ctx, uploadID).Return(nil)
+
+		jobService := mocks.NewMigrationJobServiceMock()
+		jobService.On("RunFileMigration", ctx, file, options).Return(migration.Job{}, expectedError)
+
+		service := services.NewMigrationUploadService(cfg, log, repository, jobService)
+		_, err := service.FinalizeUpload(ctx, uploadID, options, false)
+
+		assert.Equal(t, expectedError, err)
+		repository.AssertNotCalled(t, "Finish", mock.Anything, mock.Anything, mock.Anything)
+	})
+
+	t.Run("When the upload can't be claimed", func(t *testing.T) {
+		repository := mocks.NewUploadRepositoryMock()
+		repository.On("Claim", ctx, uploadID).Return(upload.Upload{}, errors.New(upload.BusyError))
+
+		jobService := mocks.NewMigrationJobServiceMock()
+
+		service := services.NewMigrationUploadService(cfg, log, repository, jobService)
+		_, err := service.FinalizeUpload(ctx, uploadID, options, true)
+
+		assert.EqualError(t, err, upload.BusyError)
+		jobService.AssertNotCalled(t, "StartFileMigration", mock.Anything, mock.Anything, mock.Anything)
+	})
+}
+
+func Test_MigrationUploadService_OpenUpload(t *testing.T) {
+	ctx := context.TODO()
+	cfg := config.NewConfig()
+	log := logger.NewLogger()
+
+	t.Run("When the upload is missing bytes", func(t *testing.T) {
+		repository := mocks.NewUploadRepositoryMock()
+		repository.On("FindByID", ctx, uploadID).Return(upload.Upload{ID: uploadID, Size: 10, ReceivedBytes: 4}, nil)
+
+		service := services.NewMigrationUploadService(cfg, log, repository, mocks.NewMigrationJobServiceMock())
+		_, err := service.OpenUpload(ctx, uploadID)
+
+		assert.EqualError(t, err, upload.IncompleteError+", 4 of 10 bytes were received")
+	})
+
+	t.Run("When the upload was finalized", func(t *testing.T) {
+		repository := mocks.NewUploadRepositoryMock()
+		repository.On("FindByID", ctx, uploadID).
+			Return(upload.Upload{ID: uploadID, Size: 10, ReceivedBytes: 10, JobID: "5"}, nil)
+
+		service := services.NewMigrationUploadService(cfg, log, repository, mocks.NewMigrationJobServiceMock())
+		_, err := service.OpenUpload(ctx, uploadID)
+
+		assert.EqualError(t, err, upload.FinalizedError)
+	})
+}
@@ -0,0 +1,64 @@
+package services
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/sebastianreh/user-balance-api/internal/domain/outbox"
+	"github.com/sebastianreh/user-balance-api/pkg/logger"
+	"github.com/sebastianreh/user-balance-api/pkg/notifier"
+)
+
+const (
+	notificationServiceName = "NotificationService"
+)
+
+type NotificationService interface {
+	// Messages returns an outbox message for every route of the notification event, to be saved with the change
+	// that raised it
+	Messages(notification notifier.Notification) ([]outbox.Message, error)
+	// Notify saves the messages of a notification that is not saved along with a change
+	Notify(ctx context.Context, notification notifier.Notification) error
+}
+
+type notificationService struct {
+	log              logger.Logger
+	notifier         notifier.Notifier
+	outboxRepository outbox.Repository
+}
+
+func NewNotificationService(log logger.Logger, router notifier.Notifier,
+	outboxRepository outbox.Repository) NotificationService {
+	return &notificationService{
+		log:              log,
+		notifier:         router,
+		outboxRepository: outboxRepository,
+	}
+}
+
+func (s *notificationService) Messages(notification notifier.Notification) ([]outbox.Message, error) {
+	deliveries := s.notifier.Deliveries(notification)
+	messages := make([]outbox.Message, 0, len(deliveries))
+	for _, delivery := range deliveries {
+		description := fmt.Sprintf("%s through %s", notification.Subject, delivery.Channel)
+		message, err := outbox.NewMessage(outbox.KindNotification, description, delivery)
+		if err != nil {
+			err = fmt.Errorf("could not build %s notification, error: %w", notification.Event, err)
+			s.log.ErrorAt(err, notificationServiceName, "Messages")
+			return nil, err
+		}
+
+		messages = append(messages, message)
+	}
+
+	return messages, nil
+}
+
+func (s *notificationService) Notify(ctx context.Context, notification notifier.Notification) error {
+	messages, err := s.Messages(notification)
+	if err != nil || len(messages) == 0 {
+		return err
+	}
+
+	return s.outboxRepository.Save(ctx, messages...)
+}
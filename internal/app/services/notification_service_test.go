@@ -0,0 +1,83 @@
+package services_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"testing"
+
+	"github.com/sebastianreh/user-balance-api/internal/app/services"
+	"github.com/sebastianreh/user-balance-api/internal/domain/outbox"
+	"github.com/sebastianreh/user-balance-api/pkg/logger"
+	"github.com/sebastianreh/user-balance-api/pkg/notifier"
+	"github.com/sebastianreh/user-balance-api/test/mocks"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func Test_NotificationService(t *testing.T) {
+	ctx := context.TODO()
+	log := logger.NewLogger()
+	notification := notifier.Notification{Event: notifier.EventLowBalance, Subject: "Low balance of user 1",
+		Text: "It dropped"}
+	deliveries := []notifier.Delivery{
+		{Channel: notifier.ChannelSMTP, Recipients: []string{"ops@example.com"}, Notification: notification},
+		{Channel: notifier.ChannelSlack, Notification: notification},
+	}
+
+	t.Run("When a notification has a message for every route", func(t *testing.T) {
+		router := mocks.NewNotifierMock()
+		router.On("Deliveries", notification).Return(deliveries)
+
+		service := services.NewNotificationService(log, router, mocks.NewOutboxRepositoryMock())
+		messages, err := service.Messages(notification)
+
+		assert.Nil(t, err)
+		assert.Len(t, messages, 2)
+		assert.Equal(t, outbox.KindNotification, messages[1].Kind)
+		assert.Equal(t, "Low balance of user 1 through slack", messages[1].Description)
+
+		var delivery notifier.Delivery
+		assert.Nil(t, json.Unmarshal(messages[0].Payload, &delivery))
+		assert.Equal(t, deliveries[0], delivery)
+	})
+
+	t.Run("When a notification is saved in the outbox", func(t *testing.T) {
+		router := mocks.NewNotifierMock()
+		router.On("Deliveries", notification).Return(deliveries)
+		repository := mocks.NewOutboxRepositoryMock()
+		repository.On("Save", ctx, mock.MatchedBy(func(messages []outbox.Message) bool {
+			return len(messages) == 2
+		})).Return(nil)
+
+		service := services.NewNotificationService(log, router, repository)
+		err := service.Notify(ctx, notification)
+
+		assert.Nil(t, err)
+		repository.AssertExpectations(t)
+	})
+
+	t.Run("When the event is not routed nothing is saved", func(t *testing.T) {
+		router := mocks.NewNotifierMock()
+		router.On("Deliveries", notification).Return([]notifier.Delivery{})
+		repository := mocks.NewOutboxRepositoryMock()
+
+		service := services.NewNotificationService(log, router, repository)
+		err := service.Notify(ctx, notification)
+
+		assert.Nil(t, err)
+		repository.AssertNotCalled(t, "Save", mock.Anything, mock.Anything)
+	})
+
+	t.Run("When the messages can't be saved", func(t *testing.T) {
+		router := mocks.NewNotifierMock()
+		router.On("Deliveries", notification).Return(deliveries)
+		repository := mocks.NewOutboxRepositoryMock()
+		repository.On("Save", ctx, mock.Anything).Return(errors.New("database error"))
+
+		service := services.NewNotificationService(log, router, repository)
+		err := service.Notify(ctx, notification)
+
+		assert.EqualError(t, err, "database error")
+	})
+}
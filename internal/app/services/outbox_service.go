@@ -0,0 +1,177 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/sebastianreh/user-balance-api/internal/domain/outbox"
+	"github.com/sebastianreh/user-balance-api/internal/domain/webhook"
+	"github.com/sebastianreh/user-balance-api/internal/infrastructure/config"
+	"github.com/sebastianreh/user-balance-api/pkg/email"
+	"github.com/sebastianreh/user-balance-api/pkg/logger"
+	"github.com/sebastianreh/user-balance-api/pkg/notifier"
+)
+
+const (
+	outboxServiceName = "OutboxService"
+)
+
+type OutboxService interface {
+	// Start dispatches the due messages every poll interval until the context is done
+	Start(ctx context.Context)
+	// Dispatch sends the due messages once and returns how many it claimed
+	Dispatch(ctx context.Context) (int, error)
+	ListMessages(ctx context.Context, options outbox.ListOptions) (outbox.ListPage, error)
+	GetMessage(ctx context.Context, messageID string) (outbox.Message, error)
+	// RedriveMessage sends a message that was not sent again right away, even when it ran out of attempts
+	RedriveMessage(ctx context.Context, messageID string) (outbox.Message, error)
+}
+
+type outboxService struct {
+	config         config.Config
+	log            logger.Logger
+	repository     outbox.Repository
+	emailService   email.EmailService
+	notifier       notifier.Notifier
+	webhookService WebhookService
+	backoff        outbox.Backoff
+}
+
+func NewOutboxService(cfg config.Config, log logger.Logger, repository outbox.Repository,
+	emailService email.EmailService, router notifier.Notifier, webhookService WebhookService) OutboxService {
+	return &outboxService{
+		config:         cfg,
+		log:            log,
+		repository:     repository,
+		emailService:   emailService,
+		notifier:       router,
+		webhookService: webhookService,
+		backoff: outbox.Backoff{
+			Base:        cfg.Outbox.BackoffBase,
+			Limit:       cfg.Outbox.BackoffLimit,
+			MaxAttempts: cfg.Outbox.MaxAttempts,
+		},
+	}
+}
+
+func (s *outboxService) Start(ctx context.Context) {
+	ticker := time.NewTicker(s.config.Outbox.PollInterval)
+
+	go func() {
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				s.dispatchDue(ctx)
+			}
+		}
+	}()
+}
+
+// dispatchDue keeps dispatching while whole batches are claimed, so a backlog isn't sent one batch per interval
+func (s *outboxService) dispatchDue(ctx context.Context) {
+	for {
+		claimed, err := s.Dispatch(ctx)
+		if err != nil || claimed < s.config.Outbox.BatchSize {
+			return
+		}
+	}
+}
+
+func (s *outboxService) Dispatch(ctx context.Context) (int, error) {
+	messages, err := s.repository.ClaimDue(ctx, s.config.Outbox.BatchSize, s.config.Outbox.Lease)
+	if err != nil {
+		s.log.ErrorAt(err, outboxServiceName, "Dispatch")
+		return 0, err
+	}
+
+	for _, message := range messages {
+		s.deliver(ctx, message)
+	}
+
+	return len(messages), nil
+}
+
+// deliver sends the message and records the attempt. A failed message waits for its backoff before it's claimed
+// again, and stays failed once it runs out of attempts
+func (s *outboxService) deliver(ctx context.Context, message outbox.Message) {
+	sendErr := s.send(ctx, message)
+	if sendErr == nil {
+		if err := s.repository.MarkSent(ctx, message.ID); err != nil {
+			s.log.ErrorAt(fmt.Errorf("could not mark outbox message %s as sent: %w", message.ID, err),
+				outboxServiceName, "deliver")
+		}
+		return
+	}
+
+	var nextAttemptAt *time.Time
+	if next, retry := s.backoff.NextAttempt(time.Now(), message.Attempts+1); retry {
+		nextAttemptAt = &next
+	}
+
+	s.log.ErrorAt(fmt.Errorf("could not send outbox message %s, attempt %d: %w", message.ID, message.Attempts+1,
+		sendErr), outboxServiceName, "deliver")
+	if err := s.repository.MarkFailed(ctx, message.ID, sendErr.Error(), nextAttemptAt); err != nil {
+		s.log.ErrorAt(fmt.Errorf("could not mark outbox message %s as failed: %w", message.ID, err),
+			outboxServiceName, "deliver")
+	}
+}
+
+func (s *outboxService) send(ctx context.Context, message outbox.Message) error {
+	switch message.Kind {
+	case outbox.KindEmail:
+		var emailMessage email.Message
+		if err := json.Unmarshal(message.Payload, &emailMessage); err != nil {
+			return err
+		}
+
+		return s.emailService.Send(emailMessage)
+	case outbox.KindNotification:
+		var delivery notifier.Delivery
+		if err := json.Unmarshal(message.Payload, &delivery); err != nil {
+			return err
+		}
+
+		return s.notifier.Deliver(ctx, delivery)
+	case outbox.KindWebhook:
+		var delivery webhook.Delivery
+		if err := json.Unmarshal(message.Payload, &delivery); err != nil {
+			return err
+		}
+
+		return s.webhookService.Deliver(ctx, delivery)
+	default:
+		return fmt.Errorf("%s: %s", outbox.UnknownKindError, message.Kind)
+	}
+}
+
+func (s *outboxService) ListMessages(ctx context.Context, options outbox.ListOptions) (outbox.ListPage, error) {
+	limit := options.Limit
+	// One extra message tells if there is a next page
+	options.Limit = limit + 1
+	messages, err := s.repository.List(ctx, options)
+	if err != nil {
+		s.log.ErrorAt(err, outboxServiceName, "ListMessages")
+		return outbox.ListPage{}, err
+	}
+
+	return outbox.NewListPage(messages, limit), nil
+}
+
+func (s *outboxService) GetMessage(ctx context.Context, messageID string) (outbox.Message, error) {
+	return s.repository.FindByID(ctx, messageID)
+}
+
+func (s *outboxService) RedriveMessage(ctx context.Context, messageID string) (outbox.Message, error) {
+	message, err := s.repository.Redrive(ctx, messageID)
+	if err != nil {
+		return message, err
+	}
+
+	s.log.Info("Outbox message redriven", "message_id", message.ID)
+	return message, nil
+}
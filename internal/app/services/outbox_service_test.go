@@ -0,0 +1,219 @@
+package services_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/sebastianreh/user-balance-api/internal/app/services"
+	"github.com/sebastianreh/user-balance-api/internal/domain/outbox"
+	"github.com/sebastianreh/user-balance-api/internal/domain/webhook"
+	"github.com/sebastianreh/user-balance-api/internal/infrastructure/config"
+	"github.com/sebastianreh/user-balance-api/pkg/email"
+	"github.com/sebastianreh/user-balance-api/pkg/logger"
+	"github.com/sebastianreh/user-balance-api/pkg/notifier"
+	"github.com/sebastianreh/user-balance-api/test/mocks"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func Test_OutboxService_Dispatch(t *testing.T) {
+	ctx := context.TODO()
+	cfg := config.NewConfig()
+	log := logger.NewLogger()
+	emailMessage := email.Message{To: []string{"test@example.com"}, Subject: "Migration Report", Text: "body"}
+	payload, _ := json.Marshal(emailMessage)
+	message := outbox.Message{ID: "1", Kind: outbox.KindEmail, Payload: payload, Status: outbox.StatusPending}
+
+	t.Run("When a due email is sent it's marked as sent", func(t *testing.T) {
+		repository := mocks.NewOutboxRepositoryMock()
+		repository.On("ClaimDue", ctx, cfg.Outbox.BatchSize, cfg.Outbox.Lease).Return([]outbox.Message{message}, nil)
+		repository.On("MarkSent", ctx, "1").Return(nil)
+		emailService := mocks.NewEmailServiceMock()
+		emailService.On("Send", emailMessage).Return(nil)
+
+		service := services.NewOutboxService(cfg, log, repository, emailService, mocks.NewNotifierMock(),
+			mocks.NewWebhookServiceMock())
+		claimed, err := service.Dispatch(ctx)
+
+		assert.Nil(t, err)
+		assert.Equal(t, 1, claimed)
+		repository.AssertExpectations(t)
+		emailService.AssertExpectations(t)
+	})
+
+	t.Run("When the email can't be sent it's retried after the backoff", func(t *testing.T) {
+		failed := message
+		failed.Attempts = 2
+		before := time.Now()
+		repository := mocks.NewOutboxRepositoryMock()
+		repository.On("ClaimDue", ctx, cfg.Outbox.BatchSize, cfg.Outbox.Lease).Return([]outbox.Message{failed}, nil)
+		repository.On("MarkFailed", ctx, "1", "smtp unavailable", mock.MatchedBy(func(next *time.Time) bool {
+			// The third attempt waits four times the base
+			wait := 4 * cfg.Outbox.BackoffBase
+			return next != nil && !next.Before(before.Add(wait)) && !next.After(time.Now().Add(wait))
+		})).Return(nil)
+		emailService := mocks.NewEmailServiceMock()
+		emailService.On("Send", emailMessage).Return(errors.New("smtp unavailable"))
+
+		service := services.NewOutboxService(cfg, log, repository, emailService, mocks.NewNotifierMock(),
+			mocks.NewWebhookServiceMock())
+		claimed, err := service.Dispatch(ctx)
+
+		assert.Nil(t, err)
+		assert.Equal(t, 1, claimed)
+		repository.AssertExpectations(t)
+	})
+
+	t.Run("When the email runs out of attempts it's marked as failed", func(t *testing.T) {
+		exhausted := message
+		exhausted.Attempts = cfg.Outbox.MaxAttempts - 1
+		repository := mocks.NewOutboxRepositoryMock()
+		repository.On("ClaimDue", ctx, cfg.Outbox.BatchSize, cfg.Outbox.Lease).Return([]outbox.Message{exhausted}, nil)
+		repository.On("MarkFailed", ctx, "1", "smtp unavailable", (*time.Time)(nil)).Return(nil)
+		emailService := mocks.NewEmailServiceMock()
+		emailService.On("Send", emailMessage).Return(errors.New("smtp unavailable"))
+
+		service := services.NewOutboxService(cfg, log, repository, emailService, mocks.NewNotifierMock(),
+			mocks.NewWebhookServiceMock())
+		_, err := service.Dispatch(ctx)
+
+		assert.Nil(t, err)
+		repository.AssertExpectations(t)
+	})
+
+	t.Run("When a notification is delivered through the channel of its route", func(t *testing.T) {
+		delivery := notifier.Delivery{Channel: notifier.ChannelSlack, Recipients: []string{"@ops"},
+			Notification: notifier.Notification{Event: notifier.EventMigrationFailed, Subject: "Migration 1 failed",
+				Text: "Error: invalid file"}}
+		deliveryPayload, _ := json.Marshal(delivery)
+		notification := outbox.Message{ID: "2", Kind: outbox.KindNotification, Payload: deliveryPayload,
+			Status: outbox.StatusPending}
+		repository := mocks.NewOutboxRepositoryMock()
+		repository.On("ClaimDue", ctx, cfg.Outbox.BatchSize, cfg.Outbox.Lease).
+			Return([]outbox.Message{notification}, nil)
+		repository.On("MarkSent", ctx, "2").Return(nil)
+		router := mocks.NewNotifierMock()
+		router.On("Deliver", ctx, delivery).Return(nil)
+
+		service := services.NewOutboxService(cfg, log, repository, mocks.NewEmailServiceMock(), router,
+			mocks.NewWebhookServiceMock())
+		_, err := service.Dispatch(ctx)
+
+		assert.Nil(t, err)
+		repository.AssertExpectations(t)
+		router.AssertExpectations(t)
+	})
+
+	t.Run("When a due webhook delivery is sent through the webhook service", func(t *testing.T) {
+		delivery := webhook.Delivery{SubscriptionID: "1", EventID: "evt_1", Event: webhook.EventUserDeleted,
+			Body: json.RawMessage(`{"id":"evt_1"}`)}
+		deliveryPayload, _ := json.Marshal(delivery)
+		webhookMessage := outbox.Message{ID: "3", Kind: outbox.KindWebhook, Reference: "webhook:1",
+			Payload: deliveryPayload, Status: outbox.StatusPending}
+		repository := mocks.NewOutboxRepositoryMock()
+		repository.On("ClaimDue", ctx, cfg.Outbox.BatchSize, cfg.Outbox.Lease).
+			Return([]outbox.Message{webhookMessage}, nil)
+		repository.On("MarkFailed", ctx, "3", webhook.InactiveError, mock.Anything).Return(nil)
+		webhookService := mocks.NewWebhookServiceMock()
+		webhookService.On("Deliver", ctx, delivery).Return(errors.New(webhook.InactiveError))
+
+		service := services.NewOutboxService(cfg, log, repository, mocks.NewEmailServiceMock(),
+			mocks.NewNotifierMock(), webhookService)
+		_, err := service.Dispatch(ctx)
+
+		assert.Nil(t, err)
+		repository.AssertExpectations(t)
+		webhookService.AssertExpectations(t)
+	})
+
+	t.Run("When the message kind is unknown the attempt fails", func(t *testing.T) {
+		unknown := message
+		unknown.Kind = "sms"
+		repository := mocks.NewOutboxRepositoryMock()
+		repository.On("ClaimDue", ctx, cfg.Outbox.BatchSize, cfg.Outbox.Lease).Return([]outbox.Message{unknown}, nil)
+		repository.On("MarkFailed", ctx, "1", outbox.UnknownKindError+": sms", mock.Anything).Return(nil)
+		emailService := mocks.NewEmailServiceMock()
+
+		service := services.NewOutboxService(cfg, log, repository, emailService, mocks.NewNotifierMock(),
+			mocks.NewWebhookServiceMock())
+		_, err := service.Dispatch(ctx)
+
+		assert.Nil(t, err)
+		repository.AssertExpectations(t)
+		emailService.AssertNotCalled(t, "Send", mock.Anything)
+	})
+
+	t.Run("When the due messages can't be claimed", func(t *testing.T) {
+		repository := mocks.NewOutboxRepositoryMock()
+		repository.On("ClaimDue", ctx, cfg.Outbox.BatchSize, cfg.Outbox.Lease).
+			Return([]outbox.Message{}, errors.New("database error"))
+
+		service := services.NewOutboxService(cfg, log, repository, mocks.NewEmailServiceMock(),
+			mocks.NewNotifierMock(), mocks.NewWebhookServiceMock())
+		claimed, err := service.Dispatch(ctx)
+
+		assert.EqualError(t, err, "database error")
+		assert.Equal(t, 0, claimed)
+	})
+}
+
+func Test_OutboxService_Messages(t *testing.T) {
+	ctx := context.TODO()
+	cfg := config.NewConfig()
+	log := logger.NewLogger()
+
+	t.Run("When there are more messages than the limit a next cursor is returned", func(t *testing.T) {
+		messages := []outbox.Message{{ID: "3"}, {ID: "2"}, {ID: "1"}}
+		repository := mocks.NewOutboxRepositoryMock()
+		repository.On("List", ctx, outbox.ListOptions{Status: outbox.StatusFailed, Limit: 3}).Return(messages, nil)
+
+		service := services.NewOutboxService(cfg, log, repository, mocks.NewEmailServiceMock(),
+			mocks.NewNotifierMock(), mocks.NewWebhookServiceMock())
+		page, err := service.ListMessages(ctx, outbox.ListOptions{Status: outbox.StatusFailed, Limit: 2})
+
+		assert.Nil(t, err)
+		assert.Equal(t, messages[:2], page.Messages)
+		assert.Equal(t, outbox.Cursor{ID: "2"}.Encode(), page.NextCursor)
+	})
+
+	t.Run("When the last page is listed there is no next cursor", func(t *testing.T) {
+		messages := []outbox.Message{{ID: "1"}}
+		repository := mocks.NewOutboxRepositoryMock()
+		repository.On("List", ctx, outbox.ListOptions{Limit: 3}).Return(messages, nil)
+
+		service := services.NewOutboxService(cfg, log, repository, mocks.NewEmailServiceMock(),
+			mocks.NewNotifierMock(), mocks.NewWebhookServiceMock())
+		page, err := service.ListMessages(ctx, outbox.ListOptions{Limit: 2})
+
+		assert.Nil(t, err)
+		assert.Equal(t, messages, page.Messages)
+		assert.Empty(t, page.NextCursor)
+	})
+
+	t.Run("When a failed message is redriven it's pending again", func(t *testing.T) {
+		redriven := outbox.Message{ID: "1", Status: outbox.StatusPending}
+		repository := mocks.NewOutboxRepositoryMock()
+		repository.On("Redrive", ctx, "1").Return(redriven, nil)
+
+		service := services.NewOutboxService(cfg, log, repository, mocks.NewEmailServiceMock(),
+			mocks.NewNotifierMock(), mocks.NewWebhookServiceMock())
+		message, err := service.RedriveMessage(ctx, "1")
+
+		assert.Nil(t, err)
+		assert.Equal(t, redriven, message)
+	})
+
+	t.Run("When the message to redrive was already sent", func(t *testing.T) {
+		repository := mocks.NewOutboxRepositoryMock()
+		repository.On("Redrive", ctx, "1").Return(outbox.Message{}, errors.New(outbox.NotRedrivableError))
+
+		service := services.NewOutboxService(cfg, log, repository, mocks.NewEmailServiceMock(),
+			mocks.NewNotifierMock(), mocks.NewWebhookServiceMock())
+		_, err := service.RedriveMessage(ctx, "1")
+
+		assert.EqualError(t, err, outbox.NotRedrivableError)
+	})
+}
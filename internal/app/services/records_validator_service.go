@@ -4,8 +4,11 @@ import (
 	"errors"
 	"fmt"
 	"strconv"
+	"strings"
 	"time"
 
+	"github.com/sebastianreh/user-balance-api/internal/domain/migration"
+	"github.com/sebastianreh/user-balance-api/internal/domain/user"
 	customStr "github.com/sebastianreh/user-balance-api/pkg/strings"
 )
 
@@ -13,6 +16,26 @@ const (
 	minRecordLen = 4
 )
 
+// recordValidatorFor checks the email of the records too when the migration creates the missing users, any other
+// user field is free text
+func recordValidatorFor(options migration.Options) func(record []string) error {
+	if !options.CreateMissingUsers {
+		return recordValidator
+	}
+
+	return func(record []string) error {
+		if err := recordValidator(record); err != nil {
+			return err
+		}
+
+		if len(record) > user.RecordEmail && strings.TrimSpace(record[user.RecordEmail]) != "" {
+			return user.ValidateEmail(user.NormalizeEmail(record[user.RecordEmail]))
+		}
+
+		return nil
+	}
+}
+
 func recordValidator(record []string) error {
 	validators := []func(string) error{
 		validateID, validateUserID, validateAmount, validateDatetime,
@@ -2,9 +2,20 @@ package services
 
 import (
 	"context"
+	"fmt"
 
+	"github.com/sebastianreh/user-balance-api/internal/domain/balance"
+	"github.com/sebastianreh/user-balance-api/internal/domain/outbox"
 	"github.com/sebastianreh/user-balance-api/internal/domain/transaction"
+	"github.com/sebastianreh/user-balance-api/internal/domain/user"
+	"github.com/sebastianreh/user-balance-api/internal/domain/webhook"
+	"github.com/sebastianreh/user-balance-api/internal/infrastructure/config"
 	"github.com/sebastianreh/user-balance-api/pkg/logger"
+	"github.com/sebastianreh/user-balance-api/pkg/notifier"
+)
+
+const (
+	transactionServiceName = "TransactionService"
 )
 
 type TransactionService interface {
@@ -12,31 +23,69 @@ type TransactionService interface {
 	UpdateTransaction(ctx context.Context, transactionEntity transaction.Transaction) error
 	GetTransaction(ctx context.Context, transactionID string) (transaction.Transaction, error)
 	DeleteTransaction(ctx context.Context, transactionID string) error
+	ListUserTransactions(ctx context.Context, options transaction.ListOptions) (transaction.ListPage, error)
 }
 
 type transactionService struct {
-	log        logger.Logger
-	repository transaction.Repository
+	config              config.Config
+	log                 logger.Logger
+	repository          transaction.Repository
+	userRepository      user.Repository
+	balanceRepository   balance.Repository
+	notificationService NotificationService
+	webhookService      WebhookService
+	alertService        AlertService
 }
 
-func NewTransactionService(log logger.Logger, repository transaction.Repository) TransactionService {
+func NewTransactionService(cfg config.Config, log logger.Logger, repository transaction.Repository,
+	userRepository user.Repository, balanceRepository balance.Repository,
+	notificationService NotificationService, webhookService WebhookService, alertService AlertService) TransactionService {
 	return &transactionService{
-		log:        log,
-		repository: repository,
+		config:              cfg,
+		log:                 log,
+		repository:          repository,
+		userRepository:      userRepository,
+		balanceRepository:   balanceRepository,
+		notificationService: notificationService,
+		webhookService:      webhookService,
+		alertService:        alertService,
 	}
 }
 
 func (t *transactionService) CreateTransaction(ctx context.Context, transactionEntity transaction.Transaction) error {
-	return t.repository.Save(ctx, transactionEntity)
+	messages, err := t.webhookMessages(ctx, webhook.EventTransactionCreated, transactionEntity)
+	if err != nil {
+		return err
+	}
+
+	if err = t.repository.Save(ctx, transactionEntity, messages...); err != nil {
+		return err
+	}
+
+	t.checkLowBalance(ctx, transactionEntity.UserID, transactionEntity.Amount)
+	t.checkAlerts(ctx, []string{transactionEntity.UserID}, transactionEntity)
+	return nil
 }
 
 func (t *transactionService) UpdateTransaction(ctx context.Context, transactionEntity transaction.Transaction) error {
-	_, err := t.repository.FindByID(ctx, transactionEntity.ID)
+	previous, err := t.repository.FindByID(ctx, transactionEntity.ID)
 	if err != nil {
 		return err
 	}
 
-	return t.repository.Update(ctx, transactionEntity)
+	if err = t.repository.Update(ctx, transactionEntity); err != nil {
+		return err
+	}
+
+	change := transactionEntity.Amount
+	if previous.UserID == transactionEntity.UserID {
+		change -= previous.Amount
+	}
+
+	t.checkLowBalance(ctx, transactionEntity.UserID, change)
+	// Moving a transaction to another user changes the balance of both
+	t.checkAlerts(ctx, []string{transactionEntity.UserID, previous.UserID}, transactionEntity)
+	return nil
 }
 
 func (t *transactionService) GetTransaction(ctx context.Context, transactionID string) (transaction.Transaction, error) {
@@ -46,5 +95,100 @@ func (t *transactionService) GetTransaction(ctx context.Context, transactionID s
 }
 
 func (t *transactionService) DeleteTransaction(ctx context.Context, transactionID string) error {
-	return t.repository.Delete(ctx, transactionID)
+	// The deleted transaction is the data of its event and tells if the balance dropped
+	deleted, err := t.repository.FindByID(ctx, transactionID)
+	if err != nil {
+		return err
+	}
+
+	messages, err := t.webhookMessages(ctx, webhook.EventTransactionDeleted, deleted)
+	if err != nil {
+		return err
+	}
+
+	if err = t.repository.Delete(ctx, transactionID, messages...); err != nil {
+		return err
+	}
+
+	t.checkLowBalance(ctx, deleted.UserID, -deleted.Amount)
+	t.checkAlerts(ctx, []string{deleted.UserID})
+	return nil
+}
+
+func (t *transactionService) ListUserTransactions(ctx context.Context,
+	options transaction.ListOptions) (transaction.ListPage, error) {
+	page := transaction.ListPage{Transactions: make([]transaction.ListItem, 0)}
+	_, err := t.userRepository.FindByID(ctx, options.UserID)
+	if err != nil {
+		return page, err
+	}
+
+	limit := options.Limit
+
+	// One extra row is requested to know if there is a next page without a count query
+	options.Limit = limit + 1
+	items, err := t.repository.List(ctx, options)
+	if err != nil {
+		return page, err
+	}
+
+	if len(items) > limit {
+		items = items[:limit]
+		page.NextCursor = transaction.NewCursor(items[limit-1], options).Encode()
+	}
+
+	page.Transactions = items
+
+	return page, nil
+}
+
+// checkLowBalance raises a low balance notification when a change of the user balance made it drop below the
+// threshold, a balance that was already below it is not notified again. The change is saved when this runs, so
+// errors are only logged
+func (t *transactionService) checkLowBalance(ctx context.Context, userID string, change float64) {
+	threshold := t.config.Notifications.LowBalanceBelow
+	if threshold == nil || change >= 0 {
+		return
+	}
+
+	userBalance, err := t.balanceRepository.FindByUserID(ctx, userID, "", "")
+	if err != nil {
+		t.log.ErrorAt(err, transactionServiceName, "checkLowBalance")
+		return
+	}
+
+	if userBalance.Balance >= *threshold || userBalance.Balance-change < *threshold {
+		return
+	}
+
+	notification := notifier.Notification{
+		Event:   notifier.EventLowBalance,
+		Subject: fmt.Sprintf("Low balance of user %s", userID),
+		Text:    fmt.Sprintf("The balance of user %s dropped to %.2f, below %.2f", userID, userBalance.Balance, *threshold),
+		Data:    map[string]any{"user_id": userID, "balance": userBalance.Balance, "threshold": *threshold},
+	}
+
+	if err = t.notificationService.Notify(ctx, notification); err != nil {
+		t.log.ErrorAt(err, transactionServiceName, "checkLowBalance")
+	}
+}
+
+// webhookMessages builds the webhook deliveries of a change before it's saved, they are saved along with it
+func (t *transactionService) webhookMessages(ctx context.Context, eventType string,
+	transactionEntity transaction.Transaction) ([]outbox.Message, error) {
+	messages, err := t.webhookService.Messages(ctx, eventType, transactionEntity)
+	if err != nil {
+		err = fmt.Errorf("could not build %s event of transaction %s: %w", eventType, transactionEntity.ID, err)
+		t.log.ErrorAt(err, transactionServiceName, "webhookMessages")
+		return nil, err
+	}
+
+	return messages, nil
+}
+
+// checkAlerts fires the alert rules of the users after a change that is already saved, the alert service logs its
+// errors
+func (t *transactionService) checkAlerts(ctx context.Context, userIDs []string,
+	transactions ...transaction.Transaction) {
+	_ = t.alertService.Check(ctx, userIDs, transactions)
 }
@@ -4,54 +4,86 @@ import (
 	"context"
 	"errors"
 	"testing"
+	"time"
 
 	"github.com/sebastianreh/user-balance-api/test/mocks"
 
 	"github.com/sebastianreh/user-balance-api/internal/app/services"
+	"github.com/sebastianreh/user-balance-api/internal/domain/balance"
+	"github.com/sebastianreh/user-balance-api/internal/domain/outbox"
 	"github.com/sebastianreh/user-balance-api/internal/domain/transaction"
+	"github.com/sebastianreh/user-balance-api/internal/domain/user"
+	"github.com/sebastianreh/user-balance-api/internal/domain/webhook"
+	"github.com/sebastianreh/user-balance-api/internal/infrastructure/config"
 	"github.com/sebastianreh/user-balance-api/pkg/logger"
+	"github.com/sebastianreh/user-balance-api/pkg/notifier"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
 )
 
 func TestTransactionService_CreateTransaction(t *testing.T) {
 	ctx := context.TODO()
+	cfg := config.NewConfig()
 	log := logger.NewLogger()
 
-	t.Run("When CreateTransaction succeeds", func(t *testing.T) {
+	t.Run("When CreateTransaction succeeds the deliveries are saved with the transaction", func(t *testing.T) {
 		mockRepo := mocks.NewTransactionRepositoryMock()
-		service := services.NewTransactionService(log, mockRepo)
+		webhookService := mocks.NewWebhookServiceMock()
+		service := services.NewTransactionService(cfg, log, mockRepo, mocks.NewUserRepositoryMock(),
+			mocks.NewBalanceRepositoryMock(), mocks.NewNotificationServiceMock(), webhookService, newAlertService())
 
 		transactionEntity := transaction.Transaction{ID: "1", UserID: "1", Amount: 100}
+		messages := []outbox.Message{{Kind: outbox.KindWebhook, Reference: "webhook:1"}}
 
-		mockRepo.On("Save", ctx, transactionEntity).Return(nil)
+		webhookService.On("Messages", ctx, webhook.EventTransactionCreated, transactionEntity).Return(messages, nil)
+		mockRepo.On("Save", ctx, transactionEntity, messages).Return(nil)
 
 		err := service.CreateTransaction(ctx, transactionEntity)
 		assert.Nil(t, err)
-		mockRepo.AssertCalled(t, "Save", ctx, transactionEntity)
+		mockRepo.AssertCalled(t, "Save", ctx, transactionEntity, messages)
+	})
+
+	t.Run("When the created event deliveries can't be built the transaction is not saved", func(t *testing.T) {
+		mockRepo := mocks.NewTransactionRepositoryMock()
+		webhookService := mocks.NewWebhookServiceMock()
+		service := services.NewTransactionService(cfg, log, mockRepo, mocks.NewUserRepositoryMock(),
+			mocks.NewBalanceRepositoryMock(), mocks.NewNotificationServiceMock(), webhookService, newAlertService())
+
+		transactionEntity := transaction.Transaction{ID: "1", UserID: "1", Amount: 100}
+
+		webhookService.On("Messages", ctx, webhook.EventTransactionCreated, transactionEntity).
+			Return([]outbox.Message{}, errors.New("database error"))
+
+		err := service.CreateTransaction(ctx, transactionEntity)
+		assert.ErrorContains(t, err, "database error")
+		mockRepo.AssertNotCalled(t, "Save", mock.Anything, mock.Anything, mock.Anything)
 	})
 
 	t.Run("When CreateTransaction fails", func(t *testing.T) {
 		mockRepo := mocks.NewTransactionRepositoryMock()
-		service := services.NewTransactionService(log, mockRepo)
+		service := services.NewTransactionService(cfg, log, mockRepo, mocks.NewUserRepositoryMock(),
+			mocks.NewBalanceRepositoryMock(), mocks.NewNotificationServiceMock(), newWebhookService(), newAlertService())
 
 		transactionEntity := transaction.Transaction{ID: "1", UserID: "1", Amount: 100}
 		expectedError := errors.New("repository error")
 
-		mockRepo.On("Save", ctx, transactionEntity).Return(expectedError)
+		mockRepo.On("Save", ctx, transactionEntity, []outbox.Message{}).Return(expectedError)
 
 		err := service.CreateTransaction(ctx, transactionEntity)
 		assert.Equal(t, expectedError, err)
-		mockRepo.AssertCalled(t, "Save", ctx, transactionEntity)
+		mockRepo.AssertCalled(t, "Save", ctx, transactionEntity, []outbox.Message{})
 	})
 }
 
 func TestTransactionService_UpdateTransaction(t *testing.T) {
 	ctx := context.TODO()
+	cfg := config.NewConfig()
 	log := logger.NewLogger()
 
 	t.Run("When UpdateTransaction succeeds", func(t *testing.T) {
 		mockRepo := mocks.NewTransactionRepositoryMock()
-		service := services.NewTransactionService(log, mockRepo)
+		service := services.NewTransactionService(cfg, log, mockRepo, mocks.NewUserRepositoryMock(),
+			mocks.NewBalanceRepositoryMock(), mocks.NewNotificationServiceMock(), newWebhookService(), newAlertService())
 
 		transactionEntity := transaction.Transaction{ID: "1", UserID: "1", Amount: 100}
 
@@ -64,9 +96,26 @@ func TestTransactionService_UpdateTransaction(t *testing.T) {
 		mockRepo.AssertCalled(t, "Update", ctx, transactionEntity)
 	})
 
+	t.Run("When a transaction moved to another user checks the alert rules of both", func(t *testing.T) {
+		mockRepo := mocks.NewTransactionRepositoryMock()
+		alertService := newAlertService()
+		service := services.NewTransactionService(cfg, log, mockRepo, mocks.NewUserRepositoryMock(),
+			mocks.NewBalanceRepositoryMock(), mocks.NewNotificationServiceMock(), newWebhookService(), alertService)
+
+		transactionEntity := transaction.Transaction{ID: "1", UserID: "2", Amount: -1500}
+
+		mockRepo.On("FindByID", ctx, "1").Return(transaction.Transaction{ID: "1", UserID: "1", Amount: -1500}, nil)
+		mockRepo.On("Update", ctx, transactionEntity).Return(nil)
+
+		err := service.UpdateTransaction(ctx, transactionEntity)
+		assert.Nil(t, err)
+		alertService.AssertCalled(t, "Check", ctx, []string{"2", "1"}, []transaction.Transaction{transactionEntity})
+	})
+
 	t.Run("When FindByID fails in UpdateTransaction", func(t *testing.T) {
 		mockRepo := mocks.NewTransactionRepositoryMock()
-		service := services.NewTransactionService(log, mockRepo)
+		service := services.NewTransactionService(cfg, log, mockRepo, mocks.NewUserRepositoryMock(),
+			mocks.NewBalanceRepositoryMock(), mocks.NewNotificationServiceMock(), newWebhookService(), newAlertService())
 
 		transactionEntity := transaction.Transaction{ID: "1", UserID: "1", Amount: 100}
 		expectedError := errors.New("transaction not found")
@@ -80,7 +129,8 @@ func TestTransactionService_UpdateTransaction(t *testing.T) {
 
 	t.Run("When Update fails in UpdateTransaction", func(t *testing.T) {
 		mockRepo := mocks.NewTransactionRepositoryMock()
-		service := services.NewTransactionService(log, mockRepo)
+		service := services.NewTransactionService(cfg, log, mockRepo, mocks.NewUserRepositoryMock(),
+			mocks.NewBalanceRepositoryMock(), mocks.NewNotificationServiceMock(), newWebhookService(), newAlertService())
 
 		transactionEntity := transaction.Transaction{ID: "1", UserID: "1", Amount: 100}
 		expectedError := errors.New("repository error")
@@ -97,11 +147,13 @@ func TestTransactionService_UpdateTransaction(t *testing.T) {
 
 func TestTransactionService_GetTransaction(t *testing.T) {
 	ctx := context.TODO()
+	cfg := config.NewConfig()
 	log := logger.NewLogger()
 
 	t.Run("When GetTransaction succeeds", func(t *testing.T) {
 		mockRepo := mocks.NewTransactionRepositoryMock()
-		service := services.NewTransactionService(log, mockRepo)
+		service := services.NewTransactionService(cfg, log, mockRepo, mocks.NewUserRepositoryMock(),
+			mocks.NewBalanceRepositoryMock(), mocks.NewNotificationServiceMock(), newWebhookService(), newAlertService())
 
 		transactionEntity := transaction.Transaction{ID: "1", UserID: "1", Amount: 100}
 
@@ -115,7 +167,8 @@ func TestTransactionService_GetTransaction(t *testing.T) {
 
 	t.Run("When GetTransaction fails with not found error", func(t *testing.T) {
 		mockRepo := mocks.NewTransactionRepositoryMock()
-		service := services.NewTransactionService(log, mockRepo)
+		service := services.NewTransactionService(cfg, log, mockRepo, mocks.NewUserRepositoryMock(),
+			mocks.NewBalanceRepositoryMock(), mocks.NewNotificationServiceMock(), newWebhookService(), newAlertService())
 
 		expectedError := errors.New(transaction.NotFoundError)
 
@@ -129,7 +182,8 @@ func TestTransactionService_GetTransaction(t *testing.T) {
 
 	t.Run("When GetTransaction fails with not found error because of logic deletion", func(t *testing.T) {
 		mockRepo := mocks.NewTransactionRepositoryMock()
-		service := services.NewTransactionService(log, mockRepo)
+		service := services.NewTransactionService(cfg, log, mockRepo, mocks.NewUserRepositoryMock(),
+			mocks.NewBalanceRepositoryMock(), mocks.NewNotificationServiceMock(), newWebhookService(), newAlertService())
 
 		expectedError := errors.New(transaction.NotFoundError)
 
@@ -144,29 +198,225 @@ func TestTransactionService_GetTransaction(t *testing.T) {
 
 func TestTransactionService_DeleteTransaction(t *testing.T) {
 	ctx := context.TODO()
+	cfg := config.NewConfig()
 	log := logger.NewLogger()
 
 	t.Run("When DeleteTransaction succeeds", func(t *testing.T) {
 		mockRepo := mocks.NewTransactionRepositoryMock()
-		service := services.NewTransactionService(log, mockRepo)
+		webhookService := mocks.NewWebhookServiceMock()
+		service := services.NewTransactionService(cfg, log, mockRepo, mocks.NewUserRepositoryMock(),
+			mocks.NewBalanceRepositoryMock(), mocks.NewNotificationServiceMock(), webhookService, newAlertService())
 
-		mockRepo.On("Delete", ctx, "1").Return(nil)
+		deleted := transaction.Transaction{ID: "1", UserID: "1", Amount: 100}
+		messages := []outbox.Message{{Kind: outbox.KindWebhook, Reference: "webhook:1"}}
+		mockRepo.On("FindByID", ctx, "1").Return(deleted, nil)
+		webhookService.On("Messages", ctx, webhook.EventTransactionDeleted, deleted).Return(messages, nil)
+		mockRepo.On("Delete", ctx, "1", messages).Return(nil)
 
 		err := service.DeleteTransaction(ctx, "1")
 		assert.Nil(t, err)
-		mockRepo.AssertCalled(t, "Delete", ctx, "1")
+		mockRepo.AssertCalled(t, "Delete", ctx, "1", messages)
 	})
 
 	t.Run("When FindByID fails in DeleteTransaction", func(t *testing.T) {
 		mockRepo := mocks.NewTransactionRepositoryMock()
-		service := services.NewTransactionService(log, mockRepo)
+		service := services.NewTransactionService(cfg, log, mockRepo, mocks.NewUserRepositoryMock(),
+			mocks.NewBalanceRepositoryMock(), mocks.NewNotificationServiceMock(), newWebhookService(), newAlertService())
 
 		expectedError := errors.New("transaction not found")
 
-		mockRepo.On("Delete", ctx, "1").Return(expectedError)
+		mockRepo.On("FindByID", ctx, "1").Return(transaction.Transaction{}, expectedError)
 
 		err := service.DeleteTransaction(ctx, "1")
 		assert.Equal(t, expectedError, err)
-		mockRepo.AssertCalled(t, "Delete", ctx, "1")
+		mockRepo.AssertNotCalled(t, "Delete", mock.Anything, mock.Anything, mock.Anything)
+	})
+
+	t.Run("When Delete fails in DeleteTransaction", func(t *testing.T) {
+		mockRepo := mocks.NewTransactionRepositoryMock()
+		alertService := newAlertService()
+		service := services.NewTransactionService(cfg, log, mockRepo, mocks.NewUserRepositoryMock(),
+			mocks.NewBalanceRepositoryMock(), mocks.NewNotificationServiceMock(), newWebhookService(), alertService)
+
+		expectedError := errors.New("repository error")
+
+		mockRepo.On("FindByID", ctx, "1").Return(transaction.Transaction{ID: "1", UserID: "1"}, nil)
+		mockRepo.On("Delete", ctx, "1", []outbox.Message{}).Return(expectedError)
+
+		err := service.DeleteTransaction(ctx, "1")
+		assert.Equal(t, expectedError, err)
+		alertService.AssertNotCalled(t, "Check", mock.Anything, mock.Anything, mock.Anything)
+	})
+
+	t.Run("When the deleted event deliveries can't be built the transaction is not deleted", func(t *testing.T) {
+		mockRepo := mocks.NewTransactionRepositoryMock()
+		webhookService := mocks.NewWebhookServiceMock()
+		service := services.NewTransactionService(cfg, log, mockRepo, mocks.NewUserRepositoryMock(),
+			mocks.NewBalanceRepositoryMock(), mocks.NewNotificationServiceMock(), webhookService, newAlertService())
+
+		deleted := transaction.Transaction{ID: "1", UserID: "1", Amount: 100}
+		mockRepo.On("FindByID", ctx, "1").Return(deleted, nil)
+		webhookService.On("Messages", ctx, webhook.EventTransactionDeleted, deleted).
+			Return([]outbox.Message{}, errors.New("database error"))
+
+		err := service.DeleteTransaction(ctx, "1")
+		assert.ErrorContains(t, err, "database error")
+		mockRepo.AssertNotCalled(t, "Delete", mock.Anything, mock.Anything, mock.Anything)
+	})
+}
+
+func TestTransactionService_ListUserTransactions(t *testing.T) {
+	ctx := context.TODO()
+	cfg := config.NewConfig()
+	log := logger.NewLogger()
+	now := time.Now()
+
+	t.Run("When ListUserTransactions returns a page with a next cursor", func(t *testing.T) {
+		mockRepo := mocks.NewTransactionRepositoryMock()
+		userRepo := mocks.NewUserRepositoryMock()
+		service := services.NewTransactionService(cfg, log, mockRepo, userRepo,
+			mocks.NewBalanceRepositoryMock(), mocks.NewNotificationServiceMock(), newWebhookService(), newAlertService())
+
+		options := transaction.ListOptions{UserID: "1", SortBy: transaction.SortByDate,
+			SortOrder: transaction.SortAsc, Limit: 1}
+		repositoryOptions := options
+		repositoryOptions.Limit = 2
+		items := []transaction.ListItem{
+			{Transaction: transaction.Transaction{ID: "1", UserID: "1", Amount: 100, DateTime: &now}},
+			{Transaction: transaction.Transaction{ID: "2", UserID: "1", Amount: -50, DateTime: &now}},
+		}
+
+		userRepo.On("FindByID", ctx, "1").Return(user.User{ID: "1"}, nil)
+		mockRepo.On("List", ctx, repositoryOptions).Return(items, nil)
+
+		page, err := service.ListUserTransactions(ctx, options)
+		assert.Nil(t, err)
+		assert.Len(t, page.Transactions, 1)
+
+		cursor, err := transaction.DecodeCursor(page.NextCursor, transaction.SortByDate, transaction.SortAsc)
+		assert.Nil(t, err)
+		assert.Equal(t, "1", cursor.ID)
+	})
+
+	t.Run("When ListUserTransactions returns the last page", func(t *testing.T) {
+		mockRepo := mocks.NewTransactionRepositoryMock()
+		userRepo := mocks.NewUserRepositoryMock()
+		service := services.NewTransactionService(cfg, log, mockRepo, userRepo,
+			mocks.NewBalanceRepositoryMock(), mocks.NewNotificationServiceMock(), newWebhookService(), newAlertService())
+
+		items := []transaction.ListItem{
+			{Transaction: transaction.Transaction{ID: "1", UserID: "1", Amount: 100, DateTime: &now}},
+		}
+
+		userRepo.On("FindByID", ctx, "1").Return(user.User{ID: "1"}, nil)
+		mockRepo.On("List", ctx, mock.Anything).Return(items, nil)
+
+		page, err := service.ListUserTransactions(ctx, transaction.ListOptions{UserID: "1", Limit: 10})
+		assert.Nil(t, err)
+		assert.Len(t, page.Transactions, 1)
+		assert.Empty(t, page.NextCursor)
+	})
+
+	t.Run("When the user is not found", func(t *testing.T) {
+		mockRepo := mocks.NewTransactionRepositoryMock()
+		userRepo := mocks.NewUserRepositoryMock()
+		service := services.NewTransactionService(cfg, log, mockRepo, userRepo,
+			mocks.NewBalanceRepositoryMock(), mocks.NewNotificationServiceMock(), newWebhookService(), newAlertService())
+
+		expectedError := errors.New(user.NotFoundError)
+		userRepo.On("FindByID", ctx, "1").Return(user.User{}, expectedError)
+
+		_, err := service.ListUserTransactions(ctx, transaction.ListOptions{UserID: "1", Limit: 10})
+		assert.Equal(t, expectedError, err)
+		mockRepo.AssertNotCalled(t, "List", mock.Anything, mock.Anything)
+	})
+
+	t.Run("When repository List fails", func(t *testing.T) {
+		mockRepo := mocks.NewTransactionRepositoryMock()
+		userRepo := mocks.NewUserRepositoryMock()
+		service := services.NewTransactionService(cfg, log, mockRepo, userRepo,
+			mocks.NewBalanceRepositoryMock(), mocks.NewNotificationServiceMock(), newWebhookService(), newAlertService())
+
+		expectedError := errors.New("repository error")
+		userRepo.On("FindByID", ctx, "1").Return(user.User{ID: "1"}, nil)
+		mockRepo.On("List", ctx, mock.Anything).Return([]transaction.ListItem{}, expectedError)
+
+		_, err := service.ListUserTransactions(ctx, transaction.ListOptions{UserID: "1", Limit: 10})
+		assert.Equal(t, expectedError, err)
+	})
+}
+
+func TestTransactionService_LowBalance(t *testing.T) {
+	ctx := context.TODO()
+	log := logger.NewLogger()
+	threshold := 100.0
+	cfg := config.NewConfig()
+	cfg.Notifications.LowBalanceBelow = &threshold
+	isLowBalance := mock.MatchedBy(func(notification notifier.Notification) bool {
+		return notification.Event == notifier.EventLowBalance && notification.Data["user_id"] == "1"
+	})
+
+	t.Run("When a debit drops the balance below the threshold a notification is raised", func(t *testing.T) {
+		transactionEntity := transaction.Transaction{ID: "1", UserID: "1", Amount: -80}
+		mockRepo := mocks.NewTransactionRepositoryMock()
+		mockRepo.On("Save", ctx, transactionEntity, []outbox.Message{}).Return(nil)
+		balanceRepo := mocks.NewBalanceRepositoryMock()
+		balanceRepo.On("FindByUserID", ctx, "1", "", "").Return(balance.UserBalance{Balance: 50}, nil)
+		notificationService := mocks.NewNotificationServiceMock()
+		notificationService.On("Notify", ctx, isLowBalance).Return(nil)
+
+		service := services.NewTransactionService(cfg, log, mockRepo, mocks.NewUserRepositoryMock(), balanceRepo,
+			notificationService, newWebhookService(), newAlertService())
+		err := service.CreateTransaction(ctx, transactionEntity)
+
+		assert.Nil(t, err)
+		notificationService.AssertExpectations(t)
+	})
+
+	t.Run("When the balance was already below the threshold it's not notified again", func(t *testing.T) {
+		transactionEntity := transaction.Transaction{ID: "1", UserID: "1", Amount: -20}
+		mockRepo := mocks.NewTransactionRepositoryMock()
+		mockRepo.On("Save", ctx, transactionEntity, []outbox.Message{}).Return(nil)
+		balanceRepo := mocks.NewBalanceRepositoryMock()
+		balanceRepo.On("FindByUserID", ctx, "1", "", "").Return(balance.UserBalance{Balance: 50}, nil)
+		notificationService := mocks.NewNotificationServiceMock()
+
+		service := services.NewTransactionService(cfg, log, mockRepo, mocks.NewUserRepositoryMock(), balanceRepo,
+			notificationService, newWebhookService(), newAlertService())
+		err := service.CreateTransaction(ctx, transactionEntity)
+
+		assert.Nil(t, err)
+		notificationService.AssertNotCalled(t, "Notify", mock.Anything, mock.Anything)
+	})
+
+	t.Run("When a deleted credit drops the balance below the threshold a notification is raised", func(t *testing.T) {
+		mockRepo := mocks.NewTransactionRepositoryMock()
+		mockRepo.On("FindByID", ctx, "1").Return(transaction.Transaction{ID: "1", UserID: "1", Amount: 80}, nil)
+		mockRepo.On("Delete", ctx, "1", []outbox.Message{}).Return(nil)
+		balanceRepo := mocks.NewBalanceRepositoryMock()
+		balanceRepo.On("FindByUserID", ctx, "1", "", "").Return(balance.UserBalance{Balance: 50}, nil)
+		notificationService := mocks.NewNotificationServiceMock()
+		notificationService.On("Notify", ctx, isLowBalance).Return(nil)
+
+		service := services.NewTransactionService(cfg, log, mockRepo, mocks.NewUserRepositoryMock(), balanceRepo,
+			notificationService, newWebhookService(), newAlertService())
+		err := service.DeleteTransaction(ctx, "1")
+
+		assert.Nil(t, err)
+		notificationService.AssertExpectations(t)
+	})
+
+	t.Run("When a credit is saved the balance is not read", func(t *testing.T) {
+		transactionEntity := transaction.Transaction{ID: "1", UserID: "1", Amount: 80}
+		mockRepo := mocks.NewTransactionRepositoryMock()
+		mockRepo.On("Save", ctx, transactionEntity, []outbox.Message{}).Return(nil)
+		balanceRepo := mocks.NewBalanceRepositoryMock()
+
+		service := services.NewTransactionService(cfg, log, mockRepo, mocks.NewUserRepositoryMock(), balanceRepo,
+			mocks.NewNotificationServiceMock(), newWebhookService(), newAlertService())
+		err := service.CreateTransaction(ctx, transactionEntity)
+
+		assert.Nil(t, err)
+		balanceRepo.AssertNotCalled(t, "FindByUserID", mock.Anything, mock.Anything, mock.Anything, mock.Anything)
 	})
 }
@@ -2,31 +2,42 @@ package services
 
 import (
 	"context"
+	"fmt"
 
 	"github.com/sebastianreh/user-balance-api/internal/domain/user"
+	"github.com/sebastianreh/user-balance-api/internal/domain/webhook"
 	"github.com/sebastianreh/user-balance-api/pkg/logger"
 )
 
+const (
+	userServiceName = "UserService"
+)
+
 type UserService interface {
 	CreateUser(ctx context.Context, userEntity user.User) (string, error)
 	UpdateUser(ctx context.Context, userEntity user.User) error
 	GetUser(ctx context.Context, userID string) (user.User, error)
+	GetUserByEmail(ctx context.Context, email string) (user.User, error)
+	ListUsers(ctx context.Context, options user.ListOptions) (user.ListPage, error)
 	DeleteUser(ctx context.Context, userID string) error
 }
 
 type userService struct {
-	log        logger.Logger
-	repository user.Repository
+	log            logger.Logger
+	repository     user.Repository
+	webhookService WebhookService
 }
 
-func NewUserService(log logger.Logger, repository user.Repository) UserService {
+func NewUserService(log logger.Logger, repository user.Repository, webhookService WebhookService) UserService {
 	return &userService{
-		log:        log,
-		repository: repository,
+		log:            log,
+		repository:     repository,
+		webhookService: webhookService,
 	}
 }
 
 func (u *userService) CreateUser(ctx context.Context, userEntity user.User) (string, error) {
+	userEntity.Email = user.NormalizeEmail(userEntity.Email)
 	return u.repository.Save(ctx, userEntity)
 }
 
@@ -36,6 +47,7 @@ func (u *userService) UpdateUser(ctx context.Context, userEntity user.User) erro
 		return err
 	}
 
+	userEntity.Email = user.NormalizeEmail(userEntity.Email)
 	err = u.repository.Update(ctx, userEntity)
 	return err
 }
@@ -46,7 +58,41 @@ func (u *userService) GetUser(ctx context.Context, userID string) (user.User, er
 	return userEntity, err
 }
 
+func (u *userService) GetUserByEmail(ctx context.Context, email string) (user.User, error) {
+	var userEntity user.User
+	userEntity, err := u.repository.FindByEmail(ctx, user.NormalizeEmail(email))
+	return userEntity, err
+}
+
+func (u *userService) ListUsers(ctx context.Context, options user.ListOptions) (user.ListPage, error) {
+	page := user.ListPage{Users: make([]user.ListItem, 0)}
+	limit := options.Limit
+
+	// One extra row is requested to know if there is a next page without a count query
+	options.Limit = limit + 1
+	items, err := u.repository.List(ctx, options)
+	if err != nil {
+		return page, err
+	}
+
+	if len(items) > limit {
+		items = items[:limit]
+		page.NextCursor = user.NewCursor(items[limit-1], options).Encode()
+	}
+
+	page.Users = items
+
+	return page, nil
+}
+
 func (u *userService) DeleteUser(ctx context.Context, userID string) error {
-	err := u.repository.Delete(ctx, userID)
-	return err
+	// The deliveries are saved along with the delete, so the event is sent only when the user is deleted
+	messages, err := u.webhookService.Messages(ctx, webhook.EventUserDeleted, map[string]string{"id": userID})
+	if err != nil {
+		err = fmt.Errorf("could not build %s event of user %s: %w", webhook.EventUserDeleted, userID, err)
+		u.log.ErrorAt(err, userServiceName, "DeleteUser")
+		return err
+	}
+
+	return u.repository.Delete(ctx, userID, messages...)
 }
@@ -6,10 +6,13 @@ import (
 	"testing"
 
 	"github.com/sebastianreh/user-balance-api/internal/app/services"
+	"github.com/sebastianreh/user-balance-api/internal/domain/outbox"
 	"github.com/sebastianreh/user-balance-api/internal/domain/user"
+	"github.com/sebastianreh/user-balance-api/internal/domain/webhook"
 	"github.com/sebastianreh/user-balance-api/pkg/logger"
 	"github.com/sebastianreh/user-balance-api/test/mocks"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
 )
 
 func TestUserService_CreateUser(t *testing.T) {
@@ -18,7 +21,7 @@ func TestUserService_CreateUser(t *testing.T) {
 
 	t.Run("When CreateUser succeeds", func(t *testing.T) {
 		mockRepo := mocks.NewUserRepositoryMock()
-		service := services.NewUserService(log, mockRepo)
+		service := services.NewUserService(log, mockRepo, newWebhookService())
 
 		userEntity := user.User{ID: "1", FirstName: "user", LastName: "lastname", Email: "user@email.com"}
 
@@ -32,7 +35,7 @@ func TestUserService_CreateUser(t *testing.T) {
 
 	t.Run("When CreateUser fails", func(t *testing.T) {
 		mockRepo := mocks.NewUserRepositoryMock()
-		service := services.NewUserService(log, mockRepo)
+		service := services.NewUserService(log, mockRepo, newWebhookService())
 
 		userEntity := user.User{ID: "1", FirstName: "user", LastName: "lastname", Email: "user@email.com"}
 		expectedError := errors.New("repository error")
@@ -44,6 +47,35 @@ func TestUserService_CreateUser(t *testing.T) {
 		assert.Empty(t, id)
 		mockRepo.AssertCalled(t, "Save", ctx, userEntity)
 	})
+
+	t.Run("When CreateUser normalizes the email", func(t *testing.T) {
+		mockRepo := mocks.NewUserRepositoryMock()
+		service := services.NewUserService(log, mockRepo, newWebhookService())
+
+		userEntity := user.User{FirstName: "user", LastName: "lastname", Email: " User@Email.COM "}
+		normalizedUser := user.User{FirstName: "user", LastName: "lastname", Email: "user@email.com"}
+
+		mockRepo.On("Save", ctx, normalizedUser).Return("1", nil)
+
+		userID, err := service.CreateUser(ctx, userEntity)
+		assert.Nil(t, err)
+		assert.Equal(t, "1", userID)
+		mockRepo.AssertCalled(t, "Save", ctx, normalizedUser)
+	})
+
+	t.Run("When CreateUser fails with a duplicated email", func(t *testing.T) {
+		mockRepo := mocks.NewUserRepositoryMock()
+		service := services.NewUserService(log, mockRepo, newWebhookService())
+
+		userEntity := user.User{FirstName: "user", LastName: "lastname", Email: "user@email.com"}
+		expectedError := errors.New(user.DuplicateEmailError)
+
+		mockRepo.On("Save", ctx, userEntity).Return("", expectedError)
+
+		id, err := service.CreateUser(ctx, userEntity)
+		assert.Equal(t, expectedError, err)
+		assert.Empty(t, id)
+	})
 }
 
 func TestUserService_UpdateUser(t *testing.T) {
@@ -52,7 +84,7 @@ func TestUserService_UpdateUser(t *testing.T) {
 
 	t.Run("When UpdateUser succeeds", func(t *testing.T) {
 		mockRepo := mocks.NewUserRepositoryMock()
-		service := services.NewUserService(log, mockRepo)
+		service := services.NewUserService(log, mockRepo, newWebhookService())
 
 		userEntity := user.User{ID: "1", FirstName: "user", LastName: "lastname", Email: "user@email.com"}
 
@@ -67,7 +99,7 @@ func TestUserService_UpdateUser(t *testing.T) {
 
 	t.Run("When FindByID fails in UpdateUser", func(t *testing.T) {
 		mockRepo := mocks.NewUserRepositoryMock()
-		service := services.NewUserService(log, mockRepo)
+		service := services.NewUserService(log, mockRepo, newWebhookService())
 
 		userEntity := user.User{ID: "1", FirstName: "user", LastName: "lastname", Email: "user@email.com"}
 		expectedError := errors.New("user not found")
@@ -81,7 +113,7 @@ func TestUserService_UpdateUser(t *testing.T) {
 
 	t.Run("When Save fails in UpdateUser", func(t *testing.T) {
 		mockRepo := mocks.NewUserRepositoryMock()
-		service := services.NewUserService(log, mockRepo)
+		service := services.NewUserService(log, mockRepo, newWebhookService())
 
 		userEntity := user.User{ID: "1", FirstName: "user", LastName: "lastname", Email: "user@email.com"}
 		expectedError := errors.New("repository error")
@@ -102,7 +134,7 @@ func TestUserService_GetUser(t *testing.T) {
 
 	t.Run("When GetUser succeeds", func(t *testing.T) {
 		mockRepo := mocks.NewUserRepositoryMock()
-		service := services.NewUserService(log, mockRepo)
+		service := services.NewUserService(log, mockRepo, newWebhookService())
 
 		userEntity := user.User{ID: "1", FirstName: "user", LastName: "lastname", Email: "user@email.com"}
 
@@ -116,7 +148,7 @@ func TestUserService_GetUser(t *testing.T) {
 
 	t.Run("When GetUser fails with not found error", func(t *testing.T) {
 		mockRepo := mocks.NewUserRepositoryMock()
-		service := services.NewUserService(log, mockRepo)
+		service := services.NewUserService(log, mockRepo, newWebhookService())
 
 		expectedError := errors.New(user.NotFoundError)
 
@@ -130,7 +162,7 @@ func TestUserService_GetUser(t *testing.T) {
 
 	t.Run("When GetUser fails with not found error because of logic deletion", func(t *testing.T) {
 		mockRepo := mocks.NewUserRepositoryMock()
-		service := services.NewUserService(log, mockRepo)
+		service := services.NewUserService(log, mockRepo, newWebhookService())
 
 		expectedError := errors.New(user.NotFoundError)
 
@@ -143,31 +175,136 @@ func TestUserService_GetUser(t *testing.T) {
 	})
 }
 
+func TestUserService_GetUserByEmail(t *testing.T) {
+	ctx := context.TODO()
+	log := logger.NewLogger()
+
+	t.Run("When GetUserByEmail succeeds", func(t *testing.T) {
+		mockRepo := mocks.NewUserRepositoryMock()
+		service := services.NewUserService(log, mockRepo, newWebhookService())
+
+		userEntity := user.User{ID: "1", FirstName: "user", LastName: "lastname", Email: "user@email.com"}
+
+		mockRepo.On("FindByEmail", ctx, "user@email.com").Return(userEntity, nil)
+
+		returnedUser, err := service.GetUserByEmail(ctx, "User@Email.com")
+		assert.Nil(t, err)
+		assert.Equal(t, userEntity, returnedUser)
+		mockRepo.AssertCalled(t, "FindByEmail", ctx, "user@email.com")
+	})
+
+	t.Run("When GetUserByEmail fails with not found error", func(t *testing.T) {
+		mockRepo := mocks.NewUserRepositoryMock()
+		service := services.NewUserService(log, mockRepo, newWebhookService())
+
+		expectedError := errors.New(user.NotFoundError)
+
+		mockRepo.On("FindByEmail", ctx, "user@email.com").Return(user.User{}, expectedError)
+
+		returnedUser, err := service.GetUserByEmail(ctx, "user@email.com")
+		assert.Equal(t, expectedError, err)
+		assert.Equal(t, user.User{}, returnedUser)
+	})
+}
+
+func TestUserService_ListUsers(t *testing.T) {
+	ctx := context.TODO()
+	log := logger.NewLogger()
+
+	t.Run("When ListUsers returns a page with a next cursor", func(t *testing.T) {
+		mockRepo := mocks.NewUserRepositoryMock()
+		service := services.NewUserService(log, mockRepo, newWebhookService())
+
+		options := user.ListOptions{SortBy: user.SortByID, SortOrder: user.SortAsc, Limit: 2}
+		repositoryOptions := options
+		repositoryOptions.Limit = 3
+		items := []user.ListItem{
+			{ID: "1"},
+			{ID: "2"},
+			{ID: "3"},
+		}
+
+		mockRepo.On("List", ctx, repositoryOptions).Return(items, nil)
+
+		page, err := service.ListUsers(ctx, options)
+		assert.Nil(t, err)
+		assert.Len(t, page.Users, 2)
+		assert.NotEmpty(t, page.NextCursor)
+
+		cursor, err := user.DecodeCursor(page.NextCursor, user.SortByID, user.SortAsc)
+		assert.Nil(t, err)
+		assert.Equal(t, "2", cursor.ID)
+	})
+
+	t.Run("When ListUsers returns the last page", func(t *testing.T) {
+		mockRepo := mocks.NewUserRepositoryMock()
+		service := services.NewUserService(log, mockRepo, newWebhookService())
+
+		options := user.ListOptions{SortBy: user.SortByID, SortOrder: user.SortAsc, Limit: 2}
+		items := []user.ListItem{{ID: "1"}}
+
+		mockRepo.On("List", ctx, mock.Anything).Return(items, nil)
+
+		page, err := service.ListUsers(ctx, options)
+		assert.Nil(t, err)
+		assert.Len(t, page.Users, 1)
+		assert.Empty(t, page.NextCursor)
+	})
+
+	t.Run("When repository List fails", func(t *testing.T) {
+		mockRepo := mocks.NewUserRepositoryMock()
+		service := services.NewUserService(log, mockRepo, newWebhookService())
+
+		expectedError := errors.New("repository error")
+		mockRepo.On("List", ctx, mock.Anything).Return([]user.ListItem{}, expectedError)
+
+		page, err := service.ListUsers(ctx, user.ListOptions{Limit: 2})
+		assert.Equal(t, expectedError, err)
+		assert.Empty(t, page.Users)
+	})
+}
+
 func TestUserService_DeleteUser(t *testing.T) {
 	ctx := context.TODO()
 	log := logger.NewLogger()
 
-	t.Run("When DeleteUser succeeds", func(t *testing.T) {
+	t.Run("When DeleteUser succeeds the deliveries are saved with the delete", func(t *testing.T) {
 		mockRepo := mocks.NewUserRepositoryMock()
-		service := services.NewUserService(log, mockRepo)
+		webhookService := mocks.NewWebhookServiceMock()
+		service := services.NewUserService(log, mockRepo, webhookService)
 
-		mockRepo.On("Delete", ctx, "1").Return(nil)
+		messages := []outbox.Message{{Kind: outbox.KindWebhook, Reference: "webhook:1"}}
+		webhookService.On("Messages", ctx, webhook.EventUserDeleted, map[string]string{"id": "1"}).Return(messages, nil)
+		mockRepo.On("Delete", ctx, "1", messages).Return(nil)
 
 		err := service.DeleteUser(ctx, "1")
 		assert.Nil(t, err)
-		mockRepo.AssertCalled(t, "Delete", ctx, "1")
+		mockRepo.AssertCalled(t, "Delete", ctx, "1", messages)
 	})
 
 	t.Run("When repository Delete fails", func(t *testing.T) {
 		mockRepo := mocks.NewUserRepositoryMock()
-		service := services.NewUserService(log, mockRepo)
+		service := services.NewUserService(log, mockRepo, newWebhookService())
 
 		expectedError := errors.New("user not found")
 
-		mockRepo.On("Delete", ctx, "1").Return(expectedError)
+		mockRepo.On("Delete", ctx, "1", []outbox.Message{}).Return(expectedError)
 
 		err := service.DeleteUser(ctx, "1")
 		assert.Equal(t, expectedError, err)
-		mockRepo.AssertCalled(t, "Delete", ctx, "1")
+		mockRepo.AssertCalled(t, "Delete", ctx, "1", []outbox.Message{})
+	})
+
+	t.Run("When the deleted event deliveries can't be built the user is not deleted", func(t *testing.T) {
+		mockRepo := mocks.NewUserRepositoryMock()
+		webhookService := mocks.NewWebhookServiceMock()
+		service := services.NewUserService(log, mockRepo, webhookService)
+
+		webhookService.On("Messages", ctx, webhook.EventUserDeleted, mock.Anything).
+			Return([]outbox.Message{}, errors.New("database error"))
+
+		err := service.DeleteUser(ctx, "1")
+		assert.ErrorContains(t, err, "database error")
+		mockRepo.AssertNotCalled(t, "Delete", mock.Anything, mock.Anything, mock.Anything)
 	})
 }
@@ -0,0 +1,201 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/sebastianreh/user-balance-api/internal/domain/outbox"
+	"github.com/sebastianreh/user-balance-api/internal/domain/webhook"
+	"github.com/sebastianreh/user-balance-api/pkg/logger"
+	webhookSender "github.com/sebastianreh/user-balance-api/pkg/webhook"
+)
+
+const (
+	webhookServiceName = "WebhookService"
+)
+
+type WebhookService interface {
+	// CreateSubscription saves the subscription with a new secret, it's the only time the secret is returned
+	CreateSubscription(ctx context.Context, subscription webhook.Subscription) (webhook.Subscription, error)
+	UpdateSubscription(ctx context.Context, subscription webhook.Subscription) (webhook.Subscription, error)
+	GetSubscription(ctx context.Context, subscriptionID string) (webhook.Subscription, error)
+	ListSubscriptions(ctx context.Context) ([]webhook.Subscription, error)
+	DeleteSubscription(ctx context.Context, subscriptionID string) error
+	// ListDeliveries pages through the deliveries of a subscription from the newest, with every attempt to send them
+	ListDeliveries(ctx context.Context, subscriptionID string, options outbox.ListOptions) (outbox.ListPage, error)
+	// ReplayDelivery sends a delivery of the subscription again as a new delivery of the same event
+	ReplayDelivery(ctx context.Context, subscriptionID, deliveryID string) (outbox.Message, error)
+	// Messages returns a delivery of the event for every active subscription to it, to be saved in the same database
+	// transaction as the change that raised it
+	Messages(ctx context.Context, eventType string, data any) ([]outbox.Message, error)
+	// Deliver sends a delivery signed with the secret of its subscription
+	Deliver(ctx context.Context, delivery webhook.Delivery) error
+}
+
+type webhookService struct {
+	log              logger.Logger
+	repository       webhook.Repository
+	outboxRepository outbox.Repository
+	sender           webhookSender.Sender
+}
+
+func NewWebhookService(log logger.Logger, repository webhook.Repository, outboxRepository outbox.Repository,
+	sender webhookSender.Sender) WebhookService {
+	return &webhookService{
+		log:              log,
+		repository:       repository,
+		outboxRepository: outboxRepository,
+		sender:           sender,
+	}
+}
+
+func (s *webhookService) CreateSubscription(ctx context.Context,
+	subscription webhook.Subscription) (webhook.Subscription, error) {
+	secret, err := webhook.NewSecret()
+	if err != nil {
+		s.log.ErrorAt(err, webhookServiceName, "CreateSubscription")
+		return subscription, err
+	}
+
+	subscription.Secret = secret
+	return s.repository.Save(ctx, subscription)
+}
+
+func (s *webhookService) UpdateSubscription(ctx context.Context,
+	subscription webhook.Subscription) (webhook.Subscription, error) {
+	updated, err := s.repository.Update(ctx, subscription)
+	updated.Secret = ""
+	return updated, err
+}
+
+func (s *webhookService) GetSubscription(ctx context.Context, subscriptionID string) (webhook.Subscription, error) {
+	subscription, err := s.repository.FindByID(ctx, subscriptionID)
+	subscription.Secret = ""
+	return subscription, err
+}
+
+func (s *webhookService) ListSubscriptions(ctx context.Context) ([]webhook.Subscription, error) {
+	subscriptions, err := s.repository.List(ctx)
+	for i := range subscriptions {
+		subscriptions[i].Secret = ""
+	}
+
+	return subscriptions, err
+}
+
+func (s *webhookService) DeleteSubscription(ctx context.Context, subscriptionID string) error {
+	return s.repository.Delete(ctx, subscriptionID)
+}
+
+func (s *webhookService) ListDeliveries(ctx context.Context, subscriptionID string,
+	options outbox.ListOptions) (outbox.ListPage, error) {
+	if _, err := s.repository.FindByID(ctx, subscriptionID); err != nil {
+		return outbox.ListPage{}, err
+	}
+
+	limit := options.Limit
+	// One extra delivery tells if there is a next page
+	options.Limit = limit + 1
+	options.Reference = webhook.Reference(subscriptionID)
+	messages, err := s.outboxRepository.List(ctx, options)
+	if err != nil {
+		s.log.ErrorAt(err, webhookServiceName, "ListDeliveries")
+		return outbox.ListPage{}, err
+	}
+
+	return outbox.NewListPage(messages, limit), nil
+}
+
+func (s *webhookService) ReplayDelivery(ctx context.Context, subscriptionID, deliveryID string) (outbox.Message, error) {
+	if _, err := s.repository.FindByID(ctx, subscriptionID); err != nil {
+		return outbox.Message{}, err
+	}
+
+	message, err := s.outboxRepository.FindByID(ctx, deliveryID)
+	if err != nil {
+		if strings.Contains(err.Error(), outbox.NotFoundError) {
+			return message, errors.New(webhook.DeliveryNotFoundError)
+		}
+
+		return message, err
+	}
+
+	// Any message could be found by its id, only the deliveries of the subscription are replayed through it
+	if message.Kind != outbox.KindWebhook || message.Reference != webhook.Reference(subscriptionID) {
+		return outbox.Message{}, errors.New(webhook.DeliveryNotFoundError)
+	}
+
+	replayed, err := s.outboxRepository.Duplicate(ctx, deliveryID)
+	if err != nil {
+		return replayed, err
+	}
+
+	s.log.Info("Webhook delivery replayed", "subscription_id", subscriptionID, "delivery_id", deliveryID,
+		"replay_id", replayed.ID)
+	return replayed, nil
+}
+
+func (s *webhookService) Messages(ctx context.Context, eventType string, data any) ([]outbox.Message, error) {
+	subscriptions, err := s.repository.FindByEvent(ctx, eventType)
+	if err != nil || len(subscriptions) == 0 {
+		return nil, err
+	}
+
+	event, err := webhook.NewEvent(eventType, data)
+	if err != nil {
+		s.log.ErrorAt(err, webhookServiceName, "Messages")
+		return nil, err
+	}
+
+	body, err := json.Marshal(event)
+	if err != nil {
+		err = fmt.Errorf("could not encode %s event, error: %w", eventType, err)
+		s.log.ErrorAt(err, webhookServiceName, "Messages")
+		return nil, err
+	}
+
+	messages := make([]outbox.Message, 0, len(subscriptions))
+	for _, subscription := range subscriptions {
+		delivery := webhook.Delivery{SubscriptionID: subscription.ID, EventID: event.ID, Event: eventType, Body: body}
+		message, err := outbox.NewMessage(outbox.KindWebhook, fmt.Sprintf("%s to %s", eventType, subscription.URL),
+			delivery)
+		if err != nil {
+			s.log.ErrorAt(err, webhookServiceName, "Messages")
+			return nil, err
+		}
+
+		message.Reference = webhook.Reference(subscription.ID)
+		messages = append(messages, message)
+	}
+
+	return messages, nil
+}
+
+func (s *webhookService) Publish(ctx context.Context, eventType string, data any) error {
+	messages, err := s.Messages(ctx, eventType, data)
+	if err != nil || len(messages) == 0 {
+		return err
+	}
+
+	return s.outboxRepository.Save(ctx, messages...)
+}
+
+func (s *webhookService) Deliver(ctx context.Context, delivery webhook.Delivery) error {
+	subscription, err := s.repository.FindByID(ctx, delivery.SubscriptionID)
+	if err != nil {
+		return err
+	}
+
+	if !subscription.Active {
+		return errors.New(webhook.InactiveError)
+	}
+
+	return s.sender.Send(ctx, subscription.URL, subscription.Secret, webhookSender.Request{
+		ID:    delivery.EventID,
+		Event: delivery.Event,
+		Body:  delivery.Body,
+	})
+}
@@ -0,0 +1,232 @@
+package services_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"testing"
+
+	"github.com/sebastianreh/user-balance-api/internal/app/services"
+	"github.com/sebastianreh/user-balance-api/internal/domain/outbox"
+	"github.com/sebastianreh/user-balance-api/internal/domain/webhook"
+	"github.com/sebastianreh/user-balance-api/pkg/logger"
+	webhookSender "github.com/sebastianreh/user-balance-api/pkg/webhook"
+	"github.com/sebastianreh/user-balance-api/test/mocks"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func Test_WebhookService_Subscriptions(t *testing.T) {
+	ctx := context.TODO()
+	log := logger.NewLogger()
+	subscription := webhook.Subscription{URL: "https://example.com/hooks", Active: true,
+		Events: []string{webhook.EventTransactionCreated}}
+
+	t.Run("When a subscription is created with a new secret", func(t *testing.T) {
+		repository := mocks.NewWebhookRepositoryMock()
+		repository.On("Save", ctx, mock.MatchedBy(func(saved webhook.Subscription) bool {
+			return len(saved.Secret) == len("whsec_")+64
+		})).Return(webhook.Subscription{ID: "1", Secret: "whsec_1"}, nil)
+
+		service := services.NewWebhookService(log, repository, mocks.NewOutboxRepositoryMock(),
+			mocks.NewWebhookSenderMock())
+		created, err := service.CreateSubscription(ctx, subscription)
+
+		assert.Nil(t, err)
+		assert.Equal(t, "whsec_1", created.Secret)
+		repository.AssertExpectations(t)
+	})
+
+	t.Run("When the secret is hidden once the subscription is created", func(t *testing.T) {
+		repository := mocks.NewWebhookRepositoryMock()
+		repository.On("FindByID", ctx, "1").Return(webhook.Subscription{ID: "1", Secret: "whsec_1"}, nil)
+		repository.On("List", ctx).Return([]webhook.Subscription{{ID: "1", Secret: "whsec_1"}}, nil)
+		repository.On("Update", ctx, subscription).Return(webhook.Subscription{ID: "1", Secret: "whsec_1"}, nil)
+
+		service := services.NewWebhookService(log, repository, mocks.NewOutboxRepositoryMock(),
+			mocks.NewWebhookSenderMock())
+		found, err := service.GetSubscription(ctx, "1")
+		assert.Nil(t, err)
+		assert.Empty(t, found.Secret)
+
+		subscriptions, err := service.ListSubscriptions(ctx)
+		assert.Nil(t, err)
+		assert.Empty(t, subscriptions[0].Secret)
+
+		updated, err := service.UpdateSubscription(ctx, subscription)
+		assert.Nil(t, err)
+		assert.Empty(t, updated.Secret)
+	})
+}
+
+func Test_WebhookService_Events(t *testing.T) {
+	ctx := context.TODO()
+	log := logger.NewLogger()
+	subscriptions := []webhook.Subscription{
+		{ID: "1", URL: "https://example.com/hooks", Active: true},
+		{ID: "2", URL: "https://other.example.com/hooks", Active: true},
+	}
+	data := map[string]string{"id": "7"}
+
+	t.Run("When an event has a delivery for every subscription with the same body", func(t *testing.T) {
+		repository := mocks.NewWebhookRepositoryMock()
+		repository.On("FindByEvent", ctx, webhook.EventUserDeleted).Return(subscriptions, nil)
+
+		service := services.NewWebhookService(log, repository, mocks.NewOutboxRepositoryMock(),
+			mocks.NewWebhookSenderMock())
+		messages, err := service.Messages(ctx, webhook.EventUserDeleted, data)
+
+		assert.Nil(t, err)
+		assert.Len(t, messages, 2)
+		assert.Equal(t, outbox.KindWebhook, messages[1].Kind)
+		assert.Equal(t, "webhook:2", messages[1].Reference)
+		assert.Equal(t, "user.deleted to https://other.example.com/hooks", messages[1].Description)
+
+		var first, second webhook.Delivery
+		assert.Nil(t, json.Unmarshal(messages[0].Payload, &first))
+		assert.Nil(t, json.Unmarshal(messages[1].Payload, &second))
+		assert.Equal(t, "1", first.SubscriptionID)
+		assert.Equal(t, first.EventID, second.EventID)
+		assert.JSONEq(t, string(first.Body), string(second.Body))
+
+		var event webhook.Event
+		assert.Nil(t, json.Unmarshal(first.Body, &event))
+		assert.Equal(t, first.EventID, event.ID)
+		assert.Equal(t, webhook.EventUserDeleted, event.Type)
+		assert.Equal(t, map[string]any{"id": "7"}, event.Data)
+	})
+
+	t.Run("When an event without subscriptions has no deliveries", func(t *testing.T) {
+		repository := mocks.NewWebhookRepositoryMock()
+		repository.On("FindByEvent", ctx, webhook.EventUserDeleted).Return([]webhook.Subscription{}, nil)
+
+		service := services.NewWebhookService(log, repository, mocks.NewOutboxRepositoryMock(),
+			mocks.NewWebhookSenderMock())
+		messages, err := service.Messages(ctx, webhook.EventUserDeleted, data)
+
+		assert.Nil(t, err)
+		assert.Empty(t, messages)
+	})
+
+	t.Run("When the subscriptions of the event can't be read", func(t *testing.T) {
+		repository := mocks.NewWebhookRepositoryMock()
+		repository.On("FindByEvent", ctx, webhook.EventUserDeleted).Return([]webhook.Subscription{},
+			errors.New("database error"))
+
+		service := services.NewWebhookService(log, repository, mocks.NewOutboxRepositoryMock(),
+			mocks.NewWebhookSenderMock())
+		_, err := service.Messages(ctx, webhook.EventUserDeleted, data)
+
+		assert.EqualError(t, err, "database error")
+	})
+}
+
+func Test_WebhookService_Deliver(t *testing.T) {
+	ctx := context.TODO()
+	log := logger.NewLogger()
+	delivery := webhook.Delivery{SubscriptionID: "1", EventID: "evt_1", Event: webhook.EventTransactionCreated,
+		Body: json.RawMessage(`{"id":"evt_1"}`)}
+
+	t.Run("When a delivery is sent signed with the secret of its subscription", func(t *testing.T) {
+		repository := mocks.NewWebhookRepositoryMock()
+		repository.On("FindByID", ctx, "1").Return(webhook.Subscription{ID: "1", URL: "https://example.com/hooks",
+			Active: true, Secret: "whsec_1"}, nil)
+		sender := mocks.NewWebhookSenderMock()
+		sender.On("Send", ctx, "https://example.com/hooks", "whsec_1", webhookSender.Request{ID: "evt_1",
+			Event: webhook.EventTransactionCreated, Body: delivery.Body}).Return(nil)
+
+		service := services.NewWebhookService(log, repository, mocks.NewOutboxRepositoryMock(), sender)
+		err := service.Deliver(ctx, delivery)
+
+		assert.Nil(t, err)
+		sender.AssertExpectations(t)
+	})
+
+	t.Run("When the subscription is paused", func(t *testing.T) {
+		repository := mocks.NewWebhookRepositoryMock()
+		repository.On("FindByID", ctx, "1").Return(webhook.Subscription{ID: "1", Active: false}, nil)
+		sender := mocks.NewWebhookSenderMock()
+
+		service := services.NewWebhookService(log, repository, mocks.NewOutboxRepositoryMock(), sender)
+		err := service.Deliver(ctx, delivery)
+
+		assert.EqualError(t, err, webhook.InactiveError)
+		sender.AssertNotCalled(t, "Send", mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+	})
+}
+
+func Test_WebhookService_Deliveries(t *testing.T) {
+	ctx := context.TODO()
+	log := logger.NewLogger()
+	subscription := webhook.Subscription{ID: "1"}
+
+	t.Run("When the deliveries of a subscription are paged", func(t *testing.T) {
+		repository := mocks.NewWebhookRepositoryMock()
+		repository.On("FindByID", ctx, "1").Return(subscription, nil)
+		outboxRepository := mocks.NewOutboxRepositoryMock()
+		outboxRepository.On("List", ctx, outbox.ListOptions{Reference: "webhook:1", Limit: 2}).
+			Return([]outbox.Message{{ID: "9"}, {ID: "8"}}, nil)
+
+		service := services.NewWebhookService(log, repository, outboxRepository, mocks.NewWebhookSenderMock())
+		page, err := service.ListDeliveries(ctx, "1", outbox.ListOptions{Limit: 1})
+
+		assert.Nil(t, err)
+		assert.Len(t, page.Messages, 1)
+		assert.Equal(t, outbox.Cursor{ID: "9"}.Encode(), page.NextCursor)
+	})
+
+	t.Run("When the deliveries of an unknown subscription are listed", func(t *testing.T) {
+		repository := mocks.NewWebhookRepositoryMock()
+		repository.On("FindByID", ctx, "1").Return(webhook.Subscription{}, errors.New(webhook.NotFoundError))
+		outboxRepository := mocks.NewOutboxRepositoryMock()
+
+		service := services.NewWebhookService(log, repository, outboxRepository, mocks.NewWebhookSenderMock())
+		_, err := service.ListDeliveries(ctx, "1", outbox.ListOptions{Limit: 1})
+
+		assert.EqualError(t, err, webhook.NotFoundError)
+		outboxRepository.AssertNotCalled(t, "List", mock.Anything, mock.Anything)
+	})
+
+	t.Run("When a delivery is replayed as a new delivery", func(t *testing.T) {
+		repository := mocks.NewWebhookRepositoryMock()
+		repository.On("FindByID", ctx, "1").Return(subscription, nil)
+		outboxRepository := mocks.NewOutboxRepositoryMock()
+		outboxRepository.On("FindByID", ctx, "8").Return(outbox.Message{ID: "8", Kind: outbox.KindWebhook,
+			Reference: "webhook:1", Status: outbox.StatusSent}, nil)
+		outboxRepository.On("Duplicate", ctx, "8").Return(outbox.Message{ID: "10", Kind: outbox.KindWebhook,
+			Reference: "webhook:1", Status: outbox.StatusPending}, nil)
+
+		service := services.NewWebhookService(log, repository, outboxRepository, mocks.NewWebhookSenderMock())
+		replayed, err := service.ReplayDelivery(ctx, "1", "8")
+
+		assert.Nil(t, err)
+		assert.Equal(t, "10", replayed.ID)
+		assert.Equal(t, outbox.StatusPending, replayed.Status)
+	})
+
+	t.Run("When the delivery belongs to another subscription", func(t *testing.T) {
+		repository := mocks.NewWebhookRepositoryMock()
+		repository.On("FindByID", ctx, "1").Return(subscription, nil)
+		outboxRepository := mocks.NewOutboxRepositoryMock()
+		outboxRepository.On("FindByID", ctx, "8").Return(outbox.Message{ID: "8", Kind: outbox.KindWebhook,
+			Reference: "webhook:2"}, nil)
+
+		service := services.NewWebhookService(log, repository, outboxRepository, mocks.NewWebhookSenderMock())
+		_, err := service.ReplayDelivery(ctx, "1", "8")
+
+		assert.EqualError(t, err, webhook.DeliveryNotFoundError)
+		outboxRepository.AssertNotCalled(t, "Duplicate", mock.Anything, mock.Anything)
+	})
+
+	t.Run("When the delivery does not exist", func(t *testing.T) {
+		repository := mocks.NewWebhookRepositoryMock()
+		repository.On("FindByID", ctx, "1").Return(subscription, nil)
+		outboxRepository := mocks.NewOutboxRepositoryMock()
+		outboxRepository.On("FindByID", ctx, "8").Return(outbox.Message{}, errors.New(outbox.NotFoundError))
+
+		service := services.NewWebhookService(log, repository, outboxRepository, mocks.NewWebhookSenderMock())
+		_, err := service.ReplayDelivery(ctx, "1", "8")
+
+		assert.EqualError(t, err, webhook.DeliveryNotFoundError)
+	})
+}
@@ -1,27 +1,45 @@
 package container
 
 import (
+	"context"
 	"database/sql"
+	nethttp "net/http"
 
 	"github.com/sebastianreh/user-balance-api/internal/app/services"
 	"github.com/sebastianreh/user-balance-api/internal/domain/balance"
+	"github.com/sebastianreh/user-balance-api/internal/domain/migration"
+	"github.com/sebastianreh/user-balance-api/internal/domain/transaction"
 	"github.com/sebastianreh/user-balance-api/internal/infrastructure/config"
+	"github.com/sebastianreh/user-balance-api/internal/infrastructure/filesystem"
 	"github.com/sebastianreh/user-balance-api/internal/infrastructure/postgresql"
 	"github.com/sebastianreh/user-balance-api/internal/interfaces/http"
 	"github.com/sebastianreh/user-balance-api/pkg/csv"
+	"github.com/sebastianreh/user-balance-api/pkg/decompress"
 	"github.com/sebastianreh/user-balance-api/pkg/email"
+	"github.com/sebastianreh/user-balance-api/pkg/jsonrecords"
 	"github.com/sebastianreh/user-balance-api/pkg/logger"
+	"github.com/sebastianreh/user-balance-api/pkg/notifier"
+	"github.com/sebastianreh/user-balance-api/pkg/pubsub"
+	"github.com/sebastianreh/user-balance-api/pkg/records"
+	"github.com/sebastianreh/user-balance-api/pkg/webhook"
 )
 
 type Dependencies struct {
-	Config             config.Config
-	Logs               logger.Logger
-	SQL                *sql.DB
-	PingHandler        *http.PingHandler
-	UserHandler        *http.UserHandler
-	TransactionHandler *http.TransactionHandler
-	BalanceHandler     *http.BalanceHandler
-	MigrationHandler   *http.MigrationHandler
+	Config                  config.Config
+	Logs                    logger.Logger
+	SQL                     *sql.DB
+	PingHandler             *http.PingHandler
+	UserHandler             *http.UserHandler
+	TransactionHandler      *http.TransactionHandler
+	BalanceHandler          *http.BalanceHandler
+	MigrationHandler        *http.MigrationHandler
+	MigrationProfileHandler *http.MigrationProfileHandler
+	MigrationUploadHandler  *http.MigrationUploadHandler
+	OutboxHandler           *http.OutboxHandler
+	WebhookHandler          *http.WebhookHandler
+	AlertHandler            *http.AlertHandler
+	BalanceStreamHandler    *http.BalanceStreamHandler
+	ChangeHandler           *http.ChangeHandler
 }
 
 func Build() Dependencies {
@@ -46,25 +64,122 @@ func Build() Dependencies {
 
 	userSQLRepository := postgresql.NewSQLUserRepository(dependencies.Logs, dependencies.SQL)
 	transactionSQLRepository := postgresql.NewSQLTransactionRepository(dependencies.Logs, dependencies.SQL)
+	balanceSQLRepository := postgresql.NewSQLBalanceRepository(dependencies.Logs, dependencies.SQL)
+	migrationJobSQLRepository := postgresql.NewSQLMigrationJobRepository(dependencies.Logs, dependencies.SQL)
+	mappingProfileSQLRepository := postgresql.NewSQLMappingProfileRepository(dependencies.Logs, dependencies.SQL)
+	outboxSQLRepository := postgresql.NewSQLOutboxRepository(dependencies.Logs, dependencies.SQL)
+	webhookSQLRepository := postgresql.NewSQLWebhookRepository(dependencies.Logs, dependencies.SQL)
+	alertSQLRepository := postgresql.NewSQLAlertRepository(dependencies.Logs, dependencies.SQL)
+	changeSQLRepository := postgresql.NewSQLChangeRepository(dependencies.Logs, dependencies.SQL)
+	uploadRepository := filesystem.NewUploadRepository(dependencies.Logs, dependencies.Config.Uploads.Dir)
 
 	balanceCalculator := balance.NewBalanceCalculator()
 
 	smtpConfig := dependencies.Config.SMTP
-	csvProcessor := csv.NewCsvProcessor()
+	// The JSON records carry the optional user fields after the transaction ones, like the mapped CSV records
+	recordKeys := append(transaction.RecordKeys(), migration.UserFields()...)
+	recordSources := records.Sources{
+		records.FormatCSV:    csv.NewCsvProcessor(),
+		records.FormatNDJSON: jsonrecords.NewNDJSONProcessor(recordKeys),
+		records.FormatJSON:   jsonrecords.NewJSONArrayProcessor(recordKeys),
+	}
 	emailService := email.NewSMTPEmailService(smtpConfig.Username, smtpConfig.Password, smtpConfig.From, smtpConfig.SendTo,
 		smtpConfig.Host, smtpConfig.Port)
-	userService := services.NewUserService(dependencies.Logs, userSQLRepository)
-	transactionService := services.NewTransactionService(dependencies.Logs, transactionSQLRepository)
+	emailRenderer, err := email.NewTemplateRenderer(smtpConfig.TemplatesDir)
+	if err != nil {
+		logs.Fatal("Email templates error, shutting down server")
+	}
+
+	notificationRouter, err := newNotifier(dependencies.Config, emailService)
+	if err != nil {
+		logs.Fatal("Notification routes error, shutting down server")
+	}
+
+	notificationService := services.NewNotificationService(dependencies.Logs, notificationRouter, outboxSQLRepository)
+	webhookService := services.NewWebhookService(dependencies.Logs, webhookSQLRepository, outboxSQLRepository,
+		webhook.NewSender(&nethttp.Client{Timeout: dependencies.Config.Webhooks.Timeout}))
+	alertService := services.NewAlertService(dependencies.Logs, alertSQLRepository, userSQLRepository,
+		balanceSQLRepository)
+	userService := services.NewUserService(dependencies.Logs, userSQLRepository, webhookService)
+	transactionService := services.NewTransactionService(dependencies.Config, dependencies.Logs,
+		transactionSQLRepository, userSQLRepository, balanceSQLRepository, notificationService, webhookService,
+		alertService)
 	balanceService := services.NewBalanceService(dependencies.Logs, userSQLRepository,
-		transactionSQLRepository, balanceCalculator)
+		transactionSQLRepository, balanceSQLRepository, balanceCalculator)
 	migrationService := services.NewMigrationService(dependencies.Config, dependencies.Logs, userSQLRepository,
-		transactionSQLRepository, csvProcessor)
-	migrationsReportService := services.NewMigrationReportService(dependencies.Logs, emailService)
+		transactionSQLRepository, recordSources, alertService)
+	migrationsReportService := services.NewMigrationReportService(dependencies.Logs, emailRenderer,
+		transactionSQLRepository)
+	migrationJobService := services.NewMigrationJobService(dependencies.Config, dependencies.Logs,
+		migrationJobSQLRepository, transactionSQLRepository, migrationService, migrationsReportService,
+		notificationService, webhookService, alertService)
+	migrationProfileService := services.NewMigrationProfileService(dependencies.Logs, mappingProfileSQLRepository)
+	changeService := services.NewChangeService(dependencies.Logs, changeSQLRepository)
+	migrationUploadService := services.NewMigrationUploadService(dependencies.Config, dependencies.Logs,
+		uploadRepository, migrationJobService)
+	outboxService := services.NewOutboxService(dependencies.Config, dependencies.Logs, outboxSQLRepository,
+		emailService, notificationRouter, webhookService)
+	if err = migrationJobService.FailInterruptedJobs(context.Background()); err != nil {
+		logs.Fatal("Migration jobs recovery error, shutting down server")
+	}
+
+	if err = migrationUploadService.DeleteExpiredUploads(context.Background()); err != nil {
+		logs.Fatal("Migration uploads cleanup error, shutting down server")
+	}
+
+	outboxService.Start(context.Background())
+
+	streamsConfig := dependencies.Config.Streams
+	balanceBroker := pubsub.NewBroker(streamsConfig.MaxConnections, streamsConfig.MaxConnectionsPerUser)
+	if err = postgresql.StartBalanceListener(context.Background(), dependencies.Config, logs, balanceBroker); err != nil {
+		logs.Fatal("Balance listener error, shutting down server")
+	}
+	balanceStreamService := services.NewBalanceStreamService(dependencies.Logs, userSQLRepository, balanceService,
+		balanceBroker)
 
 	dependencies.UserHandler = http.NewUserHandler(dependencies.Logs, userService)
 	dependencies.TransactionHandler = http.NewTransactionHandler(dependencies.Logs, transactionService)
 	dependencies.BalanceHandler = http.NewBalanceHandler(dependencies.Logs, balanceService)
-	dependencies.MigrationHandler = http.NewMigrationHandler(dependencies.Logs, migrationService, migrationsReportService)
+	migrationLimits := decompress.Limits{
+		MaxSize:    dependencies.Config.Workers.MigrationMaxDecompressedSize,
+		MaxEntries: dependencies.Config.Workers.MigrationMaxArchiveEntries,
+	}
+	dependencies.MigrationHandler = http.NewMigrationHandler(dependencies.Logs, migrationService, migrationJobService,
+		migrationProfileService, migrationLimits)
+	dependencies.MigrationProfileHandler = http.NewMigrationProfileHandler(dependencies.Logs, migrationProfileService)
+	dependencies.MigrationUploadHandler = http.NewMigrationUploadHandler(dependencies.Logs, migrationUploadService,
+		migrationProfileService, migrationLimits)
+
+	dependencies.OutboxHandler = http.NewOutboxHandler(dependencies.Logs, outboxService)
+	dependencies.WebhookHandler = http.NewWebhookHandler(dependencies.Logs, webhookService)
+	dependencies.AlertHandler = http.NewAlertHandler(dependencies.Logs, alertService)
+	dependencies.BalanceStreamHandler = http.NewBalanceStreamHandler(dependencies.Logs, balanceStreamService,
+		streamsConfig.Heartbeat)
+	dependencies.ChangeHandler = http.NewChangeHandler(dependencies.Logs, changeService)
 
 	return dependencies
 }
+
+// newNotifier routes the notifications, the webhook channels are only available when their URL is set
+func newNotifier(cfg config.Config, emailService email.EmailService) (notifier.Notifier, error) {
+	routes, err := notifier.ParseRoutes(cfg.Notifications.Routes)
+	if err != nil {
+		return nil, err
+	}
+
+	client := &nethttp.Client{Timeout: cfg.Notifications.Timeout}
+	channels := map[string]notifier.Channel{
+		notifier.ChannelSMTP: notifier.NewSMTPChannel(emailService),
+		notifier.ChannelFile: notifier.NewFileChannel(cfg.Notifications.FileDir),
+	}
+
+	if cfg.Notifications.WebhookURL != "" {
+		channels[notifier.ChannelWebhook] = notifier.NewWebhookChannel(client, cfg.Notifications.WebhookURL)
+	}
+
+	if cfg.Notifications.SlackWebhookURL != "" {
+		channels[notifier.ChannelSlack] = notifier.NewSlackChannel(client, cfg.Notifications.SlackWebhookURL)
+	}
+
+	return notifier.NewRouter(channels, routes)
+}
@@ -0,0 +1,64 @@
+package alert
+
+import (
+	"errors"
+	"strconv"
+	"time"
+
+	"github.com/sebastianreh/user-balance-api/pkg/cursor"
+)
+
+const (
+	DefaultListLimit = 50
+	MaxListLimit     = 500
+)
+
+// Alert is a fired rule, the history of a user keeps them after their rule is changed or deleted
+type Alert struct {
+	ID        string  `json:"id"`
+	RuleID    string  `json:"rule_id"`
+	UserID    string  `json:"user_id"`
+	Type      string  `json:"type"`
+	Threshold float64 `json:"threshold"`
+	// Value is the balance of a balance rule or the amount of the transaction of a debit rule
+	Value         float64   `json:"value"`
+	TransactionID string    `json:"transaction_id,omitempty"`
+	Recipients    []string  `json:"recipients"`
+	CreatedAt     time.Time `json:"created_at"`
+}
+
+// ListOptions pages through the alerts of a user from the newest
+type ListOptions struct {
+	UserID string
+	Limit  int
+	After  *Cursor
+}
+
+// Cursor holds the id of the last alert of a page, the next page starts right after it
+type Cursor struct {
+	ID string `json:"id"`
+}
+
+type ListPage struct {
+	Alerts     []Alert `json:"alerts"`
+	NextCursor string  `json:"next_cursor,omitempty"`
+}
+
+func (c Cursor) Encode() string {
+	return cursor.Encode(c)
+}
+
+// DecodeCursor parses a cursor of the alerts list, alerts are identified by a sequence so anything else would fail
+// in the database
+func DecodeCursor(value string) (Cursor, error) {
+	var position Cursor
+	if err := cursor.Decode(value, &position); err != nil {
+		return position, errors.New(cursor.InvalidCursorError)
+	}
+
+	if _, err := strconv.ParseInt(position.ID, 10, 64); err != nil {
+		return position, errors.New(cursor.InvalidCursorError)
+	}
+
+	return position, nil
+}
@@ -0,0 +1,30 @@
+package alert
+
+import (
+	"context"
+
+	"github.com/sebastianreh/user-balance-api/internal/domain/outbox"
+)
+
+const (
+	RepositoryName         = "AlertRepository"
+	RuleNotFoundError      = "alert rule not found"
+	MissingRecipientsError = "the alert rule has no recipients and the user has no email"
+)
+
+type Repository interface {
+	SaveRule(ctx context.Context, rule Rule) (Rule, error)
+	// UpdateRule replaces the type, threshold, recipients and active flag of a rule and clears its condition
+	UpdateRule(ctx context.Context, rule Rule) (Rule, error)
+	FindRule(ctx context.Context, userID, ruleID string) (Rule, error)
+	ListRules(ctx context.Context, userID string) ([]Rule, error)
+	DeleteRule(ctx context.Context, userID, ruleID string) error
+	// FindActiveRules returns the active rules of the users
+	FindActiveRules(ctx context.Context, userIDs []string) ([]Rule, error)
+	// Fire saves the alert with its email in one database transaction, and tells false without saving anything when
+	// the rule already fired for the condition: a triggered balance rule or a transaction already alerted
+	Fire(ctx context.Context, alert Alert, message outbox.Message) (bool, error)
+	// Clear lets the balance rules fire again once their condition no longer holds
+	Clear(ctx context.Context, ruleIDs []string) error
+	ListAlerts(ctx context.Context, options ListOptions) ([]Alert, error)
+}
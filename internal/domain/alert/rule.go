@@ -0,0 +1,63 @@
+package alert
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/sebastianreh/user-balance-api/internal/domain/user"
+)
+
+const (
+	// TypeBalanceBelow fires when the balance of the user drops below the threshold, and again only once the balance
+	// went back to the threshold or above
+	TypeBalanceBelow = "balance_below"
+	// TypeDebitAbove fires once for every transaction that debits more than the threshold
+	TypeDebitAbove = "debit_above"
+)
+
+// Rule alerts the recipients about a condition of the balance or the transactions of a user
+type Rule struct {
+	ID         string   `json:"id"`
+	UserID     string   `json:"user_id"`
+	Type       string   `json:"type"`
+	Threshold  float64  `json:"threshold"`
+	Recipients []string `json:"recipients"`
+	Active     bool     `json:"active"`
+	// TriggeredAt is set while the condition of a balance rule holds, the rule doesn't fire again until it clears
+	TriggeredAt *time.Time `json:"triggered_at,omitempty"`
+	CreatedAt   time.Time  `json:"created_at"`
+	UpdatedAt   time.Time  `json:"updated_at"`
+}
+
+func IsType(ruleType string) bool {
+	return ruleType == TypeBalanceBelow || ruleType == TypeDebitAbove
+}
+
+func (r Rule) Validate() error {
+	if !IsType(r.Type) {
+		return fmt.Errorf("type must be %s or %s", TypeBalanceBelow, TypeDebitAbove)
+	}
+
+	if r.Type == TypeDebitAbove && r.Threshold < 0 {
+		return errors.New("threshold of a debit rule can't be negative, debits are compared by their absolute amount")
+	}
+
+	for _, recipient := range r.Recipients {
+		if err := user.ValidateEmail(recipient); err != nil {
+			return fmt.Errorf("invalid recipient %s: %w", recipient, err)
+		}
+	}
+
+	return nil
+}
+
+// Triggers tells if a balance rule holds for the balance
+func (r Rule) Triggers(balance float64) bool {
+	return r.Type == TypeBalanceBelow && balance < r.Threshold
+}
+
+// Debits tells if a debit rule fires for the amount of a transaction
+func (r Rule) Debits(amount float64) bool {
+	return r.Type == TypeDebitAbove && amount < 0 && -amount > r.Threshold
+}
@@ -0,0 +1,47 @@
+package alert_test
+
+import (
+	"testing"
+
+	"github.com/sebastianreh/user-balance-api/internal/domain/alert"
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_Rule_Validate(t *testing.T) {
+	t.Run("When the rule has a known type and valid recipients", func(t *testing.T) {
+		rule := alert.Rule{Type: alert.TypeBalanceBelow, Threshold: -100, Recipients: []string{"ops@example.com"}}
+		assert.Nil(t, rule.Validate())
+	})
+
+	t.Run("When the type is unknown", func(t *testing.T) {
+		rule := alert.Rule{Type: "credit_above"}
+		assert.EqualError(t, rule.Validate(), "type must be balance_below or debit_above")
+	})
+
+	t.Run("When a debit rule has a negative threshold", func(t *testing.T) {
+		rule := alert.Rule{Type: alert.TypeDebitAbove, Threshold: -10}
+		assert.ErrorContains(t, rule.Validate(), "threshold of a debit rule can't be negative")
+	})
+
+	t.Run("When a recipient is not an email", func(t *testing.T) {
+		rule := alert.Rule{Type: alert.TypeDebitAbove, Recipients: []string{"ops@example.com", "ops"}}
+		assert.ErrorContains(t, rule.Validate(), "invalid recipient ops")
+	})
+}
+
+func Test_Rule_Conditions(t *testing.T) {
+	t.Run("When a balance rule holds only below the threshold", func(t *testing.T) {
+		rule := alert.Rule{Type: alert.TypeBalanceBelow, Threshold: 100}
+		assert.True(t, rule.Triggers(99.99))
+		assert.False(t, rule.Triggers(100))
+		assert.False(t, rule.Debits(-500))
+	})
+
+	t.Run("When a debit rule fires only for debits above the threshold", func(t *testing.T) {
+		rule := alert.Rule{Type: alert.TypeDebitAbove, Threshold: 1000}
+		assert.True(t, rule.Debits(-1000.01))
+		assert.False(t, rule.Debits(-1000))
+		assert.False(t, rule.Debits(5000))
+		assert.False(t, rule.Triggers(-5000))
+	})
+}
@@ -0,0 +1,18 @@
+package balance
+
+const (
+	MaxBatchUserIDs = 1000
+)
+
+type BatchQuery struct {
+	UserIDs []string `json:"user_ids"`
+	From    string   `json:"from"`
+	To      string   `json:"to"`
+	AsOf    string   `json:"as_of"`
+}
+
+type BatchResult struct {
+	Balances map[string]UserBalance `json:"balances"`
+	// Errors holds the reason why a requested user has no balance, for example when it does not exist
+	Errors map[string]string `json:"errors"`
+}
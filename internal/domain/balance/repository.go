@@ -0,0 +1,14 @@
+package balance
+
+import "context"
+
+const (
+	RepositoryName = "BalanceRepository"
+)
+
+type Repository interface {
+	// FindByUserID aggregates the user transactions in the storage, the date range applies only when both dates are set
+	FindByUserID(ctx context.Context, userID, fromDate, toDate string) (UserBalance, error)
+	// FindByUserIDs returns the balance of every active user found, missing or deleted users are not in the map
+	FindByUserIDs(ctx context.Context, userIDs []string, fromDate, toDate string) (map[string]UserBalance, error)
+}
@@ -0,0 +1,23 @@
+package balance
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+)
+
+const (
+	// ChangesChannel is notified with the id of the user on every change of its transactions, once the change is
+	// committed
+	ChangesChannel = "balance_changes"
+	// StreamEvent is the event of a balance pushed to a stream
+	StreamEvent = "balance"
+)
+
+// Version identifies the balance by its content, it's the same on every instance so a client that reconnects with
+// the version it has is only sent a different balance
+func (u UserBalance) Version() string {
+	encoded, _ := json.Marshal(u)
+	sum := sha256.Sum256(encoded)
+	return hex.EncodeToString(sum[:8])
+}
@@ -0,0 +1,71 @@
+package change
+
+import (
+	"encoding/json"
+	"errors"
+	"strconv"
+	"time"
+)
+
+const (
+	EntityUser          = "user"
+	EntityTransaction   = "transaction"
+	OperationInsert     = "insert"
+	OperationUpdate     = "update"
+	OperationSoftDelete = "soft_delete"
+	// OperationRestore is a deleted transaction saved again with the same id
+	OperationRestore   = "restore"
+	DefaultListLimit   = 100
+	MaxListLimit       = 1000
+	InvalidCursorError = "since must be a cursor returned by the changes feed"
+)
+
+// Change is a write to a user or a transaction, Data is the row as the write left it. The cursors grow in the order
+// the writes were committed, so a consumer that reads from its last cursor never misses a change
+type Change struct {
+	Cursor    string          `json:"cursor"`
+	Entity    string          `json:"entity"`
+	EntityID  string          `json:"entity_id"`
+	Operation string          `json:"operation"`
+	Data      json.RawMessage `json:"data"`
+	CreatedAt time.Time       `json:"created_at"`
+}
+
+// ListOptions reads the changes after the Since cursor, zero reads the feed from the start
+type ListOptions struct {
+	Since int64
+	Limit int
+}
+
+// ListPage holds the changes in cursor order. NextCursor is the since of the next read, it stays the same when
+// there are no new changes so a consumer can keep polling with it
+type ListPage struct {
+	Changes    []Change `json:"changes"`
+	NextCursor string   `json:"next_cursor"`
+	HasMore    bool     `json:"has_more"`
+}
+
+// NewListPage pages the changes listed with one extra change, which tells if there are more to read right away
+func NewListPage(changes []Change, options ListOptions) ListPage {
+	page := ListPage{Changes: changes, NextCursor: strconv.FormatInt(options.Since, 10)}
+	if len(changes) > options.Limit {
+		page.Changes = changes[:options.Limit]
+		page.HasMore = true
+	}
+
+	if len(page.Changes) > 0 {
+		page.NextCursor = page.Changes[len(page.Changes)-1].Cursor
+	}
+
+	return page
+}
+
+// ParseCursor reads a cursor of the feed, they are the values of a sequence
+func ParseCursor(value string) (int64, error) {
+	since, err := strconv.ParseInt(value, 10, 64)
+	if err != nil || since < 0 {
+		return 0, errors.New(InvalidCursorError)
+	}
+
+	return since, nil
+}
@@ -0,0 +1,51 @@
+package change_test
+
+import (
+	"testing"
+
+	"github.com/sebastianreh/user-balance-api/internal/domain/change"
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_NewListPage(t *testing.T) {
+	changes := []change.Change{{Cursor: "4"}, {Cursor: "7"}, {Cursor: "9"}}
+
+	t.Run("When there are more changes than the limit", func(t *testing.T) {
+		page := change.NewListPage(changes, change.ListOptions{Since: 3, Limit: 2})
+		assert.Len(t, page.Changes, 2)
+		assert.True(t, page.HasMore)
+		assert.Equal(t, "7", page.NextCursor)
+	})
+
+	t.Run("When the changes fit in the page", func(t *testing.T) {
+		page := change.NewListPage(changes, change.ListOptions{Since: 3, Limit: 3})
+		assert.Len(t, page.Changes, 3)
+		assert.False(t, page.HasMore)
+		assert.Equal(t, "9", page.NextCursor)
+	})
+
+	t.Run("When there are no new changes the since cursor is kept", func(t *testing.T) {
+		page := change.NewListPage([]change.Change{}, change.ListOptions{Since: 9, Limit: 3})
+		assert.Empty(t, page.Changes)
+		assert.False(t, page.HasMore)
+		assert.Equal(t, "9", page.NextCursor)
+	})
+}
+
+func Test_ParseCursor(t *testing.T) {
+	t.Run("When the cursor is a sequence value", func(t *testing.T) {
+		since, err := change.ParseCursor("42")
+		assert.Nil(t, err)
+		assert.Equal(t, int64(42), since)
+	})
+
+	t.Run("When the cursor is not a number", func(t *testing.T) {
+		_, err := change.ParseCursor("abc")
+		assert.ErrorContains(t, err, change.InvalidCursorError)
+	})
+
+	t.Run("When the cursor is negative", func(t *testing.T) {
+		_, err := change.ParseCursor("-1")
+		assert.ErrorContains(t, err, change.InvalidCursorError)
+	})
+}
@@ -0,0 +1,13 @@
+package change
+
+import "context"
+
+const (
+	RepositoryName = "ChangeRepository"
+)
+
+// Repository reads the changes feed. The changes are written by the repositories of the users and the transactions,
+// in the same database transaction as the write they record
+type Repository interface {
+	List(ctx context.Context, options ListOptions) ([]Change, error)
+}
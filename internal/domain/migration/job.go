@@ -0,0 +1,66 @@
+package migration
+
+import (
+	"time"
+
+	"github.com/sebastianreh/user-balance-api/internal/domain/report"
+)
+
+const (
+	StatusPending   = "pending"
+	StatusRunning   = "running"
+	StatusCompleted = "completed"
+	StatusFailed    = "failed"
+	// StatusRolledBack is a completed or failed migration whose transactions were deleted
+	StatusRolledBack = "rolled_back"
+
+	InterruptedError = "migration job was interrupted before finishing"
+)
+
+type Progress struct {
+	RowsValidated int `json:"rows_validated"`
+	RowsInserted  int `json:"rows_inserted"`
+	BatchesDone   int `json:"batches_done"`
+	BatchesTotal  int `json:"batches_total"`
+}
+
+// Job is a migration of an uploaded file, every saved transaction is stamped with its ID
+type Job struct {
+	ID       string `json:"job_id"`
+	Status   string `json:"status"`
+	FileName string `json:"file_name"`
+	// Checksum is the hex SHA-256 of the uploaded file
+	Checksum string `json:"checksum"`
+	// ContentHash is the hex SHA-256 of the normalized file content, it matches re-saved copies of the same file
+	ContentHash string `json:"content_hash"`
+	// Forced is set when the file was imported again with force, DuplicateOf is the migration that imported it before
+	Forced       bool                     `json:"forced,omitempty"`
+	DuplicateOf  string                   `json:"duplicate_of,omitempty"`
+	UploadedBy   string                   `json:"uploaded_by,omitempty"`
+	Mode         string                   `json:"mode"`
+	Progress     Progress                 `json:"progress"`
+	Summary      *report.MigrationSummary `json:"summary,omitempty"`
+	Error        string                   `json:"error,omitempty"`
+	CreatedAt    time.Time                `json:"created_at"`
+	UpdatedAt    time.Time                `json:"updated_at"`
+	RolledBackAt *time.Time               `json:"rolled_back_at,omitempty"`
+
+	// ReportDestinations are kept so the report of a job interrupted after its transactions were saved is still sent
+	ReportDestinations []string `json:"-"`
+}
+
+func (j *Job) IsActive() bool {
+	return j.Status == StatusPending || j.Status == StatusRunning
+}
+
+// CanRollBack reports a job that saved all the transactions it will, a failed job keeps the ones of the batches
+// committed before it failed
+func (j *Job) CanRollBack() bool {
+	return j.Status == StatusCompleted || j.Status == StatusFailed
+}
+
+// IsStale reports an active job whose owner stopped sending heartbeats, which happens when the instance
+// running it was restarted or crashed
+func (j *Job) IsStale(now time.Time, staleAfter time.Duration) bool {
+	return j.IsActive() && now.Sub(j.UpdatedAt) > staleAfter
+}
@@ -0,0 +1,47 @@
+package migration_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/sebastianreh/user-balance-api/internal/domain/migration"
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_Job_IsStale(t *testing.T) {
+	now := time.Now()
+
+	t.Run("When an active job stopped sending heartbeats", func(t *testing.T) {
+		job := migration.Job{Status: migration.StatusRunning, UpdatedAt: now.Add(-2 * time.Minute)}
+
+		assert.True(t, job.IsStale(now, time.Minute))
+	})
+
+	t.Run("When an active job was recently updated", func(t *testing.T) {
+		job := migration.Job{Status: migration.StatusPending, UpdatedAt: now.Add(-10 * time.Second)}
+
+		assert.False(t, job.IsStale(now, time.Minute))
+	})
+
+	t.Run("When a finished job is old", func(t *testing.T) {
+		job := migration.Job{Status: migration.StatusCompleted, UpdatedAt: now.Add(-time.Hour)}
+
+		assert.False(t, job.IsStale(now, time.Minute))
+	})
+}
+
+func Test_Job_CanRollBack(t *testing.T) {
+	t.Run("When the job saved all the transactions it will", func(t *testing.T) {
+		for _, status := range []string{migration.StatusCompleted, migration.StatusFailed} {
+			job := migration.Job{Status: status}
+			assert.True(t, job.CanRollBack(), status)
+		}
+	})
+
+	t.Run("When the job is active or already rolled back", func(t *testing.T) {
+		for _, status := range []string{migration.StatusPending, migration.StatusRunning, migration.StatusRolledBack} {
+			job := migration.Job{Status: status}
+			assert.False(t, job.CanRollBack(), status)
+		}
+	})
+}
@@ -0,0 +1,59 @@
+package migration
+
+import (
+	"errors"
+	"strconv"
+
+	"github.com/sebastianreh/user-balance-api/pkg/cursor"
+)
+
+const (
+	DefaultListLimit = 50
+	MaxListLimit     = 500
+)
+
+// ListOptions pages through jobs and through the transactions of a job, both ordered by id
+type ListOptions struct {
+	Limit int
+	After *Cursor
+}
+
+// Cursor holds the id of the last item of a page, the next page starts right after it
+type Cursor struct {
+	ID string `json:"id"`
+}
+
+type ListPage struct {
+	Migrations []Job  `json:"migrations"`
+	NextCursor string `json:"next_cursor,omitempty"`
+}
+
+func (c Cursor) Encode() string {
+	return cursor.Encode(c)
+}
+
+// DecodeJobCursor parses a cursor of the jobs list, jobs are identified by a sequence so anything else would fail
+// in the database
+func DecodeJobCursor(value string) (Cursor, error) {
+	position, err := DecodeTransactionCursor(value)
+	if err != nil || !isNumeric(position.ID) {
+		return position, errors.New(cursor.InvalidCursorError)
+	}
+
+	return position, nil
+}
+
+// DecodeTransactionCursor parses a cursor of the transactions of a job
+func DecodeTransactionCursor(value string) (Cursor, error) {
+	var position Cursor
+	if err := cursor.Decode(value, &position); err != nil || position.ID == "" {
+		return position, errors.New(cursor.InvalidCursorError)
+	}
+
+	return position, nil
+}
+
+func isNumeric(id string) bool {
+	_, err := strconv.ParseInt(id, 10, 64)
+	return err == nil
+}
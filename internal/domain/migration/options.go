@@ -0,0 +1,36 @@
+package migration
+
+const (
+	// ModeDefault validates the whole file before saving it, any rejected record fails the migration
+	ModeDefault = "default"
+	// ModePartial saves the valid records and reports every rejected one
+	ModePartial = "partial"
+	// ModeStrict saves the whole file in a single database transaction, a failure in any batch saves nothing
+	ModeStrict = "strict"
+)
+
+type Options struct {
+	// MigrationID is stamped on every saved transaction so the migration can be rolled back
+	MigrationID        string
+	Mode               string
+	ReportDestinations []string
+	UploadedBy         string
+	// Force imports a file that was already imported by another migration
+	Force bool
+	// CreateMissingUsers creates the unknown users of the file with their ids, it can't be used in strict mode
+	CreateMissingUsers bool
+	// Format is the records format of the file, csv when it's empty
+	Format string
+	// Profile maps the file columns by their header names, without one they are read by position
+	Profile *MappingProfile
+}
+
+type Hooks struct {
+	OnProgress func(progress Progress)
+	// OnRejects stores the rejected records of a batch, it's only called in partial mode
+	OnRejects func(rejects []Reject) error
+}
+
+func IsValidMode(mode string) bool {
+	return mode == ModeDefault || mode == ModePartial || mode == ModeStrict
+}
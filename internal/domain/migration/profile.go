@@ -0,0 +1,120 @@
+package migration
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+)
+
+const (
+	FieldID       = "id"
+	FieldUserID   = "user_id"
+	FieldAmount   = "amount"
+	FieldDateTime = "datetime"
+	// The user fields are optional, they fill the users created by a migration with create_missing_users
+	FieldFirstName = "first_name"
+	FieldLastName  = "last_name"
+	FieldEmail     = "email"
+)
+
+var profileNamePattern = regexp.MustCompile(`^[a-z0-9][a-z0-9_-]{0,63}$`)
+
+// MappingProfile tells which header names of a partner file hold every record field. A field is also found by its
+// own name, columns that are neither mapped nor ignored are skipped and defaults fill missing fields
+type MappingProfile struct {
+	Name           string              `json:"name"`
+	Columns        map[string][]string `json:"columns"`
+	IgnoredColumns []string            `json:"ignored_columns"`
+	Defaults       map[string]string   `json:"defaults"`
+	CreatedAt      time.Time           `json:"created_at"`
+
+	// Strict makes the files with a column that is neither mapped nor ignored invalid
+	Strict bool `json:"strict"`
+}
+
+// RecordFields are the fields of a migration record in the order they are validated
+func RecordFields() []string {
+	return []string{FieldID, FieldUserID, FieldAmount, FieldDateTime}
+}
+
+// UserFields are the optional fields of a migration record, they follow the record fields
+func UserFields() []string {
+	return []string{FieldFirstName, FieldLastName, FieldEmail}
+}
+
+// IsRecordHeader tells if the header names have the record fields in any order, optionally with user fields and
+// other columns that are skipped, such a file can be read by name without a profile
+func IsRecordHeader(header []string) bool {
+	names := make(map[string]bool, len(header))
+	for _, name := range header {
+		names[strings.ToLower(strings.TrimSpace(strings.TrimPrefix(name, "\ufeff")))] = true
+	}
+
+	for _, field := range RecordFields() {
+		if !names[field] {
+			return false
+		}
+	}
+
+	return len(names) == len(header)
+}
+
+func (p MappingProfile) Validate() error {
+	if !profileNamePattern.MatchString(p.Name) {
+		return errors.New("name must be lowercase letters, digits, '-' or '_' with up to 64 characters")
+	}
+
+	allFields := append(RecordFields(), UserFields()...)
+	fields := make(map[string]bool, len(allFields))
+	for _, field := range allFields {
+		fields[field] = true
+	}
+
+	headers := make(map[string]string)
+	claim := func(header, owner string) error {
+		name := strings.ToLower(strings.TrimSpace(header))
+		if name == "" {
+			return fmt.Errorf("%s has an empty column name", owner)
+		}
+
+		if other, taken := headers[name]; taken && other != owner {
+			return fmt.Errorf("column %q is used by %s and %s", header, other, owner)
+		}
+		headers[name] = owner
+		return nil
+	}
+
+	for _, field := range allFields {
+		if err := claim(field, field); err != nil {
+			return err
+		}
+	}
+
+	for field, aliases := range p.Columns {
+		if !fields[field] {
+			return fmt.Errorf("unknown field %q in columns", field)
+		}
+
+		for _, alias := range aliases {
+			if err := claim(alias, field); err != nil {
+				return err
+			}
+		}
+	}
+
+	for _, column := range p.IgnoredColumns {
+		if err := claim(column, "ignored_columns"); err != nil {
+			return err
+		}
+	}
+
+	for field := range p.Defaults {
+		if !fields[field] {
+			return fmt.Errorf("unknown field %q in defaults", field)
+		}
+	}
+
+	return nil
+}
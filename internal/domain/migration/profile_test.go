@@ -0,0 +1,87 @@
+package migration_test
+
+import (
+	"testing"
+
+	"github.com/sebastianreh/user-balance-api/internal/domain/migration"
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_MappingProfile_Validate(t *testing.T) {
+	t.Run("When the profile has aliases, ignored columns and defaults", func(t *testing.T) {
+		profile := migration.MappingProfile{
+			Name:           "partner-a",
+			Columns:        map[string][]string{migration.FieldUserID: {"customer"}},
+			IgnoredColumns: []string{"notes"},
+			Defaults:       map[string]string{migration.FieldDateTime: "2024-01-01T00:00:00Z"},
+		}
+
+		assert.Nil(t, profile.Validate())
+	})
+
+	t.Run("When the profile maps the user fields", func(t *testing.T) {
+		profile := migration.MappingProfile{
+			Name:     "legacy",
+			Columns:  map[string][]string{migration.FieldEmail: {"mail"}},
+			Defaults: map[string]string{migration.FieldLastName: "Legacy"},
+		}
+
+		assert.Nil(t, profile.Validate())
+	})
+
+	t.Run("When the name is not valid", func(t *testing.T) {
+		profile := migration.MappingProfile{Name: "Partner A"}
+
+		assert.ErrorContains(t, profile.Validate(), "name must be")
+	})
+
+	t.Run("When a column is mapped to an unknown field", func(t *testing.T) {
+		profile := migration.MappingProfile{Name: "partner", Columns: map[string][]string{"currency": {"cur"}}}
+
+		assert.EqualError(t, profile.Validate(), `unknown field "currency" in columns`)
+	})
+
+	t.Run("When a default is set for an unknown field", func(t *testing.T) {
+		profile := migration.MappingProfile{Name: "partner", Defaults: map[string]string{"currency": "ARS"}}
+
+		assert.EqualError(t, profile.Validate(), `unknown field "currency" in defaults`)
+	})
+
+	t.Run("When an ignored column is the name of a field", func(t *testing.T) {
+		profile := migration.MappingProfile{Name: "partner", IgnoredColumns: []string{"Amount"}}
+
+		assert.EqualError(t, profile.Validate(), `column "Amount" is used by amount and ignored_columns`)
+	})
+
+	t.Run("When an alias is empty", func(t *testing.T) {
+		profile := migration.MappingProfile{Name: "partner", Columns: map[string][]string{migration.FieldID: {" "}}}
+
+		assert.EqualError(t, profile.Validate(), "id has an empty column name")
+	})
+}
+
+func Test_IsRecordHeader(t *testing.T) {
+	t.Run("When the header has the field names in another order", func(t *testing.T) {
+		assert.True(t, migration.IsRecordHeader([]string{"\ufeffAmount", "id", " datetime", "user_id"}))
+	})
+
+	t.Run("When the header has some of the user fields", func(t *testing.T) {
+		assert.True(t, migration.IsRecordHeader([]string{"id", "user_id", "amount", "datetime", "Email", "first_name"}))
+	})
+
+	t.Run("When the header has a column that is not a field", func(t *testing.T) {
+		assert.True(t, migration.IsRecordHeader([]string{"id", "user_id", "amount", "datetime", "notes"}))
+	})
+
+	t.Run("When the header lacks a record field", func(t *testing.T) {
+		assert.False(t, migration.IsRecordHeader([]string{"id", "user_id", "amount", "notes"}))
+	})
+
+	t.Run("When the header has other names", func(t *testing.T) {
+		assert.False(t, migration.IsRecordHeader([]string{"1", "1", "100", "2023-09-14T20:00:00Z"}))
+	})
+
+	t.Run("When the header repeats a field name", func(t *testing.T) {
+		assert.False(t, migration.IsRecordHeader([]string{"id", "id", "user_id", "amount", "datetime"}))
+	})
+}
@@ -0,0 +1,22 @@
+package migration
+
+const (
+	RejectReasonValidation  = "validation"
+	RejectReasonUnknownUser = "unknown_user"
+	RejectReasonDuplicateID = "duplicate_id"
+	RejectReasonZeroAmount  = "zero_amount"
+	// RejectReasonDuplicateEmail is a record of a user that could not be created because its email is taken
+	RejectReasonDuplicateEmail = "duplicate_email"
+)
+
+// Reject is a record left out of a partial migration, or one a dry run found a problem with
+type Reject struct {
+	Line   int    `json:"line"`
+	Raw    string `json:"raw"`
+	Reason string `json:"reason"`
+	Detail string `json:"detail"`
+}
+
+func RejectsCSVHeader() []string {
+	return []string{"line", "reason", "detail", "raw"}
+}
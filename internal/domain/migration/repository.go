@@ -0,0 +1,62 @@
+package migration
+
+import (
+	"context"
+	"time"
+
+	"github.com/sebastianreh/user-balance-api/internal/domain/outbox"
+	"github.com/sebastianreh/user-balance-api/internal/domain/report"
+)
+
+const (
+	RepositoryName          = "MigrationJobRepository"
+	NotFoundError           = "migration job not found"
+	RollbackStatusError     = "only completed or failed migrations can be rolled back"
+	RollbackEditedError     = "the migration transactions were edited after it ran"
+	DuplicateFileError      = "the file was already imported"
+	StrictMissingUsersError = "create_missing_users can't be used in strict mode"
+	ProfileRepositoryName   = "MappingProfileRepository"
+	ProfileNotFoundError    = "mapping profile not found"
+	DuplicateProfileError   = "mapping profile already exists"
+)
+
+type Repository interface {
+	// Save fails with DuplicateFileError when the job is not forced and another one that is not forced imported or is
+	// importing the same content, which catches the uploads that passed FindImported together
+	Save(ctx context.Context, job Job) (string, error)
+	// Update saves the state of an active job, a job that was already finished fails with NotFoundError
+	Update(ctx context.Context, job Job) error
+	// Finish saves the final state of an active job and the messages it causes in a single database transaction, so
+	// they are only sent when the job is saved. A job that was already finished fails with NotFoundError
+	Finish(ctx context.Context, job Job, messages ...outbox.Message) error
+	FindByID(ctx context.Context, jobID string) (Job, error)
+	// FindImported returns the newest job that imported or is importing a file with the checksum or the content hash,
+	// failed and rolled back jobs don't count
+	FindImported(ctx context.Context, checksum, contentHash string) (Job, error)
+	// List returns the jobs from the newest to the oldest
+	List(ctx context.Context, options ListOptions) ([]Job, error)
+	// Rollback soft deletes the transactions of a completed or failed job and marks it as rolled back along with the
+	// messages it causes in a single database transaction, nothing changes when any of them was edited after the job
+	// saved it
+	Rollback(ctx context.Context, jobID string, messages ...outbox.Message) (int64, error)
+	// Heartbeat refreshes the job update time so other instances know it's still being processed
+	Heartbeat(ctx context.Context, jobID string) error
+	// FindStale returns the active jobs that were not updated since staleBefore
+	FindStale(ctx context.Context, staleBefore time.Time) ([]Job, error)
+	// FailStale marks as failed every active job that was not updated since staleBefore
+	FailStale(ctx context.Context, staleBefore time.Time, reason string) (int64, error)
+	// Saved summarizes what the job left in the database, the transactions it saved with their users and its rejects
+	// by reason
+	Saved(ctx context.Context, jobID string) (report.MigrationSummary, error)
+	SaveRejects(ctx context.Context, jobID string, rejects []Reject) error
+	// ForEachReject goes through the job rejects ordered by line without loading them all in memory
+	ForEachReject(ctx context.Context, jobID string, handle func(reject Reject) error) error
+}
+
+type ProfileRepository interface {
+	// Save stores a new profile and returns it with its creation time
+	Save(ctx context.Context, profile MappingProfile) (MappingProfile, error)
+	FindByName(ctx context.Context, name string) (MappingProfile, error)
+	List(ctx context.Context) ([]MappingProfile, error)
+	Delete(ctx context.Context, name string) error
+}
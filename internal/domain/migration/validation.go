@@ -0,0 +1,11 @@
+package migration
+
+import "github.com/sebastianreh/user-balance-api/internal/domain/report"
+
+// ValidationReport is the outcome of a dry run, Summary is what the import would produce with the valid records
+type ValidationReport struct {
+	Valid      bool                    `json:"valid"`
+	Summary    report.MigrationSummary `json:"summary"`
+	Problems   []Reject                `json:"problems"`
+	FileErrors []string                `json:"file_errors,omitempty"`
+}
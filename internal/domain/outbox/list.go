@@ -0,0 +1,65 @@
+package outbox
+
+import (
+	"errors"
+	"strconv"
+
+	"github.com/sebastianreh/user-balance-api/pkg/cursor"
+)
+
+const (
+	DefaultListLimit = 50
+	MaxListLimit     = 500
+)
+
+// ListOptions pages through the messages from the newest, Status and Reference filter them when they are set
+type ListOptions struct {
+	Status    string
+	Reference string
+	Limit     int
+	After     *Cursor
+}
+
+// Cursor holds the id of the last message of a page, the next page starts right after it
+type Cursor struct {
+	ID string `json:"id"`
+}
+
+type ListPage struct {
+	Messages   []Message `json:"messages"`
+	NextCursor string    `json:"next_cursor,omitempty"`
+}
+
+// NewListPage pages the messages listed with one extra message, which tells if there is a next page
+func NewListPage(messages []Message, limit int) ListPage {
+	page := ListPage{Messages: messages}
+	if len(messages) > limit {
+		page.Messages = messages[:limit]
+		page.NextCursor = Cursor{ID: page.Messages[limit-1].ID}.Encode()
+	}
+
+	return page
+}
+
+func (c Cursor) Encode() string {
+	return cursor.Encode(c)
+}
+
+// DecodeCursor parses a cursor of the messages list, messages are identified by a sequence so anything else would
+// fail in the database
+func DecodeCursor(value string) (Cursor, error) {
+	var position Cursor
+	if err := cursor.Decode(value, &position); err != nil {
+		return position, errors.New(cursor.InvalidCursorError)
+	}
+
+	if _, err := strconv.ParseInt(position.ID, 10, 64); err != nil {
+		return position, errors.New(cursor.InvalidCursorError)
+	}
+
+	return position, nil
+}
+
+func IsStatus(status string) bool {
+	return status == StatusPending || status == StatusSent || status == StatusFailed
+}
@@ -0,0 +1,79 @@
+package outbox
+
+import (
+	"encoding/json"
+	"time"
+)
+
+const (
+	StatusPending = "pending"
+	StatusSent    = "sent"
+	// StatusFailed is a message that ran out of attempts, it's only sent again when it's redriven
+	StatusFailed = "failed"
+	KindEmail    = "email"
+	// KindNotification is a notifier.Delivery, sent through the channel of its route
+	KindNotification = "notification"
+	// KindWebhook is a webhook.Delivery, sent signed to its subscription
+	KindWebhook = "webhook"
+)
+
+// Message is a side effect saved in the same database transaction as the change that caused it, the dispatcher
+// delivers it afterwards
+type Message struct {
+	ID   string `json:"id"`
+	Kind string `json:"kind"`
+	// Description tells what the message is about in the admin endpoints, the payload is not shown there
+	Description string `json:"description"`
+	// Reference groups the messages of an owner, like the deliveries of a webhook subscription
+	Reference     string          `json:"reference,omitempty"`
+	Payload       json.RawMessage `json:"-"`
+	Status        string          `json:"status"`
+	Attempts      int             `json:"attempts"`
+	LastError     string          `json:"last_error,omitempty"`
+	NextAttemptAt time.Time       `json:"next_attempt_at"`
+	CreatedAt     time.Time       `json:"created_at"`
+	SentAt        *time.Time      `json:"sent_at,omitempty"`
+	// History is only loaded when a single message is read
+	History []Attempt `json:"history,omitempty"`
+}
+
+// Attempt is a delivery of a message, Error is empty when it was sent
+type Attempt struct {
+	AttemptedAt time.Time `json:"attempted_at"`
+	Error       string    `json:"error,omitempty"`
+}
+
+// Backoff doubles the wait between attempts from base up to limit
+type Backoff struct {
+	Base        time.Duration
+	Limit       time.Duration
+	MaxAttempts int
+}
+
+func NewMessage(kind, description string, payload any) (Message, error) {
+	encoded, err := json.Marshal(payload)
+	if err != nil {
+		return Message{}, err
+	}
+
+	return Message{Kind: kind, Description: description, Payload: encoded, Status: StatusPending}, nil
+}
+
+// NextAttempt returns when the message is tried again after a failed attempt, attempts counts the failed one.
+// It returns false when the message ran out of attempts
+func (b Backoff) NextAttempt(now time.Time, attempts int) (time.Time, bool) {
+	if attempts >= b.MaxAttempts {
+		return time.Time{}, false
+	}
+
+	wait := b.Base
+	for i := 1; i < attempts && wait < b.Limit; i++ {
+		wait *= 2
+	}
+
+	return now.Add(min(wait, b.Limit)), true
+}
+
+func (m Message) Redrivable() bool {
+	return m.Status != StatusSent
+}
@@ -0,0 +1,47 @@
+package outbox_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/sebastianreh/user-balance-api/internal/domain/outbox"
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_Backoff_NextAttempt(t *testing.T) {
+	now := time.Date(2024, 9, 13, 10, 0, 0, 0, time.UTC)
+	backoff := outbox.Backoff{Base: 30 * time.Second, Limit: 5 * time.Minute, MaxAttempts: 6}
+
+	t.Run("When the wait doubles after every failed attempt", func(t *testing.T) {
+		for attempts, expected := range map[int]time.Duration{1: 30 * time.Second, 2: time.Minute, 4: 4 * time.Minute} {
+			next, retry := backoff.NextAttempt(now, attempts)
+			assert.True(t, retry)
+			assert.Equal(t, now.Add(expected), next)
+		}
+	})
+
+	t.Run("When the wait reaches its limit", func(t *testing.T) {
+		next, retry := backoff.NextAttempt(now, 5)
+		assert.True(t, retry)
+		assert.Equal(t, now.Add(5*time.Minute), next)
+	})
+
+	t.Run("When the message ran out of attempts", func(t *testing.T) {
+		_, retry := backoff.NextAttempt(now, 6)
+		assert.False(t, retry)
+	})
+}
+
+func Test_NewMessage(t *testing.T) {
+	t.Run("When the payload is encoded as JSON", func(t *testing.T) {
+		message, err := outbox.NewMessage(outbox.KindEmail, "Migration Report", map[string]string{"Subject": "Report"})
+		assert.Nil(t, err)
+		assert.Equal(t, outbox.StatusPending, message.Status)
+		assert.JSONEq(t, `{"Subject":"Report"}`, string(message.Payload))
+	})
+
+	t.Run("When the payload can't be encoded", func(t *testing.T) {
+		_, err := outbox.NewMessage(outbox.KindEmail, "Migration Report", make(chan int))
+		assert.Error(t, err)
+	})
+}
@@ -0,0 +1,34 @@
+package outbox
+
+import (
+	"context"
+	"time"
+)
+
+const (
+	RepositoryName     = "OutboxRepository"
+	NotFoundError      = "outbox message not found"
+	NotRedrivableError = "the message was already sent"
+	UnknownKindError   = "unknown outbox message kind"
+)
+
+// Repository stores the messages. They are saved by the repository of the change that causes them when they can, in
+// its own database transaction
+type Repository interface {
+	// Save saves messages that are not caused by a change saved along with them
+	Save(ctx context.Context, messages ...Message) error
+	// ClaimDue locks up to limit pending messages whose next attempt is due for lease, so no other dispatcher takes
+	// them while they are being sent. A message is claimed again when its lease ends before it's marked
+	ClaimDue(ctx context.Context, limit int, lease time.Duration) ([]Message, error)
+	// MarkSent records the successful attempt
+	MarkSent(ctx context.Context, messageID string) error
+	// MarkFailed records the failed attempt, the message is tried again at nextAttemptAt or is failed when it's nil
+	MarkFailed(ctx context.Context, messageID, attemptError string, nextAttemptAt *time.Time) error
+	List(ctx context.Context, options ListOptions) ([]Message, error)
+	// FindByID returns the message with its attempts
+	FindByID(ctx context.Context, messageID string) (Message, error)
+	// Redrive makes a message that was not sent due right away with its attempts reset, its history is kept
+	Redrive(ctx context.Context, messageID string) (Message, error)
+	// Duplicate saves a pending copy of a message, so a message that was sent can be sent again
+	Duplicate(ctx context.Context, messageID string) (Message, error)
+}
@@ -1,6 +1,40 @@
 package report
 
+import (
+	"math"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	roundToCents = 100
+)
+
 type MigrationSummary struct {
-	TotalRecords int
-	UsersUpdated int
+	JobID        string `json:"-"`
+	TotalRecords int    `json:"total_records"`
+	UsersUpdated int    `json:"users_updated"`
+	// UsersCreated counts the unknown users created by a migration with create_missing_users
+	UsersCreated    int            `json:"users_created,omitempty"`
+	RejectedRecords int            `json:"rejected_records"`
+	RejectsByReason map[string]int `json:"rejects_by_reason,omitempty"`
+	// ProcessingTime is how long the job took to read and save the file, it's only shown in the report
+	ProcessingTime time.Duration `json:"-"`
+}
+
+// BalancesCSVHeader is the header of the balances attached to the report, the columns of the expected output of the
+// transactions generator so both files can be diffed
+func BalancesCSVHeader() []string {
+	return []string{"user_id", "balance", "total_debts", "total_credits"}
+}
+
+// FormatCSVAmount writes the amount rounded to cents and always with a decimal point, like the generator does
+func FormatCSVAmount(amount float64) string {
+	formatted := strconv.FormatFloat(math.Round(amount*roundToCents)/roundToCents, 'f', -1, 64)
+	if !strings.Contains(formatted, ".") {
+		formatted += ".0"
+	}
+
+	return formatted
 }
@@ -0,0 +1,93 @@
+package transaction
+
+import (
+	"errors"
+
+	"github.com/sebastianreh/user-balance-api/pkg/cursor"
+)
+
+const (
+	SortByDate   = "date"
+	SortByAmount = "amount"
+
+	SortAsc  = "asc"
+	SortDesc = "desc"
+
+	TypeCredit = "credit"
+	TypeDebit  = "debit"
+
+	DefaultListLimit = 50
+	MaxListLimit     = 500
+
+	// cursorTimeLayout keeps the microsecond precision stored by Postgres so no row is skipped between pages
+	cursorTimeLayout = "2006-01-02T15:04:05.999999Z07:00"
+)
+
+type ListOptions struct {
+	UserID                string
+	FromDate              string
+	ToDate                string
+	MinAmount             *float64
+	MaxAmount             *float64
+	Type                  string
+	SortBy                string
+	SortOrder             string
+	Limit                 int
+	IncludeRunningBalance bool
+	After                 *Cursor
+}
+
+// Cursor holds the sort key of the last transaction of a page, the next page starts right after it
+type Cursor struct {
+	SortBy    string  `json:"s"`
+	SortOrder string  `json:"o"`
+	ID        string  `json:"id"`
+	DateTime  string  `json:"d,omitempty"`
+	Amount    float64 `json:"a,omitempty"`
+}
+
+type ListItem struct {
+	Transaction
+	// RunningBalance is the user balance right after this transaction, in chronological order
+	RunningBalance *float64 `json:"running_balance,omitempty"`
+}
+
+type ListPage struct {
+	Transactions []ListItem `json:"transactions"`
+	NextCursor   string     `json:"next_cursor,omitempty"`
+}
+
+func NewCursor(item ListItem, options ListOptions) Cursor {
+	position := Cursor{
+		SortBy:    options.SortBy,
+		SortOrder: options.SortOrder,
+		ID:        item.ID,
+	}
+
+	switch options.SortBy {
+	case SortByAmount:
+		position.Amount = item.Amount
+	default:
+		position.DateTime = item.DateTime.Format(cursorTimeLayout)
+	}
+
+	return position
+}
+
+func (c Cursor) Encode() string {
+	return cursor.Encode(c)
+}
+
+// DecodeCursor parses an opaque cursor and checks it was issued for the same sort field and order
+func DecodeCursor(value, sortBy, sortOrder string) (Cursor, error) {
+	var position Cursor
+	if err := cursor.Decode(value, &position); err != nil || position.ID == "" {
+		return position, errors.New(cursor.InvalidCursorError)
+	}
+
+	if position.SortBy != sortBy || position.SortOrder != sortOrder {
+		return position, errors.New(cursor.SortMismatchError)
+	}
+
+	return position, nil
+}
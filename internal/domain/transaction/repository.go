@@ -1,6 +1,10 @@
 package transaction
 
-import "context"
+import (
+	"context"
+
+	"github.com/sebastianreh/user-balance-api/internal/domain/outbox"
+)
 
 const (
 	RepositoryName            = "TransactionRepository"
@@ -9,11 +13,38 @@ const (
 	ZeroAmountError           = "amount must be different from zero"
 )
 
+// Rejection tells why the transaction in Index of a batch was not saved
+type Rejection struct {
+	Index  int
+	Reason string
+}
+
 type Repository interface {
-	Save(ctx context.Context, transaction Transaction) error
+	// Save saves the transaction along with the outbox messages it causes in a single database transaction
+	Save(ctx context.Context, transaction Transaction, messages ...outbox.Message) error
 	SaveBatch(ctx context.Context, transactions []Transaction) error
+	// SaveBatchSkippingRejected saves the batch leaving out the transactions that can't be saved instead of failing
+	SaveBatchSkippingRejected(ctx context.Context, transactions []Transaction) ([]Rejection, error)
+	// CreateStage opens a staging area where batches are kept apart until CommitStage saves all of them at once,
+	// DiscardStage drops a stage that won't be committed
+	CreateStage(ctx context.Context) (string, error)
+	StageBatch(ctx context.Context, stageID string, transactions []Transaction) error
+	CommitStage(ctx context.Context, stageID string) (int64, error)
+	DiscardStage(ctx context.Context, stageID string) error
 	Update(ctx context.Context, transaction Transaction) error
 	FindByID(ctx context.Context, transactionID string) (Transaction, error)
+	// FindExistingIDs returns which of the ids are already taken, deleted transactions included
+	FindExistingIDs(ctx context.Context, transactionIDs []string) (map[string]bool, error)
 	FindByUserIDWithOptions(ctx context.Context, userID, fromDate, toDate string) ([]Transaction, error)
-	Delete(ctx context.Context, transactionID string) error
+	List(ctx context.Context, options ListOptions) ([]ListItem, error)
+	// ListByMigration returns the transactions saved by a migration ordered by id, starting after afterID
+	ListByMigration(ctx context.Context, migrationID, afterID string, limit int) ([]ListItem, error)
+	// SummarizeMigration adds up the amounts saved by a migration and returns its largest transactions
+	SummarizeMigration(ctx context.Context, migrationID string, largest int) (MigrationTotals, error)
+	// ForEachMigrationUser hands the delta of every user of a migration ordered by user id, without loading them all
+	ForEachMigrationUser(ctx context.Context, migrationID string, handle func(delta UserDelta) error) error
+	// FindMigrationUserIDs returns the users of the transactions saved by a migration, deleted ones included
+	FindMigrationUserIDs(ctx context.Context, migrationID string) ([]string, error)
+	// Delete soft deletes the transaction along with the outbox messages it causes in a single database transaction
+	Delete(ctx context.Context, transactionID string, messages ...outbox.Message) error
 }
@@ -0,0 +1,20 @@
+package transaction
+
+// MigrationTotals adds up the transactions a migration saved that were not deleted, Debited is a positive amount
+type MigrationTotals struct {
+	Credited float64
+	Debited  float64
+	// Largest are the transactions with the largest absolute amount, from the largest
+	Largest []Transaction
+}
+
+// UserDelta is the change a migration made to a user, with the balance of every transaction of the user right after
+type UserDelta struct {
+	UserID       string
+	NetAmount    float64
+	Credits      int
+	Debits       int
+	Balance      float64
+	TotalDebits  int
+	TotalCredits int
+}
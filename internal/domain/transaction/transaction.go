@@ -11,6 +11,13 @@ type Transaction struct {
 	Amount    float64    `json:"amount"`
 	DateTime  *time.Time `json:"date_time"`
 	IsDeleted bool       `json:"-"`
+	// MigrationID is the migration that saved the transaction, empty when it was created through the API
+	MigrationID string `json:"migration_id,omitempty"`
+}
+
+// RecordKeys are the JSON keys of a transaction in the order of the migration record fields
+func RecordKeys() []string {
+	return []string{"id", "user_id", "amount", "date_time"}
 }
 
 func CreateTransactionByRecord(record []string) (Transaction, error) {
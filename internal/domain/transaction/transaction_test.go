@@ -55,3 +55,39 @@ func Test_CreateTransactionByRecord(t *testing.T) {
 		assert.Equal(t, transaction.Transaction{}, transactionEntity)
 	})
 }
+
+func Test_Cursor(t *testing.T) {
+	dateTime := time.Date(2024, 9, 13, 10, 0, 0, 123456000, time.UTC)
+	item := transaction.ListItem{Transaction: transaction.Transaction{ID: "7", Amount: -25.5, DateTime: &dateTime}}
+
+	t.Run("When cursor is sorted by date", func(t *testing.T) {
+		options := transaction.ListOptions{SortBy: transaction.SortByDate, SortOrder: transaction.SortDesc}
+		encoded := transaction.NewCursor(item, options).Encode()
+
+		cursor, err := transaction.DecodeCursor(encoded, transaction.SortByDate, transaction.SortDesc)
+
+		assert.Nil(t, err)
+		assert.Equal(t, "7", cursor.ID)
+		assert.Equal(t, "2024-09-13T10:00:00.123456Z", cursor.DateTime)
+	})
+
+	t.Run("When cursor is sorted by amount", func(t *testing.T) {
+		options := transaction.ListOptions{SortBy: transaction.SortByAmount, SortOrder: transaction.SortAsc}
+		encoded := transaction.NewCursor(item, options).Encode()
+
+		cursor, err := transaction.DecodeCursor(encoded, transaction.SortByAmount, transaction.SortAsc)
+
+		assert.Nil(t, err)
+		assert.Equal(t, -25.5, cursor.Amount)
+		assert.Empty(t, cursor.DateTime)
+	})
+
+	t.Run("When cursor was issued for a different sort", func(t *testing.T) {
+		options := transaction.ListOptions{SortBy: transaction.SortByAmount, SortOrder: transaction.SortAsc}
+		encoded := transaction.NewCursor(item, options).Encode()
+
+		_, err := transaction.DecodeCursor(encoded, transaction.SortByDate, transaction.SortAsc)
+
+		assert.NotNil(t, err)
+	})
+}
@@ -0,0 +1,38 @@
+package upload
+
+import (
+	"context"
+	"io"
+	"time"
+)
+
+const (
+	RepositoryName      = "UploadRepository"
+	NotFoundError       = "upload not found"
+	OffsetMismatchError = "the chunk must start at the received bytes"
+	SizeMismatchError   = "the Content-Range total must be the upload size"
+	TooLargeError       = "the file is larger than the upload limit"
+	IncompleteError     = "the upload is missing bytes"
+	FinalizedError      = "the upload was already finalized"
+	BusyError           = "the upload is being finalized"
+)
+
+type Repository interface {
+	// Create stores a new empty upload and returns it with its ID
+	Create(ctx context.Context, upload Upload) (Upload, error)
+	FindByID(ctx context.Context, uploadID string) (Upload, error)
+	// Append writes the chunk after the received bytes, offset must be the number of bytes received so far. The
+	// bytes written before the chunk is interrupted are kept so the client can resume after them
+	Append(ctx context.Context, uploadID string, offset int64, chunk io.Reader) (Upload, error)
+	// Claim reserves a complete upload to hand its file to a migration, until it's finished or released no chunk
+	// can be written and no other migration can claim it
+	Claim(ctx context.Context, uploadID string) (Upload, error)
+	Release(ctx context.Context, uploadID string) error
+	// Finish records the migration job that took over the upload file
+	Finish(ctx context.Context, uploadID, jobID string) error
+	// Delete removes the upload, the file of a finalized upload belongs to its migration and is kept
+	Delete(ctx context.Context, uploadID string) error
+	// DeleteExpired removes the uploads that were not updated since updatedBefore, like Delete it keeps the file of
+	// the finalized ones
+	DeleteExpired(ctx context.Context, updatedBefore time.Time) (int, error)
+}
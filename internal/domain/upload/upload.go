@@ -0,0 +1,99 @@
+package upload
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	// StatusReceiving is an upload still missing bytes, its chunks can be sent again from the received bytes
+	StatusReceiving = "receiving"
+	// StatusComplete is an upload with every byte that can be finalized as a migration
+	StatusComplete = "complete"
+	// StatusFinalized is an upload whose file was handed to the migration JobID
+	StatusFinalized = "finalized"
+)
+
+// Upload is a migration file sent in chunks, the received bytes are stored on the local disk until it's finalized
+type Upload struct {
+	ID            string    `json:"upload_id"`
+	FileName      string    `json:"file_name"`
+	Size          int64     `json:"size"`
+	ReceivedBytes int64     `json:"received_bytes"`
+	Status        string    `json:"status"`
+	JobID         string    `json:"job_id,omitempty"`
+	CreatedAt     time.Time `json:"created_at"`
+	UpdatedAt     time.Time `json:"updated_at"`
+	// Path is where the received bytes are stored
+	Path string `json:"-"`
+}
+
+var contentRangePattern = regexp.MustCompile(`^bytes (\d+)-(\d+)/(\d+)$`)
+
+// Chunk is the byte range of the file sent in a request, as told by its Content-Range header
+type Chunk struct {
+	Start int64
+	End   int64
+	Total int64
+}
+
+// ParseContentRange reads a Content-Range header like "bytes 0-1048575/5242880", the end is inclusive
+func ParseContentRange(value string) (Chunk, error) {
+	matches := contentRangePattern.FindStringSubmatch(strings.TrimSpace(value))
+	if matches == nil {
+		return Chunk{}, errors.New("the Content-Range header must be like bytes start-end/total")
+	}
+
+	var values [3]int64
+	for i, match := range matches[1:] {
+		position, err := strconv.ParseInt(match, 10, 64)
+		if err != nil {
+			return Chunk{}, fmt.Errorf("the Content-Range position %s is out of range", match)
+		}
+		values[i] = position
+	}
+
+	chunk := Chunk{Start: values[0], End: values[1], Total: values[2]}
+	if chunk.Start > chunk.End || chunk.End >= chunk.Total {
+		return Chunk{}, errors.New("the Content-Range header must have start <= end < total")
+	}
+
+	return chunk, nil
+}
+
+func (c Chunk) Length() int64 {
+	return c.End - c.Start + 1
+}
+
+// Validate checks a new upload, the file name is only kept for the migration job
+func (u *Upload) Validate() error {
+	if strings.TrimSpace(u.FileName) == "" {
+		return errors.New("file_name is required")
+	}
+
+	if u.Size <= 0 {
+		return errors.New("size must be greater than 0")
+	}
+
+	return nil
+}
+
+func (u *Upload) IsComplete() bool {
+	return u.ReceivedBytes == u.Size
+}
+
+// RefreshStatus sets the status that matches the received bytes and the migration job
+func (u *Upload) RefreshStatus() {
+	switch {
+	case u.JobID != "":
+		u.Status = StatusFinalized
+	case u.IsComplete():
+		u.Status = StatusComplete
+	default:
+		u.Status = StatusReceiving
+	}
+}
@@ -0,0 +1,73 @@
+package upload_test
+
+import (
+	"testing"
+
+	"github.com/sebastianreh/user-balance-api/internal/domain/upload"
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_ParseContentRange(t *testing.T) {
+	t.Run("When the range is valid", func(t *testing.T) {
+		chunk, err := upload.ParseContentRange("bytes 0-1023/4096")
+
+		assert.Nil(t, err)
+		assert.Equal(t, upload.Chunk{Start: 0, End: 1023, Total: 4096}, chunk)
+		assert.Equal(t, int64(1024), chunk.Length())
+	})
+
+	t.Run("When the header is not a byte range", func(t *testing.T) {
+		_, err := upload.ParseContentRange("bytes */4096")
+
+		assert.EqualError(t, err, "the Content-Range header must be like bytes start-end/total")
+	})
+
+	t.Run("When the range ends past the file", func(t *testing.T) {
+		_, err := upload.ParseContentRange("bytes 0-4096/4096")
+
+		assert.EqualError(t, err, "the Content-Range header must have start <= end < total")
+	})
+
+	t.Run("When a position doesn't fit in 64 bits", func(t *testing.T) {
+		_, err := upload.ParseContentRange("bytes 0-1/99999999999999999999")
+
+		assert.EqualError(t, err, "the Content-Range position 99999999999999999999 is out of range")
+	})
+}
+
+func Test_Upload_RefreshStatus(t *testing.T) {
+	t.Run("When bytes are missing", func(t *testing.T) {
+		current := upload.Upload{Size: 10, ReceivedBytes: 4}
+		current.RefreshStatus()
+
+		assert.Equal(t, upload.StatusReceiving, current.Status)
+	})
+
+	t.Run("When every byte was received", func(t *testing.T) {
+		current := upload.Upload{Size: 10, ReceivedBytes: 10}
+		current.RefreshStatus()
+
+		assert.Equal(t, upload.StatusComplete, current.Status)
+	})
+
+	t.Run("When the upload was handed to a migration", func(t *testing.T) {
+		current := upload.Upload{Size: 10, ReceivedBytes: 10, JobID: "1"}
+		current.RefreshStatus()
+
+		assert.Equal(t, upload.StatusFinalized, current.Status)
+	})
+}
+
+func Test_Upload_Validate(t *testing.T) {
+	t.Run("When the file name is missing", func(t *testing.T) {
+		current := upload.Upload{FileName: " ", Size: 10}
+
+		assert.EqualError(t, current.Validate(), "file_name is required")
+	})
+
+	t.Run("When the size is not positive", func(t *testing.T) {
+		current := upload.Upload{FileName: "migration.csv"}
+
+		assert.EqualError(t, current.Validate(), "size must be greater than 0")
+	})
+}
@@ -0,0 +1,103 @@
+package user
+
+import (
+	"errors"
+	"strconv"
+
+	"github.com/sebastianreh/user-balance-api/internal/domain/balance"
+	"github.com/sebastianreh/user-balance-api/pkg/cursor"
+)
+
+const (
+	SortByID    = "id"
+	SortByName  = "name"
+	SortByEmail = "email"
+
+	SortAsc  = "asc"
+	SortDesc = "desc"
+
+	DeletedExclude = "false"
+	DeletedOnly    = "true"
+	DeletedAll     = "all"
+
+	DefaultListLimit = 50
+	MaxListLimit     = 500
+
+	InvalidCursorError = cursor.InvalidCursorError
+)
+
+type ListOptions struct {
+	NamePrefix     string
+	Deleted        string
+	SortBy         string
+	SortOrder      string
+	Limit          int
+	IncludeBalance bool
+	After          *Cursor
+}
+
+// Cursor holds the sort key of the last user of a page, the next page starts right after it
+type Cursor struct {
+	SortBy    string `json:"s"`
+	SortOrder string `json:"o"`
+	ID        string `json:"id"`
+	FirstName string `json:"fn,omitempty"`
+	LastName  string `json:"ln,omitempty"`
+	Email     string `json:"e,omitempty"`
+}
+
+// ListItem is a user as it's listed, the deleted ones can be listed too so it shows whether the user is deleted
+type ListItem struct {
+	ID        string               `json:"id"`
+	FirstName string               `json:"first_name"`
+	LastName  string               `json:"last_name"`
+	Email     string               `json:"email"`
+	IsDeleted bool                 `json:"is_deleted"`
+	Balance   *balance.UserBalance `json:"balance,omitempty"`
+}
+
+type ListPage struct {
+	Users      []ListItem `json:"users"`
+	NextCursor string     `json:"next_cursor,omitempty"`
+}
+
+func NewCursor(item ListItem, options ListOptions) Cursor {
+	position := Cursor{
+		SortBy:    options.SortBy,
+		SortOrder: options.SortOrder,
+		ID:        item.ID,
+	}
+
+	switch options.SortBy {
+	case SortByName:
+		position.FirstName = item.FirstName
+		position.LastName = item.LastName
+	case SortByEmail:
+		position.Email = item.Email
+	}
+
+	return position
+}
+
+func (c Cursor) Encode() string {
+	return cursor.Encode(c)
+}
+
+// DecodeCursor parses an opaque cursor and checks it was issued for the same sort field and order
+func DecodeCursor(value, sortBy, sortOrder string) (Cursor, error) {
+	var position Cursor
+	if err := cursor.Decode(value, &position); err != nil {
+		return position, errors.New(InvalidCursorError)
+	}
+
+	// Users are identified by a sequence, anything else would fail in the database
+	if _, err := strconv.ParseInt(position.ID, 10, 64); err != nil {
+		return position, errors.New(InvalidCursorError)
+	}
+
+	if position.SortBy != sortBy || position.SortOrder != sortOrder {
+		return position, errors.New(cursor.SortMismatchError)
+	}
+
+	return position, nil
+}
@@ -2,16 +2,29 @@ package user
 
 import (
 	"context"
+
+	"github.com/sebastianreh/user-balance-api/internal/domain/outbox"
 )
 
 const (
-	RepositoryName = "UserRepository"
-	NotFoundError  = "user not found"
+	RepositoryName      = "UserRepository"
+	NotFoundError       = "user not found"
+	DuplicateEmailError = "email already in use"
+	InvalidEmailError   = "email is not valid"
 )
 
 type Repository interface {
 	Save(ctx context.Context, user User) (string, error)
 	Update(ctx context.Context, user User) error
 	FindByID(ctx context.Context, userID string) (User, error)
-	Delete(ctx context.Context, userID string) error
+	FindByEmail(ctx context.Context, email string) (User, error)
+	// FindActiveIDs returns which of the ids belong to users that are not deleted
+	FindActiveIDs(ctx context.Context, userIDs []string) (map[string]bool, error)
+	// CreateMissing saves with their own ids the users whose id is free and returns how many were created. A user
+	// whose email belongs to another user, or to an earlier one of the list, is not created and its id is returned in
+	// emailTaken
+	CreateMissing(ctx context.Context, users []User) (created int, emailTaken map[string]bool, err error)
+	List(ctx context.Context, options ListOptions) ([]ListItem, error)
+	// Delete soft deletes the user along with the outbox messages it causes in a single database transaction
+	Delete(ctx context.Context, userID string, messages ...outbox.Message) error
 }
@@ -1,5 +1,11 @@
 package user
 
+import (
+	"errors"
+	"net/mail"
+	"strings"
+)
+
 type User struct {
 	ID        string `json:"id"`
 	FirstName string `json:"first_name"`
@@ -12,8 +18,40 @@ type CreationResponse struct {
 	UserID string `json:"user_id"`
 }
 
+const (
+	recordFirstName = 4
+	recordLastName  = 5
+	// RecordEmail is where the optional email of the user is in a migration record, after the transaction fields
+	RecordEmail = 6
+)
+
+// CreateUserByRecord returns the user of a migration record, the user attributes are read when the record has the
+// optional user fields after the transaction fields
 func CreateUserByRecord(record []string) User {
-	return User{
+	userEntity := User{
 		ID: record[1],
 	}
+
+	if len(record) > RecordEmail {
+		userEntity.FirstName = strings.TrimSpace(record[recordFirstName])
+		userEntity.LastName = strings.TrimSpace(record[recordLastName])
+		userEntity.Email = NormalizeEmail(record[RecordEmail])
+	}
+
+	return userEntity
+}
+
+// NormalizeEmail trims the address and lowers its case so lookups and the unique index are case-insensitive
+func NormalizeEmail(email string) string {
+	return strings.ToLower(strings.TrimSpace(email))
+}
+
+// ValidateEmail checks the address against the RFC 5322 addr-spec syntax, display names are not allowed
+func ValidateEmail(email string) error {
+	address, err := mail.ParseAddress(email)
+	if err != nil || address.Name != "" || address.Address != strings.TrimSpace(email) {
+		return errors.New(InvalidEmailError)
+	}
+
+	return nil
 }
@@ -0,0 +1,109 @@
+package user_test
+
+import (
+	"testing"
+
+	"github.com/sebastianreh/user-balance-api/internal/domain/user"
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_CreateUserByRecord(t *testing.T) {
+	t.Run("When the record only has the transaction fields", func(t *testing.T) {
+		userEntity := user.CreateUserByRecord([]string{"1", "7", "10", "2024-01-01T00:00:00Z"})
+
+		assert.Equal(t, user.User{ID: "7"}, userEntity)
+	})
+
+	t.Run("When the record has the user fields", func(t *testing.T) {
+		userEntity := user.CreateUserByRecord([]string{"1", "7", "10", "2024-01-01T00:00:00Z", " Ada ", "Lovelace",
+			" Ada@Example.com"})
+
+		assert.Equal(t, user.User{ID: "7", FirstName: "Ada", LastName: "Lovelace", Email: "ada@example.com"},
+			userEntity)
+	})
+}
+
+func Test_NormalizeEmail(t *testing.T) {
+	t.Run("When email has upper case letters and surrounding spaces", func(t *testing.T) {
+		assert.Equal(t, "user@example.com", user.NormalizeEmail("  User@Example.COM "))
+	})
+
+	t.Run("When email is already normalized", func(t *testing.T) {
+		assert.Equal(t, "user@example.com", user.NormalizeEmail("user@example.com"))
+	})
+}
+
+func Test_ValidateEmail(t *testing.T) {
+	t.Run("When email is valid", func(t *testing.T) {
+		validEmails := []string{
+			"user@example.com",
+			"first.last+tag@sub.example.org",
+			"User@Example.com",
+		}
+
+		for _, email := range validEmails {
+			assert.Nil(t, user.ValidateEmail(email), email)
+		}
+	})
+
+	t.Run("When email is not valid", func(t *testing.T) {
+		invalidEmails := []string{
+			"",
+			"user",
+			"user@",
+			"@example.com",
+			"user example@example.com",
+			"User <user@example.com>",
+			"<user@example.com>",
+		}
+
+		for _, email := range invalidEmails {
+			err := user.ValidateEmail(email)
+			assert.NotNil(t, err, email)
+			assert.Equal(t, user.InvalidEmailError, err.Error())
+		}
+	})
+}
+
+func Test_Cursor(t *testing.T) {
+	item := user.ListItem{ID: "10", FirstName: "Ana", LastName: "Diaz", Email: "ana@example.com"}
+
+	t.Run("When cursor is encoded and decoded with the same sort", func(t *testing.T) {
+		options := user.ListOptions{SortBy: user.SortByName, SortOrder: user.SortAsc}
+		encoded := user.NewCursor(item, options).Encode()
+
+		cursor, err := user.DecodeCursor(encoded, user.SortByName, user.SortAsc)
+
+		assert.Nil(t, err)
+		assert.Equal(t, "10", cursor.ID)
+		assert.Equal(t, "Ana", cursor.FirstName)
+		assert.Equal(t, "Diaz", cursor.LastName)
+		assert.Empty(t, cursor.Email)
+	})
+
+	t.Run("When cursor was issued for a different sort", func(t *testing.T) {
+		options := user.ListOptions{SortBy: user.SortByEmail, SortOrder: user.SortAsc}
+		encoded := user.NewCursor(item, options).Encode()
+
+		_, err := user.DecodeCursor(encoded, user.SortByEmail, user.SortDesc)
+
+		assert.NotNil(t, err)
+		assert.Equal(t, "cursor does not match the requested sort", err.Error())
+	})
+
+	t.Run("When cursor is not valid", func(t *testing.T) {
+		_, err := user.DecodeCursor("not a cursor", user.SortByID, user.SortAsc)
+
+		assert.NotNil(t, err)
+		assert.Equal(t, user.InvalidCursorError, err.Error())
+	})
+
+	t.Run("When cursor has an id that is not a number", func(t *testing.T) {
+		forged := user.Cursor{SortBy: user.SortByID, SortOrder: user.SortAsc, ID: "1 OR 1=1"}.Encode()
+
+		_, err := user.DecodeCursor(forged, user.SortByID, user.SortAsc)
+
+		assert.NotNil(t, err)
+		assert.Equal(t, user.InvalidCursorError, err.Error())
+	})
+}
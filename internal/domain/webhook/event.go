@@ -0,0 +1,44 @@
+package webhook
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"time"
+)
+
+const (
+	eventIDBytes       = 16
+	subscriptionPrefix = "webhook:"
+)
+
+// Event is the body of the deliveries of a change, every subscription gets the same ID
+type Event struct {
+	ID         string    `json:"id"`
+	Type       string    `json:"type"`
+	OccurredAt time.Time `json:"occurred_at"`
+	Data       any       `json:"data"`
+}
+
+// Delivery is an event sent to a subscription, it's saved as an outbox message. The body is encoded once so a
+// retried or replayed delivery sends the same bytes
+type Delivery struct {
+	SubscriptionID string          `json:"subscription_id"`
+	EventID        string          `json:"event_id"`
+	Event          string          `json:"event"`
+	Body           json.RawMessage `json:"body"`
+}
+
+func NewEvent(eventType string, data any) (Event, error) {
+	id := make([]byte, eventIDBytes)
+	if _, err := rand.Read(id); err != nil {
+		return Event{}, err
+	}
+
+	return Event{ID: "evt_" + hex.EncodeToString(id), Type: eventType, OccurredAt: time.Now().UTC(), Data: data}, nil
+}
+
+// Reference groups the deliveries of a subscription in the outbox
+func Reference(subscriptionID string) string {
+	return subscriptionPrefix + subscriptionID
+}
@@ -0,0 +1,23 @@
+package webhook
+
+import "context"
+
+const (
+	RepositoryName        = "WebhookRepository"
+	NotFoundError         = "webhook subscription not found"
+	InactiveError         = "webhook subscription is not active"
+	DeliveryNotFoundError = "webhook delivery not found"
+)
+
+type Repository interface {
+	// Save creates the subscription with the secret it carries
+	Save(ctx context.Context, subscription Subscription) (Subscription, error)
+	// Update replaces the URL, description, events and active flag of the subscription, its secret is kept
+	Update(ctx context.Context, subscription Subscription) (Subscription, error)
+	// FindByID returns the subscription with its secret
+	FindByID(ctx context.Context, subscriptionID string) (Subscription, error)
+	List(ctx context.Context) ([]Subscription, error)
+	// FindByEvent returns the active subscriptions to the event
+	FindByEvent(ctx context.Context, event string) ([]Subscription, error)
+	Delete(ctx context.Context, subscriptionID string) error
+}
@@ -0,0 +1,72 @@
+package webhook
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/url"
+	"slices"
+	"time"
+)
+
+const (
+	EventTransactionCreated = "transaction.created"
+	EventTransactionDeleted = "transaction.deleted"
+	EventUserDeleted        = "user.deleted"
+	EventMigrationCompleted = "migration.completed"
+	// EventMigrationRolledBack is sent once for the whole rollback, its transactions get no transaction.deleted
+	EventMigrationRolledBack = "migration.rolled_back"
+
+	secretPrefix = "whsec_"
+	secretBytes  = 32
+)
+
+// Events are the events that can be subscribed to
+func Events() []string {
+	return []string{EventTransactionCreated, EventTransactionDeleted, EventUserDeleted, EventMigrationCompleted,
+		EventMigrationRolledBack}
+}
+
+// Subscription sends the events it lists to its URL, signed with its secret
+type Subscription struct {
+	ID          string   `json:"id"`
+	URL         string   `json:"url"`
+	Description string   `json:"description"`
+	Events      []string `json:"events"`
+	// Active is false for a paused subscription, it gets no new events and its pending deliveries fail
+	Active bool `json:"active"`
+	// Secret is only shown when the subscription is created
+	Secret    string    `json:"secret,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+func (s Subscription) Validate() error {
+	target, err := url.Parse(s.URL)
+	if err != nil || (target.Scheme != "http" && target.Scheme != "https") || target.Host == "" {
+		return errors.New("url must be an absolute http or https URL")
+	}
+
+	if len(s.Events) == 0 {
+		return errors.New("events must have at least one event")
+	}
+
+	for _, event := range s.Events {
+		if !slices.Contains(Events(), event) {
+			return fmt.Errorf("unknown event %s, the events are %v", event, Events())
+		}
+	}
+
+	return nil
+}
+
+// NewSecret returns a random secret to sign the deliveries of a subscription
+func NewSecret() (string, error) {
+	secret := make([]byte, secretBytes)
+	if _, err := rand.Read(secret); err != nil {
+		return "", err
+	}
+
+	return secretPrefix + hex.EncodeToString(secret), nil
+}
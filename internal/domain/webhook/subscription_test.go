@@ -0,0 +1,47 @@
+package webhook_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/sebastianreh/user-balance-api/internal/domain/webhook"
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_Subscription_Validate(t *testing.T) {
+	t.Run("When the subscription has an absolute URL and known events", func(t *testing.T) {
+		subscription := webhook.Subscription{URL: "https://example.com/hooks",
+			Events: []string{webhook.EventTransactionCreated, webhook.EventMigrationCompleted}}
+		assert.Nil(t, subscription.Validate())
+	})
+
+	t.Run("When the URL is not an http or https URL", func(t *testing.T) {
+		for _, url := range []string{"", "/hooks", "ftp://example.com/hooks", "https://"} {
+			subscription := webhook.Subscription{URL: url, Events: []string{webhook.EventUserDeleted}}
+			assert.EqualError(t, subscription.Validate(), "url must be an absolute http or https URL", url)
+		}
+	})
+
+	t.Run("When there are no events", func(t *testing.T) {
+		subscription := webhook.Subscription{URL: "https://example.com/hooks"}
+		assert.EqualError(t, subscription.Validate(), "events must have at least one event")
+	})
+
+	t.Run("When an event is unknown", func(t *testing.T) {
+		subscription := webhook.Subscription{URL: "https://example.com/hooks", Events: []string{"balance.changed"}}
+		assert.ErrorContains(t, subscription.Validate(), "unknown event balance.changed")
+	})
+}
+
+func Test_NewSecret(t *testing.T) {
+	t.Run("When every secret is random", func(t *testing.T) {
+		first, err := webhook.NewSecret()
+		assert.Nil(t, err)
+		second, err := webhook.NewSecret()
+		assert.Nil(t, err)
+
+		assert.True(t, strings.HasPrefix(first, "whsec_"))
+		assert.Len(t, first, len("whsec_")+64)
+		assert.NotEqual(t, first, second)
+	})
+}
@@ -1,6 +1,8 @@
 package config
 
 import (
+	"time"
+
 	"github.com/kelseyhightower/envconfig"
 )
 
@@ -27,10 +29,56 @@ type (
 			Host     string `envconfig:"SMTP_HOST" default:"smtp.sendgrid.net"`
 			Port     string `envconfig:"SMTP_PORT" default:"587"`
 			SendTo   string `envconfig:"SMTP_SEND_TO" default:"sebastianreh@gmail.com"`
+			// The HTML bodies of the emails are rendered from the templates of this directory
+			TemplatesDir string `envconfig:"EMAIL_TEMPLATES_DIR" default:"templates/email"`
 		}
 		Workers struct {
 			MigrationWorkersSize     int `envconfig:"MIGRATION_WORKERS_SIZE" default:"5"`
 			MigrationWorkerBatchSize int `envconfig:"MIGRATION_WORKERS_BATCH_SIZE" default:"400"`
+			// Running jobs refresh their heartbeat so any instance can tell when the owner stopped
+			MigrationJobHeartbeat  time.Duration `envconfig:"MIGRATION_JOB_HEARTBEAT" default:"10s"`
+			MigrationJobStaleAfter time.Duration `envconfig:"MIGRATION_JOB_STALE_AFTER" default:"1m"`
+			// Compressed files are checked while they are read so a small upload can't expand without bounds
+			MigrationMaxDecompressedSize int64 `envconfig:"MIGRATION_MAX_DECOMPRESSED_SIZE" default:"4294967296"`
+			MigrationMaxArchiveEntries   int   `envconfig:"MIGRATION_MAX_ARCHIVE_ENTRIES" default:"100"`
+		}
+		Uploads struct {
+			// Chunked uploads are stored on the local disk, so their chunks must reach the same instance
+			Dir     string `envconfig:"MIGRATION_UPLOADS_DIR" default:"/tmp/user-balance-api/uploads"`
+			MaxSize int64  `envconfig:"MIGRATION_UPLOAD_MAX_SIZE" default:"21474836480"`
+			// Uploads that received nothing for this long are removed on startup
+			ExpireAfter time.Duration `envconfig:"MIGRATION_UPLOAD_EXPIRE_AFTER" default:"72h"`
+		}
+		Outbox struct {
+			PollInterval time.Duration `envconfig:"OUTBOX_POLL_INTERVAL" default:"5s"`
+			BatchSize    int           `envconfig:"OUTBOX_BATCH_SIZE" default:"20"`
+			// A message not marked within its lease is claimed again, it must outlast the slowest send
+			Lease time.Duration `envconfig:"OUTBOX_LEASE" default:"5m"`
+			// The wait between attempts doubles from the base up to the limit
+			BackoffBase  time.Duration `envconfig:"OUTBOX_BACKOFF_BASE" default:"30s"`
+			BackoffLimit time.Duration `envconfig:"OUTBOX_BACKOFF_LIMIT" default:"1h"`
+			MaxAttempts  int           `envconfig:"OUTBOX_MAX_ATTEMPTS" default:"10"`
+		}
+		Notifications struct {
+			// Routes are written as event=channel:recipient|recipient,channel;event=channel, nothing is sent by default
+			Routes          string        `envconfig:"NOTIFY_ROUTES" default:""`
+			WebhookURL      string        `envconfig:"NOTIFY_WEBHOOK_URL" default:""`
+			SlackWebhookURL string        `envconfig:"NOTIFY_SLACK_WEBHOOK_URL" default:""`
+			FileDir         string        `envconfig:"NOTIFY_FILE_DIR" default:"/tmp/user-balance-api/notifications"`
+			Timeout         time.Duration `envconfig:"NOTIFY_TIMEOUT" default:"10s"`
+			// A user whose balance drops below this amount raises a low balance notification, unset disables it
+			LowBalanceBelow *float64 `envconfig:"NOTIFY_LOW_BALANCE_BELOW"`
+		}
+		Webhooks struct {
+			// A delivery that takes longer is retried, the receiver should answer before doing its work
+			Timeout time.Duration `envconfig:"WEBHOOK_TIMEOUT" default:"10s"`
+		}
+		Streams struct {
+			// Every open stream holds a connection, the limits keep a dashboard from taking all of them
+			MaxConnections        int `envconfig:"STREAM_MAX_CONNECTIONS" default:"1000"`
+			MaxConnectionsPerUser int `envconfig:"STREAM_MAX_CONNECTIONS_PER_USER" default:"5"`
+			// An idle stream sends a comment this often, so proxies don't close it
+			Heartbeat time.Duration `envconfig:"STREAM_HEARTBEAT" default:"15s"`
 		}
 	}
 )
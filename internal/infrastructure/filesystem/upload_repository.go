@@ -0,0 +1,376 @@
+package filesystem
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/sebastianreh/user-balance-api/internal/domain/upload"
+	"github.com/sebastianreh/user-balance-api/pkg/logger"
+)
+
+const (
+	metadataExtension = ".json"
+	dataExtension     = ".part"
+	uploadIDBytes     = 16
+)
+
+// uploadIDPattern keeps the ids from the requests inside the uploads directory
+var uploadIDPattern = regexp.MustCompile(`^[0-9a-f]{32}$`)
+
+// metadata is stored next to the received bytes of every upload, which are counted from the size of their file
+type metadata struct {
+	ID        string    `json:"upload_id"`
+	FileName  string    `json:"file_name"`
+	Size      int64     `json:"size"`
+	JobID     string    `json:"job_id,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+type uploadRepository struct {
+	log   logger.Logger
+	dir   string
+	mutex sync.Mutex
+	// locks serializes the writes of every upload, claimed holds the uploads being handed to a migration
+	locks   map[string]*sync.Mutex
+	claimed map[string]bool
+}
+
+func NewUploadRepository(log logger.Logger, dir string) upload.Repository {
+	return &uploadRepository{
+		log:     log,
+		dir:     dir,
+		locks:   make(map[string]*sync.Mutex),
+		claimed: make(map[string]bool),
+	}
+}
+
+func (r *uploadRepository) Create(_ context.Context, newUpload upload.Upload) (upload.Upload, error) {
+	if err := os.MkdirAll(r.dir, 0o750); err != nil {
+		r.log.ErrorAt(err, upload.RepositoryName, "Create")
+		return newUpload, err
+	}
+
+	id, err := newUploadID()
+	if err != nil {
+		r.log.ErrorAt(err, upload.RepositoryName, "Create")
+		return newUpload, err
+	}
+
+	data, err := os.OpenFile(r.dataPath(id), os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0o600)
+	if err != nil {
+		r.log.ErrorAt(err, upload.RepositoryName, "Create")
+		return newUpload, err
+	}
+	_ = data.Close()
+
+	err = r.writeMetadata(metadata{ID: id, FileName: newUpload.FileName, Size: newUpload.Size,
+		CreatedAt: time.Now().UTC()})
+	if err != nil {
+		_ = os.Remove(r.dataPath(id))
+		r.log.ErrorAt(err, upload.RepositoryName, "Create")
+		return newUpload, err
+	}
+
+	return r.load(id)
+}
+
+func (r *uploadRepository) FindByID(_ context.Context, uploadID string) (upload.Upload, error) {
+	found, err := r.load(uploadID)
+	if err != nil && err.Error() != upload.NotFoundError {
+		r.log.ErrorAt(err, upload.RepositoryName, "FindByID")
+	}
+
+	return found, err
+}
+
+func (r *uploadRepository) Append(_ context.Context, uploadID string, offset int64,
+	chunk io.Reader) (upload.Upload, error) {
+	unlock := r.lock(uploadID)
+	defer unlock()
+
+	current, err := r.writable(uploadID)
+	if err != nil {
+		return current, err
+	}
+
+	if offset != current.ReceivedBytes {
+		return current, fmt.Errorf("%s, %d bytes were received", upload.OffsetMismatchError, current.ReceivedBytes)
+	}
+
+	data, err := os.OpenFile(current.Path, os.O_WRONLY|os.O_APPEND, 0o600)
+	if err != nil {
+		r.log.ErrorAt(err, upload.RepositoryName, "Append")
+		return current, err
+	}
+
+	// Nothing past the upload size is written, the chunks are checked against it before
+	_, err = io.Copy(data, io.LimitReader(chunk, current.Size-current.ReceivedBytes))
+	if closeErr := data.Close(); err == nil {
+		err = closeErr
+	}
+
+	appended, loadErr := r.load(uploadID)
+	if loadErr != nil {
+		r.log.ErrorAt(loadErr, upload.RepositoryName, "Append")
+		return current, loadErr
+	}
+
+	return appended, err
+}
+
+func (r *uploadRepository) Claim(_ context.Context, uploadID string) (upload.Upload, error) {
+	unlock := r.lock(uploadID)
+	defer unlock()
+
+	current, err := r.writable(uploadID)
+	if err != nil {
+		return current, err
+	}
+
+	if !current.IsComplete() {
+		return current, fmt.Errorf("%s, %d of %d bytes were received", upload.IncompleteError,
+			current.ReceivedBytes, current.Size)
+	}
+
+	r.mutex.Lock()
+	r.claimed[uploadID] = true
+	r.mutex.Unlock()
+
+	return current, nil
+}
+
+func (r *uploadRepository) Release(_ context.Context, uploadID string) error {
+	r.mutex.Lock()
+	delete(r.claimed, uploadID)
+	r.mutex.Unlock()
+
+	return nil
+}
+
+func (r *uploadRepository) Finish(ctx context.Context, uploadID, jobID string) error {
+	unlock := r.lock(uploadID)
+	defer unlock()
+
+	stored, err := r.readMetadata(uploadID)
+	if err != nil {
+		r.log.ErrorAt(err, upload.RepositoryName, "Finish")
+		return err
+	}
+
+	stored.JobID = jobID
+	if err = r.writeMetadata(stored); err != nil {
+		r.log.ErrorAt(err, upload.RepositoryName, "Finish")
+		return err
+	}
+
+	return r.Release(ctx, uploadID)
+}
+
+func (r *uploadRepository) Delete(_ context.Context, uploadID string) error {
+	unlock := r.lock(uploadID)
+	defer unlock()
+
+	if r.isClaimed(uploadID) {
+		return errors.New(upload.BusyError)
+	}
+
+	stored, err := r.readMetadata(uploadID)
+	if err != nil {
+		if err.Error() != upload.NotFoundError {
+			r.log.ErrorAt(err, upload.RepositoryName, "Delete")
+		}
+		return err
+	}
+
+	if stored.JobID == "" {
+		if err = os.Remove(r.dataPath(uploadID)); err != nil && !errors.Is(err, fs.ErrNotExist) {
+			r.log.ErrorAt(err, upload.RepositoryName, "Delete")
+			return err
+		}
+	}
+
+	if err = os.Remove(r.metadataPath(uploadID)); err != nil {
+		r.log.ErrorAt(err, upload.RepositoryName, "Delete")
+		return err
+	}
+
+	r.mutex.Lock()
+	delete(r.locks, uploadID)
+	r.mutex.Unlock()
+
+	return nil
+}
+
+func (r *uploadRepository) DeleteExpired(ctx context.Context, updatedBefore time.Time) (int, error) {
+	entries, err := os.ReadDir(r.dir)
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return 0, nil
+		}
+
+		r.log.ErrorAt(err, upload.RepositoryName, "DeleteExpired")
+		return 0, err
+	}
+
+	deleted := 0
+	for _, entry := range entries {
+		uploadID, isMetadata := strings.CutSuffix(entry.Name(), metadataExtension)
+		if !isMetadata || !uploadIDPattern.MatchString(uploadID) {
+			continue
+		}
+
+		stored, loadErr := r.load(uploadID)
+		if loadErr != nil || !stored.UpdatedAt.Before(updatedBefore) {
+			continue
+		}
+
+		if deleteErr := r.Delete(ctx, uploadID); deleteErr != nil {
+			if deleteErr.Error() == upload.BusyError || deleteErr.Error() == upload.NotFoundError {
+				continue
+			}
+
+			return deleted, deleteErr
+		}
+
+		deleted++
+	}
+
+	return deleted, nil
+}
+
+// writable loads an upload that can still change, it must be called holding the upload lock
+func (r *uploadRepository) writable(uploadID string) (upload.Upload, error) {
+	if r.isClaimed(uploadID) {
+		return upload.Upload{}, errors.New(upload.BusyError)
+	}
+
+	current, err := r.load(uploadID)
+	if err != nil {
+		if err.Error() != upload.NotFoundError {
+			r.log.ErrorAt(err, upload.RepositoryName, "writable")
+		}
+		return current, err
+	}
+
+	if current.JobID != "" {
+		return current, errors.New(upload.FinalizedError)
+	}
+
+	return current, nil
+}
+
+// load reads the metadata of the upload and counts the received bytes, the file of a finalized upload may already
+// be removed by its migration
+func (r *uploadRepository) load(uploadID string) (upload.Upload, error) {
+	stored, err := r.readMetadata(uploadID)
+	if err != nil {
+		return upload.Upload{}, err
+	}
+
+	found := upload.Upload{ID: stored.ID, FileName: stored.FileName, Size: stored.Size, JobID: stored.JobID,
+		CreatedAt: stored.CreatedAt, UpdatedAt: stored.CreatedAt, Path: r.dataPath(uploadID)}
+	if metadataInfo, statErr := os.Stat(r.metadataPath(uploadID)); statErr == nil {
+		found.UpdatedAt = metadataInfo.ModTime().UTC()
+	}
+
+	dataInfo, err := os.Stat(found.Path)
+	switch {
+	case err == nil:
+		found.ReceivedBytes = dataInfo.Size()
+		if dataInfo.ModTime().After(found.UpdatedAt) {
+			found.UpdatedAt = dataInfo.ModTime().UTC()
+		}
+	case errors.Is(err, fs.ErrNotExist) && found.JobID != "":
+		found.ReceivedBytes = found.Size
+	default:
+		return found, err
+	}
+
+	found.RefreshStatus()
+
+	return found, nil
+}
+
+func (r *uploadRepository) readMetadata(uploadID string) (metadata, error) {
+	var stored metadata
+	if !uploadIDPattern.MatchString(uploadID) {
+		return stored, errors.New(upload.NotFoundError)
+	}
+
+	content, err := os.ReadFile(r.metadataPath(uploadID))
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return stored, errors.New(upload.NotFoundError)
+		}
+
+		return stored, err
+	}
+
+	err = json.Unmarshal(content, &stored)
+
+	return stored, err
+}
+
+// writeMetadata replaces the metadata file at once so it's never read half written
+func (r *uploadRepository) writeMetadata(stored metadata) error {
+	content, err := json.Marshal(stored)
+	if err != nil {
+		return err
+	}
+
+	temp := r.metadataPath(stored.ID) + ".tmp"
+	if err = os.WriteFile(temp, content, 0o600); err != nil {
+		return err
+	}
+
+	return os.Rename(temp, r.metadataPath(stored.ID))
+}
+
+func (r *uploadRepository) lock(uploadID string) func() {
+	r.mutex.Lock()
+	uploadLock, found := r.locks[uploadID]
+	if !found {
+		uploadLock = new(sync.Mutex)
+		r.locks[uploadID] = uploadLock
+	}
+	r.mutex.Unlock()
+
+	uploadLock.Lock()
+	return uploadLock.Unlock
+}
+
+func (r *uploadRepository) isClaimed(uploadID string) bool {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	return r.claimed[uploadID]
+}
+
+func (r *uploadRepository) metadataPath(uploadID string) string {
+	return filepath.Join(r.dir, uploadID+metadataExtension)
+}
+
+func (r *uploadRepository) dataPath(uploadID string) string {
+	return filepath.Join(r.dir, uploadID+dataExtension)
+}
+
+func newUploadID() (string, error) {
+	id := make([]byte, uploadIDBytes)
+	if _, err := rand.Read(id); err != nil {
+		return "", err
+	}
+
+	return hex.EncodeToString(id), nil
+}
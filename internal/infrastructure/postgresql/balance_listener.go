@@ -0,0 +1,65 @@
+package postgresql
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/lib/pq"
+	"github.com/sebastianreh/user-balance-api/internal/domain/balance"
+	"github.com/sebastianreh/user-balance-api/internal/infrastructure/config"
+	"github.com/sebastianreh/user-balance-api/pkg/logger"
+	"github.com/sebastianreh/user-balance-api/pkg/pubsub"
+)
+
+const (
+	balanceListenerName  = "BalanceListener"
+	listenerMinReconnect = 10 * time.Second
+	listenerMaxReconnect = time.Minute
+	// The connection is checked when no notification arrived for a while, so a dropped one is noticed
+	listenerPingInterval = 90 * time.Second
+)
+
+// StartBalanceListener forwards the balance changes of every instance to the subscribers of the broker, topics are
+// user ids. It holds a connection of its own, separate from the pool, until the context is done
+func StartBalanceListener(ctx context.Context, cfg config.Config, log logger.Logger, broker *pubsub.Broker) error {
+	listener := pq.NewListener(connectionString(cfg), listenerMinReconnect, listenerMaxReconnect,
+		func(event pq.ListenerEventType, err error) {
+			if err != nil {
+				log.ErrorAt(fmt.Errorf("balance listener connection event %d: %w", event, err), balanceListenerName,
+					"StartBalanceListener")
+			}
+		})
+
+	if err := listener.Listen(balance.ChangesChannel); err != nil {
+		_ = listener.Close()
+		log.ErrorAt(err, balanceListenerName, "StartBalanceListener")
+		return err
+	}
+
+	go func() {
+		defer listener.Close()
+		ticker := time.NewTicker(listenerPingInterval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case notification := <-listener.Notify:
+				// A nil notification follows a reconnection, the changes made while disconnected are lost
+				if notification == nil {
+					broker.PublishAll()
+					continue
+				}
+				broker.Publish(notification.Extra)
+			case <-ticker.C:
+				go func() {
+					_ = listener.Ping()
+				}()
+			}
+		}
+	}()
+
+	return nil
+}
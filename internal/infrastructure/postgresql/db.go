@@ -13,10 +13,7 @@ import (
 func NewPostgresDB(cfg config.Config, log logger.Logger) (*sql.DB, error) {
 	pgCfg := cfg.Postgres
 
-	connStr := fmt.Sprintf("host=%s port=%s user=%s password=%s dbname=%s sslmode=disable",
-		pgCfg.Host, pgCfg.Port, pgCfg.User, pgCfg.Password, pgCfg.DBName)
-
-	db, err := sql.Open("postgres", connStr)
+	db, err := sql.Open("postgres", connectionString(cfg))
 	if err != nil {
 		log.Error("failed to open database", err)
 		time.Sleep(time.Duration(pgCfg.ReconnectIdle) * time.Second)
@@ -33,3 +30,9 @@ func NewPostgresDB(cfg config.Config, log logger.Logger) (*sql.DB, error) {
 
 	return db, nil
 }
+
+func connectionString(cfg config.Config) string {
+	pgCfg := cfg.Postgres
+	return fmt.Sprintf("host=%s port=%s user=%s password=%s dbname=%s sslmode=disable",
+		pgCfg.Host, pgCfg.Port, pgCfg.User, pgCfg.Password, pgCfg.DBName)
+}
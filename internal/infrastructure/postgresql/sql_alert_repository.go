@@ -0,0 +1,237 @@
+package postgresql
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+
+	"github.com/lib/pq"
+	"github.com/sebastianreh/user-balance-api/internal/domain/alert"
+	"github.com/sebastianreh/user-balance-api/internal/domain/outbox"
+	"github.com/sebastianreh/user-balance-api/pkg/logger"
+)
+
+type sqlAlertRepository struct {
+	log logger.Logger
+	db  *sql.DB
+}
+
+func NewSQLAlertRepository(log logger.Logger, db *sql.DB) alert.Repository {
+	return &sqlAlertRepository{
+		log: log,
+		db:  db,
+	}
+}
+
+func (s *sqlAlertRepository) SaveRule(ctx context.Context, rule alert.Rule) (alert.Rule, error) {
+	saved, err := scanAlertRule(s.db.QueryRowContext(ctx, SaveAlertRule, rule.UserID, rule.Type, rule.Threshold,
+		pq.Array(rule.Recipients), rule.Active))
+	if err != nil {
+		s.log.ErrorAt(err, alert.RepositoryName, "SaveRule")
+		return rule, err
+	}
+
+	return saved, nil
+}
+
+func (s *sqlAlertRepository) UpdateRule(ctx context.Context, rule alert.Rule) (alert.Rule, error) {
+	updated, err := scanAlertRule(s.db.QueryRowContext(ctx, UpdateAlertRule, rule.UserID, rule.ID, rule.Type,
+		rule.Threshold, pq.Array(rule.Recipients), rule.Active))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return rule, errors.New(alert.RuleNotFoundError)
+		}
+
+		s.log.ErrorAt(err, alert.RepositoryName, "UpdateRule")
+		return rule, err
+	}
+
+	return updated, nil
+}
+
+func (s *sqlAlertRepository) FindRule(ctx context.Context, userID, ruleID string) (alert.Rule, error) {
+	rule, err := scanAlertRule(s.db.QueryRowContext(ctx, FindAlertRule, userID, ruleID))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return rule, errors.New(alert.RuleNotFoundError)
+		}
+
+		s.log.ErrorAt(err, alert.RepositoryName, "FindRule")
+		return rule, err
+	}
+
+	return rule, nil
+}
+
+func (s *sqlAlertRepository) ListRules(ctx context.Context, userID string) ([]alert.Rule, error) {
+	return s.queryRules(ctx, "ListRules", ListAlertRules, userID)
+}
+
+func (s *sqlAlertRepository) FindActiveRules(ctx context.Context, userIDs []string) ([]alert.Rule, error) {
+	return s.queryRules(ctx, "FindActiveRules", FindActiveAlertRules, pq.Array(userIDs))
+}
+
+func (s *sqlAlertRepository) DeleteRule(ctx context.Context, userID, ruleID string) error {
+	result, err := s.db.ExecContext(ctx, DeleteAlertRule, userID, ruleID)
+	if err != nil {
+		s.log.ErrorAt(err, alert.RepositoryName, "DeleteRule")
+		return err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rowsAffected == 0 {
+		return errors.New(alert.RuleNotFoundError)
+	}
+
+	return nil
+}
+
+func (s *sqlAlertRepository) Fire(ctx context.Context, firedAlert alert.Alert, message outbox.Message) (bool, error) {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		s.log.ErrorAt(err, alert.RepositoryName, "Fire")
+		return false, err
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	// Only the instance that triggers the rule fires it, a concurrent check finds it already triggered
+	if firedAlert.Type == alert.TypeBalanceBelow {
+		var result sql.Result
+		if result, err = tx.ExecContext(ctx, TriggerAlertRule, firedAlert.RuleID); err != nil {
+			s.log.ErrorAt(err, alert.RepositoryName, "Fire")
+			return false, err
+		}
+
+		var triggered int64
+		if triggered, err = result.RowsAffected(); err != nil || triggered == 0 {
+			return false, err
+		}
+	}
+
+	var alertID string
+	err = tx.QueryRowContext(ctx, SaveAlert, firedAlert.RuleID, firedAlert.UserID, firedAlert.Type,
+		firedAlert.Threshold, firedAlert.Value, firedAlert.TransactionID, pq.Array(firedAlert.Recipients)).Scan(&alertID)
+	if err != nil {
+		// The transaction was already alerted by the rule
+		if errors.Is(err, sql.ErrNoRows) {
+			return false, nil
+		}
+
+		s.log.ErrorAt(err, alert.RepositoryName, "Fire")
+		return false, err
+	}
+
+	if err = saveOutboxMessages(ctx, tx, []outbox.Message{message}); err != nil {
+		s.log.ErrorAt(err, alert.RepositoryName, "Fire")
+		return false, err
+	}
+
+	if err = tx.Commit(); err != nil {
+		s.log.ErrorAt(err, alert.RepositoryName, "Fire")
+		return false, err
+	}
+
+	return true, nil
+}
+
+func (s *sqlAlertRepository) Clear(ctx context.Context, ruleIDs []string) error {
+	if _, err := s.db.ExecContext(ctx, ClearAlertRules, pq.Array(ruleIDs)); err != nil {
+		s.log.ErrorAt(err, alert.RepositoryName, "Clear")
+		return err
+	}
+
+	return nil
+}
+
+func (s *sqlAlertRepository) ListAlerts(ctx context.Context, options alert.ListOptions) ([]alert.Alert, error) {
+	query, args := ListAlerts, []interface{}{options.UserID, options.Limit}
+	if options.After != nil {
+		query, args = ListAlertsAfter, append(args, options.After.ID)
+	}
+
+	alerts := make([]alert.Alert, 0)
+	rows, err := s.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		s.log.ErrorAt(err, alert.RepositoryName, "ListAlerts")
+		return alerts, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var item alert.Alert
+		if err = rows.Scan(&item.ID, &item.RuleID, &item.UserID, &item.Type, &item.Threshold, &item.Value,
+			&item.TransactionID, pq.Array(&item.Recipients), &item.CreatedAt); err != nil {
+			s.log.ErrorAt(err, alert.RepositoryName, "ListAlerts")
+			return alerts, err
+		}
+
+		alerts = append(alerts, item)
+	}
+
+	return alerts, rows.Err()
+}
+
+func (s *sqlAlertRepository) queryRules(ctx context.Context, origin, query string, args ...any) ([]alert.Rule, error) {
+	rules := make([]alert.Rule, 0)
+	rows, err := s.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		s.log.ErrorAt(err, alert.RepositoryName, origin)
+		return rules, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		rule, err := scanAlertRule(rows)
+		if err != nil {
+			s.log.ErrorAt(err, alert.RepositoryName, origin)
+			return rules, err
+		}
+
+		rules = append(rules, rule)
+	}
+
+	return rules, rows.Err()
+}
+
+func scanAlertRule(row rowScanner) (alert.Rule, error) {
+	var rule alert.Rule
+	err := row.Scan(&rule.ID, &rule.UserID, &rule.Type, &rule.Threshold, pq.Array(&rule.Recipients), &rule.Active,
+		&rule.TriggeredAt, &rule.CreatedAt, &rule.UpdatedAt)
+	return rule, err
+}
+
+const (
+	alertRuleColumns = "id, user_id, type, threshold, recipients, active, triggered_at, created_at, updated_at"
+	SaveAlertRule    = `
+	INSERT INTO alert_rules (user_id, type, threshold, recipients, active)
+	VALUES ($1, $2, $3, $4, $5)
+	RETURNING ` + alertRuleColumns
+	UpdateAlertRule = `
+	UPDATE alert_rules SET type = $3, threshold = $4, recipients = $5, active = $6, triggered_at = NULL,
+		updated_at = NOW()
+	WHERE user_id = $1 AND id = $2
+	RETURNING ` + alertRuleColumns
+	FindAlertRule        = "SELECT " + alertRuleColumns + " FROM alert_rules WHERE user_id = $1 AND id = $2"
+	ListAlertRules       = "SELECT " + alertRuleColumns + " FROM alert_rules WHERE user_id = $1 ORDER BY id"
+	FindActiveAlertRules = "SELECT " + alertRuleColumns + `
+	FROM alert_rules WHERE active AND user_id = ANY($1::BIGINT[]) ORDER BY id`
+	DeleteAlertRule  = "DELETE FROM alert_rules WHERE user_id = $1 AND id = $2"
+	TriggerAlertRule = `
+	UPDATE alert_rules SET triggered_at = NOW() WHERE id = $1 AND triggered_at IS NULL`
+	ClearAlertRules = `
+	UPDATE alert_rules SET triggered_at = NULL WHERE id = ANY($1::BIGINT[]) AND triggered_at IS NOT NULL`
+	SaveAlert = `
+	INSERT INTO alerts (rule_id, user_id, type, threshold, value, transaction_id, recipients)
+	VALUES ($1, $2, $3, $4, $5, $6, $7)
+	ON CONFLICT (rule_id, transaction_id) WHERE transaction_id <> '' DO NOTHING
+	RETURNING id`
+	alertColumns = `
+	SELECT id, rule_id, user_id, type, threshold, value, transaction_id, recipients, created_at
+	FROM alerts`
+	ListAlerts      = alertColumns + " WHERE user_id = $1 ORDER BY id DESC LIMIT $2"
+	ListAlertsAfter = alertColumns + " WHERE user_id = $1 AND id < $3 ORDER BY id DESC LIMIT $2"
+)
@@ -0,0 +1,128 @@
+package postgresql
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strconv"
+
+	"github.com/lib/pq"
+	"github.com/sebastianreh/user-balance-api/internal/domain/balance"
+	"github.com/sebastianreh/user-balance-api/pkg/logger"
+)
+
+type sqlBalanceRepository struct {
+	log logger.Logger
+	db  *sql.DB
+}
+
+func NewSQLBalanceRepository(log logger.Logger, db *sql.DB) balance.Repository {
+	return &sqlBalanceRepository{
+		log: log,
+		db:  db,
+	}
+}
+
+func (s *sqlBalanceRepository) FindByUserID(ctx context.Context, userID, fromDate,
+	toDate string) (balance.UserBalance, error) {
+	var userBalance balance.UserBalance
+	query := FindBalanceByUserID
+	args := []interface{}{userID}
+
+	if fromDate != "" && toDate != "" {
+		query += FindBalanceByUserIDDateRange
+		args = append(args, fromDate, toDate)
+	}
+
+	err := s.db.QueryRowContext(ctx, query, args...).Scan(&userBalance.Balance, &userBalance.TotalDebits,
+		&userBalance.TotalCredits)
+	if err != nil {
+		s.log.ErrorAt(err, balance.RepositoryName, "FindByUserID")
+		return userBalance, err
+	}
+
+	userBalance.RoundBalanceToTwoDecimalPlaces()
+
+	return userBalance, nil
+}
+
+func (s *sqlBalanceRepository) FindByUserIDs(ctx context.Context, userIDs []string, fromDate,
+	toDate string) (map[string]balance.UserBalance, error) {
+	balances := make(map[string]balance.UserBalance)
+
+	// Users ids are numeric, anything else can't exist so it's left out of the query
+	numericIDs := make([]int64, 0, len(userIDs))
+	for _, userID := range userIDs {
+		numericID, err := strconv.ParseInt(userID, 10, 64)
+		if err == nil {
+			numericIDs = append(numericIDs, numericID)
+		}
+	}
+
+	if len(numericIDs) == 0 {
+		return balances, nil
+	}
+
+	query, args := findBalancesByUserIDsQuery(numericIDs, fromDate, toDate)
+	rows, err := s.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		s.log.ErrorAt(err, balance.RepositoryName, "FindByUserIDs")
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	for rows.Next() {
+		var userID string
+		var userBalance balance.UserBalance
+		if err = rows.Scan(&userID, &userBalance.Balance, &userBalance.TotalDebits, &userBalance.TotalCredits); err != nil {
+			s.log.ErrorAt(err, balance.RepositoryName, "FindByUserIDs")
+			return nil, err
+		}
+
+		userBalance.RoundBalanceToTwoDecimalPlaces()
+		balances[userID] = userBalance
+	}
+
+	if err = rows.Err(); err != nil {
+		s.log.ErrorAt(err, balance.RepositoryName, "FindByUserIDs")
+		return nil, err
+	}
+
+	return balances, nil
+}
+
+func findBalancesByUserIDsQuery(userIDs []int64, fromDate, toDate string) (query string, args []interface{}) {
+	args = append(args, pq.Array(userIDs))
+	joinConditions := ""
+	if fromDate != "" {
+		args = append(args, fromDate)
+		joinConditions += fmt.Sprintf(" AND t.date_time >= CAST($%d AS timestamptz)", len(args))
+	}
+
+	if toDate != "" {
+		args = append(args, toDate)
+		joinConditions += fmt.Sprintf(" AND t.date_time <= CAST($%d AS timestamptz)", len(args))
+	}
+
+	return fmt.Sprintf(FindBalancesByUserIDs, joinConditions), args
+}
+
+const (
+	// Served by idx_transactions_user_id_date_time_amount as an index only scan
+	FindBalanceByUserID = `
+	SELECT COALESCE(SUM(amount), 0),
+		COUNT(*) FILTER (WHERE amount < 0),
+		COUNT(*) FILTER (WHERE amount > 0)
+	FROM transactions
+	WHERE user_id = $1 AND NOT is_deleted`
+	FindBalanceByUserIDDateRange = ` AND date_time >= CAST($2 AS timestamptz) AND date_time <= CAST($3 AS timestamptz)`
+	FindBalancesByUserIDs        = `
+	SELECT u.id, COALESCE(SUM(t.amount), 0),
+		COUNT(t.id) FILTER (WHERE t.amount < 0),
+		COUNT(t.id) FILTER (WHERE t.amount > 0)
+	FROM users u
+	LEFT JOIN transactions t ON t.user_id = u.id AND NOT t.is_deleted%s
+	WHERE u.id = ANY($1) AND u.is_deleted = FALSE
+	GROUP BY u.id`
+)
@@ -0,0 +1,152 @@
+package postgresql
+
+import (
+	"context"
+	"database/sql"
+	"strconv"
+
+	"github.com/lib/pq"
+	"github.com/sebastianreh/user-balance-api/internal/domain/change"
+	"github.com/sebastianreh/user-balance-api/internal/domain/outbox"
+	"github.com/sebastianreh/user-balance-api/pkg/logger"
+)
+
+type sqlChangeRepository struct {
+	log logger.Logger
+	db  *sql.DB
+}
+
+func NewSQLChangeRepository(log logger.Logger, db *sql.DB) change.Repository {
+	return &sqlChangeRepository{
+		log: log,
+		db:  db,
+	}
+}
+
+func (s *sqlChangeRepository) List(ctx context.Context, options change.ListOptions) ([]change.Change, error) {
+	rows, err := s.db.QueryContext(ctx, ListChanges, options.Since, options.Limit)
+	if err != nil {
+		s.log.ErrorAt(err, change.RepositoryName, "List")
+		return nil, err
+	}
+	defer rows.Close()
+
+	changes := make([]change.Change, 0)
+	for rows.Next() {
+		var changeEntity change.Change
+		var cursor int64
+		var data []byte
+		if err = rows.Scan(&cursor, &changeEntity.Entity, &changeEntity.EntityID, &changeEntity.Operation, &data,
+			&changeEntity.CreatedAt); err != nil {
+			s.log.ErrorAt(err, change.RepositoryName, "List")
+			return nil, err
+		}
+
+		changeEntity.Cursor = strconv.FormatInt(cursor, 10)
+		changeEntity.Data = data
+		changes = append(changes, changeEntity)
+	}
+
+	if err = rows.Err(); err != nil {
+		s.log.ErrorAt(err, change.RepositoryName, "List")
+		return nil, err
+	}
+
+	return changes, nil
+}
+
+// recordChanges writes the changes of the rows picked by query to the feed, query takes the entity and the
+// operation before its own args. It advances the change sequence, whose row stays locked until the database
+// transaction ends, so it must be the last statement before the commit to keep the other writers waiting the least
+func recordChanges(ctx context.Context, tx *sql.Tx, query, entity, operation string, args ...interface{}) error {
+	_, err := tx.ExecContext(ctx, query, append([]interface{}{entity, operation}, args...)...)
+	return err
+}
+
+// execWithChange runs a write of a single user or transaction and saves the outbox messages it causes and its change
+// in the same database transaction, nothing is saved when the write left the row as it was
+func execWithChange(ctx context.Context, db *sql.DB, entity, entityID, operation string, messages []outbox.Message,
+	query string, args ...interface{}) error {
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	result, err := tx.ExecContext(ctx, query, args...)
+	if err != nil {
+		return err
+	}
+
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if affected > 0 {
+		if err = saveOutboxMessages(ctx, tx, messages); err != nil {
+			return err
+		}
+
+		if err = recordChanges(ctx, tx, recordQuery(entity), entity, operation, pq.Array([]string{entityID})); err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+// recordQuery picks the rows of the entity by their ids
+func recordQuery(entity string) string {
+	if entity == change.EntityUser {
+		return RecordUserChanges
+	}
+
+	return RecordTransactionChanges
+}
+
+const (
+	ListChanges = `
+	SELECT id, entity, entity_id, operation, data, created_at
+	FROM changes
+	WHERE id > $1
+	ORDER BY id
+	LIMIT $2`
+
+	// saveChanges follows a changed CTE with the entity_id, data and position of each row, the positions go from 1
+	// without gaps so the cursors taken from the sequence don't either
+	saveChanges = `
+	reserved AS (
+		UPDATE change_sequence SET value = value + (SELECT COUNT(*) FROM changed)
+		RETURNING value - (SELECT COUNT(*) FROM changed) AS previous
+	)
+	INSERT INTO changes (id, entity, entity_id, operation, data)
+	SELECT reserved.previous + changed.position, $1, changed.entity_id, $2, changed.data
+	FROM changed CROSS JOIN reserved`
+	RecordUserChanges = `
+	WITH changed AS (
+		SELECT CAST(u.id AS TEXT) AS entity_id, to_jsonb(u) AS data, ROW_NUMBER() OVER (ORDER BY c.position) AS position
+		FROM unnest(CAST($3 AS BIGINT[])) WITH ORDINALITY AS c(id, position)
+		JOIN users u ON u.id = c.id
+	),` + saveChanges
+	RecordTransactionChanges = `
+	WITH changed AS (
+		SELECT t.id AS entity_id, to_jsonb(t) AS data, ROW_NUMBER() OVER (ORDER BY c.position) AS position
+		FROM unnest(CAST($3 AS TEXT[])) WITH ORDINALITY AS c(id, position)
+		JOIN transactions t ON t.id = c.id
+	),` + saveChanges
+	// The stage is emptied by the same statement, so recording its changes is still the last one of the commit
+	RecordStagedTransactionChanges = `
+	WITH staged AS (
+		DELETE FROM transaction_stages WHERE stage_id = $3 RETURNING id
+	), changed AS (
+		SELECT t.id AS entity_id, to_jsonb(t) AS data, ROW_NUMBER() OVER (ORDER BY t.id) AS position
+		FROM staged JOIN transactions t ON t.id = staged.id
+	),` + saveChanges
+	RecordMigrationTransactionChanges = `
+	WITH changed AS (
+		SELECT t.id AS entity_id, to_jsonb(t) AS data, ROW_NUMBER() OVER (ORDER BY t.id) AS position
+		FROM transactions t
+		WHERE t.migration_id = $3
+	),` + saveChanges
+)
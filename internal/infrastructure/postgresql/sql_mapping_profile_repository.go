@@ -0,0 +1,156 @@
+package postgresql
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+
+	"github.com/lib/pq"
+	"github.com/sebastianreh/user-balance-api/internal/domain/migration"
+	"github.com/sebastianreh/user-balance-api/pkg/logger"
+)
+
+type sqlMappingProfileRepository struct {
+	log logger.Logger
+	db  *sql.DB
+}
+
+func NewSQLMappingProfileRepository(log logger.Logger, db *sql.DB) migration.ProfileRepository {
+	return &sqlMappingProfileRepository{
+		log: log,
+		db:  db,
+	}
+}
+
+func (s *sqlMappingProfileRepository) Save(ctx context.Context,
+	profile migration.MappingProfile) (migration.MappingProfile, error) {
+	columns, ignoredColumns, defaults, err := encodeProfile(profile)
+	if err != nil {
+		s.log.ErrorAt(err, migration.ProfileRepositoryName, "Save")
+		return profile, err
+	}
+
+	err = s.db.QueryRowContext(ctx, SaveMappingProfile, profile.Name, columns, ignoredColumns, defaults,
+		profile.Strict).Scan(&profile.CreatedAt)
+	if err != nil {
+		var pqErr *pq.Error
+		if errors.As(err, &pqErr) && pqErr.Code == "23505" {
+			return profile, errors.New(migration.DuplicateProfileError)
+		}
+
+		s.log.ErrorAt(err, migration.ProfileRepositoryName, "Save")
+		return profile, err
+	}
+
+	return profile, nil
+}
+
+func (s *sqlMappingProfileRepository) FindByName(ctx context.Context, name string) (migration.MappingProfile, error) {
+	profile, err := scanProfile(s.db.QueryRowContext(ctx, FindMappingProfileByName, name))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return profile, errors.New(migration.ProfileNotFoundError)
+		}
+
+		s.log.ErrorAt(err, migration.ProfileRepositoryName, "FindByName")
+		return profile, err
+	}
+
+	return profile, nil
+}
+
+func (s *sqlMappingProfileRepository) List(ctx context.Context) ([]migration.MappingProfile, error) {
+	profiles := make([]migration.MappingProfile, 0)
+	rows, err := s.db.QueryContext(ctx, ListMappingProfiles)
+	if err != nil {
+		s.log.ErrorAt(err, migration.ProfileRepositoryName, "List")
+		return profiles, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		profile, err := scanProfile(rows)
+		if err != nil {
+			s.log.ErrorAt(err, migration.ProfileRepositoryName, "List")
+			return profiles, err
+		}
+
+		profiles = append(profiles, profile)
+	}
+
+	return profiles, rows.Err()
+}
+
+func (s *sqlMappingProfileRepository) Delete(ctx context.Context, name string) error {
+	result, err := s.db.ExecContext(ctx, DeleteMappingProfile, name)
+	if err != nil {
+		s.log.ErrorAt(err, migration.ProfileRepositoryName, "Delete")
+		return err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rowsAffected == 0 {
+		return errors.New(migration.ProfileNotFoundError)
+	}
+
+	return nil
+}
+
+func encodeProfile(profile migration.MappingProfile) (columns, ignoredColumns, defaults []byte, err error) {
+	if columns, err = json.Marshal(profile.Columns); err != nil {
+		return nil, nil, nil, err
+	}
+
+	if ignoredColumns, err = json.Marshal(profile.IgnoredColumns); err != nil {
+		return nil, nil, nil, err
+	}
+
+	if defaults, err = json.Marshal(profile.Defaults); err != nil {
+		return nil, nil, nil, err
+	}
+
+	return columns, ignoredColumns, defaults, nil
+}
+
+type rowScanner interface {
+	Scan(dest ...any) error
+}
+
+func scanProfile(row rowScanner) (migration.MappingProfile, error) {
+	var profile migration.MappingProfile
+	var columns, ignoredColumns, defaults []byte
+	if err := row.Scan(&profile.Name, &columns, &ignoredColumns, &defaults, &profile.Strict,
+		&profile.CreatedAt); err != nil {
+		return profile, err
+	}
+
+	if err := json.Unmarshal(columns, &profile.Columns); err != nil {
+		return profile, err
+	}
+
+	if err := json.Unmarshal(ignoredColumns, &profile.IgnoredColumns); err != nil {
+		return profile, err
+	}
+
+	if err := json.Unmarshal(defaults, &profile.Defaults); err != nil {
+		return profile, err
+	}
+
+	return profile, nil
+}
+
+const (
+	SaveMappingProfile = `
+	INSERT INTO migration_profiles (name, columns, ignored_columns, defaults, strict) VALUES ($1, $2, $3, $4, $5)
+	RETURNING created_at`
+	FindMappingProfileByName = `
+	SELECT name, columns, ignored_columns, defaults, strict, created_at FROM migration_profiles WHERE name = $1`
+	ListMappingProfiles = `
+	SELECT name, columns, ignored_columns, defaults, strict, created_at FROM migration_profiles ORDER BY name`
+	DeleteMappingProfile = "DELETE FROM migration_profiles WHERE name = $1"
+)
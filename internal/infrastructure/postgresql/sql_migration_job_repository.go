@@ -0,0 +1,468 @@
+package postgresql
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"time"
+
+	"github.com/lib/pq"
+	"github.com/sebastianreh/user-balance-api/internal/domain/change"
+	"github.com/sebastianreh/user-balance-api/internal/domain/migration"
+	"github.com/sebastianreh/user-balance-api/internal/domain/outbox"
+	"github.com/sebastianreh/user-balance-api/internal/domain/report"
+	"github.com/sebastianreh/user-balance-api/pkg/logger"
+)
+
+type sqlMigrationJobRepository struct {
+	log logger.Logger
+	db  *sql.DB
+}
+
+func NewSQLMigrationJobRepository(log logger.Logger, db *sql.DB) migration.Repository {
+	return &sqlMigrationJobRepository{
+		log: log,
+		db:  db,
+	}
+}
+
+func (s *sqlMigrationJobRepository) Save(ctx context.Context, job migration.Job) (string, error) {
+	var createdID string
+	duplicateOf := sql.NullString{String: job.DuplicateOf, Valid: job.DuplicateOf != ""}
+	err := s.db.QueryRowContext(ctx, SaveMigrationJob, job.Status, job.FileName, job.Mode, job.Checksum,
+		job.UploadedBy, job.ContentHash, job.Forced, duplicateOf, pq.Array(job.ReportDestinations)).Scan(&createdID)
+	if err != nil {
+		s.log.ErrorAt(err, migration.RepositoryName, "Save")
+		if duplicateErr := handleImportedFileError(err); duplicateErr != nil {
+			err = duplicateErr
+		}
+		return "", err
+	}
+
+	return createdID, nil
+}
+
+func (s *sqlMigrationJobRepository) Update(ctx context.Context, job migration.Job) error {
+	result, err := s.db.ExecContext(ctx, UpdateMigrationJob, updateJobArgs(job)...)
+	if err != nil {
+		s.log.ErrorAt(err, migration.RepositoryName, "Update")
+		return err
+	}
+
+	return checkJobAffected(result)
+}
+
+func (s *sqlMigrationJobRepository) Finish(ctx context.Context, job migration.Job, messages ...outbox.Message) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		s.log.ErrorAt(err, migration.RepositoryName, "Finish")
+		return err
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	result, err := tx.ExecContext(ctx, UpdateMigrationJob, updateJobArgs(job)...)
+	if err != nil {
+		s.log.ErrorAt(err, migration.RepositoryName, "Finish")
+		return err
+	}
+
+	if err = checkJobAffected(result); err != nil {
+		return err
+	}
+
+	if err = saveOutboxMessages(ctx, tx, messages); err != nil {
+		s.log.ErrorAt(err, migration.RepositoryName, "Finish")
+		return err
+	}
+
+	if err = tx.Commit(); err != nil {
+		s.log.ErrorAt(err, migration.RepositoryName, "Finish")
+		return err
+	}
+
+	return nil
+}
+
+func (s *sqlMigrationJobRepository) FindByID(ctx context.Context, jobID string) (migration.Job, error) {
+	job, err := scanJob(s.db.QueryRowContext(ctx, FindMigrationJobByID, jobID))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return job, errors.New(migration.NotFoundError)
+		}
+
+		s.log.ErrorAt(err, migration.RepositoryName, "FindByID")
+		return job, err
+	}
+
+	return job, nil
+}
+
+func (s *sqlMigrationJobRepository) FindImported(ctx context.Context, checksum,
+	contentHash string) (migration.Job, error) {
+	job, err := scanJob(s.db.QueryRowContext(ctx, FindImportedMigrationJob, checksum, contentHash))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return job, errors.New(migration.NotFoundError)
+		}
+
+		s.log.ErrorAt(err, migration.RepositoryName, "FindImported")
+		return job, err
+	}
+
+	return job, nil
+}
+
+func (s *sqlMigrationJobRepository) List(ctx context.Context, options migration.ListOptions) ([]migration.Job, error) {
+	query, args := ListMigrationJobs, []interface{}{options.Limit}
+	if options.After != nil {
+		query, args = ListMigrationJobsAfter, append(args, options.After.ID)
+	}
+
+	rows, err := s.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		s.log.ErrorAt(err, migration.RepositoryName, "List")
+		return nil, err
+	}
+	defer rows.Close()
+
+	jobs := make([]migration.Job, 0)
+	for rows.Next() {
+		job, err := scanJob(rows)
+		if err != nil {
+			s.log.ErrorAt(err, migration.RepositoryName, "List")
+			return nil, err
+		}
+
+		jobs = append(jobs, job)
+	}
+
+	if err = rows.Err(); err != nil {
+		s.log.ErrorAt(err, migration.RepositoryName, "List")
+		return nil, err
+	}
+
+	return jobs, nil
+}
+
+// Rollback locks the job so two rollbacks can't run together. The soft delete locks the transactions before
+// counting the edited ones, so an edit either finished before and is counted or waits for the rollback to end
+func (s *sqlMigrationJobRepository) Rollback(ctx context.Context, jobID string,
+	messages ...outbox.Message) (int64, error) {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		s.log.ErrorAt(err, migration.RepositoryName, "Rollback")
+		return 0, err
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	var job migration.Job
+	if err = tx.QueryRowContext(ctx, LockMigrationJob, jobID).Scan(&job.Status); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return 0, errors.New(migration.NotFoundError)
+		}
+
+		s.log.ErrorAt(err, migration.RepositoryName, "Rollback")
+		return 0, err
+	}
+
+	if !job.CanRollBack() {
+		return 0, errors.New(migration.RollbackStatusError)
+	}
+
+	var deleted, edited int64
+	if err = tx.QueryRowContext(ctx, DeleteMigrationTransactions, jobID).Scan(&deleted, &edited); err != nil {
+		s.log.ErrorAt(err, migration.RepositoryName, "Rollback")
+		return 0, err
+	}
+
+	if edited > 0 {
+		return 0, errors.New(migration.RollbackEditedError)
+	}
+
+	if _, err = tx.ExecContext(ctx, RollBackMigrationJob, jobID); err != nil {
+		s.log.ErrorAt(err, migration.RepositoryName, "Rollback")
+		return 0, err
+	}
+
+	if err = saveOutboxMessages(ctx, tx, messages); err != nil {
+		s.log.ErrorAt(err, migration.RepositoryName, "Rollback")
+		return 0, err
+	}
+
+	err = recordChanges(ctx, tx, RecordMigrationTransactionChanges, change.EntityTransaction,
+		change.OperationSoftDelete, jobID)
+	if err != nil {
+		s.log.ErrorAt(err, migration.RepositoryName, "Rollback")
+		return 0, err
+	}
+
+	if err = tx.Commit(); err != nil {
+		s.log.ErrorAt(err, migration.RepositoryName, "Rollback")
+		return 0, err
+	}
+
+	return deleted, nil
+}
+
+func (s *sqlMigrationJobRepository) Heartbeat(ctx context.Context, jobID string) error {
+	result, err := s.db.ExecContext(ctx, HeartbeatMigrationJob, jobID)
+	if err != nil {
+		s.log.ErrorAt(err, migration.RepositoryName, "Heartbeat")
+		return err
+	}
+
+	return checkJobAffected(result)
+}
+
+func (s *sqlMigrationJobRepository) FindStale(ctx context.Context, staleBefore time.Time) ([]migration.Job, error) {
+	rows, err := s.db.QueryContext(ctx, FindStaleMigrationJobs, staleBefore)
+	if err != nil {
+		s.log.ErrorAt(err, migration.RepositoryName, "FindStale")
+		return nil, err
+	}
+	defer rows.Close()
+
+	jobs := make([]migration.Job, 0)
+	for rows.Next() {
+		job, err := scanJob(rows)
+		if err != nil {
+			s.log.ErrorAt(err, migration.RepositoryName, "FindStale")
+			return nil, err
+		}
+
+		jobs = append(jobs, job)
+	}
+
+	if err = rows.Err(); err != nil {
+		s.log.ErrorAt(err, migration.RepositoryName, "FindStale")
+		return nil, err
+	}
+
+	return jobs, nil
+}
+
+func (s *sqlMigrationJobRepository) FailStale(ctx context.Context, staleBefore time.Time, reason string) (int64, error) {
+	result, err := s.db.ExecContext(ctx, FailStaleMigrationJobs, staleBefore, reason)
+	if err != nil {
+		s.log.ErrorAt(err, migration.RepositoryName, "FailStale")
+		return 0, err
+	}
+
+	return result.RowsAffected()
+}
+
+// Saved counts the transactions of the job whether they were deleted or not, they were all saved by it
+func (s *sqlMigrationJobRepository) Saved(ctx context.Context, jobID string) (report.MigrationSummary, error) {
+	summary := report.MigrationSummary{JobID: jobID}
+	err := s.db.QueryRowContext(ctx, CountMigrationTransactions, jobID).Scan(&summary.TotalRecords,
+		&summary.UsersUpdated)
+	if err != nil {
+		s.log.ErrorAt(err, migration.RepositoryName, "Saved")
+		return summary, err
+	}
+
+	rows, err := s.db.QueryContext(ctx, CountMigrationRejects, jobID)
+	if err != nil {
+		s.log.ErrorAt(err, migration.RepositoryName, "Saved")
+		return summary, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var reason string
+		var rejected int
+		if err = rows.Scan(&reason, &rejected); err != nil {
+			s.log.ErrorAt(err, migration.RepositoryName, "Saved")
+			return summary, err
+		}
+
+		if summary.RejectsByReason == nil {
+			summary.RejectsByReason = make(map[string]int)
+		}
+		summary.RejectsByReason[reason] = rejected
+		summary.RejectedRecords += rejected
+	}
+
+	if err = rows.Err(); err != nil {
+		s.log.ErrorAt(err, migration.RepositoryName, "Saved")
+		return summary, err
+	}
+
+	return summary, nil
+}
+
+func (s *sqlMigrationJobRepository) SaveRejects(ctx context.Context, jobID string, rejects []migration.Reject) error {
+	lines := make([]int64, len(rejects))
+	raws := make([]string, len(rejects))
+	reasons := make([]string, len(rejects))
+	details := make([]string, len(rejects))
+	for i, reject := range rejects {
+		lines[i] = int64(reject.Line)
+		raws[i] = reject.Raw
+		reasons[i] = reject.Reason
+		details[i] = reject.Detail
+	}
+
+	_, err := s.db.ExecContext(ctx, SaveMigrationRejects, jobID, pq.Array(lines), pq.Array(raws), pq.Array(reasons),
+		pq.Array(details))
+	if err != nil {
+		s.log.ErrorAt(err, migration.RepositoryName, "SaveRejects")
+		return err
+	}
+
+	return nil
+}
+
+func (s *sqlMigrationJobRepository) ForEachReject(ctx context.Context, jobID string,
+	handle func(reject migration.Reject) error) error {
+	rows, err := s.db.QueryContext(ctx, FindMigrationRejectsByJobID, jobID)
+	if err != nil {
+		s.log.ErrorAt(err, migration.RepositoryName, "ForEachReject")
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var reject migration.Reject
+		if err = rows.Scan(&reject.Line, &reject.Raw, &reject.Reason, &reject.Detail); err != nil {
+			s.log.ErrorAt(err, migration.RepositoryName, "ForEachReject")
+			return err
+		}
+
+		if err = handle(reject); err != nil {
+			return err
+		}
+	}
+
+	return rows.Err()
+}
+
+func scanJob(row rowScanner) (migration.Job, error) {
+	var job migration.Job
+	var totalRecords, usersUpdated, usersCreated, rejectedRecords sql.NullInt64
+	var rejectsByReason []byte
+	var duplicateOf sql.NullString
+	err := row.Scan(&job.ID, &job.Status, &job.FileName, &job.Checksum, &job.ContentHash, &job.Forced, &duplicateOf,
+		&job.UploadedBy, &job.Mode, &job.Progress.RowsValidated, &job.Progress.RowsInserted, &job.Progress.BatchesDone,
+		&job.Progress.BatchesTotal, &totalRecords, &usersUpdated, &usersCreated, &rejectedRecords, &rejectsByReason,
+		&job.Error, &job.CreatedAt, &job.UpdatedAt, &job.RolledBackAt, pq.Array(&job.ReportDestinations))
+	if err != nil {
+		return job, err
+	}
+
+	job.DuplicateOf = duplicateOf.String
+
+	if totalRecords.Valid {
+		job.Summary = &report.MigrationSummary{
+			JobID:           job.ID,
+			TotalRecords:    int(totalRecords.Int64),
+			UsersUpdated:    int(usersUpdated.Int64),
+			UsersCreated:    int(usersCreated.Int64),
+			RejectedRecords: int(rejectedRecords.Int64),
+		}
+
+		if len(rejectsByReason) > 0 {
+			if err = json.Unmarshal(rejectsByReason, &job.Summary.RejectsByReason); err != nil {
+				return job, err
+			}
+		}
+	}
+
+	return job, nil
+}
+
+func updateJobArgs(job migration.Job) []interface{} {
+	var totalRecords, usersUpdated, usersCreated, rejectedRecords sql.NullInt64
+	var rejectsByReason []byte
+	if job.Summary != nil {
+		totalRecords = sql.NullInt64{Int64: int64(job.Summary.TotalRecords), Valid: true}
+		usersUpdated = sql.NullInt64{Int64: int64(job.Summary.UsersUpdated), Valid: true}
+		usersCreated = sql.NullInt64{Int64: int64(job.Summary.UsersCreated), Valid: true}
+		rejectedRecords = sql.NullInt64{Int64: int64(job.Summary.RejectedRecords), Valid: true}
+		if len(job.Summary.RejectsByReason) > 0 {
+			rejectsByReason, _ = json.Marshal(job.Summary.RejectsByReason)
+		}
+	}
+
+	return []interface{}{job.ID, job.Status, job.Progress.RowsValidated, job.Progress.RowsInserted,
+		job.Progress.BatchesDone, job.Progress.BatchesTotal, totalRecords, usersUpdated, job.Error, rejectedRecords,
+		rejectsByReason, usersCreated}
+}
+
+func checkJobAffected(result sql.Result) error {
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rowsAffected == 0 {
+		return errors.New(migration.NotFoundError)
+	}
+
+	return nil
+}
+
+// handleImportedFileError tells a job that lost the race to import a file to another upload of it
+func handleImportedFileError(err error) error {
+	var pqErr *pq.Error
+	if errors.As(err, &pqErr) {
+		if pqErr.Code == "23505" && pqErr.Constraint == migrationJobsImportedIndex {
+			return errors.New(migration.DuplicateFileError)
+		}
+	}
+	return nil
+}
+
+const (
+	migrationJobsImportedIndex = "idx_migration_jobs_imported_content_hash"
+	SaveMigrationJob           = `
+	INSERT INTO migration_jobs (status, file_name, mode, checksum, uploaded_by, content_hash, forced, duplicate_of,
+		report_destinations)
+	VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)
+	RETURNING id`
+	migrationJobColumns = `
+	SELECT id, status, file_name, checksum, content_hash, forced, duplicate_of, uploaded_by, mode, rows_validated,
+		rows_inserted, batches_done, batches_total, total_records, users_updated, users_created, rejected_records,
+		rejects_by_reason, error, created_at, updated_at, rolled_back_at, report_destinations
+	FROM migration_jobs`
+	FindMigrationJobByID     = migrationJobColumns + " WHERE id = $1"
+	FindImportedMigrationJob = migrationJobColumns + `
+	WHERE (checksum = $1 OR content_hash = $2) AND status IN ('pending', 'running', 'completed')
+	ORDER BY id DESC LIMIT 1`
+	ListMigrationJobs      = migrationJobColumns + " ORDER BY id DESC LIMIT $1"
+	ListMigrationJobsAfter = migrationJobColumns + " WHERE id < $2 ORDER BY id DESC LIMIT $1"
+	LockMigrationJob       = "SELECT status FROM migration_jobs WHERE id = $1 FOR UPDATE"
+	FindStaleMigrationJobs = migrationJobColumns + `
+	WHERE status IN ('pending', 'running') AND updated_at < $1 ORDER BY id`
+	// Transactions deleted through the API were edited too, so the deleted ones are counted as well
+	DeleteMigrationTransactions = `
+	WITH deleted AS (
+		UPDATE transactions SET is_deleted = TRUE WHERE migration_id = $1 RETURNING edited_at
+	)
+	SELECT COUNT(*), COUNT(edited_at) FROM deleted`
+	RollBackMigrationJob = `
+	UPDATE migration_jobs SET status = 'rolled_back', rolled_back_at = NOW(), updated_at = NOW() WHERE id = $1`
+	// Only active jobs are written, so two instances recovering the same interrupted job can't both finish it and a
+	// late progress update can't revive a job that was failed or rolled back since
+	UpdateMigrationJob = `
+	UPDATE migration_jobs
+	SET status = $2, rows_validated = $3, rows_inserted = $4, batches_done = $5, batches_total = $6,
+		total_records = $7, users_updated = $8, error = $9, rejected_records = $10, rejects_by_reason = $11,
+		users_created = $12, updated_at = NOW()
+	WHERE id = $1 AND status IN ('pending', 'running')`
+	HeartbeatMigrationJob  = "UPDATE migration_jobs SET updated_at = NOW() WHERE id = $1"
+	FailStaleMigrationJobs = `
+	UPDATE migration_jobs SET status = 'failed', error = $2, updated_at = NOW()
+	WHERE status IN ('pending', 'running') AND updated_at < $1`
+	SaveMigrationRejects = `
+	INSERT INTO migration_rejects (job_id, line, raw, reason, detail)
+	SELECT $1, r.line, r.raw, r.reason, r.detail
+	FROM unnest(CAST($2 AS INT[]), CAST($3 AS TEXT[]), CAST($4 AS TEXT[]), CAST($5 AS TEXT[]))
+		AS r(line, raw, reason, detail)`
+	FindMigrationRejectsByJobID = `
+	SELECT line, raw, reason, detail FROM migration_rejects WHERE job_id = $1 ORDER BY line`
+	CountMigrationTransactions = `
+	SELECT COUNT(*), COUNT(DISTINCT user_id) FROM transactions WHERE migration_id = $1`
+	CountMigrationRejects = "SELECT reason, COUNT(*) FROM migration_rejects WHERE job_id = $1 GROUP BY reason"
+)
@@ -52,10 +52,183 @@ func (s *sqlMigrations) RunMigrations() error {
 		return err
 	}
 
+	if err := s.resolveDuplicateUsersEmail(); err != nil {
+		s.log.ErrorAt(fmt.Errorf("failed to resolve duplicate users email: %w", err),
+			RunMigrationsName, "resolveDuplicateUsersEmail")
+		return err
+	}
+
+	if _, err := s.db.Exec(normalizeUsersEmail); err != nil {
+		s.log.ErrorAt(fmt.Errorf("failed to normalize users email: %w", err),
+			RunMigrationsName, "normalizeUsersEmail")
+		return err
+	}
+
+	if _, err := s.db.Exec(createUsersEmailUniqueIndex); err != nil {
+		s.log.ErrorAt(fmt.Errorf("failed to create users email unique index: %w", err),
+			RunMigrationsName, "createUsersEmailUniqueIndex")
+		return err
+	}
+
+	if _, err := s.db.Exec(createUsersNameIndex); err != nil {
+		s.log.ErrorAt(fmt.Errorf("failed to create users name index: %w", err),
+			RunMigrationsName, "createUsersNameIndex")
+		return err
+	}
+
+	if _, err := s.db.Exec(createUsersEmailIndex); err != nil {
+		s.log.ErrorAt(fmt.Errorf("failed to create users email index: %w", err),
+			RunMigrationsName, "createUsersEmailIndex")
+		return err
+	}
+
+	if _, err := s.db.Exec(createTransactionsBalanceIndex); err != nil {
+		s.log.ErrorAt(fmt.Errorf("failed to create transactions balance index: %w", err),
+			RunMigrationsName, "createTransactionsBalanceIndex")
+		return err
+	}
+
+	if _, err := s.db.Exec(createMigrationJobsTable); err != nil {
+		s.log.ErrorAt(fmt.Errorf("failed to create migration jobs table: %w", err),
+			RunMigrationsName, "createMigrationJobsTable")
+		return err
+	}
+
+	if _, err := s.db.Exec(addMigrationJobsRejectColumns); err != nil {
+		s.log.ErrorAt(fmt.Errorf("failed to add migration jobs reject columns: %w", err),
+			RunMigrationsName, "addMigrationJobsRejectColumns")
+		return err
+	}
+
+	if _, err := s.db.Exec(createMigrationRejectsTable); err != nil {
+		s.log.ErrorAt(fmt.Errorf("failed to create migration rejects table: %w", err),
+			RunMigrationsName, "createMigrationRejectsTable")
+		return err
+	}
+
+	if _, err := s.db.Exec(createTransactionStagesTable); err != nil {
+		s.log.ErrorAt(fmt.Errorf("failed to create transaction stages table: %w", err),
+			RunMigrationsName, "createTransactionStagesTable")
+		return err
+	}
+
+	if _, err := s.db.Exec(createMigrationProfilesTable); err != nil {
+		s.log.ErrorAt(fmt.Errorf("failed to create migration profiles table: %w", err),
+			RunMigrationsName, "createMigrationProfilesTable")
+		return err
+	}
+
+	if _, err := s.db.Exec(addMigrationJobsHistoryColumns); err != nil {
+		s.log.ErrorAt(fmt.Errorf("failed to add migration jobs history columns: %w", err),
+			RunMigrationsName, "addMigrationJobsHistoryColumns")
+		return err
+	}
+
+	if _, err := s.db.Exec(addTransactionsMigrationColumns); err != nil {
+		s.log.ErrorAt(fmt.Errorf("failed to add transactions migration columns: %w", err),
+			RunMigrationsName, "addTransactionsMigrationColumns")
+		return err
+	}
+
+	if _, err := s.db.Exec(addMigrationJobsFingerprintColumns); err != nil {
+		s.log.ErrorAt(fmt.Errorf("failed to add migration jobs fingerprint columns: %w", err),
+			RunMigrationsName, "addMigrationJobsFingerprintColumns")
+		return err
+	}
+
+	if _, err := s.db.Exec(addMigrationJobsUsersCreatedColumn); err != nil {
+		s.log.ErrorAt(fmt.Errorf("failed to add migration jobs users created column: %w", err),
+			RunMigrationsName, "addMigrationJobsUsersCreatedColumn")
+		return err
+	}
+
+	if _, err := s.db.Exec(createOutboxTables); err != nil {
+		s.log.ErrorAt(fmt.Errorf("failed to create outbox tables: %w", err),
+			RunMigrationsName, "createOutboxTables")
+		return err
+	}
+
+	if _, err := s.db.Exec(addOutboxReferenceColumn); err != nil {
+		s.log.ErrorAt(fmt.Errorf("failed to add outbox reference column: %w", err),
+			RunMigrationsName, "addOutboxReferenceColumn")
+		return err
+	}
+
+	if _, err := s.db.Exec(createWebhookSubscriptionsTable); err != nil {
+		s.log.ErrorAt(fmt.Errorf("failed to create webhook subscriptions table: %w", err),
+			RunMigrationsName, "createWebhookSubscriptionsTable")
+		return err
+	}
+
+	if _, err := s.db.Exec(createAlertTables); err != nil {
+		s.log.ErrorAt(fmt.Errorf("failed to create alert tables: %w", err),
+			RunMigrationsName, "createAlertTables")
+		return err
+	}
+
+	if _, err := s.db.Exec(createBalanceChangesTrigger); err != nil {
+		s.log.ErrorAt(fmt.Errorf("failed to create balance changes trigger: %w", err),
+			RunMigrationsName, "createBalanceChangesTrigger")
+		return err
+	}
+
+	if _, err := s.db.Exec(createChangesTables); err != nil {
+		s.log.ErrorAt(fmt.Errorf("failed to create changes tables: %w", err),
+			RunMigrationsName, "createChangesTables")
+		return err
+	}
+
+	if _, err := s.db.Exec(createMigrationJobsImportedIndex); err != nil {
+		s.log.ErrorAt(fmt.Errorf("failed to create migration jobs imported index: %w", err),
+			RunMigrationsName, "createMigrationJobsImportedIndex")
+		return err
+	}
+
+	if _, err := s.db.Exec(addMigrationJobsReportDestinationsColumn); err != nil {
+		s.log.ErrorAt(fmt.Errorf("failed to add migration jobs report destinations column: %w", err),
+			RunMigrationsName, "addMigrationJobsReportDestinationsColumn")
+		return err
+	}
+
+	if _, err := s.db.Exec(addMigrationProfilesStrictColumn); err != nil {
+		s.log.ErrorAt(fmt.Errorf("failed to add migration profiles strict column: %w", err),
+			RunMigrationsName, "addMigrationProfilesStrictColumn")
+		return err
+	}
+
 	s.log.Info("Database migrations executed successfully")
 	return nil
 }
 
+// resolveDuplicateUsersEmail runs before the emails are normalized, since normalizing makes more of them collide
+// and the unique index could not be built over them
+func (s *sqlMigrations) resolveDuplicateUsersEmail() error {
+	rows, err := s.db.Query(resolveDuplicateUsersEmail)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	var userIDs []string
+	for rows.Next() {
+		var userID string
+		if err = rows.Scan(&userID); err != nil {
+			return err
+		}
+		userIDs = append(userIDs, userID)
+	}
+
+	if err = rows.Err(); err != nil {
+		return err
+	}
+
+	if len(userIDs) > 0 {
+		s.log.Warn("Duplicate users email renamed", "user_ids", userIDs)
+	}
+
+	return nil
+}
+
 const (
 	createUsersTable = `
 	CREATE TABLE IF NOT EXISTS users (
@@ -80,4 +253,265 @@ const (
 
 	createDateTimeIndex = `
 	CREATE INDEX IF NOT EXISTS idx_transactions_date_time ON transactions(date_time);`
+
+	// An email shared by active users once it's normalized is kept by the one with the lowest id, the others get it
+	// prefixed with their id so the unique index can be built without losing it
+	resolveDuplicateUsersEmail = `
+	UPDATE users u SET email = CONCAT('duplicate-', u.id, '-', LOWER(TRIM(u.email)))
+	FROM (
+		SELECT id, ROW_NUMBER() OVER (PARTITION BY LOWER(TRIM(email)) ORDER BY id) AS position
+		FROM users
+		WHERE is_deleted = FALSE AND TRIM(email) <> ''
+	) duplicates
+	WHERE u.id = duplicates.id AND duplicates.position > 1
+	RETURNING u.id`
+
+	normalizeUsersEmail = `
+	UPDATE users SET email = LOWER(TRIM(email)) WHERE email <> LOWER(TRIM(email));`
+
+	// Soft-deleted users are left out so their email can be registered again, and so are the users without an email,
+	// which migrations create when the file has none. It replaces the first index, which only left out the
+	// soft-deleted users, under a new name so databases that already have it get the new predicate
+	createUsersEmailUniqueIndex = `
+	DROP INDEX IF EXISTS idx_users_email_unique;
+	CREATE UNIQUE INDEX IF NOT EXISTS idx_users_active_email_unique ON users (LOWER(email))
+	WHERE is_deleted = FALSE AND email <> '';`
+
+	createUsersNameIndex = `
+	CREATE INDEX IF NOT EXISTS idx_users_name ON users(first_name, last_name, id);`
+
+	createUsersEmailIndex = `
+	CREATE INDEX IF NOT EXISTS idx_users_email ON users(email, id);`
+
+	// Covers the balance aggregation so it never has to read the table rows
+	createTransactionsBalanceIndex = `
+	CREATE INDEX IF NOT EXISTS idx_transactions_user_id_date_time_amount ON transactions(user_id, date_time)
+	INCLUDE (amount) WHERE NOT is_deleted;`
+
+	createMigrationJobsTable = `
+	CREATE TABLE IF NOT EXISTS migration_jobs (
+	id BIGSERIAL PRIMARY KEY,
+	status VARCHAR(20) NOT NULL,
+	file_name VARCHAR(255) NOT NULL DEFAULT '',
+	rows_validated INT NOT NULL DEFAULT 0,
+	rows_inserted INT NOT NULL DEFAULT 0,
+	batches_done INT NOT NULL DEFAULT 0,
+	batches_total INT NOT NULL DEFAULT 0,
+	total_records INT,
+	users_updated INT,
+	error TEXT NOT NULL DEFAULT '',
+	created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+	updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+	);`
+
+	addMigrationJobsRejectColumns = `
+	ALTER TABLE migration_jobs
+	ADD COLUMN IF NOT EXISTS mode VARCHAR(20) NOT NULL DEFAULT 'default',
+	ADD COLUMN IF NOT EXISTS rejected_records INT,
+	ADD COLUMN IF NOT EXISTS rejects_by_reason JSONB;`
+
+	createMigrationRejectsTable = `
+	CREATE TABLE IF NOT EXISTS migration_rejects (
+	job_id BIGINT NOT NULL REFERENCES migration_jobs(id) ON DELETE CASCADE,
+	line INT NOT NULL,
+	raw TEXT NOT NULL,
+	reason VARCHAR(50) NOT NULL,
+	detail TEXT NOT NULL
+	);
+	CREATE INDEX IF NOT EXISTS idx_migration_rejects_job_id_line ON migration_rejects(job_id, line);`
+
+	// Strict migrations are staged here until they commit, the table is unlogged since a stage lost in a crash
+	// was never committed anyway
+	createTransactionStagesTable = `
+	CREATE SEQUENCE IF NOT EXISTS transaction_stage_seq;
+	CREATE UNLOGGED TABLE IF NOT EXISTS transaction_stages (
+	stage_id BIGINT NOT NULL,
+	id VARCHAR(255) NOT NULL,
+	user_id BIGINT NOT NULL,
+	amount DECIMAL(10, 2) NOT NULL,
+	date_time TIMESTAMPTZ NOT NULL
+	);
+	CREATE INDEX IF NOT EXISTS idx_transaction_stages_stage_id ON transaction_stages(stage_id);`
+
+	createMigrationProfilesTable = `
+	CREATE TABLE IF NOT EXISTS migration_profiles (
+	name VARCHAR(64) PRIMARY KEY,
+	columns JSONB NOT NULL,
+	ignored_columns JSONB NOT NULL,
+	defaults JSONB NOT NULL,
+	created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+	);`
+
+	addMigrationJobsHistoryColumns = `
+	ALTER TABLE migration_jobs
+	ADD COLUMN IF NOT EXISTS checksum VARCHAR(64) NOT NULL DEFAULT '',
+	ADD COLUMN IF NOT EXISTS uploaded_by VARCHAR(255) NOT NULL DEFAULT '',
+	ADD COLUMN IF NOT EXISTS rolled_back_at TIMESTAMPTZ;`
+
+	// edited_at stays NULL until the transaction is updated or deleted through the API, a migration can only be
+	// rolled back while all of its transactions are untouched
+	addTransactionsMigrationColumns = `
+	ALTER TABLE transactions
+	ADD COLUMN IF NOT EXISTS migration_id BIGINT REFERENCES migration_jobs(id),
+	ADD COLUMN IF NOT EXISTS edited_at TIMESTAMPTZ;
+	ALTER TABLE transaction_stages ADD COLUMN IF NOT EXISTS migration_id BIGINT;
+	CREATE INDEX IF NOT EXISTS idx_transactions_migration_id ON transactions(migration_id, id)
+	WHERE migration_id IS NOT NULL;`
+
+	// Jobs saved before the content hash existed keep it empty, they are only matched by checksum
+	addMigrationJobsFingerprintColumns = `
+	ALTER TABLE migration_jobs
+	ADD COLUMN IF NOT EXISTS content_hash VARCHAR(64) NOT NULL DEFAULT '',
+	ADD COLUMN IF NOT EXISTS forced BOOLEAN NOT NULL DEFAULT FALSE,
+	ADD COLUMN IF NOT EXISTS duplicate_of BIGINT REFERENCES migration_jobs(id);
+	CREATE INDEX IF NOT EXISTS idx_migration_jobs_checksum ON migration_jobs(checksum);
+	CREATE INDEX IF NOT EXISTS idx_migration_jobs_content_hash ON migration_jobs(content_hash);`
+
+	addMigrationJobsUsersCreatedColumn = `
+	ALTER TABLE migration_jobs ADD COLUMN IF NOT EXISTS users_created INT;`
+
+	// The messages are saved in the database transaction of the change that causes them, locked_until is the lease
+	// of the dispatcher sending a message
+	createOutboxTables = `
+	CREATE TABLE IF NOT EXISTS outbox (
+	id BIGSERIAL PRIMARY KEY,
+	kind VARCHAR(50) NOT NULL,
+	description TEXT NOT NULL DEFAULT '',
+	payload JSONB NOT NULL,
+	status VARCHAR(20) NOT NULL DEFAULT 'pending',
+	attempts INT NOT NULL DEFAULT 0,
+	last_error TEXT NOT NULL DEFAULT '',
+	next_attempt_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+	locked_until TIMESTAMPTZ,
+	created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+	sent_at TIMESTAMPTZ
+	);
+	CREATE INDEX IF NOT EXISTS idx_outbox_pending_next_attempt_at ON outbox(next_attempt_at)
+	WHERE status = 'pending';
+	CREATE TABLE IF NOT EXISTS outbox_attempts (
+	id BIGSERIAL PRIMARY KEY,
+	message_id BIGINT NOT NULL REFERENCES outbox(id) ON DELETE CASCADE,
+	attempted_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+	error TEXT NOT NULL DEFAULT ''
+	);
+	CREATE INDEX IF NOT EXISTS idx_outbox_attempts_message_id ON outbox_attempts(message_id, id);`
+
+	// The reference groups the messages of an owner, like the deliveries of a webhook subscription
+	addOutboxReferenceColumn = `
+	ALTER TABLE outbox ADD COLUMN IF NOT EXISTS reference VARCHAR(100) NOT NULL DEFAULT '';
+	CREATE INDEX IF NOT EXISTS idx_outbox_reference ON outbox(reference, id) WHERE reference <> '';`
+
+	createWebhookSubscriptionsTable = `
+	CREATE TABLE IF NOT EXISTS webhook_subscriptions (
+	id BIGSERIAL PRIMARY KEY,
+	url TEXT NOT NULL,
+	description TEXT NOT NULL DEFAULT '',
+	events TEXT[] NOT NULL,
+	active BOOLEAN NOT NULL DEFAULT TRUE,
+	secret TEXT NOT NULL,
+	created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+	updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+	);
+	CREATE INDEX IF NOT EXISTS idx_webhook_subscriptions_events ON webhook_subscriptions USING GIN (events);`
+
+	// The alerts are the history of the rules, they are kept when their rule is deleted
+	createAlertTables = `
+	CREATE TABLE IF NOT EXISTS alert_rules (
+	id BIGSERIAL PRIMARY KEY,
+	user_id BIGINT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+	type VARCHAR(20) NOT NULL,
+	threshold DECIMAL(12, 2) NOT NULL,
+	recipients TEXT[] NOT NULL,
+	active BOOLEAN NOT NULL DEFAULT TRUE,
+	triggered_at TIMESTAMPTZ,
+	created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+	updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+	);
+	CREATE INDEX IF NOT EXISTS idx_alert_rules_user_id ON alert_rules(user_id) WHERE active;
+	CREATE TABLE IF NOT EXISTS alerts (
+	id BIGSERIAL PRIMARY KEY,
+	rule_id BIGINT NOT NULL,
+	user_id BIGINT NOT NULL,
+	type VARCHAR(20) NOT NULL,
+	threshold DECIMAL(12, 2) NOT NULL,
+	value DECIMAL(12, 2) NOT NULL,
+	transaction_id VARCHAR(255) NOT NULL DEFAULT '',
+	recipients TEXT[] NOT NULL,
+	created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+	);
+	CREATE INDEX IF NOT EXISTS idx_alerts_user_id ON alerts(user_id, id);
+	CREATE UNIQUE INDEX IF NOT EXISTS idx_alerts_rule_transaction ON alerts(rule_id, transaction_id)
+	WHERE transaction_id <> '';`
+
+	// Every write of the transactions notifies the users whose balance it changed, a transaction moved to another
+	// user notifies both. The triggers run once per statement over its transition tables, so a batch notifies each
+	// of its users once instead of running a notification per row. Transition tables take a single event per trigger
+	createBalanceChangesTrigger = `
+	CREATE OR REPLACE FUNCTION notify_balance_change() RETURNS TRIGGER AS $$
+	BEGIN
+		IF TG_OP <> 'INSERT' THEN
+			PERFORM pg_notify('balance_changes', changed.user_id::TEXT)
+			FROM (SELECT DISTINCT user_id FROM old_rows) AS changed;
+		END IF;
+		IF TG_OP <> 'DELETE' THEN
+			PERFORM pg_notify('balance_changes', changed.user_id::TEXT)
+			FROM (SELECT DISTINCT user_id FROM new_rows) AS changed;
+		END IF;
+		RETURN NULL;
+	END;
+	$$ LANGUAGE plpgsql;
+	DROP TRIGGER IF EXISTS transactions_balance_changes ON transactions;
+	DROP TRIGGER IF EXISTS transactions_balance_changes_insert ON transactions;
+	CREATE TRIGGER transactions_balance_changes_insert AFTER INSERT ON transactions
+	REFERENCING NEW TABLE AS new_rows
+	FOR EACH STATEMENT EXECUTE FUNCTION notify_balance_change();
+	DROP TRIGGER IF EXISTS transactions_balance_changes_update ON transactions;
+	CREATE TRIGGER transactions_balance_changes_update AFTER UPDATE ON transactions
+	REFERENCING OLD TABLE AS old_rows NEW TABLE AS new_rows
+	FOR EACH STATEMENT EXECUTE FUNCTION notify_balance_change();
+	DROP TRIGGER IF EXISTS transactions_balance_changes_delete ON transactions;
+	CREATE TRIGGER transactions_balance_changes_delete AFTER DELETE ON transactions
+	REFERENCING OLD TABLE AS old_rows
+	FOR EACH STATEMENT EXECUTE FUNCTION notify_balance_change();`
+
+	// The sequence is a single row table instead of a Postgres sequence: its row stays locked by the writer that
+	// advanced it until the writer commits, so the cursors of the changes are given in commit order
+	createChangesTables = `
+	CREATE TABLE IF NOT EXISTS change_sequence (
+	id BOOLEAN PRIMARY KEY DEFAULT TRUE CHECK (id),
+	value BIGINT NOT NULL
+	);
+	INSERT INTO change_sequence (id, value) VALUES (TRUE, 0) ON CONFLICT DO NOTHING;
+	CREATE TABLE IF NOT EXISTS changes (
+	id BIGINT PRIMARY KEY,
+	entity VARCHAR(20) NOT NULL,
+	entity_id VARCHAR(255) NOT NULL,
+	operation VARCHAR(20) NOT NULL,
+	data JSONB NOT NULL,
+	created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+	);`
+
+	// Only one job that is not forced can import or be importing a content, so two uploads of the same file can't
+	// both pass the duplicate check. The same checksum is the same content, only the jobs saved before the content
+	// hash existed are matched by checksum alone and they are not saved anymore. Jobs that passed the check together
+	// before the index existed are marked as forced duplicates of the first one
+	createMigrationJobsImportedIndex = `
+	UPDATE migration_jobs j SET forced = TRUE, duplicate_of = duplicates.first_id
+	FROM (
+		SELECT id, FIRST_VALUE(id) OVER (PARTITION BY content_hash ORDER BY id) AS first_id,
+			ROW_NUMBER() OVER (PARTITION BY content_hash ORDER BY id) AS position
+		FROM migration_jobs
+		WHERE forced = FALSE AND content_hash <> '' AND status IN ('pending', 'running', 'completed')
+	) duplicates
+	WHERE j.id = duplicates.id AND duplicates.position > 1;
+	CREATE UNIQUE INDEX IF NOT EXISTS ` + migrationJobsImportedIndex + ` ON migration_jobs(content_hash)
+	WHERE forced = FALSE AND content_hash <> '' AND status IN ('pending', 'running', 'completed');`
+
+	// The report of a job interrupted after its transactions were committed is sent on startup to the same addresses
+	addMigrationJobsReportDestinationsColumn = `
+	ALTER TABLE migration_jobs ADD COLUMN IF NOT EXISTS report_destinations TEXT[] NOT NULL DEFAULT '{}';`
+
+	// The profiles saved before the flag skip the unknown columns like the new ones, strict has to be set on them
+	addMigrationProfilesStrictColumn = `
+	ALTER TABLE migration_profiles ADD COLUMN IF NOT EXISTS strict BOOLEAN NOT NULL DEFAULT FALSE;`
 )
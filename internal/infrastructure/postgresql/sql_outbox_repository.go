@@ -0,0 +1,300 @@
+package postgresql
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/sebastianreh/user-balance-api/internal/domain/outbox"
+	"github.com/sebastianreh/user-balance-api/pkg/logger"
+)
+
+type sqlOutboxRepository struct {
+	log logger.Logger
+	db  *sql.DB
+}
+
+func NewSQLOutboxRepository(log logger.Logger, db *sql.DB) outbox.Repository {
+	return &sqlOutboxRepository{
+		log: log,
+		db:  db,
+	}
+}
+
+func (s *sqlOutboxRepository) Save(ctx context.Context, messages ...outbox.Message) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		s.log.ErrorAt(err, outbox.RepositoryName, "Save")
+		return err
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	if err = saveOutboxMessages(ctx, tx, messages); err != nil {
+		s.log.ErrorAt(err, outbox.RepositoryName, "Save")
+		return err
+	}
+
+	if err = tx.Commit(); err != nil {
+		s.log.ErrorAt(err, outbox.RepositoryName, "Save")
+		return err
+	}
+
+	return nil
+}
+
+func (s *sqlOutboxRepository) ClaimDue(ctx context.Context, limit int, lease time.Duration) ([]outbox.Message, error) {
+	rows, err := s.db.QueryContext(ctx, ClaimDueOutboxMessages, limit, lease.Milliseconds())
+	if err != nil {
+		s.log.ErrorAt(err, outbox.RepositoryName, "ClaimDue")
+		return nil, err
+	}
+	defer rows.Close()
+
+	messages, err := scanOutboxMessages(rows)
+	if err != nil {
+		s.log.ErrorAt(err, outbox.RepositoryName, "ClaimDue")
+		return nil, err
+	}
+
+	return messages, nil
+}
+
+func (s *sqlOutboxRepository) MarkSent(ctx context.Context, messageID string) error {
+	result, err := s.db.ExecContext(ctx, MarkOutboxMessageSent, messageID)
+	if err != nil {
+		s.log.ErrorAt(err, outbox.RepositoryName, "MarkSent")
+		return err
+	}
+
+	return checkOutboxAffected(result)
+}
+
+func (s *sqlOutboxRepository) MarkFailed(ctx context.Context, messageID, attemptError string,
+	nextAttemptAt *time.Time) error {
+	result, err := s.db.ExecContext(ctx, MarkOutboxMessageFailed, messageID, attemptError, nextAttemptAt)
+	if err != nil {
+		s.log.ErrorAt(err, outbox.RepositoryName, "MarkFailed")
+		return err
+	}
+
+	return checkOutboxAffected(result)
+}
+
+func (s *sqlOutboxRepository) List(ctx context.Context, options outbox.ListOptions) ([]outbox.Message, error) {
+	query, args := ListOutboxMessages, []interface{}{options.Limit, options.Status, options.Reference}
+	if options.After != nil {
+		query, args = ListOutboxMessagesAfter, append(args, options.After.ID)
+	}
+
+	rows, err := s.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		s.log.ErrorAt(err, outbox.RepositoryName, "List")
+		return nil, err
+	}
+	defer rows.Close()
+
+	messages, err := scanOutboxMessages(rows)
+	if err != nil {
+		s.log.ErrorAt(err, outbox.RepositoryName, "List")
+		return nil, err
+	}
+
+	return messages, nil
+}
+
+func (s *sqlOutboxRepository) FindByID(ctx context.Context, messageID string) (outbox.Message, error) {
+	message, err := scanOutboxMessage(s.db.QueryRowContext(ctx, FindOutboxMessageByID, messageID))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return message, errors.New(outbox.NotFoundError)
+		}
+
+		s.log.ErrorAt(err, outbox.RepositoryName, "FindByID")
+		return message, err
+	}
+
+	rows, err := s.db.QueryContext(ctx, FindOutboxAttempts, messageID)
+	if err != nil {
+		s.log.ErrorAt(err, outbox.RepositoryName, "FindByID")
+		return message, err
+	}
+	defer rows.Close()
+
+	message.History = make([]outbox.Attempt, 0, message.Attempts)
+	for rows.Next() {
+		var attempt outbox.Attempt
+		if err = rows.Scan(&attempt.AttemptedAt, &attempt.Error); err != nil {
+			s.log.ErrorAt(err, outbox.RepositoryName, "FindByID")
+			return message, err
+		}
+
+		message.History = append(message.History, attempt)
+	}
+
+	if err = rows.Err(); err != nil {
+		s.log.ErrorAt(err, outbox.RepositoryName, "FindByID")
+		return message, err
+	}
+
+	return message, nil
+}
+
+// Redrive locks the message so a dispatcher can't mark it while it's checked. Its lease is kept, a message being
+// sent is not claimed again until the dispatcher that holds it is done
+func (s *sqlOutboxRepository) Redrive(ctx context.Context, messageID string) (outbox.Message, error) {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		s.log.ErrorAt(err, outbox.RepositoryName, "Redrive")
+		return outbox.Message{}, err
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	message, err := scanOutboxMessage(tx.QueryRowContext(ctx, LockOutboxMessage, messageID))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return message, errors.New(outbox.NotFoundError)
+		}
+
+		s.log.ErrorAt(err, outbox.RepositoryName, "Redrive")
+		return message, err
+	}
+
+	if !message.Redrivable() {
+		return message, errors.New(outbox.NotRedrivableError)
+	}
+
+	if message, err = scanOutboxMessage(tx.QueryRowContext(ctx, RedriveOutboxMessage, messageID)); err != nil {
+		s.log.ErrorAt(err, outbox.RepositoryName, "Redrive")
+		return message, err
+	}
+
+	if err = tx.Commit(); err != nil {
+		s.log.ErrorAt(err, outbox.RepositoryName, "Redrive")
+		return message, err
+	}
+
+	return message, nil
+}
+
+func (s *sqlOutboxRepository) Duplicate(ctx context.Context, messageID string) (outbox.Message, error) {
+	message, err := scanOutboxMessage(s.db.QueryRowContext(ctx, DuplicateOutboxMessage, messageID))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return message, errors.New(outbox.NotFoundError)
+		}
+
+		s.log.ErrorAt(err, outbox.RepositoryName, "Duplicate")
+		return message, err
+	}
+
+	return message, nil
+}
+
+// saveOutboxMessages is used by the repositories that save a change along with the messages it causes
+func saveOutboxMessages(ctx context.Context, tx *sql.Tx, messages []outbox.Message) error {
+	for _, message := range messages {
+		if _, err := tx.ExecContext(ctx, SaveOutboxMessage, message.Kind, message.Description, message.Reference,
+			[]byte(message.Payload)); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func scanOutboxMessages(rows *sql.Rows) ([]outbox.Message, error) {
+	messages := make([]outbox.Message, 0)
+	for rows.Next() {
+		message, err := scanOutboxMessage(rows)
+		if err != nil {
+			return nil, err
+		}
+
+		messages = append(messages, message)
+	}
+
+	return messages, rows.Err()
+}
+
+func scanOutboxMessage(row rowScanner) (outbox.Message, error) {
+	var message outbox.Message
+	var payload []byte
+	err := row.Scan(&message.ID, &message.Kind, &message.Description, &message.Reference, &payload, &message.Status,
+		&message.Attempts, &message.LastError, &message.NextAttemptAt, &message.CreatedAt, &message.SentAt)
+	if err != nil {
+		return message, err
+	}
+
+	message.Payload = payload
+	return message, nil
+}
+
+func checkOutboxAffected(result sql.Result) error {
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rowsAffected == 0 {
+		return errors.New(outbox.NotFoundError)
+	}
+
+	return nil
+}
+
+const (
+	SaveOutboxMessage = `
+	INSERT INTO outbox (kind, description, reference, payload)
+	VALUES ($1, $2, $3, $4)`
+	outboxReturning = `
+	RETURNING id, kind, description, reference, payload, status, attempts, last_error, next_attempt_at, created_at,
+		sent_at`
+	outboxColumns = `
+	SELECT id, kind, description, reference, payload, status, attempts, last_error, next_attempt_at, created_at,
+		sent_at
+	FROM outbox`
+	// SKIP LOCKED lets several dispatchers claim different messages at the same time
+	ClaimDueOutboxMessages = `
+	UPDATE outbox SET locked_until = NOW() + $2::BIGINT * INTERVAL '1 millisecond'
+	WHERE id IN (
+		SELECT id FROM outbox
+		WHERE status = 'pending' AND next_attempt_at <= NOW() AND (locked_until IS NULL OR locked_until < NOW())
+		ORDER BY next_attempt_at, id
+		LIMIT $1
+		FOR UPDATE SKIP LOCKED
+	)` + outboxReturning
+	MarkOutboxMessageSent = `
+	WITH marked AS (
+		UPDATE outbox SET status = 'sent', attempts = attempts + 1, last_error = '', sent_at = NOW(),
+			locked_until = NULL
+		WHERE id = $1
+		RETURNING id
+	)
+	INSERT INTO outbox_attempts (message_id, error) SELECT id, '' FROM marked`
+	MarkOutboxMessageFailed = `
+	WITH marked AS (
+		UPDATE outbox SET status = CASE WHEN $3::TIMESTAMPTZ IS NULL THEN 'failed' ELSE 'pending' END,
+			attempts = attempts + 1, last_error = $2, next_attempt_at = COALESCE($3, next_attempt_at),
+			locked_until = NULL
+		WHERE id = $1
+		RETURNING id
+	)
+	INSERT INTO outbox_attempts (message_id, error) SELECT id, $2 FROM marked`
+	listOutboxFilters       = " WHERE ($2 = '' OR status = $2) AND ($3 = '' OR reference = $3)"
+	ListOutboxMessages      = outboxColumns + listOutboxFilters + " ORDER BY id DESC LIMIT $1"
+	ListOutboxMessagesAfter = outboxColumns + listOutboxFilters + " AND id < $4 ORDER BY id DESC LIMIT $1"
+	FindOutboxMessageByID   = outboxColumns + " WHERE id = $1"
+	LockOutboxMessage       = outboxColumns + " WHERE id = $1 FOR UPDATE"
+	FindOutboxAttempts      = `
+	SELECT attempted_at, error FROM outbox_attempts
+	WHERE message_id = $1
+	ORDER BY id`
+	RedriveOutboxMessage = `
+	UPDATE outbox SET status = 'pending', attempts = 0, next_attempt_at = NOW()
+	WHERE id = $1` + outboxReturning
+	DuplicateOutboxMessage = `
+	INSERT INTO outbox (kind, description, reference, payload)
+	SELECT kind, description, reference, payload FROM outbox
+	WHERE id = $1` + outboxReturning
+)
@@ -4,8 +4,13 @@ import (
 	"context"
 	"database/sql"
 	"errors"
+	"fmt"
+	"sort"
+	"time"
 
 	"github.com/lib/pq"
+	"github.com/sebastianreh/user-balance-api/internal/domain/change"
+	"github.com/sebastianreh/user-balance-api/internal/domain/outbox"
 	"github.com/sebastianreh/user-balance-api/internal/domain/transaction"
 	"github.com/sebastianreh/user-balance-api/internal/domain/user"
 	"github.com/sebastianreh/user-balance-api/pkg/logger"
@@ -23,7 +28,8 @@ func NewSQLTransactionRepository(log logger.Logger, db *sql.DB) transaction.Repo
 	}
 }
 
-func (s *sqlTransactionRepository) Save(ctx context.Context, userTransaction transaction.Transaction) error {
+func (s *sqlTransactionRepository) Save(ctx context.Context, userTransaction transaction.Transaction,
+	messages ...outbox.Message) error {
 	var userFound user.User
 	var oldTransaction transaction.Transaction
 	if userTransaction.Amount == 0 {
@@ -32,14 +38,15 @@ func (s *sqlTransactionRepository) Save(ctx context.Context, userTransaction tra
 
 	row := s.db.QueryRowContext(ctx, FindByID, userTransaction.ID)
 	err := row.Scan(&oldTransaction.ID, &oldTransaction.UserID,
-		&oldTransaction.Amount, &oldTransaction.DateTime, &oldTransaction.IsDeleted)
+		&oldTransaction.Amount, &oldTransaction.DateTime, &oldTransaction.IsDeleted, &oldTransaction.MigrationID)
 
 	if err != nil && !errors.Is(err, sql.ErrNoRows) {
 		return err
 	}
 
 	if oldTransaction.IsDeleted {
-		_, err = s.db.ExecContext(ctx, UpdateIsDeletedTransaction, userTransaction.ID, false)
+		err = execWithChange(ctx, s.db, change.EntityTransaction, userTransaction.ID, change.OperationRestore, messages,
+			UpdateIsDeletedTransaction, userTransaction.ID, false)
 		if err != nil {
 			s.log.ErrorAt(err, transaction.RepositoryName, "Update")
 			return err
@@ -67,8 +74,9 @@ func (s *sqlTransactionRepository) Save(ctx context.Context, userTransaction tra
 	}
 
 	query := SaveByUserID
-	_, err = s.db.ExecContext(ctx, query, userTransaction.ID, userTransaction.UserID,
-		userTransaction.Amount, userTransaction.DateTime)
+	err = execWithChange(ctx, s.db, change.EntityTransaction, userTransaction.ID, change.OperationInsert, messages,
+		query, userTransaction.ID, userTransaction.UserID, userTransaction.Amount, userTransaction.DateTime,
+		nullableID(userTransaction.MigrationID))
 	if err != nil {
 		s.log.ErrorAt(err, transaction.RepositoryName, "Save")
 		duplicateErr := handleDuplicateError(err)
@@ -81,9 +89,11 @@ func (s *sqlTransactionRepository) Save(ctx context.Context, userTransaction tra
 	return nil
 }
 
-func (s *sqlTransactionRepository) Delete(ctx context.Context, transactionID string) error {
+func (s *sqlTransactionRepository) Delete(ctx context.Context, transactionID string,
+	messages ...outbox.Message) error {
 	query := UpdateIsDeletedTransaction
-	_, err := s.db.ExecContext(ctx, query, transactionID, true)
+	err := execWithChange(ctx, s.db, change.EntityTransaction, transactionID, change.OperationSoftDelete, messages,
+		query, transactionID, true)
 	if err != nil {
 		s.log.ErrorAt(err, transaction.RepositoryName, "Update")
 		return err
@@ -108,14 +118,16 @@ func (s *sqlTransactionRepository) SaveBatch(ctx context.Context, transactions [
 	}
 	defer stmt.Close()
 
+	ids := make([]string, 0, len(transactions))
 	for _, transactionEntity := range transactions {
 		if transactionEntity.Amount == 0 {
 			_ = tx.Rollback()
 			return errors.New(transaction.ZeroAmountError)
 		}
+		ids = append(ids, transactionEntity.ID)
 
 		_, err = stmt.ExecContext(ctx, transactionEntity.ID, transactionEntity.UserID,
-			transactionEntity.Amount, transactionEntity.DateTime)
+			transactionEntity.Amount, transactionEntity.DateTime, nullableID(transactionEntity.MigrationID))
 		if err != nil {
 			s.log.ErrorAt(err, transaction.RepositoryName, "SaveBatch")
 			foreignKeyErr := handleForeignKeyError(err)
@@ -132,6 +144,14 @@ func (s *sqlTransactionRepository) SaveBatch(ctx context.Context, transactions [
 		}
 	}
 
+	err = recordChanges(ctx, tx, RecordTransactionChanges, change.EntityTransaction, change.OperationInsert,
+		pq.Array(ids))
+	if err != nil {
+		s.log.ErrorAt(err, transaction.RepositoryName, "SaveBatch")
+		_ = tx.Rollback()
+		return err
+	}
+
 	if err = tx.Commit(); err != nil {
 		s.log.ErrorAt(err, transaction.RepositoryName, "SaveBatch")
 		return err
@@ -140,14 +160,278 @@ func (s *sqlTransactionRepository) SaveBatch(ctx context.Context, transactions [
 	return nil
 }
 
+// SaveBatchSkippingRejected inserts the batch in one statement, transactions of unknown users and ids that already
+// exist are skipped. The users are read in the same database transaction to tell both cases apart
+func (s *sqlTransactionRepository) SaveBatchSkippingRejected(ctx context.Context,
+	transactions []transaction.Transaction) ([]transaction.Rejection, error) {
+	var rejections []transaction.Rejection
+	ids := make([]string, 0, len(transactions))
+	userIDs := make([]string, 0, len(transactions))
+	amounts := make([]float64, 0, len(transactions))
+	dates := make([]string, 0, len(transactions))
+	migrationIDs := make([]sql.NullString, 0, len(transactions))
+	for i, transactionEntity := range transactions {
+		if transactionEntity.Amount == 0 {
+			rejections = append(rejections, transaction.Rejection{Index: i, Reason: transaction.ZeroAmountError})
+			continue
+		}
+
+		ids = append(ids, transactionEntity.ID)
+		userIDs = append(userIDs, transactionEntity.UserID)
+		amounts = append(amounts, transactionEntity.Amount)
+		dates = append(dates, transactionEntity.DateTime.Format(time.RFC3339Nano))
+		migrationIDs = append(migrationIDs, nullableID(transactionEntity.MigrationID))
+	}
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		s.log.ErrorAt(err, transaction.RepositoryName, "SaveBatchSkippingRejected")
+		return nil, err
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	existingUsers, err := queryIDSet(ctx, tx, FindActiveUserIDs, pq.Array(userIDs))
+	if err != nil {
+		s.log.ErrorAt(err, transaction.RepositoryName, "SaveBatchSkippingRejected")
+		return nil, err
+	}
+
+	insertedIDs, err := queryIDSet(ctx, tx, SaveBatchSkippingRejected, pq.Array(ids), pq.Array(userIDs),
+		pq.Array(amounts), pq.Array(dates), pq.Array(migrationIDs))
+	if err != nil {
+		s.log.ErrorAt(err, transaction.RepositoryName, "SaveBatchSkippingRejected")
+		return nil, err
+	}
+
+	if len(insertedIDs) > 0 {
+		err = recordChanges(ctx, tx, RecordTransactionChanges, change.EntityTransaction, change.OperationInsert,
+			pq.Array(orderedIDs(ids, insertedIDs)))
+		if err != nil {
+			s.log.ErrorAt(err, transaction.RepositoryName, "SaveBatchSkippingRejected")
+			return nil, err
+		}
+	}
+
+	if err = tx.Commit(); err != nil {
+		s.log.ErrorAt(err, transaction.RepositoryName, "SaveBatchSkippingRejected")
+		return nil, err
+	}
+
+	// A repeated id inside the batch is inserted once, only its first appearance counts as saved
+	claimedIDs := make(map[string]bool, len(insertedIDs))
+	for i, transactionEntity := range transactions {
+		if transactionEntity.Amount == 0 {
+			continue
+		}
+
+		switch {
+		case insertedIDs[transactionEntity.ID] && !claimedIDs[transactionEntity.ID]:
+			claimedIDs[transactionEntity.ID] = true
+		case !existingUsers[transactionEntity.UserID]:
+			rejections = append(rejections, transaction.Rejection{Index: i, Reason: user.NotFoundError})
+		default:
+			rejections = append(rejections, transaction.Rejection{Index: i, Reason: transaction.DuplicateTransactionError})
+		}
+	}
+
+	sort.Slice(rejections, func(i, j int) bool {
+		return rejections[i].Index < rejections[j].Index
+	})
+
+	return rejections, nil
+}
+
+func (s *sqlTransactionRepository) CreateStage(ctx context.Context) (string, error) {
+	var stageID string
+	if err := s.db.QueryRowContext(ctx, CreateTransactionStage).Scan(&stageID); err != nil {
+		s.log.ErrorAt(err, transaction.RepositoryName, "CreateStage")
+		return "", err
+	}
+
+	return stageID, nil
+}
+
+// StageBatch copies the batch to the staging table in one statement, the constraints of the transactions are
+// checked when the stage is committed
+func (s *sqlTransactionRepository) StageBatch(ctx context.Context, stageID string,
+	transactions []transaction.Transaction) error {
+	ids := make([]string, 0, len(transactions))
+	userIDs := make([]string, 0, len(transactions))
+	amounts := make([]float64, 0, len(transactions))
+	migrationIDs := make([]sql.NullString, 0, len(transactions))
+	for _, transactionEntity := range transactions {
+		if transactionEntity.Amount == 0 {
+			return errors.New(transaction.ZeroAmountError)
+		}
+
+		ids = append(ids, transactionEntity.ID)
+		userIDs = append(userIDs, transactionEntity.UserID)
+		amounts = append(amounts, transactionEntity.Amount)
+		migrationIDs = append(migrationIDs, nullableID(transactionEntity.MigrationID))
+	}
+
+	_, err := s.db.ExecContext(ctx, StageTransactions, stageID, pq.Array(ids), pq.Array(userIDs), pq.Array(amounts),
+		pq.Array(formatDates(transactions)), pq.Array(migrationIDs))
+	if err != nil {
+		s.log.ErrorAt(err, transaction.RepositoryName, "StageBatch")
+		return err
+	}
+
+	return nil
+}
+
+// CommitStage moves the staged transactions to the ledger in a single database transaction, so either all of them
+// are saved or none is
+func (s *sqlTransactionRepository) CommitStage(ctx context.Context, stageID string) (int64, error) {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		s.log.ErrorAt(err, transaction.RepositoryName, "CommitStage")
+		return 0, err
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	result, err := tx.ExecContext(ctx, CommitTransactionStage, stageID)
+	if err != nil {
+		s.log.ErrorAt(err, transaction.RepositoryName, "CommitStage")
+		if foreignKeyErr := handleForeignKeyError(err); foreignKeyErr != nil {
+			return 0, foreignKeyErr
+		}
+
+		if duplicateErr := handleDuplicateError(err); duplicateErr != nil {
+			return 0, duplicateErr
+		}
+
+		return 0, err
+	}
+
+	err = recordChanges(ctx, tx, RecordStagedTransactionChanges, change.EntityTransaction, change.OperationInsert,
+		stageID)
+	if err != nil {
+		s.log.ErrorAt(err, transaction.RepositoryName, "CommitStage")
+		return 0, err
+	}
+
+	if err = tx.Commit(); err != nil {
+		s.log.ErrorAt(err, transaction.RepositoryName, "CommitStage")
+		return 0, err
+	}
+
+	return result.RowsAffected()
+}
+
+func (s *sqlTransactionRepository) DiscardStage(ctx context.Context, stageID string) error {
+	if _, err := s.db.ExecContext(ctx, DeleteTransactionStage, stageID); err != nil {
+		s.log.ErrorAt(err, transaction.RepositoryName, "DiscardStage")
+		return err
+	}
+
+	return nil
+}
+
+// orderedIDs keeps the ids in the set in the order they have in ids, each of them once
+func orderedIDs(ids []string, set map[string]bool) []string {
+	ordered := make([]string, 0, len(set))
+	added := make(map[string]bool, len(set))
+	for _, id := range ids {
+		if set[id] && !added[id] {
+			added[id] = true
+			ordered = append(ordered, id)
+		}
+	}
+
+	return ordered
+}
+
+// formatDates leaves a missing date as NULL so it fails on the column constraint like a single insert
+func formatDates(transactions []transaction.Transaction) []sql.NullString {
+	dates := make([]sql.NullString, 0, len(transactions))
+	for _, transactionEntity := range transactions {
+		if transactionEntity.DateTime == nil {
+			dates = append(dates, sql.NullString{})
+			continue
+		}
+
+		dates = append(dates, sql.NullString{String: transactionEntity.DateTime.Format(time.RFC3339Nano), Valid: true})
+	}
+
+	return dates
+}
+
+// nullableID stores an empty id as NULL
+func nullableID(id string) sql.NullString {
+	return sql.NullString{String: id, Valid: id != ""}
+}
+
+func (s *sqlTransactionRepository) FindExistingIDs(ctx context.Context,
+	transactionIDs []string) (map[string]bool, error) {
+	ids, err := queryIDSet(ctx, s.db, FindExistingTransactionIDs, pq.Array(transactionIDs))
+	if err != nil {
+		s.log.ErrorAt(err, transaction.RepositoryName, "FindExistingIDs")
+		return nil, err
+	}
+
+	return ids, nil
+}
+
+func (s *sqlTransactionRepository) FindMigrationUserIDs(ctx context.Context, migrationID string) ([]string, error) {
+	rows, err := s.db.QueryContext(ctx, FindMigrationUserIDs, migrationID)
+	if err != nil {
+		s.log.ErrorAt(err, transaction.RepositoryName, "FindMigrationUserIDs")
+		return nil, err
+	}
+	defer rows.Close()
+
+	userIDs := make([]string, 0)
+	for rows.Next() {
+		var userID string
+		if err = rows.Scan(&userID); err != nil {
+			s.log.ErrorAt(err, transaction.RepositoryName, "FindMigrationUserIDs")
+			return nil, err
+		}
+
+		userIDs = append(userIDs, userID)
+	}
+
+	if err = rows.Err(); err != nil {
+		s.log.ErrorAt(err, transaction.RepositoryName, "FindMigrationUserIDs")
+		return nil, err
+	}
+
+	return userIDs, nil
+}
+
+// idQuerier is satisfied by both *sql.DB and *sql.Tx
+type idQuerier interface {
+	QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error)
+}
+
+func queryIDSet(ctx context.Context, querier idQuerier, query string, args ...interface{}) (map[string]bool, error) {
+	rows, err := querier.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	ids := make(map[string]bool)
+	for rows.Next() {
+		var id string
+		if err = rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		ids[id] = true
+	}
+
+	return ids, rows.Err()
+}
+
 func (s *sqlTransactionRepository) Update(ctx context.Context, userTransaction transaction.Transaction) error {
 	query := UpdateTransaction
 	if userTransaction.Amount == 0 {
 		return errors.New(transaction.ZeroAmountError)
 	}
 
-	_, err := s.db.ExecContext(ctx, query, userTransaction.ID, userTransaction.UserID,
-		userTransaction.Amount, userTransaction.DateTime, userTransaction.IsDeleted)
+	err := execWithChange(ctx, s.db, change.EntityTransaction, userTransaction.ID, change.OperationUpdate, nil,
+		query, userTransaction.ID, userTransaction.UserID, userTransaction.Amount, userTransaction.DateTime)
 	if err != nil {
 		s.log.ErrorAt(err, transaction.RepositoryName, "Update")
 		return err
@@ -161,7 +445,8 @@ func (s *sqlTransactionRepository) FindByID(ctx context.Context, transactionID s
 	query := FindByID
 	row := s.db.QueryRowContext(ctx, query, transactionID)
 	err := row.Scan(&transactionEntity.ID, &transactionEntity.UserID,
-		&transactionEntity.Amount, &transactionEntity.DateTime, &transactionEntity.IsDeleted)
+		&transactionEntity.Amount, &transactionEntity.DateTime, &transactionEntity.IsDeleted,
+		&transactionEntity.MigrationID)
 	if err != nil {
 		if errors.Is(err, sql.ErrNoRows) {
 			return transactionEntity, errors.New(transaction.NotFoundError)
@@ -203,14 +488,142 @@ func (s *sqlTransactionRepository) FindByUserIDWithOptions(ctx context.Context,
 			s.log.ErrorAt(err, transaction.RepositoryName, "FindByUserIDWithOptions")
 			return nil, err
 		}
-		if !transactionEntity.IsDeleted {
-			transactions = append(transactions, transactionEntity)
-		}
+		transactions = append(transactions, transactionEntity)
 	}
 
 	return transactions, nil
 }
 
+func (s *sqlTransactionRepository) List(ctx context.Context, options transaction.ListOptions) ([]transaction.ListItem, error) {
+	query, args := listTransactionsQuery(options)
+	rows, err := s.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		s.log.ErrorAt(err, transaction.RepositoryName, "List")
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	items := make([]transaction.ListItem, 0)
+	for rows.Next() {
+		var item transaction.ListItem
+		var runningBalance float64
+		fields := []interface{}{&item.ID, &item.UserID, &item.Amount, &item.DateTime, &item.IsDeleted}
+		if options.IncludeRunningBalance {
+			fields = append(fields, &runningBalance)
+		}
+
+		if err = rows.Scan(fields...); err != nil {
+			s.log.ErrorAt(err, transaction.RepositoryName, "List")
+			return nil, err
+		}
+
+		if options.IncludeRunningBalance {
+			item.RunningBalance = &runningBalance
+		}
+
+		items = append(items, item)
+	}
+
+	if err = rows.Err(); err != nil {
+		s.log.ErrorAt(err, transaction.RepositoryName, "List")
+		return nil, err
+	}
+
+	return items, nil
+}
+
+func (s *sqlTransactionRepository) ListByMigration(ctx context.Context, migrationID, afterID string,
+	limit int) ([]transaction.ListItem, error) {
+	rows, err := s.db.QueryContext(ctx, ListByMigrationID, migrationID, afterID, limit)
+	if err != nil {
+		s.log.ErrorAt(err, transaction.RepositoryName, "ListByMigration")
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	items := make([]transaction.ListItem, 0)
+	for rows.Next() {
+		var item transaction.ListItem
+		if err = rows.Scan(&item.ID, &item.UserID, &item.Amount, &item.DateTime, &item.IsDeleted,
+			&item.MigrationID); err != nil {
+			s.log.ErrorAt(err, transaction.RepositoryName, "ListByMigration")
+			return nil, err
+		}
+
+		items = append(items, item)
+	}
+
+	if err = rows.Err(); err != nil {
+		s.log.ErrorAt(err, transaction.RepositoryName, "ListByMigration")
+		return nil, err
+	}
+
+	return items, nil
+}
+
+func (s *sqlTransactionRepository) SummarizeMigration(ctx context.Context, migrationID string,
+	largest int) (transaction.MigrationTotals, error) {
+	var totals transaction.MigrationTotals
+	err := s.db.QueryRowContext(ctx, SumMigrationAmounts, migrationID).Scan(&totals.Credited, &totals.Debited)
+	if err != nil {
+		s.log.ErrorAt(err, transaction.RepositoryName, "SummarizeMigration")
+		return totals, err
+	}
+
+	rows, err := s.db.QueryContext(ctx, FindLargestByMigrationID, migrationID, largest)
+	if err != nil {
+		s.log.ErrorAt(err, transaction.RepositoryName, "SummarizeMigration")
+		return totals, err
+	}
+	defer rows.Close()
+
+	totals.Largest = make([]transaction.Transaction, 0, largest)
+	for rows.Next() {
+		transactionEntity := transaction.Transaction{MigrationID: migrationID}
+		if err = rows.Scan(&transactionEntity.ID, &transactionEntity.UserID, &transactionEntity.Amount,
+			&transactionEntity.DateTime); err != nil {
+			s.log.ErrorAt(err, transaction.RepositoryName, "SummarizeMigration")
+			return totals, err
+		}
+
+		totals.Largest = append(totals.Largest, transactionEntity)
+	}
+
+	if err = rows.Err(); err != nil {
+		s.log.ErrorAt(err, transaction.RepositoryName, "SummarizeMigration")
+		return totals, err
+	}
+
+	return totals, nil
+}
+
+func (s *sqlTransactionRepository) ForEachMigrationUser(ctx context.Context, migrationID string,
+	handle func(delta transaction.UserDelta) error) error {
+	rows, err := s.db.QueryContext(ctx, FindUserDeltasByMigrationID, migrationID)
+	if err != nil {
+		s.log.ErrorAt(err, transaction.RepositoryName, "ForEachMigrationUser")
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var delta transaction.UserDelta
+		if err = rows.Scan(&delta.UserID, &delta.NetAmount, &delta.Credits, &delta.Debits, &delta.Balance,
+			&delta.TotalDebits, &delta.TotalCredits); err != nil {
+			s.log.ErrorAt(err, transaction.RepositoryName, "ForEachMigrationUser")
+			return err
+		}
+
+		if err = handle(delta); err != nil {
+			return err
+		}
+	}
+
+	return rows.Err()
+}
+
 func findByUserIDOptionalDateRangeQuery(fromDate, toDate string) string {
 	query := GetAllByUserID
 
@@ -221,6 +634,72 @@ func findByUserIDOptionalDateRangeQuery(fromDate, toDate string) string {
 	return query
 }
 
+// listTransactionsQuery builds a keyset paginated query over the user transactions, the running balance
+// is computed before any filter so it always reflects every previous transaction of the user
+func listTransactionsQuery(options transaction.ListOptions) (query string, args []interface{}) {
+	args = append(args, options.UserID)
+	addArg := func(value interface{}) string {
+		args = append(args, value)
+		return fmt.Sprintf("$%d", len(args))
+	}
+
+	var conditions []string
+	if options.FromDate != "" {
+		conditions = append(conditions, fmt.Sprintf("date_time >= CAST(%s AS timestamptz)", addArg(options.FromDate)))
+	}
+
+	if options.ToDate != "" {
+		conditions = append(conditions, fmt.Sprintf("date_time <= CAST(%s AS timestamptz)", addArg(options.ToDate)))
+	}
+
+	if options.MinAmount != nil {
+		conditions = append(conditions, fmt.Sprintf("amount >= CAST(%s AS NUMERIC)", addArg(*options.MinAmount)))
+	}
+
+	if options.MaxAmount != nil {
+		conditions = append(conditions, fmt.Sprintf("amount <= CAST(%s AS NUMERIC)", addArg(*options.MaxAmount)))
+	}
+
+	switch options.Type {
+	case transaction.TypeCredit:
+		conditions = append(conditions, "amount > 0")
+	case transaction.TypeDebit:
+		conditions = append(conditions, "amount < 0")
+	}
+
+	sortColumn := "date_time"
+	if options.SortBy == transaction.SortByAmount {
+		sortColumn = "amount"
+	}
+
+	comparator, direction := ">", "ASC"
+	if options.SortOrder == transaction.SortDesc {
+		comparator, direction = "<", "DESC"
+	}
+
+	if options.After != nil {
+		cursorValue := fmt.Sprintf("CAST(%s AS timestamptz)", addArg(options.After.DateTime))
+		if options.SortBy == transaction.SortByAmount {
+			cursorValue = fmt.Sprintf("CAST(%s AS NUMERIC)", addArg(options.After.Amount))
+		}
+		conditions = append(conditions, fmt.Sprintf("(%s, id) %s (%s, %s)",
+			sortColumn, comparator, cursorValue, addArg(options.After.ID)))
+	}
+
+	query = ListByUserID
+	if options.IncludeRunningBalance {
+		query = ListByUserIDWithRunningBalance
+	}
+
+	for _, condition := range conditions {
+		query += " AND " + condition
+	}
+
+	query += fmt.Sprintf(" ORDER BY %s %s, id %s LIMIT %s", sortColumn, direction, direction, addArg(options.Limit))
+
+	return query, args
+}
+
 func handleDuplicateError(err error) error {
 	var pqErr *pq.Error
 	if errors.As(err, &pqErr) {
@@ -242,10 +721,94 @@ func handleForeignKeyError(err error) error {
 }
 
 const (
-	SaveByUserID               = "INSERT INTO transactions (id, user_id, amount, date_time) VALUES ($1, $2, $3, $4)"
-	UpdateIsDeletedTransaction = "UPDATE transactions SET is_deleted = $2 WHERE id = $1"
-	UpdateTransaction          = "UPDATE transactions SET user_id = $2, amount = $3, date_time = $4 WHERE id = $1"
-	GetAllByUserID             = "SELECT * FROM transactions WHERE user_id = $1"
-	FindByID                   = "SELECT * FROM transactions WHERE id = $1"
-	FromToDateOption           = ` AND date_time >= CAST($2 AS timestamptz) AND date_time <= CAST($3 AS timestamptz)`
+	FindActiveUserIDs          = "SELECT id FROM users WHERE id = ANY(CAST($1 AS BIGINT[])) AND is_deleted = FALSE"
+	FindExistingTransactionIDs = "SELECT id FROM transactions WHERE id = ANY($1)"
+	FindMigrationUserIDs       = "SELECT DISTINCT CAST(user_id AS TEXT) FROM transactions WHERE migration_id = $1 ORDER BY 1"
+	SaveBatchSkippingRejected  = `
+	INSERT INTO transactions (id, user_id, amount, date_time, migration_id)
+	SELECT t.id, t.user_id, t.amount, t.date_time, t.migration_id
+	FROM unnest(CAST($1 AS TEXT[]), CAST($2 AS BIGINT[]), CAST($3 AS NUMERIC[]), CAST($4 AS TIMESTAMPTZ[]),
+		CAST($5 AS BIGINT[])) WITH ORDINALITY AS t(id, user_id, amount, date_time, migration_id, position)
+	JOIN users u ON u.id = t.user_id AND u.is_deleted = FALSE
+	ORDER BY t.position
+	ON CONFLICT (id) DO NOTHING
+	RETURNING id`
+	CreateTransactionStage = "SELECT nextval('transaction_stage_seq')"
+	StageTransactions      = `
+	INSERT INTO transaction_stages (stage_id, id, user_id, amount, date_time, migration_id)
+	SELECT $1, t.id, t.user_id, t.amount, t.date_time, t.migration_id
+	FROM unnest(CAST($2 AS TEXT[]), CAST($3 AS BIGINT[]), CAST($4 AS NUMERIC[]), CAST($5 AS TIMESTAMPTZ[]),
+		CAST($6 AS BIGINT[])) AS t(id, user_id, amount, date_time, migration_id)`
+	CommitTransactionStage = `
+	INSERT INTO transactions (id, user_id, amount, date_time, migration_id)
+	SELECT id, user_id, amount, date_time, migration_id FROM transaction_stages WHERE stage_id = $1`
+	DeleteTransactionStage = "DELETE FROM transaction_stages WHERE stage_id = $1"
+	SaveByUserID           = `
+	INSERT INTO transactions (id, user_id, amount, date_time, migration_id) VALUES ($1, $2, $3, $4, $5)`
+	// A transaction that already has the state is left as it was, so no change is recorded for it
+	UpdateIsDeletedTransaction = `
+	UPDATE transactions SET is_deleted = $2, edited_at = NOW() WHERE id = $1 AND is_deleted IS DISTINCT FROM $2`
+	UpdateTransaction = `
+	UPDATE transactions SET user_id = $2, amount = $3, date_time = $4, edited_at = NOW() WHERE id = $1`
+	GetAllByUserID = `
+	SELECT id, user_id, amount, date_time, is_deleted 
+	FROM transactions 
+	WHERE user_id = $1 AND is_deleted = FALSE`
+	FindByID = `
+	SELECT id, user_id, amount, date_time, is_deleted, COALESCE(CAST(migration_id AS TEXT), '')
+	FROM transactions
+	WHERE id = $1`
+	FromToDateOption = ` AND date_time >= CAST($2 AS timestamptz) AND date_time <= CAST($3 AS timestamptz)`
+	ListByUserID     = `
+	SELECT id, user_id, amount, date_time, is_deleted 
+	FROM transactions 
+	WHERE user_id = $1 AND is_deleted = FALSE`
+	ListByUserIDWithRunningBalance = `
+	SELECT id, user_id, amount, date_time, is_deleted, running_balance 
+	FROM (
+		SELECT id, user_id, amount, date_time, is_deleted,
+			SUM(amount) OVER (ORDER BY date_time, id) AS running_balance
+		FROM transactions 
+		WHERE user_id = $1 AND is_deleted = FALSE
+	) user_transactions 
+	WHERE TRUE`
+	ListByMigrationID = `
+	SELECT id, user_id, amount, date_time, is_deleted, CAST(migration_id AS TEXT)
+	FROM transactions
+	WHERE migration_id = $1 AND is_deleted = FALSE AND id > $2
+	ORDER BY id
+	LIMIT $3`
+
+	SumMigrationAmounts = `
+	SELECT COALESCE(SUM(amount) FILTER (WHERE amount > 0), 0),
+		COALESCE(-SUM(amount) FILTER (WHERE amount < 0), 0)
+	FROM transactions
+	WHERE migration_id = $1 AND is_deleted = FALSE`
+
+	FindLargestByMigrationID = `
+	SELECT id, user_id, amount, date_time
+	FROM transactions
+	WHERE migration_id = $1 AND is_deleted = FALSE
+	ORDER BY ABS(amount) DESC, id
+	LIMIT $2`
+
+	// The balance of each user adds up every transaction of the user, not only the ones of the migration
+	FindUserDeltasByMigrationID = `
+	SELECT m.user_id, m.net_amount, m.credits, m.debits, b.balance, b.total_debits, b.total_credits
+	FROM (
+		SELECT user_id, SUM(amount) AS net_amount,
+			COUNT(*) FILTER (WHERE amount > 0) AS credits,
+			COUNT(*) FILTER (WHERE amount < 0) AS debits
+		FROM transactions
+		WHERE migration_id = $1 AND is_deleted = FALSE
+		GROUP BY user_id
+	) m
+	CROSS JOIN LATERAL (
+		SELECT COALESCE(SUM(t.amount), 0) AS balance,
+			COUNT(*) FILTER (WHERE t.amount < 0) AS total_debits,
+			COUNT(*) FILTER (WHERE t.amount > 0) AS total_credits
+		FROM transactions t
+		WHERE t.user_id = m.user_id AND NOT t.is_deleted
+	) b
+	ORDER BY m.user_id`
 )
@@ -4,7 +4,13 @@ import (
 	"context"
 	"database/sql"
 	"errors"
+	"fmt"
+	"strings"
 
+	"github.com/lib/pq"
+	"github.com/sebastianreh/user-balance-api/internal/domain/balance"
+	"github.com/sebastianreh/user-balance-api/internal/domain/change"
+	"github.com/sebastianreh/user-balance-api/internal/domain/outbox"
 	"github.com/sebastianreh/user-balance-api/internal/domain/transaction"
 
 	"github.com/sebastianreh/user-balance-api/internal/domain/user"
@@ -25,13 +31,36 @@ func NewSQLUserRepository(log logger.Logger, db *sql.DB) user.Repository {
 
 func (s *sqlUserRepository) Save(ctx context.Context, userEntity user.User) (string, error) {
 	query := SaveUser
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		s.log.ErrorAt(err, user.RepositoryName, "Save")
+		return "", err
+	}
+	defer func() { _ = tx.Rollback() }()
+
 	var createdID string
-	err := s.db.QueryRowContext(ctx, query, userEntity.FirstName, userEntity.LastName, userEntity.Email).Scan(&createdID)
+	err = tx.QueryRowContext(ctx, query, userEntity.FirstName, userEntity.LastName, userEntity.Email).Scan(&createdID)
+	if err != nil {
+		s.log.ErrorAt(err, user.RepositoryName, "Save")
+		duplicateErr := handleDuplicateEmailError(err)
+		if duplicateErr != nil {
+			err = duplicateErr
+		}
+		return "", err
+	}
+
+	err = recordChanges(ctx, tx, RecordUserChanges, change.EntityUser, change.OperationInsert,
+		pq.Array([]string{createdID}))
 	if err != nil {
 		s.log.ErrorAt(err, user.RepositoryName, "Save")
 		return "", err
 	}
 
+	if err = tx.Commit(); err != nil {
+		s.log.ErrorAt(err, user.RepositoryName, "Save")
+		return "", err
+	}
+
 	return createdID, nil
 }
 
@@ -42,9 +71,14 @@ func (s *sqlUserRepository) Update(ctx context.Context, userEntity user.User) er
 		return err
 	}
 
-	_, err = s.db.ExecContext(ctx, query, userEntity.ID, userEntity.FirstName, userEntity.LastName, userEntity.Email)
+	err = execWithChange(ctx, s.db, change.EntityUser, userEntity.ID, change.OperationUpdate, nil, query,
+		userEntity.ID, userEntity.FirstName, userEntity.LastName, userEntity.Email)
 	if err != nil {
 		s.log.ErrorAt(err, user.RepositoryName, "Update")
+		duplicateErr := handleDuplicateEmailError(err)
+		if duplicateErr != nil {
+			err = duplicateErr
+		}
 		return err
 	}
 
@@ -72,14 +106,165 @@ func (s *sqlUserRepository) FindByID(ctx context.Context, userID string) (user.U
 	return userEntity, nil
 }
 
-func (s *sqlUserRepository) Delete(ctx context.Context, userID string) error {
+func (s *sqlUserRepository) FindByEmail(ctx context.Context, email string) (user.User, error) {
+	var userEntity user.User
+	query := FindUserByEmail
+	row := s.db.QueryRowContext(ctx, query, email)
+	err := row.Scan(&userEntity.ID, &userEntity.FirstName, &userEntity.LastName, &userEntity.Email, &userEntity.IsDeleted)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return userEntity, errors.New(user.NotFoundError)
+		}
+
+		s.log.ErrorAt(err, user.RepositoryName, "FindByEmail")
+		return userEntity, err
+	}
+
+	return userEntity, nil
+}
+
+func (s *sqlUserRepository) FindActiveIDs(ctx context.Context, userIDs []string) (map[string]bool, error) {
+	ids, err := queryIDSet(ctx, s.db, FindActiveUserIDs, pq.Array(userIDs))
+	if err != nil {
+		s.log.ErrorAt(err, user.RepositoryName, "FindActiveIDs")
+		return nil, err
+	}
+
+	return ids, nil
+}
+
+// CreateMissing inserts the users in one statement and moves the id sequence past the ids it took, in the same
+// database transaction so the users created through the API never get one of them. Only the created users are
+// recorded in the changes feed. An email taken by a user created meanwhile fails with DuplicateEmailError
+func (s *sqlUserRepository) CreateMissing(ctx context.Context, users []user.User) (int, map[string]bool, error) {
+	ids := make([]string, 0, len(users))
+	firstNames := make([]string, 0, len(users))
+	lastNames := make([]string, 0, len(users))
+	emails := make([]string, 0, len(users))
+	for _, userEntity := range users {
+		ids = append(ids, userEntity.ID)
+		firstNames = append(firstNames, userEntity.FirstName)
+		lastNames = append(lastNames, userEntity.LastName)
+		emails = append(emails, userEntity.Email)
+	}
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		s.log.ErrorAt(err, user.RepositoryName, "CreateMissing")
+		return 0, nil, err
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	createdIDs, emailTaken, err := createMissingUsers(ctx, tx, ids, firstNames, lastNames, emails)
+	if err != nil {
+		s.log.ErrorAt(err, user.RepositoryName, "CreateMissing")
+		if duplicateErr := handleDuplicateEmailError(err); duplicateErr != nil {
+			err = duplicateErr
+		}
+		return 0, nil, err
+	}
+
+	if len(createdIDs) > 0 {
+		if _, err = tx.ExecContext(ctx, AdvanceUserIDSequence); err != nil {
+			s.log.ErrorAt(err, user.RepositoryName, "CreateMissing")
+			return 0, nil, err
+		}
+
+		err = recordChanges(ctx, tx, RecordUserChanges, change.EntityUser, change.OperationInsert,
+			pq.Array(orderedIDs(ids, createdIDs)))
+		if err != nil {
+			s.log.ErrorAt(err, user.RepositoryName, "CreateMissing")
+			return 0, nil, err
+		}
+	}
+
+	if err = tx.Commit(); err != nil {
+		s.log.ErrorAt(err, user.RepositoryName, "CreateMissing")
+		return 0, nil, err
+	}
+
+	return len(createdIDs), emailTaken, nil
+}
+
+// createMissingUsers runs the insert and splits the ids it returns into the created users and the ones whose email
+// was taken
+func createMissingUsers(ctx context.Context, tx *sql.Tx, ids, firstNames, lastNames,
+	emails []string) (map[string]bool, map[string]bool, error) {
+	rows, err := tx.QueryContext(ctx, CreateMissingUsers, pq.Array(ids), pq.Array(firstNames), pq.Array(lastNames),
+		pq.Array(emails))
+	if err != nil {
+		return nil, nil, err
+	}
+	defer rows.Close()
+
+	createdIDs := make(map[string]bool)
+	emailTaken := make(map[string]bool)
+	for rows.Next() {
+		var userID string
+		var taken bool
+		if err = rows.Scan(&userID, &taken); err != nil {
+			return nil, nil, err
+		}
+
+		if taken {
+			emailTaken[userID] = true
+		} else {
+			createdIDs[userID] = true
+		}
+	}
+
+	return createdIDs, emailTaken, rows.Err()
+}
+
+func (s *sqlUserRepository) List(ctx context.Context, options user.ListOptions) ([]user.ListItem, error) {
+	query, args := listUsersQuery(options)
+	rows, err := s.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		s.log.ErrorAt(err, user.RepositoryName, "List")
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	items := make([]user.ListItem, 0)
+	for rows.Next() {
+		var item user.ListItem
+		fields := []interface{}{&item.ID, &item.FirstName, &item.LastName, &item.Email, &item.IsDeleted}
+		var userBalance balance.UserBalance
+		if options.IncludeBalance {
+			fields = append(fields, &userBalance.Balance, &userBalance.TotalDebits, &userBalance.TotalCredits)
+		}
+
+		if err = rows.Scan(fields...); err != nil {
+			s.log.ErrorAt(err, user.RepositoryName, "List")
+			return nil, err
+		}
+
+		if options.IncludeBalance {
+			userBalance.RoundBalanceToTwoDecimalPlaces()
+			item.Balance = &userBalance
+		}
+
+		items = append(items, item)
+	}
+
+	if err = rows.Err(); err != nil {
+		s.log.ErrorAt(err, user.RepositoryName, "List")
+		return nil, err
+	}
+
+	return items, nil
+}
+
+func (s *sqlUserRepository) Delete(ctx context.Context, userID string, messages ...outbox.Message) error {
 	err := s.ValidateDeletedUser(ctx, userID)
 	if err != nil {
 		return err
 	}
 
 	query := UpdateIsDeletedUser
-	_, err = s.db.ExecContext(ctx, query, userID, true)
+	err = execWithChange(ctx, s.db, change.EntityUser, userID, change.OperationSoftDelete, messages, query, userID,
+		true)
 	if err != nil {
 		s.log.ErrorAt(err, transaction.RepositoryName, "Update")
 		return err
@@ -108,8 +293,91 @@ func (s *sqlUserRepository) ValidateDeletedUser(ctx context.Context, userID stri
 	return nil
 }
 
+// listUsersQuery builds a keyset paginated query, the cursor is compared as a row against the sort columns
+func listUsersQuery(options user.ListOptions) (query string, args []interface{}) {
+	addArg := func(value interface{}) string {
+		args = append(args, value)
+		return fmt.Sprintf("$%d", len(args))
+	}
+
+	var conditions []string
+	switch options.Deleted {
+	case user.DeletedOnly:
+		conditions = append(conditions, "u.is_deleted = TRUE")
+	case user.DeletedAll:
+	default:
+		conditions = append(conditions, "u.is_deleted = FALSE")
+	}
+
+	if options.NamePrefix != "" {
+		prefix := addArg(escapeLikePattern(options.NamePrefix) + "%")
+		conditions = append(conditions, fmt.Sprintf("(u.first_name ILIKE %s OR u.last_name ILIKE %s)", prefix, prefix))
+	}
+
+	sortColumns := []string{"u.id"}
+	switch options.SortBy {
+	case user.SortByName:
+		sortColumns = []string{"u.first_name", "u.last_name", "u.id"}
+	case user.SortByEmail:
+		sortColumns = []string{"u.email", "u.id"}
+	}
+
+	comparator, direction := ">", "ASC"
+	if options.SortOrder == user.SortDesc {
+		comparator, direction = "<", "DESC"
+	}
+
+	if options.After != nil {
+		var cursorValues []string
+		switch options.SortBy {
+		case user.SortByName:
+			cursorValues = append(cursorValues, addArg(options.After.FirstName), addArg(options.After.LastName))
+		case user.SortByEmail:
+			cursorValues = append(cursorValues, addArg(options.After.Email))
+		}
+		cursorValues = append(cursorValues, fmt.Sprintf("CAST(%s AS BIGINT)", addArg(options.After.ID)))
+		conditions = append(conditions, fmt.Sprintf("(%s) %s (%s)",
+			strings.Join(sortColumns, ", "), comparator, strings.Join(cursorValues, ", ")))
+	}
+
+	query = ListUsers
+	if options.IncludeBalance {
+		query = ListUsersWithBalance
+	}
+
+	if len(conditions) > 0 {
+		query += " WHERE " + strings.Join(conditions, " AND ")
+	}
+
+	orderBy := make([]string, len(sortColumns))
+	for i, column := range sortColumns {
+		orderBy[i] = fmt.Sprintf("%s %s", column, direction)
+	}
+
+	query += fmt.Sprintf(" ORDER BY %s LIMIT %s", strings.Join(orderBy, ", "), addArg(options.Limit))
+
+	return query, args
+}
+
+func escapeLikePattern(value string) string {
+	return likePatternReplacer.Replace(value)
+}
+
+func handleDuplicateEmailError(err error) error {
+	var pqErr *pq.Error
+	if errors.As(err, &pqErr) {
+		if pqErr.Code == "23505" && pqErr.Constraint == usersEmailUniqueIndex {
+			return errors.New(user.DuplicateEmailError)
+		}
+	}
+	return nil
+}
+
+var likePatternReplacer = strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`)
+
 const (
-	SaveUser = `
+	usersEmailUniqueIndex = "idx_users_active_email_unique"
+	SaveUser              = `
 	INSERT INTO users (first_name, last_name, email) 
 	VALUES ($1, $2, $3) 
 	RETURNING id;`
@@ -119,6 +387,48 @@ const (
 		last_name = COALESCE(NULLIF($3, ''), last_name), 
 		email = COALESCE(NULLIF($4, ''), email) 
 	WHERE id = $1`
-	FindUserByID        = "SELECT id, first_name, last_name, email, is_deleted FROM users WHERE id = $1"
-	UpdateIsDeletedUser = "UPDATE users SET is_deleted = $2 WHERE id = $1"
+	FindUserByID    = "SELECT id, first_name, last_name, email, is_deleted FROM users WHERE id = $1"
+	FindUserByEmail = `
+	SELECT id, first_name, last_name, email, is_deleted 
+	FROM users 
+	WHERE LOWER(email) = LOWER($1) AND is_deleted = FALSE`
+	// Only the id may conflict, the users whose email belongs to an active user or to an earlier user of the list are
+	// left out and returned as taken
+	CreateMissingUsers = `
+	WITH missing AS (
+		SELECT u.id, u.first_name, u.last_name, u.email, u.position
+		FROM unnest(CAST($1 AS BIGINT[]), CAST($2 AS TEXT[]), CAST($3 AS TEXT[]), CAST($4 AS TEXT[]))
+			WITH ORDINALITY AS u(id, first_name, last_name, email, position)
+		WHERE NOT EXISTS (SELECT 1 FROM users existing WHERE existing.id = u.id)
+	), taken AS (
+		SELECT m.id FROM missing m
+		WHERE m.email <> '' AND (
+			EXISTS (SELECT 1 FROM users existing
+				WHERE LOWER(existing.email) = LOWER(m.email) AND existing.is_deleted = FALSE)
+			OR EXISTS (SELECT 1 FROM missing earlier
+				WHERE LOWER(earlier.email) = LOWER(m.email) AND earlier.position < m.position))
+	), created AS (
+		INSERT INTO users (id, first_name, last_name, email)
+		SELECT m.id, m.first_name, m.last_name, m.email FROM missing m
+		WHERE m.id NOT IN (SELECT id FROM taken)
+		ON CONFLICT (id) DO NOTHING
+		RETURNING id
+	)
+	SELECT CAST(id AS TEXT), FALSE FROM created
+	UNION ALL
+	SELECT CAST(id AS TEXT), TRUE FROM taken`
+	AdvanceUserIDSequence = `
+	SELECT setval('users_id_seq', GREATEST((SELECT MAX(id) FROM users), (SELECT last_value FROM users_id_seq)))`
+	UpdateIsDeletedUser  = "UPDATE users SET is_deleted = $2 WHERE id = $1 AND is_deleted IS DISTINCT FROM $2"
+	ListUsers            = "SELECT u.id, u.first_name, u.last_name, u.email, u.is_deleted FROM users u"
+	ListUsersWithBalance = `
+	SELECT u.id, u.first_name, u.last_name, u.email, u.is_deleted, b.balance, b.total_debits, b.total_credits
+	FROM users u
+	LEFT JOIN LATERAL (
+		SELECT COALESCE(SUM(t.amount), 0) AS balance,
+			COUNT(*) FILTER (WHERE t.amount < 0) AS total_debits,
+			COUNT(*) FILTER (WHERE t.amount > 0) AS total_credits
+		FROM transactions t
+		WHERE t.user_id = u.id AND t.is_deleted = FALSE
+	) b ON TRUE`
 )
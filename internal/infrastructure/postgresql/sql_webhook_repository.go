@@ -0,0 +1,140 @@
+package postgresql
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+
+	"github.com/lib/pq"
+	"github.com/sebastianreh/user-balance-api/internal/domain/webhook"
+	"github.com/sebastianreh/user-balance-api/pkg/logger"
+)
+
+type sqlWebhookRepository struct {
+	log logger.Logger
+	db  *sql.DB
+}
+
+func NewSQLWebhookRepository(log logger.Logger, db *sql.DB) webhook.Repository {
+	return &sqlWebhookRepository{
+		log: log,
+		db:  db,
+	}
+}
+
+func (s *sqlWebhookRepository) Save(ctx context.Context,
+	subscription webhook.Subscription) (webhook.Subscription, error) {
+	saved, err := scanWebhookSubscription(s.db.QueryRowContext(ctx, SaveWebhookSubscription, subscription.URL,
+		subscription.Description, pq.Array(subscription.Events), subscription.Active, subscription.Secret))
+	if err != nil {
+		s.log.ErrorAt(err, webhook.RepositoryName, "Save")
+		return subscription, err
+	}
+
+	return saved, nil
+}
+
+func (s *sqlWebhookRepository) Update(ctx context.Context,
+	subscription webhook.Subscription) (webhook.Subscription, error) {
+	updated, err := scanWebhookSubscription(s.db.QueryRowContext(ctx, UpdateWebhookSubscription, subscription.ID,
+		subscription.URL, subscription.Description, pq.Array(subscription.Events), subscription.Active))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return subscription, errors.New(webhook.NotFoundError)
+		}
+
+		s.log.ErrorAt(err, webhook.RepositoryName, "Update")
+		return subscription, err
+	}
+
+	return updated, nil
+}
+
+func (s *sqlWebhookRepository) FindByID(ctx context.Context, subscriptionID string) (webhook.Subscription, error) {
+	subscription, err := scanWebhookSubscription(s.db.QueryRowContext(ctx, FindWebhookSubscriptionByID, subscriptionID))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return subscription, errors.New(webhook.NotFoundError)
+		}
+
+		s.log.ErrorAt(err, webhook.RepositoryName, "FindByID")
+		return subscription, err
+	}
+
+	return subscription, nil
+}
+
+func (s *sqlWebhookRepository) List(ctx context.Context) ([]webhook.Subscription, error) {
+	return s.query(ctx, "List", ListWebhookSubscriptions)
+}
+
+func (s *sqlWebhookRepository) FindByEvent(ctx context.Context, event string) ([]webhook.Subscription, error) {
+	return s.query(ctx, "FindByEvent", FindWebhookSubscriptionsByEvent, event)
+}
+
+func (s *sqlWebhookRepository) Delete(ctx context.Context, subscriptionID string) error {
+	result, err := s.db.ExecContext(ctx, DeleteWebhookSubscription, subscriptionID)
+	if err != nil {
+		s.log.ErrorAt(err, webhook.RepositoryName, "Delete")
+		return err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rowsAffected == 0 {
+		return errors.New(webhook.NotFoundError)
+	}
+
+	return nil
+}
+
+func (s *sqlWebhookRepository) query(ctx context.Context, origin, query string,
+	args ...any) ([]webhook.Subscription, error) {
+	subscriptions := make([]webhook.Subscription, 0)
+	rows, err := s.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		s.log.ErrorAt(err, webhook.RepositoryName, origin)
+		return subscriptions, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		subscription, err := scanWebhookSubscription(rows)
+		if err != nil {
+			s.log.ErrorAt(err, webhook.RepositoryName, origin)
+			return subscriptions, err
+		}
+
+		subscriptions = append(subscriptions, subscription)
+	}
+
+	return subscriptions, rows.Err()
+}
+
+func scanWebhookSubscription(row rowScanner) (webhook.Subscription, error) {
+	var subscription webhook.Subscription
+	err := row.Scan(&subscription.ID, &subscription.URL, &subscription.Description,
+		pq.Array(&subscription.Events), &subscription.Active, &subscription.Secret, &subscription.CreatedAt,
+		&subscription.UpdatedAt)
+	return subscription, err
+}
+
+const (
+	webhookSubscriptionColumns = "id, url, description, events, active, secret, created_at, updated_at"
+	SaveWebhookSubscription    = `
+	INSERT INTO webhook_subscriptions (url, description, events, active, secret)
+	VALUES ($1, $2, $3, $4, $5)
+	RETURNING ` + webhookSubscriptionColumns
+	UpdateWebhookSubscription = `
+	UPDATE webhook_subscriptions SET url = $2, description = $3, events = $4, active = $5, updated_at = NOW()
+	WHERE id = $1
+	RETURNING ` + webhookSubscriptionColumns
+	FindWebhookSubscriptionByID     = "SELECT " + webhookSubscriptionColumns + " FROM webhook_subscriptions WHERE id = $1"
+	ListWebhookSubscriptions        = "SELECT " + webhookSubscriptionColumns + " FROM webhook_subscriptions ORDER BY id"
+	FindWebhookSubscriptionsByEvent = "SELECT " + webhookSubscriptionColumns + `
+	FROM webhook_subscriptions WHERE active AND $1 = ANY(events) ORDER BY id`
+	DeleteWebhookSubscription = "DELETE FROM webhook_subscriptions WHERE id = $1"
+)
@@ -0,0 +1,298 @@
+package http
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/labstack/echo/v4"
+	"github.com/sebastianreh/user-balance-api/cmd/httpserver/exceptions"
+	"github.com/sebastianreh/user-balance-api/internal/app/services"
+	"github.com/sebastianreh/user-balance-api/internal/domain/alert"
+	"github.com/sebastianreh/user-balance-api/internal/domain/user"
+	"github.com/sebastianreh/user-balance-api/pkg/logger"
+	customStr "github.com/sebastianreh/user-balance-api/pkg/strings"
+)
+
+const (
+	alertHandlerName = "AlertHandler"
+)
+
+type AlertHandler struct {
+	log     logger.Logger
+	service services.AlertService
+}
+
+func NewAlertHandler(log logger.Logger, service services.AlertService) *AlertHandler {
+	return &AlertHandler{
+		log:     log,
+		service: service,
+	}
+}
+
+// CreateAlertRule godoc
+// @Summary Create an alert rule
+// @Description Creates a rule that emails the recipients when the balance of the user drops below the threshold
+// @Description (balance_below) or a transaction debits more than it (debit_above). Without recipients the alert is
+// @Description sent to the email of the user
+// @Tags Alerts
+// @Accept json
+// @Produce json
+// @Param user_id path string true "User ID"
+// @Param rule body alert.Rule true "Alert rule, active defaults to true"
+// @Success 201 {object} alert.Rule "Created rule"
+// @Failure 400 {object} exceptions.BadRequestException "Invalid rule or no recipients"
+// @Failure 404 {object} exceptions.NotFoundException "User not found"
+// @Failure 500 {object} exceptions.InternalServerException "Internal server error"
+// @Router /users/{user_id}/alert-rules [post]
+func (h *AlertHandler) CreateAlertRule(ctx echo.Context) error {
+	rule, err := validateAlertRuleRequest(ctx)
+	if err != nil {
+		h.log.ErrorAt(err, alertHandlerName, "CreateAlertRule")
+		exception := exceptions.NewBadRequestException(err.Error())
+		return ctx.JSON(exception.Code(), exception)
+	}
+
+	rule, err = h.service.CreateRule(ctx.Request().Context(), rule)
+	if err != nil {
+		return alertErrorResponse(ctx, err)
+	}
+
+	return ctx.JSON(http.StatusCreated, rule)
+}
+
+// ListAlertRules godoc
+// @Summary List alert rules
+// @Description Lists the alert rules of a user
+// @Tags Alerts
+// @Produce json
+// @Param user_id path string true "User ID"
+// @Success 200 {array} alert.Rule "Alert rules"
+// @Failure 400 {object} exceptions.BadRequestException "Missing user ID"
+// @Failure 404 {object} exceptions.NotFoundException "User not found"
+// @Failure 500 {object} exceptions.InternalServerException "Internal server error"
+// @Router /users/{user_id}/alert-rules [get]
+func (h *AlertHandler) ListAlertRules(ctx echo.Context) error {
+	userID, err := validateUserIDParam(ctx)
+	if err != nil {
+		h.log.ErrorAt(err, alertHandlerName, "ListAlertRules")
+		exception := exceptions.NewBadRequestException(err.Error())
+		return ctx.JSON(exception.Code(), exception)
+	}
+
+	rules, err := h.service.ListRules(ctx.Request().Context(), userID)
+	if err != nil {
+		return alertErrorResponse(ctx, err)
+	}
+
+	return ctx.JSON(http.StatusOK, rules)
+}
+
+// GetAlertRule godoc
+// @Summary Get an alert rule
+// @Description Returns an alert rule of a user
+// @Tags Alerts
+// @Produce json
+// @Param user_id path string true "User ID"
+// @Param rule_id path string true "Alert rule ID"
+// @Success 200 {object} alert.Rule "Alert rule"
+// @Failure 400 {object} exceptions.BadRequestException "Invalid user or rule ID"
+// @Failure 404 {object} exceptions.NotFoundException "Alert rule not found"
+// @Failure 500 {object} exceptions.InternalServerException "Internal server error"
+// @Router /users/{user_id}/alert-rules/{rule_id} [get]
+func (h *AlertHandler) GetAlertRule(ctx echo.Context) error {
+	userID, ruleID, err := validateAlertRuleIDRequest(ctx)
+	if err != nil {
+		h.log.ErrorAt(err, alertHandlerName, "GetAlertRule")
+		exception := exceptions.NewBadRequestException(err.Error())
+		return ctx.JSON(exception.Code(), exception)
+	}
+
+	rule, err := h.service.GetRule(ctx.Request().Context(), userID, ruleID)
+	if err != nil {
+		return alertErrorResponse(ctx, err)
+	}
+
+	return ctx.JSON(http.StatusOK, rule)
+}
+
+// UpdateAlertRule godoc
+// @Summary Update an alert rule
+// @Description Replaces the type, threshold, recipients and active flag of a rule. The rule can fire again right away
+// @Description since its condition is cleared
+// @Tags Alerts
+// @Accept json
+// @Produce json
+// @Param user_id path string true "User ID"
+// @Param rule_id path string true "Alert rule ID"
+// @Param rule body alert.Rule true "Alert rule, active defaults to true"
+// @Success 200 {object} alert.Rule "Updated rule"
+// @Failure 400 {object} exceptions.BadRequestException "Invalid user or rule ID, invalid rule or no recipients"
+// @Failure 404 {object} exceptions.NotFoundException "User or alert rule not found"
+// @Failure 500 {object} exceptions.InternalServerException "Internal server error"
+// @Router /users/{user_id}/alert-rules/{rule_id} [put]
+func (h *AlertHandler) UpdateAlertRule(ctx echo.Context) error {
+	_, ruleID, err := validateAlertRuleIDRequest(ctx)
+	if err != nil {
+		h.log.ErrorAt(err, alertHandlerName, "UpdateAlertRule")
+		exception := exceptions.NewBadRequestException(err.Error())
+		return ctx.JSON(exception.Code(), exception)
+	}
+
+	rule, err := validateAlertRuleRequest(ctx)
+	if err != nil {
+		h.log.ErrorAt(err, alertHandlerName, "UpdateAlertRule")
+		exception := exceptions.NewBadRequestException(err.Error())
+		return ctx.JSON(exception.Code(), exception)
+	}
+
+	rule.ID = ruleID
+	rule, err = h.service.UpdateRule(ctx.Request().Context(), rule)
+	if err != nil {
+		return alertErrorResponse(ctx, err)
+	}
+
+	return ctx.JSON(http.StatusOK, rule)
+}
+
+// DeleteAlertRule godoc
+// @Summary Delete an alert rule
+// @Description Deletes an alert rule, the alerts it fired are kept in the history of the user
+// @Tags Alerts
+// @Param user_id path string true "User ID"
+// @Param rule_id path string true "Alert rule ID"
+// @Success 200 "No Content"
+// @Failure 400 {object} exceptions.BadRequestException "Invalid user or rule ID"
+// @Failure 404 {object} exceptions.NotFoundException "Alert rule not found"
+// @Failure 500 {object} exceptions.InternalServerException "Internal server error"
+// @Router /users/{user_id}/alert-rules/{rule_id} [delete]
+func (h *AlertHandler) DeleteAlertRule(ctx echo.Context) error {
+	userID, ruleID, err := validateAlertRuleIDRequest(ctx)
+	if err != nil {
+		h.log.ErrorAt(err, alertHandlerName, "DeleteAlertRule")
+		exception := exceptions.NewBadRequestException(err.Error())
+		return ctx.JSON(exception.Code(), exception)
+	}
+
+	if err = h.service.DeleteRule(ctx.Request().Context(), userID, ruleID); err != nil {
+		return alertErrorResponse(ctx, err)
+	}
+
+	return ctx.NoContent(http.StatusOK)
+}
+
+// ListAlerts godoc
+// @Summary List fired alerts
+// @Description Lists the alerts fired for a user from the newest with keyset pagination, including the ones of
+// @Description deleted rules
+// @Tags Alerts
+// @Produce json
+// @Param user_id path string true "User ID"
+// @Param limit query int false "Page size, from 1 to 500, defaults to 50"
+// @Param cursor query string false "Cursor returned by the previous page"
+// @Success 200 {object} alert.ListPage "Alerts page"
+// @Failure 400 {object} exceptions.BadRequestException "Invalid query params or cursor"
+// @Failure 404 {object} exceptions.NotFoundException "User not found"
+// @Failure 500 {object} exceptions.InternalServerException "Internal server error"
+// @Router /users/{user_id}/alerts [get]
+func (h *AlertHandler) ListAlerts(ctx echo.Context) error {
+	options, err := validateListAlertsRequest(ctx)
+	if err != nil {
+		h.log.ErrorAt(err, alertHandlerName, "ListAlerts")
+		exception := exceptions.NewBadRequestException(err.Error())
+		return ctx.JSON(exception.Code(), exception)
+	}
+
+	page, err := h.service.ListAlerts(ctx.Request().Context(), options)
+	if err != nil {
+		return alertErrorResponse(ctx, err)
+	}
+
+	return ctx.JSON(http.StatusOK, page)
+}
+
+// alertErrorResponse maps the errors of the alert service, a missing user or rule is not found and a rule without
+// anyone to send it to is a bad request
+func alertErrorResponse(ctx echo.Context, err error) error {
+	if strings.Contains(err.Error(), user.NotFoundError) || strings.Contains(err.Error(), alert.RuleNotFoundError) {
+		exception := exceptions.NewNotFoundException(err.Error())
+		return ctx.JSON(exception.Code(), exception)
+	}
+
+	if strings.Contains(err.Error(), alert.MissingRecipientsError) {
+		exception := exceptions.NewBadRequestException(err.Error())
+		return ctx.JSON(exception.Code(), exception)
+	}
+
+	exception := exceptions.NewInternalServerException(err.Error())
+	return ctx.JSON(exception.Code(), exception)
+}
+
+func validateAlertRuleRequest(ctx echo.Context) (alert.Rule, error) {
+	rule := alert.Rule{Active: true}
+	if err := ctx.Bind(&rule); err != nil {
+		return rule, errors.New("invalid request body")
+	}
+
+	userID, err := validateUserIDParam(ctx)
+	if err != nil {
+		return rule, err
+	}
+
+	// The server sets these, a request can't choose them
+	rule.ID, rule.UserID, rule.TriggeredAt = "", userID, nil
+	for i, recipient := range rule.Recipients {
+		rule.Recipients[i] = strings.TrimSpace(recipient)
+	}
+
+	if err = rule.Validate(); err != nil {
+		return rule, err
+	}
+
+	return rule, nil
+}
+
+func validateAlertRuleIDRequest(ctx echo.Context) (userID, ruleID string, err error) {
+	if userID, err = validateUserIDParam(ctx); err != nil {
+		return userID, ruleID, err
+	}
+
+	ruleID, err = validateNumericParam(ctx, "rule_id")
+	return userID, ruleID, err
+}
+
+func validateListAlertsRequest(ctx echo.Context) (alert.ListOptions, error) {
+	options := alert.ListOptions{UserID: ctx.Param("user_id"), Limit: alert.DefaultListLimit}
+	if customStr.IsEmpty(options.UserID) {
+		return options, errors.New("missing param user_id")
+	}
+
+	if limitParam := ctx.QueryParam("limit"); !customStr.IsEmpty(limitParam) {
+		limit, err := strconv.Atoi(limitParam)
+		if err != nil || limit < 1 || limit > alert.MaxListLimit {
+			return options, fmt.Errorf("limit must be a number between 1 and %d", alert.MaxListLimit)
+		}
+		options.Limit = limit
+	}
+
+	if cursorParam := ctx.QueryParam("cursor"); !customStr.IsEmpty(cursorParam) {
+		cursor, err := alert.DecodeCursor(cursorParam)
+		if err != nil {
+			return options, err
+		}
+		options.After = &cursor
+	}
+
+	return options, nil
+}
+
+func validateUserIDParam(ctx echo.Context) (string, error) {
+	userID := ctx.Param("user_id")
+	if customStr.IsEmpty(userID) {
+		return userID, errors.New("missing param user_id")
+	}
+
+	return userID, nil
+}
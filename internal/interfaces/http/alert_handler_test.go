@@ -0,0 +1,191 @@
+package http_test
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"testing"
+
+	"github.com/sebastianreh/user-balance-api/cmd/httpserver"
+	"github.com/sebastianreh/user-balance-api/internal/domain/alert"
+	"github.com/sebastianreh/user-balance-api/internal/domain/user"
+	localHttp "github.com/sebastianreh/user-balance-api/internal/interfaces/http"
+	"github.com/sebastianreh/user-balance-api/pkg/logger"
+	"github.com/sebastianreh/user-balance-api/test/mocks"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func TestAlertHandler_CreateAlertRule(t *testing.T) {
+	log := logger.NewLogger()
+
+	t.Run("it creates an active rule for the user of the path", func(t *testing.T) {
+		serviceMock := mocks.NewAlertServiceMock()
+		body := `{"id":"9","user_id":"2","type":"balance_below","threshold":100,"recipients":[" ops@example.com "]}`
+		expected := alert.Rule{UserID: "1", Type: alert.TypeBalanceBelow, Threshold: 100,
+			Recipients: []string{"ops@example.com"}, Active: true}
+		created := expected
+		created.ID = "3"
+
+		context, rec := httpserver.SetupAsRecorderWithIDField(http.MethodPost, "/users", "1", body, "user_id")
+		serviceMock.On("CreateRule", mock.Anything, expected).Return(created, nil)
+
+		handler := localHttp.NewAlertHandler(log, serviceMock)
+		err := handler.CreateAlertRule(context)
+
+		var response alert.Rule
+		_ = json.Unmarshal(rec.Body.Bytes(), &response)
+
+		assert.Nil(t, err)
+		assert.Equal(t, http.StatusCreated, rec.Code)
+		assert.Equal(t, created, response)
+	})
+
+	t.Run("it returns bad request for an unknown type", func(t *testing.T) {
+		serviceMock := mocks.NewAlertServiceMock()
+		body := `{"type":"credit_above","threshold":100}`
+
+		context, rec := httpserver.SetupAsRecorderWithIDField(http.MethodPost, "/users", "1", body, "user_id")
+
+		handler := localHttp.NewAlertHandler(log, serviceMock)
+		err := handler.CreateAlertRule(context)
+
+		assert.Nil(t, err)
+		assert.Equal(t, http.StatusBadRequest, rec.Code)
+		serviceMock.AssertNotCalled(t, "CreateRule", mock.Anything, mock.Anything)
+	})
+
+	t.Run("it returns bad request when nobody would get the alert", func(t *testing.T) {
+		serviceMock := mocks.NewAlertServiceMock()
+		body := `{"type":"debit_above","threshold":1000}`
+
+		context, rec := httpserver.SetupAsRecorderWithIDField(http.MethodPost, "/users", "1", body, "user_id")
+		serviceMock.On("CreateRule", mock.Anything, mock.Anything).
+			Return(alert.Rule{}, errors.New(alert.MissingRecipientsError))
+
+		handler := localHttp.NewAlertHandler(log, serviceMock)
+		err := handler.CreateAlertRule(context)
+
+		assert.Nil(t, err)
+		assert.Equal(t, http.StatusBadRequest, rec.Code)
+	})
+
+	t.Run("it returns not found when the user doesn't exist", func(t *testing.T) {
+		serviceMock := mocks.NewAlertServiceMock()
+		body := `{"type":"debit_above","threshold":1000}`
+
+		context, rec := httpserver.SetupAsRecorderWithIDField(http.MethodPost, "/users", "1", body, "user_id")
+		serviceMock.On("CreateRule", mock.Anything, mock.Anything).Return(alert.Rule{}, errors.New(user.NotFoundError))
+
+		handler := localHttp.NewAlertHandler(log, serviceMock)
+		err := handler.CreateAlertRule(context)
+
+		assert.Nil(t, err)
+		assert.Equal(t, http.StatusNotFound, rec.Code)
+	})
+}
+
+func TestAlertHandler_Rule(t *testing.T) {
+	log := logger.NewLogger()
+
+	t.Run("it updates the rule of the path", func(t *testing.T) {
+		serviceMock := mocks.NewAlertServiceMock()
+		body := `{"type":"debit_above","threshold":500,"active":false}`
+		expected := alert.Rule{ID: "3", UserID: "1", Type: alert.TypeDebitAbove, Threshold: 500}
+
+		context, rec := httpserver.SetupAsRecorder(http.MethodPut, "/users/1/alert-rules/3", "", body)
+		context.SetParamNames("user_id", "rule_id")
+		context.SetParamValues("1", "3")
+		serviceMock.On("UpdateRule", mock.Anything, expected).Return(expected, nil)
+
+		handler := localHttp.NewAlertHandler(log, serviceMock)
+		err := handler.UpdateAlertRule(context)
+
+		assert.Nil(t, err)
+		assert.Equal(t, http.StatusOK, rec.Code)
+		serviceMock.AssertExpectations(t)
+	})
+
+	t.Run("it returns not found when the rule is not of the user", func(t *testing.T) {
+		serviceMock := mocks.NewAlertServiceMock()
+
+		context, rec := httpserver.SetupAsRecorder(http.MethodGet, "/users/1/alert-rules/3", "", "")
+		context.SetParamNames("user_id", "rule_id")
+		context.SetParamValues("1", "3")
+		serviceMock.On("GetRule", mock.Anything, "1", "3").Return(alert.Rule{}, errors.New(alert.RuleNotFoundError))
+
+		handler := localHttp.NewAlertHandler(log, serviceMock)
+		err := handler.GetAlertRule(context)
+
+		assert.Nil(t, err)
+		assert.Equal(t, http.StatusNotFound, rec.Code)
+	})
+
+	t.Run("it returns bad request for a non numeric rule id", func(t *testing.T) {
+		serviceMock := mocks.NewAlertServiceMock()
+
+		context, rec := httpserver.SetupAsRecorder(http.MethodDelete, "/users/1/alert-rules/abc", "", "")
+		context.SetParamNames("user_id", "rule_id")
+		context.SetParamValues("1", "abc")
+
+		handler := localHttp.NewAlertHandler(log, serviceMock)
+		err := handler.DeleteAlertRule(context)
+
+		assert.Nil(t, err)
+		assert.Equal(t, http.StatusBadRequest, rec.Code)
+		serviceMock.AssertNotCalled(t, "DeleteRule", mock.Anything, mock.Anything, mock.Anything)
+	})
+
+	t.Run("it deletes the rule", func(t *testing.T) {
+		serviceMock := mocks.NewAlertServiceMock()
+
+		context, rec := httpserver.SetupAsRecorder(http.MethodDelete, "/users/1/alert-rules/3", "", "")
+		context.SetParamNames("user_id", "rule_id")
+		context.SetParamValues("1", "3")
+		serviceMock.On("DeleteRule", mock.Anything, "1", "3").Return(nil)
+
+		handler := localHttp.NewAlertHandler(log, serviceMock)
+		err := handler.DeleteAlertRule(context)
+
+		assert.Nil(t, err)
+		assert.Equal(t, http.StatusOK, rec.Code)
+	})
+}
+
+func TestAlertHandler_ListAlerts(t *testing.T) {
+	log := logger.NewLogger()
+
+	t.Run("it lists a page of alerts after the cursor", func(t *testing.T) {
+		serviceMock := mocks.NewAlertServiceMock()
+		cursor := alert.Cursor{ID: "7"}
+		page := alert.ListPage{Alerts: []alert.Alert{{ID: "4", RuleID: "3", UserID: "1"}}}
+
+		context, rec := httpserver.SetupAsRecorderWithIDField(http.MethodGet,
+			"/users?limit=1&cursor="+cursor.Encode(), "1", "", "user_id")
+		serviceMock.On("ListAlerts", mock.Anything, alert.ListOptions{UserID: "1", Limit: 1, After: &cursor}).
+			Return(page, nil)
+
+		handler := localHttp.NewAlertHandler(log, serviceMock)
+		err := handler.ListAlerts(context)
+
+		var response alert.ListPage
+		_ = json.Unmarshal(rec.Body.Bytes(), &response)
+
+		assert.Nil(t, err)
+		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.Equal(t, page, response)
+	})
+
+	t.Run("it returns bad request for an invalid cursor", func(t *testing.T) {
+		serviceMock := mocks.NewAlertServiceMock()
+
+		context, rec := httpserver.SetupAsRecorderWithIDField(http.MethodGet, "/users?cursor=abc", "1", "", "user_id")
+
+		handler := localHttp.NewAlertHandler(log, serviceMock)
+		err := handler.ListAlerts(context)
+
+		assert.Nil(t, err)
+		assert.Equal(t, http.StatusBadRequest, rec.Code)
+		serviceMock.AssertNotCalled(t, "ListAlerts", mock.Anything, mock.Anything)
+	})
+}
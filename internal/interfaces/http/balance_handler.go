@@ -4,11 +4,13 @@ import (
 	"errors"
 	"fmt"
 	"net/http"
+	"strings"
 	"time"
 
 	"github.com/labstack/echo/v4"
 	"github.com/sebastianreh/user-balance-api/cmd/httpserver/exceptions"
 	"github.com/sebastianreh/user-balance-api/internal/app/services"
+	"github.com/sebastianreh/user-balance-api/internal/domain/balance"
 	"github.com/sebastianreh/user-balance-api/pkg/logger"
 	customStr "github.com/sebastianreh/user-balance-api/pkg/strings"
 )
@@ -96,6 +98,36 @@ func (h *BalanceHandler) HandleGetUserBalanceWithoutOptions(ctx echo.Context) er
 	return ctx.JSON(http.StatusOK, balance)
 }
 
+// QueryBalances godoc
+// @Summary Get the balance of many users
+// @Description Get the balance of every requested user in one call.
+// Users that don't exist are reported in "errors" instead of failing the whole request.
+// "as_of" computes the balance up to that date and can't be combined with "to".
+// @Tags balances
+// @Accept json
+// @Produce json
+// @Param query body balance.BatchQuery true "User IDs and optional dates in ISO8601 format"
+// @Success 200 {object} balance.BatchResult
+// @Failure 400 {object} exceptions.BadRequestException
+// @Failure 500 {object} exceptions.InternalServerException
+// @Router /balances/query [post]
+func (h *BalanceHandler) QueryBalances(ctx echo.Context) error {
+	query, err := validateBatchBalanceRequest(ctx)
+	if err != nil {
+		exception := exceptions.NewBadRequestException(err.Error())
+		h.log.ErrorAt(exception, balanceHandlerName, "QueryBalances")
+		return ctx.JSON(exception.Code(), exception)
+	}
+
+	result, err := h.service.GetBalancesByUserIDs(ctx.Request().Context(), query)
+	if err != nil {
+		exception := exceptions.NewInternalServerException(err.Error())
+		return ctx.JSON(exception.Code(), exception)
+	}
+
+	return ctx.JSON(http.StatusOK, result)
+}
+
 func isWithOptionsRequest(ctx echo.Context) bool {
 	fromDate := ctx.QueryParam("from")
 	ToDate := ctx.QueryParam("to")
@@ -129,20 +161,14 @@ func validateBalanceWithOptionsRequest(ctx echo.Context) (id, fromDate, toDate s
 }
 
 func validateDates(fromDate, toDate string) error {
-	fromTime, err := time.Parse(TimeLayoutUTC, fromDate)
+	fromTime, err := parseRequestDate(fromDate)
 	if err != nil {
-		fromTime, err = time.Parse(TimeLayoutWithOffset, fromDate)
-		if err != nil {
-			return fmt.Errorf("invalid fromDate format: %v", err)
-		}
+		return fmt.Errorf("invalid fromDate format: %v", err)
 	}
 
-	toTime, err := time.Parse(TimeLayoutUTC, toDate)
+	toTime, err := parseRequestDate(toDate)
 	if err != nil {
-		toTime, err = time.Parse(TimeLayoutWithOffset, toDate)
-		if err != nil {
-			return fmt.Errorf("invalid toDate format: %v", err)
-		}
+		return fmt.Errorf("invalid toDate format: %v", err)
 	}
 
 	if fromTime.After(toTime) {
@@ -152,6 +178,62 @@ func validateDates(fromDate, toDate string) error {
 	return nil
 }
 
+// parseRequestDate accepts both UTC and offset dates, see TimeLayoutUTC and TimeLayoutWithOffset
+func parseRequestDate(date string) (time.Time, error) {
+	parsedTime, err := time.Parse(TimeLayoutUTC, date)
+	if err != nil {
+		return time.Parse(TimeLayoutWithOffset, date)
+	}
+
+	return parsedTime, nil
+}
+
+func validateBatchBalanceRequest(ctx echo.Context) (balance.BatchQuery, error) {
+	var query balance.BatchQuery
+	if err := ctx.Bind(&query); err != nil {
+		return query, errors.New("invalid request body")
+	}
+
+	if len(query.UserIDs) == 0 {
+		return query, errors.New("user_ids is required")
+	}
+
+	// Repeated ids are queried once, the response is keyed by id anyway
+	uniqueIDs := make(map[string]bool)
+	userIDs := make([]string, 0, len(query.UserIDs))
+	for _, userID := range query.UserIDs {
+		userID = strings.TrimSpace(userID)
+		if customStr.IsEmpty(userID) {
+			return query, errors.New("user_ids cannot contain empty values")
+		}
+
+		if !uniqueIDs[userID] {
+			uniqueIDs[userID] = true
+			userIDs = append(userIDs, userID)
+		}
+	}
+
+	if len(userIDs) > balance.MaxBatchUserIDs {
+		return query, fmt.Errorf("user_ids cannot have more than %d values", balance.MaxBatchUserIDs)
+	}
+	query.UserIDs = userIDs
+
+	if !customStr.IsEmpty(query.AsOf) && !customStr.IsEmpty(query.To) {
+		return query, errors.New("as_of and to cannot be used together")
+	}
+
+	toDate := query.To
+	if !customStr.IsEmpty(query.AsOf) {
+		toDate = query.AsOf
+	}
+
+	if err := validateOptionalDates(query.From, toDate); err != nil {
+		return query, err
+	}
+
+	return query, nil
+}
+
 func validateUserBalanceRequest(ctx echo.Context) (string, error) {
 	id := ctx.Param("user_id")
 
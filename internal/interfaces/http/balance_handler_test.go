@@ -4,6 +4,7 @@ import (
 	"encoding/json"
 	"errors"
 	"net/http"
+	"strconv"
 	"testing"
 
 	"github.com/sebastianreh/user-balance-api/cmd/httpserver"
@@ -209,3 +210,84 @@ func TestBalanceHandler_GetUserBalanceWithOptions(t *testing.T) {
 		assert.Equal(t, http.StatusInternalServerError, rec.Code)
 	})
 }
+
+func TestBalanceHandler_QueryBalances(t *testing.T) {
+	log := logger.NewLogger()
+
+	t.Run("it gets the balances of many users successfully", func(t *testing.T) {
+		serviceMock := mocks.NewBalanceServiceMock()
+		expectedQuery := balance.BatchQuery{UserIDs: []string{"1", "2"}, AsOf: "2024-06-30T00:00:00Z"}
+		expectedResult := balance.BatchResult{
+			Balances: map[string]balance.UserBalance{"1": {Balance: 100, TotalCredits: 1}},
+			Errors:   map[string]string{"2": services.UserNotFound},
+		}
+
+		body := `{"user_ids": ["1", "2", "1"], "as_of": "2024-06-30T00:00:00Z"}`
+		context, rec := httpserver.SetupAsRecorder(http.MethodPost, "/balances/query", "", body)
+		serviceMock.On("GetBalancesByUserIDs", mock.Anything, expectedQuery).Return(expectedResult, nil)
+
+		handler := localHttp.NewBalanceHandler(log, serviceMock)
+		err := handler.QueryBalances(context)
+
+		var response balance.BatchResult
+		_ = json.Unmarshal(rec.Body.Bytes(), &response)
+
+		assert.Nil(t, err)
+		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.Equal(t, expectedResult, response)
+	})
+
+	t.Run("it returns bad request for invalid bodies", func(t *testing.T) {
+		invalidBodies := []string{
+			`invalid body`,
+			`{"user_ids": []}`,
+			`{"user_ids": ["1", ""]}`,
+			`{"user_ids": ["1"], "to": "2024-06-30T00:00:00Z", "as_of": "2024-06-30T00:00:00Z"}`,
+			`{"user_ids": ["1"], "from": "2024-06-30"}`,
+			`{"user_ids": ["1"], "from": "2024-06-30T00:00:00Z", "as_of": "2024-01-01T00:00:00Z"}`,
+		}
+
+		for _, body := range invalidBodies {
+			serviceMock := mocks.NewBalanceServiceMock()
+			context, rec := httpserver.SetupAsRecorder(http.MethodPost, "/balances/query", "", body)
+
+			handler := localHttp.NewBalanceHandler(log, serviceMock)
+			err := handler.QueryBalances(context)
+
+			assert.Nil(t, err)
+			assert.Equal(t, http.StatusBadRequest, rec.Code, body)
+			serviceMock.AssertNotCalled(t, "GetBalancesByUserIDs", mock.Anything, mock.Anything)
+		}
+	})
+
+	t.Run("it returns bad request when too many user ids are requested", func(t *testing.T) {
+		serviceMock := mocks.NewBalanceServiceMock()
+		userIDs := make([]string, balance.MaxBatchUserIDs+1)
+		for i := range userIDs {
+			userIDs[i] = strconv.Itoa(i)
+		}
+
+		body, _ := json.Marshal(balance.BatchQuery{UserIDs: userIDs})
+		context, rec := httpserver.SetupAsRecorder(http.MethodPost, "/balances/query", "", string(body))
+
+		handler := localHttp.NewBalanceHandler(log, serviceMock)
+		err := handler.QueryBalances(context)
+
+		assert.Nil(t, err)
+		assert.Equal(t, http.StatusBadRequest, rec.Code)
+	})
+
+	t.Run("it returns internal server error when service fails", func(t *testing.T) {
+		serviceMock := mocks.NewBalanceServiceMock()
+
+		context, rec := httpserver.SetupAsRecorder(http.MethodPost, "/balances/query", "", `{"user_ids": ["1"]}`)
+		serviceMock.On("GetBalancesByUserIDs", mock.Anything, mock.Anything).
+			Return(balance.BatchResult{}, errors.New("service failure"))
+
+		handler := localHttp.NewBalanceHandler(log, serviceMock)
+		err := handler.QueryBalances(context)
+
+		assert.Nil(t, err)
+		assert.Equal(t, http.StatusInternalServerError, rec.Code)
+	})
+}
@@ -0,0 +1,152 @@
+package http
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+	"time"
+
+	"github.com/labstack/echo/v4"
+	"github.com/sebastianreh/user-balance-api/cmd/httpserver/exceptions"
+	"github.com/sebastianreh/user-balance-api/internal/app/services"
+	"github.com/sebastianreh/user-balance-api/internal/domain/balance"
+	"github.com/sebastianreh/user-balance-api/internal/domain/user"
+	"github.com/sebastianreh/user-balance-api/pkg/logger"
+	"github.com/sebastianreh/user-balance-api/pkg/pubsub"
+	"github.com/sebastianreh/user-balance-api/pkg/sse"
+)
+
+const (
+	balanceStreamHandlerName = "BalanceStreamHandler"
+	lastEventIDHeader        = "Last-Event-ID"
+)
+
+type BalanceStreamHandler struct {
+	log       logger.Logger
+	service   services.BalanceStreamService
+	heartbeat time.Duration
+}
+
+func NewBalanceStreamHandler(log logger.Logger, service services.BalanceStreamService,
+	heartbeat time.Duration) *BalanceStreamHandler {
+	return &BalanceStreamHandler{
+		log:       log,
+		service:   service,
+		heartbeat: heartbeat,
+	}
+}
+
+// StreamUserBalance godoc
+// @Summary Stream the balance of a user
+// @Description Server-Sent Events stream that sends the balance of the user when it connects and after every change
+// @Description of its transactions on any instance. The id of an event is the version of the balance, a client that
+// @Description reconnects with it as Last-Event-ID is only sent the balance when it changed meanwhile. An idle stream
+// @Description sends a heartbeat comment
+// @Tags balances
+// @Produce text/event-stream
+// @Param user_id path string true "User ID"
+// @Param Last-Event-ID header string false "Id of the last event received"
+// @Success 200 {object} balance.UserBalance "Balance events"
+// @Failure 400 {object} exceptions.BadRequestException "Missing user ID"
+// @Failure 404 {object} exceptions.NotFoundException "User not found"
+// @Failure 429 {object} exceptions.TooManyRequestsException "Too many streams of the user"
+// @Failure 503 {object} exceptions.ServiceUnavailableException "Too many streams"
+// @Failure 500 {object} exceptions.InternalServerException "Internal server error"
+// @Router /users/{user_id}/balance/stream [get]
+func (h *BalanceStreamHandler) StreamUserBalance(ctx echo.Context) error {
+	userID, err := validateUserIDParam(ctx)
+	if err != nil {
+		h.log.ErrorAt(err, balanceStreamHandlerName, "StreamUserBalance")
+		exception := exceptions.NewBadRequestException(err.Error())
+		return ctx.JSON(exception.Code(), exception)
+	}
+
+	requestCtx := ctx.Request().Context()
+	subscription, err := h.service.Subscribe(requestCtx, userID)
+	if err != nil {
+		return streamErrorResponse(ctx, err)
+	}
+	defer subscription.Close()
+
+	writer, err := sse.NewWriter(ctx.Response())
+	if err != nil {
+		exception := exceptions.NewInternalServerException(err.Error())
+		return ctx.JSON(exception.Code(), exception)
+	}
+
+	stream := balanceStream{service: h.service, writer: writer, userID: userID,
+		lastVersion: ctx.Request().Header.Get(lastEventIDHeader)}
+	heartbeat := time.NewTicker(h.heartbeat)
+	defer heartbeat.Stop()
+
+	// The subscription comes first, so a change made while the current balance is read is sent after it
+	err = stream.send(requestCtx)
+	for err == nil {
+		select {
+		case <-requestCtx.Done():
+			return nil
+		case <-subscription.Signals():
+			err = stream.send(requestCtx)
+		case <-heartbeat.C:
+			err = writer.Comment("heartbeat")
+		}
+	}
+
+	// The response has started so errors can't be answered, the client reconnects with its last event
+	if requestCtx.Err() == nil {
+		h.log.ErrorAt(err, balanceStreamHandlerName, "StreamUserBalance")
+	}
+
+	return nil
+}
+
+// balanceStream sends the balance of a user when its version differs from the last one the client got
+type balanceStream struct {
+	service     services.BalanceStreamService
+	writer      *sse.Writer
+	userID      string
+	lastVersion string
+}
+
+func (s *balanceStream) send(ctx context.Context) error {
+	userBalance, err := s.service.Current(ctx, s.userID)
+	if err != nil {
+		return err
+	}
+
+	version := userBalance.Version()
+	if version == s.lastVersion {
+		return nil
+	}
+
+	data, err := json.Marshal(userBalance)
+	if err != nil {
+		return err
+	}
+
+	if err = s.writer.Event(version, balance.StreamEvent, data); err != nil {
+		return err
+	}
+
+	s.lastVersion = version
+	return nil
+}
+
+// streamErrorResponse maps the errors of a stream that didn't start, the limits of the user are the client's to
+// respect while the limit of the instance is a server one
+func streamErrorResponse(ctx echo.Context, err error) error {
+	switch {
+	case strings.Contains(err.Error(), user.NotFoundError):
+		exception := exceptions.NewNotFoundException(err.Error())
+		return ctx.JSON(exception.Code(), exception)
+	case strings.Contains(err.Error(), pubsub.TooManyTopicSubscribersError):
+		exception := exceptions.NewTooManyRequestsException(err.Error())
+		return ctx.JSON(exception.Code(), exception)
+	case strings.Contains(err.Error(), pubsub.TooManySubscribersError):
+		exception := exceptions.NewServiceUnavailableException(err.Error())
+		return ctx.JSON(exception.Code(), exception)
+	default:
+		exception := exceptions.NewInternalServerException(err.Error())
+		return ctx.JSON(exception.Code(), exception)
+	}
+}
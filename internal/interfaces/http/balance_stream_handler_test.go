@@ -0,0 +1,149 @@
+package http_test
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/labstack/echo/v4"
+	"github.com/sebastianreh/user-balance-api/cmd/httpserver"
+	"github.com/sebastianreh/user-balance-api/internal/domain/balance"
+	"github.com/sebastianreh/user-balance-api/internal/domain/user"
+	localHttp "github.com/sebastianreh/user-balance-api/internal/interfaces/http"
+	"github.com/sebastianreh/user-balance-api/pkg/logger"
+	"github.com/sebastianreh/user-balance-api/pkg/pubsub"
+	"github.com/sebastianreh/user-balance-api/test/mocks"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func TestBalanceStreamHandler_StreamUserBalance(t *testing.T) {
+	log := logger.NewLogger()
+	current := balance.UserBalance{Balance: 150, TotalDebits: 1, TotalCredits: 2}
+	changed := balance.UserBalance{Balance: 90, TotalDebits: 2, TotalCredits: 2}
+	currentEvent := "id: " + current.Version() + "\nevent: balance\n" +
+		"data: {\"balance\":150,\"total_debits\":1,\"total_credits\":2}\n\n"
+
+	streamContext := func(t *testing.T) (echo.Context, func() string, context.CancelFunc) {
+		echoContext, rec := httpserver.SetupAsRecorderWithIDField(http.MethodGet, "/users", "1", "", "user_id")
+		requestCtx, cancel := context.WithCancel(echoContext.Request().Context())
+		echoContext.SetRequest(echoContext.Request().WithContext(requestCtx))
+		t.Cleanup(cancel)
+		return echoContext, rec.Body.String, cancel
+	}
+
+	t.Run("it sends the current balance when the stream starts", func(t *testing.T) {
+		broker := pubsub.NewBroker(0, 1)
+		subscription, _ := broker.Subscribe("1")
+		serviceMock := mocks.NewBalanceStreamServiceMock()
+		serviceMock.On("Subscribe", mock.Anything, "1").Return(subscription, nil)
+		serviceMock.On("Current", mock.Anything, "1").Return(current, nil)
+
+		echoContext, body, cancel := streamContext(t)
+		cancel()
+
+		handler := localHttp.NewBalanceStreamHandler(log, serviceMock, time.Minute)
+		err := handler.StreamUserBalance(echoContext)
+
+		assert.Nil(t, err)
+		assert.Equal(t, http.StatusOK, echoContext.Response().Status)
+		assert.Equal(t, "text/event-stream", echoContext.Response().Header().Get("Content-Type"))
+		assert.Equal(t, currentEvent, body())
+
+		_, err = broker.Subscribe("1")
+		assert.Nil(t, err, "the subscription is closed with the stream")
+	})
+
+	t.Run("it skips the balance the client already has when it reconnects", func(t *testing.T) {
+		subscription, _ := pubsub.NewBroker(0, 0).Subscribe("1")
+		serviceMock := mocks.NewBalanceStreamServiceMock()
+		serviceMock.On("Subscribe", mock.Anything, "1").Return(subscription, nil)
+		serviceMock.On("Current", mock.Anything, "1").Return(current, nil)
+
+		echoContext, body, cancel := streamContext(t)
+		echoContext.Request().Header.Set("Last-Event-ID", current.Version())
+		cancel()
+
+		handler := localHttp.NewBalanceStreamHandler(log, serviceMock, time.Minute)
+		err := handler.StreamUserBalance(echoContext)
+
+		assert.Nil(t, err)
+		assert.Empty(t, body())
+	})
+
+	t.Run("it sends the balance again after a change", func(t *testing.T) {
+		broker := pubsub.NewBroker(0, 0)
+		subscription, _ := broker.Subscribe("1")
+		serviceMock := mocks.NewBalanceStreamServiceMock()
+		echoContext, body, cancel := streamContext(t)
+		serviceMock.On("Subscribe", mock.Anything, "1").Return(subscription, nil)
+		serviceMock.On("Current", mock.Anything, "1").Return(current, nil).Once()
+		serviceMock.On("Current", mock.Anything, "1").Return(changed, nil).Once().Run(func(mock.Arguments) {
+			cancel()
+		})
+		broker.Publish("1")
+
+		handler := localHttp.NewBalanceStreamHandler(log, serviceMock, time.Minute)
+		err := handler.StreamUserBalance(echoContext)
+
+		assert.Nil(t, err)
+		assert.Equal(t, currentEvent+"id: "+changed.Version()+"\nevent: balance\n"+
+			"data: {\"balance\":90,\"total_debits\":2,\"total_credits\":2}\n\n", body())
+	})
+
+	t.Run("it ends the stream when the balance can't be read", func(t *testing.T) {
+		subscription, _ := pubsub.NewBroker(0, 0).Subscribe("1")
+		serviceMock := mocks.NewBalanceStreamServiceMock()
+		serviceMock.On("Subscribe", mock.Anything, "1").Return(subscription, nil)
+		serviceMock.On("Current", mock.Anything, "1").Return(balance.UserBalance{}, errors.New("connection reset"))
+
+		echoContext, body, _ := streamContext(t)
+
+		handler := localHttp.NewBalanceStreamHandler(log, serviceMock, time.Minute)
+		err := handler.StreamUserBalance(echoContext)
+
+		assert.Nil(t, err)
+		assert.Empty(t, body())
+	})
+
+	t.Run("it returns not found when the user doesn't exist", func(t *testing.T) {
+		serviceMock := mocks.NewBalanceStreamServiceMock()
+		serviceMock.On("Subscribe", mock.Anything, "1").Return(nil, errors.New(user.NotFoundError))
+
+		echoContext, _, _ := streamContext(t)
+
+		handler := localHttp.NewBalanceStreamHandler(log, serviceMock, time.Minute)
+		err := handler.StreamUserBalance(echoContext)
+
+		assert.Nil(t, err)
+		assert.Equal(t, http.StatusNotFound, echoContext.Response().Status)
+	})
+
+	t.Run("it returns too many requests when the user has too many streams", func(t *testing.T) {
+		serviceMock := mocks.NewBalanceStreamServiceMock()
+		serviceMock.On("Subscribe", mock.Anything, "1").Return(nil, errors.New(pubsub.TooManyTopicSubscribersError))
+
+		echoContext, _, _ := streamContext(t)
+
+		handler := localHttp.NewBalanceStreamHandler(log, serviceMock, time.Minute)
+		err := handler.StreamUserBalance(echoContext)
+
+		assert.Nil(t, err)
+		assert.Equal(t, http.StatusTooManyRequests, echoContext.Response().Status)
+	})
+
+	t.Run("it returns service unavailable when the server has too many streams", func(t *testing.T) {
+		serviceMock := mocks.NewBalanceStreamServiceMock()
+		serviceMock.On("Subscribe", mock.Anything, "1").Return(nil, errors.New(pubsub.TooManySubscribersError))
+
+		echoContext, _, _ := streamContext(t)
+
+		handler := localHttp.NewBalanceStreamHandler(log, serviceMock, time.Minute)
+		err := handler.StreamUserBalance(echoContext)
+
+		assert.Nil(t, err)
+		assert.Equal(t, http.StatusServiceUnavailable, echoContext.Response().Status)
+	})
+}
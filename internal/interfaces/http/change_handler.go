@@ -0,0 +1,81 @@
+package http
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/labstack/echo/v4"
+	"github.com/sebastianreh/user-balance-api/cmd/httpserver/exceptions"
+	"github.com/sebastianreh/user-balance-api/internal/app/services"
+	"github.com/sebastianreh/user-balance-api/internal/domain/change"
+	"github.com/sebastianreh/user-balance-api/pkg/logger"
+	customStr "github.com/sebastianreh/user-balance-api/pkg/strings"
+)
+
+const (
+	changeHandlerName = "ChangeHandler"
+)
+
+type ChangeHandler struct {
+	log     logger.Logger
+	service services.ChangeService
+}
+
+func NewChangeHandler(log logger.Logger, service services.ChangeService) *ChangeHandler {
+	return &ChangeHandler{
+		log:     log,
+		service: service,
+	}
+}
+
+// ListChanges godoc
+// @Summary List changes
+// @Description Lists every insert, update, soft delete and restore of the users and the transactions in the order
+// @Description they were committed, with the row as each change left it. The cursors grow with every change, a
+// @Description consumer keeps the next cursor of a page and reads from it on its next poll
+// @Tags Changes
+// @Produce json
+// @Param since query string false "Cursor of the last change read, the feed is read from the start without it"
+// @Param limit query int false "Page size, from 1 to 1000, defaults to 100"
+// @Success 200 {object} change.ListPage "Changes page"
+// @Failure 400 {object} exceptions.BadRequestException "Invalid query params or cursor"
+// @Failure 500 {object} exceptions.InternalServerException "Internal server error"
+// @Router /changes [get]
+func (h *ChangeHandler) ListChanges(ctx echo.Context) error {
+	options, err := validateListChangesRequest(ctx)
+	if err != nil {
+		h.log.ErrorAt(err, changeHandlerName, "ListChanges")
+		exception := exceptions.NewBadRequestException(err.Error())
+		return ctx.JSON(exception.Code(), exception)
+	}
+
+	page, err := h.service.ListChanges(ctx.Request().Context(), options)
+	if err != nil {
+		exception := exceptions.NewInternalServerException(err.Error())
+		return ctx.JSON(exception.Code(), exception)
+	}
+
+	return ctx.JSON(http.StatusOK, page)
+}
+
+func validateListChangesRequest(ctx echo.Context) (change.ListOptions, error) {
+	options := change.ListOptions{Limit: change.DefaultListLimit}
+	if limitParam := ctx.QueryParam("limit"); !customStr.IsEmpty(limitParam) {
+		limit, err := strconv.Atoi(limitParam)
+		if err != nil || limit < 1 || limit > change.MaxListLimit {
+			return options, fmt.Errorf("limit must be a number between 1 and %d", change.MaxListLimit)
+		}
+		options.Limit = limit
+	}
+
+	if sinceParam := ctx.QueryParam("since"); !customStr.IsEmpty(sinceParam) {
+		since, err := change.ParseCursor(sinceParam)
+		if err != nil {
+			return options, err
+		}
+		options.Since = since
+	}
+
+	return options, nil
+}
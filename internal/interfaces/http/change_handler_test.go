@@ -0,0 +1,99 @@
+package http_test
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"testing"
+
+	"github.com/sebastianreh/user-balance-api/cmd/httpserver"
+	"github.com/sebastianreh/user-balance-api/internal/domain/change"
+	localHttp "github.com/sebastianreh/user-balance-api/internal/interfaces/http"
+	"github.com/sebastianreh/user-balance-api/pkg/logger"
+	"github.com/sebastianreh/user-balance-api/test/mocks"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func TestChangeHandler_ListChanges(t *testing.T) {
+	log := logger.NewLogger()
+
+	t.Run("it lists the changes after the since cursor", func(t *testing.T) {
+		serviceMock := mocks.NewChangeServiceMock()
+		page := change.ListPage{NextCursor: "8", Changes: []change.Change{{Cursor: "8", Entity: change.EntityUser,
+			EntityID: "1", Operation: change.OperationUpdate, Data: json.RawMessage(`{"id":1}`)}}}
+		options := change.ListOptions{Since: 7, Limit: 10}
+
+		context, rec := httpserver.SetupAsRecorder(http.MethodGet, "/changes", "", "")
+		context.Request().URL.RawQuery = "since=7&limit=10"
+		serviceMock.On("ListChanges", mock.Anything, options).Return(page, nil)
+
+		handler := localHttp.NewChangeHandler(log, serviceMock)
+		err := handler.ListChanges(context)
+
+		var response change.ListPage
+		_ = json.Unmarshal(rec.Body.Bytes(), &response)
+
+		assert.Nil(t, err)
+		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.Equal(t, "8", response.NextCursor)
+		assert.Equal(t, change.OperationUpdate, response.Changes[0].Operation)
+		assert.JSONEq(t, `{"id":1}`, string(response.Changes[0].Data))
+	})
+
+	t.Run("it reads the feed from the start with the default limit", func(t *testing.T) {
+		serviceMock := mocks.NewChangeServiceMock()
+		options := change.ListOptions{Limit: change.DefaultListLimit}
+
+		context, rec := httpserver.SetupAsRecorder(http.MethodGet, "/changes", "", "")
+		serviceMock.On("ListChanges", mock.Anything, options).Return(change.ListPage{NextCursor: "0"}, nil)
+
+		handler := localHttp.NewChangeHandler(log, serviceMock)
+		err := handler.ListChanges(context)
+
+		assert.Nil(t, err)
+		assert.Equal(t, http.StatusOK, rec.Code)
+		serviceMock.AssertExpectations(t)
+	})
+
+	t.Run("it returns bad request for an invalid cursor", func(t *testing.T) {
+		serviceMock := mocks.NewChangeServiceMock()
+
+		context, rec := httpserver.SetupAsRecorder(http.MethodGet, "/changes", "", "")
+		context.Request().URL.RawQuery = "since=abc"
+
+		handler := localHttp.NewChangeHandler(log, serviceMock)
+		err := handler.ListChanges(context)
+
+		assert.Nil(t, err)
+		assert.Equal(t, http.StatusBadRequest, rec.Code)
+		assert.Contains(t, rec.Body.String(), change.InvalidCursorError)
+		serviceMock.AssertNotCalled(t, "ListChanges", mock.Anything, mock.Anything)
+	})
+
+	t.Run("it returns bad request for a limit out of range", func(t *testing.T) {
+		serviceMock := mocks.NewChangeServiceMock()
+
+		context, rec := httpserver.SetupAsRecorder(http.MethodGet, "/changes", "", "")
+		context.Request().URL.RawQuery = "limit=1001"
+
+		handler := localHttp.NewChangeHandler(log, serviceMock)
+		err := handler.ListChanges(context)
+
+		assert.Nil(t, err)
+		assert.Equal(t, http.StatusBadRequest, rec.Code)
+	})
+
+	t.Run("it returns internal server error when the service fails", func(t *testing.T) {
+		serviceMock := mocks.NewChangeServiceMock()
+
+		context, rec := httpserver.SetupAsRecorder(http.MethodGet, "/changes", "", "")
+		serviceMock.On("ListChanges", mock.Anything, mock.Anything).Return(change.ListPage{}, errors.New("db error"))
+
+		handler := localHttp.NewChangeHandler(log, serviceMock)
+		err := handler.ListChanges(context)
+
+		assert.Nil(t, err)
+		assert.Equal(t, http.StatusInternalServerError, rec.Code)
+	})
+}
@@ -1,38 +1,56 @@
 package http
 
 import (
+	"bufio"
+	"bytes"
 	"encoding/csv"
 	"errors"
 	"fmt"
 	"io"
 	"mime/multipart"
 	"net/http"
+	"strconv"
 	"strings"
 
 	"github.com/labstack/echo/v4"
 	"github.com/sebastianreh/user-balance-api/cmd/httpserver/exceptions"
 	"github.com/sebastianreh/user-balance-api/internal/app/services"
+	"github.com/sebastianreh/user-balance-api/internal/domain/migration"
 	"github.com/sebastianreh/user-balance-api/internal/domain/transaction"
+	"github.com/sebastianreh/user-balance-api/pkg/decompress"
 	"github.com/sebastianreh/user-balance-api/pkg/logger"
+	"github.com/sebastianreh/user-balance-api/pkg/records"
+	customStr "github.com/sebastianreh/user-balance-api/pkg/strings"
 )
 
 const (
 	migrationHandlerName = "MigrationHandler"
 	fileColumns          = 4
+	fileFormatError      = "the file is not in the correct format"
+	profileFormatError   = "mapping profiles only apply to csv files"
+	// sniffSize is how much of the file is read to pick its format
+	sniffSize = 512
+	// headerMaxSize is the longest CSV header read while the file is sniffed, a file without line breaks is refused
+	// once it's read instead of being decompressed whole
+	headerMaxSize = 64 << 10
 )
 
 type MigrationHandler struct {
-	log           logger.Logger
-	service       services.MigrationService
-	reportService services.MigrationReportService
+	log            logger.Logger
+	service        services.MigrationService
+	jobService     services.MigrationJobService
+	profileService services.MigrationProfileService
+	limits         decompress.Limits
 }
 
-func NewMigrationHandler(log logger.Logger, service services.MigrationService,
-	reportService services.MigrationReportService) *MigrationHandler {
+func NewMigrationHandler(log logger.Logger, service services.MigrationService, jobService services.MigrationJobService,
+	profileService services.MigrationProfileService, limits decompress.Limits) *MigrationHandler {
 	return &MigrationHandler{
-		log:           log,
-		service:       service,
-		reportService: reportService,
+		log:            log,
+		service:        service,
+		jobService:     jobService,
+		profileService: profileService,
+		limits:         limits,
 	}
 }
 
@@ -41,29 +59,225 @@ func NewMigrationHandler(log logger.Logger, service services.MigrationService,
 //
 // @Summary      Upload Migration CSV
 // @Description  This endpoint allows uploading a CSV file that contains migration data.
-//               The system processes the CSV file, migrates the necessary data, and sends a report
-//               to the email addresses specified in the "X-Destination-Emails" header.
+//               The system processes the CSV file, migrates the necessary data, and queues a report
+//               to the email addresses specified in the "X-Destination-Emails" header, sent in the background.
+//               Every migration is recorded as a job with the file checksum and the "X-Uploaded-By" header,
+//               the job is returned once it finishes and can be rolled back in /migrations/{job_id}/rollback.
+//               With async=true the file is processed in the background and the created job is returned,
+//               its status can be polled in /migrations/{job_id}.
+//               A file that another migration already imported, even re-saved with other line breaks or blank
+//               lines, is refused with a 409 naming that migration. With force=true it's imported again and
+//               the job records the migration it repeats.
+//               With mode=partial the valid records are saved and the rejected ones can be downloaded
+//               from /migrations/{job_id}/rejects.
+//               With mode=strict the whole file is saved in a single database transaction or not at all.
+//               The file can also be JSON Lines or a JSON array of transactions, the format is picked by the
+//               extension of the file name, then by the content type of the part and last by the content of
+//               the file. Gzip and zstd files, and zip files of CSVs with the same header, are
+//               decompressed while they are read.
+//               The CSV columns are read by position unless the header has the field names or a mapping profile
+//               is given in the profile form field.
+//               With create_missing_users=true the unknown users are created with the ids of the file, taking
+//               their first_name, last_name and email from the optional columns of those names. It can't be used
+//               with mode=strict.
 // @Tags         Migration
 // @Accept       multipart/form-data
 // @Produce      application/json
-// @Param        file         formData   file   true  "CSV file with migration data"
+// @Param        file         formData   file   true  "CSV, JSON Lines or JSON array file with migration data"
+// @Param        profile      formData   string false "Name of the mapping profile for the file columns"
+// @Param        async        query      bool   false "Process the file in the background"
+// @Param        mode         query      string false "Migration mode (default, partial, strict)"
+// @Param        force        query      bool   false "Import the file even when another migration already imported it"
+// @Param        create_missing_users query bool false "Create the unknown users with the ids of the file"
 // @Param        X-User-Emails  header    string true  "Comma-separated list of email addresses to send the migration report"
-// @Success      200 "No content"
+// @Param        X-Uploaded-By  header    string false "Who uploaded the file, kept in the migration history"
+// @Success      200 {object}  migration.Job "Finished migration job"
+// @Success      202 {object}  migration.Job "Created migration job"
 // @Failure      400 {object}  exceptions.BadRequestException {message=string} "Bad request (e.g., invalid CSV file format)"
+// @Failure      409 {object}  exceptions.DuplicatedException "The file was already imported by another migration"
 // @Failure      500 {object}  exceptions.InternalServerException {message=string} "Internal server error"
 
 func (h *MigrationHandler) UploadMigrationCSV(ctx echo.Context) error {
-	file, err := validateFile(ctx)
+	file, format, header, err := validateFile(ctx, h.limits)
 	if err != nil {
 		exception := exceptions.NewBadRequestException(err.Error())
 		h.log.ErrorAt(exception, migrationHandlerName, "UploadMigrationsCSV")
 		return ctx.JSON(exception.Code(), exception)
 	}
 
-	migrationReport, err := h.service.ProcessBalance(ctx.Request().Context(), file)
+	profile, err := h.resolveProfile(ctx, format, header)
 	if err != nil {
-		if err.Error() == services.ReadFileError || strings.Contains(err.Error(), transaction.DuplicateTransactionError) {
-			exception := exceptions.NewBadRequestException(err.Error())
+		return profileErrorResponse(ctx, err)
+	}
+
+	options, async, err := validateMigrationOptionsRequest(ctx, format, profile)
+	if err != nil {
+		exception := exceptions.NewBadRequestException(err.Error())
+		return ctx.JSON(exception.Code(), exception)
+	}
+
+	if async {
+		return h.startMigrationJob(ctx, file, options)
+	}
+
+	return h.runMigrationJob(ctx, file, options)
+}
+
+func (h *MigrationHandler) resolveProfile(ctx echo.Context, format string,
+	header []string) (*migration.MappingProfile, error) {
+	profile, err := resolveProfile(ctx, h.profileService, format, header)
+	if err != nil {
+		h.log.ErrorAt(err, migrationHandlerName, "resolveProfile")
+	}
+
+	return profile, err
+}
+
+// resolveProfile picks how the CSV columns are read. The profile of the form is loaded by name, without one a
+// header with the field names is read by name and any other file by position
+func resolveProfile(ctx echo.Context, profileService services.MigrationProfileService, format string,
+	header []string) (*migration.MappingProfile, error) {
+	name := strings.TrimSpace(ctx.FormValue("profile"))
+	if format != records.FormatCSV {
+		if name != "" {
+			return nil, errors.New(profileFormatError)
+		}
+
+		return nil, nil
+	}
+
+	if name == "" {
+		if migration.IsRecordHeader(header) {
+			return &migration.MappingProfile{}, nil
+		}
+
+		if len(header) != fileColumns {
+			return nil, errors.New(fileFormatError)
+		}
+
+		return nil, nil
+	}
+
+	profile, err := profileService.GetProfile(ctx.Request().Context(), name)
+	if err != nil {
+		return nil, err
+	}
+
+	return &profile, nil
+}
+
+func profileErrorResponse(ctx echo.Context, err error) error {
+	if err.Error() == fileFormatError || err.Error() == profileFormatError ||
+		strings.Contains(err.Error(), migration.ProfileNotFoundError) {
+		exception := exceptions.NewBadRequestException(err.Error())
+		return ctx.JSON(exception.Code(), exception)
+	}
+
+	exception := exceptions.NewInternalServerException(err.Error())
+	return ctx.JSON(exception.Code(), exception)
+}
+
+func (h *MigrationHandler) startMigrationJob(ctx echo.Context, file *multipart.FileHeader,
+	options migration.Options) error {
+	job, err := h.jobService.StartMigration(ctx.Request().Context(), file, options)
+	if err != nil {
+		return migrationErrorResponse(ctx, err)
+	}
+
+	return ctx.JSON(http.StatusAccepted, job)
+}
+
+func (h *MigrationHandler) runMigrationJob(ctx echo.Context, file *multipart.FileHeader,
+	options migration.Options) error {
+	job, err := h.jobService.RunMigration(ctx.Request().Context(), file, options)
+	if err != nil {
+		return migrationErrorResponse(ctx, err)
+	}
+
+	return ctx.JSON(http.StatusOK, job)
+}
+
+func migrationErrorResponse(ctx echo.Context, err error) error {
+	if strings.HasPrefix(err.Error(), migration.DuplicateFileError) {
+		exception := exceptions.NewDuplicatedException(err.Error() + ", upload it with force=true to import it again")
+		return ctx.JSON(exception.Code(), exception)
+	}
+
+	if strings.HasPrefix(err.Error(), services.ReadFileError) || strings.Contains(err.Error(), transaction.DuplicateTransactionError) {
+		exception := exceptions.NewBadRequestException(err.Error())
+		return ctx.JSON(exception.Code(), exception)
+	}
+
+	exception := exceptions.NewInternalServerException(err.Error())
+	return ctx.JSON(exception.Code(), exception)
+}
+
+// ValidateMigrationCSV godoc
+// @Summary Validate migration CSV
+// @Description Run every check of the migration without writing anything. The report lists the problem of every
+// @Description rejected record and the summary the migration would produce
+// @Tags Migration
+// @Accept multipart/form-data
+// @Produce json
+// @Param file formData file true "CSV, JSON Lines or JSON array file with migration data"
+// @Param profile formData string false "Name of the mapping profile for the file columns"
+// @Param create_missing_users query bool false "Count the unknown users as created instead of rejecting them"
+// @Success 200 {object} migration.ValidationReport "Validation report"
+// @Failure 400 {object} exceptions.BadRequestException "Bad request (e.g., invalid CSV file format)"
+// @Failure 500 {object} exceptions.InternalServerException "Internal server error"
+// @Router /migrate/validate [post]
+func (h *MigrationHandler) ValidateMigrationCSV(ctx echo.Context) error {
+	file, format, header, err := validateFile(ctx, h.limits)
+	if err != nil {
+		exception := exceptions.NewBadRequestException(err.Error())
+		h.log.ErrorAt(exception, migrationHandlerName, "ValidateMigrationCSV")
+		return ctx.JSON(exception.Code(), exception)
+	}
+
+	profile, err := h.resolveProfile(ctx, format, header)
+	if err != nil {
+		return profileErrorResponse(ctx, err)
+	}
+
+	createMissingUsers, err := validateBoolRequest(ctx, "create_missing_users")
+	if err != nil {
+		exception := exceptions.NewBadRequestException(err.Error())
+		return ctx.JSON(exception.Code(), exception)
+	}
+
+	validationReport, err := h.service.ValidateBalance(ctx.Request().Context(), func() (io.ReadCloser, error) {
+		return file.Open()
+	}, migration.Options{Format: format, Profile: profile, CreateMissingUsers: createMissingUsers})
+	if err != nil {
+		return migrationErrorResponse(ctx, err)
+	}
+
+	return ctx.JSON(http.StatusOK, validationReport)
+}
+
+// GetMigrationJob godoc
+// @Summary Get migration job
+// @Description Get the status and progress of a migration started with async=true
+// @Tags Migration
+// @Produce json
+// @Param job_id path string true "Migration job ID"
+// @Success 200 {object} migration.Job "Migration job status"
+// @Failure 400 {object} exceptions.BadRequestException "Invalid job ID"
+// @Failure 404 {object} exceptions.NotFoundException "Migration job not found"
+// @Failure 500 {object} exceptions.InternalServerException "Internal server error"
+// @Router /migrations/{job_id} [get]
+func (h *MigrationHandler) GetMigrationJob(ctx echo.Context) error {
+	jobID, err := validateJobIDRequest(ctx)
+	if err != nil {
+		h.log.ErrorAt(err, migrationHandlerName, "GetMigrationJob")
+		exception := exceptions.NewBadRequestException(err.Error())
+		return ctx.JSON(exception.Code(), exception)
+	}
+
+	job, err := h.jobService.GetJob(ctx.Request().Context(), jobID)
+	if err != nil {
+		if strings.Contains(err.Error(), migration.NotFoundError) {
+			exception := exceptions.NewNotFoundException(err.Error())
 			return ctx.JSON(exception.Code(), exception)
 		}
 
@@ -71,47 +285,358 @@ func (h *MigrationHandler) UploadMigrationCSV(ctx echo.Context) error {
 		return ctx.JSON(exception.Code(), exception)
 	}
 
-	reportDestinations := getDestinationEmailsFromRequestHeader(ctx)
-	err = h.reportService.GenerateAndSendReport(migrationReport, reportDestinations)
+	return ctx.JSON(http.StatusOK, job)
+}
+
+// ListMigrations godoc
+// @Summary List migrations
+// @Description Lists the migration history from the newest to the oldest with keyset pagination. Pass the returned
+// @Description next_cursor as cursor to get the next page
+// @Tags Migration
+// @Produce json
+// @Param limit query int false "Page size, from 1 to 500, defaults to 50"
+// @Param cursor query string false "Cursor returned by the previous page"
+// @Success 200 {object} migration.ListPage "Migrations page"
+// @Failure 400 {object} exceptions.BadRequestException "Invalid query params or cursor"
+// @Failure 500 {object} exceptions.InternalServerException "Internal server error"
+// @Router /migrations [get]
+func (h *MigrationHandler) ListMigrations(ctx echo.Context) error {
+	options, err := validateListMigrationsRequest(ctx, migration.DecodeJobCursor)
+	if err != nil {
+		h.log.ErrorAt(err, migrationHandlerName, "ListMigrations")
+		exception := exceptions.NewBadRequestException(err.Error())
+		return ctx.JSON(exception.Code(), exception)
+	}
+
+	page, err := h.jobService.ListJobs(ctx.Request().Context(), options)
 	if err != nil {
 		exception := exceptions.NewInternalServerException(err.Error())
 		return ctx.JSON(exception.Code(), exception)
 	}
 
-	return ctx.NoContent(http.StatusOK)
+	return ctx.JSON(http.StatusOK, page)
 }
 
-func validateFile(ctx echo.Context) (*multipart.FileHeader, error) {
-	file, err := ctx.FormFile("file")
+// GetMigrationTransactions godoc
+// @Summary Get migration transactions
+// @Description Lists the transactions saved by a migration that were not deleted, ordered by id
+// @Tags Migration
+// @Produce json
+// @Param job_id path string true "Migration job ID"
+// @Param limit query int false "Page size, from 1 to 500, defaults to 50"
+// @Param cursor query string false "Cursor returned by the previous page"
+// @Success 200 {object} transaction.ListPage "Transactions page"
+// @Failure 400 {object} exceptions.BadRequestException "Invalid job ID, query params or cursor"
+// @Failure 404 {object} exceptions.NotFoundException "Migration job not found"
+// @Failure 500 {object} exceptions.InternalServerException "Internal server error"
+// @Router /migrations/{job_id}/transactions [get]
+func (h *MigrationHandler) GetMigrationTransactions(ctx echo.Context) error {
+	jobID, err := validateJobIDRequest(ctx)
 	if err != nil {
-		return nil, err
+		h.log.ErrorAt(err, migrationHandlerName, "GetMigrationTransactions")
+		exception := exceptions.NewBadRequestException(err.Error())
+		return ctx.JSON(exception.Code(), exception)
+	}
+
+	options, err := validateListMigrationsRequest(ctx, migration.DecodeTransactionCursor)
+	if err != nil {
+		h.log.ErrorAt(err, migrationHandlerName, "GetMigrationTransactions")
+		exception := exceptions.NewBadRequestException(err.Error())
+		return ctx.JSON(exception.Code(), exception)
+	}
+
+	page, err := h.jobService.ListTransactions(ctx.Request().Context(), jobID, options)
+	if err != nil {
+		if strings.Contains(err.Error(), migration.NotFoundError) {
+			exception := exceptions.NewNotFoundException(err.Error())
+			return ctx.JSON(exception.Code(), exception)
+		}
+
+		exception := exceptions.NewInternalServerException(err.Error())
+		return ctx.JSON(exception.Code(), exception)
+	}
+
+	return ctx.JSON(http.StatusOK, page)
+}
+
+// RollbackMigration godoc
+// @Summary Roll back migration
+// @Description Deletes every transaction saved by a completed or failed migration in a single database transaction,
+// @Description along with a single migration.rolled_back webhook. It's refused while the migration runs and when any
+// @Description of them was edited or deleted after the migration ran
+// @Tags Migration
+// @Produce json
+// @Param job_id path string true "Migration job ID"
+// @Success 200 {object} migration.Job "Rolled back migration job"
+// @Failure 400 {object} exceptions.BadRequestException "Invalid job ID"
+// @Failure 404 {object} exceptions.NotFoundException "Migration job not found"
+// @Failure 409 {object} exceptions.DuplicatedException "The migration is running or was edited since"
+// @Failure 500 {object} exceptions.InternalServerException "Internal server error"
+// @Router /migrations/{job_id}/rollback [post]
+func (h *MigrationHandler) RollbackMigration(ctx echo.Context) error {
+	jobID, err := validateJobIDRequest(ctx)
+	if err != nil {
+		h.log.ErrorAt(err, migrationHandlerName, "RollbackMigration")
+		exception := exceptions.NewBadRequestException(err.Error())
+		return ctx.JSON(exception.Code(), exception)
+	}
+
+	job, err := h.jobService.Rollback(ctx.Request().Context(), jobID)
+	if err != nil {
+		switch {
+		case strings.Contains(err.Error(), migration.NotFoundError):
+			exception := exceptions.NewNotFoundException(err.Error())
+			return ctx.JSON(exception.Code(), exception)
+		case strings.Contains(err.Error(), migration.RollbackStatusError),
+			strings.Contains(err.Error(), migration.RollbackEditedError):
+			exception := exceptions.NewDuplicatedException(err.Error())
+			return ctx.JSON(exception.Code(), exception)
+		}
+
+		exception := exceptions.NewInternalServerException(err.Error())
+		return ctx.JSON(exception.Code(), exception)
+	}
+
+	return ctx.JSON(http.StatusOK, job)
+}
+
+// GetMigrationRejects godoc
+// @Summary Get migration rejected records
+// @Description Download as CSV the records rejected by a migration in partial mode, with their line and reason
+// @Tags Migration
+// @Produce text/csv
+// @Param job_id path string true "Migration job ID"
+// @Success 200 {string} string "CSV with the line, reason, detail and raw content of every rejected record"
+// @Failure 400 {object} exceptions.BadRequestException "Invalid job ID"
+// @Failure 404 {object} exceptions.NotFoundException "Migration job not found"
+// @Failure 500 {object} exceptions.InternalServerException "Internal server error"
+// @Router /migrations/{job_id}/rejects [get]
+func (h *MigrationHandler) GetMigrationRejects(ctx echo.Context) error {
+	jobID, err := validateJobIDRequest(ctx)
+	if err != nil {
+		h.log.ErrorAt(err, migrationHandlerName, "GetMigrationRejects")
+		exception := exceptions.NewBadRequestException(err.Error())
+		return ctx.JSON(exception.Code(), exception)
+	}
+
+	response := ctx.Response()
+	writer := csv.NewWriter(response)
+	// The header is written with the first reject, so a missing job can still be answered with an error
+	writeHeader := func() error {
+		response.Header().Set(echo.HeaderContentType, "text/csv")
+		response.Header().Set(echo.HeaderContentDisposition,
+			fmt.Sprintf("attachment; filename=migration-%s-rejects.csv", jobID))
+		response.WriteHeader(http.StatusOK)
+		return writer.Write(migration.RejectsCSVHeader())
+	}
+
+	err = h.jobService.ForEachReject(ctx.Request().Context(), jobID, func(reject migration.Reject) error {
+		if !response.Committed {
+			if err := writeHeader(); err != nil {
+				return err
+			}
+		}
+
+		return writer.Write([]string{strconv.Itoa(reject.Line), reject.Reason, reject.Detail, reject.Raw})
+	})
+	if err == nil && !response.Committed {
+		err = writeHeader()
 	}
+	writer.Flush()
+
+	if err != nil {
+		h.log.ErrorAt(err, migrationHandlerName, "GetMigrationRejects")
+		if response.Committed {
+			return err
+		}
+
+		if strings.Contains(err.Error(), migration.NotFoundError) {
+			exception := exceptions.NewNotFoundException(err.Error())
+			return ctx.JSON(exception.Code(), exception)
+		}
+
+		exception := exceptions.NewInternalServerException(err.Error())
+		return ctx.JSON(exception.Code(), exception)
+	}
+
+	return writer.Error()
+}
+
+// validateMigrationOptionsRequest reads the query params and headers shared by every way of starting a migration
+func validateMigrationOptionsRequest(ctx echo.Context, format string,
+	profile *migration.MappingProfile) (migration.Options, bool, error) {
+	async, err := validateBoolRequest(ctx, "async")
+	if err != nil {
+		return migration.Options{}, false, err
+	}
+
+	force, err := validateBoolRequest(ctx, "force")
+	if err != nil {
+		return migration.Options{}, false, err
+	}
+
+	mode, err := validateModeRequest(ctx)
+	if err != nil {
+		return migration.Options{}, false, err
+	}
+
+	createMissingUsers, err := validateCreateMissingUsersRequest(ctx, mode)
+	if err != nil {
+		return migration.Options{}, false, err
+	}
+
+	options := migration.Options{Mode: mode, ReportDestinations: getDestinationEmailsFromRequestHeader(ctx),
+		UploadedBy: strings.TrimSpace(ctx.Request().Header.Get("X-Uploaded-By")), Force: force,
+		CreateMissingUsers: createMissingUsers, Format: format, Profile: profile}
+
+	return options, async, nil
+}
 
-	if !strings.Contains(file.Filename, ".csv") {
-		return nil, errors.New("the file must be csv")
+func validateModeRequest(ctx echo.Context) (string, error) {
+	mode := ctx.QueryParam("mode")
+	if mode == "" {
+		return migration.ModeDefault, nil
+	}
+
+	if !migration.IsValidMode(mode) {
+		return mode, fmt.Errorf("mode must be %s, %s or %s", migration.ModeDefault, migration.ModePartial,
+			migration.ModeStrict)
+	}
+
+	return mode, nil
+}
+
+func validateBoolRequest(ctx echo.Context, name string) (bool, error) {
+	param := ctx.QueryParam(name)
+	if param == "" {
+		return false, nil
+	}
+
+	value, err := strconv.ParseBool(param)
+	if err != nil {
+		return false, fmt.Errorf("%s must be true or false", name)
+	}
+
+	return value, nil
+}
+
+func validateCreateMissingUsersRequest(ctx echo.Context, mode string) (bool, error) {
+	createMissingUsers, err := validateBoolRequest(ctx, "create_missing_users")
+	if err != nil {
+		return false, err
+	}
+
+	if createMissingUsers && mode == migration.ModeStrict {
+		return false, errors.New(migration.StrictMissingUsersError)
+	}
+
+	return createMissingUsers, nil
+}
+
+func validateListMigrationsRequest(ctx echo.Context,
+	decodeCursor func(value string) (migration.Cursor, error)) (migration.ListOptions, error) {
+	options := migration.ListOptions{Limit: migration.DefaultListLimit}
+	if limitParam := ctx.QueryParam("limit"); !customStr.IsEmpty(limitParam) {
+		limit, err := strconv.Atoi(limitParam)
+		if err != nil || limit < 1 || limit > migration.MaxListLimit {
+			return options, fmt.Errorf("limit must be a number between 1 and %d", migration.MaxListLimit)
+		}
+		options.Limit = limit
+	}
+
+	if cursorParam := ctx.QueryParam("cursor"); !customStr.IsEmpty(cursorParam) {
+		cursor, err := decodeCursor(cursorParam)
+		if err != nil {
+			return options, err
+		}
+		options.After = &cursor
+	}
+
+	return options, nil
+}
+
+func validateJobIDRequest(ctx echo.Context) (string, error) {
+	jobID := ctx.Param("job_id")
+	if customStr.IsEmpty(jobID) {
+		return jobID, errors.New("missing param job_id")
+	}
+
+	// Jobs are identified by a sequence, anything else would fail in the database
+	if _, err := strconv.ParseInt(jobID, 10, 64); err != nil {
+		return jobID, errors.New("job_id must be numeric")
+	}
+
+	return jobID, nil
+}
+
+// validateFile returns the uploaded file with its format and the header of a CSV file. Gzip, zip and zstd files are
+// sniffed once decompressed within the same limits the migration reads the whole file with. The columns are
+// checked once the profile is known
+func validateFile(ctx echo.Context, limits decompress.Limits) (*multipart.FileHeader, string, []string, error) {
+	file, err := ctx.FormFile("file")
+	if err != nil {
+		return nil, "", nil, err
 	}
 
 	src, err := file.Open()
 	if err != nil {
-		return nil, err
+		return nil, "", nil, err
 	}
 	defer src.Close()
 
-	csvReader := csv.NewReader(src)
-	record, err := csvReader.Read()
-	if err == io.EOF {
-		return nil, errors.New("empty file")
+	declaredFormat, _ := records.DeclaredFormat(file.Filename, file.Header.Get(echo.HeaderContentType))
+	format, header, err := sniffFile(src, declaredFormat, limits)
+	if err != nil {
+		return nil, "", nil, err
+	}
+
+	return file, format, header, nil
+}
+
+// sniffFile returns the declared format, or the one of the file content when none was declared, and the header of a
+// CSV file. The content is checked to be text whatever the declared format, and no more than the header is read
+func sniffFile(src io.Reader, declaredFormat string, limits decompress.Limits) (string, []string, error) {
+	content, err := decompress.Open(src, limits)
+	if err != nil {
+		return "", nil, fmt.Errorf("cannot read file - error: %s", err.Error())
+	}
+	defer content.Close()
+
+	reader := bufio.NewReaderSize(content, sniffSize)
+	head, err := reader.Peek(sniffSize)
+	if err != nil && !errors.Is(err, io.EOF) {
+		return "", nil, fmt.Errorf("cannot read file - error: %s", err.Error())
+	}
+
+	if len(bytes.TrimSpace(head)) == 0 {
+		return "", nil, errors.New("empty file")
+	}
+
+	format, supported := records.SniffFormat(head)
+	if !supported {
+		return "", nil, errors.New("the file must be csv, ndjson or json")
+	}
+
+	if declaredFormat != "" {
+		format = declaredFormat
+	}
+
+	if format != records.FormatCSV {
+		return format, nil, nil
 	}
 
+	// One byte more than the limit is read to tell a header of the exact size from a longer one
+	csvReader := csv.NewReader(io.LimitReader(reader, headerMaxSize+1))
+	header, err := csvReader.Read()
 	if err != nil {
-		return nil, fmt.Errorf("cannot read file - error: %s", err.Error())
+		return "", nil, fmt.Errorf("cannot read file - error: %s", err.Error())
 	}
 
-	if len(record) != fileColumns {
-		return nil, errors.New("the file is not in the correct format")
+	if csvReader.InputOffset() > headerMaxSize {
+		return "", nil, fmt.Errorf("the header is longer than %d bytes", headerMaxSize)
 	}
 
-	return file, nil
+	return format, header, nil
 }
 
 func getDestinationEmailsFromRequestHeader(ctx echo.Context) []string {
@@ -1,19 +1,30 @@
 package http_test
 
 import (
+	"archive/zip"
 	"bytes"
+	"compress/gzip"
+	"encoding/json"
 	"errors"
+	"fmt"
 	"mime/multipart"
 	"net/http"
 	"net/http/httptest"
+	"net/textproto"
+	"strings"
 	"testing"
 
+	"github.com/sebastianreh/user-balance-api/cmd/httpserver"
+	"github.com/sebastianreh/user-balance-api/internal/domain/migration"
 	"github.com/sebastianreh/user-balance-api/internal/domain/report"
+	"github.com/sebastianreh/user-balance-api/internal/domain/transaction"
 
 	"github.com/labstack/echo/v4"
 	"github.com/sebastianreh/user-balance-api/internal/app/services"
 	localHttp "github.com/sebastianreh/user-balance-api/internal/interfaces/http"
+	"github.com/sebastianreh/user-balance-api/pkg/decompress"
 	"github.com/sebastianreh/user-balance-api/pkg/logger"
+	"github.com/sebastianreh/user-balance-api/pkg/records"
 	"github.com/sebastianreh/user-balance-api/test/mocks"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
@@ -22,68 +33,69 @@ import (
 func TestMigrationHandler_UploadMigrationCSV(t *testing.T) {
 	log := logger.NewLogger()
 
-	t.Run("it processes the CSV successfully and sends the report to the default email", func(t *testing.T) {
-		serviceMock := mocks.NewMigrationServiceMock()
-		migrationServiceMock := mocks.NewReportServiceMock()
-		migrationReport := report.MigrationSummary{
-			TotalRecords: 1000, UsersUpdated: 200,
-		}
+	t.Run("it processes the CSV successfully and returns the finished migration job", func(t *testing.T) {
+		jobServiceMock := mocks.NewMigrationJobServiceMock()
+		job := migration.Job{ID: "1", Status: migration.StatusCompleted, FileName: "test.csv",
+			Summary: &report.MigrationSummary{TotalRecords: 1000, UsersUpdated: 200}}
 
 		rec, ctx := createMultipartFile(t, "test.csv", "1,1,100,2023-09-14T20:00:00Z")
 
-		serviceMock.On("ProcessBalance", mock.Anything, mock.Anything).Return(migrationReport, nil)
-		migrationServiceMock.On("GenerateAndSendReport", migrationReport, mock.Anything).Return(nil)
+		jobServiceMock.On("RunMigration", mock.Anything, mock.Anything, mock.Anything).Return(job, nil)
 
-		handler := localHttp.NewMigrationHandler(log, serviceMock, migrationServiceMock)
+		handler := localHttp.NewMigrationHandler(log, mocks.NewMigrationServiceMock(), jobServiceMock,
+			mocks.NewMigrationProfileServiceMock(), fileLimits)
 		err := handler.UploadMigrationCSV(ctx)
 
+		var response migration.Job
+		_ = json.Unmarshal(rec.Body.Bytes(), &response)
+
 		assert.Nil(t, err)
 		assert.Equal(t, http.StatusOK, rec.Code)
-		serviceMock.AssertCalled(t, "ProcessBalance", mock.Anything, mock.Anything)
+		assert.Equal(t, job.ID, response.ID)
+		assert.Equal(t, job.Summary, response.Summary)
 	})
 
 	t.Run("it processes the CSV successfully and sends the report to email addresses specified"+
 		" in the X-User-Emails header.", func(t *testing.T) {
-		serviceMock := mocks.NewMigrationServiceMock()
-		migrationServiceMock := mocks.NewReportServiceMock()
-		migrationReport := report.MigrationSummary{
-			TotalRecords: 1000, UsersUpdated: 200,
-		}
+		jobServiceMock := mocks.NewMigrationJobServiceMock()
 
 		rec, ctx := createMultipartFile(t, "test.csv", "1,1,100,2023-09-14T20:00:00Z")
 		ctx.Request().Header.Set("X-Destination-Emails", "test1@example.com,test2@example.com")
+		ctx.Request().Header.Set("X-Uploaded-By", " ops@example.com ")
 
-		serviceMock.On("ProcessBalance", mock.Anything, mock.Anything).Return(migrationReport, nil)
-		migrationServiceMock.On("GenerateAndSendReport", migrationReport, mock.Anything).Return(nil)
+		jobServiceMock.On("RunMigration", mock.Anything, mock.Anything, migration.Options{Mode: migration.ModeDefault,
+			ReportDestinations: []string{"test1@example.com", "test2@example.com"}, UploadedBy: "ops@example.com",
+			Format: records.FormatCSV}).Return(migration.Job{ID: "1", Status: migration.StatusCompleted}, nil)
 
-		handler := localHttp.NewMigrationHandler(log, serviceMock, migrationServiceMock)
+		handler := localHttp.NewMigrationHandler(log, mocks.NewMigrationServiceMock(), jobServiceMock,
+			mocks.NewMigrationProfileServiceMock(), fileLimits)
 		err := handler.UploadMigrationCSV(ctx)
 
 		assert.Nil(t, err)
 		assert.Equal(t, http.StatusOK, rec.Code)
-		serviceMock.AssertCalled(t, "ProcessBalance", mock.Anything, mock.Anything)
 	})
 
-	t.Run("it returns an error for an invalid file format", func(t *testing.T) {
-		serviceMock := mocks.NewMigrationServiceMock()
-		migrationServiceMock := mocks.NewReportServiceMock()
+	t.Run("it returns an error for a file that is not csv nor json", func(t *testing.T) {
+		jobServiceMock := mocks.NewMigrationJobServiceMock()
 
-		rec, ctx := createMultipartFile(t, "test.txt", "1,1,100,2023-09-14T20:00:00Z")
+		rec, ctx := createMultipartFile(t, "test.csv", "\x89PNG\r\n\x1a\n\x00\x00")
 
-		handler := localHttp.NewMigrationHandler(log, serviceMock, migrationServiceMock)
+		handler := localHttp.NewMigrationHandler(log, mocks.NewMigrationServiceMock(), jobServiceMock,
+			mocks.NewMigrationProfileServiceMock(), fileLimits)
 		err := handler.UploadMigrationCSV(ctx)
 
 		assert.Nil(t, err)
 		assert.Equal(t, http.StatusBadRequest, rec.Code)
+		jobServiceMock.AssertNotCalled(t, "RunMigration", mock.Anything, mock.Anything, mock.Anything)
 	})
 
 	t.Run("it returns an error for an empty CSV", func(t *testing.T) {
-		serviceMock := mocks.NewMigrationServiceMock()
-		migrationServiceMock := mocks.NewReportServiceMock()
+		jobServiceMock := mocks.NewMigrationJobServiceMock()
 
 		rec, ctx := createMultipartFile(t, "test.csv", "")
 
-		handler := localHttp.NewMigrationHandler(log, serviceMock, migrationServiceMock)
+		handler := localHttp.NewMigrationHandler(log, mocks.NewMigrationServiceMock(), jobServiceMock,
+			mocks.NewMigrationProfileServiceMock(), fileLimits)
 		err := handler.UploadMigrationCSV(ctx)
 
 		assert.Nil(t, err)
@@ -91,12 +103,12 @@ func TestMigrationHandler_UploadMigrationCSV(t *testing.T) {
 	})
 
 	t.Run("it returns an error for a CSV with the wrong format", func(t *testing.T) {
-		serviceMock := mocks.NewMigrationServiceMock()
-		migrationServiceMock := mocks.NewReportServiceMock()
+		jobServiceMock := mocks.NewMigrationJobServiceMock()
 
 		rec, ctx := createMultipartFile(t, "test.csv", "1,1,100") // Missing one column
 
-		handler := localHttp.NewMigrationHandler(log, serviceMock, migrationServiceMock)
+		handler := localHttp.NewMigrationHandler(log, mocks.NewMigrationServiceMock(), jobServiceMock,
+			mocks.NewMigrationProfileServiceMock(), fileLimits)
 		err := handler.UploadMigrationCSV(ctx)
 
 		assert.Nil(t, err)
@@ -104,46 +116,1036 @@ func TestMigrationHandler_UploadMigrationCSV(t *testing.T) {
 	})
 
 	t.Run("it returns an error when the service fails", func(t *testing.T) {
-		serviceMock := mocks.NewMigrationServiceMock()
-		migrationServiceMock := mocks.NewReportServiceMock()
+		jobServiceMock := mocks.NewMigrationJobServiceMock()
 
 		rec, ctx := createMultipartFile(t, "test.csv", "1,1,100,2023-09-14T20:00:00Z")
 
 		expectedError := errors.New(services.ReadFileError)
-		serviceMock.On("ProcessBalance", mock.Anything, mock.Anything).Return(
-			report.MigrationSummary{}, expectedError)
+		jobServiceMock.On("RunMigration", mock.Anything, mock.Anything, mock.Anything).
+			Return(migration.Job{Status: migration.StatusFailed}, expectedError)
+
+		handler := localHttp.NewMigrationHandler(log, mocks.NewMigrationServiceMock(), jobServiceMock,
+			mocks.NewMigrationProfileServiceMock(), fileLimits)
+		err := handler.UploadMigrationCSV(ctx)
+
+		assert.Nil(t, err)
+		assert.Equal(t, http.StatusBadRequest, rec.Code)
+		jobServiceMock.AssertCalled(t, "RunMigration", mock.Anything, mock.Anything, mock.Anything)
+	})
+
+	t.Run("it returns an internal server error when the report fails unexpectedly", func(t *testing.T) {
+		jobServiceMock := mocks.NewMigrationJobServiceMock()
+
+		rec, ctx := createMultipartFile(t, "test.csv", "1,1,100,2023-09-14T20:00:00Z")
+
+		expectedError := errors.New("migration report service error")
+		jobServiceMock.On("RunMigration", mock.Anything, mock.Anything, mock.Anything).
+			Return(migration.Job{Status: migration.StatusFailed}, expectedError)
+
+		handler := localHttp.NewMigrationHandler(log, mocks.NewMigrationServiceMock(), jobServiceMock,
+			mocks.NewMigrationProfileServiceMock(), fileLimits)
+		err := handler.UploadMigrationCSV(ctx)
+
+		assert.Nil(t, err)
+		assert.Equal(t, http.StatusInternalServerError, rec.Code)
+	})
+}
+
+func TestMigrationHandler_UploadMigrationCSVAsync(t *testing.T) {
+	log := logger.NewLogger()
+
+	t.Run("it starts a migration job and returns it", func(t *testing.T) {
+		jobServiceMock := mocks.NewMigrationJobServiceMock()
+		job := migration.Job{ID: "1", Status: migration.StatusPending, FileName: "test.csv"}
+
+		rec, ctx := createMultipartFile(t, "test.csv", "1,1,100,2023-09-14T20:00:00Z")
+		ctx.Request().URL.RawQuery = "async=true"
+		ctx.Request().Header.Set("X-Destination-Emails", "test1@example.com")
+
+		jobServiceMock.On("StartMigration", mock.Anything, mock.Anything, migration.Options{Mode: migration.ModeDefault,
+			ReportDestinations: []string{"test1@example.com"}, Format: records.FormatCSV}).Return(job, nil)
+
+		serviceMock := mocks.NewMigrationServiceMock()
+		handler := localHttp.NewMigrationHandler(log, serviceMock, jobServiceMock,
+			mocks.NewMigrationProfileServiceMock(), fileLimits)
+		err := handler.UploadMigrationCSV(ctx)
+
+		var response migration.Job
+		_ = json.Unmarshal(rec.Body.Bytes(), &response)
+
+		assert.Nil(t, err)
+		assert.Equal(t, http.StatusAccepted, rec.Code)
+		assert.Equal(t, job.ID, response.ID)
+		jobServiceMock.AssertNotCalled(t, "RunMigration", mock.Anything, mock.Anything, mock.Anything)
+	})
+
+	t.Run("it returns bad request for an invalid async value", func(t *testing.T) {
+		jobServiceMock := mocks.NewMigrationJobServiceMock()
+
+		rec, ctx := createMultipartFile(t, "test.csv", "1,1,100,2023-09-14T20:00:00Z")
+		ctx.Request().URL.RawQuery = "async=maybe"
 
-		handler := localHttp.NewMigrationHandler(log, serviceMock, migrationServiceMock)
+		handler := localHttp.NewMigrationHandler(log, mocks.NewMigrationServiceMock(),
+			jobServiceMock, mocks.NewMigrationProfileServiceMock(), fileLimits)
 		err := handler.UploadMigrationCSV(ctx)
 
 		assert.Nil(t, err)
 		assert.Equal(t, http.StatusBadRequest, rec.Code)
-		serviceMock.AssertCalled(t, "ProcessBalance", mock.Anything, mock.Anything)
+		jobServiceMock.AssertNotCalled(t, "StartMigration", mock.Anything, mock.Anything, mock.Anything)
 	})
 
-	t.Run("it returns an internal server error when migrationReportService fails unexpectedly", func(t *testing.T) {
+	t.Run("it returns internal server error when the job can't be started", func(t *testing.T) {
+		jobServiceMock := mocks.NewMigrationJobServiceMock()
+
+		rec, ctx := createMultipartFile(t, "test.csv", "1,1,100,2023-09-14T20:00:00Z")
+		ctx.Request().URL.RawQuery = "async=true"
+
+		jobServiceMock.On("StartMigration", mock.Anything, mock.Anything, mock.Anything).
+			Return(migration.Job{}, errors.New("repository error"))
+
+		handler := localHttp.NewMigrationHandler(log, mocks.NewMigrationServiceMock(),
+			jobServiceMock, mocks.NewMigrationProfileServiceMock(), fileLimits)
+		err := handler.UploadMigrationCSV(ctx)
+
+		assert.Nil(t, err)
+		assert.Equal(t, http.StatusInternalServerError, rec.Code)
+	})
+}
+
+func TestMigrationHandler_UploadMigrationCSVPartial(t *testing.T) {
+	log := logger.NewLogger()
+
+	t.Run("it runs a partial migration job and returns it once finished", func(t *testing.T) {
+		jobServiceMock := mocks.NewMigrationJobServiceMock()
+		summary := &report.MigrationSummary{TotalRecords: 1, UsersUpdated: 1, RejectedRecords: 1,
+			RejectsByReason: map[string]int{migration.RejectReasonUnknownUser: 1}}
+		job := migration.Job{ID: "1", Status: migration.StatusCompleted, Mode: migration.ModePartial, Summary: summary}
+
+		rec, ctx := createMultipartFile(t, "test.csv", "1,1,100,2023-09-14T20:00:00Z")
+		ctx.Request().URL.RawQuery = "mode=partial"
+		ctx.Request().Header.Set("X-Destination-Emails", "test1@example.com")
+
+		jobServiceMock.On("RunMigration", mock.Anything, mock.Anything, migration.Options{Mode: migration.ModePartial,
+			ReportDestinations: []string{"test1@example.com"}, Format: records.FormatCSV}).Return(job, nil)
+
 		serviceMock := mocks.NewMigrationServiceMock()
-		migrationServiceMock := mocks.NewReportServiceMock()
-		migrationReport := report.MigrationSummary{
-			TotalRecords: 1000, UsersUpdated: 200,
+		handler := localHttp.NewMigrationHandler(log, serviceMock, jobServiceMock,
+			mocks.NewMigrationProfileServiceMock(), fileLimits)
+		err := handler.UploadMigrationCSV(ctx)
+
+		var response migration.Job
+		_ = json.Unmarshal(rec.Body.Bytes(), &response)
+
+		assert.Nil(t, err)
+		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.Equal(t, summary.RejectsByReason, response.Summary.RejectsByReason)
+	})
+
+	t.Run("it returns bad request for an unknown mode", func(t *testing.T) {
+		jobServiceMock := mocks.NewMigrationJobServiceMock()
+
+		rec, ctx := createMultipartFile(t, "test.csv", "1,1,100,2023-09-14T20:00:00Z")
+		ctx.Request().URL.RawQuery = "mode=lenient"
+
+		handler := localHttp.NewMigrationHandler(log, mocks.NewMigrationServiceMock(),
+			jobServiceMock, mocks.NewMigrationProfileServiceMock(), fileLimits)
+		err := handler.UploadMigrationCSV(ctx)
+
+		assert.Nil(t, err)
+		assert.Equal(t, http.StatusBadRequest, rec.Code)
+		jobServiceMock.AssertNotCalled(t, "RunMigration", mock.Anything, mock.Anything, mock.Anything)
+	})
+
+	t.Run("it returns bad request when the file can't be read", func(t *testing.T) {
+		jobServiceMock := mocks.NewMigrationJobServiceMock()
+
+		rec, ctx := createMultipartFile(t, "test.csv", "1,1,100,2023-09-14T20:00:00Z")
+		ctx.Request().URL.RawQuery = "mode=partial"
+
+		jobServiceMock.On("RunMigration", mock.Anything, mock.Anything, mock.Anything).
+			Return(migration.Job{Status: migration.StatusFailed}, errors.New(services.ReadFileError+": EOF"))
+
+		handler := localHttp.NewMigrationHandler(log, mocks.NewMigrationServiceMock(),
+			jobServiceMock, mocks.NewMigrationProfileServiceMock(), fileLimits)
+		err := handler.UploadMigrationCSV(ctx)
+
+		assert.Nil(t, err)
+		assert.Equal(t, http.StatusBadRequest, rec.Code)
+	})
+}
+
+func TestMigrationHandler_UploadMigrationCSVStrict(t *testing.T) {
+	log := logger.NewLogger()
+	strictOptions := mock.MatchedBy(func(options migration.Options) bool {
+		return options.Mode == migration.ModeStrict && options.Profile == nil
+	})
+
+	t.Run("it processes the CSV in strict mode and sends the report", func(t *testing.T) {
+		jobServiceMock := mocks.NewMigrationJobServiceMock()
+
+		rec, ctx := createMultipartFile(t, "test.csv", "1,1,100,2023-09-14T20:00:00Z")
+		ctx.Request().URL.RawQuery = "mode=strict"
+
+		jobServiceMock.On("RunMigration", mock.Anything, mock.Anything, strictOptions).
+			Return(migration.Job{ID: "1", Status: migration.StatusCompleted, Mode: migration.ModeStrict}, nil)
+
+		handler := localHttp.NewMigrationHandler(log, mocks.NewMigrationServiceMock(), jobServiceMock,
+			mocks.NewMigrationProfileServiceMock(), fileLimits)
+		err := handler.UploadMigrationCSV(ctx)
+
+		assert.Nil(t, err)
+		assert.Equal(t, http.StatusOK, rec.Code)
+	})
+
+	t.Run("it returns bad request when a duplicate rolls back the migration", func(t *testing.T) {
+		jobServiceMock := mocks.NewMigrationJobServiceMock()
+
+		rec, ctx := createMultipartFile(t, "test.csv", "1,1,100,2023-09-14T20:00:00Z")
+		ctx.Request().URL.RawQuery = "mode=strict"
+
+		jobServiceMock.On("RunMigration", mock.Anything, mock.Anything, strictOptions).
+			Return(migration.Job{Status: migration.StatusFailed}, errors.New(transaction.DuplicateTransactionError))
+
+		handler := localHttp.NewMigrationHandler(log, mocks.NewMigrationServiceMock(), jobServiceMock,
+			mocks.NewMigrationProfileServiceMock(), fileLimits)
+		err := handler.UploadMigrationCSV(ctx)
+
+		assert.Nil(t, err)
+		assert.Equal(t, http.StatusBadRequest, rec.Code)
+	})
+}
+
+func TestMigrationHandler_UploadMigrationCSVDuplicate(t *testing.T) {
+	log := logger.NewLogger()
+
+	t.Run("it returns conflict with the earlier migration when the file was already imported", func(t *testing.T) {
+		for _, query := range []string{"", "async=true"} {
+			jobServiceMock := mocks.NewMigrationJobServiceMock()
+			duplicateError := errors.New(migration.DuplicateFileError + " by migration 7")
+
+			rec, ctx := createMultipartFile(t, "test.csv", "1,1,100,2023-09-14T20:00:00Z")
+			ctx.Request().URL.RawQuery = query
+
+			jobServiceMock.On("RunMigration", mock.Anything, mock.Anything, mock.Anything).
+				Return(migration.Job{}, duplicateError)
+			jobServiceMock.On("StartMigration", mock.Anything, mock.Anything, mock.Anything).
+				Return(migration.Job{}, duplicateError)
+
+			handler := localHttp.NewMigrationHandler(log, mocks.NewMigrationServiceMock(), jobServiceMock,
+				mocks.NewMigrationProfileServiceMock(), fileLimits)
+			err := handler.UploadMigrationCSV(ctx)
+
+			var response map[string]interface{}
+			_ = json.Unmarshal(rec.Body.Bytes(), &response)
+
+			assert.Nil(t, err)
+			assert.Equal(t, http.StatusConflict, rec.Code, query)
+			assert.Contains(t, response["error"], "by migration 7")
 		}
+	})
+
+	t.Run("it imports the file again with force", func(t *testing.T) {
+		jobServiceMock := mocks.NewMigrationJobServiceMock()
+		job := migration.Job{ID: "8", Status: migration.StatusCompleted, Forced: true, DuplicateOf: "7"}
 
 		rec, ctx := createMultipartFile(t, "test.csv", "1,1,100,2023-09-14T20:00:00Z")
+		ctx.Request().URL.RawQuery = "force=true"
+		ctx.Request().Header.Set("X-Destination-Emails", "test1@example.com")
 
-		expectedError := errors.New("migration report service error")
-		serviceMock.On("ProcessBalance", mock.Anything, mock.Anything).Return(migrationReport, nil)
-		migrationServiceMock.On("GenerateAndSendReport", migrationReport, mock.Anything).Return(expectedError)
+		jobServiceMock.On("RunMigration", mock.Anything, mock.Anything, migration.Options{Mode: migration.ModeDefault,
+			ReportDestinations: []string{"test1@example.com"}, Force: true, Format: records.FormatCSV}).Return(job, nil)
 
-		handler := localHttp.NewMigrationHandler(log, serviceMock, migrationServiceMock)
+		handler := localHttp.NewMigrationHandler(log, mocks.NewMigrationServiceMock(), jobServiceMock,
+			mocks.NewMigrationProfileServiceMock(), fileLimits)
 		err := handler.UploadMigrationCSV(ctx)
 
+		var response migration.Job
+		_ = json.Unmarshal(rec.Body.Bytes(), &response)
+
+		assert.Nil(t, err)
+		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.True(t, response.Forced)
+		assert.Equal(t, "7", response.DuplicateOf)
+	})
+
+	t.Run("it returns bad request for an invalid force value", func(t *testing.T) {
+		jobServiceMock := mocks.NewMigrationJobServiceMock()
+
+		rec, ctx := createMultipartFile(t, "test.csv", "1,1,100,2023-09-14T20:00:00Z")
+		ctx.Request().URL.RawQuery = "force=yes"
+
+		handler := localHttp.NewMigrationHandler(log, mocks.NewMigrationServiceMock(), jobServiceMock,
+			mocks.NewMigrationProfileServiceMock(), fileLimits)
+		err := handler.UploadMigrationCSV(ctx)
+
+		assert.Nil(t, err)
+		assert.Equal(t, http.StatusBadRequest, rec.Code)
+		assert.Contains(t, rec.Body.String(), "force must be true or false")
+		jobServiceMock.AssertNotCalled(t, "RunMigration", mock.Anything, mock.Anything, mock.Anything)
+	})
+}
+
+func TestMigrationHandler_UploadMigrationCSVCreateMissingUsers(t *testing.T) {
+	log := logger.NewLogger()
+
+	t.Run("it creates the missing users with the user columns of the header", func(t *testing.T) {
+		jobServiceMock := mocks.NewMigrationJobServiceMock()
+		summary := &report.MigrationSummary{TotalRecords: 1, UsersUpdated: 1, UsersCreated: 1}
+
+		rec, ctx := createMultipartFile(t, "test.csv",
+			"id,user_id,amount,datetime,email\n1,7,100,2023-09-14T20:00:00Z,ada@example.com")
+		ctx.Request().URL.RawQuery = "create_missing_users=true"
+
+		jobServiceMock.On("RunMigration", mock.Anything, mock.Anything,
+			mock.MatchedBy(func(options migration.Options) bool {
+				return options.CreateMissingUsers && options.Profile != nil
+			})).Return(migration.Job{ID: "1", Status: migration.StatusCompleted, Summary: summary}, nil)
+
+		handler := localHttp.NewMigrationHandler(log, mocks.NewMigrationServiceMock(), jobServiceMock,
+			mocks.NewMigrationProfileServiceMock(), fileLimits)
+		err := handler.UploadMigrationCSV(ctx)
+
+		var response migration.Job
+		_ = json.Unmarshal(rec.Body.Bytes(), &response)
+
+		assert.Nil(t, err)
+		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.Equal(t, 1, response.Summary.UsersCreated)
+	})
+
+	t.Run("it returns bad request in strict mode", func(t *testing.T) {
+		jobServiceMock := mocks.NewMigrationJobServiceMock()
+
+		rec, ctx := createMultipartFile(t, "test.csv", "1,7,100,2023-09-14T20:00:00Z")
+		ctx.Request().URL.RawQuery = "create_missing_users=true&mode=strict"
+
+		handler := localHttp.NewMigrationHandler(log, mocks.NewMigrationServiceMock(), jobServiceMock,
+			mocks.NewMigrationProfileServiceMock(), fileLimits)
+		err := handler.UploadMigrationCSV(ctx)
+
+		assert.Nil(t, err)
+		assert.Equal(t, http.StatusBadRequest, rec.Code)
+		assert.Contains(t, rec.Body.String(), migration.StrictMissingUsersError)
+		jobServiceMock.AssertNotCalled(t, "RunMigration", mock.Anything, mock.Anything, mock.Anything)
+	})
+}
+
+func TestMigrationHandler_ValidateMigrationCSV(t *testing.T) {
+	log := logger.NewLogger()
+
+	t.Run("it returns the validation report of the file", func(t *testing.T) {
+		serviceMock := mocks.NewMigrationServiceMock()
+		validationReport := migration.ValidationReport{
+			Summary: report.MigrationSummary{TotalRecords: 1, UsersUpdated: 1, RejectedRecords: 1,
+				RejectsByReason: map[string]int{migration.RejectReasonUnknownUser: 1}},
+			Problems: []migration.Reject{{Line: 3, Raw: "2,9,10,2023-09-14T20:00:00Z",
+				Reason: migration.RejectReasonUnknownUser, Detail: "user not found"}},
+		}
+
+		rec, ctx := createMultipartFile(t, "test.csv", "1,1,100,2023-09-14T20:00:00Z")
+		serviceMock.On("ValidateBalance", mock.Anything, mock.Anything, mock.Anything).Return(validationReport, nil)
+
+		handler := localHttp.NewMigrationHandler(log, serviceMock,
+			mocks.NewMigrationJobServiceMock(), mocks.NewMigrationProfileServiceMock(), fileLimits)
+		err := handler.ValidateMigrationCSV(ctx)
+
+		var response migration.ValidationReport
+		_ = json.Unmarshal(rec.Body.Bytes(), &response)
+
+		assert.Nil(t, err)
+		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.Equal(t, validationReport, response)
+	})
+
+	t.Run("it returns an error for a file that is not csv nor json", func(t *testing.T) {
+		serviceMock := mocks.NewMigrationServiceMock()
+
+		rec, ctx := createMultipartFile(t, "test.csv", "\x89PNG\r\n\x1a\n\x00\x00")
+
+		handler := localHttp.NewMigrationHandler(log, serviceMock,
+			mocks.NewMigrationJobServiceMock(), mocks.NewMigrationProfileServiceMock(), fileLimits)
+		err := handler.ValidateMigrationCSV(ctx)
+
+		assert.Nil(t, err)
+		assert.Equal(t, http.StatusBadRequest, rec.Code)
+		serviceMock.AssertNotCalled(t, "ValidateBalance", mock.Anything, mock.Anything, mock.Anything)
+	})
+
+	t.Run("it returns internal server error when the service fails", func(t *testing.T) {
+		serviceMock := mocks.NewMigrationServiceMock()
+
+		rec, ctx := createMultipartFile(t, "test.csv", "1,1,100,2023-09-14T20:00:00Z")
+		serviceMock.On("ValidateBalance", mock.Anything, mock.Anything, mock.Anything).
+			Return(migration.ValidationReport{}, errors.New("error finding users: repository error"))
+
+		handler := localHttp.NewMigrationHandler(log, serviceMock,
+			mocks.NewMigrationJobServiceMock(), mocks.NewMigrationProfileServiceMock(), fileLimits)
+		err := handler.ValidateMigrationCSV(ctx)
+
 		assert.Nil(t, err)
 		assert.Equal(t, http.StatusInternalServerError, rec.Code)
-		serviceMock.AssertCalled(t, "ProcessBalance", mock.Anything, mock.Anything)
 	})
 }
 
+func TestMigrationHandler_GetMigrationRejects(t *testing.T) {
+	log := logger.NewLogger()
+
+	t.Run("it downloads the rejected records as CSV", func(t *testing.T) {
+		jobServiceMock := mocks.NewMigrationJobServiceMock()
+		rejects := []migration.Reject{
+			{Line: 3, Raw: "2,9,10,2024-09-13T10:00:00Z", Reason: migration.RejectReasonUnknownUser, Detail: "user not found"},
+			{Line: 4, Raw: "3,\"1", Reason: migration.RejectReasonValidation, Detail: "bare quote"},
+		}
+
+		context, rec := httpserver.SetupAsRecorderWithIDField(http.MethodGet, "/migrations", "1", "", "job_id")
+		jobServiceMock.On("ForEachReject", mock.Anything, "1", mock.Anything).Return(rejects, nil)
+
+		handler := localHttp.NewMigrationHandler(log, mocks.NewMigrationServiceMock(),
+			jobServiceMock, mocks.NewMigrationProfileServiceMock(), fileLimits)
+		err := handler.GetMigrationRejects(context)
+
+		assert.Nil(t, err)
+		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.Equal(t, "text/csv", rec.Header().Get("Content-Type"))
+		assert.Equal(t, "line,reason,detail,raw\n"+
+			"3,unknown_user,user not found,\"2,9,10,2024-09-13T10:00:00Z\"\n"+
+			"4,validation,bare quote,\"3,\"\"1\"\n", rec.Body.String())
+	})
+
+	t.Run("it returns only the header when there are no rejects", func(t *testing.T) {
+		jobServiceMock := mocks.NewMigrationJobServiceMock()
+
+		context, rec := httpserver.SetupAsRecorderWithIDField(http.MethodGet, "/migrations", "1", "", "job_id")
+		jobServiceMock.On("ForEachReject", mock.Anything, "1", mock.Anything).Return([]migration.Reject{}, nil)
+
+		handler := localHttp.NewMigrationHandler(log, mocks.NewMigrationServiceMock(),
+			jobServiceMock, mocks.NewMigrationProfileServiceMock(), fileLimits)
+		err := handler.GetMigrationRejects(context)
+
+		assert.Nil(t, err)
+		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.Equal(t, "line,reason,detail,raw\n", rec.Body.String())
+	})
+
+	t.Run("it returns not found when the job does not exist", func(t *testing.T) {
+		jobServiceMock := mocks.NewMigrationJobServiceMock()
+
+		context, rec := httpserver.SetupAsRecorderWithIDField(http.MethodGet, "/migrations", "1", "", "job_id")
+		jobServiceMock.On("ForEachReject", mock.Anything, "1", mock.Anything).
+			Return([]migration.Reject{}, errors.New(migration.NotFoundError))
+
+		handler := localHttp.NewMigrationHandler(log, mocks.NewMigrationServiceMock(),
+			jobServiceMock, mocks.NewMigrationProfileServiceMock(), fileLimits)
+		err := handler.GetMigrationRejects(context)
+
+		assert.Nil(t, err)
+		assert.Equal(t, http.StatusNotFound, rec.Code)
+	})
+}
+
+func TestMigrationHandler_GetMigrationJob(t *testing.T) {
+	log := logger.NewLogger()
+
+	t.Run("it gets the migration job successfully", func(t *testing.T) {
+		jobServiceMock := mocks.NewMigrationJobServiceMock()
+		job := migration.Job{ID: "1", Status: migration.StatusRunning,
+			Progress: migration.Progress{RowsValidated: 10, RowsInserted: 5, BatchesDone: 1, BatchesTotal: 2}}
+
+		context, rec := httpserver.SetupAsRecorderWithIDField(http.MethodGet, "/migrations", "1", "", "job_id")
+		jobServiceMock.On("GetJob", mock.Anything, "1").Return(job, nil)
+
+		handler := localHttp.NewMigrationHandler(log, mocks.NewMigrationServiceMock(),
+			jobServiceMock, mocks.NewMigrationProfileServiceMock(), fileLimits)
+		err := handler.GetMigrationJob(context)
+
+		var response migration.Job
+		_ = json.Unmarshal(rec.Body.Bytes(), &response)
+
+		assert.Nil(t, err)
+		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.Equal(t, job.Progress, response.Progress)
+	})
+
+	t.Run("it returns bad request for a non numeric job id", func(t *testing.T) {
+		jobServiceMock := mocks.NewMigrationJobServiceMock()
+
+		context, rec := httpserver.SetupAsRecorderWithIDField(http.MethodGet, "/migrations", "abc", "", "job_id")
+
+		handler := localHttp.NewMigrationHandler(log, mocks.NewMigrationServiceMock(),
+			jobServiceMock, mocks.NewMigrationProfileServiceMock(), fileLimits)
+		err := handler.GetMigrationJob(context)
+
+		assert.Nil(t, err)
+		assert.Equal(t, http.StatusBadRequest, rec.Code)
+	})
+
+	t.Run("it returns not found when the job does not exist", func(t *testing.T) {
+		jobServiceMock := mocks.NewMigrationJobServiceMock()
+
+		context, rec := httpserver.SetupAsRecorderWithIDField(http.MethodGet, "/migrations", "1", "", "job_id")
+		jobServiceMock.On("GetJob", mock.Anything, "1").Return(migration.Job{}, errors.New(migration.NotFoundError))
+
+		handler := localHttp.NewMigrationHandler(log, mocks.NewMigrationServiceMock(),
+			jobServiceMock, mocks.NewMigrationProfileServiceMock(), fileLimits)
+		err := handler.GetMigrationJob(context)
+
+		assert.Nil(t, err)
+		assert.Equal(t, http.StatusNotFound, rec.Code)
+	})
+
+	t.Run("it returns internal server error when service fails", func(t *testing.T) {
+		jobServiceMock := mocks.NewMigrationJobServiceMock()
+
+		context, rec := httpserver.SetupAsRecorderWithIDField(http.MethodGet, "/migrations", "1", "", "job_id")
+		jobServiceMock.On("GetJob", mock.Anything, "1").Return(migration.Job{}, errors.New("repository error"))
+
+		handler := localHttp.NewMigrationHandler(log, mocks.NewMigrationServiceMock(),
+			jobServiceMock, mocks.NewMigrationProfileServiceMock(), fileLimits)
+		err := handler.GetMigrationJob(context)
+
+		assert.Nil(t, err)
+		assert.Equal(t, http.StatusInternalServerError, rec.Code)
+	})
+}
+
+func TestMigrationHandler_ListMigrations(t *testing.T) {
+	log := logger.NewLogger()
+
+	t.Run("it lists the migrations with the default limit", func(t *testing.T) {
+		jobServiceMock := mocks.NewMigrationJobServiceMock()
+		expectedPage := migration.ListPage{Migrations: []migration.Job{{ID: "2", Status: migration.StatusCompleted,
+			Checksum: "abc", UploadedBy: "ops@example.com"}}, NextCursor: "next"}
+
+		context, rec := httpserver.SetupAsRecorder(http.MethodGet, "/migrations", "", "")
+		jobServiceMock.On("ListJobs", mock.Anything, migration.ListOptions{Limit: migration.DefaultListLimit}).
+			Return(expectedPage, nil)
+
+		handler := localHttp.NewMigrationHandler(log, mocks.NewMigrationServiceMock(), jobServiceMock,
+			mocks.NewMigrationProfileServiceMock(), fileLimits)
+		err := handler.ListMigrations(context)
+
+		var response migration.ListPage
+		_ = json.Unmarshal(rec.Body.Bytes(), &response)
+
+		assert.Nil(t, err)
+		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.Equal(t, expectedPage, response)
+	})
+
+	t.Run("it lists the migrations after the cursor", func(t *testing.T) {
+		jobServiceMock := mocks.NewMigrationJobServiceMock()
+		cursor := migration.Cursor{ID: "5"}
+		expectedOptions := migration.ListOptions{Limit: 10, After: &cursor}
+
+		context, rec := httpserver.SetupAsRecorder(http.MethodGet, "/migrations?limit=10&cursor="+cursor.Encode(), "", "")
+		jobServiceMock.On("ListJobs", mock.Anything, expectedOptions).Return(migration.ListPage{}, nil)
+
+		handler := localHttp.NewMigrationHandler(log, mocks.NewMigrationServiceMock(), jobServiceMock,
+			mocks.NewMigrationProfileServiceMock(), fileLimits)
+		err := handler.ListMigrations(context)
+
+		assert.Nil(t, err)
+		assert.Equal(t, http.StatusOK, rec.Code)
+		jobServiceMock.AssertCalled(t, "ListJobs", mock.Anything, expectedOptions)
+	})
+
+	t.Run("it returns bad request for an invalid limit or cursor", func(t *testing.T) {
+		for _, target := range []string{"/migrations?limit=0", "/migrations?limit=501",
+			"/migrations?cursor=" + migration.Cursor{ID: "abc"}.Encode()} {
+			jobServiceMock := mocks.NewMigrationJobServiceMock()
+
+			context, rec := httpserver.SetupAsRecorder(http.MethodGet, target, "", "")
+
+			handler := localHttp.NewMigrationHandler(log, mocks.NewMigrationServiceMock(), jobServiceMock,
+				mocks.NewMigrationProfileServiceMock(), fileLimits)
+			err := handler.ListMigrations(context)
+
+			assert.Nil(t, err)
+			assert.Equal(t, http.StatusBadRequest, rec.Code, target)
+			jobServiceMock.AssertNotCalled(t, "ListJobs", mock.Anything, mock.Anything)
+		}
+	})
+}
+
+func TestMigrationHandler_GetMigrationTransactions(t *testing.T) {
+	log := logger.NewLogger()
+
+	t.Run("it lists the transactions of the migration", func(t *testing.T) {
+		jobServiceMock := mocks.NewMigrationJobServiceMock()
+		expectedPage := transaction.ListPage{Transactions: []transaction.ListItem{
+			{Transaction: transaction.Transaction{ID: "tx-1", UserID: "1", Amount: 100, MigrationID: "1"}},
+		}}
+
+		context, rec := httpserver.SetupAsRecorderWithIDField(http.MethodGet, "/migrations", "1", "", "job_id")
+		jobServiceMock.On("ListTransactions", mock.Anything, "1",
+			migration.ListOptions{Limit: migration.DefaultListLimit}).Return(expectedPage, nil)
+
+		handler := localHttp.NewMigrationHandler(log, mocks.NewMigrationServiceMock(), jobServiceMock,
+			mocks.NewMigrationProfileServiceMock(), fileLimits)
+		err := handler.GetMigrationTransactions(context)
+
+		var response transaction.ListPage
+		_ = json.Unmarshal(rec.Body.Bytes(), &response)
+
+		assert.Nil(t, err)
+		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.Equal(t, expectedPage, response)
+	})
+
+	t.Run("it returns not found when the migration does not exist", func(t *testing.T) {
+		jobServiceMock := mocks.NewMigrationJobServiceMock()
+
+		context, rec := httpserver.SetupAsRecorderWithIDField(http.MethodGet, "/migrations", "1", "", "job_id")
+		jobServiceMock.On("ListTransactions", mock.Anything, "1", mock.Anything).
+			Return(transaction.ListPage{}, errors.New(migration.NotFoundError))
+
+		handler := localHttp.NewMigrationHandler(log, mocks.NewMigrationServiceMock(), jobServiceMock,
+			mocks.NewMigrationProfileServiceMock(), fileLimits)
+		err := handler.GetMigrationTransactions(context)
+
+		assert.Nil(t, err)
+		assert.Equal(t, http.StatusNotFound, rec.Code)
+	})
+
+	t.Run("it returns bad request for an invalid cursor", func(t *testing.T) {
+		jobServiceMock := mocks.NewMigrationJobServiceMock()
+
+		context, rec := httpserver.SetupAsRecorderWithIDField(http.MethodGet, "/migrations?cursor=broken", "1", "",
+			"job_id")
+
+		handler := localHttp.NewMigrationHandler(log, mocks.NewMigrationServiceMock(), jobServiceMock,
+			mocks.NewMigrationProfileServiceMock(), fileLimits)
+		err := handler.GetMigrationTransactions(context)
+
+		assert.Nil(t, err)
+		assert.Equal(t, http.StatusBadRequest, rec.Code)
+		jobServiceMock.AssertNotCalled(t, "ListTransactions", mock.Anything, mock.Anything, mock.Anything)
+	})
+}
+
+func TestMigrationHandler_RollbackMigration(t *testing.T) {
+	log := logger.NewLogger()
+
+	t.Run("it rolls back the migration and returns it", func(t *testing.T) {
+		jobServiceMock := mocks.NewMigrationJobServiceMock()
+		job := migration.Job{ID: "1", Status: migration.StatusRolledBack}
+
+		context, rec := httpserver.SetupAsRecorderWithIDField(http.MethodPost, "/migrations", "1", "", "job_id")
+		jobServiceMock.On("Rollback", mock.Anything, "1").Return(job, nil)
+
+		handler := localHttp.NewMigrationHandler(log, mocks.NewMigrationServiceMock(), jobServiceMock,
+			mocks.NewMigrationProfileServiceMock(), fileLimits)
+		err := handler.RollbackMigration(context)
+
+		var response migration.Job
+		_ = json.Unmarshal(rec.Body.Bytes(), &response)
+
+		assert.Nil(t, err)
+		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.Equal(t, migration.StatusRolledBack, response.Status)
+	})
+
+	t.Run("it returns conflict when the migration can't be rolled back", func(t *testing.T) {
+		for _, rollbackError := range []string{migration.RollbackEditedError, migration.RollbackStatusError} {
+			jobServiceMock := mocks.NewMigrationJobServiceMock()
+
+			context, rec := httpserver.SetupAsRecorderWithIDField(http.MethodPost, "/migrations", "1", "", "job_id")
+			jobServiceMock.On("Rollback", mock.Anything, "1").Return(migration.Job{}, errors.New(rollbackError))
+
+			handler := localHttp.NewMigrationHandler(log, mocks.NewMigrationServiceMock(), jobServiceMock,
+				mocks.NewMigrationProfileServiceMock(), fileLimits)
+			err := handler.RollbackMigration(context)
+
+			assert.Nil(t, err)
+			assert.Equal(t, http.StatusConflict, rec.Code, rollbackError)
+		}
+	})
+
+	t.Run("it returns not found when the migration does not exist", func(t *testing.T) {
+		jobServiceMock := mocks.NewMigrationJobServiceMock()
+
+		context, rec := httpserver.SetupAsRecorderWithIDField(http.MethodPost, "/migrations", "1", "", "job_id")
+		jobServiceMock.On("Rollback", mock.Anything, "1").Return(migration.Job{}, errors.New(migration.NotFoundError))
+
+		handler := localHttp.NewMigrationHandler(log, mocks.NewMigrationServiceMock(), jobServiceMock,
+			mocks.NewMigrationProfileServiceMock(), fileLimits)
+		err := handler.RollbackMigration(context)
+
+		assert.Nil(t, err)
+		assert.Equal(t, http.StatusNotFound, rec.Code)
+	})
+}
+
+func TestMigrationHandler_UploadMigrationCSVWithProfile(t *testing.T) {
+	log := logger.NewLogger()
+	profile := migration.MappingProfile{
+		Name:           "partner",
+		Columns:        map[string][]string{migration.FieldUserID: {"customer"}},
+		IgnoredColumns: []string{"notes"},
+	}
+	content := "customer,notes,id,amount,datetime\n1,first,1,100,2023-09-14T20:00:00Z"
+
+	t.Run("it processes the CSV with the profile of the form", func(t *testing.T) {
+		jobServiceMock := mocks.NewMigrationJobServiceMock()
+		profileServiceMock := mocks.NewMigrationProfileServiceMock()
+
+		rec, ctx := createMultipartForm(t, "test.csv", content, map[string]string{"profile": "partner"})
+
+		profileServiceMock.On("GetProfile", mock.Anything, "partner").Return(profile, nil)
+		jobServiceMock.On("RunMigration", mock.Anything, mock.Anything,
+			mock.MatchedBy(func(options migration.Options) bool {
+				return options.Mode == migration.ModeDefault && options.Profile != nil &&
+					options.Profile.Name == profile.Name
+			})).Return(migration.Job{ID: "1", Status: migration.StatusCompleted}, nil)
+
+		handler := localHttp.NewMigrationHandler(log, mocks.NewMigrationServiceMock(), jobServiceMock,
+			profileServiceMock, fileLimits)
+		err := handler.UploadMigrationCSV(ctx)
+
+		assert.Nil(t, err)
+		assert.Equal(t, http.StatusOK, rec.Code)
+	})
+
+	t.Run("it returns bad request when the profile does not exist", func(t *testing.T) {
+		jobServiceMock := mocks.NewMigrationJobServiceMock()
+		profileServiceMock := mocks.NewMigrationProfileServiceMock()
+
+		rec, ctx := createMultipartForm(t, "test.csv", content, map[string]string{"profile": "missing"})
+
+		profileServiceMock.On("GetProfile", mock.Anything, "missing").
+			Return(migration.MappingProfile{}, errors.New(migration.ProfileNotFoundError))
+
+		handler := localHttp.NewMigrationHandler(log, mocks.NewMigrationServiceMock(), jobServiceMock,
+			profileServiceMock, fileLimits)
+		err := handler.UploadMigrationCSV(ctx)
+
+		assert.Nil(t, err)
+		assert.Equal(t, http.StatusBadRequest, rec.Code)
+		jobServiceMock.AssertNotCalled(t, "RunMigration", mock.Anything, mock.Anything, mock.Anything)
+	})
+
+	t.Run("it returns bad request for extra columns without the field names nor a profile", func(t *testing.T) {
+		serviceMock := mocks.NewMigrationServiceMock()
+
+		rec, ctx := createMultipartFile(t, "test.csv", content)
+
+		handler := localHttp.NewMigrationHandler(log, serviceMock,
+			mocks.NewMigrationJobServiceMock(), mocks.NewMigrationProfileServiceMock(), fileLimits)
+		err := handler.UploadMigrationCSV(ctx)
+
+		assert.Nil(t, err)
+		assert.Equal(t, http.StatusBadRequest, rec.Code)
+	})
+
+	t.Run("it maps the columns by name when the header has the field names", func(t *testing.T) {
+		jobServiceMock := mocks.NewMigrationJobServiceMock()
+
+		rec, ctx := createMultipartFile(t, "test.csv", "amount,id,datetime,user_id\n100,1,2023-09-14T20:00:00Z,1")
+
+		jobServiceMock.On("RunMigration", mock.Anything, mock.Anything,
+			mock.MatchedBy(func(options migration.Options) bool {
+				return options.Profile != nil
+			})).Return(migration.Job{ID: "1", Status: migration.StatusCompleted}, nil)
+
+		handler := localHttp.NewMigrationHandler(log, mocks.NewMigrationServiceMock(), jobServiceMock,
+			mocks.NewMigrationProfileServiceMock(), fileLimits)
+		err := handler.UploadMigrationCSV(ctx)
+
+		assert.Nil(t, err)
+		assert.Equal(t, http.StatusOK, rec.Code)
+	})
+
+	t.Run("it maps the columns by name skipping the ones that are not fields", func(t *testing.T) {
+		jobServiceMock := mocks.NewMigrationJobServiceMock()
+
+		rec, ctx := createMultipartFile(t, "test.csv", "amount,notes,id,datetime,user_id\n100,first,1,2023-09-14T20:00:00Z,1")
+
+		jobServiceMock.On("RunMigration", mock.Anything, mock.Anything,
+			mock.MatchedBy(func(options migration.Options) bool {
+				return options.Profile != nil && !options.Profile.Strict
+			})).Return(migration.Job{ID: "1", Status: migration.StatusCompleted}, nil)
+
+		handler := localHttp.NewMigrationHandler(log, mocks.NewMigrationServiceMock(), jobServiceMock,
+			mocks.NewMigrationProfileServiceMock(), fileLimits)
+		err := handler.UploadMigrationCSV(ctx)
+
+		assert.Nil(t, err)
+		assert.Equal(t, http.StatusOK, rec.Code)
+	})
+}
+
+func TestMigrationHandler_UploadMigrationJSON(t *testing.T) {
+	log := logger.NewLogger()
+	content := `{"id":1,"user_id":1,"amount":100,"date_time":"2023-09-14T20:00:00Z"}` + "\n"
+
+	t.Run("it processes a JSON Lines file with the ndjson format", func(t *testing.T) {
+		jobServiceMock := mocks.NewMigrationJobServiceMock()
+
+		rec, ctx := createMultipartFile(t, "test.ndjson", content)
+
+		jobServiceMock.On("RunMigration", mock.Anything, mock.Anything,
+			mock.MatchedBy(func(options migration.Options) bool {
+				return options.Format == records.FormatNDJSON && options.Profile == nil
+			})).Return(migration.Job{ID: "1", Status: migration.StatusCompleted}, nil)
+
+		handler := localHttp.NewMigrationHandler(log, mocks.NewMigrationServiceMock(), jobServiceMock,
+			mocks.NewMigrationProfileServiceMock(), fileLimits)
+		err := handler.UploadMigrationCSV(ctx)
+
+		assert.Nil(t, err)
+		assert.Equal(t, http.StatusOK, rec.Code)
+	})
+
+	t.Run("it picks the format by the extension before the content", func(t *testing.T) {
+		jobServiceMock := mocks.NewMigrationJobServiceMock()
+
+		// A JSON document starting with an object sniffs as JSON Lines
+		rec, ctx := createMultipartFile(t, "test.json", content)
+
+		jobServiceMock.On("RunMigration", mock.Anything, mock.Anything,
+			mock.MatchedBy(func(options migration.Options) bool {
+				return options.Format == records.FormatJSON
+			})).Return(migration.Job{ID: "1", Status: migration.StatusCompleted}, nil)
+
+		handler := localHttp.NewMigrationHandler(log, mocks.NewMigrationServiceMock(), jobServiceMock,
+			mocks.NewMigrationProfileServiceMock(), fileLimits)
+		err := handler.UploadMigrationCSV(ctx)
+
+		assert.Nil(t, err)
+		assert.Equal(t, http.StatusOK, rec.Code)
+	})
+
+	t.Run("it picks the ndjson extension for lines that start with an array", func(t *testing.T) {
+		jobServiceMock := mocks.NewMigrationJobServiceMock()
+
+		rec, ctx := createMultipartFile(t, "test.ndjson", `[{"id":1}]`+"\n"+content)
+
+		jobServiceMock.On("RunMigration", mock.Anything, mock.Anything,
+			mock.MatchedBy(func(options migration.Options) bool {
+				return options.Format == records.FormatNDJSON
+			})).Return(migration.Job{ID: "1", Status: migration.StatusCompleted}, nil)
+
+		handler := localHttp.NewMigrationHandler(log, mocks.NewMigrationServiceMock(), jobServiceMock,
+			mocks.NewMigrationProfileServiceMock(), fileLimits)
+		err := handler.UploadMigrationCSV(ctx)
+
+		assert.Nil(t, err)
+		assert.Equal(t, http.StatusOK, rec.Code)
+	})
+
+	t.Run("it picks the format by the content type when the name has no extension", func(t *testing.T) {
+		jobServiceMock := mocks.NewMigrationJobServiceMock()
+
+		rec, ctx := createMultipartFileWithType(t, "balances", "application/x-ndjson", `[{"id":1}]`+"\n"+content)
+
+		jobServiceMock.On("RunMigration", mock.Anything, mock.Anything,
+			mock.MatchedBy(func(options migration.Options) bool {
+				return options.Format == records.FormatNDJSON
+			})).Return(migration.Job{ID: "1", Status: migration.StatusCompleted}, nil)
+
+		handler := localHttp.NewMigrationHandler(log, mocks.NewMigrationServiceMock(), jobServiceMock,
+			mocks.NewMigrationProfileServiceMock(), fileLimits)
+		err := handler.UploadMigrationCSV(ctx)
+
+		assert.Nil(t, err)
+		assert.Equal(t, http.StatusOK, rec.Code)
+	})
+
+	t.Run("it sniffs the content when neither the name nor the content type declare the format", func(t *testing.T) {
+		jobServiceMock := mocks.NewMigrationJobServiceMock()
+
+		rec, ctx := createMultipartFile(t, "balances.txt", content)
+
+		jobServiceMock.On("RunMigration", mock.Anything, mock.Anything,
+			mock.MatchedBy(func(options migration.Options) bool {
+				return options.Format == records.FormatNDJSON
+			})).Return(migration.Job{ID: "1", Status: migration.StatusCompleted}, nil)
+
+		handler := localHttp.NewMigrationHandler(log, mocks.NewMigrationServiceMock(), jobServiceMock,
+			mocks.NewMigrationProfileServiceMock(), fileLimits)
+		err := handler.UploadMigrationCSV(ctx)
+
+		assert.Nil(t, err)
+		assert.Equal(t, http.StatusOK, rec.Code)
+	})
+
+	t.Run("it returns bad request when a profile is given for a JSON file", func(t *testing.T) {
+		serviceMock := mocks.NewMigrationServiceMock()
+		profileServiceMock := mocks.NewMigrationProfileServiceMock()
+
+		rec, ctx := createMultipartForm(t, "test.ndjson", content, map[string]string{"profile": "partner"})
+
+		handler := localHttp.NewMigrationHandler(log, serviceMock,
+			mocks.NewMigrationJobServiceMock(), profileServiceMock, fileLimits)
+		err := handler.UploadMigrationCSV(ctx)
+
+		assert.Nil(t, err)
+		assert.Equal(t, http.StatusBadRequest, rec.Code)
+		profileServiceMock.AssertNotCalled(t, "GetProfile", mock.Anything, mock.Anything)
+	})
+}
+
+func TestMigrationHandler_UploadCompressedMigration(t *testing.T) {
+	log := logger.NewLogger()
+	csvContent := "id,user_id,amount,datetime\n1,1,100,2023-09-14T20:00:00Z\n"
+
+	t.Run("it sniffs the format of a gzip file once decompressed", func(t *testing.T) {
+		jobServiceMock := mocks.NewMigrationJobServiceMock()
+
+		var compressed bytes.Buffer
+		gzipWriter := gzip.NewWriter(&compressed)
+		_, _ = gzipWriter.Write([]byte(`{"id":1,"user_id":1,"amount":100,"date_time":"2023-09-14T20:00:00Z"}`))
+		assert.NoError(t, gzipWriter.Close())
+
+		rec, ctx := createMultipartFile(t, "test.ndjson.gz", compressed.String())
+
+		jobServiceMock.On("RunMigration", mock.Anything, mock.Anything,
+			mock.MatchedBy(func(options migration.Options) bool {
+				return options.Format == records.FormatNDJSON
+			})).Return(migration.Job{ID: "1", Status: migration.StatusCompleted}, nil)
+
+		handler := localHttp.NewMigrationHandler(log, mocks.NewMigrationServiceMock(), jobServiceMock,
+			mocks.NewMigrationProfileServiceMock(), fileLimits)
+		err := handler.UploadMigrationCSV(ctx)
+
+		assert.Nil(t, err)
+		assert.Equal(t, http.StatusOK, rec.Code)
+	})
+
+	t.Run("it reads the header of the first csv of a zip file", func(t *testing.T) {
+		jobServiceMock := mocks.NewMigrationJobServiceMock()
+
+		var compressed bytes.Buffer
+		zipWriter := zip.NewWriter(&compressed)
+		entry, err := zipWriter.Create("balances.csv")
+		assert.NoError(t, err)
+		_, _ = entry.Write([]byte(csvContent))
+		assert.NoError(t, zipWriter.Close())
+
+		rec, ctx := createMultipartFile(t, "test.zip", compressed.String())
+
+		jobServiceMock.On("RunMigration", mock.Anything, mock.Anything,
+			mock.MatchedBy(func(options migration.Options) bool {
+				return options.Format == records.FormatCSV && options.Profile != nil
+			})).Return(migration.Job{ID: "1", Status: migration.StatusCompleted}, nil)
+
+		handler := localHttp.NewMigrationHandler(log, mocks.NewMigrationServiceMock(), jobServiceMock,
+			mocks.NewMigrationProfileServiceMock(), fileLimits)
+		err = handler.UploadMigrationCSV(ctx)
+
+		assert.Nil(t, err)
+		assert.Equal(t, http.StatusOK, rec.Code)
+	})
+
+	t.Run("it returns bad request for a zip file without csv files", func(t *testing.T) {
+		serviceMock := mocks.NewMigrationServiceMock()
+
+		var compressed bytes.Buffer
+		zipWriter := zip.NewWriter(&compressed)
+		_, err := zipWriter.Create("balances.xlsx")
+		assert.NoError(t, err)
+		assert.NoError(t, zipWriter.Close())
+
+		rec, ctx := createMultipartFile(t, "test.zip", compressed.String())
+
+		handler := localHttp.NewMigrationHandler(log, serviceMock,
+			mocks.NewMigrationJobServiceMock(), mocks.NewMigrationProfileServiceMock(), fileLimits)
+		err = handler.UploadMigrationCSV(ctx)
+
+		assert.Nil(t, err)
+		assert.Equal(t, http.StatusBadRequest, rec.Code)
+	})
+
+	t.Run("it refuses a gzip bomb without line breaks once the header limit is read", func(t *testing.T) {
+		jobServiceMock := mocks.NewMigrationJobServiceMock()
+
+		var compressed bytes.Buffer
+		gzipWriter := gzip.NewWriter(&compressed)
+		chunk := bytes.Repeat([]byte("a"), 1<<20)
+		for range 64 {
+			_, _ = gzipWriter.Write(chunk)
+		}
+		assert.NoError(t, gzipWriter.Close())
+
+		rec, ctx := createMultipartFile(t, "test.csv.gz", compressed.String())
+
+		handler := localHttp.NewMigrationHandler(log, mocks.NewMigrationServiceMock(), jobServiceMock,
+			mocks.NewMigrationProfileServiceMock(), decompress.Limits{})
+		err := handler.UploadMigrationCSV(ctx)
+
+		assert.Nil(t, err)
+		assert.Equal(t, http.StatusBadRequest, rec.Code)
+		assert.Contains(t, rec.Body.String(), "the header is longer than 65536 bytes")
+		jobServiceMock.AssertNotCalled(t, "RunMigration", mock.Anything, mock.Anything, mock.Anything)
+	})
+
+	t.Run("it sniffs a compressed file within the configured limits", func(t *testing.T) {
+		jobServiceMock := mocks.NewMigrationJobServiceMock()
+
+		var compressed bytes.Buffer
+		gzipWriter := gzip.NewWriter(&compressed)
+		_, _ = gzipWriter.Write([]byte(strings.Repeat("a,", 512) + "a\n"))
+		assert.NoError(t, gzipWriter.Close())
+
+		rec, ctx := createMultipartFile(t, "test.csv.gz", compressed.String())
+
+		handler := localHttp.NewMigrationHandler(log, mocks.NewMigrationServiceMock(), jobServiceMock,
+			mocks.NewMigrationProfileServiceMock(), decompress.Limits{MaxSize: 512})
+		err := handler.UploadMigrationCSV(ctx)
+
+		assert.Nil(t, err)
+		assert.Equal(t, http.StatusBadRequest, rec.Code)
+		assert.Contains(t, rec.Body.String(), "the decompressed file is larger than 512 bytes")
+		jobServiceMock.AssertNotCalled(t, "RunMigration", mock.Anything, mock.Anything, mock.Anything)
+	})
+
+	t.Run("it returns bad request for a corrupted gzip file", func(t *testing.T) {
+		serviceMock := mocks.NewMigrationServiceMock()
+
+		rec, ctx := createMultipartFile(t, "test.csv.gz", "\x1f\x8b\x08\x00broken")
+
+		handler := localHttp.NewMigrationHandler(log, serviceMock,
+			mocks.NewMigrationJobServiceMock(), mocks.NewMigrationProfileServiceMock(), fileLimits)
+		err := handler.UploadMigrationCSV(ctx)
+
+		assert.Nil(t, err)
+		assert.Equal(t, http.StatusBadRequest, rec.Code)
+	})
+}
+
+// fileLimits are the decompression limits of the handlers under test
+var fileLimits = decompress.Limits{MaxSize: 1 << 20, MaxEntries: 10}
+
 func createMultipartFile(t *testing.T, filename string, content string) (*httptest.ResponseRecorder, echo.Context) {
+	return createMultipartForm(t, filename, content, nil)
+}
+
+// createMultipartFileWithType sets the content type of the file part, which is application/octet-stream otherwise
+func createMultipartFileWithType(t *testing.T, filename, contentType, content string) (*httptest.ResponseRecorder,
+	echo.Context) {
+	e := echo.New()
+	body := new(bytes.Buffer)
+	writer := multipart.NewWriter(body)
+
+	header := make(textproto.MIMEHeader)
+	header.Set("Content-Disposition", fmt.Sprintf(`form-data; name="file"; filename=%q`, filename))
+	header.Set(echo.HeaderContentType, contentType)
+	part, err := writer.CreatePart(header)
+	assert.NoError(t, err)
+
+	_, err = part.Write([]byte(content))
+	assert.NoError(t, err)
+	writer.Close()
+
+	req := httptest.NewRequest(http.MethodPost, "/", body)
+	req.Header.Set(echo.HeaderContentType, writer.FormDataContentType())
+	rec := httptest.NewRecorder()
+
+	return rec, e.NewContext(req, rec)
+}
+
+func createMultipartForm(t *testing.T, filename string, content string,
+	fields map[string]string) (*httptest.ResponseRecorder, echo.Context) {
 	e := echo.New()
 	body := new(bytes.Buffer)
 	writer := multipart.NewWriter(body)
@@ -154,6 +1156,10 @@ func createMultipartFile(t *testing.T, filename string, content string) (*httpte
 	_, err = part.Write([]byte(content))
 	assert.NoError(t, err)
 
+	for name, value := range fields {
+		assert.NoError(t, writer.WriteField(name, value))
+	}
+
 	writer.Close()
 
 	req := httptest.NewRequest(http.MethodPost, "/", body)
@@ -0,0 +1,172 @@
+package http
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/labstack/echo/v4"
+	"github.com/sebastianreh/user-balance-api/cmd/httpserver/exceptions"
+	"github.com/sebastianreh/user-balance-api/internal/app/services"
+	"github.com/sebastianreh/user-balance-api/internal/domain/migration"
+	"github.com/sebastianreh/user-balance-api/pkg/logger"
+	customStr "github.com/sebastianreh/user-balance-api/pkg/strings"
+)
+
+const (
+	migrationProfileHandlerName = "MigrationProfileHandler"
+)
+
+type MigrationProfileHandler struct {
+	log     logger.Logger
+	service services.MigrationProfileService
+}
+
+func NewMigrationProfileHandler(log logger.Logger, service services.MigrationProfileService) *MigrationProfileHandler {
+	return &MigrationProfileHandler{
+		log:     log,
+		service: service,
+	}
+}
+
+// CreateMigrationProfile godoc
+// @Summary Create a mapping profile
+// @Description Stores a named profile telling which header names hold the id, user_id, amount and datetime of a
+// @Description migration file, which columns are ignored and the constant value of the fields without a column.
+// @Description Other columns are skipped unless the profile is strict
+// @Tags Migration
+// @Accept json
+// @Produce json
+// @Param profile body migration.MappingProfile true "Mapping profile"
+// @Success 201 {object} migration.MappingProfile "Created mapping profile"
+// @Failure 400 {object} exceptions.BadRequestException "Invalid profile"
+// @Failure 409 {object} exceptions.DuplicatedException "Profile name already in use"
+// @Failure 500 {object} exceptions.InternalServerException "Internal server error"
+// @Router /migration-profiles [post]
+func (h *MigrationProfileHandler) CreateMigrationProfile(ctx echo.Context) error {
+	profile, err := validateProfileRequest(ctx)
+	if err != nil {
+		h.log.ErrorAt(err, migrationProfileHandlerName, "CreateMigrationProfile")
+		exception := exceptions.NewBadRequestException(err.Error())
+		return ctx.JSON(exception.Code(), exception)
+	}
+
+	profile, err = h.service.CreateProfile(ctx.Request().Context(), profile)
+	if err != nil {
+		if strings.Contains(err.Error(), migration.DuplicateProfileError) {
+			exception := exceptions.NewDuplicatedException(err.Error())
+			return ctx.JSON(exception.Code(), exception)
+		}
+
+		exception := exceptions.NewInternalServerException(err.Error())
+		return ctx.JSON(exception.Code(), exception)
+	}
+
+	return ctx.JSON(http.StatusCreated, profile)
+}
+
+// GetMigrationProfile godoc
+// @Summary Get a mapping profile
+// @Description Get a mapping profile by its name
+// @Tags Migration
+// @Produce json
+// @Param name path string true "Profile name"
+// @Success 200 {object} migration.MappingProfile "Mapping profile"
+// @Failure 400 {object} exceptions.BadRequestException "Missing profile name"
+// @Failure 404 {object} exceptions.NotFoundException "Mapping profile not found"
+// @Failure 500 {object} exceptions.InternalServerException "Internal server error"
+// @Router /migration-profiles/{name} [get]
+func (h *MigrationProfileHandler) GetMigrationProfile(ctx echo.Context) error {
+	name, err := validateProfileNameRequest(ctx)
+	if err != nil {
+		h.log.ErrorAt(err, migrationProfileHandlerName, "GetMigrationProfile")
+		exception := exceptions.NewBadRequestException(err.Error())
+		return ctx.JSON(exception.Code(), exception)
+	}
+
+	profile, err := h.service.GetProfile(ctx.Request().Context(), name)
+	if err != nil {
+		if strings.Contains(err.Error(), migration.ProfileNotFoundError) {
+			exception := exceptions.NewNotFoundException(err.Error())
+			return ctx.JSON(exception.Code(), exception)
+		}
+
+		exception := exceptions.NewInternalServerException(err.Error())
+		return ctx.JSON(exception.Code(), exception)
+	}
+
+	return ctx.JSON(http.StatusOK, profile)
+}
+
+// ListMigrationProfiles godoc
+// @Summary List mapping profiles
+// @Description List every mapping profile ordered by name
+// @Tags Migration
+// @Produce json
+// @Success 200 {array} migration.MappingProfile "Mapping profiles"
+// @Failure 500 {object} exceptions.InternalServerException "Internal server error"
+// @Router /migration-profiles [get]
+func (h *MigrationProfileHandler) ListMigrationProfiles(ctx echo.Context) error {
+	profiles, err := h.service.ListProfiles(ctx.Request().Context())
+	if err != nil {
+		exception := exceptions.NewInternalServerException(err.Error())
+		return ctx.JSON(exception.Code(), exception)
+	}
+
+	return ctx.JSON(http.StatusOK, profiles)
+}
+
+// DeleteMigrationProfile godoc
+// @Summary Delete a mapping profile
+// @Description Delete a mapping profile by its name, migrations already started keep using it
+// @Tags Migration
+// @Param name path string true "Profile name"
+// @Success 200 "No Content"
+// @Failure 400 {object} exceptions.BadRequestException "Missing profile name"
+// @Failure 404 {object} exceptions.NotFoundException "Mapping profile not found"
+// @Failure 500 {object} exceptions.InternalServerException "Internal server error"
+// @Router /migration-profiles/{name} [delete]
+func (h *MigrationProfileHandler) DeleteMigrationProfile(ctx echo.Context) error {
+	name, err := validateProfileNameRequest(ctx)
+	if err != nil {
+		h.log.ErrorAt(err, migrationProfileHandlerName, "DeleteMigrationProfile")
+		exception := exceptions.NewBadRequestException(err.Error())
+		return ctx.JSON(exception.Code(), exception)
+	}
+
+	err = h.service.DeleteProfile(ctx.Request().Context(), name)
+	if err != nil {
+		if strings.Contains(err.Error(), migration.ProfileNotFoundError) {
+			exception := exceptions.NewNotFoundException(err.Error())
+			return ctx.JSON(exception.Code(), exception)
+		}
+
+		exception := exceptions.NewInternalServerException(err.Error())
+		return ctx.JSON(exception.Code(), exception)
+	}
+
+	return ctx.NoContent(http.StatusOK)
+}
+
+func validateProfileRequest(ctx echo.Context) (migration.MappingProfile, error) {
+	var profile migration.MappingProfile
+	if err := ctx.Bind(&profile); err != nil {
+		return profile, errors.New("invalid request body")
+	}
+
+	profile.Name = strings.TrimSpace(profile.Name)
+	if err := profile.Validate(); err != nil {
+		return profile, err
+	}
+
+	return profile, nil
+}
+
+func validateProfileNameRequest(ctx echo.Context) (string, error) {
+	name := ctx.Param("name")
+	if customStr.IsEmpty(name) {
+		return name, errors.New("missing param name")
+	}
+
+	return name, nil
+}
@@ -0,0 +1,161 @@
+package http_test
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"testing"
+
+	"github.com/sebastianreh/user-balance-api/cmd/httpserver"
+	"github.com/sebastianreh/user-balance-api/internal/domain/migration"
+	localHttp "github.com/sebastianreh/user-balance-api/internal/interfaces/http"
+	"github.com/sebastianreh/user-balance-api/pkg/logger"
+	"github.com/sebastianreh/user-balance-api/test/mocks"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func TestMigrationProfileHandler_CreateMigrationProfile(t *testing.T) {
+	log := logger.NewLogger()
+	profile := migration.MappingProfile{
+		Name:           "partner-a",
+		Columns:        map[string][]string{migration.FieldUserID: {"customer"}},
+		IgnoredColumns: []string{"notes"},
+		Defaults:       map[string]string{migration.FieldDateTime: "2024-01-01T00:00:00Z"},
+	}
+
+	t.Run("it creates the profile", func(t *testing.T) {
+		serviceMock := mocks.NewMigrationProfileServiceMock()
+
+		requestBytes, _ := json.Marshal(profile)
+		context, rec := httpserver.SetupAsRecorder(http.MethodPost, "/migration-profiles", "", string(requestBytes))
+		serviceMock.On("CreateProfile", mock.Anything, profile).Return(profile, nil)
+
+		handler := localHttp.NewMigrationProfileHandler(log, serviceMock)
+		err := handler.CreateMigrationProfile(context)
+
+		var response migration.MappingProfile
+		_ = json.Unmarshal(rec.Body.Bytes(), &response)
+
+		assert.Nil(t, err)
+		assert.Equal(t, http.StatusCreated, rec.Code)
+		assert.Equal(t, profile.Name, response.Name)
+	})
+
+	t.Run("it returns bad request for an invalid profile", func(t *testing.T) {
+		serviceMock := mocks.NewMigrationProfileServiceMock()
+
+		context, rec := httpserver.SetupAsRecorder(http.MethodPost, "/migration-profiles", "",
+			`{"name": "partner", "columns": {"currency": ["cur"]}}`)
+
+		handler := localHttp.NewMigrationProfileHandler(log, serviceMock)
+		err := handler.CreateMigrationProfile(context)
+
+		assert.Nil(t, err)
+		assert.Equal(t, http.StatusBadRequest, rec.Code)
+		serviceMock.AssertNotCalled(t, "CreateProfile", mock.Anything, mock.Anything)
+	})
+
+	t.Run("it returns conflict when the name is taken", func(t *testing.T) {
+		serviceMock := mocks.NewMigrationProfileServiceMock()
+
+		requestBytes, _ := json.Marshal(profile)
+		context, rec := httpserver.SetupAsRecorder(http.MethodPost, "/migration-profiles", "", string(requestBytes))
+		serviceMock.On("CreateProfile", mock.Anything, profile).
+			Return(profile, errors.New(migration.DuplicateProfileError))
+
+		handler := localHttp.NewMigrationProfileHandler(log, serviceMock)
+		err := handler.CreateMigrationProfile(context)
+
+		assert.Nil(t, err)
+		assert.Equal(t, http.StatusConflict, rec.Code)
+	})
+}
+
+func TestMigrationProfileHandler_GetMigrationProfile(t *testing.T) {
+	log := logger.NewLogger()
+
+	t.Run("it returns the profile", func(t *testing.T) {
+		serviceMock := mocks.NewMigrationProfileServiceMock()
+		profile := migration.MappingProfile{Name: "partner"}
+
+		context, rec := httpserver.SetupAsRecorderWithIDField(http.MethodGet, "/migration-profiles", "partner", "",
+			"name")
+		serviceMock.On("GetProfile", mock.Anything, "partner").Return(profile, nil)
+
+		handler := localHttp.NewMigrationProfileHandler(log, serviceMock)
+		err := handler.GetMigrationProfile(context)
+
+		assert.Nil(t, err)
+		assert.Equal(t, http.StatusOK, rec.Code)
+	})
+
+	t.Run("it returns not found when the profile does not exist", func(t *testing.T) {
+		serviceMock := mocks.NewMigrationProfileServiceMock()
+
+		context, rec := httpserver.SetupAsRecorderWithIDField(http.MethodGet, "/migration-profiles", "missing", "",
+			"name")
+		serviceMock.On("GetProfile", mock.Anything, "missing").
+			Return(migration.MappingProfile{}, errors.New(migration.ProfileNotFoundError))
+
+		handler := localHttp.NewMigrationProfileHandler(log, serviceMock)
+		err := handler.GetMigrationProfile(context)
+
+		assert.Nil(t, err)
+		assert.Equal(t, http.StatusNotFound, rec.Code)
+	})
+}
+
+func TestMigrationProfileHandler_ListMigrationProfiles(t *testing.T) {
+	log := logger.NewLogger()
+
+	t.Run("it returns every profile", func(t *testing.T) {
+		serviceMock := mocks.NewMigrationProfileServiceMock()
+		profiles := []migration.MappingProfile{{Name: "partner-a"}, {Name: "partner-b"}}
+
+		context, rec := httpserver.SetupAsRecorder(http.MethodGet, "/migration-profiles", "", "")
+		serviceMock.On("ListProfiles", mock.Anything).Return(profiles, nil)
+
+		handler := localHttp.NewMigrationProfileHandler(log, serviceMock)
+		err := handler.ListMigrationProfiles(context)
+
+		var response []migration.MappingProfile
+		_ = json.Unmarshal(rec.Body.Bytes(), &response)
+
+		assert.Nil(t, err)
+		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.Len(t, response, 2)
+	})
+}
+
+func TestMigrationProfileHandler_DeleteMigrationProfile(t *testing.T) {
+	log := logger.NewLogger()
+
+	t.Run("it deletes the profile", func(t *testing.T) {
+		serviceMock := mocks.NewMigrationProfileServiceMock()
+
+		context, rec := httpserver.SetupAsRecorderWithIDField(http.MethodDelete, "/migration-profiles", "partner", "",
+			"name")
+		serviceMock.On("DeleteProfile", mock.Anything, "partner").Return(nil)
+
+		handler := localHttp.NewMigrationProfileHandler(log, serviceMock)
+		err := handler.DeleteMigrationProfile(context)
+
+		assert.Nil(t, err)
+		assert.Equal(t, http.StatusOK, rec.Code)
+	})
+
+	t.Run("it returns not found when the profile does not exist", func(t *testing.T) {
+		serviceMock := mocks.NewMigrationProfileServiceMock()
+
+		context, rec := httpserver.SetupAsRecorderWithIDField(http.MethodDelete, "/migration-profiles", "missing", "",
+			"name")
+		serviceMock.On("DeleteProfile", mock.Anything, "missing").Return(errors.New(migration.ProfileNotFoundError))
+
+		handler := localHttp.NewMigrationProfileHandler(log, serviceMock)
+		err := handler.DeleteMigrationProfile(context)
+
+		assert.Nil(t, err)
+		assert.Equal(t, http.StatusNotFound, rec.Code)
+	})
+}
@@ -0,0 +1,300 @@
+package http
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"regexp"
+	"strings"
+
+	"github.com/labstack/echo/v4"
+	"github.com/sebastianreh/user-balance-api/cmd/httpserver/exceptions"
+	"github.com/sebastianreh/user-balance-api/internal/app/services"
+	"github.com/sebastianreh/user-balance-api/internal/domain/upload"
+	"github.com/sebastianreh/user-balance-api/pkg/decompress"
+	"github.com/sebastianreh/user-balance-api/pkg/logger"
+	"github.com/sebastianreh/user-balance-api/pkg/records"
+	customStr "github.com/sebastianreh/user-balance-api/pkg/strings"
+)
+
+const (
+	migrationUploadHandlerName = "MigrationUploadHandler"
+)
+
+var uploadIDPattern = regexp.MustCompile(`^[0-9a-f]{32}$`)
+
+type MigrationUploadHandler struct {
+	log            logger.Logger
+	service        services.MigrationUploadService
+	profileService services.MigrationProfileService
+	limits         decompress.Limits
+}
+
+func NewMigrationUploadHandler(log logger.Logger, service services.MigrationUploadService,
+	profileService services.MigrationProfileService, limits decompress.Limits) *MigrationUploadHandler {
+	return &MigrationUploadHandler{
+		log:            log,
+		service:        service,
+		profileService: profileService,
+		limits:         limits,
+	}
+}
+
+// CreateMigrationUpload godoc
+// @Summary Create a chunked upload
+// @Description Starts a resumable upload of a migration file too large to be sent in a single request to /migrate.
+// @Description The chunks are sent in order with PUT /uploads/{upload_id} and the complete file is handed to a
+// @Description migration with POST /uploads/{upload_id}/finalize. Uploads that receive nothing for a while are
+// @Description removed
+// @Tags Migration
+// @Accept json
+// @Produce json
+// @Param upload body upload.Upload true "File name and size in bytes"
+// @Success 201 {object} upload.Upload "Created upload"
+// @Failure 400 {object} exceptions.BadRequestException "Invalid upload or file larger than the limit"
+// @Failure 500 {object} exceptions.InternalServerException "Internal server error"
+// @Router /uploads [post]
+func (h *MigrationUploadHandler) CreateMigrationUpload(ctx echo.Context) error {
+	request, err := validateUploadRequest(ctx)
+	if err != nil {
+		h.log.ErrorAt(err, migrationUploadHandlerName, "CreateMigrationUpload")
+		exception := exceptions.NewBadRequestException(err.Error())
+		return ctx.JSON(exception.Code(), exception)
+	}
+
+	created, err := h.service.CreateUpload(ctx.Request().Context(), request.FileName, request.Size)
+	if err != nil {
+		return uploadErrorResponse(ctx, err)
+	}
+
+	return ctx.JSON(http.StatusCreated, created)
+}
+
+// GetMigrationUpload godoc
+// @Summary Get a chunked upload
+// @Description Get how many bytes of the file were received, a client resumes the upload after them. A finalized
+// @Description upload has the ID of its migration job
+// @Tags Migration
+// @Produce json
+// @Param upload_id path string true "Upload ID"
+// @Success 200 {object} upload.Upload "Upload status"
+// @Failure 400 {object} exceptions.BadRequestException "Invalid upload ID"
+// @Failure 404 {object} exceptions.NotFoundException "Upload not found"
+// @Failure 500 {object} exceptions.InternalServerException "Internal server error"
+// @Router /uploads/{upload_id} [get]
+func (h *MigrationUploadHandler) GetMigrationUpload(ctx echo.Context) error {
+	uploadID, err := validateUploadIDRequest(ctx)
+	if err != nil {
+		h.log.ErrorAt(err, migrationUploadHandlerName, "GetMigrationUpload")
+		exception := exceptions.NewBadRequestException(err.Error())
+		return ctx.JSON(exception.Code(), exception)
+	}
+
+	found, err := h.service.GetUpload(ctx.Request().Context(), uploadID)
+	if err != nil {
+		return uploadErrorResponse(ctx, err)
+	}
+
+	return ctx.JSON(http.StatusOK, found)
+}
+
+// WriteMigrationUploadChunk godoc
+// @Summary Upload a chunk
+// @Description Appends the bytes of the request body to the upload. The Content-Range header tells their position
+// @Description in the file, like "bytes 0-1048575/5242880", and they must start at the received bytes. When a
+// @Description chunk is cut short the bytes that arrived are kept, get the upload to know where to resume
+// @Tags Migration
+// @Accept application/octet-stream
+// @Produce json
+// @Param upload_id path string true "Upload ID"
+// @Param Content-Range header string true "Position of the chunk in the file, like bytes start-end/total"
+// @Success 200 {object} upload.Upload "Upload status after the chunk"
+// @Failure 400 {object} exceptions.BadRequestException "Invalid upload ID or Content-Range"
+// @Failure 404 {object} exceptions.NotFoundException "Upload not found"
+// @Failure 409 {object} exceptions.DuplicatedException "The chunk doesn't start at the received bytes"
+// @Failure 500 {object} exceptions.InternalServerException "Internal server error"
+// @Router /uploads/{upload_id} [put]
+func (h *MigrationUploadHandler) WriteMigrationUploadChunk(ctx echo.Context) error {
+	uploadID, err := validateUploadIDRequest(ctx)
+	if err != nil {
+		h.log.ErrorAt(err, migrationUploadHandlerName, "WriteMigrationUploadChunk")
+		exception := exceptions.NewBadRequestException(err.Error())
+		return ctx.JSON(exception.Code(), exception)
+	}
+
+	chunk, err := validateChunkRequest(ctx)
+	if err != nil {
+		h.log.ErrorAt(err, migrationUploadHandlerName, "WriteMigrationUploadChunk")
+		exception := exceptions.NewBadRequestException(err.Error())
+		return ctx.JSON(exception.Code(), exception)
+	}
+
+	updated, err := h.service.WriteChunk(ctx.Request().Context(), uploadID, chunk, ctx.Request().Body)
+	if err != nil {
+		return uploadErrorResponse(ctx, err)
+	}
+
+	return ctx.JSON(http.StatusOK, updated)
+}
+
+// FinalizeMigrationUpload godoc
+// @Summary Finalize a chunked upload
+// @Description Hands the complete file of the upload to a migration job, with the same query params and headers
+// @Description as /migrate and the mapping profile in the profile query param. With async=true the created job is
+// @Description returned right away, which is advised for large files. When the migration can't start, for example
+// @Description because the file was already imported, the upload is kept and can be finalized again
+// @Tags Migration
+// @Produce json
+// @Param upload_id path string true "Upload ID"
+// @Param profile query string false "Name of the mapping profile for the file columns"
+// @Param async query bool false "Process the file in the background"
+// @Param mode query string false "Migration mode (default, partial, strict)"
+// @Param force query bool false "Import the file even when another migration already imported it"
+// @Param create_missing_users query bool false "Create the unknown users with the ids of the file"
+// @Param X-Destination-Emails header string true "Comma-separated list of email addresses to send the migration report"
+// @Param X-Uploaded-By header string false "Who uploaded the file, kept in the migration history"
+// @Success 200 {object} migration.Job "Finished migration job"
+// @Success 202 {object} migration.Job "Created migration job"
+// @Failure 400 {object} exceptions.BadRequestException "Invalid upload ID, params or file format"
+// @Failure 404 {object} exceptions.NotFoundException "Upload not found"
+// @Failure 409 {object} exceptions.DuplicatedException "Incomplete or finalized upload, or file already imported"
+// @Failure 500 {object} exceptions.InternalServerException "Internal server error"
+// @Router /uploads/{upload_id}/finalize [post]
+func (h *MigrationUploadHandler) FinalizeMigrationUpload(ctx echo.Context) error {
+	uploadID, err := validateUploadIDRequest(ctx)
+	if err != nil {
+		h.log.ErrorAt(err, migrationUploadHandlerName, "FinalizeMigrationUpload")
+		exception := exceptions.NewBadRequestException(err.Error())
+		return ctx.JSON(exception.Code(), exception)
+	}
+
+	current, err := h.service.GetUpload(ctx.Request().Context(), uploadID)
+	if err != nil {
+		return uploadErrorResponse(ctx, err)
+	}
+
+	src, err := h.service.OpenUpload(ctx.Request().Context(), uploadID)
+	if err != nil {
+		return uploadErrorResponse(ctx, err)
+	}
+
+	// The chunks carry no content type, only the file name declares the format
+	declaredFormat, _ := records.DeclaredFormat(current.FileName, "")
+	format, header, err := sniffFile(src, declaredFormat, h.limits)
+	_ = src.Close()
+	if err != nil {
+		exception := exceptions.NewBadRequestException(err.Error())
+		h.log.ErrorAt(exception, migrationUploadHandlerName, "FinalizeMigrationUpload")
+		return ctx.JSON(exception.Code(), exception)
+	}
+
+	profile, err := resolveProfile(ctx, h.profileService, format, header)
+	if err != nil {
+		h.log.ErrorAt(err, migrationUploadHandlerName, "FinalizeMigrationUpload")
+		return profileErrorResponse(ctx, err)
+	}
+
+	options, async, err := validateMigrationOptionsRequest(ctx, format, profile)
+	if err != nil {
+		exception := exceptions.NewBadRequestException(err.Error())
+		return ctx.JSON(exception.Code(), exception)
+	}
+
+	job, err := h.service.FinalizeUpload(ctx.Request().Context(), uploadID, options, async)
+	if err != nil {
+		return uploadErrorResponse(ctx, err)
+	}
+
+	if async {
+		return ctx.JSON(http.StatusAccepted, job)
+	}
+
+	return ctx.JSON(http.StatusOK, job)
+}
+
+// DeleteMigrationUpload godoc
+// @Summary Delete a chunked upload
+// @Description Deletes an upload and its received bytes, the file of a finalized upload is kept by its migration
+// @Tags Migration
+// @Param upload_id path string true "Upload ID"
+// @Success 200 "No Content"
+// @Failure 400 {object} exceptions.BadRequestException "Invalid upload ID"
+// @Failure 404 {object} exceptions.NotFoundException "Upload not found"
+// @Failure 409 {object} exceptions.DuplicatedException "The upload is being finalized"
+// @Failure 500 {object} exceptions.InternalServerException "Internal server error"
+// @Router /uploads/{upload_id} [delete]
+func (h *MigrationUploadHandler) DeleteMigrationUpload(ctx echo.Context) error {
+	uploadID, err := validateUploadIDRequest(ctx)
+	if err != nil {
+		h.log.ErrorAt(err, migrationUploadHandlerName, "DeleteMigrationUpload")
+		exception := exceptions.NewBadRequestException(err.Error())
+		return ctx.JSON(exception.Code(), exception)
+	}
+
+	if err = h.service.DeleteUpload(ctx.Request().Context(), uploadID); err != nil {
+		return uploadErrorResponse(ctx, err)
+	}
+
+	return ctx.NoContent(http.StatusOK)
+}
+
+// uploadErrorResponse answers the errors of the upload and, on finalize, the ones of the migration it starts
+func uploadErrorResponse(ctx echo.Context, err error) error {
+	switch {
+	case strings.HasPrefix(err.Error(), upload.NotFoundError):
+		exception := exceptions.NewNotFoundException(err.Error())
+		return ctx.JSON(exception.Code(), exception)
+	case strings.HasPrefix(err.Error(), upload.OffsetMismatchError),
+		strings.HasPrefix(err.Error(), upload.IncompleteError),
+		strings.HasPrefix(err.Error(), upload.FinalizedError),
+		strings.HasPrefix(err.Error(), upload.BusyError):
+		exception := exceptions.NewDuplicatedException(err.Error())
+		return ctx.JSON(exception.Code(), exception)
+	case strings.HasPrefix(err.Error(), upload.SizeMismatchError), strings.HasPrefix(err.Error(), upload.TooLargeError):
+		exception := exceptions.NewBadRequestException(err.Error())
+		return ctx.JSON(exception.Code(), exception)
+	}
+
+	return migrationErrorResponse(ctx, err)
+}
+
+func validateUploadRequest(ctx echo.Context) (upload.Upload, error) {
+	var request upload.Upload
+	if err := ctx.Bind(&request); err != nil {
+		return request, errors.New("invalid request body")
+	}
+
+	request.FileName = strings.TrimSpace(request.FileName)
+	if err := request.Validate(); err != nil {
+		return request, err
+	}
+
+	return request, nil
+}
+
+func validateUploadIDRequest(ctx echo.Context) (string, error) {
+	uploadID := ctx.Param("upload_id")
+	if customStr.IsEmpty(uploadID) {
+		return uploadID, errors.New("missing param upload_id")
+	}
+
+	if !uploadIDPattern.MatchString(uploadID) {
+		return uploadID, errors.New("upload_id is not valid")
+	}
+
+	return uploadID, nil
+}
+
+// validateChunkRequest reads the chunk position, a body with a known length must have the size of the range
+func validateChunkRequest(ctx echo.Context) (upload.Chunk, error) {
+	chunk, err := upload.ParseContentRange(ctx.Request().Header.Get("Content-Range"))
+	if err != nil {
+		return chunk, err
+	}
+
+	if length := ctx.Request().ContentLength; length >= 0 && length != chunk.Length() {
+		return chunk, fmt.Errorf("the body has %d bytes but the Content-Range has %d", length, chunk.Length())
+	}
+
+	return chunk, nil
+}
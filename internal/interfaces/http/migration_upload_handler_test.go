@@ -0,0 +1,306 @@
+package http_test
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/sebastianreh/user-balance-api/cmd/httpserver"
+	"github.com/sebastianreh/user-balance-api/internal/domain/migration"
+	"github.com/sebastianreh/user-balance-api/internal/domain/upload"
+	localHttp "github.com/sebastianreh/user-balance-api/internal/interfaces/http"
+	"github.com/sebastianreh/user-balance-api/pkg/logger"
+	"github.com/sebastianreh/user-balance-api/pkg/records"
+	"github.com/sebastianreh/user-balance-api/test/mocks"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+const testUploadID = "0123456789abcdef0123456789abcdef"
+
+func TestMigrationUploadHandler_CreateMigrationUpload(t *testing.T) {
+	log := logger.NewLogger()
+
+	t.Run("it creates the upload", func(t *testing.T) {
+		serviceMock := mocks.NewMigrationUploadServiceMock()
+		created := upload.Upload{ID: testUploadID, FileName: "migration.csv", Size: 2048,
+			Status: upload.StatusReceiving}
+
+		context, rec := httpserver.SetupAsRecorder(http.MethodPost, "/uploads", "",
+			`{"file_name": " migration.csv ", "size": 2048}`)
+		serviceMock.On("CreateUpload", mock.Anything, "migration.csv", int64(2048)).Return(created, nil)
+
+		handler := localHttp.NewMigrationUploadHandler(log, serviceMock, mocks.NewMigrationProfileServiceMock(), fileLimits)
+		err := handler.CreateMigrationUpload(context)
+
+		var response upload.Upload
+		_ = json.Unmarshal(rec.Body.Bytes(), &response)
+
+		assert.Nil(t, err)
+		assert.Equal(t, http.StatusCreated, rec.Code)
+		assert.Equal(t, testUploadID, response.ID)
+	})
+
+	t.Run("it returns bad request without a size", func(t *testing.T) {
+		serviceMock := mocks.NewMigrationUploadServiceMock()
+
+		context, rec := httpserver.SetupAsRecorder(http.MethodPost, "/uploads", "", `{"file_name": "migration.csv"}`)
+
+		handler := localHttp.NewMigrationUploadHandler(log, serviceMock, mocks.NewMigrationProfileServiceMock(), fileLimits)
+		err := handler.CreateMigrationUpload(context)
+
+		assert.Nil(t, err)
+		assert.Equal(t, http.StatusBadRequest, rec.Code)
+		serviceMock.AssertNotCalled(t, "CreateUpload", mock.Anything, mock.Anything, mock.Anything)
+	})
+
+	t.Run("it returns bad request when the file is too large", func(t *testing.T) {
+		serviceMock := mocks.NewMigrationUploadServiceMock()
+
+		context, rec := httpserver.SetupAsRecorder(http.MethodPost, "/uploads", "",
+			`{"file_name": "migration.csv", "size": 2048}`)
+		serviceMock.On("CreateUpload", mock.Anything, "migration.csv", int64(2048)).
+			Return(upload.Upload{}, errors.New(upload.TooLargeError+" of 1024 bytes"))
+
+		handler := localHttp.NewMigrationUploadHandler(log, serviceMock, mocks.NewMigrationProfileServiceMock(), fileLimits)
+		err := handler.CreateMigrationUpload(context)
+
+		assert.Nil(t, err)
+		assert.Equal(t, http.StatusBadRequest, rec.Code)
+	})
+}
+
+func TestMigrationUploadHandler_WriteMigrationUploadChunk(t *testing.T) {
+	log := logger.NewLogger()
+
+	t.Run("it appends the chunk", func(t *testing.T) {
+		serviceMock := mocks.NewMigrationUploadServiceMock()
+		updated := upload.Upload{ID: testUploadID, Size: 10, ReceivedBytes: 4, Status: upload.StatusReceiving}
+
+		context, rec := httpserver.SetupAsRecorderWithIDField(http.MethodPut, "/uploads", testUploadID, "0123",
+			"upload_id")
+		context.Request().Header.Set("Content-Range", "bytes 0-3/10")
+		serviceMock.On("WriteChunk", mock.Anything, testUploadID, upload.Chunk{Start: 0, End: 3, Total: 10},
+			mock.Anything).Return(updated, nil)
+
+		handler := localHttp.NewMigrationUploadHandler(log, serviceMock, mocks.NewMigrationProfileServiceMock(), fileLimits)
+		err := handler.WriteMigrationUploadChunk(context)
+
+		var response upload.Upload
+		_ = json.Unmarshal(rec.Body.Bytes(), &response)
+
+		assert.Nil(t, err)
+		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.Equal(t, int64(4), response.ReceivedBytes)
+	})
+
+	t.Run("it returns bad request when the body doesn't match the range", func(t *testing.T) {
+		serviceMock := mocks.NewMigrationUploadServiceMock()
+
+		context, rec := httpserver.SetupAsRecorderWithIDField(http.MethodPut, "/uploads", testUploadID, "01",
+			"upload_id")
+		context.Request().Header.Set("Content-Range", "bytes 0-3/10")
+
+		handler := localHttp.NewMigrationUploadHandler(log, serviceMock, mocks.NewMigrationProfileServiceMock(), fileLimits)
+		err := handler.WriteMigrationUploadChunk(context)
+
+		assert.Nil(t, err)
+		assert.Equal(t, http.StatusBadRequest, rec.Code)
+		serviceMock.AssertNotCalled(t, "WriteChunk", mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+	})
+
+	t.Run("it returns bad request for an invalid upload id", func(t *testing.T) {
+		serviceMock := mocks.NewMigrationUploadServiceMock()
+
+		context, rec := httpserver.SetupAsRecorderWithIDField(http.MethodPut, "/uploads", "..", "0123", "upload_id")
+		context.Request().Header.Set("Content-Range", "bytes 0-3/10")
+
+		handler := localHttp.NewMigrationUploadHandler(log, serviceMock, mocks.NewMigrationProfileServiceMock(), fileLimits)
+		err := handler.WriteMigrationUploadChunk(context)
+
+		assert.Nil(t, err)
+		assert.Equal(t, http.StatusBadRequest, rec.Code)
+	})
+
+	t.Run("it returns conflict when the chunk doesn't start at the received bytes", func(t *testing.T) {
+		serviceMock := mocks.NewMigrationUploadServiceMock()
+
+		context, rec := httpserver.SetupAsRecorderWithIDField(http.MethodPut, "/uploads", testUploadID, "4567",
+			"upload_id")
+		context.Request().Header.Set("Content-Range", "bytes 4-7/10")
+		serviceMock.On("WriteChunk", mock.Anything, testUploadID, upload.Chunk{Start: 4, End: 7, Total: 10},
+			mock.Anything).Return(upload.Upload{}, errors.New(upload.OffsetMismatchError+", 2 bytes were received"))
+
+		handler := localHttp.NewMigrationUploadHandler(log, serviceMock, mocks.NewMigrationProfileServiceMock(), fileLimits)
+		err := handler.WriteMigrationUploadChunk(context)
+
+		assert.Nil(t, err)
+		assert.Equal(t, http.StatusConflict, rec.Code)
+		assert.Contains(t, rec.Body.String(), "2 bytes were received")
+	})
+}
+
+func TestMigrationUploadHandler_GetMigrationUpload(t *testing.T) {
+	log := logger.NewLogger()
+
+	t.Run("it returns not found when the upload does not exist", func(t *testing.T) {
+		serviceMock := mocks.NewMigrationUploadServiceMock()
+
+		context, rec := httpserver.SetupAsRecorderWithIDField(http.MethodGet, "/uploads", testUploadID, "",
+			"upload_id")
+		serviceMock.On("GetUpload", mock.Anything, testUploadID).Return(upload.Upload{},
+			errors.New(upload.NotFoundError))
+
+		handler := localHttp.NewMigrationUploadHandler(log, serviceMock, mocks.NewMigrationProfileServiceMock(), fileLimits)
+		err := handler.GetMigrationUpload(context)
+
+		assert.Nil(t, err)
+		assert.Equal(t, http.StatusNotFound, rec.Code)
+	})
+}
+
+func TestMigrationUploadHandler_FinalizeMigrationUpload(t *testing.T) {
+	log := logger.NewLogger()
+	content := "id,user_id,amount,datetime\n1,1,100,2024-09-13T10:00:00Z\n"
+
+	t.Run("it hands the upload to a background migration", func(t *testing.T) {
+		serviceMock := mocks.NewMigrationUploadServiceMock()
+		job := migration.Job{ID: "5", Status: migration.StatusPending}
+		expectedOptions := migration.Options{Mode: migration.ModePartial, ReportDestinations: []string{"ops@example.com"},
+			UploadedBy: "ops", Format: records.FormatCSV, Profile: &migration.MappingProfile{}}
+
+		context, rec := httpserver.SetupAsRecorderWithIDField(http.MethodPost, "/uploads", testUploadID, "",
+			"upload_id")
+		context.Request().URL.RawQuery = "async=true&mode=partial"
+		context.Request().Header.Set("X-Destination-Emails", "ops@example.com")
+		context.Request().Header.Set("X-Uploaded-By", "ops")
+		serviceMock.On("GetUpload", mock.Anything, testUploadID).
+			Return(upload.Upload{ID: testUploadID, FileName: "migration.csv"}, nil)
+		serviceMock.On("OpenUpload", mock.Anything, testUploadID).
+			Return(io.NopCloser(strings.NewReader(content)), nil)
+		serviceMock.On("FinalizeUpload", mock.Anything, testUploadID, expectedOptions, true).Return(job, nil)
+
+		handler := localHttp.NewMigrationUploadHandler(log, serviceMock, mocks.NewMigrationProfileServiceMock(), fileLimits)
+		err := handler.FinalizeMigrationUpload(context)
+
+		var response migration.Job
+		_ = json.Unmarshal(rec.Body.Bytes(), &response)
+
+		assert.Nil(t, err)
+		assert.Equal(t, http.StatusAccepted, rec.Code)
+		assert.Equal(t, "5", response.ID)
+	})
+
+	t.Run("it picks the format by the file name of the upload", func(t *testing.T) {
+		serviceMock := mocks.NewMigrationUploadServiceMock()
+		jsonContent := `{"id":1,"user_id":1,"amount":100,"date_time":"2024-09-13T10:00:00Z"}`
+
+		context, rec := httpserver.SetupAsRecorderWithIDField(http.MethodPost, "/uploads", testUploadID, "",
+			"upload_id")
+		context.Request().Header.Set("X-Destination-Emails", "ops@example.com")
+		serviceMock.On("GetUpload", mock.Anything, testUploadID).
+			Return(upload.Upload{ID: testUploadID, FileName: "migration.json"}, nil)
+		serviceMock.On("OpenUpload", mock.Anything, testUploadID).
+			Return(io.NopCloser(strings.NewReader(jsonContent)), nil)
+		serviceMock.On("FinalizeUpload", mock.Anything, testUploadID, mock.MatchedBy(func(options migration.Options) bool {
+			return options.Format == records.FormatJSON
+		}), false).Return(migration.Job{ID: "5", Status: migration.StatusCompleted}, nil)
+
+		handler := localHttp.NewMigrationUploadHandler(log, serviceMock, mocks.NewMigrationProfileServiceMock(), fileLimits)
+		err := handler.FinalizeMigrationUpload(context)
+
+		assert.Nil(t, err)
+		assert.Equal(t, http.StatusOK, rec.Code)
+	})
+
+	t.Run("it returns conflict when the upload is missing bytes", func(t *testing.T) {
+		serviceMock := mocks.NewMigrationUploadServiceMock()
+
+		context, rec := httpserver.SetupAsRecorderWithIDField(http.MethodPost, "/uploads", testUploadID, "",
+			"upload_id")
+		serviceMock.On("GetUpload", mock.Anything, testUploadID).
+			Return(upload.Upload{ID: testUploadID, FileName: "migration.csv"}, nil)
+		serviceMock.On("OpenUpload", mock.Anything, testUploadID).
+			Return(nil, errors.New(upload.IncompleteError+", 4 of 10 bytes were received"))
+
+		handler := localHttp.NewMigrationUploadHandler(log, serviceMock, mocks.NewMigrationProfileServiceMock(), fileLimits)
+		err := handler.FinalizeMigrationUpload(context)
+
+		assert.Nil(t, err)
+		assert.Equal(t, http.StatusConflict, rec.Code)
+	})
+
+	t.Run("it returns bad request when the file format is not supported", func(t *testing.T) {
+		serviceMock := mocks.NewMigrationUploadServiceMock()
+
+		context, rec := httpserver.SetupAsRecorderWithIDField(http.MethodPost, "/uploads", testUploadID, "",
+			"upload_id")
+		serviceMock.On("GetUpload", mock.Anything, testUploadID).
+			Return(upload.Upload{ID: testUploadID, FileName: "migration.xml"}, nil)
+		serviceMock.On("OpenUpload", mock.Anything, testUploadID).
+			Return(io.NopCloser(strings.NewReader("<xml></xml>")), nil)
+
+		handler := localHttp.NewMigrationUploadHandler(log, serviceMock, mocks.NewMigrationProfileServiceMock(), fileLimits)
+		err := handler.FinalizeMigrationUpload(context)
+
+		assert.Nil(t, err)
+		assert.Equal(t, http.StatusBadRequest, rec.Code)
+		serviceMock.AssertNotCalled(t, "FinalizeUpload", mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+	})
+
+	t.Run("it returns conflict when the file was already imported", func(t *testing.T) {
+		serviceMock := mocks.NewMigrationUploadServiceMock()
+
+		context, rec := httpserver.SetupAsRecorderWithIDField(http.MethodPost, "/uploads", testUploadID, "",
+			"upload_id")
+		context.Request().Header.Set("X-Destination-Emails", "ops@example.com")
+		serviceMock.On("GetUpload", mock.Anything, testUploadID).
+			Return(upload.Upload{ID: testUploadID, FileName: "migration.csv"}, nil)
+		serviceMock.On("OpenUpload", mock.Anything, testUploadID).
+			Return(io.NopCloser(strings.NewReader(content)), nil)
+		serviceMock.On("FinalizeUpload", mock.Anything, testUploadID, mock.Anything, false).
+			Return(migration.Job{}, errors.New(migration.DuplicateFileError+" by migration 7"))
+
+		handler := localHttp.NewMigrationUploadHandler(log, serviceMock, mocks.NewMigrationProfileServiceMock(), fileLimits)
+		err := handler.FinalizeMigrationUpload(context)
+
+		assert.Nil(t, err)
+		assert.Equal(t, http.StatusConflict, rec.Code)
+		assert.Contains(t, rec.Body.String(), "force=true")
+	})
+}
+
+func TestMigrationUploadHandler_DeleteMigrationUpload(t *testing.T) {
+	log := logger.NewLogger()
+
+	t.Run("it deletes the upload", func(t *testing.T) {
+		serviceMock := mocks.NewMigrationUploadServiceMock()
+
+		context, rec := httpserver.SetupAsRecorderWithIDField(http.MethodDelete, "/uploads", testUploadID, "",
+			"upload_id")
+		serviceMock.On("DeleteUpload", mock.Anything, testUploadID).Return(nil)
+
+		handler := localHttp.NewMigrationUploadHandler(log, serviceMock, mocks.NewMigrationProfileServiceMock(), fileLimits)
+		err := handler.DeleteMigrationUpload(context)
+
+		assert.Nil(t, err)
+		assert.Equal(t, http.StatusOK, rec.Code)
+	})
+
+	t.Run("it returns conflict while the upload is being finalized", func(t *testing.T) {
+		serviceMock := mocks.NewMigrationUploadServiceMock()
+
+		context, rec := httpserver.SetupAsRecorderWithIDField(http.MethodDelete, "/uploads", testUploadID, "",
+			"upload_id")
+		serviceMock.On("DeleteUpload", mock.Anything, testUploadID).Return(errors.New(upload.BusyError))
+
+		handler := localHttp.NewMigrationUploadHandler(log, serviceMock, mocks.NewMigrationProfileServiceMock(), fileLimits)
+		err := handler.DeleteMigrationUpload(context)
+
+		assert.Nil(t, err)
+		assert.Equal(t, http.StatusConflict, rec.Code)
+	})
+}
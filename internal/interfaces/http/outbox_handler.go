@@ -0,0 +1,172 @@
+package http
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/labstack/echo/v4"
+	"github.com/sebastianreh/user-balance-api/cmd/httpserver/exceptions"
+	"github.com/sebastianreh/user-balance-api/internal/app/services"
+	"github.com/sebastianreh/user-balance-api/internal/domain/outbox"
+	"github.com/sebastianreh/user-balance-api/pkg/logger"
+	customStr "github.com/sebastianreh/user-balance-api/pkg/strings"
+)
+
+const (
+	outboxHandlerName = "OutboxHandler"
+)
+
+type OutboxHandler struct {
+	log     logger.Logger
+	service services.OutboxService
+}
+
+func NewOutboxHandler(log logger.Logger, service services.OutboxService) *OutboxHandler {
+	return &OutboxHandler{
+		log:     log,
+		service: service,
+	}
+}
+
+// ListOutboxMessages godoc
+// @Summary List outbox messages
+// @Description Lists the messages waiting to be sent or already sent from the newest, with keyset pagination. The
+// @Description failed ones ran out of attempts and are only sent again when they are redriven
+// @Tags Admin
+// @Produce json
+// @Param status query string false "pending, sent or failed"
+// @Param limit query int false "Page size, from 1 to 500, defaults to 50"
+// @Param cursor query string false "Cursor returned by the previous page"
+// @Success 200 {object} outbox.ListPage "Outbox messages page"
+// @Failure 400 {object} exceptions.BadRequestException "Invalid query params or cursor"
+// @Failure 500 {object} exceptions.InternalServerException "Internal server error"
+// @Router /admin/outbox [get]
+func (h *OutboxHandler) ListOutboxMessages(ctx echo.Context) error {
+	options, err := validateListOutboxRequest(ctx)
+	if err != nil {
+		h.log.ErrorAt(err, outboxHandlerName, "ListOutboxMessages")
+		exception := exceptions.NewBadRequestException(err.Error())
+		return ctx.JSON(exception.Code(), exception)
+	}
+
+	page, err := h.service.ListMessages(ctx.Request().Context(), options)
+	if err != nil {
+		exception := exceptions.NewInternalServerException(err.Error())
+		return ctx.JSON(exception.Code(), exception)
+	}
+
+	return ctx.JSON(http.StatusOK, page)
+}
+
+// GetOutboxMessage godoc
+// @Summary Get outbox message
+// @Description Returns a message with every attempt to send it
+// @Tags Admin
+// @Produce json
+// @Param message_id path string true "Outbox message ID"
+// @Success 200 {object} outbox.Message "Outbox message"
+// @Failure 400 {object} exceptions.BadRequestException "Invalid message ID"
+// @Failure 404 {object} exceptions.NotFoundException "Outbox message not found"
+// @Failure 500 {object} exceptions.InternalServerException "Internal server error"
+// @Router /admin/outbox/{message_id} [get]
+func (h *OutboxHandler) GetOutboxMessage(ctx echo.Context) error {
+	messageID, err := validateMessageIDRequest(ctx)
+	if err != nil {
+		h.log.ErrorAt(err, outboxHandlerName, "GetOutboxMessage")
+		exception := exceptions.NewBadRequestException(err.Error())
+		return ctx.JSON(exception.Code(), exception)
+	}
+
+	message, err := h.service.GetMessage(ctx.Request().Context(), messageID)
+	if err != nil {
+		if strings.Contains(err.Error(), outbox.NotFoundError) {
+			exception := exceptions.NewNotFoundException(err.Error())
+			return ctx.JSON(exception.Code(), exception)
+		}
+
+		exception := exceptions.NewInternalServerException(err.Error())
+		return ctx.JSON(exception.Code(), exception)
+	}
+
+	return ctx.JSON(http.StatusOK, message)
+}
+
+// RedriveOutboxMessage godoc
+// @Summary Redrive outbox message
+// @Description Makes a message that was not sent due right away with its attempts reset, including the failed ones
+// @Tags Admin
+// @Produce json
+// @Param message_id path string true "Outbox message ID"
+// @Success 200 {object} outbox.Message "Redriven outbox message"
+// @Failure 400 {object} exceptions.BadRequestException "Invalid message ID"
+// @Failure 404 {object} exceptions.NotFoundException "Outbox message not found"
+// @Failure 409 {object} exceptions.DuplicatedException "The message was already sent"
+// @Failure 500 {object} exceptions.InternalServerException "Internal server error"
+// @Router /admin/outbox/{message_id}/redrive [post]
+func (h *OutboxHandler) RedriveOutboxMessage(ctx echo.Context) error {
+	messageID, err := validateMessageIDRequest(ctx)
+	if err != nil {
+		h.log.ErrorAt(err, outboxHandlerName, "RedriveOutboxMessage")
+		exception := exceptions.NewBadRequestException(err.Error())
+		return ctx.JSON(exception.Code(), exception)
+	}
+
+	message, err := h.service.RedriveMessage(ctx.Request().Context(), messageID)
+	if err != nil {
+		switch {
+		case strings.Contains(err.Error(), outbox.NotFoundError):
+			exception := exceptions.NewNotFoundException(err.Error())
+			return ctx.JSON(exception.Code(), exception)
+		case strings.Contains(err.Error(), outbox.NotRedrivableError):
+			exception := exceptions.NewDuplicatedException(err.Error())
+			return ctx.JSON(exception.Code(), exception)
+		}
+
+		exception := exceptions.NewInternalServerException(err.Error())
+		return ctx.JSON(exception.Code(), exception)
+	}
+
+	return ctx.JSON(http.StatusOK, message)
+}
+
+func validateListOutboxRequest(ctx echo.Context) (outbox.ListOptions, error) {
+	options := outbox.ListOptions{Limit: outbox.DefaultListLimit, Status: ctx.QueryParam("status")}
+	if options.Status != "" && !outbox.IsStatus(options.Status) {
+		return options, errors.New("status must be pending, sent or failed")
+	}
+
+	if limitParam := ctx.QueryParam("limit"); !customStr.IsEmpty(limitParam) {
+		limit, err := strconv.Atoi(limitParam)
+		if err != nil || limit < 1 || limit > outbox.MaxListLimit {
+			return options, fmt.Errorf("limit must be a number between 1 and %d", outbox.MaxListLimit)
+		}
+		options.Limit = limit
+	}
+
+	if cursorParam := ctx.QueryParam("cursor"); !customStr.IsEmpty(cursorParam) {
+		cursor, err := outbox.DecodeCursor(cursorParam)
+		if err != nil {
+			return options, err
+		}
+		options.After = &cursor
+	}
+
+	return options, nil
+}
+
+func validateMessageIDRequest(ctx echo.Context) (string, error) {
+	messageID := ctx.Param("message_id")
+	if customStr.IsEmpty(messageID) {
+		return messageID, errors.New("missing param message_id")
+	}
+
+	// Messages are identified by a sequence, anything else would fail in the database
+	if _, err := strconv.ParseInt(messageID, 10, 64); err != nil {
+		return messageID, errors.New("message_id must be numeric")
+	}
+
+	return messageID, nil
+}
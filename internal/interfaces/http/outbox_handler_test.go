@@ -0,0 +1,167 @@
+package http_test
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"testing"
+
+	"github.com/sebastianreh/user-balance-api/cmd/httpserver"
+	"github.com/sebastianreh/user-balance-api/internal/domain/outbox"
+	localHttp "github.com/sebastianreh/user-balance-api/internal/interfaces/http"
+	"github.com/sebastianreh/user-balance-api/pkg/logger"
+	"github.com/sebastianreh/user-balance-api/test/mocks"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func TestOutboxHandler_ListOutboxMessages(t *testing.T) {
+	log := logger.NewLogger()
+
+	t.Run("it lists the messages of a status", func(t *testing.T) {
+		serviceMock := mocks.NewOutboxServiceMock()
+		page := outbox.ListPage{Messages: []outbox.Message{{ID: "1", Status: outbox.StatusFailed}}}
+		options := outbox.ListOptions{Status: outbox.StatusFailed, Limit: 10}
+
+		context, rec := httpserver.SetupAsRecorder(http.MethodGet, "/admin/outbox", "", "")
+		context.Request().URL.RawQuery = "status=failed&limit=10"
+		serviceMock.On("ListMessages", mock.Anything, options).Return(page, nil)
+
+		handler := localHttp.NewOutboxHandler(log, serviceMock)
+		err := handler.ListOutboxMessages(context)
+
+		var response outbox.ListPage
+		_ = json.Unmarshal(rec.Body.Bytes(), &response)
+
+		assert.Nil(t, err)
+		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.Equal(t, page.Messages[0].ID, response.Messages[0].ID)
+	})
+
+	t.Run("it returns bad request for an unknown status", func(t *testing.T) {
+		serviceMock := mocks.NewOutboxServiceMock()
+
+		context, rec := httpserver.SetupAsRecorder(http.MethodGet, "/admin/outbox", "", "")
+		context.Request().URL.RawQuery = "status=lost"
+
+		handler := localHttp.NewOutboxHandler(log, serviceMock)
+		err := handler.ListOutboxMessages(context)
+
+		assert.Nil(t, err)
+		assert.Equal(t, http.StatusBadRequest, rec.Code)
+		serviceMock.AssertNotCalled(t, "ListMessages", mock.Anything, mock.Anything)
+	})
+
+	t.Run("it returns bad request for a limit out of range", func(t *testing.T) {
+		serviceMock := mocks.NewOutboxServiceMock()
+
+		context, rec := httpserver.SetupAsRecorder(http.MethodGet, "/admin/outbox", "", "")
+		context.Request().URL.RawQuery = "limit=501"
+
+		handler := localHttp.NewOutboxHandler(log, serviceMock)
+		err := handler.ListOutboxMessages(context)
+
+		assert.Nil(t, err)
+		assert.Equal(t, http.StatusBadRequest, rec.Code)
+	})
+}
+
+func TestOutboxHandler_GetOutboxMessage(t *testing.T) {
+	log := logger.NewLogger()
+
+	t.Run("it returns the message with its attempts", func(t *testing.T) {
+		serviceMock := mocks.NewOutboxServiceMock()
+		message := outbox.Message{ID: "1", Status: outbox.StatusPending,
+			History: []outbox.Attempt{{Error: "smtp unavailable"}}}
+
+		context, rec := httpserver.SetupAsRecorderWithIDField(http.MethodGet, "/admin/outbox/:message_id", "1", "",
+			"message_id")
+		serviceMock.On("GetMessage", mock.Anything, "1").Return(message, nil)
+
+		handler := localHttp.NewOutboxHandler(log, serviceMock)
+		err := handler.GetOutboxMessage(context)
+
+		var response outbox.Message
+		_ = json.Unmarshal(rec.Body.Bytes(), &response)
+
+		assert.Nil(t, err)
+		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.Equal(t, "smtp unavailable", response.History[0].Error)
+	})
+
+	t.Run("it returns not found when the message does not exist", func(t *testing.T) {
+		serviceMock := mocks.NewOutboxServiceMock()
+
+		context, rec := httpserver.SetupAsRecorderWithIDField(http.MethodGet, "/admin/outbox/:message_id", "9", "",
+			"message_id")
+		serviceMock.On("GetMessage", mock.Anything, "9").Return(outbox.Message{}, errors.New(outbox.NotFoundError))
+
+		handler := localHttp.NewOutboxHandler(log, serviceMock)
+		err := handler.GetOutboxMessage(context)
+
+		assert.Nil(t, err)
+		assert.Equal(t, http.StatusNotFound, rec.Code)
+	})
+}
+
+func TestOutboxHandler_RedriveOutboxMessage(t *testing.T) {
+	log := logger.NewLogger()
+
+	t.Run("it redrives the message", func(t *testing.T) {
+		serviceMock := mocks.NewOutboxServiceMock()
+		message := outbox.Message{ID: "1", Status: outbox.StatusPending}
+
+		context, rec := httpserver.SetupAsRecorderWithIDField(http.MethodPost, "/admin/outbox/:message_id/redrive", "1",
+			"", "message_id")
+		serviceMock.On("RedriveMessage", mock.Anything, "1").Return(message, nil)
+
+		handler := localHttp.NewOutboxHandler(log, serviceMock)
+		err := handler.RedriveOutboxMessage(context)
+
+		assert.Nil(t, err)
+		assert.Equal(t, http.StatusOK, rec.Code)
+	})
+
+	t.Run("it returns conflict when the message was already sent", func(t *testing.T) {
+		serviceMock := mocks.NewOutboxServiceMock()
+
+		context, rec := httpserver.SetupAsRecorderWithIDField(http.MethodPost, "/admin/outbox/:message_id/redrive", "1",
+			"", "message_id")
+		serviceMock.On("RedriveMessage", mock.Anything, "1").
+			Return(outbox.Message{}, errors.New(outbox.NotRedrivableError))
+
+		handler := localHttp.NewOutboxHandler(log, serviceMock)
+		err := handler.RedriveOutboxMessage(context)
+
+		assert.Nil(t, err)
+		assert.Equal(t, http.StatusConflict, rec.Code)
+	})
+
+	t.Run("it returns not found when the message does not exist", func(t *testing.T) {
+		serviceMock := mocks.NewOutboxServiceMock()
+
+		context, rec := httpserver.SetupAsRecorderWithIDField(http.MethodPost, "/admin/outbox/:message_id/redrive", "9",
+			"", "message_id")
+		serviceMock.On("RedriveMessage", mock.Anything, "9").Return(outbox.Message{}, errors.New(outbox.NotFoundError))
+
+		handler := localHttp.NewOutboxHandler(log, serviceMock)
+		err := handler.RedriveOutboxMessage(context)
+
+		assert.Nil(t, err)
+		assert.Equal(t, http.StatusNotFound, rec.Code)
+	})
+
+	t.Run("it returns bad request for a non numeric id", func(t *testing.T) {
+		serviceMock := mocks.NewOutboxServiceMock()
+
+		context, rec := httpserver.SetupAsRecorderWithIDField(http.MethodPost, "/admin/outbox/:message_id/redrive", "abc",
+			"", "message_id")
+
+		handler := localHttp.NewOutboxHandler(log, serviceMock)
+		err := handler.RedriveOutboxMessage(context)
+
+		assert.Nil(t, err)
+		assert.Equal(t, http.StatusBadRequest, rec.Code)
+		serviceMock.AssertNotCalled(t, "RedriveMessage", mock.Anything, mock.Anything)
+	})
+}
@@ -2,7 +2,9 @@ package http
 
 import (
 	"errors"
+	"fmt"
 	"net/http"
+	"strconv"
 	"strings"
 
 	"github.com/sebastianreh/user-balance-api/internal/domain/user"
@@ -186,6 +188,50 @@ func (t *TransactionHandler) DeleteTransaction(ctx echo.Context) error {
 	return ctx.NoContent(http.StatusOK)
 }
 
+// ListUserTransactions godoc
+// @Summary List user transactions
+// @Description Lists the transactions of a user with keyset pagination.
+// Pass the returned next_cursor as cursor to get the next page.
+// @Tags transactions
+// @Produce json
+// @Param user_id path string true "User ID"
+// @Param from query string false "Start date in ISO8601 format (YYYY-MM-DDThh:mm:ssZ)"
+// @Param to query string false "End date in ISO8601 format (YYYY-MM-DDThh:mm:ssZ)"
+// @Param min_amount query number false "Minimum amount, inclusive"
+// @Param max_amount query number false "Maximum amount, inclusive"
+// @Param type query string false "Transaction type: credit or debit"
+// @Param sort query string false "Sort field: date (default) or amount"
+// @Param order query string false "Sort order: asc (default) or desc"
+// @Param limit query int false "Page size, from 1 to 500, defaults to 50"
+// @Param cursor query string false "Cursor returned by the previous page"
+// @Param include query string false "Set to running_balance to include the balance after each transaction"
+// @Success 200 {object} transaction.ListPage "Transactions page"
+// @Failure 400 {object} exceptions.BadRequestException "Invalid query params or cursor"
+// @Failure 404 {object} exceptions.NotFoundException "User not found"
+// @Failure 500 {object} exceptions.InternalServerException "Internal server error"
+// @Router /users/{user_id}/transactions [get]
+func (t *TransactionHandler) ListUserTransactions(ctx echo.Context) error {
+	options, err := validateListTransactionsRequest(ctx)
+	if err != nil {
+		t.log.ErrorAt(err, transactionHandlerName, "ListUserTransactions")
+		exception := exceptions.NewBadRequestException(err.Error())
+		return ctx.JSON(exception.Code(), exception)
+	}
+
+	page, err := t.service.ListUserTransactions(ctx.Request().Context(), options)
+	if err != nil {
+		if strings.Contains(err.Error(), user.NotFoundError) {
+			exception := exceptions.NewNotFoundException(err.Error())
+			return ctx.JSON(exception.Code(), exception)
+		}
+
+		exception := exceptions.NewInternalServerException(err.Error())
+		return ctx.JSON(exception.Code(), exception)
+	}
+
+	return ctx.JSON(http.StatusOK, page)
+}
+
 func validateTransactionRequest(ctx echo.Context) (transaction.Transaction, error) {
 	var transactionEntity transaction.Transaction
 	if err := ctx.Bind(&transactionEntity); err != nil {
@@ -216,3 +262,117 @@ func validateTransactionIDRequest(ctx echo.Context) (string, error) {
 
 	return id, nil
 }
+
+func validateListTransactionsRequest(ctx echo.Context) (transaction.ListOptions, error) {
+	options := transaction.ListOptions{
+		UserID:    ctx.Param("user_id"),
+		FromDate:  ctx.QueryParam("from"),
+		ToDate:    ctx.QueryParam("to"),
+		SortBy:    transaction.SortByDate,
+		SortOrder: transaction.SortAsc,
+		Limit:     transaction.DefaultListLimit,
+	}
+
+	if customStr.IsEmpty(options.UserID) {
+		return options, errors.New("missing param user_id")
+	}
+
+	if err := validateOptionalDates(options.FromDate, options.ToDate); err != nil {
+		return options, err
+	}
+
+	var err error
+	if options.MinAmount, err = parseOptionalAmount(ctx.QueryParam("min_amount"), "min_amount"); err != nil {
+		return options, err
+	}
+
+	if options.MaxAmount, err = parseOptionalAmount(ctx.QueryParam("max_amount"), "max_amount"); err != nil {
+		return options, err
+	}
+
+	if options.MinAmount != nil && options.MaxAmount != nil && *options.MinAmount > *options.MaxAmount {
+		return options, errors.New("min_amount cannot be greater than max_amount")
+	}
+
+	if transactionType := ctx.QueryParam("type"); !customStr.IsEmpty(transactionType) {
+		if transactionType != transaction.TypeCredit && transactionType != transaction.TypeDebit {
+			return options, errors.New("type must be credit or debit")
+		}
+		options.Type = transactionType
+	}
+
+	if sortBy := ctx.QueryParam("sort"); !customStr.IsEmpty(sortBy) {
+		if sortBy != transaction.SortByDate && sortBy != transaction.SortByAmount {
+			return options, errors.New("sort must be date or amount")
+		}
+		options.SortBy = sortBy
+	}
+
+	if sortOrder := ctx.QueryParam("order"); !customStr.IsEmpty(sortOrder) {
+		if sortOrder != transaction.SortAsc && sortOrder != transaction.SortDesc {
+			return options, errors.New("order must be asc or desc")
+		}
+		options.SortOrder = sortOrder
+	}
+
+	if limitParam := ctx.QueryParam("limit"); !customStr.IsEmpty(limitParam) {
+		limit, err := strconv.Atoi(limitParam)
+		if err != nil || limit < 1 || limit > transaction.MaxListLimit {
+			return options, fmt.Errorf("limit must be a number between 1 and %d", transaction.MaxListLimit)
+		}
+		options.Limit = limit
+	}
+
+	for _, include := range strings.Split(ctx.QueryParam("include"), ",") {
+		switch strings.TrimSpace(include) {
+		case customStr.Empty:
+		case "running_balance":
+			options.IncludeRunningBalance = true
+		default:
+			return options, fmt.Errorf("include value %s is not supported", include)
+		}
+	}
+
+	if cursorParam := ctx.QueryParam("cursor"); !customStr.IsEmpty(cursorParam) {
+		cursor, err := transaction.DecodeCursor(cursorParam, options.SortBy, options.SortOrder)
+		if err != nil {
+			return options, err
+		}
+		options.After = &cursor
+	}
+
+	return options, nil
+}
+
+func validateOptionalDates(fromDate, toDate string) error {
+	if !customStr.IsEmpty(fromDate) && !customStr.IsEmpty(toDate) {
+		return validateDates(fromDate, toDate)
+	}
+
+	if !customStr.IsEmpty(fromDate) {
+		if _, err := parseRequestDate(fromDate); err != nil {
+			return fmt.Errorf("invalid fromDate format: %v", err)
+		}
+	}
+
+	if !customStr.IsEmpty(toDate) {
+		if _, err := parseRequestDate(toDate); err != nil {
+			return fmt.Errorf("invalid toDate format: %v", err)
+		}
+	}
+
+	return nil
+}
+
+func parseOptionalAmount(value, fieldName string) (*float64, error) {
+	if customStr.IsEmpty(value) {
+		return nil, nil
+	}
+
+	amount, err := strconv.ParseFloat(value, 64)
+	if err != nil {
+		return nil, fmt.Errorf("%s must be a valid number", fieldName)
+	}
+
+	return &amount, nil
+}
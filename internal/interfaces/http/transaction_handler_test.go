@@ -9,6 +9,7 @@ import (
 
 	"github.com/sebastianreh/user-balance-api/cmd/httpserver"
 	"github.com/sebastianreh/user-balance-api/internal/domain/transaction"
+	"github.com/sebastianreh/user-balance-api/internal/domain/user"
 	localHttp "github.com/sebastianreh/user-balance-api/internal/interfaces/http"
 	"github.com/sebastianreh/user-balance-api/pkg/logger"
 	"github.com/sebastianreh/user-balance-api/test/mocks"
@@ -294,3 +295,127 @@ func TestTransactionHandler_DeleteTransaction(t *testing.T) {
 		assert.Equal(t, http.StatusInternalServerError, rec.Code)
 	})
 }
+
+func TestTransactionHandler_ListUserTransactions(t *testing.T) {
+	log := logger.NewLogger()
+
+	t.Run("it lists user transactions with default options", func(t *testing.T) {
+		serviceMock := mocks.NewTransactionServiceMock()
+
+		expectedOptions := transaction.ListOptions{
+			UserID:    "1",
+			SortBy:    transaction.SortByDate,
+			SortOrder: transaction.SortAsc,
+			Limit:     transaction.DefaultListLimit,
+		}
+		expectedPage := transaction.ListPage{
+			Transactions: []transaction.ListItem{{Transaction: transaction.Transaction{ID: "1", UserID: "1", Amount: 10}}},
+		}
+
+		context, rec := httpserver.SetupAsRecorderWithDynamicQueryParams(http.MethodGet, "/users", "1",
+			map[string]string{}, "")
+		serviceMock.On("ListUserTransactions", mock.Anything, expectedOptions).Return(expectedPage, nil)
+
+		handler := localHttp.NewTransactionHandler(log, serviceMock)
+		err := handler.ListUserTransactions(context)
+
+		var response transaction.ListPage
+		_ = json.Unmarshal(rec.Body.Bytes(), &response)
+
+		assert.Nil(t, err)
+		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.Equal(t, expectedPage, response)
+	})
+
+	t.Run("it lists user transactions with filters, sort and running balance", func(t *testing.T) {
+		serviceMock := mocks.NewTransactionServiceMock()
+
+		minAmount, maxAmount := -100.0, 500.5
+		cursor := transaction.Cursor{SortBy: transaction.SortByAmount, SortOrder: transaction.SortDesc, ID: "9", Amount: 20}
+		expectedOptions := transaction.ListOptions{
+			UserID:                "1",
+			FromDate:              "2024-01-01T00:00:00Z",
+			ToDate:                "2024-12-31T00:00:00Z",
+			MinAmount:             &minAmount,
+			MaxAmount:             &maxAmount,
+			Type:                  transaction.TypeDebit,
+			SortBy:                transaction.SortByAmount,
+			SortOrder:             transaction.SortDesc,
+			Limit:                 20,
+			IncludeRunningBalance: true,
+			After:                 &cursor,
+		}
+
+		queryParams := map[string]string{
+			"from": "2024-01-01T00:00:00Z", "to": "2024-12-31T00:00:00Z", "min_amount": "-100",
+			"max_amount": "500.5", "type": "debit", "sort": "amount", "order": "desc", "limit": "20",
+			"include": "running_balance", "cursor": cursor.Encode(),
+		}
+		context, rec := httpserver.SetupAsRecorderWithDynamicQueryParams(http.MethodGet, "/users", "1", queryParams, "")
+		serviceMock.On("ListUserTransactions", mock.Anything, expectedOptions).Return(transaction.ListPage{}, nil)
+
+		handler := localHttp.NewTransactionHandler(log, serviceMock)
+		err := handler.ListUserTransactions(context)
+
+		assert.Nil(t, err)
+		assert.Equal(t, http.StatusOK, rec.Code)
+		serviceMock.AssertCalled(t, "ListUserTransactions", mock.Anything, expectedOptions)
+	})
+
+	t.Run("it returns bad request for invalid query params", func(t *testing.T) {
+		invalidParams := []map[string]string{
+			{"from": "2024-01-01"},
+			{"from": "2024-12-31T00:00:00Z", "to": "2024-01-01T00:00:00Z"},
+			{"min_amount": "ten"},
+			{"min_amount": "10", "max_amount": "5"},
+			{"type": "refund"},
+			{"sort": "user"},
+			{"order": "up"},
+			{"limit": "1000"},
+			{"include": "balance"},
+			{"cursor": "invalid"},
+		}
+
+		for _, queryParams := range invalidParams {
+			serviceMock := mocks.NewTransactionServiceMock()
+			context, rec := httpserver.SetupAsRecorderWithDynamicQueryParams(http.MethodGet, "/users", "1", queryParams, "")
+
+			handler := localHttp.NewTransactionHandler(log, serviceMock)
+			err := handler.ListUserTransactions(context)
+
+			assert.Nil(t, err)
+			assert.Equal(t, http.StatusBadRequest, rec.Code, queryParams)
+			serviceMock.AssertNotCalled(t, "ListUserTransactions", mock.Anything, mock.Anything)
+		}
+	})
+
+	t.Run("it returns not found when the user does not exist", func(t *testing.T) {
+		serviceMock := mocks.NewTransactionServiceMock()
+
+		context, rec := httpserver.SetupAsRecorderWithDynamicQueryParams(http.MethodGet, "/users", "1",
+			map[string]string{}, "")
+		serviceMock.On("ListUserTransactions", mock.Anything, mock.Anything).
+			Return(transaction.ListPage{}, errors.New(user.NotFoundError))
+
+		handler := localHttp.NewTransactionHandler(log, serviceMock)
+		err := handler.ListUserTransactions(context)
+
+		assert.Nil(t, err)
+		assert.Equal(t, http.StatusNotFound, rec.Code)
+	})
+
+	t.Run("it returns internal server error when service fails", func(t *testing.T) {
+		serviceMock := mocks.NewTransactionServiceMock()
+
+		context, rec := httpserver.SetupAsRecorderWithDynamicQueryParams(http.MethodGet, "/users", "1",
+			map[string]string{}, "")
+		serviceMock.On("ListUserTransactions", mock.Anything, mock.Anything).
+			Return(transaction.ListPage{}, errors.New("service failure"))
+
+		handler := localHttp.NewTransactionHandler(log, serviceMock)
+		err := handler.ListUserTransactions(context)
+
+		assert.Nil(t, err)
+		assert.Equal(t, http.StatusInternalServerError, rec.Code)
+	})
+}
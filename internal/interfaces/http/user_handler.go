@@ -2,7 +2,9 @@ package http
 
 import (
 	"errors"
+	"fmt"
 	"net/http"
+	"strconv"
 	"strings"
 
 	"github.com/labstack/echo/v4"
@@ -38,6 +40,7 @@ func NewUserHandler(log logger.Logger, service services.UserService) *UserHandle
 // @Param user body user.User true "User Request Body"
 // @Success 201 {object} user.CreationResponse "User created successfully with the user_id"
 // @Failure 400 {object} exceptions.BadRequestException "Invalid input"
+// @Failure 409 {object} exceptions.DuplicatedException "Email already in use"
 // @Failure 500 {object} exceptions.InternalServerException "Internal server error"
 // @Router /users/create [post]
 func (u *UserHandler) CreateUser(ctx echo.Context) error {
@@ -50,6 +53,11 @@ func (u *UserHandler) CreateUser(ctx echo.Context) error {
 
 	createdID, err := u.service.CreateUser(ctx.Request().Context(), userEntity)
 	if err != nil {
+		if strings.Contains(err.Error(), user.DuplicateEmailError) {
+			exception := exceptions.NewDuplicatedException(err.Error())
+			return ctx.JSON(exception.Code(), exception)
+		}
+
 		exception := exceptions.NewInternalServerException(err.Error())
 		return ctx.JSON(exception.Code(), exception)
 	}
@@ -72,6 +80,7 @@ func (u *UserHandler) CreateUser(ctx echo.Context) error {
 // @Success 200 "User updated successfully"
 // @Failure 400 {object} exceptions.BadRequestException  "Invalid request or missing user ID"
 // @Failure 404 {object} exceptions.NotFoundException "User not found"
+// @Failure 409 {object} exceptions.DuplicatedException "Email already in use"
 // @Failure 500 {object} exceptions.InternalServerException"Internal server error"
 // @Router /users/{id} [put]
 func (u *UserHandler) UpdateUser(ctx echo.Context) error {
@@ -97,6 +106,11 @@ func (u *UserHandler) UpdateUser(ctx echo.Context) error {
 			return ctx.JSON(exception.Code(), exception)
 		}
 
+		if strings.Contains(err.Error(), user.DuplicateEmailError) {
+			exception := exceptions.NewDuplicatedException(err.Error())
+			return ctx.JSON(exception.Code(), exception)
+		}
+
 		exception := exceptions.NewInternalServerException(err.Error())
 		return ctx.JSON(exception.Code(), exception)
 	}
@@ -138,6 +152,69 @@ func (u *UserHandler) GetUser(ctx echo.Context) error {
 	return ctx.JSON(http.StatusOK, userEntity)
 }
 
+// ListUsers godoc
+// @Summary List users
+// @Description Lists users with keyset pagination. Pass the returned next_cursor as cursor to get the next page.
+// If "email" is provided the single matching user is returned instead, the lookup is case-insensitive.
+// @Tags users
+// @Accept json
+// @Produce json
+// @Param email query string false "User email, returns a single user"
+// @Param name query string false "First or last name prefix, case-insensitive"
+// @Param deleted query string false "Deleted status filter: false (default), true or all"
+// @Param sort query string false "Sort field: id (default), name or email"
+// @Param order query string false "Sort order: asc (default) or desc"
+// @Param limit query int false "Page size, from 1 to 500, defaults to 50"
+// @Param cursor query string false "Cursor returned by the previous page"
+// @Param include query string false "Set to balance to include each user's current balance"
+// @Success 200 {object} user.ListPage "Users page"
+// @Failure 400 {object} exceptions.BadRequestException "Invalid query params or cursor"
+// @Failure 404 {object} exceptions.NotFoundException "User not found when filtering by email"
+// @Failure 500 {object} exceptions.InternalServerException "Internal server error"
+// @Router /users [get]
+func (u *UserHandler) ListUsers(ctx echo.Context) error {
+	if isEmailLookupRequest(ctx) {
+		return u.GetUserByEmail(ctx)
+	}
+
+	options, err := validateListUsersRequest(ctx)
+	if err != nil {
+		u.log.ErrorAt(err, userHandlerName, "ListUsers")
+		exception := exceptions.NewBadRequestException(err.Error())
+		return ctx.JSON(exception.Code(), exception)
+	}
+
+	page, err := u.service.ListUsers(ctx.Request().Context(), options)
+	if err != nil {
+		exception := exceptions.NewInternalServerException(err.Error())
+		return ctx.JSON(exception.Code(), exception)
+	}
+
+	return ctx.JSON(http.StatusOK, page)
+}
+
+func (u *UserHandler) GetUserByEmail(ctx echo.Context) error {
+	email, err := validateUserEmailRequest(ctx)
+	if err != nil {
+		u.log.ErrorAt(err, userHandlerName, "GetUserByEmail")
+		exception := exceptions.NewBadRequestException(err.Error())
+		return ctx.JSON(exception.Code(), exception)
+	}
+
+	userEntity, err := u.service.GetUserByEmail(ctx.Request().Context(), email)
+	if err != nil {
+		if strings.Contains(err.Error(), user.NotFoundError) {
+			exception := exceptions.NewNotFoundException(err.Error())
+			return ctx.JSON(exception.Code(), exception)
+		}
+
+		exception := exceptions.NewInternalServerException(err.Error())
+		return ctx.JSON(exception.Code(), exception)
+	}
+
+	return ctx.JSON(http.StatusOK, userEntity)
+}
+
 // DeleteUser godoc
 // @Summary Delete a user by ID
 // @Description Soft delete a user by marking them as deleted
@@ -190,9 +267,91 @@ func validateUserRequest(ctx echo.Context) (user.User, error) {
 		return userEntity, errors.New("email is required")
 	}
 
+	if err := user.ValidateEmail(userEntity.Email); err != nil {
+		return userEntity, err
+	}
+
 	return userEntity, nil
 }
 
+func isEmailLookupRequest(ctx echo.Context) bool {
+	_, exists := ctx.QueryParams()["email"]
+	return exists
+}
+
+func validateListUsersRequest(ctx echo.Context) (user.ListOptions, error) {
+	options := user.ListOptions{
+		NamePrefix: strings.TrimSpace(ctx.QueryParam("name")),
+		Deleted:    user.DeletedExclude,
+		SortBy:     user.SortByID,
+		SortOrder:  user.SortAsc,
+		Limit:      user.DefaultListLimit,
+	}
+
+	if deleted := ctx.QueryParam("deleted"); !customStr.IsEmpty(deleted) {
+		if deleted != user.DeletedExclude && deleted != user.DeletedOnly && deleted != user.DeletedAll {
+			return options, errors.New("deleted must be one of false, true or all")
+		}
+		options.Deleted = deleted
+	}
+
+	if sortBy := ctx.QueryParam("sort"); !customStr.IsEmpty(sortBy) {
+		if sortBy != user.SortByID && sortBy != user.SortByName && sortBy != user.SortByEmail {
+			return options, errors.New("sort must be one of id, name or email")
+		}
+		options.SortBy = sortBy
+	}
+
+	if sortOrder := ctx.QueryParam("order"); !customStr.IsEmpty(sortOrder) {
+		if sortOrder != user.SortAsc && sortOrder != user.SortDesc {
+			return options, errors.New("order must be asc or desc")
+		}
+		options.SortOrder = sortOrder
+	}
+
+	if limitParam := ctx.QueryParam("limit"); !customStr.IsEmpty(limitParam) {
+		limit, err := strconv.Atoi(limitParam)
+		if err != nil || limit < 1 || limit > user.MaxListLimit {
+			return options, fmt.Errorf("limit must be a number between 1 and %d", user.MaxListLimit)
+		}
+		options.Limit = limit
+	}
+
+	for _, include := range strings.Split(ctx.QueryParam("include"), ",") {
+		switch strings.TrimSpace(include) {
+		case customStr.Empty:
+		case "balance":
+			options.IncludeBalance = true
+		default:
+			return options, fmt.Errorf("include value %s is not supported", include)
+		}
+	}
+
+	if cursorParam := ctx.QueryParam("cursor"); !customStr.IsEmpty(cursorParam) {
+		cursor, err := user.DecodeCursor(cursorParam, options.SortBy, options.SortOrder)
+		if err != nil {
+			return options, err
+		}
+		options.After = &cursor
+	}
+
+	return options, nil
+}
+
+func validateUserEmailRequest(ctx echo.Context) (string, error) {
+	email := ctx.QueryParam("email")
+
+	if customStr.IsEmpty(email) {
+		return email, errors.New("missing query param email")
+	}
+
+	if err := user.ValidateEmail(email); err != nil {
+		return email, err
+	}
+
+	return email, nil
+}
+
 func validateUserIDRequest(ctx echo.Context) (string, error) {
 	id := ctx.Param("id")
 
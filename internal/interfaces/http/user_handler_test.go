@@ -77,6 +77,39 @@ func TestUserHandler_CreateUser(t *testing.T) {
 		assert.Nil(t, err)
 		assert.Equal(t, http.StatusInternalServerError, rec.Code)
 	})
+	t.Run("it returns bad request when email is not valid", func(t *testing.T) {
+		serviceMock := mocks.NewUserServiceMock()
+
+		invalidUserRequest := `{"first_name": "user", "last_name": "lastname", "email": "user.example.com"}`
+		context, rec := httpserver.SetupAsRecorder(http.MethodPost, "/users/create", "", invalidUserRequest)
+
+		handler := localHttp.NewUserHandler(log, serviceMock)
+		err := handler.CreateUser(context)
+
+		assert.Nil(t, err)
+		assert.Equal(t, http.StatusBadRequest, rec.Code)
+		serviceMock.AssertNotCalled(t, "CreateUser", mock.Anything, mock.Anything)
+	})
+
+	t.Run("it returns conflict when email is already in use", func(t *testing.T) {
+		serviceMock := mocks.NewUserServiceMock()
+
+		userRequest := user.User{
+			FirstName: "user",
+			LastName:  "lastname",
+			Email:     "user@example.com",
+		}
+
+		requestBytes, _ := json.Marshal(userRequest)
+		context, rec := httpserver.SetupAsRecorder(http.MethodPost, "/users/create", "", string(requestBytes))
+		serviceMock.On("CreateUser", mock.Anything, userRequest).Return("", errors.New(user.DuplicateEmailError))
+
+		handler := localHttp.NewUserHandler(log, serviceMock)
+		err := handler.CreateUser(context)
+
+		assert.Nil(t, err)
+		assert.Equal(t, http.StatusConflict, rec.Code)
+	})
 }
 
 func TestUserHandler_GetUser(t *testing.T) {
@@ -249,6 +282,231 @@ func TestUserHandler_UpdateUser(t *testing.T) {
 		handler := localHttp.NewUserHandler(log, serviceMock)
 		err := handler.UpdateUser(context)
 
+		assert.Nil(t, err)
+		assert.Equal(t, http.StatusInternalServerError, rec.Code)
+	})
+	t.Run("it returns conflict when email is already in use", func(t *testing.T) {
+		serviceMock := mocks.NewUserServiceMock()
+
+		userRequest := user.User{
+			ID:        "1",
+			FirstName: "user",
+			LastName:  "lastname",
+			Email:     "user@example.com",
+		}
+
+		requestBytes, _ := json.Marshal(userRequest)
+		context, rec := httpserver.SetupAsRecorder(http.MethodPut, "/:id", userRequest.ID, string(requestBytes))
+		serviceMock.On("UpdateUser", mock.Anything, userRequest).Return(errors.New(user.DuplicateEmailError))
+
+		handler := localHttp.NewUserHandler(log, serviceMock)
+		err := handler.UpdateUser(context)
+
+		assert.Nil(t, err)
+		assert.Equal(t, http.StatusConflict, rec.Code)
+	})
+}
+
+func TestUserHandler_GetUserByEmail(t *testing.T) {
+	log := logger.NewLogger()
+
+	t.Run("it gets user by email successfully", func(t *testing.T) {
+		serviceMock := mocks.NewUserServiceMock()
+
+		expectedResponse := user.User{
+			ID:        "1",
+			FirstName: "user",
+			LastName:  "lastname",
+			Email:     "user@example.com",
+		}
+
+		context, rec := httpserver.SetupAsRecorder(http.MethodGet, "/users?email=User@Example.com", "", "")
+		serviceMock.On("GetUserByEmail", mock.Anything, "User@Example.com").Return(expectedResponse, nil)
+
+		handler := localHttp.NewUserHandler(log, serviceMock)
+		err := handler.GetUserByEmail(context)
+
+		var response user.User
+		_ = json.Unmarshal(rec.Body.Bytes(), &response)
+
+		assert.Nil(t, err)
+		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.Equal(t, expectedResponse, response)
+	})
+
+	t.Run("it returns bad request when email is missing", func(t *testing.T) {
+		serviceMock := mocks.NewUserServiceMock()
+
+		context, rec := httpserver.SetupAsRecorder(http.MethodGet, "/users", "", "")
+
+		handler := localHttp.NewUserHandler(log, serviceMock)
+		err := handler.GetUserByEmail(context)
+
+		assert.Nil(t, err)
+		assert.Equal(t, http.StatusBadRequest, rec.Code)
+		serviceMock.AssertNotCalled(t, "GetUserByEmail", mock.Anything, mock.Anything)
+	})
+
+	t.Run("it returns bad request when email is not valid", func(t *testing.T) {
+		serviceMock := mocks.NewUserServiceMock()
+
+		context, rec := httpserver.SetupAsRecorder(http.MethodGet, "/users?email=not-an-email", "", "")
+
+		handler := localHttp.NewUserHandler(log, serviceMock)
+		err := handler.GetUserByEmail(context)
+
+		assert.Nil(t, err)
+		assert.Equal(t, http.StatusBadRequest, rec.Code)
+		serviceMock.AssertNotCalled(t, "GetUserByEmail", mock.Anything, mock.Anything)
+	})
+
+	t.Run("it returns not found when user is not found", func(t *testing.T) {
+		serviceMock := mocks.NewUserServiceMock()
+
+		context, rec := httpserver.SetupAsRecorder(http.MethodGet, "/users?email=user@example.com", "", "")
+		serviceMock.On("GetUserByEmail", mock.Anything, "user@example.com").
+			Return(user.User{}, errors.New(user.NotFoundError))
+
+		handler := localHttp.NewUserHandler(log, serviceMock)
+		err := handler.GetUserByEmail(context)
+
+		assert.Nil(t, err)
+		assert.Equal(t, http.StatusNotFound, rec.Code)
+	})
+
+	t.Run("it returns internal server error when service fails", func(t *testing.T) {
+		serviceMock := mocks.NewUserServiceMock()
+
+		context, rec := httpserver.SetupAsRecorder(http.MethodGet, "/users?email=user@example.com", "", "")
+		serviceMock.On("GetUserByEmail", mock.Anything, "user@example.com").
+			Return(user.User{}, errors.New("service failure"))
+
+		handler := localHttp.NewUserHandler(log, serviceMock)
+		err := handler.GetUserByEmail(context)
+
+		assert.Nil(t, err)
+		assert.Equal(t, http.StatusInternalServerError, rec.Code)
+	})
+}
+
+func TestUserHandler_ListUsers(t *testing.T) {
+	log := logger.NewLogger()
+
+	t.Run("it lists users with default options", func(t *testing.T) {
+		serviceMock := mocks.NewUserServiceMock()
+
+		expectedOptions := user.ListOptions{
+			Deleted:   user.DeletedExclude,
+			SortBy:    user.SortByID,
+			SortOrder: user.SortAsc,
+			Limit:     user.DefaultListLimit,
+		}
+		expectedPage := user.ListPage{
+			Users:      []user.ListItem{{ID: "1", FirstName: "user", Email: "user@example.com"}},
+			NextCursor: "next",
+		}
+
+		context, rec := httpserver.SetupAsRecorder(http.MethodGet, "/users", "", "")
+		serviceMock.On("ListUsers", mock.Anything, expectedOptions).Return(expectedPage, nil)
+
+		handler := localHttp.NewUserHandler(log, serviceMock)
+		err := handler.ListUsers(context)
+
+		var response user.ListPage
+		_ = json.Unmarshal(rec.Body.Bytes(), &response)
+
+		assert.Nil(t, err)
+		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.Equal(t, expectedPage, response)
+	})
+
+	t.Run("it lists users with filters, sort and balance", func(t *testing.T) {
+		serviceMock := mocks.NewUserServiceMock()
+
+		cursor := user.Cursor{SortBy: user.SortByName, SortOrder: user.SortDesc, ID: "5", FirstName: "Ana", LastName: "Diaz"}
+		expectedOptions := user.ListOptions{
+			NamePrefix:     "an",
+			Deleted:        user.DeletedAll,
+			SortBy:         user.SortByName,
+			SortOrder:      user.SortDesc,
+			Limit:          10,
+			IncludeBalance: true,
+			After:          &cursor,
+		}
+
+		target := "/users?name=an&deleted=all&sort=name&order=desc&limit=10&include=balance&cursor=" + cursor.Encode()
+		context, rec := httpserver.SetupAsRecorder(http.MethodGet, target, "", "")
+		serviceMock.On("ListUsers", mock.Anything, expectedOptions).Return(user.ListPage{}, nil)
+
+		handler := localHttp.NewUserHandler(log, serviceMock)
+		err := handler.ListUsers(context)
+
+		assert.Nil(t, err)
+		assert.Equal(t, http.StatusOK, rec.Code)
+		serviceMock.AssertCalled(t, "ListUsers", mock.Anything, expectedOptions)
+	})
+
+	t.Run("it looks up a single user when email is provided", func(t *testing.T) {
+		serviceMock := mocks.NewUserServiceMock()
+
+		expectedResponse := user.User{ID: "1", FirstName: "user", LastName: "lastname", Email: "user@example.com"}
+		context, rec := httpserver.SetupAsRecorder(http.MethodGet, "/users?email=user@example.com", "", "")
+		serviceMock.On("GetUserByEmail", mock.Anything, "user@example.com").Return(expectedResponse, nil)
+
+		handler := localHttp.NewUserHandler(log, serviceMock)
+		err := handler.ListUsers(context)
+
+		assert.Nil(t, err)
+		assert.Equal(t, http.StatusOK, rec.Code)
+		serviceMock.AssertNotCalled(t, "ListUsers", mock.Anything, mock.Anything)
+	})
+
+	t.Run("it returns bad request for invalid query params", func(t *testing.T) {
+		invalidTargets := []string{
+			"/users?sort=age",
+			"/users?order=up",
+			"/users?deleted=maybe",
+			"/users?limit=0",
+			"/users?limit=501",
+			"/users?include=transactions",
+			"/users?cursor=invalid",
+		}
+
+		for _, target := range invalidTargets {
+			serviceMock := mocks.NewUserServiceMock()
+			context, rec := httpserver.SetupAsRecorder(http.MethodGet, target, "", "")
+
+			handler := localHttp.NewUserHandler(log, serviceMock)
+			err := handler.ListUsers(context)
+
+			assert.Nil(t, err)
+			assert.Equal(t, http.StatusBadRequest, rec.Code, target)
+			serviceMock.AssertNotCalled(t, "ListUsers", mock.Anything, mock.Anything)
+		}
+	})
+
+	t.Run("it returns bad request when cursor does not match the sort", func(t *testing.T) {
+		serviceMock := mocks.NewUserServiceMock()
+
+		cursor := user.Cursor{SortBy: user.SortByID, SortOrder: user.SortAsc, ID: "5"}
+		context, rec := httpserver.SetupAsRecorder(http.MethodGet, "/users?sort=email&cursor="+cursor.Encode(), "", "")
+
+		handler := localHttp.NewUserHandler(log, serviceMock)
+		err := handler.ListUsers(context)
+
+		assert.Nil(t, err)
+		assert.Equal(t, http.StatusBadRequest, rec.Code)
+	})
+
+	t.Run("it returns internal server error when service fails", func(t *testing.T) {
+		serviceMock := mocks.NewUserServiceMock()
+
+		context, rec := httpserver.SetupAsRecorder(http.MethodGet, "/users", "", "")
+		serviceMock.On("ListUsers", mock.Anything, mock.Anything).Return(user.ListPage{}, errors.New("service failure"))
+
+		handler := localHttp.NewUserHandler(log, serviceMock)
+		err := handler.ListUsers(context)
+
 		assert.Nil(t, err)
 		assert.Equal(t, http.StatusInternalServerError, rec.Code)
 	})
@@ -0,0 +1,305 @@
+package http
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/labstack/echo/v4"
+	"github.com/sebastianreh/user-balance-api/cmd/httpserver/exceptions"
+	"github.com/sebastianreh/user-balance-api/internal/app/services"
+	"github.com/sebastianreh/user-balance-api/internal/domain/webhook"
+	"github.com/sebastianreh/user-balance-api/pkg/logger"
+	customStr "github.com/sebastianreh/user-balance-api/pkg/strings"
+)
+
+const (
+	webhookHandlerName = "WebhookHandler"
+)
+
+type WebhookHandler struct {
+	log     logger.Logger
+	service services.WebhookService
+}
+
+func NewWebhookHandler(log logger.Logger, service services.WebhookService) *WebhookHandler {
+	return &WebhookHandler{
+		log:     log,
+		service: service,
+	}
+}
+
+// CreateWebhook godoc
+// @Summary Create a webhook subscription
+// @Description Subscribes a URL to transaction.created, transaction.deleted, user.deleted, migration.completed or
+// @Description migration.rolled_back.
+// @Description Every delivery is a POST signed with the secret of the subscription, which is only returned here
+// @Tags Webhooks
+// @Accept json
+// @Produce json
+// @Param subscription body webhook.Subscription true "Webhook subscription, active defaults to true"
+// @Success 201 {object} webhook.Subscription "Created subscription with its secret"
+// @Failure 400 {object} exceptions.BadRequestException "Invalid subscription"
+// @Failure 500 {object} exceptions.InternalServerException "Internal server error"
+// @Router /webhooks [post]
+func (h *WebhookHandler) CreateWebhook(ctx echo.Context) error {
+	subscription, err := validateWebhookRequest(ctx)
+	if err != nil {
+		h.log.ErrorAt(err, webhookHandlerName, "CreateWebhook")
+		exception := exceptions.NewBadRequestException(err.Error())
+		return ctx.JSON(exception.Code(), exception)
+	}
+
+	subscription, err = h.service.CreateSubscription(ctx.Request().Context(), subscription)
+	if err != nil {
+		exception := exceptions.NewInternalServerException(err.Error())
+		return ctx.JSON(exception.Code(), exception)
+	}
+
+	return ctx.JSON(http.StatusCreated, subscription)
+}
+
+// ListWebhooks godoc
+// @Summary List webhook subscriptions
+// @Description Lists every webhook subscription without its secret
+// @Tags Webhooks
+// @Produce json
+// @Success 200 {array} webhook.Subscription "Webhook subscriptions"
+// @Failure 500 {object} exceptions.InternalServerException "Internal server error"
+// @Router /webhooks [get]
+func (h *WebhookHandler) ListWebhooks(ctx echo.Context) error {
+	subscriptions, err := h.service.ListSubscriptions(ctx.Request().Context())
+	if err != nil {
+		exception := exceptions.NewInternalServerException(err.Error())
+		return ctx.JSON(exception.Code(), exception)
+	}
+
+	return ctx.JSON(http.StatusOK, subscriptions)
+}
+
+// GetWebhook godoc
+// @Summary Get a webhook subscription
+// @Description Returns a webhook subscription without its secret
+// @Tags Webhooks
+// @Produce json
+// @Param webhook_id path string true "Webhook subscription ID"
+// @Success 200 {object} webhook.Subscription "Webhook subscription"
+// @Failure 400 {object} exceptions.BadRequestException "Invalid webhook ID"
+// @Failure 404 {object} exceptions.NotFoundException "Webhook subscription not found"
+// @Failure 500 {object} exceptions.InternalServerException "Internal server error"
+// @Router /webhooks/{webhook_id} [get]
+func (h *WebhookHandler) GetWebhook(ctx echo.Context) error {
+	subscriptionID, err := validateNumericParam(ctx, "webhook_id")
+	if err != nil {
+		h.log.ErrorAt(err, webhookHandlerName, "GetWebhook")
+		exception := exceptions.NewBadRequestException(err.Error())
+		return ctx.JSON(exception.Code(), exception)
+	}
+
+	subscription, err := h.service.GetSubscription(ctx.Request().Context(), subscriptionID)
+	if err != nil {
+		if strings.Contains(err.Error(), webhook.NotFoundError) {
+			exception := exceptions.NewNotFoundException(err.Error())
+			return ctx.JSON(exception.Code(), exception)
+		}
+
+		exception := exceptions.NewInternalServerException(err.Error())
+		return ctx.JSON(exception.Code(), exception)
+	}
+
+	return ctx.JSON(http.StatusOK, subscription)
+}
+
+// UpdateWebhook godoc
+// @Summary Update a webhook subscription
+// @Description Replaces the URL, description, events and active flag of a subscription, its secret is kept. A paused
+// @Description subscription gets no new events
+// @Tags Webhooks
+// @Accept json
+// @Produce json
+// @Param webhook_id path string true "Webhook subscription ID"
+// @Param subscription body webhook.Subscription true "Webhook subscription, active defaults to true"
+// @Success 200 {object} webhook.Subscription "Updated subscription"
+// @Failure 400 {object} exceptions.BadRequestException "Invalid webhook ID or subscription"
+// @Failure 404 {object} exceptions.NotFoundException "Webhook subscription not found"
+// @Failure 500 {object} exceptions.InternalServerException "Internal server error"
+// @Router /webhooks/{webhook_id} [put]
+func (h *WebhookHandler) UpdateWebhook(ctx echo.Context) error {
+	subscriptionID, err := validateNumericParam(ctx, "webhook_id")
+	if err != nil {
+		h.log.ErrorAt(err, webhookHandlerName, "UpdateWebhook")
+		exception := exceptions.NewBadRequestException(err.Error())
+		return ctx.JSON(exception.Code(), exception)
+	}
+
+	subscription, err := validateWebhookRequest(ctx)
+	if err != nil {
+		h.log.ErrorAt(err, webhookHandlerName, "UpdateWebhook")
+		exception := exceptions.NewBadRequestException(err.Error())
+		return ctx.JSON(exception.Code(), exception)
+	}
+
+	subscription.ID = subscriptionID
+	subscription, err = h.service.UpdateSubscription(ctx.Request().Context(), subscription)
+	if err != nil {
+		if strings.Contains(err.Error(), webhook.NotFoundError) {
+			exception := exceptions.NewNotFoundException(err.Error())
+			return ctx.JSON(exception.Code(), exception)
+		}
+
+		exception := exceptions.NewInternalServerException(err.Error())
+		return ctx.JSON(exception.Code(), exception)
+	}
+
+	return ctx.JSON(http.StatusOK, subscription)
+}
+
+// DeleteWebhook godoc
+// @Summary Delete a webhook subscription
+// @Description Deletes a webhook subscription, its pending deliveries fail and its delivery log is kept in the outbox
+// @Tags Webhooks
+// @Param webhook_id path string true "Webhook subscription ID"
+// @Success 200 "No Content"
+// @Failure 400 {object} exceptions.BadRequestException "Invalid webhook ID"
+// @Failure 404 {object} exceptions.NotFoundException "Webhook subscription not found"
+// @Failure 500 {object} exceptions.InternalServerException "Internal server error"
+// @Router /webhooks/{webhook_id} [delete]
+func (h *WebhookHandler) DeleteWebhook(ctx echo.Context) error {
+	subscriptionID, err := validateNumericParam(ctx, "webhook_id")
+	if err != nil {
+		h.log.ErrorAt(err, webhookHandlerName, "DeleteWebhook")
+		exception := exceptions.NewBadRequestException(err.Error())
+		return ctx.JSON(exception.Code(), exception)
+	}
+
+	if err = h.service.DeleteSubscription(ctx.Request().Context(), subscriptionID); err != nil {
+		if strings.Contains(err.Error(), webhook.NotFoundError) {
+			exception := exceptions.NewNotFoundException(err.Error())
+			return ctx.JSON(exception.Code(), exception)
+		}
+
+		exception := exceptions.NewInternalServerException(err.Error())
+		return ctx.JSON(exception.Code(), exception)
+	}
+
+	return ctx.NoContent(http.StatusOK)
+}
+
+// ListWebhookDeliveries godoc
+// @Summary List the deliveries of a webhook subscription
+// @Description Lists the deliveries of a subscription from the newest with every attempt to send them, with keyset
+// @Description pagination. A failed delivery is retried with backoff until it runs out of attempts
+// @Tags Webhooks
+// @Produce json
+// @Param webhook_id path string true "Webhook subscription ID"
+// @Param status query string false "pending, sent or failed"
+// @Param limit query int false "Page size, from 1 to 500, defaults to 50"
+// @Param cursor query string false "Cursor returned by the previous page"
+// @Success 200 {object} outbox.ListPage "Deliveries page"
+// @Failure 400 {object} exceptions.BadRequestException "Invalid webhook ID, query params or cursor"
+// @Failure 404 {object} exceptions.NotFoundException "Webhook subscription not found"
+// @Failure 500 {object} exceptions.InternalServerException "Internal server error"
+// @Router /webhooks/{webhook_id}/deliveries [get]
+func (h *WebhookHandler) ListWebhookDeliveries(ctx echo.Context) error {
+	subscriptionID, err := validateNumericParam(ctx, "webhook_id")
+	if err != nil {
+		h.log.ErrorAt(err, webhookHandlerName, "ListWebhookDeliveries")
+		exception := exceptions.NewBadRequestException(err.Error())
+		return ctx.JSON(exception.Code(), exception)
+	}
+
+	options, err := validateListOutboxRequest(ctx)
+	if err != nil {
+		h.log.ErrorAt(err, webhookHandlerName, "ListWebhookDeliveries")
+		exception := exceptions.NewBadRequestException(err.Error())
+		return ctx.JSON(exception.Code(), exception)
+	}
+
+	page, err := h.service.ListDeliveries(ctx.Request().Context(), subscriptionID, options)
+	if err != nil {
+		if strings.Contains(err.Error(), webhook.NotFoundError) {
+			exception := exceptions.NewNotFoundException(err.Error())
+			return ctx.JSON(exception.Code(), exception)
+		}
+
+		exception := exceptions.NewInternalServerException(err.Error())
+		return ctx.JSON(exception.Code(), exception)
+	}
+
+	return ctx.JSON(http.StatusOK, page)
+}
+
+// ReplayWebhookDelivery godoc
+// @Summary Replay a webhook delivery
+// @Description Sends a delivery of the subscription again as a new delivery with the same event ID and body, so the
+// @Description receiver can tell it's a repeated event
+// @Tags Webhooks
+// @Produce json
+// @Param webhook_id path string true "Webhook subscription ID"
+// @Param delivery_id path string true "Delivery ID"
+// @Success 202 {object} outbox.Message "New delivery"
+// @Failure 400 {object} exceptions.BadRequestException "Invalid webhook or delivery ID"
+// @Failure 404 {object} exceptions.NotFoundException "Webhook subscription or delivery not found"
+// @Failure 500 {object} exceptions.InternalServerException "Internal server error"
+// @Router /webhooks/{webhook_id}/deliveries/{delivery_id}/replay [post]
+func (h *WebhookHandler) ReplayWebhookDelivery(ctx echo.Context) error {
+	subscriptionID, err := validateNumericParam(ctx, "webhook_id")
+	if err != nil {
+		h.log.ErrorAt(err, webhookHandlerName, "ReplayWebhookDelivery")
+		exception := exceptions.NewBadRequestException(err.Error())
+		return ctx.JSON(exception.Code(), exception)
+	}
+
+	deliveryID, err := validateNumericParam(ctx, "delivery_id")
+	if err != nil {
+		h.log.ErrorAt(err, webhookHandlerName, "ReplayWebhookDelivery")
+		exception := exceptions.NewBadRequestException(err.Error())
+		return ctx.JSON(exception.Code(), exception)
+	}
+
+	message, err := h.service.ReplayDelivery(ctx.Request().Context(), subscriptionID, deliveryID)
+	if err != nil {
+		if strings.Contains(err.Error(), webhook.NotFoundError) ||
+			strings.Contains(err.Error(), webhook.DeliveryNotFoundError) {
+			exception := exceptions.NewNotFoundException(err.Error())
+			return ctx.JSON(exception.Code(), exception)
+		}
+
+		exception := exceptions.NewInternalServerException(err.Error())
+		return ctx.JSON(exception.Code(), exception)
+	}
+
+	return ctx.JSON(http.StatusAccepted, message)
+}
+
+func validateWebhookRequest(ctx echo.Context) (webhook.Subscription, error) {
+	subscription := webhook.Subscription{Active: true}
+	if err := ctx.Bind(&subscription); err != nil {
+		return subscription, errors.New("invalid request body")
+	}
+
+	// The server sets these, a request can't choose them
+	subscription.ID, subscription.Secret = "", ""
+	subscription.URL = strings.TrimSpace(subscription.URL)
+	if err := subscription.Validate(); err != nil {
+		return subscription, err
+	}
+
+	return subscription, nil
+}
+
+// validateNumericParam checks a path param that identifies a row by a sequence, anything else would fail in the
+// database
+func validateNumericParam(ctx echo.Context, name string) (string, error) {
+	value := ctx.Param(name)
+	if customStr.IsEmpty(value) {
+		return value, errors.New("missing param " + name)
+	}
+
+	if _, err := strconv.ParseInt(value, 10, 64); err != nil {
+		return value, errors.New(name + " must be numeric")
+	}
+
+	return value, nil
+}
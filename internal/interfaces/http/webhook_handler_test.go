@@ -0,0 +1,261 @@
+package http_test
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"testing"
+
+	"github.com/sebastianreh/user-balance-api/cmd/httpserver"
+	"github.com/sebastianreh/user-balance-api/internal/domain/outbox"
+	"github.com/sebastianreh/user-balance-api/internal/domain/webhook"
+	localHttp "github.com/sebastianreh/user-balance-api/internal/interfaces/http"
+	"github.com/sebastianreh/user-balance-api/pkg/logger"
+	"github.com/sebastianreh/user-balance-api/test/mocks"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func TestWebhookHandler_CreateWebhook(t *testing.T) {
+	log := logger.NewLogger()
+
+	t.Run("it creates an active subscription and returns its secret", func(t *testing.T) {
+		serviceMock := mocks.NewWebhookServiceMock()
+		body := `{"url":" https://example.com/hooks ","events":["transaction.created"],"secret":"mine"}`
+		expected := webhook.Subscription{URL: "https://example.com/hooks", Active: true,
+			Events: []string{webhook.EventTransactionCreated}}
+		created := expected
+		created.ID, created.Secret = "1", "whsec_1"
+
+		context, rec := httpserver.SetupAsRecorder(http.MethodPost, "/webhooks", "", body)
+		serviceMock.On("CreateSubscription", mock.Anything, expected).Return(created, nil)
+
+		handler := localHttp.NewWebhookHandler(log, serviceMock)
+		err := handler.CreateWebhook(context)
+
+		var response webhook.Subscription
+		_ = json.Unmarshal(rec.Body.Bytes(), &response)
+
+		assert.Nil(t, err)
+		assert.Equal(t, http.StatusCreated, rec.Code)
+		assert.Equal(t, "whsec_1", response.Secret)
+	})
+
+	t.Run("it returns bad request for an unknown event", func(t *testing.T) {
+		serviceMock := mocks.NewWebhookServiceMock()
+		body := `{"url":"https://example.com/hooks","events":["balance.changed"]}`
+
+		context, rec := httpserver.SetupAsRecorder(http.MethodPost, "/webhooks", "", body)
+
+		handler := localHttp.NewWebhookHandler(log, serviceMock)
+		err := handler.CreateWebhook(context)
+
+		assert.Nil(t, err)
+		assert.Equal(t, http.StatusBadRequest, rec.Code)
+		serviceMock.AssertNotCalled(t, "CreateSubscription", mock.Anything, mock.Anything)
+	})
+
+	t.Run("it returns bad request for a relative url", func(t *testing.T) {
+		serviceMock := mocks.NewWebhookServiceMock()
+		body := `{"url":"/hooks","events":["user.deleted"]}`
+
+		context, rec := httpserver.SetupAsRecorder(http.MethodPost, "/webhooks", "", body)
+
+		handler := localHttp.NewWebhookHandler(log, serviceMock)
+		err := handler.CreateWebhook(context)
+
+		assert.Nil(t, err)
+		assert.Equal(t, http.StatusBadRequest, rec.Code)
+	})
+}
+
+func TestWebhookHandler_UpdateWebhook(t *testing.T) {
+	log := logger.NewLogger()
+	body := `{"url":"https://example.com/hooks","events":["user.deleted"],"active":false}`
+
+	t.Run("it pauses the subscription", func(t *testing.T) {
+		serviceMock := mocks.NewWebhookServiceMock()
+		expected := webhook.Subscription{ID: "1", URL: "https://example.com/hooks",
+			Events: []string{webhook.EventUserDeleted}}
+
+		context, rec := httpserver.SetupAsRecorderWithIDField(http.MethodPut, "/webhooks/:webhook_id", "1", body,
+			"webhook_id")
+		serviceMock.On("UpdateSubscription", mock.Anything, expected).Return(expected, nil)
+
+		handler := localHttp.NewWebhookHandler(log, serviceMock)
+		err := handler.UpdateWebhook(context)
+
+		assert.Nil(t, err)
+		assert.Equal(t, http.StatusOK, rec.Code)
+		serviceMock.AssertExpectations(t)
+	})
+
+	t.Run("it returns not found when the subscription does not exist", func(t *testing.T) {
+		serviceMock := mocks.NewWebhookServiceMock()
+
+		context, rec := httpserver.SetupAsRecorderWithIDField(http.MethodPut, "/webhooks/:webhook_id", "9", body,
+			"webhook_id")
+		serviceMock.On("UpdateSubscription", mock.Anything, mock.Anything).
+			Return(webhook.Subscription{}, errors.New(webhook.NotFoundError))
+
+		handler := localHttp.NewWebhookHandler(log, serviceMock)
+		err := handler.UpdateWebhook(context)
+
+		assert.Nil(t, err)
+		assert.Equal(t, http.StatusNotFound, rec.Code)
+	})
+}
+
+func TestWebhookHandler_GetWebhook(t *testing.T) {
+	log := logger.NewLogger()
+
+	t.Run("it returns the subscription", func(t *testing.T) {
+		serviceMock := mocks.NewWebhookServiceMock()
+		subscription := webhook.Subscription{ID: "1", URL: "https://example.com/hooks"}
+
+		context, rec := httpserver.SetupAsRecorderWithIDField(http.MethodGet, "/webhooks/:webhook_id", "1", "",
+			"webhook_id")
+		serviceMock.On("GetSubscription", mock.Anything, "1").Return(subscription, nil)
+
+		handler := localHttp.NewWebhookHandler(log, serviceMock)
+		err := handler.GetWebhook(context)
+
+		assert.Nil(t, err)
+		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.NotContains(t, rec.Body.String(), "secret")
+	})
+
+	t.Run("it returns bad request for a non numeric id", func(t *testing.T) {
+		serviceMock := mocks.NewWebhookServiceMock()
+
+		context, rec := httpserver.SetupAsRecorderWithIDField(http.MethodGet, "/webhooks/:webhook_id", "abc", "",
+			"webhook_id")
+
+		handler := localHttp.NewWebhookHandler(log, serviceMock)
+		err := handler.GetWebhook(context)
+
+		assert.Nil(t, err)
+		assert.Equal(t, http.StatusBadRequest, rec.Code)
+		serviceMock.AssertNotCalled(t, "GetSubscription", mock.Anything, mock.Anything)
+	})
+}
+
+func TestWebhookHandler_DeleteWebhook(t *testing.T) {
+	log := logger.NewLogger()
+
+	t.Run("it deletes the subscription", func(t *testing.T) {
+		serviceMock := mocks.NewWebhookServiceMock()
+
+		context, rec := httpserver.SetupAsRecorderWithIDField(http.MethodDelete, "/webhooks/:webhook_id", "1", "",
+			"webhook_id")
+		serviceMock.On("DeleteSubscription", mock.Anything, "1").Return(nil)
+
+		handler := localHttp.NewWebhookHandler(log, serviceMock)
+		err := handler.DeleteWebhook(context)
+
+		assert.Nil(t, err)
+		assert.Equal(t, http.StatusOK, rec.Code)
+	})
+
+	t.Run("it returns not found when the subscription does not exist", func(t *testing.T) {
+		serviceMock := mocks.NewWebhookServiceMock()
+
+		context, rec := httpserver.SetupAsRecorderWithIDField(http.MethodDelete, "/webhooks/:webhook_id", "9", "",
+			"webhook_id")
+		serviceMock.On("DeleteSubscription", mock.Anything, "9").Return(errors.New(webhook.NotFoundError))
+
+		handler := localHttp.NewWebhookHandler(log, serviceMock)
+		err := handler.DeleteWebhook(context)
+
+		assert.Nil(t, err)
+		assert.Equal(t, http.StatusNotFound, rec.Code)
+	})
+}
+
+func TestWebhookHandler_ListWebhookDeliveries(t *testing.T) {
+	log := logger.NewLogger()
+
+	t.Run("it lists the failed deliveries of the subscription", func(t *testing.T) {
+		serviceMock := mocks.NewWebhookServiceMock()
+		page := outbox.ListPage{Messages: []outbox.Message{{ID: "8", Status: outbox.StatusFailed}}}
+		options := outbox.ListOptions{Status: outbox.StatusFailed, Limit: outbox.DefaultListLimit}
+
+		context, rec := httpserver.SetupAsRecorderWithIDField(http.MethodGet, "/webhooks/:webhook_id/deliveries", "1",
+			"", "webhook_id")
+		context.Request().URL.RawQuery = "status=failed"
+		serviceMock.On("ListDeliveries", mock.Anything, "1", options).Return(page, nil)
+
+		handler := localHttp.NewWebhookHandler(log, serviceMock)
+		err := handler.ListWebhookDeliveries(context)
+
+		assert.Nil(t, err)
+		assert.Equal(t, http.StatusOK, rec.Code)
+		serviceMock.AssertExpectations(t)
+	})
+
+	t.Run("it returns not found when the subscription does not exist", func(t *testing.T) {
+		serviceMock := mocks.NewWebhookServiceMock()
+
+		context, rec := httpserver.SetupAsRecorderWithIDField(http.MethodGet, "/webhooks/:webhook_id/deliveries", "9",
+			"", "webhook_id")
+		serviceMock.On("ListDeliveries", mock.Anything, "9", mock.Anything).
+			Return(outbox.ListPage{}, errors.New(webhook.NotFoundError))
+
+		handler := localHttp.NewWebhookHandler(log, serviceMock)
+		err := handler.ListWebhookDeliveries(context)
+
+		assert.Nil(t, err)
+		assert.Equal(t, http.StatusNotFound, rec.Code)
+	})
+}
+
+func TestWebhookHandler_ReplayWebhookDelivery(t *testing.T) {
+	log := logger.NewLogger()
+
+	t.Run("it replays the delivery", func(t *testing.T) {
+		serviceMock := mocks.NewWebhookServiceMock()
+
+		context, rec := httpserver.SetupAsRecorder(http.MethodPost, "/webhooks/1/deliveries/8/replay", "", "")
+		context.SetParamNames("webhook_id", "delivery_id")
+		context.SetParamValues("1", "8")
+		serviceMock.On("ReplayDelivery", mock.Anything, "1", "8").
+			Return(outbox.Message{ID: "10", Status: outbox.StatusPending}, nil)
+
+		handler := localHttp.NewWebhookHandler(log, serviceMock)
+		err := handler.ReplayWebhookDelivery(context)
+
+		assert.Nil(t, err)
+		assert.Equal(t, http.StatusAccepted, rec.Code)
+	})
+
+	t.Run("it returns not found when the delivery is not of the subscription", func(t *testing.T) {
+		serviceMock := mocks.NewWebhookServiceMock()
+
+		context, rec := httpserver.SetupAsRecorder(http.MethodPost, "/webhooks/1/deliveries/8/replay", "", "")
+		context.SetParamNames("webhook_id", "delivery_id")
+		context.SetParamValues("1", "8")
+		serviceMock.On("ReplayDelivery", mock.Anything, "1", "8").
+			Return(outbox.Message{}, errors.New(webhook.DeliveryNotFoundError))
+
+		handler := localHttp.NewWebhookHandler(log, serviceMock)
+		err := handler.ReplayWebhookDelivery(context)
+
+		assert.Nil(t, err)
+		assert.Equal(t, http.StatusNotFound, rec.Code)
+	})
+
+	t.Run("it returns bad request for a non numeric delivery id", func(t *testing.T) {
+		serviceMock := mocks.NewWebhookServiceMock()
+
+		context, rec := httpserver.SetupAsRecorder(http.MethodPost, "/webhooks/1/deliveries/x/replay", "", "")
+		context.SetParamNames("webhook_id", "delivery_id")
+		context.SetParamValues("1", "x")
+
+		handler := localHttp.NewWebhookHandler(log, serviceMock)
+		err := handler.ReplayWebhookDelivery(context)
+
+		assert.Nil(t, err)
+		assert.Equal(t, http.StatusBadRequest, rec.Code)
+		serviceMock.AssertNotCalled(t, "ReplayDelivery", mock.Anything, mock.Anything, mock.Anything)
+	})
+}
@@ -1,80 +1,96 @@
 package csv
 
 import (
-	"bytes"
 	"encoding/csv"
 	"errors"
+	"fmt"
 	"io"
-	"mime/multipart"
-)
+	"strings"
 
-const (
-	minRecords = 2
+	"github.com/sebastianreh/user-balance-api/pkg/records"
 )
 
-type CsvProcessor interface {
-	ReadFile(file *multipart.FileHeader, recordValidator func(record []string) error) ([][]string, error)
-}
-
-type csvProcessor struct{}
-
-func NewCsvProcessor() CsvProcessor {
-	return &csvProcessor{}
+// NewCsvProcessor reads files with a header row followed by one record per line
+func NewCsvProcessor() records.Source {
+	return records.NewSource(readRecords)
 }
 
-func (c *csvProcessor) ReadFile(file *multipart.FileHeader, recordValidator func(record []string) error) ([][]string, error) {
-	records := make([][]string, 0)
-
-	src, err := file.Open()
-	if err != nil {
-		return records, err
-	}
-	defer src.Close()
-
-	var fileBytes []byte
-	fileBytes, err = io.ReadAll(src)
-	if err != nil {
-		return records, err
-	}
+// readRecords goes through the records after the header, a parse error is handed to the handler with the record
+// and the reading goes on from the next line. The fields are sorted by the mapping when there is one
+func readRecords(src io.Reader, mapping *records.Mapping, handle func(record records.Record) error) error {
+	capture := &rawCapture{src: src}
+	reader := csv.NewReader(capture)
 
-	records, err = csvBytesToRecords(fileBytes, recordValidator)
+	// Read the header and skip it to parse the records
+	header, err := reader.Read()
 	if err != nil {
-		return records, err
+		return err
 	}
+	capture.take(reader.InputOffset())
 
-	return records, nil
-}
-
-func csvBytesToRecords(csvBytes []byte, validator func(record []string) error) ([][]string, error) {
-	var records [][]string
-	reader := csv.NewReader(bytes.NewReader(csvBytes))
-
-	// Read the header and skip it to parse the records
-	_, err := reader.Read()
-	if err != nil {
-		return records, err
+	var bound *records.Binding
+	if mapping != nil {
+		if bound, err = mapping.Bind(header); err != nil {
+			return fmt.Errorf("header: %w", err)
+		}
 	}
 
 	for {
-		record, err := reader.Read()
-		if err != nil {
-			if err.Error() != "EOF" {
-				return records, err
-			}
-			break
+		fields, err := reader.Read()
+		if errors.Is(err, io.EOF) {
+			return nil
 		}
 
-		err = validator(record)
-		if err != nil {
-			return records, err
+		record := records.Record{Fields: fields, Raw: capture.take(reader.InputOffset())}
+		var parseErr *csv.ParseError
+		switch {
+		case errors.As(err, &parseErr):
+			record.Line = parseErr.StartLine
+			record.Fields = nil
+			record.Err = err
+		case err != nil:
+			return err
+		default:
+			record.Line, _ = reader.FieldPos(0)
+			if bound != nil {
+				record.Fields = bound.Apply(fields)
+			}
 		}
 
-		records = append(records, record)
+		if err = handle(record); err != nil {
+			return err
+		}
 	}
+}
 
-	if len(records) < minRecords {
-		return records, errors.New("error reading csv bytes - records < 2")
+// rawCapture keeps the bytes read by the csv reader until the record they belong to is taken, so it holds at most
+// the reader buffer plus one record
+type rawCapture struct {
+	src    io.Reader
+	buf    []byte
+	start  int
+	offset int64
+}
+
+func (r *rawCapture) Read(p []byte) (int, error) {
+	// Taken bytes are dropped only once they are most of the buffer, so they're not moved on every record
+	if r.start > len(r.buf)/2 {
+		r.buf = append(r.buf[:0], r.buf[r.start:]...)
+		r.start = 0
 	}
 
-	return records, nil
+	n, err := r.src.Read(p)
+	r.buf = append(r.buf, p[:n]...)
+	return n, err
+}
+
+func (r *rawCapture) take(end int64) string {
+	end = min(end, r.offset+int64(len(r.buf)-r.start))
+	size := int(end - r.offset)
+
+	raw := string(r.buf[r.start : r.start+size])
+	r.start += size
+	r.offset = end
+
+	return strings.TrimRight(raw, "\r\n")
 }
@@ -0,0 +1,185 @@
+package csv_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/sebastianreh/user-balance-api/pkg/csv"
+	"github.com/sebastianreh/user-balance-api/pkg/records"
+	"github.com/stretchr/testify/assert"
+)
+
+const header = "id,user_id,amount,datetime\n"
+
+func Test_CsvProcessor_Validate(t *testing.T) {
+	processor := csv.NewCsvProcessor()
+	validator := func(record []string) error {
+		if record[2] == "0" {
+			return errors.New("amount must be different from zero")
+		}
+		return nil
+	}
+
+	t.Run("When every record is valid", func(t *testing.T) {
+		src := strings.NewReader(header + "1,1,10,2024-01-01T00:00:00Z\n2,1,-5,2024-01-01T00:00:00Z\n")
+
+		records, err := processor.Validate(src, nil, validator)
+
+		assert.Nil(t, err)
+		assert.Equal(t, 2, records)
+	})
+
+	t.Run("When a record is invalid the error has its line number", func(t *testing.T) {
+		src := strings.NewReader(header + "1,1,10,2024-01-01T00:00:00Z\n2,1,0,2024-01-01T00:00:00Z\n")
+
+		_, err := processor.Validate(src, nil, validator)
+
+		assert.EqualError(t, err, "line 3: amount must be different from zero")
+	})
+
+	t.Run("When a record has a different number of fields", func(t *testing.T) {
+		src := strings.NewReader(header + "1,1,10\n")
+
+		_, err := processor.Validate(src, nil, validator)
+
+		assert.ErrorContains(t, err, "line 2")
+	})
+
+	t.Run("When a line can't be parsed", func(t *testing.T) {
+		src := strings.NewReader(header + "1,\"1,10,2024-01-01T00:00:00Z\n")
+
+		_, err := processor.Validate(src, nil, validator)
+
+		assert.ErrorContains(t, err, "line 2")
+	})
+
+	t.Run("When the file has less than the minimum records", func(t *testing.T) {
+		src := strings.NewReader(header + "1,1,10,2024-01-01T00:00:00Z\n")
+
+		_, err := processor.Validate(src, nil, validator)
+
+		assert.EqualError(t, err, "the file must have at least 2 records")
+	})
+}
+
+func Test_CsvProcessor_Count(t *testing.T) {
+	processor := csv.NewCsvProcessor()
+
+	t.Run("When the file has lines that can't be parsed", func(t *testing.T) {
+		src := strings.NewReader(header + "1,1,10,a\n2,1\n3,1,10,c\n")
+
+		records, err := processor.Count(src)
+
+		assert.Nil(t, err)
+		assert.Equal(t, 3, records)
+	})
+}
+
+func Test_CsvProcessor_StreamBatches(t *testing.T) {
+	ctx := context.TODO()
+	processor := csv.NewCsvProcessor()
+
+	t.Run("When the records are split in batches", func(t *testing.T) {
+		src := strings.NewReader(header + "1,1,10,a\n2,1,20,b\n3,2,30,c\n")
+		batches := make(chan records.Batch, 10)
+
+		err := processor.StreamBatches(ctx, src, nil, 2, batches)
+
+		assert.Nil(t, err)
+		first, second := <-batches, <-batches
+		assert.Equal(t, records.Batch{{Line: 2, Fields: []string{"1", "1", "10", "a"}, Raw: "1,1,10,a"},
+			{Line: 3, Fields: []string{"2", "1", "20", "b"}, Raw: "2,1,20,b"}}, first)
+		assert.Equal(t, records.Batch{{Line: 4, Fields: []string{"3", "2", "30", "c"}, Raw: "3,2,30,c"}}, second)
+
+		_, open := <-batches
+		assert.False(t, open)
+	})
+
+	t.Run("When a line can't be parsed it's sent with its error and raw content", func(t *testing.T) {
+		src := strings.NewReader(header + "1,1,10,a\r\n2,1,20\r\n3,\"multi\nline\",30,c\r\n")
+		batches := make(chan records.Batch, 10)
+
+		err := processor.StreamBatches(ctx, src, nil, 10, batches)
+
+		assert.Nil(t, err)
+		batch := <-batches
+		assert.Len(t, batch, 3)
+		assert.Equal(t, "1,1,10,a", batch[0].Raw)
+		assert.Equal(t, 3, batch[1].Line)
+		assert.Equal(t, "2,1,20", batch[1].Raw)
+		assert.Nil(t, batch[1].Fields)
+		assert.Error(t, batch[1].Err)
+		assert.Equal(t, []string{"3", "multi\nline", "30", "c"}, batch[2].Fields)
+		assert.Equal(t, "3,\"multi\nline\",30,c", batch[2].Raw)
+	})
+
+	t.Run("When a large file is streamed through a bounded channel", func(t *testing.T) {
+		const rows = 100000
+		batches := make(chan records.Batch, 1)
+		done := make(chan error, 1)
+
+		go func() {
+			done <- processor.StreamBatches(ctx, newRowsReader(rows), nil, 1000, batches)
+		}()
+
+		var streamed, received int
+		for batch := range batches {
+			received++
+			streamed += len(batch)
+			assert.LessOrEqual(t, len(batch), 1000)
+			assert.Equal(t, fmt.Sprintf("%d,1,10.5,2024-01-01T00:00:00Z", streamed), batch[len(batch)-1].Raw)
+		}
+
+		assert.Nil(t, <-done)
+		assert.Equal(t, rows, streamed)
+		assert.Equal(t, 100, received)
+	})
+
+	t.Run("When the context is canceled while the consumers are busy", func(t *testing.T) {
+		canceledCtx, cancel := context.WithCancel(ctx)
+		cancel()
+		batches := make(chan records.Batch)
+
+		err := processor.StreamBatches(canceledCtx, newRowsReader(10), nil, 1, batches)
+
+		assert.ErrorIs(t, err, context.Canceled)
+	})
+
+	t.Run("When the batch size is not valid", func(t *testing.T) {
+		batches := make(chan records.Batch, 1)
+
+		err := processor.StreamBatches(ctx, strings.NewReader(header), nil, 0, batches)
+
+		assert.Error(t, err)
+	})
+}
+
+// rowsReader generates the rows on demand so the whole file never exists in memory
+type rowsReader struct {
+	rows    int
+	current int
+	pending []byte
+}
+
+func newRowsReader(rows int) io.Reader {
+	return &rowsReader{rows: rows, pending: []byte(header)}
+}
+
+func (r *rowsReader) Read(p []byte) (int, error) {
+	if len(r.pending) == 0 {
+		if r.current == r.rows {
+			return 0, io.EOF
+		}
+
+		r.current++
+		r.pending = []byte(fmt.Sprintf("%d,1,10.5,2024-01-01T00:00:00Z\n", r.current))
+	}
+
+	n := copy(p, r.pending)
+	r.pending = r.pending[n:]
+	return n, nil
+}
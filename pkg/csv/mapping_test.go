@@ -0,0 +1,110 @@
+package csv_test
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/sebastianreh/user-balance-api/pkg/csv"
+	"github.com/sebastianreh/user-balance-api/pkg/records"
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_CsvProcessor_Mapping(t *testing.T) {
+	ctx := context.TODO()
+	processor := csv.NewCsvProcessor()
+	newMapping := func() *records.Mapping {
+		return &records.Mapping{
+			Fields:  []string{"id", "user_id", "amount", "datetime"},
+			Columns: map[string][]string{"user_id": {"customer", "client"}, "datetime": {"created"}},
+			Ignored: []string{"notes"},
+		}
+	}
+	stream := func(content string, mapping *records.Mapping) (records.Batch, error) {
+		batches := make(chan records.Batch, 10)
+		err := processor.StreamBatches(ctx, strings.NewReader(content), mapping, 10, batches)
+		if err != nil {
+			return nil, err
+		}
+
+		return <-batches, nil
+	}
+
+	t.Run("When the columns are in another order they are sorted by the header names", func(t *testing.T) {
+		batch, err := stream("amount,datetime,id,user_id\n10,2024-01-01T00:00:00Z,1,2\n", newMapping())
+
+		assert.Nil(t, err)
+		assert.Equal(t, records.Batch{{Line: 2, Fields: []string{"1", "2", "10", "2024-01-01T00:00:00Z"},
+			Raw: "10,2024-01-01T00:00:00Z,1,2"}}, batch)
+	})
+
+	t.Run("When the header uses aliases and has ignored columns", func(t *testing.T) {
+		batch, err := stream("\ufeffID, Customer ,Notes,Created,Amount\n1,2,first,2024-01-01T00:00:00Z,10\n",
+			newMapping())
+
+		assert.Nil(t, err)
+		assert.Equal(t, []string{"1", "2", "10", "2024-01-01T00:00:00Z"}, batch[0].Fields)
+	})
+
+	t.Run("When a field has no column its default is used", func(t *testing.T) {
+		mapping := newMapping()
+		mapping.Defaults = map[string]string{"datetime": "2024-01-01T00:00:00Z"}
+
+		batch, err := stream("id,user_id,amount\n1,2,10\n", mapping)
+
+		assert.Nil(t, err)
+		assert.Equal(t, []string{"1", "2", "10", "2024-01-01T00:00:00Z"}, batch[0].Fields)
+	})
+
+	t.Run("When a field has no column nor default", func(t *testing.T) {
+		_, err := stream("id,user_id,amount\n1,2,10\n", newMapping())
+
+		assert.EqualError(t, err, "header: missing column for datetime")
+	})
+
+	t.Run("When a column is not mapped nor ignored it's skipped", func(t *testing.T) {
+		batch, err := stream("id,user_id,amount,datetime,currency\n1,2,10,2024-01-01T00:00:00Z,ARS\n", newMapping())
+
+		assert.Nil(t, err)
+		assert.Equal(t, []string{"1", "2", "10", "2024-01-01T00:00:00Z"}, batch[0].Fields)
+	})
+
+	t.Run("When a strict mapping finds a column that is not mapped nor ignored", func(t *testing.T) {
+		mapping := newMapping()
+		mapping.Strict = true
+
+		_, err := stream("id,user_id,amount,datetime,currency\n1,2,10,2024-01-01T00:00:00Z,ARS\n", mapping)
+
+		assert.EqualError(t, err, `header: unknown column "currency"`)
+	})
+
+	t.Run("When a strict mapping finds an ignored column", func(t *testing.T) {
+		mapping := newMapping()
+		mapping.Strict = true
+
+		batch, err := stream("id,user_id,notes,amount,datetime\n1,2,first,10,2024-01-01T00:00:00Z\n", mapping)
+
+		assert.Nil(t, err)
+		assert.Equal(t, []string{"1", "2", "10", "2024-01-01T00:00:00Z"}, batch[0].Fields)
+	})
+
+	t.Run("When two columns are mapped to the same field", func(t *testing.T) {
+		_, err := stream("id,customer,client,amount,datetime\n1,2,3,10,2024-01-01T00:00:00Z\n", newMapping())
+
+		assert.EqualError(t, err, `header: columns "customer" and "client" are both mapped to user_id`)
+	})
+
+	t.Run("When the file is validated the records are mapped before the validator", func(t *testing.T) {
+		var validated [][]string
+		src := strings.NewReader("user_id,id,amount,datetime\n2,1,10,a\n3,2,20,b\n")
+
+		records, err := processor.Validate(src, newMapping(), func(record []string) error {
+			validated = append(validated, record)
+			return nil
+		})
+
+		assert.Nil(t, err)
+		assert.Equal(t, 2, records)
+		assert.Equal(t, [][]string{{"1", "2", "10", "a"}, {"2", "3", "20", "b"}}, validated)
+	})
+}
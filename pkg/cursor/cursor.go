@@ -0,0 +1,32 @@
+package cursor
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+)
+
+const (
+	InvalidCursorError = "invalid cursor"
+	SortMismatchError  = "cursor does not match the requested sort"
+)
+
+// Encode turns a keyset position into an opaque url safe token
+func Encode(position interface{}) string {
+	positionBytes, _ := json.Marshal(position)
+	return base64.RawURLEncoding.EncodeToString(positionBytes)
+}
+
+// Decode reads a token created by Encode into position
+func Decode(token string, position interface{}) error {
+	positionBytes, err := base64.RawURLEncoding.DecodeString(token)
+	if err != nil {
+		return errors.New(InvalidCursorError)
+	}
+
+	if err = json.Unmarshal(positionBytes, position); err != nil {
+		return errors.New(InvalidCursorError)
+	}
+
+	return nil
+}
@@ -0,0 +1,290 @@
+package decompress
+
+import (
+	"archive/zip"
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+	"path"
+	"strings"
+
+	"github.com/klauspost/compress/zstd"
+)
+
+const (
+	None = ""
+	Gzip = "gzip"
+	Zip  = "zip"
+	Zstd = "zstd"
+	// magicSize is the longest magic number
+	magicSize = 4
+	// zstdMaxWindow bounds the memory a zstd frame can ask the decoder for
+	zstdMaxWindow = 128 << 20
+)
+
+var magicNumbers = []struct {
+	compression string
+	prefix      []byte
+}{
+	{compression: Gzip, prefix: []byte{0x1f, 0x8b}},
+	{compression: Zip, prefix: []byte("PK\x03\x04")},
+	{compression: Zstd, prefix: []byte{0x28, 0xb5, 0x2f, 0xfd}},
+}
+
+// Limits protects against files that decompress to far more than they weigh, a zero value means no limit
+type Limits struct {
+	// MaxSize is the most bytes a compressed file can decompress to, counting every zip entry
+	MaxSize int64
+	// MaxEntries is the most files a zip can have
+	MaxEntries int
+}
+
+// Detect returns the compression of a file by its first bytes, None when it's not compressed
+func Detect(head []byte) string {
+	for _, magic := range magicNumbers {
+		if bytes.HasPrefix(head, magic.prefix) {
+			return magic.compression
+		}
+	}
+
+	return None
+}
+
+// Open returns the content of src, decompressed while it's read when the first bytes are the ones of gzip, zip or
+// zstd. The csv files of a zip are read one after the other as a single file, so they must have the same header.
+// Zip files need src to be an io.ReaderAt and io.Seeker, like the uploaded and the temporary files. Closing the
+// returned reader does not close src
+func Open(src io.Reader, limits Limits) (io.ReadCloser, error) {
+	reader := bufio.NewReader(src)
+	head, err := reader.Peek(magicSize)
+	if err != nil && !errors.Is(err, io.EOF) {
+		return nil, err
+	}
+
+	switch Detect(head) {
+	case Gzip:
+		gzipReader, err := gzip.NewReader(reader)
+		if err != nil {
+			return nil, fmt.Errorf("cannot read gzip file: %w", err)
+		}
+
+		return &content{Reader: limit(gzipReader, limits.MaxSize), close: gzipReader.Close}, nil
+	case Zstd:
+		decoder, err := zstd.NewReader(reader, zstd.WithDecoderConcurrency(1), zstd.WithDecoderLowmem(true),
+			zstd.WithDecoderMaxWindow(zstdMaxWindow))
+		if err != nil {
+			return nil, fmt.Errorf("cannot read zstd file: %w", err)
+		}
+
+		return &content{Reader: limit(decoder, limits.MaxSize), close: func() error {
+			decoder.Close()
+			return nil
+		}}, nil
+	case Zip:
+		entries, err := openZip(src, limits.MaxEntries)
+		if err != nil {
+			return nil, err
+		}
+
+		return &content{Reader: limit(entries, limits.MaxSize), close: entries.Close}, nil
+	default:
+		return io.NopCloser(reader), nil
+	}
+}
+
+// Opener wraps open so every pass over the file reads its decompressed content
+func Opener(open func() (io.ReadCloser, error), limits Limits) func() (io.ReadCloser, error) {
+	return func() (io.ReadCloser, error) {
+		src, err := open()
+		if err != nil {
+			return nil, err
+		}
+
+		decompressed, err := Open(src, limits)
+		if err != nil {
+			_ = src.Close()
+			return nil, err
+		}
+
+		return &content{Reader: decompressed, close: func() error {
+			return errors.Join(decompressed.Close(), src.Close())
+		}}, nil
+	}
+}
+
+type content struct {
+	io.Reader
+	close func() error
+}
+
+func (c *content) Close() error {
+	return c.close()
+}
+
+func openZip(src io.Reader, maxEntries int) (*zipEntries, error) {
+	readerAt, isReaderAt := src.(io.ReaderAt)
+	seeker, isSeeker := src.(io.Seeker)
+	if !isReaderAt || !isSeeker {
+		return nil, errors.New("zip files must be read from a file")
+	}
+
+	size, err := seeker.Seek(0, io.SeekEnd)
+	if err != nil {
+		return nil, err
+	}
+
+	archive, err := zip.NewReader(readerAt, size)
+	if err != nil {
+		return nil, fmt.Errorf("cannot read zip file: %w", err)
+	}
+
+	var files []*zip.File
+	for _, file := range archive.File {
+		// Folders and the metadata added by macOS are not part of the content
+		if file.FileInfo().IsDir() || strings.HasPrefix(file.Name, "__MACOSX/") {
+			continue
+		}
+
+		if !strings.EqualFold(path.Ext(file.Name), ".csv") {
+			return nil, fmt.Errorf("zip entry %q is not a csv file", file.Name)
+		}
+
+		files = append(files, file)
+	}
+
+	if len(files) == 0 {
+		return nil, errors.New("the zip file has no csv files")
+	}
+
+	if maxEntries > 0 && len(files) > maxEntries {
+		return nil, fmt.Errorf("the zip file has more than %d entries", maxEntries)
+	}
+
+	return &zipEntries{files: files}, nil
+}
+
+// zipEntries reads the csv files of a zip in order. The header of every file after the first one is checked against
+// the first header and read as an empty line, so the line numbers keep counting across the files
+type zipEntries struct {
+	files    []*zip.File
+	current  io.ReadCloser
+	reader   io.Reader
+	header   *string
+	lastByte byte
+}
+
+func (z *zipEntries) Read(p []byte) (int, error) {
+	for {
+		if z.current == nil {
+			if len(z.files) == 0 {
+				return 0, io.EOF
+			}
+
+			if err := z.next(); err != nil {
+				return 0, err
+			}
+		}
+
+		n, err := z.reader.Read(p)
+		if n > 0 {
+			z.lastByte = p[n-1]
+		}
+
+		if errors.Is(err, io.EOF) {
+			err = z.current.Close()
+			z.current = nil
+		}
+
+		if n > 0 || err != nil {
+			return n, err
+		}
+	}
+}
+
+func (z *zipEntries) next() error {
+	file := z.files[0]
+	z.files = z.files[1:]
+
+	entry, err := file.Open()
+	if err != nil {
+		return fmt.Errorf("cannot read zip entry %q: %w", file.Name, err)
+	}
+
+	z.current = entry
+	reader := bufio.NewReader(entry)
+	line, err := reader.ReadString('\n')
+	if err != nil && !errors.Is(err, io.EOF) {
+		return fmt.Errorf("cannot read zip entry %q: %w", file.Name, err)
+	}
+
+	header := firstLine(line)
+	if z.header == nil {
+		z.header = &header
+		z.reader = io.MultiReader(strings.NewReader(line), reader)
+		return nil
+	}
+
+	if header != *z.header {
+		return fmt.Errorf("zip entry %q has a different header than the first csv file", file.Name)
+	}
+
+	prefix := "\n"
+	// The previous file may not end with a line break
+	if z.lastByte != '\n' {
+		prefix += "\n"
+	}
+
+	z.reader = io.MultiReader(strings.NewReader(prefix), reader)
+	return nil
+}
+
+func (z *zipEntries) Close() error {
+	if z.current == nil {
+		return nil
+	}
+
+	return z.current.Close()
+}
+
+func firstLine(line string) string {
+	return strings.TrimPrefix(strings.TrimRight(line, "\r\n"), "\ufeff")
+}
+
+// limit fails the reading once more than maxSize bytes come out of src
+func limit(src io.Reader, maxSize int64) io.Reader {
+	if maxSize <= 0 {
+		return src
+	}
+
+	return &limitedReader{src: src, maxSize: maxSize, remaining: maxSize}
+}
+
+type limitedReader struct {
+	src       io.Reader
+	maxSize   int64
+	remaining int64
+	exceeded  bool
+}
+
+func (l *limitedReader) Read(p []byte) (int, error) {
+	if l.exceeded {
+		return 0, l.sizeError()
+	}
+
+	// One byte more than the limit is asked for to tell a file of the exact size from a larger one
+	n, err := l.src.Read(p[:min(int64(len(p)), l.remaining+1)])
+	if int64(n) > l.remaining {
+		l.exceeded = true
+		return int(l.remaining), l.sizeError()
+	}
+
+	l.remaining -= int64(n)
+	return n, err
+}
+
+func (l *limitedReader) sizeError() error {
+	return fmt.Errorf("the decompressed file is larger than %d bytes", l.maxSize)
+}
@@ -0,0 +1,171 @@
+package decompress_test
+
+import (
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/klauspost/compress/zstd"
+	"github.com/sebastianreh/user-balance-api/pkg/decompress"
+	"github.com/stretchr/testify/assert"
+)
+
+const content = "id,user_id,amount,datetime\n1,1,10,2024-01-01T00:00:00Z\n"
+
+func gzipFile(t *testing.T, text string) []byte {
+	var file bytes.Buffer
+	writer := gzip.NewWriter(&file)
+	_, err := writer.Write([]byte(text))
+	assert.NoError(t, err)
+	assert.NoError(t, writer.Close())
+
+	return file.Bytes()
+}
+
+func zipFile(t *testing.T, entries ...string) []byte {
+	var file bytes.Buffer
+	writer := zip.NewWriter(&file)
+	for i := 0; i < len(entries); i += 2 {
+		entry, err := writer.Create(entries[i])
+		assert.NoError(t, err)
+		_, err = entry.Write([]byte(entries[i+1]))
+		assert.NoError(t, err)
+	}
+	assert.NoError(t, writer.Close())
+
+	return file.Bytes()
+}
+
+func readAll(t *testing.T, file []byte, limits decompress.Limits) (string, error) {
+	reader, err := decompress.Open(bytes.NewReader(file), limits)
+	if err != nil {
+		return "", err
+	}
+	defer func() {
+		assert.NoError(t, reader.Close())
+	}()
+
+	text, err := io.ReadAll(reader)
+	return string(text), err
+}
+
+func Test_Detect(t *testing.T) {
+	t.Run("When the file starts with a magic number", func(t *testing.T) {
+		assert.Equal(t, decompress.Gzip, decompress.Detect(gzipFile(t, content)))
+		assert.Equal(t, decompress.Zip, decompress.Detect(zipFile(t, "a.csv", content)))
+		assert.Equal(t, decompress.Zstd, decompress.Detect([]byte{0x28, 0xb5, 0x2f, 0xfd, 0x00}))
+	})
+
+	t.Run("When the file is not compressed", func(t *testing.T) {
+		assert.Equal(t, decompress.None, decompress.Detect([]byte(content)))
+	})
+}
+
+func Test_Open(t *testing.T) {
+	t.Run("When the file is not compressed it's read as it is", func(t *testing.T) {
+		text, err := readAll(t, []byte(content), decompress.Limits{MaxSize: 1})
+
+		assert.Nil(t, err)
+		assert.Equal(t, content, text)
+	})
+
+	t.Run("When the file is gzip", func(t *testing.T) {
+		text, err := readAll(t, gzipFile(t, content), decompress.Limits{})
+
+		assert.Nil(t, err)
+		assert.Equal(t, content, text)
+	})
+
+	t.Run("When the file is zstd", func(t *testing.T) {
+		encoder, err := zstd.NewWriter(nil)
+		assert.NoError(t, err)
+		file := encoder.EncodeAll([]byte(content), nil)
+
+		text, err := readAll(t, file, decompress.Limits{})
+
+		assert.Nil(t, err)
+		assert.Equal(t, content, text)
+	})
+
+	t.Run("When the decompressed file has exactly the limit size", func(t *testing.T) {
+		text, err := readAll(t, gzipFile(t, content), decompress.Limits{MaxSize: int64(len(content))})
+
+		assert.Nil(t, err)
+		assert.Equal(t, content, text)
+	})
+
+	t.Run("When the decompressed file is larger than the limit", func(t *testing.T) {
+		file := gzipFile(t, strings.Repeat("0", 1<<20))
+
+		_, err := readAll(t, file, decompress.Limits{MaxSize: 1 << 10})
+
+		assert.EqualError(t, err, "the decompressed file is larger than 1024 bytes")
+	})
+
+	t.Run("When the zip has many csv files the headers after the first are read as empty lines", func(t *testing.T) {
+		file := zipFile(t, "january.csv", content, "folder/", "", "__MACOSX/._january.csv", "metadata",
+			"february.csv", "\ufeffid,user_id,amount,datetime\r\n2,1,20,2024-02-01T00:00:00Z",
+			"march.csv", "id,user_id,amount,datetime\n3,1,30,2024-03-01T00:00:00Z\n")
+
+		text, err := readAll(t, file, decompress.Limits{})
+
+		assert.Nil(t, err)
+		assert.Equal(t, content+"\n2,1,20,2024-02-01T00:00:00Z\n\n3,1,30,2024-03-01T00:00:00Z\n", text)
+	})
+
+	t.Run("When a csv of the zip has a different header", func(t *testing.T) {
+		_, err := readAll(t, zipFile(t, "a.csv", content, "b.csv", "user_id,id,amount,datetime\n"),
+			decompress.Limits{})
+
+		assert.EqualError(t, err, `zip entry "b.csv" has a different header than the first csv file`)
+	})
+
+	t.Run("When the zip has a file that is not a csv", func(t *testing.T) {
+		_, err := readAll(t, zipFile(t, "a.csv", content, "notes.txt", "notes"), decompress.Limits{})
+
+		assert.EqualError(t, err, `zip entry "notes.txt" is not a csv file`)
+	})
+
+	t.Run("When the zip has more entries than the limit", func(t *testing.T) {
+		_, err := readAll(t, zipFile(t, "a.csv", content, "b.csv", content), decompress.Limits{MaxEntries: 1})
+
+		assert.EqualError(t, err, "the zip file has more than 1 entries")
+	})
+
+	t.Run("When the zip is not read from a file", func(t *testing.T) {
+		_, err := decompress.Open(io.MultiReader(bytes.NewReader(zipFile(t, "a.csv", content))), decompress.Limits{})
+
+		assert.EqualError(t, err, "zip files must be read from a file")
+	})
+}
+
+type closeCounter struct {
+	io.Reader
+	closed int
+}
+
+func (c *closeCounter) Close() error {
+	c.closed++
+	return nil
+}
+
+func Test_Opener(t *testing.T) {
+	t.Run("When the decompressed file is closed the original one is closed too", func(t *testing.T) {
+		src := &closeCounter{Reader: bytes.NewReader(gzipFile(t, content))}
+		open := decompress.Opener(func() (io.ReadCloser, error) {
+			return src, nil
+		}, decompress.Limits{})
+
+		reader, err := open()
+		assert.NoError(t, err)
+		text, err := io.ReadAll(reader)
+
+		assert.Nil(t, err)
+		assert.Equal(t, content, string(text))
+		assert.Nil(t, reader.Close())
+		assert.Equal(t, 1, src.closed)
+	})
+}
@@ -3,11 +3,13 @@ package email
 import (
 	"fmt"
 	"net/smtp"
-	"strings"
 )
 
 type EmailService interface {
 	SendEmail(to []string, subject, body string) error
+	// Send sends the message with its bodies, inline images and attachments. The message is sent from the address
+	// of the service and to its default recipient when they are empty
+	Send(message Message) error
 }
 
 type smtpEmailService struct {
@@ -31,22 +33,24 @@ func NewSMTPEmailService(username, password, from, to, host, port string) EmailS
 }
 
 func (s *smtpEmailService) SendEmail(to []string, subject, body string) error {
-	if len(to) == 0 {
-		to = append(to, s.to)
-	}
+	return s.Send(Message{To: to, Subject: subject, Text: body})
+}
 
-	auth := smtp.PlainAuth("apikey", s.username, s.password, s.host)
+func (s *smtpEmailService) Send(message Message) error {
+	if message.From == "" {
+		message.From = s.from
+	}
 
-	headers := make(map[string]string)
-	headers["From"] = s.from
-	headers["To"] = strings.Join(to, ",")
-	headers["Subject"] = subject
+	if len(message.To) == 0 {
+		message.To = []string{s.to}
+	}
 
-	message := ""
-	for k, v := range headers {
-		message += fmt.Sprintf("%s: %s\r\n", k, v)
+	encoded, err := Build(message)
+	if err != nil {
+		return err
 	}
-	message += "\r\n" + body
 
-	return smtp.SendMail(fmt.Sprintf("%s:%s", s.host, s.port), auth, s.from, to, []byte(message))
+	auth := smtp.PlainAuth("apikey", s.username, s.password, s.host)
+
+	return smtp.SendMail(fmt.Sprintf("%s:%s", s.host, s.port), auth, message.From, message.To, encoded)
 }
@@ -0,0 +1,248 @@
+package email
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"mime/multipart"
+	"mime/quotedprintable"
+	"net/textproto"
+	"strings"
+	"time"
+)
+
+const (
+	// base64LineLength is the longest encoded line allowed in a MIME body
+	base64LineLength = 76
+	messageIDBytes   = 16
+)
+
+// Message is an email before it's encoded, at least one of Text and HTML must be set
+type Message struct {
+	From    string
+	To      []string
+	Subject string
+	// Text is the plain body, when HTML is set too both are sent as alternatives
+	Text string
+	HTML string
+	// Inline are the images referenced from the HTML body as cid:<ContentID>
+	Inline      []Attachment
+	Attachments []Attachment
+	// Date and MessageID are set when the message is built if they are empty
+	Date      time.Time
+	MessageID string
+}
+
+// Attachment is a file sent along with an email, ContentID is only used by inline images
+type Attachment struct {
+	FileName    string
+	ContentType string
+	Content     []byte
+	ContentID   string
+}
+
+// part is a MIME entity, the header of the outermost one is written with the message headers
+type part struct {
+	header textproto.MIMEHeader
+	body   []byte
+}
+
+// Build encodes the message as MIME. The bodies are nested as multipart/mixed for the attachments,
+// multipart/related for the inline images and multipart/alternative for the text and HTML bodies, leaving out the
+// levels the message doesn't need. The headers are always written in the same order
+func Build(message Message) ([]byte, error) {
+	if message.Text == "" && message.HTML == "" {
+		return nil, errors.New("the message has no body")
+	}
+
+	content, err := buildContent(message)
+	if err != nil {
+		return nil, err
+	}
+
+	if message.Date.IsZero() {
+		message.Date = time.Now()
+	}
+
+	if message.MessageID == "" {
+		if message.MessageID, err = newMessageID(message.From); err != nil {
+			return nil, err
+		}
+	}
+
+	var encoded bytes.Buffer
+	writeHeader(&encoded, "Date", message.Date.Format(time.RFC1123Z))
+	writeHeader(&encoded, "Message-ID", message.MessageID)
+	writeHeader(&encoded, "From", message.From)
+	writeHeader(&encoded, "To", strings.Join(message.To, ", "))
+	writeHeader(&encoded, "Subject", mime.QEncoding.Encode("utf-8", message.Subject))
+	writeHeader(&encoded, "MIME-Version", "1.0")
+	writeHeader(&encoded, "Content-Type", content.header.Get("Content-Type"))
+	if encoding := content.header.Get("Content-Transfer-Encoding"); encoding != "" {
+		writeHeader(&encoded, "Content-Transfer-Encoding", encoding)
+	}
+	encoded.WriteString("\r\n")
+	encoded.Write(content.body)
+
+	return encoded.Bytes(), nil
+}
+
+func buildContent(message Message) (part, error) {
+	var content part
+	var err error
+	switch {
+	case message.HTML == "":
+		content, err = textPart("text/plain", message.Text)
+	case message.Text == "":
+		content, err = textPart("text/html", message.HTML)
+	default:
+		content, err = alternativePart(message.Text, message.HTML)
+	}
+	if err != nil {
+		return part{}, err
+	}
+
+	if len(message.Inline) > 0 {
+		related := []part{content}
+		for _, image := range message.Inline {
+			if image.ContentID == "" {
+				return part{}, fmt.Errorf("the inline file %s has no content id", image.FileName)
+			}
+			related = append(related, filePart(image, "inline"))
+		}
+
+		if content, err = multipartOf("related", related); err != nil {
+			return part{}, err
+		}
+	}
+
+	if len(message.Attachments) > 0 {
+		mixed := []part{content}
+		for _, attachment := range message.Attachments {
+			mixed = append(mixed, filePart(attachment, "attachment"))
+		}
+
+		if content, err = multipartOf("mixed", mixed); err != nil {
+			return part{}, err
+		}
+	}
+
+	return content, nil
+}
+
+func alternativePart(text, html string) (part, error) {
+	textContent, err := textPart("text/plain", text)
+	if err != nil {
+		return part{}, err
+	}
+
+	htmlContent, err := textPart("text/html", html)
+	if err != nil {
+		return part{}, err
+	}
+
+	// The last alternative is the one preferred by the clients that can show it
+	return multipartOf("alternative", []part{textContent, htmlContent})
+}
+
+// textPart encodes the body as quoted-printable, so long HTML lines stay under the SMTP line length limit
+func textPart(mediaType, content string) (part, error) {
+	var body bytes.Buffer
+	writer := quotedprintable.NewWriter(&body)
+	if _, err := writer.Write([]byte(content)); err != nil {
+		return part{}, err
+	}
+
+	if err := writer.Close(); err != nil {
+		return part{}, err
+	}
+
+	return part{
+		header: textproto.MIMEHeader{
+			"Content-Type":              {mime.FormatMediaType(mediaType, map[string]string{"charset": "utf-8"})},
+			"Content-Transfer-Encoding": {"quoted-printable"},
+		},
+		body: body.Bytes(),
+	}, nil
+}
+
+func filePart(file Attachment, disposition string) part {
+	contentType := file.ContentType
+	if contentType == "" {
+		contentType = "application/octet-stream"
+	}
+
+	header := textproto.MIMEHeader{
+		"Content-Type":              {contentType},
+		"Content-Transfer-Encoding": {"base64"},
+		"Content-Disposition":       {mime.FormatMediaType(disposition, map[string]string{"filename": file.FileName})},
+	}
+	if file.ContentID != "" {
+		header.Set("Content-ID", "<"+file.ContentID+">")
+	}
+
+	var body bytes.Buffer
+	// Writing to a buffer can't fail
+	_ = writeBase64Lines(&body, file.Content)
+
+	return part{header: header, body: body.Bytes()}
+}
+
+func multipartOf(subtype string, parts []part) (part, error) {
+	var body bytes.Buffer
+	writer := multipart.NewWriter(&body)
+	for _, nested := range parts {
+		partWriter, err := writer.CreatePart(nested.header)
+		if err != nil {
+			return part{}, err
+		}
+
+		if _, err = partWriter.Write(nested.body); err != nil {
+			return part{}, err
+		}
+	}
+
+	if err := writer.Close(); err != nil {
+		return part{}, err
+	}
+
+	contentType := mime.FormatMediaType("multipart/"+subtype, map[string]string{"boundary": writer.Boundary()})
+	return part{header: textproto.MIMEHeader{"Content-Type": {contentType}}, body: body.Bytes()}, nil
+}
+
+func writeHeader(writer *bytes.Buffer, key, value string) {
+	writer.WriteString(key + ": " + value + "\r\n")
+}
+
+func writeBase64Lines(writer io.Writer, content []byte) error {
+	encoded := base64.StdEncoding.EncodeToString(content)
+	for len(encoded) > 0 {
+		line := encoded[:min(base64LineLength, len(encoded))]
+		encoded = encoded[len(line):]
+		if _, err := writer.Write([]byte(line + "\r\n")); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// newMessageID returns a random id on the domain of the sender
+func newMessageID(from string) (string, error) {
+	id := make([]byte, messageIDBytes)
+	if _, err := rand.Read(id); err != nil {
+		return "", err
+	}
+
+	domain := "localhost"
+	if at := strings.LastIndex(from, "@"); at >= 0 && at < len(from)-1 {
+		domain = strings.TrimSuffix(from[at+1:], ">")
+	}
+
+	return fmt.Sprintf("<%s@%s>", hex.EncodeToString(id), domain), nil
+}
@@ -0,0 +1,146 @@
+package email
+
+import (
+	"bytes"
+	"encoding/base64"
+	"io"
+	"mime"
+	"mime/multipart"
+	"mime/quotedprintable"
+	"net/mail"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_Build(t *testing.T) {
+	date := time.Date(2024, 9, 13, 10, 0, 0, 0, time.UTC)
+
+	t.Run("When a plain text message is written with its headers in order", func(t *testing.T) {
+		message, err := Build(Message{From: "from@example.com", To: []string{"a@example.com", "b@example.com"},
+			Subject: "Migration Report", Text: "Total Records Processed: 10", Date: date, MessageID: "<1@example.com>"})
+		assert.Nil(t, err)
+
+		headers := string(message[:bytes.Index(message, []byte("\r\n\r\n"))])
+		assert.Equal(t, "Date: Fri, 13 Sep 2024 10:00:00 +0000\r\n"+
+			"Message-ID: <1@example.com>\r\n"+
+			"From: from@example.com\r\n"+
+			"To: a@example.com, b@example.com\r\n"+
+			"Subject: Migration Report\r\n"+
+			"MIME-Version: 1.0\r\n"+
+			"Content-Type: text/plain; charset=utf-8\r\n"+
+			"Content-Transfer-Encoding: quoted-printable", headers)
+
+		parsed := readMessage(t, message)
+		assert.Equal(t, "Total Records Processed: 10", readQuotedPrintable(t, parsed.Body))
+	})
+
+	t.Run("When the date and message id are set on build", func(t *testing.T) {
+		message, err := Build(Message{From: "Reports <from@example.com>", Subject: "Informe de migración",
+			Text: "body"})
+		assert.Nil(t, err)
+
+		parsed := readMessage(t, message)
+		_, err = parsed.Header.Date()
+		assert.Nil(t, err)
+		assert.Regexp(t, `^<[0-9a-f]{32}@example\.com>$`, parsed.Header.Get("Message-ID"))
+		subject, err := new(mime.WordDecoder).DecodeHeader(parsed.Header.Get("Subject"))
+		assert.Nil(t, err)
+		assert.Equal(t, "Informe de migración", subject)
+	})
+
+	t.Run("When the text and HTML bodies are written as alternatives", func(t *testing.T) {
+		html := "<p>" + strings.Repeat("Total Records Processed ", 100) + "</p>"
+		message, err := Build(Message{From: "from@example.com", Text: "Total Records Processed", HTML: html})
+		assert.Nil(t, err)
+
+		parts := readParts(t, readMessage(t, message), "multipart/alternative")
+		assert.Len(t, parts, 2)
+		assert.Equal(t, "text/plain; charset=utf-8", parts[0].header.Get("Content-Type"))
+		assert.Equal(t, "Total Records Processed", readQuotedPrintable(t, bytes.NewReader(parts[0].body)))
+		assert.Equal(t, "text/html; charset=utf-8", parts[1].header.Get("Content-Type"))
+		assert.Equal(t, html, readQuotedPrintable(t, bytes.NewReader(parts[1].body)))
+		for _, line := range strings.Split(string(parts[1].body), "\r\n") {
+			assert.LessOrEqual(t, len(line), base64LineLength)
+		}
+	})
+
+	t.Run("When the inline images and attachments wrap the bodies", func(t *testing.T) {
+		content := []byte(strings.Repeat("user_id,balance,total_debts,total_credits\n", 10))
+		message, err := Build(Message{From: "from@example.com", Text: "text", HTML: `<img src="cid:logo">`,
+			Inline:      []Attachment{{FileName: "logo.png", ContentType: "image/png", Content: []byte{0x89}, ContentID: "logo"}},
+			Attachments: []Attachment{{FileName: "balances.csv", ContentType: "text/csv", Content: content}}})
+		assert.Nil(t, err)
+
+		mixed := readParts(t, readMessage(t, message), "multipart/mixed")
+		assert.Len(t, mixed, 2)
+		assert.Equal(t, `attachment; filename=balances.csv`, mixed[1].header.Get("Content-Disposition"))
+		for _, line := range strings.Split(strings.TrimSpace(string(mixed[1].body)), "\r\n") {
+			assert.LessOrEqual(t, len(line), base64LineLength)
+		}
+		decoded, err := base64.StdEncoding.DecodeString(strings.ReplaceAll(string(mixed[1].body), "\r\n", ""))
+		assert.Nil(t, err)
+		assert.Equal(t, content, decoded)
+
+		related := readNestedParts(t, mixed[0], "multipart/related")
+		assert.Len(t, related, 2)
+		assert.Equal(t, "<logo>", related[1].header.Get("Content-ID"))
+		assert.Equal(t, `inline; filename=logo.png`, related[1].header.Get("Content-Disposition"))
+
+		alternative := readNestedParts(t, related[0], "multipart/alternative")
+		assert.Len(t, alternative, 2)
+	})
+
+	t.Run("When an inline image has no content id", func(t *testing.T) {
+		_, err := Build(Message{Text: "text", HTML: "<p>html</p>", Inline: []Attachment{{FileName: "logo.png"}}})
+		assert.EqualError(t, err, "the inline file logo.png has no content id")
+	})
+
+	t.Run("When the message has no body", func(t *testing.T) {
+		_, err := Build(Message{From: "from@example.com"})
+		assert.EqualError(t, err, "the message has no body")
+	})
+}
+
+func readMessage(t *testing.T, message []byte) *mail.Message {
+	parsed, err := mail.ReadMessage(bytes.NewReader(message))
+	assert.Nil(t, err)
+	return parsed
+}
+
+func readQuotedPrintable(t *testing.T, reader io.Reader) string {
+	decoded, err := io.ReadAll(quotedprintable.NewReader(reader))
+	assert.Nil(t, err)
+	return string(decoded)
+}
+
+func readParts(t *testing.T, message *mail.Message, expectedType string) []part {
+	return readMultipart(t, message.Header.Get("Content-Type"), message.Body, expectedType)
+}
+
+func readNestedParts(t *testing.T, parent part, expectedType string) []part {
+	return readMultipart(t, parent.header.Get("Content-Type"), bytes.NewReader(parent.body), expectedType)
+}
+
+// readMultipart reads the raw parts, so their encoded bodies can be checked
+func readMultipart(t *testing.T, contentType string, body io.Reader, expectedType string) []part {
+	mediaType, params, err := mime.ParseMediaType(contentType)
+	assert.Nil(t, err)
+	assert.Equal(t, expectedType, mediaType)
+
+	var parts []part
+	reader := multipart.NewReader(body, params["boundary"])
+	for {
+		nested, partErr := reader.NextRawPart()
+		if partErr == io.EOF {
+			return parts
+		}
+		assert.Nil(t, partErr)
+
+		content, readErr := io.ReadAll(nested)
+		assert.Nil(t, readErr)
+		parts = append(parts, part{header: nested.Header, body: content})
+	}
+}
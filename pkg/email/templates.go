@@ -0,0 +1,42 @@
+package email
+
+import (
+	"bytes"
+	"fmt"
+	"html/template"
+	"path/filepath"
+)
+
+const (
+	templateExtension = ".html"
+)
+
+// Renderer renders the HTML body of an email from its template
+type Renderer interface {
+	// Render executes the template called name, which is the file name without its extension
+	Render(name string, data any) (string, error)
+}
+
+type templateRenderer struct {
+	templates *template.Template
+}
+
+// NewTemplateRenderer parses every .html file in dir together, so a template can use the ones defined in the
+// others. The values are escaped for HTML when they are rendered
+func NewTemplateRenderer(dir string) (Renderer, error) {
+	templates, err := template.ParseGlob(filepath.Join(dir, "*"+templateExtension))
+	if err != nil {
+		return nil, fmt.Errorf("could not parse the email templates of %s: %w", dir, err)
+	}
+
+	return &templateRenderer{templates: templates}, nil
+}
+
+func (r *templateRenderer) Render(name string, data any) (string, error) {
+	var rendered bytes.Buffer
+	if err := r.templates.ExecuteTemplate(&rendered, name+templateExtension, data); err != nil {
+		return "", err
+	}
+
+	return rendered.String(), nil
+}
@@ -0,0 +1,36 @@
+package email
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_TemplateRenderer(t *testing.T) {
+	dir := t.TempDir()
+	assert.Nil(t, os.WriteFile(filepath.Join(dir, "layout.html"),
+		[]byte(`{{define "header"}}<h2>{{.}}</h2>{{end}}`), 0o600))
+	assert.Nil(t, os.WriteFile(filepath.Join(dir, "report.html"),
+		[]byte(`{{template "header" "Report"}}<p>{{.Name}}</p>`), 0o600))
+
+	renderer, err := NewTemplateRenderer(dir)
+	assert.Nil(t, err)
+
+	t.Run("When a template uses the ones defined in the others and its values are escaped", func(t *testing.T) {
+		rendered, err := renderer.Render("report", map[string]string{"Name": "<script>alert(1)</script>"})
+		assert.Nil(t, err)
+		assert.Equal(t, "<h2>Report</h2><p>&lt;script&gt;alert(1)&lt;/script&gt;</p>", rendered)
+	})
+
+	t.Run("When the template does not exist", func(t *testing.T) {
+		_, err := renderer.Render("missing", nil)
+		assert.Error(t, err)
+	})
+
+	t.Run("When the directory has no templates", func(t *testing.T) {
+		_, err := NewTemplateRenderer(t.TempDir())
+		assert.ErrorContains(t, err, "could not parse the email templates")
+	})
+}
@@ -0,0 +1,78 @@
+package fingerprint
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+)
+
+const bufferSize = 32 << 10
+
+var bom = []byte("\xef\xbb\xbf")
+
+// ContentHash returns the hex SHA-256 of the normalized content of src, so the same records hash the same even when
+// an editor re-saved the file. The normalization drops the UTF-8 BOM, the blank lines and the spaces and tabs at the
+// end of the lines, and treats CRLF and CR as LF. Lines are hashed as they're read, so long lines don't use memory
+func ContentHash(src io.Reader) (string, error) {
+	reader := bufio.NewReaderSize(src, bufferSize)
+	if head, _ := reader.Peek(len(bom)); bytes.Equal(head, bom) {
+		_, _ = reader.Discard(len(bom))
+	}
+
+	hash := sha256.New()
+	out := bufio.NewWriterSize(hash, bufferSize)
+	normalizer := lineNormalizer{out: out}
+
+	buffer := make([]byte, bufferSize)
+	for {
+		n, err := reader.Read(buffer)
+		normalizer.write(buffer[:n])
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return "", err
+		}
+	}
+
+	if err := out.Flush(); err != nil {
+		return "", err
+	}
+
+	return hex.EncodeToString(hash.Sum(nil)), nil
+}
+
+// lineNormalizer holds the spaces until the line shows it has more content after them, and the line break until
+// another line with content comes
+type lineNormalizer struct {
+	out          *bufio.Writer
+	spaces       []byte
+	hasContent   bool
+	pendingBreak bool
+}
+
+func (n *lineNormalizer) write(chunk []byte) {
+	for _, char := range chunk {
+		switch char {
+		case '\n', '\r':
+			if n.hasContent {
+				n.pendingBreak = true
+			}
+			n.hasContent = false
+			n.spaces = n.spaces[:0]
+		case ' ', '\t':
+			n.spaces = append(n.spaces, char)
+		default:
+			if n.pendingBreak {
+				_ = n.out.WriteByte('\n')
+				n.pendingBreak = false
+			}
+			_, _ = n.out.Write(n.spaces)
+			n.spaces = n.spaces[:0]
+			_ = n.out.WriteByte(char)
+			n.hasContent = true
+		}
+	}
+}
@@ -0,0 +1,57 @@
+package fingerprint_test
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"testing/iotest"
+
+	"github.com/sebastianreh/user-balance-api/pkg/fingerprint"
+	"github.com/stretchr/testify/assert"
+)
+
+const content = "id,user_id,amount,datetime\n1,1,10,2024-01-01T00:00:00Z\n"
+
+func Test_ContentHash(t *testing.T) {
+	expectedHash, err := fingerprint.ContentHash(strings.NewReader(content))
+	assert.NoError(t, err)
+
+	t.Run("When the file was re-saved with a BOM, CRLF line breaks and trailing spaces", func(t *testing.T) {
+		hash, err := fingerprint.ContentHash(strings.NewReader(
+			"\xef\xbb\xbfid,user_id,amount,datetime  \r\n1,1,10,2024-01-01T00:00:00Z\t\r\n"))
+		assert.NoError(t, err)
+		assert.Equal(t, expectedHash, hash)
+	})
+
+	t.Run("When the file has blank lines and no final line break", func(t *testing.T) {
+		hash, err := fingerprint.ContentHash(strings.NewReader(
+			"\n\nid,user_id,amount,datetime\n \n\r1,1,10,2024-01-01T00:00:00Z"))
+		assert.NoError(t, err)
+		assert.Equal(t, expectedHash, hash)
+	})
+
+	t.Run("When the file is read one byte at a time", func(t *testing.T) {
+		hash, err := fingerprint.ContentHash(iotest.OneByteReader(strings.NewReader(content)))
+		assert.NoError(t, err)
+		assert.Equal(t, expectedHash, hash)
+	})
+
+	t.Run("When the spaces are inside the line they're part of the content", func(t *testing.T) {
+		hash, err := fingerprint.ContentHash(strings.NewReader(
+			"id,user_id,amount,datetime\n1, 1,10,2024-01-01T00:00:00Z\n"))
+		assert.NoError(t, err)
+		assert.NotEqual(t, expectedHash, hash)
+	})
+
+	t.Run("When a record changes", func(t *testing.T) {
+		hash, err := fingerprint.ContentHash(strings.NewReader(
+			"id,user_id,amount,datetime\n1,1,11,2024-01-01T00:00:00Z\n"))
+		assert.NoError(t, err)
+		assert.NotEqual(t, expectedHash, hash)
+	})
+
+	t.Run("When the file can't be read", func(t *testing.T) {
+		_, err := fingerprint.ContentHash(iotest.ErrReader(errors.New("read error")))
+		assert.EqualError(t, err, "read error")
+	})
+}
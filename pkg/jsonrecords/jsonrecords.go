@@ -0,0 +1,176 @@
+package jsonrecords
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/sebastianreh/user-balance-api/pkg/records"
+)
+
+var errMapping = errors.New("column mappings are only supported for csv files")
+
+// NewNDJSONProcessor reads files with one JSON object per line, the record fields are the values of keys in order.
+// Blank lines are skipped
+func NewNDJSONProcessor(keys []string) records.Source {
+	return records.NewSource(func(src io.Reader, mapping *records.Mapping,
+		handle func(record records.Record) error) error {
+		return readLines(src, mapping, keys, handle)
+	})
+}
+
+// NewJSONArrayProcessor reads files with a JSON array of objects, the record fields are the values of keys in
+// order. The elements are decoded one at a time so the array is never held in memory
+func NewJSONArrayProcessor(keys []string) records.Source {
+	return records.NewSource(func(src io.Reader, mapping *records.Mapping,
+		handle func(record records.Record) error) error {
+		return readArray(src, mapping, keys, handle)
+	})
+}
+
+func readLines(src io.Reader, mapping *records.Mapping, keys []string,
+	handle func(record records.Record) error) error {
+	if mapping != nil {
+		return errMapping
+	}
+
+	reader := bufio.NewReader(src)
+	for line := 1; ; line++ {
+		content, err := reader.ReadBytes('\n')
+		if err != nil && !errors.Is(err, io.EOF) {
+			return err
+		}
+
+		raw := bytes.TrimRight(content, "\r\n")
+		if len(bytes.TrimSpace(raw)) > 0 {
+			record := records.Record{Line: line, Raw: string(raw)}
+			record.Fields, record.Err = decodeObject(raw, keys)
+			if record.Err != nil {
+				record.Err = fmt.Errorf("line %d: %w", line, record.Err)
+			}
+
+			if handleErr := handle(record); handleErr != nil {
+				return handleErr
+			}
+		}
+
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+	}
+}
+
+// readArray hands every element of the array as a record, its line is the one where the element starts. A syntax
+// error stops the reading since the next element can't be found
+func readArray(src io.Reader, mapping *records.Mapping, keys []string,
+	handle func(record records.Record) error) error {
+	if mapping != nil {
+		return errMapping
+	}
+
+	lines := &lineCounter{src: src}
+	decoder := json.NewDecoder(lines)
+	token, err := decoder.Token()
+	if err != nil {
+		return fmt.Errorf("the file must be a JSON array: %w", err)
+	}
+
+	if delim, isDelim := token.(json.Delim); !isDelim || delim != '[' {
+		return errors.New("the file must be a JSON array")
+	}
+
+	for decoder.More() {
+		var raw json.RawMessage
+		if err = decoder.Decode(&raw); err != nil {
+			return fmt.Errorf("line %d: %w", lines.lineAt(errorOffset(decoder, err)), err)
+		}
+
+		line := lines.lineAt(decoder.InputOffset() - int64(len(raw)))
+		record := records.Record{Line: line, Raw: string(raw)}
+		record.Fields, record.Err = decodeObject(raw, keys)
+		if record.Err != nil {
+			record.Err = fmt.Errorf("line %d: %w", line, record.Err)
+		}
+
+		if err = handle(record); err != nil {
+			return err
+		}
+	}
+
+	if _, err = decoder.Token(); err != nil {
+		return fmt.Errorf("line %d: %w", lines.lineAt(decoder.InputOffset()), err)
+	}
+
+	return nil
+}
+
+// decodeObject returns the values of keys as text, strings are unquoted and numbers keep the digits of the file.
+// A missing key or a null value is left empty for the validator to report
+func decodeObject(raw []byte, keys []string) ([]string, error) {
+	if trimmed := bytes.TrimSpace(raw); len(trimmed) > 0 && trimmed[0] != '{' {
+		return nil, errors.New("the record must be a JSON object")
+	}
+
+	var object map[string]json.RawMessage
+	if err := json.Unmarshal(raw, &object); err != nil {
+		return nil, err
+	}
+
+	fields := make([]string, len(keys))
+	for i, key := range keys {
+		value := bytes.TrimSpace(object[key])
+		switch {
+		case len(value) == 0 || string(value) == "null":
+		case value[0] == '"':
+			if err := json.Unmarshal(value, &fields[i]); err != nil {
+				return nil, err
+			}
+		case value[0] == '-' || (value[0] >= '0' && value[0] <= '9'):
+			fields[i] = string(value)
+		default:
+			return nil, fmt.Errorf("%s must be a string or a number", key)
+		}
+	}
+
+	return fields, nil
+}
+
+// errorOffset returns where a syntax error was found, the decoder offset doesn't move past a value it can't decode
+func errorOffset(decoder *json.Decoder, err error) int64 {
+	var syntaxErr *json.SyntaxError
+	if errors.As(err, &syntaxErr) {
+		return syntaxErr.Offset
+	}
+
+	return decoder.InputOffset()
+}
+
+// lineCounter keeps the bytes read by the decoder until their lines are counted, so it holds at most the decoder
+// buffer
+type lineCounter struct {
+	src    io.Reader
+	buf    []byte
+	offset int64
+	lines  int
+}
+
+func (l *lineCounter) Read(p []byte) (int, error) {
+	n, err := l.src.Read(p)
+	l.buf = append(l.buf, p[:n]...)
+	return n, err
+}
+
+// lineAt returns the line of the byte in offset, the offsets must be asked in order
+func (l *lineCounter) lineAt(offset int64) int {
+	size := int(min(offset-l.offset, int64(len(l.buf))))
+	if size > 0 {
+		l.lines += bytes.Count(l.buf[:size], []byte{'\n'})
+		l.buf = append(l.buf[:0], l.buf[size:]...)
+		l.offset += int64(size)
+	}
+
+	return l.lines + 1
+}
@@ -0,0 +1,128 @@
+package jsonrecords_test
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/sebastianreh/user-balance-api/pkg/jsonrecords"
+	"github.com/sebastianreh/user-balance-api/pkg/records"
+	"github.com/stretchr/testify/assert"
+)
+
+var keys = []string{"id", "user_id", "amount", "date_time"}
+
+func collect(t *testing.T, source records.Source, content string) records.Batch {
+	batches := make(chan records.Batch, 10)
+	err := source.StreamBatches(context.Background(), strings.NewReader(content), nil, 10, batches)
+	assert.Nil(t, err)
+
+	var collected records.Batch
+	for batch := range batches {
+		collected = append(collected, batch...)
+	}
+
+	return collected
+}
+
+func Test_NDJSONProcessor(t *testing.T) {
+	processor := jsonrecords.NewNDJSONProcessor(keys)
+
+	t.Run("When the objects are read the fields follow the keys and numbers keep their text", func(t *testing.T) {
+		content := `{"date_time":"2024-01-01T00:00:00Z","amount":10.50,"user_id":1,"id":"1"}` + "\n\n" +
+			`{"id":2,"user_id":1,"amount":-5,"date_time":"2024-01-01T00:00:00Z"}` + "\n"
+
+		collected := collect(t, processor, content)
+
+		assert.Len(t, collected, 2)
+		assert.Equal(t, []string{"1", "1", "10.50", "2024-01-01T00:00:00Z"}, collected[0].Fields)
+		assert.Equal(t, 1, collected[0].Line)
+		assert.Equal(t, 3, collected[1].Line)
+	})
+
+	t.Run("When a line can't be parsed it's sent with its error and raw content", func(t *testing.T) {
+		content := `{"id":1,"user_id":1,"amount":10,"date_time":"2024-01-01T00:00:00Z"}` + "\r\n{\"id\":2,\n"
+
+		collected := collect(t, processor, content)
+
+		assert.Len(t, collected, 2)
+		assert.Nil(t, collected[0].Err)
+		assert.ErrorContains(t, collected[1].Err, "line 2:")
+		assert.Equal(t, `{"id":2,`, collected[1].Raw)
+	})
+
+	t.Run("When a key is missing or null its field is empty", func(t *testing.T) {
+		collected := collect(t, processor, `{"id":1,"user_id":null,"amount":10}`)
+
+		assert.Equal(t, []string{"1", "", "10", ""}, collected[0].Fields)
+	})
+
+	t.Run("When a value is not a string nor a number", func(t *testing.T) {
+		collected := collect(t, processor, `{"id":1,"user_id":1,"amount":true}`)
+
+		assert.EqualError(t, collected[0].Err, "line 1: amount must be a string or a number")
+	})
+
+	t.Run("When a line is not an object", func(t *testing.T) {
+		collected := collect(t, processor, "[1,2]\nnull\n")
+
+		assert.Error(t, collected[0].Err)
+		assert.EqualError(t, collected[1].Err, "line 2: the record must be a JSON object")
+	})
+
+	t.Run("When the file is validated with a mapping", func(t *testing.T) {
+		_, err := processor.Validate(strings.NewReader("{}"), &records.Mapping{}, func([]string) error { return nil })
+
+		assert.EqualError(t, err, "column mappings are only supported for csv files")
+	})
+
+	t.Run("When the records are counted", func(t *testing.T) {
+		count, err := processor.Count(strings.NewReader("{}\n\n{\"id\":\n{}"))
+
+		assert.Nil(t, err)
+		assert.Equal(t, 3, count)
+	})
+}
+
+func Test_JSONArrayProcessor(t *testing.T) {
+	processor := jsonrecords.NewJSONArrayProcessor(keys)
+
+	t.Run("When the elements are read their line is the one where they start", func(t *testing.T) {
+		content := "[\n  {\"id\": 1, \"user_id\": 1,\n   \"amount\": 10, \"date_time\": \"2024-01-01T00:00:00Z\"},\n" +
+			"  {\"id\": 2, \"user_id\": 1, \"amount\": -5, \"date_time\": \"2024-01-01T00:00:00Z\"}\n]\n"
+
+		collected := collect(t, processor, content)
+
+		assert.Len(t, collected, 2)
+		assert.Equal(t, 2, collected[0].Line)
+		assert.Equal(t, 4, collected[1].Line)
+		assert.Equal(t, []string{"2", "1", "-5", "2024-01-01T00:00:00Z"}, collected[1].Fields)
+	})
+
+	t.Run("When an element is not an object it's sent with its error", func(t *testing.T) {
+		collected := collect(t, processor, "[{}, \"text\"]")
+
+		assert.Len(t, collected, 2)
+		assert.Nil(t, collected[0].Err)
+		assert.EqualError(t, collected[1].Err, "line 1: the record must be a JSON object")
+	})
+
+	t.Run("When the file is not an array", func(t *testing.T) {
+		_, err := processor.Count(strings.NewReader(`{"id":1}`))
+
+		assert.EqualError(t, err, "the file must be a JSON array")
+	})
+
+	t.Run("When the array has a syntax error the reading stops", func(t *testing.T) {
+		_, err := processor.Count(strings.NewReader("[\n{},\n{\"id\" 1}\n]"))
+
+		assert.ErrorContains(t, err, "line 3:")
+	})
+
+	t.Run("When the file is validated", func(t *testing.T) {
+		count, err := processor.Validate(strings.NewReader("[{}, {}, {}]"), nil, func([]string) error { return nil })
+
+		assert.Nil(t, err)
+		assert.Equal(t, 3, count)
+	})
+}
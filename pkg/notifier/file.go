@@ -0,0 +1,56 @@
+package notifier
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/sebastianreh/user-balance-api/pkg/email"
+)
+
+const (
+	fileSinkFrom = "notifications@localhost"
+)
+
+type fileChannel struct {
+	dir      string
+	sequence atomic.Int64
+}
+
+// NewFileChannel writes every notification to the directory as a JSON file and as an .eml file that mail clients
+// can open, so the notifications can be checked in development and tests without sending them
+func NewFileChannel(dir string) Channel {
+	return &fileChannel{dir: dir}
+}
+
+func (c *fileChannel) Send(_ context.Context, recipients []string, notification Notification) error {
+	if err := os.MkdirAll(c.dir, 0o750); err != nil {
+		return err
+	}
+
+	// The sequence keeps apart the notifications written in the same instant
+	name := fmt.Sprintf("%d-%d-%s", time.Now().UnixNano(), c.sequence.Add(1),
+		strings.ReplaceAll(notification.Event, ".", "-"))
+
+	content, err := json.MarshalIndent(webhookPayload{Notification: notification, Recipients: recipients}, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	if err = os.WriteFile(filepath.Join(c.dir, name+".json"), content, 0o600); err != nil {
+		return err
+	}
+
+	message, err := email.Build(email.Message{From: fileSinkFrom, To: recipients, Subject: notification.Subject,
+		Text: notification.Text})
+	if err != nil {
+		return err
+	}
+
+	return os.WriteFile(filepath.Join(c.dir, name+".eml"), message, 0o600)
+}
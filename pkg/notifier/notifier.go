@@ -0,0 +1,89 @@
+package notifier
+
+import (
+	"context"
+	"errors"
+	"fmt"
+)
+
+const (
+	EventMigrationFinished = "migration.finished"
+	EventMigrationFailed   = "migration.failed"
+	EventLowBalance        = "balance.low"
+
+	ChannelSMTP    = "smtp"
+	ChannelWebhook = "webhook"
+	ChannelSlack   = "slack"
+	ChannelFile    = "file"
+
+	UnknownChannelError = "unknown notification channel"
+)
+
+// Events are the events that can be routed
+func Events() []string {
+	return []string{EventMigrationFinished, EventMigrationFailed, EventLowBalance}
+}
+
+// Notification is an event worth telling someone about, Data carries its fields for the channels that read them
+type Notification struct {
+	Event   string         `json:"event"`
+	Subject string         `json:"subject"`
+	Text    string         `json:"text"`
+	Data    map[string]any `json:"data,omitempty"`
+}
+
+// Channel sends notifications through one medium, recipients are those of the route and each channel reads them its
+// own way
+type Channel interface {
+	Send(ctx context.Context, recipients []string, notification Notification) error
+}
+
+// Delivery is a notification to send through a channel of its route
+type Delivery struct {
+	Channel      string       `json:"channel"`
+	Recipients   []string     `json:"recipients,omitempty"`
+	Notification Notification `json:"notification"`
+}
+
+type Notifier interface {
+	// Deliveries returns a delivery for every route of the notification event, none when the event is not routed
+	Deliveries(notification Notification) []Delivery
+	Deliver(ctx context.Context, delivery Delivery) error
+}
+
+type router struct {
+	channels map[string]Channel
+	routes   Routes
+}
+
+// NewRouter fails when a route points to a channel that is not configured, so a typo doesn't drop notifications
+func NewRouter(channels map[string]Channel, routes Routes) (Notifier, error) {
+	for event, eventRoutes := range routes {
+		for _, route := range eventRoutes {
+			if _, found := channels[route.Channel]; !found {
+				return nil, fmt.Errorf("%s %s in the routes of %s", UnknownChannelError, route.Channel, event)
+			}
+		}
+	}
+
+	return &router{channels: channels, routes: routes}, nil
+}
+
+func (r *router) Deliveries(notification Notification) []Delivery {
+	deliveries := make([]Delivery, 0, len(r.routes[notification.Event]))
+	for _, route := range r.routes[notification.Event] {
+		deliveries = append(deliveries, Delivery{Channel: route.Channel, Recipients: route.Recipients,
+			Notification: notification})
+	}
+
+	return deliveries
+}
+
+func (r *router) Deliver(ctx context.Context, delivery Delivery) error {
+	channel, found := r.channels[delivery.Channel]
+	if !found {
+		return errors.New(UnknownChannelError + ": " + delivery.Channel)
+	}
+
+	return channel.Send(ctx, delivery.Recipients, delivery.Notification)
+}
@@ -0,0 +1,136 @@
+package notifier
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_ParseRoutes(t *testing.T) {
+	t.Run("When every event has its channels and recipients", func(t *testing.T) {
+		routes, err := ParseRoutes("migration.finished=smtp:ops@example.com|finance@example.com,slack; " +
+			"balance.low=webhook")
+
+		assert.Nil(t, err)
+		assert.Equal(t, Routes{
+			EventMigrationFinished: {
+				{Channel: ChannelSMTP, Recipients: []string{"ops@example.com", "finance@example.com"}},
+				{Channel: ChannelSlack},
+			},
+			EventLowBalance: {{Channel: ChannelWebhook}},
+		}, routes)
+	})
+
+	t.Run("When no routes are set", func(t *testing.T) {
+		routes, err := ParseRoutes("")
+
+		assert.Nil(t, err)
+		assert.Empty(t, routes)
+	})
+
+	t.Run("When the event is unknown", func(t *testing.T) {
+		_, err := ParseRoutes("migration.done=smtp")
+		assert.ErrorContains(t, err, "unknown event migration.done")
+	})
+
+	t.Run("When a route has no channel", func(t *testing.T) {
+		_, err := ParseRoutes("migration.failed=smtp,")
+		assert.ErrorContains(t, err, "a channel is empty")
+	})
+}
+
+func Test_Router(t *testing.T) {
+	ctx := context.TODO()
+	dir := t.TempDir()
+	channels := map[string]Channel{ChannelFile: NewFileChannel(dir)}
+	notification := Notification{Event: EventMigrationFailed, Subject: "Migration 1 failed", Text: "Error: bad file"}
+
+	t.Run("When a route points to a channel that is not configured", func(t *testing.T) {
+		_, err := NewRouter(channels, Routes{EventMigrationFailed: {{Channel: ChannelSlack}}})
+		assert.ErrorContains(t, err, UnknownChannelError+" slack")
+	})
+
+	t.Run("When a notification is delivered to the file channel", func(t *testing.T) {
+		router, err := NewRouter(channels, Routes{
+			EventMigrationFailed: {{Channel: ChannelFile, Recipients: []string{"ops@example.com"}}},
+		})
+		assert.Nil(t, err)
+
+		deliveries := router.Deliveries(notification)
+		assert.Equal(t, []Delivery{{Channel: ChannelFile, Recipients: []string{"ops@example.com"},
+			Notification: notification}}, deliveries)
+		assert.Empty(t, router.Deliveries(Notification{Event: EventLowBalance}))
+
+		assert.Nil(t, router.Deliver(ctx, deliveries[0]))
+		jsonFiles, _ := filepath.Glob(filepath.Join(dir, "*-migration-failed.json"))
+		emlFiles, _ := filepath.Glob(filepath.Join(dir, "*-migration-failed.eml"))
+		assert.Len(t, jsonFiles, 1)
+		assert.Len(t, emlFiles, 1)
+
+		eml, _ := os.ReadFile(emlFiles[0])
+		assert.Contains(t, string(eml), "To: ops@example.com")
+		assert.Contains(t, string(eml), "Subject: Migration 1 failed")
+	})
+
+	t.Run("When the channel of a delivery is unknown", func(t *testing.T) {
+		router, _ := NewRouter(channels, Routes{})
+		err := router.Deliver(ctx, Delivery{Channel: ChannelWebhook, Notification: notification})
+		assert.ErrorContains(t, err, UnknownChannelError)
+	})
+}
+
+func Test_WebhookChannel(t *testing.T) {
+	ctx := context.TODO()
+	notification := Notification{Event: EventLowBalance, Subject: "Low balance of user 1", Text: "It dropped",
+		Data: map[string]any{"user_id": "1"}}
+
+	t.Run("When the notification is posted as JSON with its recipients", func(t *testing.T) {
+		var received map[string]any
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			assert.Equal(t, "application/json", r.Header.Get("Content-Type"))
+			_ = json.NewDecoder(r.Body).Decode(&received)
+			w.WriteHeader(http.StatusNoContent)
+		}))
+		defer server.Close()
+
+		err := NewWebhookChannel(server.Client(), server.URL).Send(ctx, []string{"billing"}, notification)
+
+		assert.Nil(t, err)
+		assert.Equal(t, EventLowBalance, received["event"])
+		assert.Equal(t, map[string]any{"user_id": "1"}, received["data"])
+		assert.Equal(t, []any{"billing"}, received["recipients"])
+	})
+
+	t.Run("When the Slack webhook gets the text with the recipients mentioned", func(t *testing.T) {
+		var body []byte
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			body, _ = io.ReadAll(r.Body)
+		}))
+		defer server.Close()
+
+		err := NewSlackChannel(server.Client(), server.URL).Send(ctx, []string{"@U024BE7LH", "!here"}, notification)
+
+		assert.Nil(t, err)
+		assert.JSONEq(t, `{"text":"<@U024BE7LH> <!here> *Low balance of user 1*\nIt dropped"}`, string(body))
+	})
+
+	t.Run("When the webhook refuses the notification", func(t *testing.T) {
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			http.Error(w, strings.Repeat("invalid payload", 100), http.StatusBadRequest)
+		}))
+		defer server.Close()
+
+		err := NewWebhookChannel(server.Client(), server.URL).Send(ctx, nil, notification)
+
+		assert.ErrorContains(t, err, "the webhook answered 400: invalid payload")
+		assert.Less(t, len(err.Error()), 600)
+	})
+}
@@ -0,0 +1,56 @@
+package notifier
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+)
+
+// Route sends the notifications of an event through a channel to its recipients
+type Route struct {
+	Channel    string
+	Recipients []string
+}
+
+// Routes are the routes of every event
+type Routes map[string][]Route
+
+// ParseRoutes reads routes written as event=channel:recipient|recipient,channel;event=channel. A channel without
+// recipients sends to its default ones, like the SMTP recipient or the channel of a Slack webhook
+func ParseRoutes(value string) (Routes, error) {
+	routes := make(Routes)
+	for _, eventRoutes := range strings.Split(value, ";") {
+		eventRoutes = strings.TrimSpace(eventRoutes)
+		if eventRoutes == "" {
+			continue
+		}
+
+		event, channels, found := strings.Cut(eventRoutes, "=")
+		event = strings.TrimSpace(event)
+		if !found || event == "" {
+			return nil, fmt.Errorf("invalid notification route %q, it must be event=channel", eventRoutes)
+		}
+
+		if !slices.Contains(Events(), event) {
+			return nil, fmt.Errorf("invalid notification route %q, unknown event %s", eventRoutes, event)
+		}
+
+		for _, channel := range strings.Split(channels, ",") {
+			name, recipients, _ := strings.Cut(channel, ":")
+			route := Route{Channel: strings.TrimSpace(name)}
+			if route.Channel == "" {
+				return nil, fmt.Errorf("invalid notification route %q, a channel is empty", eventRoutes)
+			}
+
+			for _, recipient := range strings.Split(recipients, "|") {
+				if recipient = strings.TrimSpace(recipient); recipient != "" {
+					route.Recipients = append(route.Recipients, recipient)
+				}
+			}
+
+			routes[event] = append(routes[event], route)
+		}
+	}
+
+	return routes, nil
+}
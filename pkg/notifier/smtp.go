@@ -0,0 +1,20 @@
+package notifier
+
+import (
+	"context"
+
+	"github.com/sebastianreh/user-balance-api/pkg/email"
+)
+
+type smtpChannel struct {
+	emailService email.EmailService
+}
+
+// NewSMTPChannel emails the notifications, to the default recipient of the email service when the route has none
+func NewSMTPChannel(emailService email.EmailService) Channel {
+	return &smtpChannel{emailService: emailService}
+}
+
+func (c *smtpChannel) Send(_ context.Context, recipients []string, notification Notification) error {
+	return c.emailService.Send(email.Message{To: recipients, Subject: notification.Subject, Text: notification.Text})
+}
@@ -0,0 +1,86 @@
+package notifier
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+)
+
+const (
+	// responseExcerptSize is how much of a refused response is kept in the error
+	responseExcerptSize = 512
+)
+
+// webhookPayload is the body posted by the generic webhook
+type webhookPayload struct {
+	Notification
+	Recipients []string `json:"recipients,omitempty"`
+}
+
+// slackPayload is the body of a Slack incoming webhook, the services compatible with them accept it too
+type slackPayload struct {
+	Text string `json:"text"`
+}
+
+type webhookChannel struct {
+	client *http.Client
+	url    string
+	slack  bool
+}
+
+// NewWebhookChannel posts the notifications as JSON to the URL, along with the recipients of the route
+func NewWebhookChannel(client *http.Client, url string) Channel {
+	return &webhookChannel{client: client, url: url}
+}
+
+// NewSlackChannel posts the notifications to a Slack incoming webhook. The webhook decides the Slack channel, so the
+// recipients of the route are mentioned in the message
+func NewSlackChannel(client *http.Client, url string) Channel {
+	return &webhookChannel{client: client, url: url, slack: true}
+}
+
+func (c *webhookChannel) Send(ctx context.Context, recipients []string, notification Notification) error {
+	var payload any = webhookPayload{Notification: notification, Recipients: recipients}
+	if c.slack {
+		payload = slackPayload{Text: slackText(recipients, notification)}
+	}
+
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+
+	request, err := http.NewRequestWithContext(ctx, http.MethodPost, c.url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	request.Header.Set("Content-Type", "application/json")
+
+	response, err := c.client.Do(request)
+	if err != nil {
+		return err
+	}
+	defer response.Body.Close()
+
+	if response.StatusCode < http.StatusOK || response.StatusCode >= http.StatusMultipleChoices {
+		excerpt, _ := io.ReadAll(io.LimitReader(response.Body, responseExcerptSize))
+		return fmt.Errorf("the webhook answered %d: %s", response.StatusCode, excerpt)
+	}
+
+	return nil
+}
+
+// slackText mentions the recipients before the message, they are written like @U024BE7LH or !here
+func slackText(recipients []string, notification Notification) string {
+	var text strings.Builder
+	for _, recipient := range recipients {
+		fmt.Fprintf(&text, "<%s> ", recipient)
+	}
+	fmt.Fprintf(&text, "*%s*\n%s", notification.Subject, notification.Text)
+
+	return text.String()
+}
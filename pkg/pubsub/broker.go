@@ -0,0 +1,116 @@
+package pubsub
+
+import (
+	"errors"
+	"sync"
+)
+
+const (
+	TooManySubscribersError      = "too many subscribers"
+	TooManyTopicSubscribersError = "too many subscribers of the topic"
+)
+
+// Broker signals the subscribers of a topic in process. A signal only tells that the topic changed, so a subscriber
+// with a signal pending gets no second one and a burst of changes reaches a slow subscriber as one signal
+type Broker struct {
+	mu             sync.Mutex
+	maxSubscribers int
+	maxPerTopic    int
+	subscribers    int
+	topics         map[string]map[*Subscription]struct{}
+}
+
+// Subscription receives the signals of its topic until it's closed
+type Subscription struct {
+	topic   string
+	signals chan struct{}
+	broker  *Broker
+	once    sync.Once
+}
+
+// NewBroker limits the subscribers in total and by topic, a limit of zero or less is no limit
+func NewBroker(maxSubscribers, maxPerTopic int) *Broker {
+	return &Broker{
+		maxSubscribers: maxSubscribers,
+		maxPerTopic:    maxPerTopic,
+		topics:         make(map[string]map[*Subscription]struct{}),
+	}
+}
+
+func (b *Broker) Subscribe(topic string) (*Subscription, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.maxSubscribers > 0 && b.subscribers >= b.maxSubscribers {
+		return nil, errors.New(TooManySubscribersError)
+	}
+
+	subscriptions := b.topics[topic]
+	if b.maxPerTopic > 0 && len(subscriptions) >= b.maxPerTopic {
+		return nil, errors.New(TooManyTopicSubscribersError)
+	}
+
+	if subscriptions == nil {
+		subscriptions = make(map[*Subscription]struct{})
+		b.topics[topic] = subscriptions
+	}
+
+	subscription := &Subscription{topic: topic, signals: make(chan struct{}, 1), broker: b}
+	subscriptions[subscription] = struct{}{}
+	b.subscribers++
+
+	return subscription, nil
+}
+
+// Publish signals the subscribers of the topic without waiting for them
+func (b *Broker) Publish(topic string) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	for subscription := range b.topics[topic] {
+		subscription.signal()
+	}
+}
+
+// PublishAll signals every subscriber, for when changes could have been missed and no topic can be told apart
+func (b *Broker) PublishAll() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	for _, subscriptions := range b.topics {
+		for subscription := range subscriptions {
+			subscription.signal()
+		}
+	}
+}
+
+func (b *Broker) unsubscribe(subscription *Subscription) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	subscriptions := b.topics[subscription.topic]
+	delete(subscriptions, subscription)
+	if len(subscriptions) == 0 {
+		delete(b.topics, subscription.topic)
+	}
+	b.subscribers--
+}
+
+// Signals receives a value after every change of the topic, changes made while one is pending are merged into it
+func (s *Subscription) Signals() <-chan struct{} {
+	return s.signals
+}
+
+// Close releases the place of the subscription in the limits, it can be called more than once
+func (s *Subscription) Close() {
+	s.once.Do(func() {
+		s.broker.unsubscribe(s)
+	})
+}
+
+func (s *Subscription) signal() {
+	select {
+	case s.signals <- struct{}{}:
+	default:
+	}
+}
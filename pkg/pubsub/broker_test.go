@@ -0,0 +1,65 @@
+package pubsub_test
+
+import (
+	"testing"
+
+	"github.com/sebastianreh/user-balance-api/pkg/pubsub"
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_Broker(t *testing.T) {
+	t.Run("When only the subscribers of the topic are signaled", func(t *testing.T) {
+		broker := pubsub.NewBroker(0, 0)
+		first, _ := broker.Subscribe("1")
+		second, _ := broker.Subscribe("1")
+		other, _ := broker.Subscribe("2")
+
+		broker.Publish("1")
+
+		assert.Len(t, first.Signals(), 1)
+		assert.Len(t, second.Signals(), 1)
+		assert.Empty(t, other.Signals())
+	})
+
+	t.Run("When a burst of changes is merged into one pending signal", func(t *testing.T) {
+		broker := pubsub.NewBroker(0, 0)
+		subscription, _ := broker.Subscribe("1")
+
+		broker.Publish("1")
+		broker.Publish("1")
+		broker.PublishAll()
+
+		<-subscription.Signals()
+		assert.Empty(t, subscription.Signals())
+	})
+
+	t.Run("When the limits are reached until a subscription is closed", func(t *testing.T) {
+		broker := pubsub.NewBroker(2, 1)
+		first, err := broker.Subscribe("1")
+		assert.Nil(t, err)
+
+		_, err = broker.Subscribe("1")
+		assert.EqualError(t, err, pubsub.TooManyTopicSubscribersError)
+
+		_, err = broker.Subscribe("2")
+		assert.Nil(t, err)
+
+		_, err = broker.Subscribe("3")
+		assert.EqualError(t, err, pubsub.TooManySubscribersError)
+
+		first.Close()
+		first.Close()
+		_, err = broker.Subscribe("1")
+		assert.Nil(t, err)
+	})
+
+	t.Run("When a closed subscription is not signaled", func(t *testing.T) {
+		broker := pubsub.NewBroker(0, 0)
+		subscription, _ := broker.Subscribe("1")
+		subscription.Close()
+
+		broker.Publish("1")
+
+		assert.Empty(t, subscription.Signals())
+	})
+}
@@ -0,0 +1,106 @@
+package records
+
+import (
+	"bytes"
+	"fmt"
+	"mime"
+	"path"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+const (
+	FormatCSV = "csv"
+	// FormatNDJSON has one JSON object per line
+	FormatNDJSON = "ndjson"
+	// FormatJSON is a JSON array of objects
+	FormatJSON = "json"
+)
+
+// compressedExtensions are skipped to find the extension of the content, a compressed file is named after it
+var compressedExtensions = map[string]bool{".gz": true, ".gzip": true, ".zst": true, ".zstd": true, ".zip": true}
+
+// DeclaredFormat picks the format by the extension of the file name, or by its content type when the extension is
+// not a known one. Generic content types like application/octet-stream declare nothing
+func DeclaredFormat(fileName, contentType string) (string, bool) {
+	name := strings.ToLower(strings.TrimSpace(fileName))
+	extension := path.Ext(name)
+	if compressedExtensions[extension] {
+		extension = path.Ext(strings.TrimSuffix(name, extension))
+	}
+
+	switch extension {
+	case ".csv":
+		return FormatCSV, true
+	case ".ndjson", ".jsonl":
+		return FormatNDJSON, true
+	case ".json":
+		return FormatJSON, true
+	}
+
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return "", false
+	}
+
+	switch mediaType {
+	case "text/csv", "application/csv":
+		return FormatCSV, true
+	case "application/x-ndjson", "application/ndjson", "application/jsonl", "application/x-jsonlines":
+		return FormatNDJSON, true
+	case "application/json":
+		return FormatJSON, true
+	}
+
+	return "", false
+}
+
+// SniffFormat picks the format of a file by its first bytes. JSON arrays start with '[' and JSON Lines with '{', any
+// other text is read as CSV. Leading spaces and a byte order mark are skipped
+func SniffFormat(head []byte) (string, bool) {
+	text := bytes.TrimLeftFunc(bytes.TrimPrefix(head, []byte("\ufeff")), unicode.IsSpace)
+	if len(text) == 0 {
+		return "", false
+	}
+
+	switch text[0] {
+	case '[':
+		return FormatJSON, true
+	case '{':
+		return FormatNDJSON, true
+	}
+
+	return FormatCSV, isText(text)
+}
+
+// isText tells whether head is UTF-8 without control characters other than line breaks and tabs, the head may end in
+// the middle of a character
+func isText(head []byte) bool {
+	for len(head) > 0 {
+		char, size := utf8.DecodeRune(head)
+		if char == utf8.RuneError && size <= 1 {
+			return !utf8.FullRune(head)
+		}
+
+		if unicode.IsControl(char) && char != '\n' && char != '\r' && char != '\t' {
+			return false
+		}
+
+		head = head[size:]
+	}
+
+	return true
+}
+
+// Sources holds the source of every supported format
+type Sources map[string]Source
+
+func (s Sources) Get(format string) (Source, error) {
+	source, found := s[format]
+	if !found {
+		return nil, fmt.Errorf("unsupported file format %q", format)
+	}
+
+	return source, nil
+}
@@ -0,0 +1,84 @@
+package records_test
+
+import (
+	"testing"
+
+	"github.com/sebastianreh/user-balance-api/pkg/records"
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_SniffFormat(t *testing.T) {
+	t.Run("When the file starts with an array after spaces and a byte order mark", func(t *testing.T) {
+		format, found := records.SniffFormat([]byte("\ufeff \n[{\"id\":1}]"))
+
+		assert.True(t, found)
+		assert.Equal(t, records.FormatJSON, format)
+	})
+
+	t.Run("When the file starts with an object", func(t *testing.T) {
+		format, found := records.SniffFormat([]byte(`{"id":1}`))
+
+		assert.True(t, found)
+		assert.Equal(t, records.FormatNDJSON, format)
+	})
+
+	t.Run("When the file is text cut in the middle of a character", func(t *testing.T) {
+		format, found := records.SniffFormat([]byte("id,user_id,amount,datetime\n1,1,10,año\xc3"))
+
+		assert.True(t, found)
+		assert.Equal(t, records.FormatCSV, format)
+	})
+
+	t.Run("When the file is binary", func(t *testing.T) {
+		_, found := records.SniffFormat([]byte("\x89PNG\r\n\x1a\n\x00\x00"))
+
+		assert.False(t, found)
+	})
+
+	t.Run("When the file is empty", func(t *testing.T) {
+		_, found := records.SniffFormat([]byte(" \n"))
+
+		assert.False(t, found)
+	})
+}
+
+func Test_DeclaredFormat(t *testing.T) {
+	t.Run("When the extension declares the format", func(t *testing.T) {
+		for fileName, expected := range map[string]string{"balances.CSV": records.FormatCSV,
+			"balances.ndjson": records.FormatNDJSON, "balances.jsonl": records.FormatNDJSON,
+			"balances.json": records.FormatJSON, "balances.ndjson.gz": records.FormatNDJSON} {
+			format, found := records.DeclaredFormat(fileName, "application/octet-stream")
+
+			assert.True(t, found, fileName)
+			assert.Equal(t, expected, format, fileName)
+		}
+	})
+
+	t.Run("When the extension is checked before the content type", func(t *testing.T) {
+		format, found := records.DeclaredFormat("balances.json", "text/csv")
+
+		assert.True(t, found)
+		assert.Equal(t, records.FormatJSON, format)
+	})
+
+	t.Run("When only the content type declares the format", func(t *testing.T) {
+		format, found := records.DeclaredFormat("balances", "application/x-ndjson; charset=utf-8")
+
+		assert.True(t, found)
+		assert.Equal(t, records.FormatNDJSON, format)
+	})
+
+	t.Run("When neither declares the format", func(t *testing.T) {
+		_, found := records.DeclaredFormat("balances.zip", "application/zip")
+
+		assert.False(t, found)
+	})
+}
+
+func Test_Sources_Get(t *testing.T) {
+	t.Run("When the format has no source", func(t *testing.T) {
+		_, err := records.Sources{}.Get(records.FormatJSON)
+
+		assert.EqualError(t, err, `unsupported file format "json"`)
+	})
+}
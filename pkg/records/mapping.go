@@ -0,0 +1,107 @@
+package records
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Mapping picks the columns of a file by their header names and sorts them in the order of Fields, so the files can
+// have their columns in any order. A nil mapping keeps the columns as they are
+type Mapping struct {
+	Fields []string
+	// Columns lists the header names accepted for a field besides the field name itself
+	Columns map[string][]string
+	// Ignored columns are skipped, like any other column that is not mapped unless the mapping is strict
+	Ignored []string
+	// Defaults fill the fields that have no column in the file
+	Defaults map[string]string
+
+	// Strict makes the file invalid when a column is neither mapped nor ignored
+	Strict bool
+}
+
+// Binding is a mapping applied to the header of a file, a negative index takes the default of the field
+type Binding struct {
+	indexes  []int
+	defaults []string
+}
+
+// Bind finds the column of every field in the header of a file
+func (m *Mapping) Bind(header []string) (*Binding, error) {
+	columns := make(map[string]int, len(header))
+	for i, name := range header {
+		columns[normalizeColumn(name)] = i
+	}
+
+	used := make(map[int]string, len(m.Fields))
+	bound := &Binding{indexes: make([]int, len(m.Fields)), defaults: make([]string, len(m.Fields))}
+	for i, field := range m.Fields {
+		bound.indexes[i] = -1
+		for _, name := range append([]string{field}, m.Columns[field]...) {
+			index, found := columns[normalizeColumn(name)]
+			if !found || index == bound.indexes[i] {
+				continue
+			}
+
+			if bound.indexes[i] >= 0 {
+				return nil, fmt.Errorf("columns %q and %q are both mapped to %s",
+					header[bound.indexes[i]], header[index], field)
+			}
+
+			if other, taken := used[index]; taken {
+				return nil, fmt.Errorf("column %q is mapped to %s and %s", header[index], other, field)
+			}
+
+			bound.indexes[i] = index
+			used[index] = field
+		}
+
+		if bound.indexes[i] >= 0 {
+			continue
+		}
+
+		value, hasDefault := m.Defaults[field]
+		if !hasDefault {
+			return nil, fmt.Errorf("missing column for %s", field)
+		}
+		bound.defaults[i] = value
+	}
+
+	if !m.Strict {
+		return bound, nil
+	}
+
+	ignored := make(map[string]bool, len(m.Ignored))
+	for _, name := range m.Ignored {
+		ignored[normalizeColumn(name)] = true
+	}
+
+	for i, name := range header {
+		if _, mapped := used[i]; !mapped && !ignored[normalizeColumn(name)] {
+			return nil, fmt.Errorf("unknown column %q", name)
+		}
+	}
+
+	return bound, nil
+}
+
+// Apply returns the record fields in the mapping order, a missing column is left empty for the validator to report
+func (b *Binding) Apply(fields []string) []string {
+	mapped := make([]string, len(b.indexes))
+	for i, index := range b.indexes {
+		switch {
+		case index < 0:
+			mapped[i] = b.defaults[i]
+		case index < len(fields):
+			mapped[i] = fields[index]
+		}
+	}
+
+	return mapped
+}
+
+// normalizeColumn compares the header names without case, surrounding spaces or the byte order mark some
+// spreadsheets write at the start of the file
+func normalizeColumn(name string) string {
+	return strings.ToLower(strings.TrimSpace(strings.TrimPrefix(name, "\ufeff")))
+}
@@ -0,0 +1,126 @@
+package records
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+)
+
+const (
+	// MinRecords is the least amount of records a file needs to pass Validate
+	MinRecords = 2
+)
+
+// Opener opens the file again for every pass over it, so it's never held in memory
+type Opener func() (io.ReadCloser, error)
+
+type Record struct {
+	Line   int
+	Fields []string
+	// Raw is the record as it was written in the file, without the line break
+	Raw string
+	// Err is set when the record could not be parsed, Fields is empty in that case
+	Err error
+}
+
+type Batch []Record
+
+// Source reads the records of a file in one format, every format goes through the same validation and batching
+type Source interface {
+	Validate(src io.Reader, mapping *Mapping, recordValidator func(record []string) error) (int, error)
+	Count(src io.Reader) (int, error)
+	StreamBatches(ctx context.Context, src io.Reader, mapping *Mapping, batchSize int, batches chan<- Batch) error
+}
+
+// ReadFunc goes through the records of a file in order handing them to handle, a record that can't be parsed is
+// handed with its error and the reading goes on. The fields are sorted by the mapping when there is one
+type ReadFunc func(src io.Reader, mapping *Mapping, handle func(record Record) error) error
+
+type source struct {
+	read ReadFunc
+}
+
+// NewSource builds a Source on top of the reader of a format
+func NewSource(read ReadFunc) Source {
+	return &source{read: read}
+}
+
+// Validate reads the file one record at a time and returns how many records it has, the first invalid record stops
+// the reading with its line number in the error
+func (s *source) Validate(src io.Reader, mapping *Mapping, recordValidator func(record []string) error) (int, error) {
+	var records int
+	err := s.read(src, mapping, func(record Record) error {
+		if record.Err != nil {
+			return record.Err
+		}
+
+		if err := recordValidator(record.Fields); err != nil {
+			return fmt.Errorf("line %d: %w", record.Line, err)
+		}
+
+		records++
+		return nil
+	})
+	if err != nil {
+		return records, err
+	}
+
+	if records < MinRecords {
+		return records, fmt.Errorf("the file must have at least %d records", MinRecords)
+	}
+
+	return records, nil
+}
+
+// Count returns how many records the file has, including the ones that can't be parsed
+func (s *source) Count(src io.Reader) (int, error) {
+	var records int
+	err := s.read(src, nil, func(record Record) error {
+		records++
+		return nil
+	})
+
+	return records, err
+}
+
+// StreamBatches sends the file records in batches of batchSize, the channel is closed when it returns. A bounded
+// channel keeps at most its capacity plus one batches in memory while the consumers catch up. Records that can't be
+// parsed are sent with their error so the consumer decides whether to reject them or fail
+func (s *source) StreamBatches(ctx context.Context, src io.Reader, mapping *Mapping, batchSize int,
+	batches chan<- Batch) error {
+	defer close(batches)
+
+	if batchSize < 1 {
+		return errors.New("batch size must be greater than zero")
+	}
+
+	batch := make(Batch, 0, batchSize)
+	send := func() error {
+		select {
+		case batches <- batch:
+			batch = make(Batch, 0, batchSize)
+			return nil
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+
+	err := s.read(src, mapping, func(record Record) error {
+		batch = append(batch, record)
+		if len(batch) < batchSize {
+			return nil
+		}
+
+		return send()
+	})
+	if err != nil {
+		return err
+	}
+
+	if len(batch) > 0 {
+		return send()
+	}
+
+	return nil
+}
@@ -0,0 +1,71 @@
+package sse
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+const (
+	ContentType          = "text/event-stream"
+	StreamingUnsupported = "the response writer can't stream"
+)
+
+// Writer writes Server-Sent Events and flushes every one of them, so the client gets it right away
+type Writer struct {
+	writer  http.ResponseWriter
+	flusher http.Flusher
+}
+
+// NewWriter sends the headers of an event stream, proxies are told not to buffer it
+func NewWriter(writer http.ResponseWriter) (*Writer, error) {
+	flusher, ok := writer.(http.Flusher)
+	if !ok {
+		return nil, errors.New(StreamingUnsupported)
+	}
+
+	header := writer.Header()
+	header.Set("Content-Type", ContentType)
+	header.Set("Cache-Control", "no-cache")
+	header.Set("Connection", "keep-alive")
+	header.Set("X-Accel-Buffering", "no")
+	writer.WriteHeader(http.StatusOK)
+	flusher.Flush()
+
+	return &Writer{writer: writer, flusher: flusher}, nil
+}
+
+// Event writes an event with its id, the client sends the last one back as Last-Event-ID when it reconnects. Every
+// line of data is sent as its own data field
+func (w *Writer) Event(id, event string, data []byte) error {
+	var builder strings.Builder
+	if id != "" {
+		fmt.Fprintf(&builder, "id: %s\n", id)
+	}
+
+	if event != "" {
+		fmt.Fprintf(&builder, "event: %s\n", event)
+	}
+
+	for _, line := range strings.Split(string(data), "\n") {
+		fmt.Fprintf(&builder, "data: %s\n", line)
+	}
+	builder.WriteString("\n")
+
+	return w.write(builder.String())
+}
+
+// Comment writes a line the client ignores, it keeps idle connections from being closed by proxies
+func (w *Writer) Comment(text string) error {
+	return w.write(fmt.Sprintf(": %s\n\n", text))
+}
+
+func (w *Writer) write(value string) error {
+	if _, err := w.writer.Write([]byte(value)); err != nil {
+		return err
+	}
+
+	w.flusher.Flush()
+	return nil
+}
@@ -0,0 +1,37 @@
+package sse_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/sebastianreh/user-balance-api/pkg/sse"
+	"github.com/stretchr/testify/assert"
+)
+
+type unflushableWriter struct {
+	http.ResponseWriter
+}
+
+func Test_Writer(t *testing.T) {
+	t.Run("When the events are written with their id and every data line", func(t *testing.T) {
+		recorder := httptest.NewRecorder()
+		writer, err := sse.NewWriter(recorder)
+		assert.Nil(t, err)
+
+		assert.Nil(t, writer.Event("7", "balance", []byte("{\"balance\":10}")))
+		assert.Nil(t, writer.Event("", "", []byte("first\nsecond")))
+		assert.Nil(t, writer.Comment("heartbeat"))
+
+		assert.Equal(t, sse.ContentType, recorder.Header().Get("Content-Type"))
+		assert.Equal(t, "no-cache", recorder.Header().Get("Cache-Control"))
+		assert.True(t, recorder.Flushed)
+		assert.Equal(t, "id: 7\nevent: balance\ndata: {\"balance\":10}\n\ndata: first\ndata: second\n\n: heartbeat\n\n",
+			recorder.Body.String())
+	})
+
+	t.Run("When the response writer can't flush", func(t *testing.T) {
+		_, err := sse.NewWriter(unflushableWriter{httptest.NewRecorder()})
+		assert.EqualError(t, err, sse.StreamingUnsupported)
+	})
+}
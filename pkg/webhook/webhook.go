@@ -0,0 +1,88 @@
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+const (
+	EventHeader     = "X-Webhook-Event"
+	DeliveryHeader  = "X-Webhook-Delivery"
+	TimestampHeader = "X-Webhook-Timestamp"
+	// SignatureHeader is sha256=<hex HMAC-SHA256 of the timestamp, a dot and the body, keyed with the secret>
+	SignatureHeader = "X-Webhook-Signature"
+
+	signaturePrefix = "sha256="
+	// responseExcerptSize is how much of a refused response is kept in the error
+	responseExcerptSize = 512
+)
+
+// Request is a signed POST of an event, ID identifies the event so the receiver can drop the repeated ones
+type Request struct {
+	ID    string
+	Event string
+	Body  []byte
+}
+
+type Sender interface {
+	Send(ctx context.Context, url, secret string, request Request) error
+}
+
+type httpSender struct {
+	client *http.Client
+}
+
+func NewSender(client *http.Client) Sender {
+	return &httpSender{client: client}
+}
+
+// Sign returns the signature header of the body sent at timestamp, the timestamp is signed too so a captured request
+// can't be replayed later with another one
+func Sign(secret string, timestamp int64, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(strconv.FormatInt(timestamp, 10)))
+	mac.Write([]byte("."))
+	mac.Write(body)
+
+	return signaturePrefix + hex.EncodeToString(mac.Sum(nil))
+}
+
+// Verify tells if the signature is the one of the body sent at timestamp, comparing them in constant time
+func Verify(secret string, timestamp int64, body []byte, signature string) bool {
+	return hmac.Equal([]byte(Sign(secret, timestamp, body)), []byte(signature))
+}
+
+func (s *httpSender) Send(ctx context.Context, url, secret string, request Request) error {
+	httpRequest, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(request.Body))
+	if err != nil {
+		return err
+	}
+
+	timestamp := time.Now().Unix()
+	httpRequest.Header.Set("Content-Type", "application/json")
+	httpRequest.Header.Set(EventHeader, request.Event)
+	httpRequest.Header.Set(DeliveryHeader, request.ID)
+	httpRequest.Header.Set(TimestampHeader, strconv.FormatInt(timestamp, 10))
+	httpRequest.Header.Set(SignatureHeader, Sign(secret, timestamp, request.Body))
+
+	response, err := s.client.Do(httpRequest)
+	if err != nil {
+		return err
+	}
+	defer response.Body.Close()
+
+	if response.StatusCode < http.StatusOK || response.StatusCode >= http.StatusMultipleChoices {
+		excerpt, _ := io.ReadAll(io.LimitReader(response.Body, responseExcerptSize))
+		return fmt.Errorf("the webhook answered %d: %s", response.StatusCode, excerpt)
+	}
+
+	return nil
+}
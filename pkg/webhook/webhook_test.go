@@ -0,0 +1,68 @@
+package webhook
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_Sign(t *testing.T) {
+	body := []byte(`{"id":"evt_1"}`)
+
+	t.Run("When the signature is the HMAC of the timestamp and the body", func(t *testing.T) {
+		signature := Sign("whsec_1", 1726221600, body)
+
+		assert.Equal(t, "sha256=fa16dd3568921c939b53a033a4ccec4bcb8b95cb3eefa5fc073b05c7223b7dcf", signature)
+		assert.True(t, Verify("whsec_1", 1726221600, body, signature))
+	})
+
+	t.Run("When the timestamp, the body or the secret change", func(t *testing.T) {
+		signature := Sign("whsec_1", 1726221600, body)
+
+		assert.False(t, Verify("whsec_1", 1726221601, body, signature))
+		assert.False(t, Verify("whsec_1", 1726221600, []byte(`{"id":"evt_2"}`), signature))
+		assert.False(t, Verify("whsec_2", 1726221600, body, signature))
+	})
+}
+
+func Test_Sender(t *testing.T) {
+	ctx := context.TODO()
+	request := Request{ID: "evt_1", Event: "transaction.created", Body: []byte(`{"id":"evt_1"}`)}
+
+	t.Run("When the request is signed and the receiver accepts it", func(t *testing.T) {
+		var received *http.Request
+		var receivedBody []byte
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			received = r
+			receivedBody, _ = io.ReadAll(r.Body)
+			w.WriteHeader(http.StatusNoContent)
+		}))
+		defer server.Close()
+
+		err := NewSender(server.Client()).Send(ctx, server.URL, "whsec_1", request)
+
+		assert.Nil(t, err)
+		assert.Equal(t, "transaction.created", received.Header.Get(EventHeader))
+		assert.Equal(t, "evt_1", received.Header.Get(DeliveryHeader))
+		timestamp, err := strconv.ParseInt(received.Header.Get(TimestampHeader), 10, 64)
+		assert.Nil(t, err)
+		assert.True(t, Verify("whsec_1", timestamp, receivedBody, received.Header.Get(SignatureHeader)))
+	})
+
+	t.Run("When the receiver refuses the request", func(t *testing.T) {
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+			w.WriteHeader(http.StatusGone)
+			_, _ = w.Write([]byte("unknown hook"))
+		}))
+		defer server.Close()
+
+		err := NewSender(server.Client()).Send(ctx, server.URL, "whsec_1", request)
+
+		assert.EqualError(t, err, "the webhook answered 410: unknown hook")
+	})
+}
@@ -2,6 +2,7 @@ import csv
 import os
 import random
 import datetime
+import uuid
 import requests
 import faker
 
@@ -30,7 +31,8 @@ def create_user():
     fake = faker.Faker()
     first_name = fake.first_name()
     last_name = fake.last_name()
-    email = f"{first_name.lower()}.{last_name.lower()}@example.com"
+    # Emails are unique per active user, the suffix avoids collisions between generated names
+    email = f"{first_name.lower()}.{last_name.lower()}.{uuid.uuid4().hex[:8]}@example.com"
 
     user_data = {
         "first_name": first_name,
@@ -0,0 +1,117 @@
+package filesystem_test
+
+import (
+	"context"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/sebastianreh/user-balance-api/internal/domain/upload"
+	"github.com/sebastianreh/user-balance-api/internal/infrastructure/filesystem"
+	"github.com/sebastianreh/user-balance-api/pkg/logger"
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_UploadRepository(t *testing.T) {
+	ctx := context.TODO()
+	content := "id,user_id,amount,datetime\n1,1,100,2024-09-13T10:00:00Z\n"
+	repo := filesystem.NewUploadRepository(logger.NewLogger(), t.TempDir())
+
+	created, err := repo.Create(ctx, upload.Upload{FileName: "migration.csv", Size: int64(len(content))})
+	assert.Nil(t, err)
+
+	t.Run("When an upload is created empty", func(t *testing.T) {
+		assert.Len(t, created.ID, 32)
+		assert.Equal(t, upload.StatusReceiving, created.Status)
+		assert.Equal(t, int64(0), created.ReceivedBytes)
+	})
+
+	t.Run("When a chunk starts after the received bytes", func(t *testing.T) {
+		_, err := repo.Append(ctx, created.ID, 10, strings.NewReader(content[10:]))
+
+		assert.EqualError(t, err, upload.OffsetMismatchError+", 0 bytes were received")
+	})
+
+	t.Run("When an incomplete upload is claimed", func(t *testing.T) {
+		_, err := repo.Claim(ctx, created.ID)
+
+		assert.EqualError(t, err, upload.IncompleteError+", 0 of 56 bytes were received")
+	})
+
+	t.Run("When the chunks are appended in order", func(t *testing.T) {
+		appended, err := repo.Append(ctx, created.ID, 0, strings.NewReader(content[:20]))
+		assert.Nil(t, err)
+		assert.Equal(t, int64(20), appended.ReceivedBytes)
+
+		appended, err = repo.Append(ctx, created.ID, 20, strings.NewReader(content[20:]+"extra"))
+		assert.Nil(t, err)
+		assert.Equal(t, upload.StatusComplete, appended.Status)
+
+		stored, err := os.ReadFile(appended.Path)
+		assert.Nil(t, err)
+		assert.Equal(t, content, string(stored))
+	})
+
+	t.Run("When a claimed upload can't be written nor claimed again", func(t *testing.T) {
+		claimed, err := repo.Claim(ctx, created.ID)
+		assert.Nil(t, err)
+		assert.Equal(t, "migration.csv", claimed.FileName)
+
+		_, err = repo.Claim(ctx, created.ID)
+		assert.EqualError(t, err, upload.BusyError)
+		assert.EqualError(t, repo.Delete(ctx, created.ID), upload.BusyError)
+
+		assert.Nil(t, repo.Release(ctx, created.ID))
+	})
+
+	t.Run("When a finished upload keeps its job after the migration removes the file", func(t *testing.T) {
+		claimed, err := repo.Claim(ctx, created.ID)
+		assert.Nil(t, err)
+		assert.Nil(t, repo.Finish(ctx, created.ID, "7"))
+		assert.Nil(t, os.Remove(claimed.Path))
+
+		found, err := repo.FindByID(ctx, created.ID)
+		assert.Nil(t, err)
+		assert.Equal(t, upload.StatusFinalized, found.Status)
+		assert.Equal(t, "7", found.JobID)
+		assert.Equal(t, found.Size, found.ReceivedBytes)
+
+		_, err = repo.Append(ctx, created.ID, found.Size, strings.NewReader("more"))
+		assert.EqualError(t, err, upload.FinalizedError)
+	})
+
+	t.Run("When an upload is deleted", func(t *testing.T) {
+		assert.Nil(t, repo.Delete(ctx, created.ID))
+
+		_, err := repo.FindByID(ctx, created.ID)
+		assert.EqualError(t, err, upload.NotFoundError)
+	})
+
+	t.Run("When the upload id would leave the directory", func(t *testing.T) {
+		_, err := repo.FindByID(ctx, "../uploads")
+
+		assert.EqualError(t, err, upload.NotFoundError)
+	})
+
+	t.Run("When only the expired uploads are deleted", func(t *testing.T) {
+		expired, err := repo.Create(ctx, upload.Upload{FileName: "old.csv", Size: 10})
+		assert.Nil(t, err)
+		recent, err := repo.Create(ctx, upload.Upload{FileName: "new.csv", Size: 10})
+		assert.Nil(t, err)
+
+		old := time.Now().Add(-2 * time.Hour)
+		for _, path := range []string{expired.Path, strings.TrimSuffix(expired.Path, ".part") + ".json"} {
+			assert.Nil(t, os.Chtimes(path, old, old))
+		}
+
+		deleted, err := repo.DeleteExpired(ctx, time.Now().Add(-time.Hour))
+		assert.Nil(t, err)
+		assert.Equal(t, 1, deleted)
+
+		_, err = repo.FindByID(ctx, expired.ID)
+		assert.EqualError(t, err, upload.NotFoundError)
+		_, err = repo.FindByID(ctx, recent.ID)
+		assert.Nil(t, err)
+	})
+}
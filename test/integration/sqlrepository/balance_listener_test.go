@@ -0,0 +1,92 @@
+package sqlrepository_test
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/sebastianreh/user-balance-api/internal/domain/transaction"
+	"github.com/sebastianreh/user-balance-api/internal/domain/user"
+	"github.com/sebastianreh/user-balance-api/internal/infrastructure/postgresql"
+	"github.com/sebastianreh/user-balance-api/pkg/logger"
+	"github.com/sebastianreh/user-balance-api/pkg/pubsub"
+	"github.com/sebastianreh/user-balance-api/test/integration/sqlrepository"
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_BalanceListener(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.TODO())
+	defer cancel()
+	testDb := sqlrepository.SetupTestDB(t)
+	testDb.RunMigrations(t)
+	log := logger.NewLogger()
+	defer testDb.TeardownTestDB(t)
+
+	broker := pubsub.NewBroker(0, 0)
+	assert.Nil(t, postgresql.StartBalanceListener(ctx, testDb.Config, log, broker))
+
+	transactionRepo := postgresql.NewSQLTransactionRepository(log, testDb.DB)
+	userID := testDb.CreateUser(t, user.User{FirstName: "user", LastName: "lastname", Email: "user@email.com"})
+	otherID := testDb.CreateUser(t, user.User{FirstName: "other", LastName: "lastname", Email: "other@email.com"})
+	now := time.Now()
+	subscription, _ := broker.Subscribe(userID)
+	other, _ := broker.Subscribe(otherID)
+
+	// The listener connects on its own, a first change can be sent before it listens
+	waitSignal := func(subscription *pubsub.Subscription, change func()) bool {
+		for attempt := 0; attempt < 20; attempt++ {
+			change()
+			select {
+			case <-subscription.Signals():
+				return true
+			case <-time.After(250 * time.Millisecond):
+			}
+		}
+		return false
+	}
+
+	t.Run("When a saved transaction signals the subscribers of its user", func(t *testing.T) {
+		attempt := 0
+		signaled := waitSignal(subscription, func() {
+			attempt++
+			err := transactionRepo.Save(ctx, transaction.Transaction{ID: fmt.Sprintf("listener-%d", attempt),
+				UserID: userID, Amount: 10, DateTime: &now})
+			assert.Nil(t, err)
+		})
+
+		assert.True(t, signaled)
+		assert.Empty(t, other.Signals())
+	})
+
+	t.Run("When a batch signals every user once", func(t *testing.T) {
+		err := transactionRepo.SaveBatch(ctx, []transaction.Transaction{
+			{ID: "batch-1", UserID: userID, Amount: 10, DateTime: &now},
+			{ID: "batch-2", UserID: otherID, Amount: -5, DateTime: &now},
+			{ID: "batch-3", UserID: otherID, Amount: -5, DateTime: &now},
+		})
+		assert.Nil(t, err)
+
+		for _, signals := range []<-chan struct{}{subscription.Signals(), other.Signals()} {
+			select {
+			case <-signals:
+			case <-time.After(5 * time.Second):
+				t.Fatal("the batch didn't signal its users")
+			}
+		}
+	})
+
+	t.Run("When a transaction moved to another user signals both users", func(t *testing.T) {
+		err := transactionRepo.Update(ctx, transaction.Transaction{ID: "batch-1", UserID: otherID, Amount: 10,
+			DateTime: &now})
+		assert.Nil(t, err)
+
+		for _, signals := range []<-chan struct{}{subscription.Signals(), other.Signals()} {
+			select {
+			case <-signals:
+			case <-time.After(5 * time.Second):
+				t.Fatal("the update didn't signal both users")
+			}
+		}
+	})
+}
@@ -0,0 +1,198 @@
+package sqlrepository_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/sebastianreh/user-balance-api/internal/domain/migration"
+	"github.com/sebastianreh/user-balance-api/internal/domain/outbox"
+	"github.com/sebastianreh/user-balance-api/internal/domain/report"
+	"github.com/sebastianreh/user-balance-api/internal/domain/transaction"
+	"github.com/sebastianreh/user-balance-api/internal/domain/user"
+	"github.com/sebastianreh/user-balance-api/internal/infrastructure/postgresql"
+	"github.com/sebastianreh/user-balance-api/pkg/logger"
+	"github.com/sebastianreh/user-balance-api/test/integration/sqlrepository"
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_MigrationHistory(t *testing.T) {
+	ctx := context.TODO()
+	testDb := sqlrepository.SetupTestDB(t)
+	testDb.RunMigrations(t)
+	log := logger.NewLogger()
+	defer testDb.TeardownTestDB(t)
+
+	jobRepo := postgresql.NewSQLMigrationJobRepository(log, testDb.DB)
+	transactionRepo := postgresql.NewSQLTransactionRepository(log, testDb.DB)
+	userID := testDb.CreateUser(t, user.User{
+		FirstName: "user",
+		LastName:  "lastname",
+		Email:     "user@email.com",
+	})
+	now := time.Now()
+
+	saveMigrationWithStatus := func(t *testing.T, status string, ids ...string) string {
+		jobID, err := jobRepo.Save(ctx, migration.Job{Status: migration.StatusRunning, FileName: "history.csv",
+			Checksum: "e3b0c442", UploadedBy: "ops@example.com"})
+		assert.Nil(t, err)
+
+		transactions := make([]transaction.Transaction, 0, len(ids))
+		for _, id := range ids {
+			transactions = append(transactions, transaction.Transaction{ID: id, UserID: userID, Amount: 10.00,
+				DateTime: &now, MigrationID: jobID})
+		}
+		assert.Nil(t, transactionRepo.SaveBatch(ctx, transactions))
+
+		job, err := jobRepo.FindByID(ctx, jobID)
+		assert.Nil(t, err)
+		job.Status = status
+		assert.Nil(t, jobRepo.Update(ctx, job))
+
+		return jobID
+	}
+
+	saveMigration := func(t *testing.T, ids ...string) string {
+		return saveMigrationWithStatus(t, migration.StatusCompleted, ids...)
+	}
+
+	t.Run("When the migrations are listed from the newest", func(t *testing.T) {
+		defer testDb.CleanTransactions(t)
+		firstID := saveMigration(t, "a1")
+		secondID := saveMigration(t, "b1")
+
+		jobs, err := jobRepo.List(ctx, migration.ListOptions{Limit: 10})
+		assert.Nil(t, err)
+		assert.GreaterOrEqual(t, len(jobs), 2)
+		assert.Equal(t, secondID, jobs[0].ID)
+		assert.Equal(t, "e3b0c442", jobs[0].Checksum)
+		assert.Equal(t, "ops@example.com", jobs[0].UploadedBy)
+
+		jobs, err = jobRepo.List(ctx, migration.ListOptions{Limit: 10, After: &migration.Cursor{ID: secondID}})
+		assert.Nil(t, err)
+		assert.Equal(t, firstID, jobs[0].ID)
+	})
+
+	t.Run("When the transactions of a migration are listed by id", func(t *testing.T) {
+		defer testDb.CleanTransactions(t)
+		jobID := saveMigration(t, "c2", "c1", "c3")
+
+		items, err := transactionRepo.ListByMigration(ctx, jobID, "", 2)
+		assert.Nil(t, err)
+		assert.Len(t, items, 2)
+		assert.Equal(t, "c1", items[0].ID)
+		assert.Equal(t, jobID, items[0].MigrationID)
+
+		items, err = transactionRepo.ListByMigration(ctx, jobID, "c2", 2)
+		assert.Nil(t, err)
+		assert.Len(t, items, 1)
+		assert.Equal(t, "c3", items[0].ID)
+	})
+
+	t.Run("When a migration is rolled back its transactions are deleted", func(t *testing.T) {
+		defer testDb.CleanTransactions(t)
+		jobID := saveMigration(t, "d1", "d2")
+
+		deleted, err := jobRepo.Rollback(ctx, jobID)
+		assert.Nil(t, err)
+		assert.Equal(t, int64(2), deleted)
+
+		job, err := jobRepo.FindByID(ctx, jobID)
+		assert.Nil(t, err)
+		assert.Equal(t, migration.StatusRolledBack, job.Status)
+		assert.NotNil(t, job.RolledBackAt)
+
+		items, err := transactionRepo.ListByMigration(ctx, jobID, "", 10)
+		assert.Nil(t, err)
+		assert.Empty(t, items)
+
+		_, err = jobRepo.Rollback(ctx, jobID)
+		assert.EqualError(t, err, migration.RollbackStatusError)
+	})
+
+	t.Run("When a failed migration is rolled back its deliveries are saved with it", func(t *testing.T) {
+		defer testDb.CleanTransactions(t)
+		jobID := saveMigrationWithStatus(t, migration.StatusFailed, "f1")
+		message, err := outbox.NewMessage(outbox.KindWebhook, "migration.rolled_back to https://example.com/hooks",
+			map[string]string{"job_id": jobID})
+		assert.Nil(t, err)
+		messages := testDb.CountRows(t, "outbox")
+
+		deleted, err := jobRepo.Rollback(ctx, jobID, message)
+		assert.Nil(t, err)
+		assert.Equal(t, int64(1), deleted)
+		assert.Equal(t, messages+1, testDb.CountRows(t, "outbox"))
+
+		job, err := jobRepo.FindByID(ctx, jobID)
+		assert.Nil(t, err)
+		assert.Equal(t, migration.StatusRolledBack, job.Status)
+
+		userIDs, err := transactionRepo.FindMigrationUserIDs(ctx, jobID)
+		assert.Nil(t, err)
+		assert.Equal(t, []string{userID}, userIDs)
+	})
+
+	t.Run("When a running migration is rolled back", func(t *testing.T) {
+		defer testDb.CleanTransactions(t)
+		jobID := saveMigrationWithStatus(t, migration.StatusRunning, "g1")
+
+		_, err := jobRepo.Rollback(ctx, jobID)
+		assert.EqualError(t, err, migration.RollbackStatusError)
+	})
+
+	t.Run("When a transaction was edited the rollback changes nothing", func(t *testing.T) {
+		defer testDb.CleanTransactions(t)
+		jobID := saveMigration(t, "e1", "e2")
+
+		edited, err := transactionRepo.FindByID(ctx, "e1")
+		assert.Nil(t, err)
+		edited.Amount = 20.00
+		assert.Nil(t, transactionRepo.Update(ctx, edited))
+
+		_, err = jobRepo.Rollback(ctx, jobID)
+		assert.EqualError(t, err, migration.RollbackEditedError)
+
+		job, err := jobRepo.FindByID(ctx, jobID)
+		assert.Nil(t, err)
+		assert.Equal(t, migration.StatusCompleted, job.Status)
+
+		items, err := transactionRepo.ListByMigration(ctx, jobID, "", 10)
+		assert.Nil(t, err)
+		assert.Len(t, items, 2)
+	})
+
+	t.Run("When the migration does not exist", func(t *testing.T) {
+		_, err := jobRepo.Rollback(ctx, "999")
+		assert.EqualError(t, err, migration.NotFoundError)
+	})
+
+	t.Run("When an interrupted migration is read back with what it saved", func(t *testing.T) {
+		destinations := []string{"ops@example.com"}
+		jobID, err := jobRepo.Save(ctx, migration.Job{Status: migration.StatusRunning, FileName: "stale.csv",
+			ReportDestinations: destinations})
+		assert.Nil(t, err)
+
+		assert.Nil(t, transactionRepo.SaveBatch(ctx, []transaction.Transaction{
+			{ID: "h1", UserID: userID, Amount: 10.00, DateTime: &now, MigrationID: jobID},
+			{ID: "h2", UserID: userID, Amount: 20.00, DateTime: &now, MigrationID: jobID},
+		}))
+		assert.Nil(t, jobRepo.SaveRejects(ctx, jobID, []migration.Reject{{Line: 4, Raw: "h3,999,10,2024-09-13",
+			Reason: migration.RejectReasonUnknownUser, Detail: "user not found"}}))
+
+		stale, err := jobRepo.FindStale(ctx, time.Now().Add(time.Minute))
+		assert.Nil(t, err)
+		assert.NotEmpty(t, stale)
+		assert.Equal(t, jobID, stale[len(stale)-1].ID)
+		assert.Equal(t, destinations, stale[len(stale)-1].ReportDestinations)
+
+		summary, err := jobRepo.Saved(ctx, jobID)
+		assert.Nil(t, err)
+		assert.Equal(t, report.MigrationSummary{JobID: jobID, TotalRecords: 2, UsersUpdated: 1, RejectedRecords: 1,
+			RejectsByReason: map[string]int{migration.RejectReasonUnknownUser: 1}}, summary)
+
+		// A job finished by another instance is not finished again
+		finished := migration.Job{ID: jobID, Status: migration.StatusCompleted, Summary: &summary}
+		assert.Nil(t, jobRepo.Finish(ctx, finished))
+		assert.EqualError(t, jobRepo.Finish(ctx, finished), migration.NotFoundError)
+	})
+}
@@ -0,0 +1,67 @@
+package sqlrepository_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/sebastianreh/user-balance-api/internal/domain/migration"
+	"github.com/sebastianreh/user-balance-api/internal/domain/transaction"
+	"github.com/sebastianreh/user-balance-api/internal/domain/user"
+	"github.com/sebastianreh/user-balance-api/internal/infrastructure/postgresql"
+	"github.com/sebastianreh/user-balance-api/pkg/logger"
+	"github.com/sebastianreh/user-balance-api/test/integration/sqlrepository"
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_MigrationReport(t *testing.T) {
+	ctx := context.TODO()
+	testDb := sqlrepository.SetupTestDB(t)
+	testDb.RunMigrations(t)
+	log := logger.NewLogger()
+	defer testDb.TeardownTestDB(t)
+	defer testDb.CleanTransactions(t)
+
+	jobRepo := postgresql.NewSQLMigrationJobRepository(log, testDb.DB)
+	transactionRepo := postgresql.NewSQLTransactionRepository(log, testDb.DB)
+	firstUserID := testDb.CreateUser(t, user.User{FirstName: "first", LastName: "user", Email: "first@email.com"})
+	secondUserID := testDb.CreateUser(t, user.User{FirstName: "second", LastName: "user", Email: "second@email.com"})
+	now := time.Now()
+
+	assert.Nil(t, transactionRepo.Save(ctx, transaction.Transaction{ID: "api1", UserID: firstUserID, Amount: 50,
+		DateTime: &now}))
+	jobID, err := jobRepo.Save(ctx, migration.Job{Status: migration.StatusRunning, FileName: "report.csv"})
+	assert.Nil(t, err)
+	assert.Nil(t, transactionRepo.SaveBatch(ctx, []transaction.Transaction{
+		{ID: "r1", UserID: firstUserID, Amount: 300, DateTime: &now, MigrationID: jobID},
+		{ID: "r2", UserID: firstUserID, Amount: -100.5, DateTime: &now, MigrationID: jobID},
+		{ID: "r3", UserID: secondUserID, Amount: 1000, DateTime: &now, MigrationID: jobID},
+		{ID: "r4", UserID: secondUserID, Amount: 20, DateTime: &now, MigrationID: jobID},
+	}))
+	assert.Nil(t, transactionRepo.Delete(ctx, "r4"))
+
+	t.Run("When the amounts and largest transactions of a migration are summarized", func(t *testing.T) {
+		totals, err := transactionRepo.SummarizeMigration(ctx, jobID, 2)
+		assert.Nil(t, err)
+		assert.Equal(t, 1300.0, totals.Credited)
+		assert.Equal(t, 100.5, totals.Debited)
+		assert.Len(t, totals.Largest, 2)
+		assert.Equal(t, "r3", totals.Largest[0].ID)
+		assert.Equal(t, "r1", totals.Largest[1].ID)
+	})
+
+	t.Run("When the users of a migration are read with their resulting balance", func(t *testing.T) {
+		var deltas []transaction.UserDelta
+		err := transactionRepo.ForEachMigrationUser(ctx, jobID, func(delta transaction.UserDelta) error {
+			deltas = append(deltas, delta)
+			return nil
+		})
+
+		assert.Nil(t, err)
+		assert.Equal(t, []transaction.UserDelta{
+			{UserID: firstUserID, NetAmount: 199.5, Credits: 1, Debits: 1, Balance: 249.5, TotalDebits: 1,
+				TotalCredits: 2},
+			{UserID: secondUserID, NetAmount: 1000, Credits: 1, Balance: 1000, TotalCredits: 1},
+		}, deltas)
+	})
+}
@@ -0,0 +1,114 @@
+package sqlrepository_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/sebastianreh/user-balance-api/internal/domain/alert"
+	"github.com/sebastianreh/user-balance-api/internal/domain/outbox"
+	"github.com/sebastianreh/user-balance-api/internal/domain/user"
+	"github.com/sebastianreh/user-balance-api/internal/infrastructure/postgresql"
+	"github.com/sebastianreh/user-balance-api/pkg/logger"
+	"github.com/sebastianreh/user-balance-api/test/integration/sqlrepository"
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_AlertRepository(t *testing.T) {
+	ctx := context.TODO()
+	testDb := sqlrepository.SetupTestDB(t)
+	testDb.RunMigrations(t)
+	log := logger.NewLogger()
+	defer testDb.TeardownTestDB(t)
+
+	repository := postgresql.NewSQLAlertRepository(log, testDb.DB)
+	userID := testDb.CreateUser(t, user.User{FirstName: "user", LastName: "lastname", Email: "user@email.com"})
+	balanceRule := alert.Rule{UserID: userID, Type: alert.TypeBalanceBelow, Threshold: 100,
+		Recipients: []string{"ops@example.com"}, Active: true}
+	debitRule := alert.Rule{UserID: userID, Type: alert.TypeDebitAbove, Threshold: 1000,
+		Recipients: []string{"ops@example.com"}, Active: true}
+	message, _ := outbox.NewMessage(outbox.KindEmail, "alert", map[string]string{"subject": "alert"})
+
+	t.Run("When the rules are saved only the active ones are checked", func(t *testing.T) {
+		saved, err := repository.SaveRule(ctx, balanceRule)
+		assert.Nil(t, err)
+		balanceRule.ID = saved.ID
+
+		paused := debitRule
+		paused.Active = false
+		saved, err = repository.SaveRule(ctx, paused)
+		assert.Nil(t, err)
+		debitRule.ID = saved.ID
+
+		rules, err := repository.ListRules(ctx, userID)
+		assert.Nil(t, err)
+		assert.Len(t, rules, 2)
+
+		active, err := repository.FindActiveRules(ctx, []string{userID, "999"})
+		assert.Nil(t, err)
+		assert.Len(t, active, 1)
+		assert.Equal(t, balanceRule.ID, active[0].ID)
+
+		_, err = repository.FindRule(ctx, "999", balanceRule.ID)
+		assert.EqualError(t, err, alert.RuleNotFoundError)
+	})
+
+	t.Run("When a balance rule fires once until it's cleared", func(t *testing.T) {
+		firedAlert := alert.Alert{RuleID: balanceRule.ID, UserID: userID, Type: balanceRule.Type,
+			Threshold: balanceRule.Threshold, Value: 20, Recipients: balanceRule.Recipients}
+
+		fired, err := repository.Fire(ctx, firedAlert, message)
+		assert.Nil(t, err)
+		assert.True(t, fired)
+
+		fired, err = repository.Fire(ctx, firedAlert, message)
+		assert.Nil(t, err)
+		assert.False(t, fired)
+
+		rule, err := repository.FindRule(ctx, userID, balanceRule.ID)
+		assert.Nil(t, err)
+		assert.NotNil(t, rule.TriggeredAt)
+
+		assert.Nil(t, repository.Clear(ctx, []string{balanceRule.ID}))
+		fired, err = repository.Fire(ctx, firedAlert, message)
+		assert.Nil(t, err)
+		assert.True(t, fired)
+		assert.Equal(t, 2, testDb.CountRows(t, "outbox"))
+	})
+
+	t.Run("When a debit rule fires once per transaction", func(t *testing.T) {
+		debitRule.Active = true
+		_, err := repository.UpdateRule(ctx, debitRule)
+		assert.Nil(t, err)
+
+		firedAlert := alert.Alert{RuleID: debitRule.ID, UserID: userID, Type: debitRule.Type,
+			Threshold: debitRule.Threshold, Value: -1500, TransactionID: "10", Recipients: debitRule.Recipients}
+		fired, err := repository.Fire(ctx, firedAlert, message)
+		assert.Nil(t, err)
+		assert.True(t, fired)
+
+		fired, err = repository.Fire(ctx, firedAlert, message)
+		assert.Nil(t, err)
+		assert.False(t, fired)
+
+		firedAlert.TransactionID = "11"
+		fired, err = repository.Fire(ctx, firedAlert, message)
+		assert.Nil(t, err)
+		assert.True(t, fired)
+	})
+
+	t.Run("When the alerts of a deleted rule stay in the paged history", func(t *testing.T) {
+		assert.Nil(t, repository.DeleteRule(ctx, userID, debitRule.ID))
+		assert.EqualError(t, repository.DeleteRule(ctx, userID, debitRule.ID), alert.RuleNotFoundError)
+
+		alerts, err := repository.ListAlerts(ctx, alert.ListOptions{UserID: userID, Limit: 3})
+		assert.Nil(t, err)
+		assert.Len(t, alerts, 3)
+		assert.Equal(t, "11", alerts[0].TransactionID)
+
+		after := alert.Cursor{ID: alerts[2].ID}
+		alerts, err = repository.ListAlerts(ctx, alert.ListOptions{UserID: userID, Limit: 3, After: &after})
+		assert.Nil(t, err)
+		assert.Len(t, alerts, 1)
+		assert.Equal(t, alert.TypeBalanceBelow, alerts[0].Type)
+	})
+}
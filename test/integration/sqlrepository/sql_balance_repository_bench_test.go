@@ -0,0 +1,101 @@
+package sqlrepository_test
+
+import (
+	"context"
+	"strconv"
+	"testing"
+
+	"github.com/sebastianreh/user-balance-api/internal/domain/balance"
+	"github.com/sebastianreh/user-balance-api/internal/infrastructure/postgresql"
+	"github.com/sebastianreh/user-balance-api/pkg/logger"
+	"github.com/sebastianreh/user-balance-api/test/integration/sqlrepository"
+)
+
+const (
+	benchmarkUsers        = 1000
+	benchmarkTransactions = 1000000
+
+	seedBenchmarkUsers = `
+	INSERT INTO users (first_name, last_name, email)
+	SELECT 'user', 'benchmark', 'user' || i || '@benchmark.com' FROM generate_series(1, $1) AS i`
+	// Soft-deletes one transaction out of ten so the partial index has something to skip
+	seedBenchmarkTransactions = `
+	INSERT INTO transactions (id, user_id, amount, date_time, is_deleted)
+	SELECT i::text, (i % $1) + 1, ROUND((random() * 2000 - 1000)::numeric, 2),
+		TIMESTAMPTZ '2023-01-01' + (i % 548) * INTERVAL '1 day', i % 10 = 0
+	FROM generate_series(1, $2) AS i`
+)
+
+func setupBalanceBenchmark(b *testing.B) *sqlrepository.TestSQLRepository {
+	testDb := sqlrepository.SetupTestDB(b)
+	testDb.RunMigrations(b)
+
+	if _, err := testDb.DB.Exec(seedBenchmarkUsers, benchmarkUsers); err != nil {
+		b.Fatalf("Failed to seed users: %v", err)
+	}
+
+	if _, err := testDb.DB.Exec(seedBenchmarkTransactions, benchmarkUsers, benchmarkTransactions); err != nil {
+		b.Fatalf("Failed to seed transactions: %v", err)
+	}
+
+	// Updates the visibility map and statistics so the planner can pick the index only scan
+	if _, err := testDb.DB.Exec("VACUUM ANALYZE transactions"); err != nil {
+		b.Fatalf("Failed to vacuum transactions: %v", err)
+	}
+
+	return testDb
+}
+
+// Run with: go test ./test/integration/sqlrepository -run '^$' -bench BalanceRepository
+func Benchmark_BalanceRepository_FindByUserID(b *testing.B) {
+	ctx := context.TODO()
+	testDb := setupBalanceBenchmark(b)
+	defer testDb.TeardownTestDB(b)
+	log := logger.NewLogger()
+	balanceRepo := postgresql.NewSQLBalanceRepository(log, testDb.DB)
+	transactionRepo := postgresql.NewSQLTransactionRepository(log, testDb.DB)
+	calculator := balance.NewBalanceCalculator()
+
+	b.Run("SQL aggregation", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			userID := strconv.Itoa(i%benchmarkUsers + 1)
+			if _, err := balanceRepo.FindByUserID(ctx, userID, "", ""); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+
+	b.Run("In memory calculator", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			userID := strconv.Itoa(i%benchmarkUsers + 1)
+			transactions, err := transactionRepo.FindByUserIDWithOptions(ctx, userID, "", "")
+			if err != nil {
+				b.Fatal(err)
+			}
+
+			calculator.CalculateBalanceByUser(transactions)
+		}
+	})
+
+	b.Run("SQL aggregation with date range", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			userID := strconv.Itoa(i%benchmarkUsers + 1)
+			if _, err := balanceRepo.FindByUserID(ctx, userID, "2023-03-01T00:00:00Z", "2023-09-01T00:00:00Z"); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+
+	b.Run("In memory calculator with date range", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			userID := strconv.Itoa(i%benchmarkUsers + 1)
+			transactions, err := transactionRepo.FindByUserIDWithOptions(ctx, userID,
+				"2023-03-01T00:00:00Z", "2023-09-01T00:00:00Z")
+			if err != nil {
+				b.Fatal(err)
+			}
+
+			calculator.CalculateBalanceByUser(transactions)
+		}
+	})
+}
@@ -0,0 +1,114 @@
+package sqlrepository_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/sebastianreh/user-balance-api/internal/domain/balance"
+	"github.com/sebastianreh/user-balance-api/internal/domain/transaction"
+	"github.com/sebastianreh/user-balance-api/internal/domain/user"
+	"github.com/sebastianreh/user-balance-api/internal/infrastructure/postgresql"
+	"github.com/sebastianreh/user-balance-api/pkg/logger"
+	"github.com/sebastianreh/user-balance-api/test/integration/sqlrepository"
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_SqlBalanceRepository_FindByUserIDs(t *testing.T) {
+	ctx := context.TODO()
+	testDb := sqlrepository.SetupTestDB(t)
+	testDb.RunMigrations(t)
+	log := logger.NewLogger()
+	repo := postgresql.NewSQLBalanceRepository(log, testDb.DB)
+	transactionRepo := postgresql.NewSQLTransactionRepository(log, testDb.DB)
+	userRepo := postgresql.NewSQLUserRepository(log, testDb.DB)
+	defer testDb.TeardownTestDB(t)
+
+	firstUserID := testDb.CreateUser(t, user.User{FirstName: "first", LastName: "user", Email: "first@email.com"})
+	secondUserID := testDb.CreateUser(t, user.User{FirstName: "second", LastName: "user", Email: "second@email.com"})
+	deletedUserID := testDb.CreateUser(t, user.User{FirstName: "deleted", LastName: "user", Email: "deleted@email.com"})
+	assert.Nil(t, userRepo.Delete(ctx, deletedUserID))
+
+	january := time.Date(2024, 1, 15, 0, 0, 0, 0, time.UTC)
+	june := time.Date(2024, 6, 15, 0, 0, 0, 0, time.UTC)
+	assert.Nil(t, transactionRepo.Save(ctx, transaction.Transaction{ID: "1", UserID: firstUserID, Amount: 100.10, DateTime: &january}))
+	assert.Nil(t, transactionRepo.Save(ctx, transaction.Transaction{ID: "2", UserID: firstUserID, Amount: -40, DateTime: &june}))
+	assert.Nil(t, transactionRepo.Save(ctx, transaction.Transaction{ID: "3", UserID: firstUserID, Amount: 500, DateTime: &june}))
+	assert.Nil(t, transactionRepo.Delete(ctx, "3"))
+
+	t.Run("When FindByUserIDs returns every active user in one query", func(t *testing.T) {
+		balances, err := repo.FindByUserIDs(ctx, []string{firstUserID, secondUserID, deletedUserID, "999", "abc"}, "", "")
+
+		assert.Nil(t, err)
+		assert.Len(t, balances, 2)
+		assert.Equal(t, 60.10, balances[firstUserID].Balance)
+		assert.Equal(t, 1, balances[firstUserID].TotalCredits)
+		assert.Equal(t, 1, balances[firstUserID].TotalDebits)
+		assert.Equal(t, float64(0), balances[secondUserID].Balance)
+	})
+
+	t.Run("When FindByUserIDs filters by date", func(t *testing.T) {
+		balances, err := repo.FindByUserIDs(ctx, []string{firstUserID}, "", "2024-03-01T00:00:00Z")
+
+		assert.Nil(t, err)
+		assert.Equal(t, 100.10, balances[firstUserID].Balance)
+		assert.Equal(t, 0, balances[firstUserID].TotalDebits)
+	})
+
+	t.Run("When FindByUserIDs has no numeric ids", func(t *testing.T) {
+		balances, err := repo.FindByUserIDs(ctx, []string{"abc"}, "", "")
+
+		assert.Nil(t, err)
+		assert.Empty(t, balances)
+	})
+}
+
+func Test_SqlBalanceRepository_FindByUserID(t *testing.T) {
+	ctx := context.TODO()
+	testDb := sqlrepository.SetupTestDB(t)
+	testDb.RunMigrations(t)
+	log := logger.NewLogger()
+	repo := postgresql.NewSQLBalanceRepository(log, testDb.DB)
+	transactionRepo := postgresql.NewSQLTransactionRepository(log, testDb.DB)
+	defer testDb.TeardownTestDB(t)
+
+	userID := testDb.CreateUser(t, user.User{FirstName: "first", LastName: "user", Email: "first@email.com"})
+	emptyUserID := testDb.CreateUser(t, user.User{FirstName: "empty", LastName: "user", Email: "empty@email.com"})
+
+	january := time.Date(2024, 1, 15, 0, 0, 0, 0, time.UTC)
+	june := time.Date(2024, 6, 15, 0, 0, 0, 0, time.UTC)
+	assert.Nil(t, transactionRepo.Save(ctx, transaction.Transaction{ID: "1", UserID: userID, Amount: 100.10, DateTime: &january}))
+	assert.Nil(t, transactionRepo.Save(ctx, transaction.Transaction{ID: "2", UserID: userID, Amount: -40, DateTime: &june}))
+	assert.Nil(t, transactionRepo.Save(ctx, transaction.Transaction{ID: "3", UserID: userID, Amount: 500, DateTime: &june}))
+	assert.Nil(t, transactionRepo.Delete(ctx, "3"))
+
+	t.Run("When FindByUserID aggregates the active transactions", func(t *testing.T) {
+		userBalance, err := repo.FindByUserID(ctx, userID, "", "")
+
+		assert.Nil(t, err)
+		assert.Equal(t, balance.UserBalance{Balance: 60.10, TotalDebits: 1, TotalCredits: 1}, userBalance)
+	})
+
+	t.Run("When FindByUserID filters by date range", func(t *testing.T) {
+		userBalance, err := repo.FindByUserID(ctx, userID, "2024-01-01T00:00:00Z", "2024-03-01T00:00:00Z")
+
+		assert.Nil(t, err)
+		assert.Equal(t, balance.UserBalance{Balance: 100.10, TotalCredits: 1}, userBalance)
+	})
+
+	t.Run("When FindByUserID matches the calculator", func(t *testing.T) {
+		transactions, err := transactionRepo.FindByUserIDWithOptions(ctx, userID, "", "")
+		assert.Nil(t, err)
+
+		userBalance, err := repo.FindByUserID(ctx, userID, "", "")
+		assert.Nil(t, err)
+		assert.Equal(t, balance.NewBalanceCalculator().CalculateBalanceByUser(transactions), userBalance)
+	})
+
+	t.Run("When FindByUserID has no transactions", func(t *testing.T) {
+		userBalance, err := repo.FindByUserID(ctx, emptyUserID, "", "")
+
+		assert.Nil(t, err)
+		assert.Equal(t, balance.UserBalance{}, userBalance)
+	})
+}
@@ -18,14 +18,17 @@ const (
 	testDBName         = "test_db"
 	deleteUsers        = "TRUNCATE TABLE users RESTART IDENTITY CASCADE"
 	deleteTransactions = "TRUNCATE TABLE transactions RESTART IDENTITY CASCADE"
+	resetSchema        = "DROP SCHEMA IF EXISTS public CASCADE; CREATE SCHEMA public;"
 )
 
 type TestSQLRepository struct {
-	DB  *sql.DB
-	log logger.Logger
+	DB *sql.DB
+	// Config points to the test database, for the code that opens connections of its own
+	Config config.Config
+	log    logger.Logger
 }
 
-func SetupTestDB(t *testing.T) *TestSQLRepository {
+func SetupTestDB(t testing.TB) *TestSQLRepository {
 	log := logger.NewLogger()
 	cfg := config.NewConfig()
 
@@ -48,35 +51,41 @@ func SetupTestDB(t *testing.T) *TestSQLRepository {
 		t.Fatalf("Database initialization error, shutting down server: %v", err)
 	}
 
+	// Every test starts from an empty schema so unique constraints don't collide with leftovers
+	if _, err = db.Exec(resetSchema); err != nil {
+		t.Fatalf("Failed to reset test database schema: %v", err)
+	}
+
 	return &TestSQLRepository{
-		DB:  db,
-		log: log,
+		DB:     db,
+		Config: cfg,
+		log:    log,
 	}
 }
 
-func (r *TestSQLRepository) RunMigrations(t *testing.T) {
+func (r *TestSQLRepository) RunMigrations(t testing.TB) {
 	migrations := postgresql.NewSQLMigrations(r.log, r.DB)
 	if err := migrations.RunMigrations(); err != nil {
 		t.Fatalf("Failed to run migrations: %v", err)
 	}
 }
 
-func (r *TestSQLRepository) CleanUsers(t *testing.T) {
+func (r *TestSQLRepository) CleanUsers(t testing.TB) {
 	r.cleanDatabase(t, deleteUsers)
 }
 
-func (r *TestSQLRepository) CleanTransactions(t *testing.T) {
+func (r *TestSQLRepository) CleanTransactions(t testing.TB) {
 	r.cleanDatabase(t, deleteTransactions)
 }
 
-func (r *TestSQLRepository) cleanDatabase(t *testing.T, query string) {
+func (r *TestSQLRepository) cleanDatabase(t testing.TB, query string) {
 	_, err := r.DB.Exec(query)
 	if err != nil {
 		t.Fatalf("Failed to delete transactions: %v", err)
 	}
 }
 
-func (r *TestSQLRepository) CreateUser(t *testing.T, input user.User) string {
+func (r *TestSQLRepository) CreateUser(t testing.TB, input user.User) string {
 	repo := postgresql.NewSQLUserRepository(r.log, r.DB)
 	userID, err := repo.Save(context.TODO(), input)
 	if err != nil {
@@ -86,7 +95,16 @@ func (r *TestSQLRepository) CreateUser(t *testing.T, input user.User) string {
 	return userID
 }
 
-func (r *TestSQLRepository) TeardownTestDB(t *testing.T) {
+func (r *TestSQLRepository) CountRows(t testing.TB, table string) int {
+	var rows int
+	if err := r.DB.QueryRow(fmt.Sprintf("SELECT COUNT(*) FROM %s", table)).Scan(&rows); err != nil {
+		t.Fatalf("Failed to count %s rows: %v", table, err)
+	}
+
+	return rows
+}
+
+func (r *TestSQLRepository) TeardownTestDB(t testing.TB) {
 	if r.DB != nil {
 		err := r.DB.Close()
 		if err != nil {
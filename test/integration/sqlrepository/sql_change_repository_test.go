@@ -0,0 +1,162 @@
+package sqlrepository_test
+
+import (
+	"context"
+	"encoding/json"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/sebastianreh/user-balance-api/internal/domain/change"
+	"github.com/sebastianreh/user-balance-api/internal/domain/transaction"
+	"github.com/sebastianreh/user-balance-api/internal/domain/user"
+	"github.com/sebastianreh/user-balance-api/internal/infrastructure/postgresql"
+	"github.com/sebastianreh/user-balance-api/pkg/logger"
+	"github.com/sebastianreh/user-balance-api/test/integration/sqlrepository"
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_SqlChangeRepository_List(t *testing.T) {
+	ctx := context.TODO()
+	testDB := sqlrepository.SetupTestDB(t)
+	testDB.RunMigrations(t)
+	defer testDB.TeardownTestDB(t)
+	log := logger.NewLogger()
+	repo := postgresql.NewSQLChangeRepository(log, testDB.DB)
+	userRepo := postgresql.NewSQLUserRepository(log, testDB.DB)
+	transactionRepo := postgresql.NewSQLTransactionRepository(log, testDB.DB)
+	now := time.Now()
+
+	userID := testDB.CreateUser(t, user.User{FirstName: "user", LastName: "lastname", Email: "user@email.com"})
+	since := lastCursor(t, repo)
+
+	t.Run("When every write of a user and a transaction is recorded in commit order", func(t *testing.T) {
+		tx := transaction.Transaction{ID: "change-1", UserID: userID, Amount: 100, DateTime: &now}
+		assert.Nil(t, userRepo.Update(ctx, user.User{ID: userID, FirstName: "renamed"}))
+		assert.Nil(t, transactionRepo.Save(ctx, tx))
+		tx.Amount = 150
+		assert.Nil(t, transactionRepo.Update(ctx, tx))
+		assert.Nil(t, transactionRepo.Delete(ctx, tx.ID))
+		assert.Nil(t, transactionRepo.Save(ctx, tx))
+
+		changes, err := repo.List(ctx, change.ListOptions{Since: since, Limit: 10})
+		assert.Nil(t, err)
+		assert.Len(t, changes, 5)
+		operations := make([]string, 0, len(changes))
+		for i, changeEntity := range changes {
+			operations = append(operations, changeEntity.Operation)
+			assert.Equal(t, strconv.FormatInt(since+int64(i)+1, 10), changeEntity.Cursor)
+		}
+		assert.Equal(t, []string{change.OperationUpdate, change.OperationInsert, change.OperationUpdate,
+			change.OperationSoftDelete, change.OperationRestore}, operations)
+		assert.Equal(t, change.EntityUser, changes[0].Entity)
+		assert.Equal(t, userID, changes[0].EntityID)
+
+		var data map[string]interface{}
+		assert.Nil(t, json.Unmarshal(changes[2].Data, &data))
+		assert.Equal(t, 150.0, data["amount"])
+		since = lastCursor(t, repo)
+	})
+
+	t.Run("When deleting a transaction already deleted records nothing", func(t *testing.T) {
+		assert.Nil(t, transactionRepo.Delete(ctx, "change-1"))
+		assert.Nil(t, transactionRepo.Delete(ctx, "change-1"))
+
+		changes, err := repo.List(ctx, change.ListOptions{Since: since, Limit: 10})
+		assert.Nil(t, err)
+		assert.Len(t, changes, 1)
+		since = lastCursor(t, repo)
+	})
+
+	t.Run("When SaveBatch records a change for each transaction of the batch", func(t *testing.T) {
+		batch := []transaction.Transaction{
+			{ID: "change-2", UserID: userID, Amount: 10, DateTime: &now},
+			{ID: "change-3", UserID: userID, Amount: -5, DateTime: &now},
+		}
+		assert.Nil(t, transactionRepo.SaveBatch(ctx, batch))
+
+		changes, err := repo.List(ctx, change.ListOptions{Since: since, Limit: 10})
+		assert.Nil(t, err)
+		assert.Len(t, changes, 2)
+		assert.Equal(t, "change-2", changes[0].EntityID)
+		assert.Equal(t, "change-3", changes[1].EntityID)
+		since = lastCursor(t, repo)
+	})
+
+	t.Run("When a failed SaveBatch records nothing and leaves no gap in the cursors", func(t *testing.T) {
+		batch := []transaction.Transaction{
+			{ID: "change-4", UserID: userID, Amount: 10, DateTime: &now},
+			{ID: "change-2", UserID: userID, Amount: 10, DateTime: &now},
+		}
+		assert.Error(t, transactionRepo.SaveBatch(ctx, batch))
+		assert.Nil(t, transactionRepo.Save(ctx, transaction.Transaction{ID: "change-4", UserID: userID, Amount: 10,
+			DateTime: &now}))
+
+		changes, err := repo.List(ctx, change.ListOptions{Since: since, Limit: 10})
+		assert.Nil(t, err)
+		assert.Len(t, changes, 1)
+		assert.Equal(t, strconv.FormatInt(since+1, 10), changes[0].Cursor)
+		since = lastCursor(t, repo)
+	})
+
+	t.Run("When the skipped transactions of a batch are not recorded", func(t *testing.T) {
+		batch := []transaction.Transaction{
+			{ID: "change-5", UserID: userID, Amount: 10, DateTime: &now},
+			{ID: "change-4", UserID: userID, Amount: 10, DateTime: &now},
+		}
+		_, err := transactionRepo.SaveBatchSkippingRejected(ctx, batch)
+		assert.Nil(t, err)
+
+		changes, err := repo.List(ctx, change.ListOptions{Since: since, Limit: 10})
+		assert.Nil(t, err)
+		assert.Len(t, changes, 1)
+		assert.Equal(t, "change-5", changes[0].EntityID)
+		since = lastCursor(t, repo)
+	})
+
+	t.Run("When a committed stage records its transactions", func(t *testing.T) {
+		stageID, err := transactionRepo.CreateStage(ctx)
+		assert.Nil(t, err)
+		assert.Nil(t, transactionRepo.StageBatch(ctx, stageID, []transaction.Transaction{
+			{ID: "change-6", UserID: userID, Amount: 10, DateTime: &now},
+			{ID: "change-7", UserID: userID, Amount: 20, DateTime: &now},
+		}))
+		_, err = transactionRepo.CommitStage(ctx, stageID)
+		assert.Nil(t, err)
+
+		changes, err := repo.List(ctx, change.ListOptions{Since: since, Limit: 10})
+		assert.Nil(t, err)
+		assert.Len(t, changes, 2)
+		assert.Equal(t, 0, testDB.CountRows(t, "transaction_stages"))
+		since = lastCursor(t, repo)
+	})
+
+	t.Run("When the feed is read in pages", func(t *testing.T) {
+		changes, err := repo.List(ctx, change.ListOptions{Since: 0, Limit: 2})
+		assert.Nil(t, err)
+		assert.Len(t, changes, 2)
+		assert.Equal(t, "1", changes[0].Cursor)
+		assert.Equal(t, change.OperationInsert, changes[0].Operation)
+
+		changes, err = repo.List(ctx, change.ListOptions{Since: since, Limit: 2})
+		assert.Nil(t, err)
+		assert.Empty(t, changes)
+	})
+}
+
+// lastCursor reads the cursor of the latest change so a subtest only sees its own
+func lastCursor(t *testing.T, repo change.Repository) int64 {
+	var since int64
+	for {
+		changes, err := repo.List(context.TODO(), change.ListOptions{Since: since, Limit: 100})
+		if err != nil {
+			t.Fatalf("Failed to list changes: %v", err)
+		}
+
+		if len(changes) == 0 {
+			return since
+		}
+
+		since, _ = strconv.ParseInt(changes[len(changes)-1].Cursor, 10, 64)
+	}
+}
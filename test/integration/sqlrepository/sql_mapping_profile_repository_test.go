@@ -0,0 +1,65 @@
+package sqlrepository_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/sebastianreh/user-balance-api/internal/domain/migration"
+	"github.com/sebastianreh/user-balance-api/internal/infrastructure/postgresql"
+	"github.com/sebastianreh/user-balance-api/pkg/logger"
+	"github.com/sebastianreh/user-balance-api/test/integration/sqlrepository"
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_SqlMappingProfileRepository(t *testing.T) {
+	ctx := context.TODO()
+	testDb := sqlrepository.SetupTestDB(t)
+	testDb.RunMigrations(t)
+	repo := postgresql.NewSQLMappingProfileRepository(logger.NewLogger(), testDb.DB)
+	defer testDb.TeardownTestDB(t)
+
+	profile := migration.MappingProfile{
+		Name:           "partner-a",
+		Columns:        map[string][]string{migration.FieldUserID: {"customer", "client"}},
+		IgnoredColumns: []string{"notes"},
+		Defaults:       map[string]string{migration.FieldDateTime: "2024-01-01T00:00:00Z"},
+		Strict:         true,
+	}
+
+	t.Run("When a profile is saved and found by its name", func(t *testing.T) {
+		saved, err := repo.Save(ctx, profile)
+		assert.Nil(t, err)
+		assert.False(t, saved.CreatedAt.IsZero())
+
+		found, err := repo.FindByName(ctx, profile.Name)
+		assert.Nil(t, err)
+		assert.Equal(t, profile.Columns, found.Columns)
+		assert.Equal(t, profile.IgnoredColumns, found.IgnoredColumns)
+		assert.Equal(t, profile.Defaults, found.Defaults)
+		assert.True(t, found.Strict)
+	})
+
+	t.Run("When the name is already taken", func(t *testing.T) {
+		_, err := repo.Save(ctx, profile)
+		assert.EqualError(t, err, migration.DuplicateProfileError)
+	})
+
+	t.Run("When the profiles are listed by name", func(t *testing.T) {
+		_, err := repo.Save(ctx, migration.MappingProfile{Name: "another"})
+		assert.Nil(t, err)
+
+		profiles, err := repo.List(ctx)
+		assert.Nil(t, err)
+		assert.Len(t, profiles, 2)
+		assert.Equal(t, "another", profiles[0].Name)
+		assert.Equal(t, profile.Name, profiles[1].Name)
+	})
+
+	t.Run("When a profile is deleted", func(t *testing.T) {
+		assert.Nil(t, repo.Delete(ctx, profile.Name))
+
+		_, err := repo.FindByName(ctx, profile.Name)
+		assert.EqualError(t, err, migration.ProfileNotFoundError)
+		assert.EqualError(t, repo.Delete(ctx, profile.Name), migration.ProfileNotFoundError)
+	})
+}
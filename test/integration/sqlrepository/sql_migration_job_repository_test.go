@@ -0,0 +1,160 @@
+package sqlrepository_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/sebastianreh/user-balance-api/internal/domain/migration"
+	"github.com/sebastianreh/user-balance-api/internal/domain/report"
+	"github.com/sebastianreh/user-balance-api/internal/infrastructure/postgresql"
+	"github.com/sebastianreh/user-balance-api/pkg/logger"
+	"github.com/sebastianreh/user-balance-api/test/integration/sqlrepository"
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_SqlMigrationJobRepository(t *testing.T) {
+	ctx := context.TODO()
+	testDb := sqlrepository.SetupTestDB(t)
+	testDb.RunMigrations(t)
+	repo := postgresql.NewSQLMigrationJobRepository(logger.NewLogger(), testDb.DB)
+	defer testDb.TeardownTestDB(t)
+
+	t.Run("When a job is saved and updated", func(t *testing.T) {
+		jobID, err := repo.Save(ctx, migration.Job{Status: migration.StatusPending, FileName: "test.csv"})
+		assert.Nil(t, err)
+
+		job, err := repo.FindByID(ctx, jobID)
+		assert.Nil(t, err)
+		assert.Equal(t, migration.StatusPending, job.Status)
+		assert.Equal(t, "test.csv", job.FileName)
+		assert.Nil(t, job.Summary)
+
+		job.Status = migration.StatusCompleted
+		job.Progress = migration.Progress{RowsValidated: 10, RowsInserted: 10, BatchesDone: 2, BatchesTotal: 2}
+		job.Summary = &report.MigrationSummary{TotalRecords: 10, UsersUpdated: 3}
+		assert.Nil(t, repo.Update(ctx, job))
+
+		updated, err := repo.FindByID(ctx, jobID)
+		assert.Nil(t, err)
+		assert.Equal(t, job.Progress, updated.Progress)
+		assert.Equal(t, job.Summary, updated.Summary)
+		assert.Equal(t, migration.StatusCompleted, updated.Status)
+
+		job.Status = migration.StatusFailed
+		job.Error = migration.InterruptedError
+		assert.EqualError(t, repo.Update(ctx, job), migration.NotFoundError)
+	})
+
+	t.Run("When the job does not exist", func(t *testing.T) {
+		_, err := repo.FindByID(ctx, "999")
+		assert.EqualError(t, err, migration.NotFoundError)
+
+		err = repo.Update(ctx, migration.Job{ID: "999", Status: migration.StatusFailed})
+		assert.EqualError(t, err, migration.NotFoundError)
+
+		err = repo.Heartbeat(ctx, "999")
+		assert.EqualError(t, err, migration.NotFoundError)
+	})
+
+	t.Run("When stale jobs are failed", func(t *testing.T) {
+		jobID, err := repo.Save(ctx, migration.Job{Status: migration.StatusRunning, FileName: "stale.csv"})
+		assert.Nil(t, err)
+
+		failed, err := repo.FailStale(ctx, time.Now().Add(time.Minute), migration.InterruptedError)
+		assert.Nil(t, err)
+		assert.Equal(t, int64(1), failed)
+
+		job, err := repo.FindByID(ctx, jobID)
+		assert.Nil(t, err)
+		assert.Equal(t, migration.StatusFailed, job.Status)
+		assert.Equal(t, migration.InterruptedError, job.Error)
+	})
+
+	t.Run("When the rejects of a partial job are saved and read in line order", func(t *testing.T) {
+		jobID, err := repo.Save(ctx, migration.Job{Status: migration.StatusRunning, FileName: "partial.csv",
+			Mode: migration.ModePartial})
+		assert.Nil(t, err)
+
+		rejects := []migration.Reject{
+			{Line: 7, Raw: "5,9,10,2024-09-13T10:00:00Z", Reason: migration.RejectReasonUnknownUser, Detail: "user not found"},
+			{Line: 3, Raw: "2,1,0,2024-09-13T10:00:00Z", Reason: migration.RejectReasonZeroAmount, Detail: "zero"},
+		}
+		assert.Nil(t, repo.SaveRejects(ctx, jobID, rejects))
+
+		var saved []migration.Reject
+		err = repo.ForEachReject(ctx, jobID, func(reject migration.Reject) error {
+			saved = append(saved, reject)
+			return nil
+		})
+		assert.Nil(t, err)
+		assert.Equal(t, []migration.Reject{rejects[1], rejects[0]}, saved)
+
+		job, err := repo.FindByID(ctx, jobID)
+		assert.Nil(t, err)
+		assert.Equal(t, migration.ModePartial, job.Mode)
+
+		job.Status = migration.StatusCompleted
+		job.Summary = &report.MigrationSummary{TotalRecords: 1, UsersUpdated: 1, RejectedRecords: 2,
+			RejectsByReason: map[string]int{migration.RejectReasonUnknownUser: 1, migration.RejectReasonZeroAmount: 1}}
+		assert.Nil(t, repo.Update(ctx, job))
+
+		updated, err := repo.FindByID(ctx, jobID)
+		assert.Nil(t, err)
+		assert.Equal(t, job.Summary, updated.Summary)
+	})
+
+	t.Run("When an imported file is found by its checksum or its content hash", func(t *testing.T) {
+		firstID, err := repo.Save(ctx, migration.Job{Status: migration.StatusCompleted, FileName: "import.csv",
+			Checksum: "checksum-a", ContentHash: "content-a"})
+		assert.Nil(t, err)
+
+		forcedID, err := repo.Save(ctx, migration.Job{Status: migration.StatusCompleted, FileName: "import.csv",
+			Checksum: "checksum-a", ContentHash: "content-a", Forced: true, DuplicateOf: firstID})
+		assert.Nil(t, err)
+
+		job, err := repo.FindImported(ctx, "checksum-b", "content-a")
+		assert.Nil(t, err)
+		assert.Equal(t, forcedID, job.ID)
+		assert.True(t, job.Forced)
+		assert.Equal(t, firstID, job.DuplicateOf)
+
+		job, err = repo.FindImported(ctx, "checksum-a", "content-b")
+		assert.Nil(t, err)
+		assert.Equal(t, forcedID, job.ID)
+	})
+
+	t.Run("When two jobs that are not forced import the same content", func(t *testing.T) {
+		firstID, err := repo.Save(ctx, migration.Job{Status: migration.StatusPending, FileName: "race.csv",
+			Checksum: "checksum-d", ContentHash: "content-d"})
+		assert.Nil(t, err)
+
+		_, err = repo.Save(ctx, migration.Job{Status: migration.StatusPending, FileName: "race.csv.gz",
+			Checksum: "checksum-e", ContentHash: "content-d"})
+		assert.EqualError(t, err, migration.DuplicateFileError)
+
+		_, err = repo.Save(ctx, migration.Job{Status: migration.StatusPending, FileName: "race.csv",
+			Checksum: "checksum-d", ContentHash: "content-d", Forced: true, DuplicateOf: firstID})
+		assert.Nil(t, err)
+
+		first, err := repo.FindByID(ctx, firstID)
+		assert.Nil(t, err)
+		first.Status = migration.StatusFailed
+		assert.Nil(t, repo.Update(ctx, first))
+
+		_, err = repo.Save(ctx, migration.Job{Status: migration.StatusPending, FileName: "race.csv",
+			Checksum: "checksum-d", ContentHash: "content-d"})
+		assert.Nil(t, err)
+	})
+
+	t.Run("When the file was only imported by failed or rolled back jobs", func(t *testing.T) {
+		for _, status := range []string{migration.StatusFailed, migration.StatusRolledBack} {
+			_, err := repo.Save(ctx, migration.Job{Status: status, FileName: "failed.csv", Checksum: "checksum-c",
+				ContentHash: "content-c"})
+			assert.Nil(t, err)
+		}
+
+		_, err := repo.FindImported(ctx, "checksum-c", "content-c")
+		assert.EqualError(t, err, migration.NotFoundError)
+	})
+}
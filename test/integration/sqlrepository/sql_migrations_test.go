@@ -26,10 +26,54 @@ func Test_RunMigrations(t *testing.T) {
 		_, err = repo.DB.Exec("SELECT 1 FROM transactions LIMIT 1;")
 		assert.Nil(t, err, "transactions table should exist")
 
-		_, err = repo.DB.Exec("SELECT indexname FROM pg_indexes WHERE indexname = 'idx_transactions_user_id';")
+		// A query without rows doesn't fail, the name is scanned to tell the index exists
+		for _, index := range []string{"idx_transactions_user_id", "idx_transactions_date_time",
+			"idx_users_active_email_unique"} {
+			var indexName string
+			err = repo.DB.QueryRow("SELECT indexname FROM pg_indexes WHERE indexname = $1", index).Scan(&indexName)
+			assert.Nil(t, err, "%s index should exist", index)
+			assert.Equal(t, index, indexName)
+		}
+	})
+}
+
+func Test_RunMigrations_DuplicateEmails(t *testing.T) {
+	repo := sqlrepository.SetupTestDB(t)
+	defer repo.TeardownTestDB(t)
+
+	t.Run("When users created before the unique index share an email it's kept by the lowest id", func(t *testing.T) {
+		_, err := repo.DB.Exec(`
+		CREATE TABLE users (
+		id BIGSERIAL PRIMARY KEY,
+		first_name VARCHAR(255),
+		last_name VARCHAR(255),
+		email VARCHAR(255),
+		is_deleted BOOLEAN DEFAULT FALSE
+		);
+		INSERT INTO users (first_name, last_name, email, is_deleted) VALUES
+		('first', 'user', 'User@Email.com', FALSE),
+		('second', 'user', ' user@email.com ', FALSE),
+		('deleted', 'user', 'user@email.com', TRUE),
+		('other', 'user', 'other@email.com', FALSE),
+		('missing', 'email', '', FALSE),
+		('missing', 'email', '', FALSE);`)
 		assert.Nil(t, err)
 
-		_, err = repo.DB.Exec("SELECT indexname FROM pg_indexes WHERE indexname = 'idx_transactions_date_time';")
+		migrations := postgresql.NewSQLMigrations(logger.NewLogger(), repo.DB)
+		assert.Nil(t, migrations.RunMigrations())
+
+		rows, err := repo.DB.Query("SELECT email FROM users ORDER BY id")
 		assert.Nil(t, err)
+		defer rows.Close()
+
+		var emails []string
+		for rows.Next() {
+			var email string
+			assert.Nil(t, rows.Scan(&email))
+			emails = append(emails, email)
+		}
+
+		assert.Equal(t, []string{"user@email.com", "duplicate-2-user@email.com", "user@email.com", "other@email.com",
+			"", ""}, emails)
 	})
 }
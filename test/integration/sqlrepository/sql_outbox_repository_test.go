@@ -0,0 +1,177 @@
+package sqlrepository_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/sebastianreh/user-balance-api/internal/domain/migration"
+	"github.com/sebastianreh/user-balance-api/internal/domain/outbox"
+	"github.com/sebastianreh/user-balance-api/internal/domain/transaction"
+	"github.com/sebastianreh/user-balance-api/internal/domain/user"
+	"github.com/sebastianreh/user-balance-api/internal/infrastructure/postgresql"
+	"github.com/sebastianreh/user-balance-api/pkg/logger"
+	"github.com/sebastianreh/user-balance-api/test/integration/sqlrepository"
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_OutboxRepository(t *testing.T) {
+	ctx := context.TODO()
+	testDb := sqlrepository.SetupTestDB(t)
+	testDb.RunMigrations(t)
+	log := logger.NewLogger()
+	defer testDb.TeardownTestDB(t)
+
+	jobRepo := postgresql.NewSQLMigrationJobRepository(log, testDb.DB)
+	outboxRepo := postgresql.NewSQLOutboxRepository(log, testDb.DB)
+	message, err := outbox.NewMessage(outbox.KindEmail, "Migration Report", map[string]string{"Subject": "Report"})
+	assert.Nil(t, err)
+
+	t.Run("When a job is completed its messages are saved with it", func(t *testing.T) {
+		jobID, err := jobRepo.Save(ctx, migration.Job{Status: migration.StatusRunning, FileName: "outbox.csv"})
+		assert.Nil(t, err)
+
+		err = jobRepo.Finish(ctx, migration.Job{ID: jobID, Status: migration.StatusCompleted, FileName: "outbox.csv"},
+			message)
+		assert.Nil(t, err)
+
+		job, err := jobRepo.FindByID(ctx, jobID)
+		assert.Nil(t, err)
+		assert.Equal(t, migration.StatusCompleted, job.Status)
+		assert.Equal(t, 1, testDb.CountRows(t, "outbox"))
+	})
+
+	t.Run("When the job to complete does not exist no message is saved", func(t *testing.T) {
+		err := jobRepo.Finish(ctx, migration.Job{ID: "999", Status: migration.StatusCompleted}, message)
+
+		assert.ErrorContains(t, err, migration.NotFoundError)
+		assert.Equal(t, 1, testDb.CountRows(t, "outbox"))
+	})
+
+	t.Run("When a claimed message fails it's not claimed again until its next attempt", func(t *testing.T) {
+		claimed, err := outboxRepo.ClaimDue(ctx, 10, time.Minute)
+		assert.Nil(t, err)
+		assert.Len(t, claimed, 1)
+		assert.JSONEq(t, `{"Subject":"Report"}`, string(claimed[0].Payload))
+
+		// A claimed message is leased to its dispatcher
+		again, err := outboxRepo.ClaimDue(ctx, 10, time.Minute)
+		assert.Nil(t, err)
+		assert.Empty(t, again)
+
+		next := time.Now().Add(time.Hour)
+		assert.Nil(t, outboxRepo.MarkFailed(ctx, claimed[0].ID, "smtp unavailable", &next))
+		again, err = outboxRepo.ClaimDue(ctx, 10, time.Minute)
+		assert.Nil(t, err)
+		assert.Empty(t, again)
+
+		failed, err := outboxRepo.FindByID(ctx, claimed[0].ID)
+		assert.Nil(t, err)
+		assert.Equal(t, outbox.StatusPending, failed.Status)
+		assert.Equal(t, 1, failed.Attempts)
+		assert.Equal(t, "smtp unavailable", failed.LastError)
+		assert.Len(t, failed.History, 1)
+	})
+
+	t.Run("When a message runs out of attempts it's only claimed after it's redriven", func(t *testing.T) {
+		page, err := outboxRepo.List(ctx, outbox.ListOptions{Status: outbox.StatusPending, Limit: 10})
+		assert.Nil(t, err)
+		assert.Len(t, page, 1)
+		messageID := page[0].ID
+
+		assert.Nil(t, outboxRepo.MarkFailed(ctx, messageID, "smtp unavailable", nil))
+		failed, err := outboxRepo.List(ctx, outbox.ListOptions{Status: outbox.StatusFailed, Limit: 10})
+		assert.Nil(t, err)
+		assert.Len(t, failed, 1)
+
+		redriven, err := outboxRepo.Redrive(ctx, messageID)
+		assert.Nil(t, err)
+		assert.Equal(t, outbox.StatusPending, redriven.Status)
+		assert.Equal(t, 0, redriven.Attempts)
+
+		claimed, err := outboxRepo.ClaimDue(ctx, 10, time.Minute)
+		assert.Nil(t, err)
+		assert.Len(t, claimed, 1)
+		assert.Nil(t, outboxRepo.MarkSent(ctx, messageID))
+
+		sent, err := outboxRepo.FindByID(ctx, messageID)
+		assert.Nil(t, err)
+		assert.Equal(t, outbox.StatusSent, sent.Status)
+		assert.NotNil(t, sent.SentAt)
+		assert.Len(t, sent.History, 3)
+	})
+
+	t.Run("When a sent message is redriven", func(t *testing.T) {
+		sent, err := outboxRepo.List(ctx, outbox.ListOptions{Status: outbox.StatusSent, Limit: 10})
+		assert.Nil(t, err)
+		assert.Len(t, sent, 1)
+
+		_, err = outboxRepo.Redrive(ctx, sent[0].ID)
+		assert.EqualError(t, err, outbox.NotRedrivableError)
+	})
+
+	t.Run("When messages are saved on their own they are pending", func(t *testing.T) {
+		assert.Nil(t, outboxRepo.Save(ctx, message, message))
+
+		pending, err := outboxRepo.List(ctx, outbox.ListOptions{Status: outbox.StatusPending, Limit: 10})
+		assert.Nil(t, err)
+		assert.Len(t, pending, 2)
+	})
+
+	t.Run("When the message does not exist", func(t *testing.T) {
+		_, err := outboxRepo.FindByID(ctx, "999")
+		assert.ErrorContains(t, err, outbox.NotFoundError)
+
+		err = outboxRepo.MarkSent(ctx, "999")
+		assert.ErrorContains(t, err, outbox.NotFoundError)
+	})
+}
+
+func Test_OutboxRepository_SavedWithChanges(t *testing.T) {
+	ctx := context.TODO()
+	testDb := sqlrepository.SetupTestDB(t)
+	testDb.RunMigrations(t)
+	log := logger.NewLogger()
+	defer testDb.TeardownTestDB(t)
+
+	userRepo := postgresql.NewSQLUserRepository(log, testDb.DB)
+	transactionRepo := postgresql.NewSQLTransactionRepository(log, testDb.DB)
+	message, err := outbox.NewMessage(outbox.KindWebhook, "transaction.created to https://example.com/hooks",
+		map[string]string{"event": "transaction.created"})
+	assert.Nil(t, err)
+	userID := testDb.CreateUser(t, user.User{FirstName: "John", LastName: "Doe", Email: "outbox@email.com"})
+	now := time.Now()
+
+	t.Run("When a transaction is saved its messages are saved with it", func(t *testing.T) {
+		err := transactionRepo.Save(ctx, transaction.Transaction{ID: "outbox-1", UserID: userID, Amount: 10,
+			DateTime: &now}, message)
+
+		assert.Nil(t, err)
+		assert.Equal(t, 1, testDb.CountRows(t, "outbox"))
+	})
+
+	t.Run("When a transaction can't be saved no message is saved", func(t *testing.T) {
+		err := transactionRepo.Save(ctx, transaction.Transaction{ID: "outbox-1", UserID: userID, Amount: 10,
+			DateTime: &now}, message)
+
+		assert.ErrorContains(t, err, transaction.DuplicateTransactionError)
+		assert.Equal(t, 1, testDb.CountRows(t, "outbox"))
+	})
+
+	t.Run("When a transaction is deleted its messages are saved with it", func(t *testing.T) {
+		assert.Nil(t, transactionRepo.Delete(ctx, "outbox-1", message))
+		assert.Equal(t, 2, testDb.CountRows(t, "outbox"))
+	})
+
+	t.Run("When a user is deleted its messages are saved with it", func(t *testing.T) {
+		assert.Nil(t, userRepo.Delete(ctx, userID, message))
+		assert.Equal(t, 3, testDb.CountRows(t, "outbox"))
+	})
+
+	t.Run("When the user to delete is already deleted no message is saved", func(t *testing.T) {
+		err := userRepo.Delete(ctx, userID, message)
+
+		assert.ErrorContains(t, err, user.NotFoundError)
+		assert.Equal(t, 3, testDb.CountRows(t, "outbox"))
+	})
+}
@@ -2,6 +2,7 @@ package sqlrepository_test
 
 import (
 	"context"
+	"strconv"
 	"testing"
 	"time"
 
@@ -180,6 +181,159 @@ func Test_SqlTransactionRepository_SaveBatch(t *testing.T) {
 	})
 }
 
+func Test_SqlTransactionRepository_SaveBatchSkippingRejected(t *testing.T) {
+	ctx := context.TODO()
+	testDb := sqlrepository.SetupTestDB(t)
+	testDb.RunMigrations(t)
+	log := logger.NewLogger()
+	repo := postgresql.NewSQLTransactionRepository(log, testDb.DB)
+	defer testDb.TeardownTestDB(t)
+	userID := testDb.CreateUser(t, user.User{
+		FirstName: "user",
+		LastName:  "lastname",
+		Email:     "user@email.com",
+	})
+	now := time.Now()
+
+	t.Run("When the valid transactions are saved and the rest are rejected", func(t *testing.T) {
+		defer testDb.CleanTransactions(t)
+		assert.Nil(t, repo.Save(ctx, transaction.Transaction{ID: "1", UserID: userID, Amount: 50.00, DateTime: &now}))
+
+		transactions := []transaction.Transaction{
+			{ID: "1", UserID: userID, Amount: 100.00, DateTime: &now},             // Already saved
+			{ID: "2", UserID: userID, Amount: 200.00, DateTime: &now},             // Saved
+			{ID: "3", UserID: "3426985345123341", Amount: 100.00, DateTime: &now}, // UserID not found
+			{ID: "4", UserID: userID, DateTime: &now},                             // Zero amount
+			{ID: "2", UserID: userID, Amount: 300.00, DateTime: &now},             // Duplicated in the batch
+		}
+
+		rejections, err := repo.SaveBatchSkippingRejected(ctx, transactions)
+		assert.Nil(t, err)
+		assert.Equal(t, []transaction.Rejection{
+			{Index: 0, Reason: transaction.DuplicateTransactionError},
+			{Index: 2, Reason: notFoundError},
+			{Index: 3, Reason: transaction.ZeroAmountError},
+			{Index: 4, Reason: transaction.DuplicateTransactionError},
+		}, rejections)
+
+		savedTransaction, err := repo.FindByID(ctx, "2")
+		assert.Nil(t, err)
+		assert.Equal(t, 200.00, savedTransaction.Amount)
+
+		savedTransaction, err = repo.FindByID(ctx, "1")
+		assert.Nil(t, err)
+		assert.Equal(t, 50.00, savedTransaction.Amount)
+	})
+}
+
+func Test_SqlTransactionRepository_Stage(t *testing.T) {
+	ctx := context.TODO()
+	testDb := sqlrepository.SetupTestDB(t)
+	testDb.RunMigrations(t)
+	log := logger.NewLogger()
+	repo := postgresql.NewSQLTransactionRepository(log, testDb.DB)
+	defer testDb.TeardownTestDB(t)
+	userID := testDb.CreateUser(t, user.User{
+		FirstName: "user",
+		LastName:  "lastname",
+		Email:     "user@email.com",
+	})
+	now := time.Now()
+
+	t.Run("When CommitStage saves every staged batch at once", func(t *testing.T) {
+		defer testDb.CleanTransactions(t)
+		stageID, err := repo.CreateStage(ctx)
+		assert.Nil(t, err)
+
+		assert.Nil(t, repo.StageBatch(ctx, stageID, []transaction.Transaction{
+			{ID: "1", UserID: userID, Amount: 100.00, DateTime: &now},
+			{ID: "2", UserID: userID, Amount: 200.00, DateTime: &now},
+		}))
+		assert.Nil(t, repo.StageBatch(ctx, stageID, []transaction.Transaction{
+			{ID: "3", UserID: userID, Amount: -50.00, DateTime: &now},
+		}))
+		assert.Equal(t, 0, testDb.CountRows(t, "transactions"))
+
+		committed, err := repo.CommitStage(ctx, stageID)
+		assert.Nil(t, err)
+		assert.Equal(t, int64(3), committed)
+		assert.Equal(t, 3, testDb.CountRows(t, "transactions"))
+		assert.Equal(t, 0, testDb.CountRows(t, "transaction_stages"))
+	})
+
+	t.Run("When a late batch fails to commit no staged transaction is saved", func(t *testing.T) {
+		defer testDb.CleanTransactions(t)
+		assert.Nil(t, repo.Save(ctx, transaction.Transaction{ID: "9", UserID: userID, Amount: 10.00, DateTime: &now}))
+
+		stageID, err := repo.CreateStage(ctx)
+		assert.Nil(t, err)
+
+		assert.Nil(t, repo.StageBatch(ctx, stageID, []transaction.Transaction{
+			{ID: "1", UserID: userID, Amount: 100.00, DateTime: &now},
+			{ID: "2", UserID: userID, Amount: 200.00, DateTime: &now},
+		}))
+		assert.Nil(t, repo.StageBatch(ctx, stageID, []transaction.Transaction{
+			{ID: "9", UserID: userID, Amount: 300.00, DateTime: &now}, // Already saved
+		}))
+
+		_, err = repo.CommitStage(ctx, stageID)
+		assert.EqualError(t, err, transaction.DuplicateTransactionError)
+		assert.Equal(t, 1, testDb.CountRows(t, "transactions"))
+
+		_, err = repo.FindByID(ctx, "1")
+		assert.EqualError(t, err, transaction.NotFoundError)
+
+		assert.Nil(t, repo.DiscardStage(ctx, stageID))
+		assert.Equal(t, 0, testDb.CountRows(t, "transaction_stages"))
+	})
+
+	t.Run("When the staged transactions belong to an unknown user", func(t *testing.T) {
+		stageID, err := repo.CreateStage(ctx)
+		assert.Nil(t, err)
+		defer func() { _ = repo.DiscardStage(ctx, stageID) }()
+
+		assert.Nil(t, repo.StageBatch(ctx, stageID, []transaction.Transaction{
+			{ID: "1", UserID: "3426985345123341", Amount: 100.00, DateTime: &now},
+		}))
+
+		_, err = repo.CommitStage(ctx, stageID)
+		assert.EqualError(t, err, notFoundError)
+		assert.Equal(t, 0, testDb.CountRows(t, "transactions"))
+	})
+
+	t.Run("When StageBatch returns a zero amount error", func(t *testing.T) {
+		err := repo.StageBatch(ctx, "1", []transaction.Transaction{{ID: "1", UserID: userID, DateTime: &now}})
+		assert.EqualError(t, err, transaction.ZeroAmountError)
+	})
+}
+
+func Test_SqlTransactionRepository_FindExistingIDs(t *testing.T) {
+	ctx := context.TODO()
+	testDb := sqlrepository.SetupTestDB(t)
+	testDb.RunMigrations(t)
+	log := logger.NewLogger()
+	repo := postgresql.NewSQLTransactionRepository(log, testDb.DB)
+	defer testDb.TeardownTestDB(t)
+	userID := testDb.CreateUser(t, user.User{
+		FirstName: "user",
+		LastName:  "lastname",
+		Email:     "user@email.com",
+	})
+	now := time.Now()
+
+	t.Run("When FindExistingIDs includes the deleted transactions", func(t *testing.T) {
+		defer testDb.CleanTransactions(t)
+		assert.Nil(t, repo.Save(ctx, transaction.Transaction{ID: "1", UserID: userID, Amount: 10.00, DateTime: &now}))
+		assert.Nil(t, repo.Save(ctx, transaction.Transaction{ID: "2", UserID: userID, Amount: 10.00, DateTime: &now}))
+		assert.Nil(t, repo.Delete(ctx, "2"))
+
+		ids, err := repo.FindExistingIDs(ctx, []string{"1", "2", "3"})
+
+		assert.Nil(t, err)
+		assert.Equal(t, map[string]bool{"1": true, "2": true}, ids)
+	})
+}
+
 func Test_SqlTransactionRepository_FindByID(t *testing.T) {
 	ctx := context.TODO()
 	testDb := sqlrepository.SetupTestDB(t)
@@ -326,3 +480,95 @@ func Test_SqlTransactionRepository_FindByUserIDWithOptions(t *testing.T) {
 		assert.Equal(t, "sql: database is closed", err.Error())
 	})
 }
+
+func Test_SqlTransactionRepository_List(t *testing.T) {
+	ctx := context.TODO()
+	testDb := sqlrepository.SetupTestDB(t)
+	testDb.RunMigrations(t)
+	log := logger.NewLogger()
+	repo := postgresql.NewSQLTransactionRepository(log, testDb.DB)
+	defer testDb.TeardownTestDB(t)
+
+	userID := testDb.CreateUser(t, user.User{
+		FirstName: "Test",
+		LastName:  "User",
+		Email:     "testuser@email.com",
+	})
+
+	baseTime := time.Date(2024, 1, 1, 10, 0, 0, 0, time.UTC)
+	amounts := []float64{100, -30, 250, -75.5}
+	for i, amount := range amounts {
+		dateTime := baseTime.AddDate(0, 0, i)
+		err := repo.Save(ctx, transaction.Transaction{ID: strconv.Itoa(i + 1), UserID: userID,
+			Amount: amount, DateTime: &dateTime})
+		assert.Nil(t, err)
+	}
+
+	deletedTime := baseTime.AddDate(0, 0, 10)
+	assert.Nil(t, repo.Save(ctx, transaction.Transaction{ID: "99", UserID: userID, Amount: 1000, DateTime: &deletedTime}))
+	assert.Nil(t, repo.Delete(ctx, "99"))
+
+	t.Run("When List paginates by date with a cursor and skips deleted rows", func(t *testing.T) {
+		options := transaction.ListOptions{UserID: userID, SortBy: transaction.SortByDate,
+			SortOrder: transaction.SortAsc, Limit: 3}
+
+		firstPage, err := repo.List(ctx, options)
+		assert.Nil(t, err)
+		assert.Len(t, firstPage, 3)
+		assert.Equal(t, "1", firstPage[0].ID)
+		assert.Equal(t, "3", firstPage[2].ID)
+
+		cursor := transaction.NewCursor(firstPage[2], options)
+		options.After = &cursor
+		secondPage, err := repo.List(ctx, options)
+		assert.Nil(t, err)
+		assert.Len(t, secondPage, 1)
+		assert.Equal(t, "4", secondPage[0].ID)
+	})
+
+	t.Run("When List sorts by amount descending", func(t *testing.T) {
+		options := transaction.ListOptions{UserID: userID, SortBy: transaction.SortByAmount,
+			SortOrder: transaction.SortDesc, Limit: 2}
+
+		firstPage, err := repo.List(ctx, options)
+		assert.Nil(t, err)
+		assert.Equal(t, "3", firstPage[0].ID)
+		assert.Equal(t, "1", firstPage[1].ID)
+
+		cursor := transaction.NewCursor(firstPage[1], options)
+		options.After = &cursor
+		secondPage, err := repo.List(ctx, options)
+		assert.Nil(t, err)
+		assert.Len(t, secondPage, 2)
+		assert.Equal(t, "2", secondPage[0].ID)
+		assert.Equal(t, "4", secondPage[1].ID)
+	})
+
+	t.Run("When List filters by type, amount and date", func(t *testing.T) {
+		minAmount := -50.0
+		options := transaction.ListOptions{UserID: userID, Type: transaction.TypeDebit, MinAmount: &minAmount,
+			SortBy: transaction.SortByDate, SortOrder: transaction.SortAsc, Limit: 10}
+
+		transactions, err := repo.List(ctx, options)
+		assert.Nil(t, err)
+		assert.Len(t, transactions, 1)
+		assert.Equal(t, "2", transactions[0].ID)
+
+		options = transaction.ListOptions{UserID: userID, FromDate: baseTime.AddDate(0, 0, 2).Format(time.RFC3339),
+			SortBy: transaction.SortByDate, SortOrder: transaction.SortAsc, Limit: 10}
+		transactions, err = repo.List(ctx, options)
+		assert.Nil(t, err)
+		assert.Len(t, transactions, 2)
+	})
+
+	t.Run("When List includes the running balance", func(t *testing.T) {
+		options := transaction.ListOptions{UserID: userID, Type: transaction.TypeCredit, IncludeRunningBalance: true,
+			SortBy: transaction.SortByDate, SortOrder: transaction.SortAsc, Limit: 10}
+
+		transactions, err := repo.List(ctx, options)
+		assert.Nil(t, err)
+		assert.Len(t, transactions, 2)
+		assert.Equal(t, 100.0, *transactions[0].RunningBalance)
+		assert.Equal(t, 320.0, *transactions[1].RunningBalance)
+	})
+}
@@ -3,7 +3,9 @@ package sqlrepository_test
 import (
 	"context"
 	"testing"
+	"time"
 
+	"github.com/sebastianreh/user-balance-api/internal/domain/transaction"
 	"github.com/sebastianreh/user-balance-api/internal/domain/user"
 	"github.com/sebastianreh/user-balance-api/internal/infrastructure/postgresql"
 	"github.com/sebastianreh/user-balance-api/pkg/logger"
@@ -135,3 +137,244 @@ func Test_SqlUserRepository_FindByID(t *testing.T) {
 		assert.Equal(t, "sql: database is closed", err.Error())
 	})
 }
+
+func Test_SqlUserRepository_FindByEmail(t *testing.T) {
+	ctx := context.TODO()
+	testDb := sqlrepository.SetupTestDB(t)
+	testDb.RunMigrations(t)
+	log := logger.NewLogger()
+	repo := postgresql.NewSQLUserRepository(log, testDb.DB)
+	defer testDb.TeardownTestDB(t)
+
+	t.Run("When FindByEmail succeeds ignoring case", func(t *testing.T) {
+		defer testDb.CleanUsers(t)
+		userID := testDb.CreateUser(t, user.User{
+			FirstName: "user",
+			LastName:  "lastname",
+			Email:     "user@email.com",
+		})
+
+		foundUser, err := repo.FindByEmail(ctx, "USER@email.com")
+		assert.Nil(t, err)
+		assert.Equal(t, userID, foundUser.ID)
+		assert.Equal(t, "user@email.com", foundUser.Email)
+	})
+
+	t.Run("When FindByEmail does not return deleted users", func(t *testing.T) {
+		defer testDb.CleanUsers(t)
+		userID := testDb.CreateUser(t, user.User{
+			FirstName: "user",
+			LastName:  "lastname",
+			Email:     "user@email.com",
+		})
+
+		err := repo.Delete(ctx, userID)
+		assert.Nil(t, err)
+
+		_, err = repo.FindByEmail(ctx, "user@email.com")
+		assert.Error(t, err)
+		assert.Equal(t, user.NotFoundError, err.Error())
+	})
+
+	t.Run("When FindByEmail returns no results", func(t *testing.T) {
+		_, err := repo.FindByEmail(ctx, "missing@email.com")
+		assert.Error(t, err)
+		assert.Equal(t, user.NotFoundError, err.Error())
+	})
+}
+
+func Test_SqlUserRepository_FindActiveIDs(t *testing.T) {
+	ctx := context.TODO()
+	testDb := sqlrepository.SetupTestDB(t)
+	testDb.RunMigrations(t)
+	log := logger.NewLogger()
+	repo := postgresql.NewSQLUserRepository(log, testDb.DB)
+	defer testDb.TeardownTestDB(t)
+
+	t.Run("When FindActiveIDs leaves out deleted and unknown users", func(t *testing.T) {
+		activeID := testDb.CreateUser(t, user.User{FirstName: "active", LastName: "user", Email: "active@email.com"})
+		deletedID := testDb.CreateUser(t, user.User{FirstName: "deleted", LastName: "user", Email: "deleted@email.com"})
+		assert.Nil(t, repo.Delete(ctx, deletedID))
+
+		ids, err := repo.FindActiveIDs(ctx, []string{activeID, deletedID, "3426985345123341"})
+
+		assert.Nil(t, err)
+		assert.Equal(t, map[string]bool{activeID: true}, ids)
+	})
+}
+
+func Test_SqlUserRepository_UniqueEmail(t *testing.T) {
+	ctx := context.TODO()
+	testDb := sqlrepository.SetupTestDB(t)
+	testDb.RunMigrations(t)
+	log := logger.NewLogger()
+	repo := postgresql.NewSQLUserRepository(log, testDb.DB)
+	defer testDb.TeardownTestDB(t)
+
+	t.Run("When Save returns a duplicated email error", func(t *testing.T) {
+		defer testDb.CleanUsers(t)
+		testDb.CreateUser(t, user.User{FirstName: "user", LastName: "lastname", Email: "user@email.com"})
+
+		id, err := repo.Save(ctx, user.User{FirstName: "other", LastName: "lastname", Email: "USER@email.com"})
+		assert.Error(t, err)
+		assert.Empty(t, id)
+		assert.Equal(t, user.DuplicateEmailError, err.Error())
+	})
+
+	t.Run("When Update returns a duplicated email error", func(t *testing.T) {
+		defer testDb.CleanUsers(t)
+		testDb.CreateUser(t, user.User{FirstName: "user", LastName: "lastname", Email: "user@email.com"})
+		otherID := testDb.CreateUser(t, user.User{FirstName: "other", LastName: "lastname", Email: "other@email.com"})
+
+		err := repo.Update(ctx, user.User{ID: otherID, Email: "user@email.com"})
+		assert.Error(t, err)
+		assert.Equal(t, user.DuplicateEmailError, err.Error())
+	})
+
+	t.Run("When Save reuses the email of a deleted user", func(t *testing.T) {
+		defer testDb.CleanUsers(t)
+		userID := testDb.CreateUser(t, user.User{FirstName: "user", LastName: "lastname", Email: "user@email.com"})
+
+		err := repo.Delete(ctx, userID)
+		assert.Nil(t, err)
+
+		id, err := repo.Save(ctx, user.User{FirstName: "user", LastName: "lastname", Email: "user@email.com"})
+		assert.Nil(t, err)
+		assert.NotEmpty(t, id)
+	})
+}
+
+func Test_SqlUserRepository_List(t *testing.T) {
+	ctx := context.TODO()
+	testDb := sqlrepository.SetupTestDB(t)
+	testDb.RunMigrations(t)
+	log := logger.NewLogger()
+	repo := postgresql.NewSQLUserRepository(log, testDb.DB)
+	transactionRepo := postgresql.NewSQLTransactionRepository(log, testDb.DB)
+	defer testDb.TeardownTestDB(t)
+
+	anaID := testDb.CreateUser(t, user.User{FirstName: "Ana", LastName: "Diaz", Email: "ana@email.com"})
+	brunoID := testDb.CreateUser(t, user.User{FirstName: "Bruno", LastName: "Andrade", Email: "bruno@email.com"})
+	carlaID := testDb.CreateUser(t, user.User{FirstName: "Carla", LastName: "Perez", Email: "carla@email.com"})
+	deletedID := testDb.CreateUser(t, user.User{FirstName: "Dario", LastName: "Lopez", Email: "dario@email.com"})
+	assert.Nil(t, repo.Delete(ctx, deletedID))
+
+	t.Run("When List paginates by id with a cursor", func(t *testing.T) {
+		options := user.ListOptions{SortBy: user.SortByID, SortOrder: user.SortAsc, Limit: 2}
+
+		firstPage, err := repo.List(ctx, options)
+		assert.Nil(t, err)
+		assert.Len(t, firstPage, 2)
+		assert.Equal(t, anaID, firstPage[0].ID)
+		assert.Equal(t, brunoID, firstPage[1].ID)
+
+		cursor := user.NewCursor(firstPage[1], options)
+		options.After = &cursor
+		secondPage, err := repo.List(ctx, options)
+		assert.Nil(t, err)
+		assert.Len(t, secondPage, 1)
+		assert.Equal(t, carlaID, secondPage[0].ID)
+	})
+
+	t.Run("When List sorts by email descending", func(t *testing.T) {
+		options := user.ListOptions{SortBy: user.SortByEmail, SortOrder: user.SortDesc, Limit: 10}
+
+		users, err := repo.List(ctx, options)
+		assert.Nil(t, err)
+		assert.Len(t, users, 3)
+		assert.Equal(t, carlaID, users[0].ID)
+		assert.Equal(t, anaID, users[2].ID)
+	})
+
+	t.Run("When List filters by name prefix", func(t *testing.T) {
+		options := user.ListOptions{NamePrefix: "an", SortBy: user.SortByName, SortOrder: user.SortAsc, Limit: 10}
+
+		users, err := repo.List(ctx, options)
+		assert.Nil(t, err)
+		assert.Len(t, users, 2)
+		assert.Equal(t, anaID, users[0].ID)
+		assert.Equal(t, brunoID, users[1].ID)
+	})
+
+	t.Run("When List filters by deleted status", func(t *testing.T) {
+		options := user.ListOptions{Deleted: user.DeletedOnly, SortBy: user.SortByID, SortOrder: user.SortAsc, Limit: 10}
+
+		users, err := repo.List(ctx, options)
+		assert.Nil(t, err)
+		assert.Len(t, users, 1)
+		assert.Equal(t, deletedID, users[0].ID)
+		assert.True(t, users[0].IsDeleted)
+
+		options.Deleted = user.DeletedAll
+		users, err = repo.List(ctx, options)
+		assert.Nil(t, err)
+		assert.Len(t, users, 4)
+	})
+
+	t.Run("When List includes the balance", func(t *testing.T) {
+		defer testDb.CleanTransactions(t)
+		now := time.Now()
+		assert.Nil(t, transactionRepo.Save(ctx, transaction.Transaction{ID: "1", UserID: anaID, Amount: 100.50, DateTime: &now}))
+		assert.Nil(t, transactionRepo.Save(ctx, transaction.Transaction{ID: "2", UserID: anaID, Amount: -20.25, DateTime: &now}))
+
+		options := user.ListOptions{SortBy: user.SortByID, SortOrder: user.SortAsc, Limit: 2, IncludeBalance: true}
+
+		users, err := repo.List(ctx, options)
+		assert.Nil(t, err)
+		assert.Len(t, users, 2)
+		assert.Equal(t, 80.25, users[0].Balance.Balance)
+		assert.Equal(t, 1, users[0].Balance.TotalCredits)
+		assert.Equal(t, 1, users[0].Balance.TotalDebits)
+		assert.Equal(t, float64(0), users[1].Balance.Balance)
+	})
+}
+
+func Test_SqlUserRepository_CreateMissing(t *testing.T) {
+	ctx := context.TODO()
+	testDb := sqlrepository.SetupTestDB(t)
+	testDb.RunMigrations(t)
+	log := logger.NewLogger()
+	repo := postgresql.NewSQLUserRepository(log, testDb.DB)
+	defer testDb.TeardownTestDB(t)
+
+	existingID, err := repo.Save(ctx, user.User{FirstName: "user", LastName: "lastname", Email: "taken@email.com"})
+	assert.Nil(t, err)
+
+	t.Run("When the users with free ids are created with them", func(t *testing.T) {
+		created, emailTaken, err := repo.CreateMissing(ctx, []user.User{
+			{ID: existingID, FirstName: "ignored"},
+			{ID: "5000", FirstName: "Ada", LastName: "Lovelace", Email: "ada@email.com"},
+			{ID: "5001"},
+			{ID: "5002", Email: "taken@email.com"},
+			{ID: "5003", Email: "ada@email.com"},
+			{ID: "5004"},
+		})
+
+		assert.Nil(t, err)
+		assert.Equal(t, 3, created)
+		assert.Equal(t, map[string]bool{"5002": true, "5003": true}, emailTaken)
+
+		legacyUser, err := repo.FindByID(ctx, "5000")
+		assert.Nil(t, err)
+		assert.Equal(t, "Ada", legacyUser.FirstName)
+
+		existingUser, err := repo.FindByID(ctx, existingID)
+		assert.Nil(t, err)
+		assert.Equal(t, "user", existingUser.FirstName)
+
+		_, err = repo.FindByID(ctx, "5002")
+		assert.EqualError(t, err, user.NotFoundError)
+
+		// Users without an email don't take it from each other
+		withoutEmail, err := repo.FindByID(ctx, "5004")
+		assert.Nil(t, err)
+		assert.Empty(t, withoutEmail.Email)
+	})
+
+	t.Run("When a user is saved after the legacy ids it gets a new id", func(t *testing.T) {
+		id, err := repo.Save(ctx, user.User{FirstName: "new", LastName: "user", Email: "new@email.com"})
+
+		assert.Nil(t, err)
+		assert.Equal(t, "5005", id)
+	})
+}
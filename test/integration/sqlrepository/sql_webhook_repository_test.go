@@ -0,0 +1,89 @@
+package sqlrepository_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/sebastianreh/user-balance-api/internal/domain/outbox"
+	"github.com/sebastianreh/user-balance-api/internal/domain/webhook"
+	"github.com/sebastianreh/user-balance-api/internal/infrastructure/postgresql"
+	"github.com/sebastianreh/user-balance-api/pkg/logger"
+	"github.com/sebastianreh/user-balance-api/test/integration/sqlrepository"
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_WebhookRepository(t *testing.T) {
+	ctx := context.TODO()
+	testDb := sqlrepository.SetupTestDB(t)
+	testDb.RunMigrations(t)
+	log := logger.NewLogger()
+	defer testDb.TeardownTestDB(t)
+
+	repository := postgresql.NewSQLWebhookRepository(log, testDb.DB)
+	outboxRepo := postgresql.NewSQLOutboxRepository(log, testDb.DB)
+	subscription := webhook.Subscription{URL: "https://example.com/hooks", Description: "ledger", Active: true,
+		Events: []string{webhook.EventTransactionCreated, webhook.EventUserDeleted}, Secret: "whsec_1"}
+
+	t.Run("When a subscription is saved it's found by its events", func(t *testing.T) {
+		saved, err := repository.Save(ctx, subscription)
+		assert.Nil(t, err)
+		assert.NotEmpty(t, saved.ID)
+		subscription.ID = saved.ID
+
+		subscriptions, err := repository.FindByEvent(ctx, webhook.EventUserDeleted)
+		assert.Nil(t, err)
+		assert.Len(t, subscriptions, 1)
+		assert.Equal(t, subscription.Events, subscriptions[0].Events)
+
+		subscriptions, err = repository.FindByEvent(ctx, webhook.EventMigrationCompleted)
+		assert.Nil(t, err)
+		assert.Empty(t, subscriptions)
+	})
+
+	t.Run("When a subscription is paused it keeps its secret and gets no events", func(t *testing.T) {
+		paused := subscription
+		paused.Active, paused.Secret = false, ""
+		_, err := repository.Update(ctx, paused)
+		assert.Nil(t, err)
+
+		found, err := repository.FindByID(ctx, subscription.ID)
+		assert.Nil(t, err)
+		assert.False(t, found.Active)
+		assert.Equal(t, "whsec_1", found.Secret)
+
+		subscriptions, err := repository.FindByEvent(ctx, webhook.EventUserDeleted)
+		assert.Nil(t, err)
+		assert.Empty(t, subscriptions)
+	})
+
+	t.Run("When the deliveries of a subscription are listed and replayed", func(t *testing.T) {
+		delivery, err := outbox.NewMessage(outbox.KindWebhook, "user.deleted to https://example.com/hooks",
+			webhook.Delivery{SubscriptionID: subscription.ID, EventID: "evt_1", Event: webhook.EventUserDeleted})
+		assert.Nil(t, err)
+		delivery.Reference = webhook.Reference(subscription.ID)
+		other, err := outbox.NewMessage(outbox.KindEmail, "Migration Report", map[string]string{"Subject": "Report"})
+		assert.Nil(t, err)
+		assert.Nil(t, outboxRepo.Save(ctx, delivery, other))
+
+		deliveries, err := outboxRepo.List(ctx, outbox.ListOptions{Reference: delivery.Reference, Limit: 10})
+		assert.Nil(t, err)
+		assert.Len(t, deliveries, 1)
+
+		replayed, err := outboxRepo.Duplicate(ctx, deliveries[0].ID)
+		assert.Nil(t, err)
+		assert.NotEqual(t, deliveries[0].ID, replayed.ID)
+		assert.Equal(t, delivery.Reference, replayed.Reference)
+		assert.JSONEq(t, string(deliveries[0].Payload), string(replayed.Payload))
+
+		_, err = outboxRepo.Duplicate(ctx, "999")
+		assert.ErrorContains(t, err, outbox.NotFoundError)
+	})
+
+	t.Run("When a subscription is deleted", func(t *testing.T) {
+		assert.Nil(t, repository.Delete(ctx, subscription.ID))
+
+		_, err := repository.FindByID(ctx, subscription.ID)
+		assert.ErrorContains(t, err, webhook.NotFoundError)
+		assert.ErrorContains(t, repository.Delete(ctx, subscription.ID), webhook.NotFoundError)
+	})
+}
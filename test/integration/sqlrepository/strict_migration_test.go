@@ -0,0 +1,101 @@
+package sqlrepository_test
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/sebastianreh/user-balance-api/internal/app/services"
+	"github.com/sebastianreh/user-balance-api/internal/domain/migration"
+	"github.com/sebastianreh/user-balance-api/internal/domain/transaction"
+	"github.com/sebastianreh/user-balance-api/internal/domain/user"
+	"github.com/sebastianreh/user-balance-api/internal/infrastructure/config"
+	"github.com/sebastianreh/user-balance-api/internal/infrastructure/postgresql"
+	"github.com/sebastianreh/user-balance-api/pkg/csv"
+	"github.com/sebastianreh/user-balance-api/pkg/logger"
+	"github.com/sebastianreh/user-balance-api/pkg/records"
+	"github.com/sebastianreh/user-balance-api/test/integration/sqlrepository"
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_MigrationService_StrictMode(t *testing.T) {
+	ctx := context.TODO()
+	testDb := sqlrepository.SetupTestDB(t)
+	testDb.RunMigrations(t)
+	log := logger.NewLogger()
+	defer testDb.TeardownTestDB(t)
+
+	cfg := config.NewConfig()
+	cfg.Workers.MigrationWorkersSize = 4
+	cfg.Workers.MigrationWorkerBatchSize = 10
+	userRepo := postgresql.NewSQLUserRepository(log, testDb.DB)
+	transactionRepo := postgresql.NewSQLTransactionRepository(log, testDb.DB)
+	service := services.NewMigrationService(cfg, log, userRepo, transactionRepo,
+		records.Sources{records.FormatCSV: csv.NewCsvProcessor()},
+		services.NewAlertService(log, postgresql.NewSQLAlertRepository(log, testDb.DB), userRepo,
+			postgresql.NewSQLBalanceRepository(log, testDb.DB)))
+	strictOptions := migration.Options{Mode: migration.ModeStrict}
+	userID := testDb.CreateUser(t, user.User{
+		FirstName: "user",
+		LastName:  "lastname",
+		Email:     "user@email.com",
+	})
+
+	t.Run("When every batch is valid the whole file is saved", func(t *testing.T) {
+		defer testDb.CleanTransactions(t)
+
+		summary, err := service.ProcessBalanceWithOptions(ctx, newStrictFile(userID, 200, ""), strictOptions,
+			migration.Hooks{})
+
+		assert.Nil(t, err)
+		assert.Equal(t, 200, summary.TotalRecords)
+		assert.Equal(t, 200, testDb.CountRows(t, "transactions"))
+		assert.Equal(t, 0, testDb.CountRows(t, "transaction_stages"))
+	})
+
+	t.Run("When the last batch fails no row of the file survives", func(t *testing.T) {
+		defer testDb.CleanTransactions(t)
+		// The last record repeats the id of the first one, every other batch is valid
+		_, err := service.ProcessBalanceWithOptions(ctx, newStrictFile(userID, 200, "1"), strictOptions,
+			migration.Hooks{})
+
+		assert.ErrorContains(t, err, transaction.DuplicateTransactionError)
+		assert.Equal(t, 0, testDb.CountRows(t, "transactions"))
+		assert.Equal(t, 0, testDb.CountRows(t, "transaction_stages"))
+	})
+
+	t.Run("When a late record belongs to an unknown user no row of the file survives", func(t *testing.T) {
+		defer testDb.CleanTransactions(t)
+		open := func() (io.ReadCloser, error) {
+			src, _ := newStrictFile(userID, 200, "")()
+			content, _ := io.ReadAll(src)
+			content = append(content, []byte("201,3426985345123341,10.00,2024-09-13T10:00:00Z\n")...)
+			return io.NopCloser(strings.NewReader(string(content))), nil
+		}
+
+		_, err := service.ProcessBalanceWithOptions(ctx, open, strictOptions, migration.Hooks{})
+
+		assert.ErrorContains(t, err, user.NotFoundError)
+		assert.Equal(t, 0, testDb.CountRows(t, "transactions"))
+		assert.Equal(t, 0, testDb.CountRows(t, "transaction_stages"))
+	})
+}
+
+// newStrictFile builds a file of records for the user, a non empty lastID is appended as one more record
+func newStrictFile(userID string, records int, lastID string) records.Opener {
+	return func() (io.ReadCloser, error) {
+		var builder strings.Builder
+		builder.WriteString("id,user_id,amount,datetime\n")
+		for i := 1; i <= records; i++ {
+			builder.WriteString(fmt.Sprintf("%d,%s,10.00,2024-09-13T10:00:00Z\n", i, userID))
+		}
+
+		if lastID != "" {
+			builder.WriteString(fmt.Sprintf("%s,%s,10.00,2024-09-13T10:00:00Z\n", lastID, userID))
+		}
+
+		return io.NopCloser(strings.NewReader(builder.String())), nil
+	}
+}
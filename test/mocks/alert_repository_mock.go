@@ -0,0 +1,62 @@
+package mocks
+
+import (
+	"context"
+
+	"github.com/sebastianreh/user-balance-api/internal/domain/alert"
+	"github.com/sebastianreh/user-balance-api/internal/domain/outbox"
+	"github.com/stretchr/testify/mock"
+)
+
+type AlertRepositoryMock struct {
+	mock.Mock
+}
+
+func NewAlertRepositoryMock() *AlertRepositoryMock {
+	return new(AlertRepositoryMock)
+}
+
+func (m *AlertRepositoryMock) SaveRule(ctx context.Context, rule alert.Rule) (alert.Rule, error) {
+	args := m.Called(ctx, rule)
+	return args.Get(0).(alert.Rule), args.Error(1)
+}
+
+func (m *AlertRepositoryMock) UpdateRule(ctx context.Context, rule alert.Rule) (alert.Rule, error) {
+	args := m.Called(ctx, rule)
+	return args.Get(0).(alert.Rule), args.Error(1)
+}
+
+func (m *AlertRepositoryMock) FindRule(ctx context.Context, userID, ruleID string) (alert.Rule, error) {
+	args := m.Called(ctx, userID, ruleID)
+	return args.Get(0).(alert.Rule), args.Error(1)
+}
+
+func (m *AlertRepositoryMock) ListRules(ctx context.Context, userID string) ([]alert.Rule, error) {
+	args := m.Called(ctx, userID)
+	return args.Get(0).([]alert.Rule), args.Error(1)
+}
+
+func (m *AlertRepositoryMock) DeleteRule(ctx context.Context, userID, ruleID string) error {
+	args := m.Called(ctx, userID, ruleID)
+	return args.Error(0)
+}
+
+func (m *AlertRepositoryMock) FindActiveRules(ctx context.Context, userIDs []string) ([]alert.Rule, error) {
+	args := m.Called(ctx, userIDs)
+	return args.Get(0).([]alert.Rule), args.Error(1)
+}
+
+func (m *AlertRepositoryMock) Fire(ctx context.Context, firedAlert alert.Alert, message outbox.Message) (bool, error) {
+	args := m.Called(ctx, firedAlert, message)
+	return args.Bool(0), args.Error(1)
+}
+
+func (m *AlertRepositoryMock) Clear(ctx context.Context, ruleIDs []string) error {
+	args := m.Called(ctx, ruleIDs)
+	return args.Error(0)
+}
+
+func (m *AlertRepositoryMock) ListAlerts(ctx context.Context, options alert.ListOptions) ([]alert.Alert, error) {
+	args := m.Called(ctx, options)
+	return args.Get(0).([]alert.Alert), args.Error(1)
+}
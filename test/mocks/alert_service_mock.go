@@ -0,0 +1,52 @@
+package mocks
+
+import (
+	"context"
+
+	"github.com/sebastianreh/user-balance-api/internal/domain/alert"
+	"github.com/sebastianreh/user-balance-api/internal/domain/transaction"
+	"github.com/stretchr/testify/mock"
+)
+
+type AlertServiceMock struct {
+	mock.Mock
+}
+
+func NewAlertServiceMock() *AlertServiceMock {
+	return new(AlertServiceMock)
+}
+
+func (m *AlertServiceMock) CreateRule(ctx context.Context, rule alert.Rule) (alert.Rule, error) {
+	args := m.Called(ctx, rule)
+	return args.Get(0).(alert.Rule), args.Error(1)
+}
+
+func (m *AlertServiceMock) UpdateRule(ctx context.Context, rule alert.Rule) (alert.Rule, error) {
+	args := m.Called(ctx, rule)
+	return args.Get(0).(alert.Rule), args.Error(1)
+}
+
+func (m *AlertServiceMock) GetRule(ctx context.Context, userID, ruleID string) (alert.Rule, error) {
+	args := m.Called(ctx, userID, ruleID)
+	return args.Get(0).(alert.Rule), args.Error(1)
+}
+
+func (m *AlertServiceMock) ListRules(ctx context.Context, userID string) ([]alert.Rule, error) {
+	args := m.Called(ctx, userID)
+	return args.Get(0).([]alert.Rule), args.Error(1)
+}
+
+func (m *AlertServiceMock) DeleteRule(ctx context.Context, userID, ruleID string) error {
+	args := m.Called(ctx, userID, ruleID)
+	return args.Error(0)
+}
+
+func (m *AlertServiceMock) ListAlerts(ctx context.Context, options alert.ListOptions) (alert.ListPage, error) {
+	args := m.Called(ctx, options)
+	return args.Get(0).(alert.ListPage), args.Error(1)
+}
+
+func (m *AlertServiceMock) Check(ctx context.Context, userIDs []string, transactions []transaction.Transaction) error {
+	args := m.Called(ctx, userIDs, transactions)
+	return args.Error(0)
+}
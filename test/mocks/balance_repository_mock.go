@@ -0,0 +1,28 @@
+package mocks
+
+import (
+	"context"
+
+	"github.com/sebastianreh/user-balance-api/internal/domain/balance"
+	"github.com/stretchr/testify/mock"
+)
+
+type BalanceRepositoryMock struct {
+	mock.Mock
+}
+
+func NewBalanceRepositoryMock() *BalanceRepositoryMock {
+	return new(BalanceRepositoryMock)
+}
+
+func (m *BalanceRepositoryMock) FindByUserIDs(ctx context.Context, userIDs []string, fromDate,
+	toDate string) (map[string]balance.UserBalance, error) {
+	args := m.Called(ctx, userIDs, fromDate, toDate)
+	return args.Get(0).(map[string]balance.UserBalance), args.Error(1)
+}
+
+func (m *BalanceRepositoryMock) FindByUserID(ctx context.Context, userID, fromDate,
+	toDate string) (balance.UserBalance, error) {
+	args := m.Called(ctx, userID, fromDate, toDate)
+	return args.Get(0).(balance.UserBalance), args.Error(1)
+}
@@ -25,3 +25,8 @@ func (m *BalanceServiceMock) GetBalanceByUserID(ctx context.Context, userID stri
 	args := m.Called(ctx, userID)
 	return args.Get(0).(balance.UserBalance), args.Error(1)
 }
+
+func (m *BalanceServiceMock) GetBalancesByUserIDs(ctx context.Context, query balance.BatchQuery) (balance.BatchResult, error) {
+	args := m.Called(ctx, query)
+	return args.Get(0).(balance.BatchResult), args.Error(1)
+}
@@ -0,0 +1,30 @@
+package mocks
+
+import (
+	"context"
+
+	"github.com/sebastianreh/user-balance-api/internal/domain/balance"
+	"github.com/sebastianreh/user-balance-api/pkg/pubsub"
+	"github.com/stretchr/testify/mock"
+)
+
+type BalanceStreamServiceMock struct {
+	mock.Mock
+}
+
+func NewBalanceStreamServiceMock() *BalanceStreamServiceMock {
+	return new(BalanceStreamServiceMock)
+}
+
+func (m *BalanceStreamServiceMock) Subscribe(ctx context.Context, userID string) (*pubsub.Subscription, error) {
+	args := m.Called(ctx, userID)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*pubsub.Subscription), args.Error(1)
+}
+
+func (m *BalanceStreamServiceMock) Current(ctx context.Context, userID string) (balance.UserBalance, error) {
+	args := m.Called(ctx, userID)
+	return args.Get(0).(balance.UserBalance), args.Error(1)
+}
@@ -0,0 +1,21 @@
+package mocks
+
+import (
+	"context"
+
+	"github.com/sebastianreh/user-balance-api/internal/domain/change"
+	"github.com/stretchr/testify/mock"
+)
+
+type ChangeRepositoryMock struct {
+	mock.Mock
+}
+
+func NewChangeRepositoryMock() *ChangeRepositoryMock {
+	return new(ChangeRepositoryMock)
+}
+
+func (m *ChangeRepositoryMock) List(ctx context.Context, options change.ListOptions) ([]change.Change, error) {
+	args := m.Called(ctx, options)
+	return args.Get(0).([]change.Change), args.Error(1)
+}
@@ -0,0 +1,21 @@
+package mocks
+
+import (
+	"context"
+
+	"github.com/sebastianreh/user-balance-api/internal/domain/change"
+	"github.com/stretchr/testify/mock"
+)
+
+type ChangeServiceMock struct {
+	mock.Mock
+}
+
+func NewChangeServiceMock() *ChangeServiceMock {
+	return new(ChangeServiceMock)
+}
+
+func (m *ChangeServiceMock) ListChanges(ctx context.Context, options change.ListOptions) (change.ListPage, error) {
+	args := m.Called(ctx, options)
+	return args.Get(0).(change.ListPage), args.Error(1)
+}
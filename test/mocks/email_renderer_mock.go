@@ -0,0 +1,18 @@
+package mocks
+
+import (
+	"github.com/stretchr/testify/mock"
+)
+
+type EmailRendererMock struct {
+	mock.Mock
+}
+
+func NewEmailRendererMock() *EmailRendererMock {
+	return new(EmailRendererMock)
+}
+
+func (m *EmailRendererMock) Render(name string, data any) (string, error) {
+	args := m.Called(name, data)
+	return args.String(0), args.Error(1)
+}
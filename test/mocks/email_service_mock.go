@@ -1,6 +1,7 @@
 package mocks
 
 import (
+	"github.com/sebastianreh/user-balance-api/pkg/email"
 	"github.com/stretchr/testify/mock"
 )
 
@@ -16,3 +17,8 @@ func (m *EmailServiceMock) SendEmail(to []string, subject, body string) error {
 	args := m.Called(to, subject, body)
 	return args.Error(0)
 }
+
+func (m *EmailServiceMock) Send(message email.Message) error {
+	args := m.Called(message)
+	return args.Error(0)
+}
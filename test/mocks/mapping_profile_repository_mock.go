@@ -0,0 +1,37 @@
+package mocks
+
+import (
+	"context"
+
+	"github.com/sebastianreh/user-balance-api/internal/domain/migration"
+	"github.com/stretchr/testify/mock"
+)
+
+type MappingProfileRepositoryMock struct {
+	mock.Mock
+}
+
+func NewMappingProfileRepositoryMock() *MappingProfileRepositoryMock {
+	return new(MappingProfileRepositoryMock)
+}
+
+func (m *MappingProfileRepositoryMock) Save(ctx context.Context,
+	profile migration.MappingProfile) (migration.MappingProfile, error) {
+	args := m.Called(ctx, profile)
+	return args.Get(0).(migration.MappingProfile), args.Error(1)
+}
+
+func (m *MappingProfileRepositoryMock) FindByName(ctx context.Context, name string) (migration.MappingProfile, error) {
+	args := m.Called(ctx, name)
+	return args.Get(0).(migration.MappingProfile), args.Error(1)
+}
+
+func (m *MappingProfileRepositoryMock) List(ctx context.Context) ([]migration.MappingProfile, error) {
+	args := m.Called(ctx)
+	return args.Get(0).([]migration.MappingProfile), args.Error(1)
+}
+
+func (m *MappingProfileRepositoryMock) Delete(ctx context.Context, name string) error {
+	args := m.Called(ctx, name)
+	return args.Error(0)
+}
@@ -0,0 +1,94 @@
+package mocks
+
+import (
+	"context"
+	"time"
+
+	"github.com/sebastianreh/user-balance-api/internal/domain/migration"
+	"github.com/sebastianreh/user-balance-api/internal/domain/outbox"
+	"github.com/sebastianreh/user-balance-api/internal/domain/report"
+	"github.com/stretchr/testify/mock"
+)
+
+type MigrationJobRepositoryMock struct {
+	mock.Mock
+}
+
+func NewMigrationJobRepositoryMock() *MigrationJobRepositoryMock {
+	return new(MigrationJobRepositoryMock)
+}
+
+func (m *MigrationJobRepositoryMock) Save(ctx context.Context, job migration.Job) (string, error) {
+	args := m.Called(ctx, job)
+	return args.String(0), args.Error(1)
+}
+
+func (m *MigrationJobRepositoryMock) Update(ctx context.Context, job migration.Job) error {
+	args := m.Called(ctx, job)
+	return args.Error(0)
+}
+
+func (m *MigrationJobRepositoryMock) Finish(ctx context.Context, job migration.Job,
+	messages ...outbox.Message) error {
+	args := m.Called(ctx, job, messages)
+	return args.Error(0)
+}
+
+func (m *MigrationJobRepositoryMock) FindByID(ctx context.Context, jobID string) (migration.Job, error) {
+	args := m.Called(ctx, jobID)
+	return args.Get(0).(migration.Job), args.Error(1)
+}
+
+func (m *MigrationJobRepositoryMock) FindImported(ctx context.Context, checksum,
+	contentHash string) (migration.Job, error) {
+	args := m.Called(ctx, checksum, contentHash)
+	return args.Get(0).(migration.Job), args.Error(1)
+}
+
+func (m *MigrationJobRepositoryMock) Heartbeat(ctx context.Context, jobID string) error {
+	args := m.Called(ctx, jobID)
+	return args.Error(0)
+}
+
+func (m *MigrationJobRepositoryMock) FindStale(ctx context.Context, staleBefore time.Time) ([]migration.Job, error) {
+	args := m.Called(ctx, staleBefore)
+	return args.Get(0).([]migration.Job), args.Error(1)
+}
+
+func (m *MigrationJobRepositoryMock) FailStale(ctx context.Context, staleBefore time.Time, reason string) (int64, error) {
+	args := m.Called(ctx, staleBefore, reason)
+	return args.Get(0).(int64), args.Error(1)
+}
+
+func (m *MigrationJobRepositoryMock) Saved(ctx context.Context, jobID string) (report.MigrationSummary, error) {
+	args := m.Called(ctx, jobID)
+	return args.Get(0).(report.MigrationSummary), args.Error(1)
+}
+
+func (m *MigrationJobRepositoryMock) SaveRejects(ctx context.Context, jobID string, rejects []migration.Reject) error {
+	args := m.Called(ctx, jobID, rejects)
+	return args.Error(0)
+}
+
+func (m *MigrationJobRepositoryMock) ForEachReject(ctx context.Context, jobID string,
+	handle func(reject migration.Reject) error) error {
+	args := m.Called(ctx, jobID, handle)
+	for _, reject := range args.Get(0).([]migration.Reject) {
+		if err := handle(reject); err != nil {
+			return err
+		}
+	}
+
+	return args.Error(1)
+}
+
+func (m *MigrationJobRepositoryMock) List(ctx context.Context, options migration.ListOptions) ([]migration.Job, error) {
+	args := m.Called(ctx, options)
+	return args.Get(0).([]migration.Job), args.Error(1)
+}
+
+func (m *MigrationJobRepositoryMock) Rollback(ctx context.Context, jobID string,
+	messages ...outbox.Message) (int64, error) {
+	args := m.Called(ctx, jobID, messages)
+	return args.Get(0).(int64), args.Error(1)
+}
@@ -0,0 +1,81 @@
+package mocks
+
+import (
+	"context"
+	"mime/multipart"
+
+	"github.com/sebastianreh/user-balance-api/internal/app/services"
+	"github.com/sebastianreh/user-balance-api/internal/domain/migration"
+	"github.com/sebastianreh/user-balance-api/internal/domain/transaction"
+	"github.com/stretchr/testify/mock"
+)
+
+type MigrationJobServiceMock struct {
+	mock.Mock
+}
+
+func NewMigrationJobServiceMock() *MigrationJobServiceMock {
+	return new(MigrationJobServiceMock)
+}
+
+func (m *MigrationJobServiceMock) StartMigration(ctx context.Context, file *multipart.FileHeader,
+	options migration.Options) (migration.Job, error) {
+	args := m.Called(ctx, file, options)
+	return args.Get(0).(migration.Job), args.Error(1)
+}
+
+func (m *MigrationJobServiceMock) RunMigration(ctx context.Context, file *multipart.FileHeader,
+	options migration.Options) (migration.Job, error) {
+	args := m.Called(ctx, file, options)
+	return args.Get(0).(migration.Job), args.Error(1)
+}
+
+func (m *MigrationJobServiceMock) StartFileMigration(ctx context.Context, file services.MigrationFile,
+	options migration.Options) (migration.Job, error) {
+	args := m.Called(ctx, file, options)
+	return args.Get(0).(migration.Job), args.Error(1)
+}
+
+func (m *MigrationJobServiceMock) RunFileMigration(ctx context.Context, file services.MigrationFile,
+	options migration.Options) (migration.Job, error) {
+	args := m.Called(ctx, file, options)
+	return args.Get(0).(migration.Job), args.Error(1)
+}
+
+func (m *MigrationJobServiceMock) ForEachReject(ctx context.Context, jobID string,
+	handle func(reject migration.Reject) error) error {
+	args := m.Called(ctx, jobID, handle)
+	for _, reject := range args.Get(0).([]migration.Reject) {
+		if err := handle(reject); err != nil {
+			return err
+		}
+	}
+
+	return args.Error(1)
+}
+
+func (m *MigrationJobServiceMock) GetJob(ctx context.Context, jobID string) (migration.Job, error) {
+	args := m.Called(ctx, jobID)
+	return args.Get(0).(migration.Job), args.Error(1)
+}
+
+func (m *MigrationJobServiceMock) ListJobs(ctx context.Context, options migration.ListOptions) (migration.ListPage, error) {
+	args := m.Called(ctx, options)
+	return args.Get(0).(migration.ListPage), args.Error(1)
+}
+
+func (m *MigrationJobServiceMock) ListTransactions(ctx context.Context, jobID string,
+	options migration.ListOptions) (transaction.ListPage, error) {
+	args := m.Called(ctx, jobID, options)
+	return args.Get(0).(transaction.ListPage), args.Error(1)
+}
+
+func (m *MigrationJobServiceMock) Rollback(ctx context.Context, jobID string) (migration.Job, error) {
+	args := m.Called(ctx, jobID)
+	return args.Get(0).(migration.Job), args.Error(1)
+}
+
+func (m *MigrationJobServiceMock) FailInterruptedJobs(ctx context.Context) error {
+	args := m.Called(ctx)
+	return args.Error(0)
+}
@@ -4,7 +4,9 @@ import (
 	"context"
 	"mime/multipart"
 
+	"github.com/sebastianreh/user-balance-api/internal/domain/migration"
 	"github.com/sebastianreh/user-balance-api/internal/domain/report"
+	"github.com/sebastianreh/user-balance-api/pkg/records"
 	"github.com/stretchr/testify/mock"
 )
 
@@ -20,3 +22,15 @@ func (m *MigrationServiceMock) ProcessBalance(ctx context.Context, file *multipa
 	args := m.Called(ctx, file)
 	return args.Get(0).(report.MigrationSummary), args.Error(1)
 }
+
+func (m *MigrationServiceMock) ProcessBalanceWithOptions(ctx context.Context, open records.Opener,
+	options migration.Options, hooks migration.Hooks) (report.MigrationSummary, error) {
+	args := m.Called(ctx, open, options, hooks)
+	return args.Get(0).(report.MigrationSummary), args.Error(1)
+}
+
+func (m *MigrationServiceMock) ValidateBalance(ctx context.Context, open records.Opener,
+	options migration.Options) (migration.ValidationReport, error) {
+	args := m.Called(ctx, open, options)
+	return args.Get(0).(migration.ValidationReport), args.Error(1)
+}
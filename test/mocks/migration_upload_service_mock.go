@@ -0,0 +1,60 @@
+package mocks
+
+import (
+	"context"
+	"io"
+
+	"github.com/sebastianreh/user-balance-api/internal/domain/migration"
+	"github.com/sebastianreh/user-balance-api/internal/domain/upload"
+	"github.com/stretchr/testify/mock"
+)
+
+type MigrationUploadServiceMock struct {
+	mock.Mock
+}
+
+func NewMigrationUploadServiceMock() *MigrationUploadServiceMock {
+	return new(MigrationUploadServiceMock)
+}
+
+func (m *MigrationUploadServiceMock) CreateUpload(ctx context.Context, fileName string,
+	size int64) (upload.Upload, error) {
+	args := m.Called(ctx, fileName, size)
+	return args.Get(0).(upload.Upload), args.Error(1)
+}
+
+func (m *MigrationUploadServiceMock) GetUpload(ctx context.Context, uploadID string) (upload.Upload, error) {
+	args := m.Called(ctx, uploadID)
+	return args.Get(0).(upload.Upload), args.Error(1)
+}
+
+func (m *MigrationUploadServiceMock) WriteChunk(ctx context.Context, uploadID string, chunk upload.Chunk,
+	content io.Reader) (upload.Upload, error) {
+	args := m.Called(ctx, uploadID, chunk, content)
+	return args.Get(0).(upload.Upload), args.Error(1)
+}
+
+func (m *MigrationUploadServiceMock) OpenUpload(ctx context.Context, uploadID string) (io.ReadCloser, error) {
+	args := m.Called(ctx, uploadID)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+
+	return args.Get(0).(io.ReadCloser), args.Error(1)
+}
+
+func (m *MigrationUploadServiceMock) FinalizeUpload(ctx context.Context, uploadID string, options migration.Options,
+	async bool) (migration.Job, error) {
+	args := m.Called(ctx, uploadID, options, async)
+	return args.Get(0).(migration.Job), args.Error(1)
+}
+
+func (m *MigrationUploadServiceMock) DeleteUpload(ctx context.Context, uploadID string) error {
+	args := m.Called(ctx, uploadID)
+	return args.Error(0)
+}
+
+func (m *MigrationUploadServiceMock) DeleteExpiredUploads(ctx context.Context) error {
+	args := m.Called(ctx)
+	return args.Error(0)
+}
@@ -0,0 +1,27 @@
+package mocks
+
+import (
+	"context"
+
+	"github.com/sebastianreh/user-balance-api/internal/domain/outbox"
+	"github.com/sebastianreh/user-balance-api/pkg/notifier"
+	"github.com/stretchr/testify/mock"
+)
+
+type NotificationServiceMock struct {
+	mock.Mock
+}
+
+func NewNotificationServiceMock() *NotificationServiceMock {
+	return new(NotificationServiceMock)
+}
+
+func (m *NotificationServiceMock) Messages(notification notifier.Notification) ([]outbox.Message, error) {
+	args := m.Called(notification)
+	return args.Get(0).([]outbox.Message), args.Error(1)
+}
+
+func (m *NotificationServiceMock) Notify(ctx context.Context, notification notifier.Notification) error {
+	args := m.Called(ctx, notification)
+	return args.Error(0)
+}
@@ -0,0 +1,26 @@
+package mocks
+
+import (
+	"context"
+
+	"github.com/sebastianreh/user-balance-api/pkg/notifier"
+	"github.com/stretchr/testify/mock"
+)
+
+type NotifierMock struct {
+	mock.Mock
+}
+
+func NewNotifierMock() *NotifierMock {
+	return new(NotifierMock)
+}
+
+func (m *NotifierMock) Deliveries(notification notifier.Notification) []notifier.Delivery {
+	args := m.Called(notification)
+	return args.Get(0).([]notifier.Delivery)
+}
+
+func (m *NotifierMock) Deliver(ctx context.Context, delivery notifier.Delivery) error {
+	args := m.Called(ctx, delivery)
+	return args.Error(0)
+}
@@ -0,0 +1,58 @@
+package mocks
+
+import (
+	"context"
+	"time"
+
+	"github.com/sebastianreh/user-balance-api/internal/domain/outbox"
+	"github.com/stretchr/testify/mock"
+)
+
+type OutboxRepositoryMock struct {
+	mock.Mock
+}
+
+func NewOutboxRepositoryMock() *OutboxRepositoryMock {
+	return new(OutboxRepositoryMock)
+}
+
+func (m *OutboxRepositoryMock) Save(ctx context.Context, messages ...outbox.Message) error {
+	args := m.Called(ctx, messages)
+	return args.Error(0)
+}
+
+func (m *OutboxRepositoryMock) ClaimDue(ctx context.Context, limit int, lease time.Duration) ([]outbox.Message, error) {
+	args := m.Called(ctx, limit, lease)
+	return args.Get(0).([]outbox.Message), args.Error(1)
+}
+
+func (m *OutboxRepositoryMock) MarkSent(ctx context.Context, messageID string) error {
+	args := m.Called(ctx, messageID)
+	return args.Error(0)
+}
+
+func (m *OutboxRepositoryMock) MarkFailed(ctx context.Context, messageID, attemptError string,
+	nextAttemptAt *time.Time) error {
+	args := m.Called(ctx, messageID, attemptError, nextAttemptAt)
+	return args.Error(0)
+}
+
+func (m *OutboxRepositoryMock) List(ctx context.Context, options outbox.ListOptions) ([]outbox.Message, error) {
+	args := m.Called(ctx, options)
+	return args.Get(0).([]outbox.Message), args.Error(1)
+}
+
+func (m *OutboxRepositoryMock) FindByID(ctx context.Context, messageID string) (outbox.Message, error) {
+	args := m.Called(ctx, messageID)
+	return args.Get(0).(outbox.Message), args.Error(1)
+}
+
+func (m *OutboxRepositoryMock) Redrive(ctx context.Context, messageID string) (outbox.Message, error) {
+	args := m.Called(ctx, messageID)
+	return args.Get(0).(outbox.Message), args.Error(1)
+}
+
+func (m *OutboxRepositoryMock) Duplicate(ctx context.Context, messageID string) (outbox.Message, error) {
+	args := m.Called(ctx, messageID)
+	return args.Get(0).(outbox.Message), args.Error(1)
+}
@@ -0,0 +1,40 @@
+package mocks
+
+import (
+	"context"
+
+	"github.com/sebastianreh/user-balance-api/internal/domain/outbox"
+	"github.com/stretchr/testify/mock"
+)
+
+type OutboxServiceMock struct {
+	mock.Mock
+}
+
+func NewOutboxServiceMock() *OutboxServiceMock {
+	return new(OutboxServiceMock)
+}
+
+func (m *OutboxServiceMock) Start(ctx context.Context) {
+	m.Called(ctx)
+}
+
+func (m *OutboxServiceMock) Dispatch(ctx context.Context) (int, error) {
+	args := m.Called(ctx)
+	return args.Int(0), args.Error(1)
+}
+
+func (m *OutboxServiceMock) ListMessages(ctx context.Context, options outbox.ListOptions) (outbox.ListPage, error) {
+	args := m.Called(ctx, options)
+	return args.Get(0).(outbox.ListPage), args.Error(1)
+}
+
+func (m *OutboxServiceMock) GetMessage(ctx context.Context, messageID string) (outbox.Message, error) {
+	args := m.Called(ctx, messageID)
+	return args.Get(0).(outbox.Message), args.Error(1)
+}
+
+func (m *OutboxServiceMock) RedriveMessage(ctx context.Context, messageID string) (outbox.Message, error) {
+	args := m.Called(ctx, messageID)
+	return args.Get(0).(outbox.Message), args.Error(1)
+}
@@ -0,0 +1,40 @@
+package mocks
+
+import (
+	"context"
+	"io"
+
+	"github.com/sebastianreh/user-balance-api/pkg/records"
+	"github.com/stretchr/testify/mock"
+)
+
+type RecordSourceMock struct {
+	mock.Mock
+}
+
+func NewRecordSourceMock() *RecordSourceMock {
+	return new(RecordSourceMock)
+}
+
+func (m *RecordSourceMock) Validate(src io.Reader, mapping *records.Mapping,
+	recordValidator func(record []string) error) (int, error) {
+	args := m.Called(src, mapping, recordValidator)
+	return args.Int(0), args.Error(1)
+}
+
+func (m *RecordSourceMock) Count(src io.Reader) (int, error) {
+	args := m.Called(src)
+	return args.Int(0), args.Error(1)
+}
+
+// StreamBatches sends the batches given in the first return value and closes the channel like the real source
+func (m *RecordSourceMock) StreamBatches(ctx context.Context, src io.Reader, mapping *records.Mapping, batchSize int,
+	batches chan<- records.Batch) error {
+	defer close(batches)
+	args := m.Called(ctx, src, mapping, batchSize, batches)
+	for _, batch := range args.Get(0).([]records.Batch) {
+		batches <- batch
+	}
+
+	return args.Error(1)
+}
@@ -1,6 +1,9 @@
 package mocks
 
 import (
+	"context"
+
+	"github.com/sebastianreh/user-balance-api/internal/domain/outbox"
 	"github.com/sebastianreh/user-balance-api/internal/domain/report"
 	"github.com/stretchr/testify/mock"
 )
@@ -13,7 +16,8 @@ func NewReportServiceMock() *ReportServiceMock {
 	return new(ReportServiceMock)
 }
 
-func (m *ReportServiceMock) GenerateAndSendReport(summary report.MigrationSummary, to []string) error {
-	args := m.Called(summary, to)
-	return args.Error(0)
+func (m *ReportServiceMock) GenerateReport(ctx context.Context, summary report.MigrationSummary,
+	to []string) (outbox.Message, error) {
+	args := m.Called(ctx, summary, to)
+	return args.Get(0).(outbox.Message), args.Error(1)
 }
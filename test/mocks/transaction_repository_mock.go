@@ -3,6 +3,7 @@ package mocks
 import (
 	"context"
 
+	"github.com/sebastianreh/user-balance-api/internal/domain/outbox"
 	"github.com/sebastianreh/user-balance-api/internal/domain/transaction"
 	"github.com/stretchr/testify/mock"
 )
@@ -15,8 +16,9 @@ func NewTransactionRepositoryMock() *TransactionRepositoryMock {
 	return new(TransactionRepositoryMock)
 }
 
-func (m *TransactionRepositoryMock) Save(ctx context.Context, transactionEntity transaction.Transaction) error {
-	args := m.Called(ctx, transactionEntity)
+func (m *TransactionRepositoryMock) Save(ctx context.Context, transactionEntity transaction.Transaction,
+	messages ...outbox.Message) error {
+	args := m.Called(ctx, transactionEntity, messages)
 	return args.Error(0)
 }
 
@@ -30,8 +32,9 @@ func (m *TransactionRepositoryMock) Update(ctx context.Context, transactionEntit
 	return args.Error(0)
 }
 
-func (m *TransactionRepositoryMock) Delete(ctx context.Context, transactionID string) error {
-	args := m.Called(ctx, transactionID)
+func (m *TransactionRepositoryMock) Delete(ctx context.Context, transactionID string,
+	messages ...outbox.Message) error {
+	args := m.Called(ctx, transactionID, messages)
 	return args.Error(0)
 }
 
@@ -45,3 +48,69 @@ func (m *TransactionRepositoryMock) FindByUserIDWithOptions(ctx context.Context,
 	args := m.Called(ctx, userID, fromDate, toDate)
 	return args.Get(0).([]transaction.Transaction), args.Error(1)
 }
+
+func (m *TransactionRepositoryMock) List(ctx context.Context, options transaction.ListOptions) ([]transaction.ListItem, error) {
+	args := m.Called(ctx, options)
+	return args.Get(0).([]transaction.ListItem), args.Error(1)
+}
+
+func (m *TransactionRepositoryMock) ListByMigration(ctx context.Context, migrationID, afterID string,
+	limit int) ([]transaction.ListItem, error) {
+	args := m.Called(ctx, migrationID, afterID, limit)
+	return args.Get(0).([]transaction.ListItem), args.Error(1)
+}
+
+func (m *TransactionRepositoryMock) SaveBatchSkippingRejected(ctx context.Context,
+	transactions []transaction.Transaction) ([]transaction.Rejection, error) {
+	args := m.Called(ctx, transactions)
+	return args.Get(0).([]transaction.Rejection), args.Error(1)
+}
+
+func (m *TransactionRepositoryMock) CreateStage(ctx context.Context) (string, error) {
+	args := m.Called(ctx)
+	return args.String(0), args.Error(1)
+}
+
+func (m *TransactionRepositoryMock) StageBatch(ctx context.Context, stageID string,
+	transactions []transaction.Transaction) error {
+	args := m.Called(ctx, stageID, transactions)
+	return args.Error(0)
+}
+
+func (m *TransactionRepositoryMock) CommitStage(ctx context.Context, stageID string) (int64, error) {
+	args := m.Called(ctx, stageID)
+	return args.Get(0).(int64), args.Error(1)
+}
+
+func (m *TransactionRepositoryMock) DiscardStage(ctx context.Context, stageID string) error {
+	args := m.Called(ctx, stageID)
+	return args.Error(0)
+}
+
+func (m *TransactionRepositoryMock) FindMigrationUserIDs(ctx context.Context, migrationID string) ([]string, error) {
+	args := m.Called(ctx, migrationID)
+	return args.Get(0).([]string), args.Error(1)
+}
+
+func (m *TransactionRepositoryMock) FindExistingIDs(ctx context.Context, transactionIDs []string) (map[string]bool, error) {
+	args := m.Called(ctx, transactionIDs)
+	return args.Get(0).(map[string]bool), args.Error(1)
+}
+
+func (m *TransactionRepositoryMock) SummarizeMigration(ctx context.Context, migrationID string,
+	largest int) (transaction.MigrationTotals, error) {
+	args := m.Called(ctx, migrationID, largest)
+	return args.Get(0).(transaction.MigrationTotals), args.Error(1)
+}
+
+func (m *TransactionRepositoryMock) ForEachMigrationUser(ctx context.Context, migrationID string,
+	handle func(delta transaction.UserDelta) error) error {
+	args := m.Called(ctx, migrationID, handle)
+	for _, delta := range args.Get(0).([]transaction.UserDelta) {
+		if err := handle(delta); err != nil {
+			return err
+		}
+	}
+
+	return args.Error(1)
+}
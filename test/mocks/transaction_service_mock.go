@@ -34,3 +34,9 @@ func (m *TransactionServiceMock) DeleteTransaction(ctx context.Context, transact
 	args := m.Called(ctx, transactionID)
 	return args.Error(0)
 }
+
+func (m *TransactionServiceMock) ListUserTransactions(ctx context.Context,
+	options transaction.ListOptions) (transaction.ListPage, error) {
+	args := m.Called(ctx, options)
+	return args.Get(0).(transaction.ListPage), args.Error(1)
+}
@@ -0,0 +1,59 @@
+package mocks
+
+import (
+	"context"
+	"io"
+	"time"
+
+	"github.com/sebastianreh/user-balance-api/internal/domain/upload"
+	"github.com/stretchr/testify/mock"
+)
+
+type UploadRepositoryMock struct {
+	mock.Mock
+}
+
+func NewUploadRepositoryMock() *UploadRepositoryMock {
+	return new(UploadRepositoryMock)
+}
+
+func (m *UploadRepositoryMock) Create(ctx context.Context, newUpload upload.Upload) (upload.Upload, error) {
+	args := m.Called(ctx, newUpload)
+	return args.Get(0).(upload.Upload), args.Error(1)
+}
+
+func (m *UploadRepositoryMock) FindByID(ctx context.Context, uploadID string) (upload.Upload, error) {
+	args := m.Called(ctx, uploadID)
+	return args.Get(0).(upload.Upload), args.Error(1)
+}
+
+func (m *UploadRepositoryMock) Append(ctx context.Context, uploadID string, offset int64,
+	chunk io.Reader) (upload.Upload, error) {
+	args := m.Called(ctx, uploadID, offset, chunk)
+	return args.Get(0).(upload.Upload), args.Error(1)
+}
+
+func (m *UploadRepositoryMock) Claim(ctx context.Context, uploadID string) (upload.Upload, error) {
+	args := m.Called(ctx, uploadID)
+	return args.Get(0).(upload.Upload), args.Error(1)
+}
+
+func (m *UploadRepositoryMock) Release(ctx context.Context, uploadID string) error {
+	args := m.Called(ctx, uploadID)
+	return args.Error(0)
+}
+
+func (m *UploadRepositoryMock) Finish(ctx context.Context, uploadID, jobID string) error {
+	args := m.Called(ctx, uploadID, jobID)
+	return args.Error(0)
+}
+
+func (m *UploadRepositoryMock) Delete(ctx context.Context, uploadID string) error {
+	args := m.Called(ctx, uploadID)
+	return args.Error(0)
+}
+
+func (m *UploadRepositoryMock) DeleteExpired(ctx context.Context, updatedBefore time.Time) (int, error) {
+	args := m.Called(ctx, updatedBefore)
+	return args.Int(0), args.Error(1)
+}
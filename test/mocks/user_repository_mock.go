@@ -3,6 +3,7 @@ package mocks
 import (
 	"context"
 
+	"github.com/sebastianreh/user-balance-api/internal/domain/outbox"
 	"github.com/sebastianreh/user-balance-api/internal/domain/user"
 	"github.com/stretchr/testify/mock"
 )
@@ -25,8 +26,8 @@ func (m *UserRepositoryMock) Update(ctx context.Context, userEntity user.User) e
 	return args.Error(0)
 }
 
-func (m *UserRepositoryMock) Delete(ctx context.Context, userID string) error {
-	args := m.Called(ctx, userID)
+func (m *UserRepositoryMock) Delete(ctx context.Context, userID string, messages ...outbox.Message) error {
+	args := m.Called(ctx, userID, messages)
 	return args.Error(0)
 }
 
@@ -35,7 +36,30 @@ func (m *UserRepositoryMock) FindByID(ctx context.Context, userID string) (user.
 	return args.Get(0).(user.User), args.Error(1)
 }
 
+func (m *UserRepositoryMock) FindByEmail(ctx context.Context, email string) (user.User, error) {
+	args := m.Called(ctx, email)
+	return args.Get(0).(user.User), args.Error(1)
+}
+
+func (m *UserRepositoryMock) List(ctx context.Context, options user.ListOptions) ([]user.ListItem, error) {
+	args := m.Called(ctx, options)
+	return args.Get(0).([]user.ListItem), args.Error(1)
+}
+
 func (m *UserRepositoryMock) FindByTransactionID(ctx context.Context, transactionID string) (user.User, error) {
 	args := m.Called(ctx, transactionID)
 	return args.Get(0).(user.User), args.Error(1)
 }
+
+func (m *UserRepositoryMock) FindActiveIDs(ctx context.Context, userIDs []string) (map[string]bool, error) {
+	args := m.Called(ctx, userIDs)
+	return args.Get(0).(map[string]bool), args.Error(1)
+}
+
+func (m *UserRepositoryMock) CreateMissing(ctx context.Context, users []user.User) (int, map[string]bool, error) {
+	args := m.Called(ctx, users)
+	if args.Get(1) == nil {
+		return args.Int(0), nil, args.Error(2)
+	}
+	return args.Int(0), args.Get(1).(map[string]bool), args.Error(2)
+}
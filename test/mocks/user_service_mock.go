@@ -30,6 +30,16 @@ func (m *UserServiceMock) GetUser(ctx context.Context, userID string) (user.User
 	return args.Get(0).(user.User), args.Error(1)
 }
 
+func (m *UserServiceMock) GetUserByEmail(ctx context.Context, email string) (user.User, error) {
+	args := m.Called(ctx, email)
+	return args.Get(0).(user.User), args.Error(1)
+}
+
+func (m *UserServiceMock) ListUsers(ctx context.Context, options user.ListOptions) (user.ListPage, error) {
+	args := m.Called(ctx, options)
+	return args.Get(0).(user.ListPage), args.Error(1)
+}
+
 func (m *UserServiceMock) DeleteUser(ctx context.Context, userID string) error {
 	args := m.Called(ctx, userID)
 	return args.Error(0)
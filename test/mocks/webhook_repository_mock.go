@@ -0,0 +1,48 @@
+package mocks
+
+import (
+	"context"
+
+	"github.com/sebastianreh/user-balance-api/internal/domain/webhook"
+	"github.com/stretchr/testify/mock"
+)
+
+type WebhookRepositoryMock struct {
+	mock.Mock
+}
+
+func NewWebhookRepositoryMock() *WebhookRepositoryMock {
+	return new(WebhookRepositoryMock)
+}
+
+func (m *WebhookRepositoryMock) Save(ctx context.Context,
+	subscription webhook.Subscription) (webhook.Subscription, error) {
+	args := m.Called(ctx, subscription)
+	return args.Get(0).(webhook.Subscription), args.Error(1)
+}
+
+func (m *WebhookRepositoryMock) Update(ctx context.Context,
+	subscription webhook.Subscription) (webhook.Subscription, error) {
+	args := m.Called(ctx, subscription)
+	return args.Get(0).(webhook.Subscription), args.Error(1)
+}
+
+func (m *WebhookRepositoryMock) FindByID(ctx context.Context, subscriptionID string) (webhook.Subscription, error) {
+	args := m.Called(ctx, subscriptionID)
+	return args.Get(0).(webhook.Subscription), args.Error(1)
+}
+
+func (m *WebhookRepositoryMock) List(ctx context.Context) ([]webhook.Subscription, error) {
+	args := m.Called(ctx)
+	return args.Get(0).([]webhook.Subscription), args.Error(1)
+}
+
+func (m *WebhookRepositoryMock) FindByEvent(ctx context.Context, event string) ([]webhook.Subscription, error) {
+	args := m.Called(ctx, event)
+	return args.Get(0).([]webhook.Subscription), args.Error(1)
+}
+
+func (m *WebhookRepositoryMock) Delete(ctx context.Context, subscriptionID string) error {
+	args := m.Called(ctx, subscriptionID)
+	return args.Error(0)
+}
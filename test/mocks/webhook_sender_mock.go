@@ -0,0 +1,21 @@
+package mocks
+
+import (
+	"context"
+
+	"github.com/sebastianreh/user-balance-api/pkg/webhook"
+	"github.com/stretchr/testify/mock"
+)
+
+type WebhookSenderMock struct {
+	mock.Mock
+}
+
+func NewWebhookSenderMock() *WebhookSenderMock {
+	return new(WebhookSenderMock)
+}
+
+func (m *WebhookSenderMock) Send(ctx context.Context, url, secret string, request webhook.Request) error {
+	args := m.Called(ctx, url, secret, request)
+	return args.Error(0)
+}
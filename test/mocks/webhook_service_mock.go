@@ -0,0 +1,66 @@
+package mocks
+
+import (
+	"context"
+
+	"github.com/sebastianreh/user-balance-api/internal/domain/outbox"
+	"github.com/sebastianreh/user-balance-api/internal/domain/webhook"
+	"github.com/stretchr/testify/mock"
+)
+
+type WebhookServiceMock struct {
+	mock.Mock
+}
+
+func NewWebhookServiceMock() *WebhookServiceMock {
+	return new(WebhookServiceMock)
+}
+
+func (m *WebhookServiceMock) CreateSubscription(ctx context.Context,
+	subscription webhook.Subscription) (webhook.Subscription, error) {
+	args := m.Called(ctx, subscription)
+	return args.Get(0).(webhook.Subscription), args.Error(1)
+}
+
+func (m *WebhookServiceMock) UpdateSubscription(ctx context.Context,
+	subscription webhook.Subscription) (webhook.Subscription, error) {
+	args := m.Called(ctx, subscription)
+	return args.Get(0).(webhook.Subscription), args.Error(1)
+}
+
+func (m *WebhookServiceMock) GetSubscription(ctx context.Context, subscriptionID string) (webhook.Subscription, error) {
+	args := m.Called(ctx, subscriptionID)
+	return args.Get(0).(webhook.Subscription), args.Error(1)
+}
+
+func (m *WebhookServiceMock) ListSubscriptions(ctx context.Context) ([]webhook.Subscription, error) {
+	args := m.Called(ctx)
+	return args.Get(0).([]webhook.Subscription), args.Error(1)
+}
+
+func (m *WebhookServiceMock) DeleteSubscription(ctx context.Context, subscriptionID string) error {
+	args := m.Called(ctx, subscriptionID)
+	return args.Error(0)
+}
+
+func (m *WebhookServiceMock) ListDeliveries(ctx context.Context, subscriptionID string,
+	options outbox.ListOptions) (outbox.ListPage, error) {
+	args := m.Called(ctx, subscriptionID, options)
+	return args.Get(0).(outbox.ListPage), args.Error(1)
+}
+
+func (m *WebhookServiceMock) ReplayDelivery(ctx context.Context, subscriptionID,
+	deliveryID string) (outbox.Message, error) {
+	args := m.Called(ctx, subscriptionID, deliveryID)
+	return args.Get(0).(outbox.Message), args.Error(1)
+}
+
+func (m *WebhookServiceMock) Messages(ctx context.Context, eventType string, data any) ([]outbox.Message, error) {
+	args := m.Called(ctx, eventType, data)
+	return args.Get(0).([]outbox.Message), args.Error(1)
+}
+
+func (m *WebhookServiceMock) Deliver(ctx context.Context, delivery webhook.Delivery) error {
+	args := m.Called(ctx, delivery)
+	return args.Error(0)
+}